@@ -1,15 +1,30 @@
 module github.com/iqiyi/dpvs/tools/healthcheck
 
-go 1.20
+go 1.22
 
 require (
 	github.com/golang/glog v1.2.4
 	github.com/google/gops v0.3.28
+	github.com/quic-go/quic-go v0.48.2
 	github.com/vishvananda/netlink v1.3.0
+	github.com/vishvananda/netns v0.0.4
+	golang.org/x/net v0.30.0
 	gopkg.in/yaml.v2 v2.4.0
 )
 
 require (
-	github.com/vishvananda/netns v0.0.4 // indirect
+	github.com/go-task/slim-sprig v0.0.0-20230315185526-52ccab3ef572 // indirect
+	github.com/google/pprof v0.0.0-20210407192527-94a9f03dee38 // indirect
+	github.com/onsi/ginkgo/v2 v2.9.5 // indirect
+	github.com/quic-go/qpack v0.5.1 // indirect
+	go.uber.org/mock v0.4.0 // indirect
+	golang.org/x/crypto v0.28.0 // indirect
+	golang.org/x/exp v0.0.0-20240506185415-9bf2ced13842 // indirect
+	golang.org/x/mod v0.17.0 // indirect
+	golang.org/x/tools v0.21.1-0.20240508182429-e35e4ccd0d2d // indirect
+)
+
+require (
 	golang.org/x/sys v0.26.0
+	golang.org/x/text v0.19.0 // indirect
 )
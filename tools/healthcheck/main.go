@@ -18,7 +18,9 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"math/rand"
+	"os"
 	"strings"
 	"time"
 
@@ -26,6 +28,7 @@ import (
 	gops "github.com/google/gops/agent"
 
 	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/manager"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/preflight"
 	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/types"
 	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/utils"
 )
@@ -88,6 +91,47 @@ func init() {
 	metricDelay := flag.Duration("metric-delay",
 		types.DefaultAppConf.MetricDelay,
 		"Max delayed time to send changed metric to metric server.")
+	checkConcurrency := flag.Uint("check-concurrency",
+		types.DefaultAppConf.CheckConcurrency,
+		"Max number of health checks running concurrently.")
+	checkJitter := flag.Float64("check-jitter",
+		types.DefaultAppConf.CheckJitter,
+		"Random jitter in ratio of check interval, range [0, 1].")
+	checkHistory := flag.Uint("check-history",
+		types.DefaultAppConf.CheckHistory,
+		"Number of the recent check results kept per target for admin API, 0 to disable.")
+	stateFile := flag.String("state-file",
+		types.DefaultAppConf.StateFile,
+		"File path to persist checker states for warm restart, empty to disable.")
+	stateMaxAge := flag.Duration("state-max-age",
+		types.DefaultAppConf.StateMaxAge,
+		"States older than the age are ignored when loaded from state file or restored to checkers.")
+	stateSaveInterval := flag.Duration("state-save-interval",
+		types.DefaultAppConf.StateSaveInterval,
+		"Time interval to save checker states to state file.")
+	adminAddr := flag.String("admin-addr",
+		types.DefaultAppConf.AdminAddr,
+		"Admin http server address to inspect and override health states, empty to disable.")
+	adminAllowRemote := flag.Bool("admin-allow-remote",
+		types.DefaultAppConf.AdminAllowRemote,
+		"Allow state overrides when admin server listens on a non-loopback address.")
+	actionerRate := flag.Float64("actioner-rate",
+		types.DefaultAppConf.ActionerRate.Rate,
+		"Max actioner invocations per second globally, 0 for unlimited.")
+	actionerBurst := flag.Uint("actioner-burst",
+		types.DefaultAppConf.ActionerRate.Burst,
+		"Max burst of actioner invocations globally.")
+	actionerTypeRate := flag.String("actioner-type-rate", "",
+		"Rate limits per actioner type in format \"NAME=RATE[:BURST],...\", e.g. \"KernelRouteAddDel=20:40\".")
+	logFormat := flag.String("log-format",
+		types.DefaultAppConf.LogFormat,
+		"Format of check failure, state transition and action logs, \"text\" by glog or \"json\" to stdout.")
+	logThrottleInterval := flag.Duration("log-throttle-interval",
+		types.DefaultAppConf.LogThrottleInterval,
+		"Interval to summarize the identical failure logs suppressed per target, 0 to disable suppression.")
+	strictPreflight := flag.Bool("strict-preflight",
+		types.DefaultAppConf.StrictPreflight,
+		"Refuse to start if any preflight check relevant to the configured checkers and actioners fails.")
 
 	flag.Parse()
 
@@ -133,6 +177,70 @@ func init() {
 	if metricDelay != nil && *metricDelay > 0 {
 		appConf.MetricDelay = *metricDelay
 	}
+	if checkConcurrency != nil && *checkConcurrency > 0 {
+		appConf.CheckConcurrency = *checkConcurrency
+	}
+	if checkJitter != nil && *checkJitter >= 0 && *checkJitter <= 1 {
+		appConf.CheckJitter = *checkJitter
+	}
+	if checkHistory != nil {
+		appConf.CheckHistory = *checkHistory
+	}
+	if stateFile != nil {
+		appConf.StateFile = *stateFile
+	}
+	if stateMaxAge != nil && *stateMaxAge > 0 {
+		appConf.StateMaxAge = *stateMaxAge
+	}
+	if stateSaveInterval != nil && *stateSaveInterval > 0 {
+		appConf.StateSaveInterval = *stateSaveInterval
+	}
+	if adminAddr != nil {
+		appConf.AdminAddr = *adminAddr
+	}
+	if adminAllowRemote != nil {
+		appConf.AdminAllowRemote = *adminAllowRemote
+	}
+	if actionerRate != nil && *actionerRate >= 0 {
+		appConf.ActionerRate.Rate = *actionerRate
+	}
+	if actionerBurst != nil && *actionerBurst > 0 {
+		appConf.ActionerRate.Burst = *actionerBurst
+	}
+	if actionerTypeRate != nil && len(*actionerTypeRate) > 0 {
+		if rates, err := types.ParseRateLimits(*actionerTypeRate); err != nil {
+			glog.Warningf("Ignore invalid actioner-type-rate: %v", err)
+		} else {
+			appConf.ActionerTypeRates = rates
+		}
+	}
+	if logFormat != nil {
+		if *logFormat == utils.LogFormatText || *logFormat == utils.LogFormatJSON {
+			appConf.LogFormat = *logFormat
+		} else {
+			glog.Warningf("Ignore invalid log-format: %q", *logFormat)
+		}
+	}
+	if logThrottleInterval != nil && *logThrottleInterval >= 0 {
+		appConf.LogThrottleInterval = *logThrottleInterval
+	}
+	if strictPreflight != nil {
+		appConf.StrictPreflight = *strictPreflight
+	}
+}
+
+// runPreflight inspects the host for the checkers and actioners configured.
+func runPreflight() *preflight.Report {
+	req, err := manager.PreflightRequirements(&appConf)
+	report := preflight.Run(preflight.HostSystem{}, req)
+	if err != nil {
+		report.Results = append([]preflight.Result{{
+			Name:    "config-file",
+			Status:  preflight.StatusFail,
+			Message: fmt.Sprintf("fail to load %s: %v", appConf.HcCfgFile, err),
+		}}, report.Results...)
+	}
+	return report
 }
 
 func main() {
@@ -148,6 +256,25 @@ func main() {
 
 	rand.Seed(time.Now().UnixNano())
 
+	// Subcommand "preflight" only reports the preflight checks.
+	if flag.Arg(0) == "preflight" {
+		report := runPreflight()
+		fmt.Print(report)
+		if report.Failed() {
+			glog.Flush()
+			os.Exit(1)
+		}
+		return
+	}
+	if report := runPreflight(); report.Failed() {
+		if appConf.StrictPreflight {
+			glog.Exitf("Refuse to start with -strict-preflight. %s", report)
+		}
+		glog.Warning(report)
+	} else {
+		glog.Info(report)
+	}
+
 	m := manager.NewManager(&appConf)
 	if m == nil {
 		glog.Fatalf("NewManager failed!")
@@ -155,5 +282,6 @@ func main() {
 	manager.SetAppManager(m)
 
 	utils.ShutdownHandler(m)
+	utils.ReloadHandler(m)
 	m.Run()
 }
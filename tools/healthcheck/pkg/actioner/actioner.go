@@ -18,6 +18,7 @@ package actioner
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/types"
@@ -26,6 +27,10 @@ import (
 
 var methods map[string]ActionMethod
 
+// logLimiter bounds the error logs of actioners when targets fail massively,
+// the details are still available at verbosity utils.LogVerboseSuppressed.
+var logLimiter = utils.NewLogLimiter(utils.DefaultLogBurst, utils.DefaultLogPeriod)
+
 type ActionMethod interface {
 	// Act performs actions corresponding to health state change signal.
 	// The function MUST return in or immediately after `timeout` time.
@@ -43,30 +48,143 @@ type ActionMethodWithVerdict interface {
 	Verdict(timeout time.Duration) (types.State, error)
 }
 
+type ActionMethodWithStop interface {
+	// Stop stops the background work of an action method, such as the timers
+	// of delayed actions. It's called when the action method is replaced, or
+	// its target is removed.
+	Stop()
+}
+
+// ActionDispatch runs the action procedure `job` on `item` of the target, and
+// returns the job's results. It lets the caller pace the actions an action
+// method starts on its own rather than by Act.
+type ActionDispatch func(item string, timeout time.Duration,
+	job func(timeout time.Duration) (interface{}, error)) (interface{}, error)
+
+type ActionMethodWithDispatch interface {
+	// SetDispatch sets the ActionDispatch for the actions started by the action
+	// method on its own. Such actions run immediately if it's not set.
+	SetDispatch(dispatch ActionDispatch)
+}
+
+// ActionDetail describes the check result which triggers an action. It's passed
+// to ActionMethod.Act as an optional element of `data`, and actioners should
+// work as well without it.
+type ActionDetail struct {
+	Target  *utils.L3L4Addr // the checked target whose state change triggers the action
+	Method  string          // check method of the target
+	Message string          // what the check found, e.g., the failure reason
+	Time    time.Time       // when the state change happened
+}
+
+// String returns a one-line description of the detail for logging.
+func (d *ActionDetail) String() string {
+	if d == nil {
+		return ""
+	}
+	var b strings.Builder
+	if d.Target != nil {
+		b.WriteString(d.Target.String())
+	} else {
+		b.WriteString("(unknown)")
+	}
+	if len(d.Method) > 0 {
+		fmt.Fprintf(&b, " by %s check", d.Method)
+	}
+	if !d.Time.IsZero() {
+		fmt.Fprintf(&b, " at %s", d.Time.Format(time.RFC3339Nano))
+	}
+	if len(d.Message) > 0 {
+		fmt.Fprintf(&b, ": %s", d.Message)
+	}
+	return b.String()
+}
+
+// findActionDetail returns the first ActionDetail in `data` passed to Act, or
+// nil if not found.
+func findActionDetail(data []interface{}) *ActionDetail {
+	for _, v := range data {
+		switch d := v.(type) {
+		case *ActionDetail:
+			if d != nil {
+				return d
+			}
+		case ActionDetail:
+			return &d
+		}
+	}
+	return nil
+}
+
+// detailSuffix returns the detail in `data` formatted as a log line suffix, or
+// an empty string if no detail is given.
+func detailSuffix(data []interface{}) string {
+	if d := findActionDetail(data); d != nil {
+		return ", triggered by " + d.String()
+	}
+	return ""
+}
+
+// registerMethod registers the action method `name` in the init functions of
+// actioners. It panics if `name` is registered already, so that an actioner
+// mistakenly registered twice never overrides another one silently.
 func registerMethod(name string, method ActionMethod) {
 	if methods == nil {
 		methods = make(map[string]ActionMethod)
 	}
+	if _, ok := methods[name]; ok {
+		panic(fmt.Sprintf("action method %q registered twice", name))
+	}
 	methods[name] = method
 }
 
+// RegisteredMethods returns a copy of the registered action methods keyed by
+// their names.
+func RegisteredMethods() map[string]ActionMethod {
+	res := make(map[string]ActionMethod, len(methods))
+	for name, method := range methods {
+		res[name] = method
+	}
+	return res
+}
+
+// NewActioner creates the action method of `kind` for `target`, which is the
+// VIP itself as the targets of VS and VA actioners. See NewServiceActioner.
 func NewActioner(kind string, target *utils.L3L4Addr, configs map[string]string,
+	extras ...interface{}) (ActionMethod, error) {
+	return NewServiceActioner(kind, target, target, configs, extras...)
+}
+
+// NewServiceActioner creates the action method of `kind` for `target` of the
+// virtual service `vip`, where the param variables in `configs` are expanded
+// for them.
+func NewServiceActioner(kind string, target, vip *utils.L3L4Addr, configs map[string]string,
 	extras ...interface{}) (ActionMethod, error) {
 	method, ok := methods[kind]
 	if !ok {
 		return nil, fmt.Errorf("unsupported Action type %q", kind)
 	}
-	actioner, err := method.create(target, configs, extras...)
+	params, err := utils.ExpandParams(configs, &utils.ParamVars{Target: target, VIP: vip})
+	if err != nil {
+		return nil, fmt.Errorf("actioner params expansion failed: %v", err)
+	}
+	actioner, err := method.create(target, params, extras...)
 	if err != nil {
 		return nil, fmt.Errorf("actioner create failed: %v", err)
 	}
 	return actioner, nil
 }
 
+// Validate checks the configs of action method `kind`, where the param
+// variables are expanded by utils.SampleParamVars.
 func Validate(kind string, configs map[string]string) error {
 	method, ok := methods[kind]
 	if !ok {
 		return fmt.Errorf("unsupported action type: %s", kind)
 	}
-	return method.validate(configs)
+	expanded, err := utils.ExpandParams(configs, utils.SampleParamVars)
+	if err != nil {
+		return err
+	}
+	return method.validate(expanded)
 }
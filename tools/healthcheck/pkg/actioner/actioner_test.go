@@ -0,0 +1,109 @@
+// /*
+// Copyright 2025 IQiYi Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// */
+
+package actioner
+
+import (
+	"net"
+	"testing"
+
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/utils"
+)
+
+func TestActionerParamVars(t *testing.T) {
+	vip := &utils.L3L4Addr{IP: net.ParseIP("10.0.0.1"), Port: 80, Proto: utils.IPProtoTCP}
+	rs := &utils.L3L4Addr{IP: net.ParseIP("2001:db8::10"), Port: 8080, Proto: utils.IPProtoTCP}
+	params := map[string]string{"server": "127.0.0.1", "zone": "example.com",
+		"record": "{af}-{port}.vs-{vport}.example.com"}
+
+	for _, tc := range []struct {
+		target, vip *utils.L3L4Addr
+		record      string
+	}{
+		{rs, vip, "ipv6-8080.vs-80.example.com."},
+		{vip, nil, "ipv4-80.vs-80.example.com."}, // the target is the vip itself
+	} {
+		var act ActionMethod
+		var err error
+		if tc.vip != nil {
+			act, err = NewServiceActioner(dnsUpdateActionerName, tc.target, tc.vip, params)
+		} else {
+			act, err = NewActioner(dnsUpdateActionerName, tc.target, params)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if record := act.(*DnsUpdateAction).record; record != tc.record {
+			t.Errorf("expect record %q, got %q", tc.record, record)
+		}
+	}
+	if params["record"] != "{af}-{port}.vs-{vport}.example.com" {
+		t.Errorf("shared params modified: %v", params)
+	}
+
+	if err := Validate(dnsUpdateActionerName, params); err != nil {
+		t.Errorf("unexpected validation error: %v", err)
+	}
+	params["record"] = "{hostname}.example.com"
+	if err := Validate(dnsUpdateActionerName, params); err == nil {
+		t.Errorf("expect error of unknown variable")
+	}
+	if _, err := NewServiceActioner(dnsUpdateActionerName, rs, nil, map[string]string{"server": "127.0.0.1",
+		"zone": "example.com", "record": "{vip}.example.com"}); err == nil {
+		t.Errorf("expect error of {vip} without virtual service")
+	}
+}
+
+func TestRegisteredMethods(t *testing.T) {
+	registered := RegisteredMethods()
+	for _, name := range []string{"Blank", "BackendUpdate", "WeightDrain", "KernelRouteAddDel",
+		"KernelRouteAddDelVerdict", "DpvsAddrAddDel", "DpvsAddrKernelRouteAddDel", "DnsUpdate", "Script"} {
+		if _, ok := registered[name]; !ok {
+			t.Errorf("action method %q not registered", name)
+		}
+		delete(registered, name)
+	}
+	if len(registered) > 0 {
+		t.Errorf("unexpected action methods registered: %v", registered)
+	}
+
+	// The returned map must be a copy.
+	RegisteredMethods()["Foo"] = &BlankAction{}
+	if _, ok := RegisteredMethods()["Foo"]; ok {
+		t.Errorf("registered methods modified by caller")
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("expect panic with duplicate action method")
+			}
+		}()
+		registerMethod(blankActionerName, &BlankAction{})
+	}()
+	if methods[blankActionerName] == nil {
+		t.Errorf("action method %q lost", blankActionerName)
+	}
+
+	// The recording actioner is replaced when registered again.
+	a1, a2 := NewRecordingAction(), NewRecordingAction()
+	RegisterRecordingAction(a1)
+	RegisterRecordingAction(a2)
+	defer delete(methods, RecordingActionerName)
+	if RegisteredMethods()[RecordingActionerName] != a2 {
+		t.Errorf("recording action method not replaced")
+	}
+}
@@ -70,7 +70,8 @@ func (a *BackendAction) Act(signal types.State, timeout time.Duration,
 
 	newVS, err := comm.UpdateCheckState(a.apiServer, vs, ctx)
 	if err != nil {
-		glog.Errorf("%s actioner %s (VS: %v) failed: %v", backendActionerName, a.name, *vs, err)
+		logLimiter.Errorf(backendActionerName+" actions failed",
+			"%s actioner %s (VS: %v) failed: %v", backendActionerName, a.name, *vs, err)
 	} else if newVS != nil {
 		glog.Warningf("%s actioner %s (VS: %v) outdated and returned newVS %v",
 			backendActionerName, a.name, *vs, newVS)
@@ -0,0 +1,639 @@
+// /*
+// Copyright 2025 IQiYi Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// */
+
+package actioner
+
+/*
+DnsUpdate Actioner Params:
+-------------------------------------------------
+name                value
+-------------------------------------------------
+server              DNS server accepting updates, "host[:port]", port defaults to 53
+zone                zone to update
+record              domain name of the A/AAAA record, within zone
+ttl                 TTL of the record added in seconds, default 60
+key                 TSIG key name
+secret              TSIG secret in base64
+algorithm           hmac-sha256 | hmac-sha1 | hmac-sha512, default hmac-sha256
+tcp                 yes | no | true | false, send updates over TCP rather than UDP
+
+-------------------------------------------------
+
+The A or AAAA record of the target IP, by its address family, is added to
+`record` on Healthy and removed on Unhealthy with RFC 2136 dynamic updates.
+Only the record of the target IP is touched, so that multiple targets can share
+a record for DNS round robin. Adding an existing record or deleting a missing one
+is not an error. The updates are signed with TSIG (RFC 8945) if `key` and
+`secret` are given, and so must be the responses.
+*/
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/types"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/utils"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+var _ ActionMethod = (*DnsUpdateAction)(nil)
+
+const dnsUpdateActionerName = "DnsUpdate"
+
+const (
+	dnsUpdateDefaultPort = "53"
+	dnsUpdateDefaultTTL  = 60
+	dnsUpdateDefaultAlgo = "hmac-sha256"
+
+	dnsOpcodeUpdate dnsmessage.OpCode = 5
+	dnsClassNone    dnsmessage.Class  = 254
+	dnsClassAny     dnsmessage.Class  = 255
+	dnsTypeTSIG     dnsmessage.Type   = 250
+
+	tsigFudge = 300 // seconds of clock skew allowed
+)
+
+// RCODEs of RFC 2136 which dnsmessage does not name.
+const (
+	dnsRCodeYXDomain dnsmessage.RCode = 6
+	dnsRCodeYXRRSet  dnsmessage.RCode = 7
+	dnsRCodeNXRRSet  dnsmessage.RCode = 8
+	dnsRCodeNotAuth  dnsmessage.RCode = 9
+	dnsRCodeNotZone  dnsmessage.RCode = 10
+)
+
+var dnsRCodeNames = map[dnsmessage.RCode]string{
+	dnsRCodeYXDomain: "YXDOMAIN",
+	dnsRCodeYXRRSet:  "YXRRSET",
+	dnsRCodeNXRRSet:  "NXRRSET",
+	dnsRCodeNotAuth:  "NOTAUTH",
+	dnsRCodeNotZone:  "NOTZONE",
+}
+
+func dnsRCodeName(rcode dnsmessage.RCode) string {
+	if name, ok := dnsRCodeNames[rcode]; ok {
+		return name
+	}
+	return strings.TrimPrefix(rcode.String(), "RCode")
+}
+
+// TSIG errors of RFC 8945.
+var tsigErrorNames = map[uint16]string{
+	16: "BADSIG",
+	17: "BADKEY",
+	18: "BADTIME",
+	22: "BADTRUNC",
+}
+
+var tsigAlgorithms = map[string]func() hash.Hash{
+	"hmac-sha1":   sha1.New,
+	"hmac-sha256": sha256.New,
+	"hmac-sha512": sha512.New,
+}
+
+func init() {
+	registerMethod(dnsUpdateActionerName, &DnsUpdateAction{})
+}
+
+type DnsUpdateAction struct {
+	target *utils.L3L4Addr
+	server string
+	zone   string
+	record string
+	ttl    uint32
+	tcp    bool
+	tsig   *tsigKey
+}
+
+// tsigKey signs and verifies DNS messages with TSIG.
+type tsigKey struct {
+	name      string // canonical absolute name
+	algorithm string // canonical absolute name
+	hash      func() hash.Hash
+	secret    []byte
+}
+
+func (a *DnsUpdateAction) Act(signal types.State, timeout time.Duration,
+	data ...interface{}) (interface{}, error) {
+	addr := a.target.IP
+
+	if timeout <= 0 {
+		return nil, fmt.Errorf("zero timeout on %s actioner %v", dnsUpdateActionerName, addr)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	why := detailSuffix(data)
+	glog.V(7).Infof("starting %s actioner %v ...%s", dnsUpdateActionerName, addr, why)
+
+	operation := "UP"
+	if signal == types.Unhealthy {
+		operation = "DOWN"
+	}
+
+	if err := a.update(ctx, signal != types.Unhealthy); err != nil {
+		if ctx.Err() != nil {
+			logLimiter.Errorf(dnsUpdateActionerName+" actions timeout",
+				"%s actioner %v %s timeout%s", dnsUpdateActionerName, addr, operation, why)
+			return nil, ctx.Err()
+		}
+		logLimiter.Errorf(dnsUpdateActionerName+" actions failed",
+			"%s actioner %v %s failed: %v%s", dnsUpdateActionerName, addr, operation, err, why)
+		return nil, err
+	}
+	glog.V(6).Infof("%s actioner %v %s succeed%s", dnsUpdateActionerName, addr, operation, why)
+	return nil, nil
+}
+
+// update adds the record of the target if `add`, or deletes it otherwise.
+func (a *DnsUpdateAction) update(ctx context.Context, add bool) error {
+	msg, id, err := a.buildUpdate(add)
+	if err != nil {
+		return err
+	}
+	var reqMAC []byte
+	if a.tsig != nil {
+		msg, reqMAC = a.tsig.sign(msg, nil, time.Now())
+	}
+
+	resp, err := dnsExchange(ctx, a.server, a.tcp, msg, id)
+	if err != nil {
+		return err
+	}
+
+	var p dnsmessage.Parser
+	hdr, err := p.Start(resp)
+	if err != nil {
+		return fmt.Errorf("malformed response: %v", err)
+	}
+	if !hdr.Response || hdr.OpCode != dnsOpcodeUpdate {
+		return fmt.Errorf("unexpected response opcode %d", hdr.OpCode)
+	}
+	if a.tsig != nil {
+		// Errors are not signed if the server fails to verify the request.
+		if _, err := a.tsig.verify(resp, reqMAC, time.Now()); err != nil {
+			if hdr.RCode != dnsmessage.RCodeSuccess {
+				return fmt.Errorf("update refused by server: %s, %v", dnsRCodeName(hdr.RCode), err)
+			}
+			return fmt.Errorf("update response rejected: %v", err)
+		}
+	}
+
+	switch {
+	case hdr.RCode == dnsmessage.RCodeSuccess:
+	case add && hdr.RCode == dnsRCodeYXRRSet:
+		glog.V(8).Infof("Warning: adding record %s %v already exists\n", a.record, a.target.IP)
+	case !add && (hdr.RCode == dnsRCodeNXRRSet || hdr.RCode == dnsmessage.RCodeNameError):
+		glog.V(8).Infof("Warning: deleting record %s %v does not exist\n", a.record, a.target.IP)
+	default:
+		return fmt.Errorf("update refused by server: %s", dnsRCodeName(hdr.RCode))
+	}
+	return nil
+}
+
+// buildUpdate returns the unsigned update message and its ID.
+func (a *DnsUpdateAction) buildUpdate(add bool) ([]byte, uint16, error) {
+	var idb [2]byte
+	if _, err := io.ReadFull(rand.Reader, idb[:]); err != nil {
+		return nil, 0, err
+	}
+	id := binary.BigEndian.Uint16(idb[:])
+
+	zone, err := dnsmessage.NewName(a.zone)
+	if err != nil {
+		return nil, 0, err
+	}
+	record, err := dnsmessage.NewName(a.record)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	b := dnsmessage.NewBuilder(make([]byte, 0, 512), dnsmessage.Header{ID: id, OpCode: dnsOpcodeUpdate})
+	b.StartQuestions() // the zone section
+	if err = b.Question(dnsmessage.Question{Name: zone, Type: dnsmessage.TypeSOA,
+		Class: dnsmessage.ClassINET}); err != nil {
+		return nil, 0, err
+	}
+
+	// RFC 2136 section 2.5.1 and 2.5.4, the class NONE with TTL 0 deletes the
+	// record of the given data only.
+	rr := dnsmessage.ResourceHeader{Name: record, Class: dnsmessage.ClassINET, TTL: a.ttl}
+	if !add {
+		rr.Class, rr.TTL = dnsClassNone, 0
+	}
+	b.StartAuthorities() // the update section
+	if ip4 := a.target.IP.To4(); ip4 != nil {
+		var r dnsmessage.AResource
+		copy(r.A[:], ip4)
+		err = b.AResource(rr, r)
+	} else {
+		var r dnsmessage.AAAAResource
+		copy(r.AAAA[:], a.target.IP.To16())
+		err = b.AAAAResource(rr, r)
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+
+	msg, err := b.Finish()
+	if err != nil {
+		return nil, 0, err
+	}
+	return msg, id, nil
+}
+
+// dnsExchange sends `msg` of `id` to `server`, and returns the response.
+func dnsExchange(ctx context.Context, server string, tcp bool, msg []byte, id uint16) ([]byte, error) {
+	network := "udp"
+	if tcp {
+		network = "tcp"
+	}
+	conn, err := (&net.Dialer{}).DialContext(ctx, network, server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if tcp {
+		frame := binary.BigEndian.AppendUint16(make([]byte, 0, 2+len(msg)), uint16(len(msg)))
+		if err = utils.WriteFull(conn, append(frame, msg...)); err != nil {
+			return nil, err
+		}
+		var size [2]byte
+		if _, err = io.ReadFull(conn, size[:]); err != nil {
+			return nil, err
+		}
+		resp := make([]byte, binary.BigEndian.Uint16(size[:]))
+		if _, err = io.ReadFull(conn, resp); err != nil {
+			return nil, err
+		}
+		if len(resp) < 2 || binary.BigEndian.Uint16(resp) != id {
+			return nil, errors.New("response id mismatched")
+		}
+		return resp, nil
+	}
+
+	if _, err = conn.Write(msg); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 65535)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+		// Responses of other IDs are stale or spoofed.
+		if n >= 2 && binary.BigEndian.Uint16(buf) == id {
+			return buf[:n], nil
+		}
+	}
+}
+
+var errTSIGMissing = errors.New("TSIG record missing")
+
+// dnsNameWire returns the uncompressed wire format of the absolute `name`.
+func dnsNameWire(name string) []byte {
+	wire := make([]byte, 0, len(name)+1)
+	for _, label := range strings.Split(strings.TrimSuffix(name, "."), ".") {
+		wire = append(wire, byte(len(label)))
+		wire = append(wire, label...)
+	}
+	return append(wire, 0)
+}
+
+// readDnsName reads an uncompressed name at the beginning of `b`, and returns
+// it and its length in `b`.
+func readDnsName(b []byte) (string, int, error) {
+	var labels []string
+	for off := 0; off < len(b); {
+		n := int(b[off])
+		if n == 0 {
+			return strings.Join(labels, ".") + ".", off + 1, nil
+		}
+		if n > 63 || off+1+n > len(b) {
+			break
+		}
+		labels = append(labels, string(b[off+1:off+1+n]))
+		off += 1 + n
+	}
+	return "", 0, errors.New("malformed name")
+}
+
+// digest returns the MAC of the message `msg` without TSIG, with the TSIG
+// variables of time `signed`, `tsigErr` and `other`. The MAC of the request
+// `reqMAC` is prefixed for a response.
+func (k *tsigKey) digest(msg, reqMAC []byte, signed uint64, fudge, tsigErr uint16, other []byte) []byte {
+	h := hmac.New(k.hash, k.secret)
+	if reqMAC != nil {
+		h.Write(binary.BigEndian.AppendUint16(nil, uint16(len(reqMAC))))
+		h.Write(reqMAC)
+	}
+	h.Write(msg)
+	vars := dnsNameWire(k.name)
+	vars = binary.BigEndian.AppendUint16(vars, uint16(dnsClassAny))
+	vars = binary.BigEndian.AppendUint32(vars, 0) // TTL
+	vars = append(vars, dnsNameWire(k.algorithm)...)
+	vars = binary.BigEndian.AppendUint16(vars, uint16(signed>>32))
+	vars = binary.BigEndian.AppendUint32(vars, uint32(signed))
+	vars = binary.BigEndian.AppendUint16(vars, fudge)
+	vars = binary.BigEndian.AppendUint16(vars, tsigErr)
+	vars = binary.BigEndian.AppendUint16(vars, uint16(len(other)))
+	vars = append(vars, other...)
+	h.Write(vars)
+	return h.Sum(nil)
+}
+
+// sign appends the TSIG record signing `msg` at `now` to it, where `reqMAC` is
+// the MAC of the request if `msg` is a response. It returns the signed message
+// and its MAC.
+func (k *tsigKey) sign(msg, reqMAC []byte, now time.Time) ([]byte, []byte) {
+	signed := uint64(now.Unix())
+	mac := k.digest(msg, reqMAC, signed, tsigFudge, 0, nil)
+
+	rdata := dnsNameWire(k.algorithm)
+	rdata = binary.BigEndian.AppendUint16(rdata, uint16(signed>>32))
+	rdata = binary.BigEndian.AppendUint32(rdata, uint32(signed))
+	rdata = binary.BigEndian.AppendUint16(rdata, tsigFudge)
+	rdata = binary.BigEndian.AppendUint16(rdata, uint16(len(mac)))
+	rdata = append(rdata, mac...)
+	rdata = append(rdata, msg[:2]...)               // original ID
+	rdata = binary.BigEndian.AppendUint16(rdata, 0) // error
+	rdata = binary.BigEndian.AppendUint16(rdata, 0) // other len
+
+	signedMsg := make([]byte, 0, len(msg)+len(k.name)+12+len(rdata))
+	signedMsg = append(signedMsg, msg...)
+	signedMsg = append(signedMsg, dnsNameWire(k.name)...)
+	signedMsg = binary.BigEndian.AppendUint16(signedMsg, uint16(dnsTypeTSIG))
+	signedMsg = binary.BigEndian.AppendUint16(signedMsg, uint16(dnsClassAny))
+	signedMsg = binary.BigEndian.AppendUint32(signedMsg, 0)
+	signedMsg = binary.BigEndian.AppendUint16(signedMsg, uint16(len(rdata)))
+	signedMsg = append(signedMsg, rdata...)
+	arcount := binary.BigEndian.Uint16(signedMsg[10:])
+	binary.BigEndian.PutUint16(signedMsg[10:], arcount+1)
+	return signedMsg, mac
+}
+
+// verify checks the TSIG record at the end of `msg` at `now`, where `reqMAC` is
+// the MAC of the request if `msg` is a response. It returns the MAC of `msg`.
+func (k *tsigKey) verify(msg, reqMAC []byte, now time.Time) ([]byte, error) {
+	var p dnsmessage.Parser
+	if _, err := p.Start(msg); err != nil {
+		return nil, err
+	}
+	if err := p.SkipAllQuestions(); err != nil {
+		return nil, err
+	}
+	if err := p.SkipAllAnswers(); err != nil {
+		return nil, err
+	}
+	if err := p.SkipAllAuthorities(); err != nil {
+		return nil, err
+	}
+	var rr dnsmessage.ResourceHeader
+	var rdata []byte
+	for {
+		h, err := p.AdditionalHeader()
+		if err == dnsmessage.ErrSectionDone {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if rdata != nil {
+			return nil, errors.New("TSIG record not the last")
+		}
+		if h.Type != dnsTypeTSIG {
+			if err = p.SkipAdditional(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		r, err := p.UnknownResource()
+		if err != nil {
+			return nil, err
+		}
+		rr, rdata = h, r.Data
+	}
+	if rdata == nil {
+		return nil, errTSIGMissing
+	}
+	if !strings.EqualFold(rr.Name.String(), k.name) {
+		return nil, fmt.Errorf("unknown TSIG key %s", rr.Name)
+	}
+
+	// The TSIG record is never compressed, so that it's located by its size.
+	name := dnsNameWire(k.name)
+	start := len(msg) - len(name) - 10 - len(rdata)
+	if start < 12 || !bytes.EqualFold(msg[start:start+len(name)], name) {
+		return nil, errors.New("malformed TSIG record")
+	}
+
+	algorithm, n, err := readDnsName(rdata)
+	if err != nil || len(rdata) < n+10 {
+		return nil, errors.New("malformed TSIG record")
+	}
+	if !strings.EqualFold(algorithm, k.algorithm) {
+		return nil, fmt.Errorf("unexpected TSIG algorithm %s", algorithm)
+	}
+	fields := rdata[n:]
+	signed := uint64(binary.BigEndian.Uint16(fields))<<32 | uint64(binary.BigEndian.Uint32(fields[2:]))
+	fudge := binary.BigEndian.Uint16(fields[6:])
+	size := int(binary.BigEndian.Uint16(fields[8:]))
+	if len(fields) < 10+size+6 {
+		return nil, errors.New("malformed TSIG record")
+	}
+	mac := fields[10 : 10+size]
+	origID := fields[10+size : 12+size]
+	tsigErr := binary.BigEndian.Uint16(fields[12+size:])
+	otherLen := int(binary.BigEndian.Uint16(fields[14+size:]))
+	if len(fields) != 16+size+otherLen {
+		return nil, errors.New("malformed TSIG record")
+	}
+	if tsigErr != 0 {
+		if name, ok := tsigErrorNames[tsigErr]; ok {
+			return nil, fmt.Errorf("TSIG error %s", name)
+		}
+		return nil, fmt.Errorf("TSIG error %d", tsigErr)
+	}
+
+	unsigned := append([]byte{}, msg[:start]...)
+	copy(unsigned, origID)
+	arcount := binary.BigEndian.Uint16(unsigned[10:])
+	binary.BigEndian.PutUint16(unsigned[10:], arcount-1)
+	expected := k.digest(unsigned, reqMAC, signed, fudge, tsigErr, fields[16+size:])
+	if !hmac.Equal(mac, expected) {
+		return nil, errors.New("TSIG signature mismatched")
+	}
+	if skew := now.Unix() - int64(signed); skew > int64(fudge) || -skew > int64(fudge) {
+		return nil, fmt.Errorf("TSIG time skew %ds exceeds %ds", skew, fudge)
+	}
+	return mac, nil
+}
+
+// dnsName returns the absolute domain name of `s`.
+func dnsName(s string) (string, error) {
+	if !strings.HasSuffix(s, ".") {
+		s += "."
+	}
+	if len(s) > 254 {
+		return "", fmt.Errorf("name too long")
+	}
+	for _, label := range strings.Split(strings.TrimSuffix(s, "."), ".") {
+		if len(label) == 0 || len(label) > 63 {
+			return "", fmt.Errorf("invalid label %q", label)
+		}
+	}
+	return s, nil
+}
+
+// dnsServerAddr returns the address of the DNS server `s` with the default
+// port if missing.
+func dnsServerAddr(s string) (string, error) {
+	host, port, err := net.SplitHostPort(s)
+	if err != nil {
+		host, port = strings.Trim(s, "[]"), dnsUpdateDefaultPort
+	}
+	if len(host) == 0 || strings.ContainsAny(host, " /") {
+		return "", fmt.Errorf("invalid server host %q", host)
+	}
+	if n, err := strconv.Atoi(port); err != nil || n <= 0 || n > 65535 {
+		return "", fmt.Errorf("invalid server port %q", port)
+	}
+	return net.JoinHostPort(host, port), nil
+}
+
+func (a *DnsUpdateAction) validate(params map[string]string) error {
+	required := []string{"server", "zone", "record"}
+	var missed []string
+	for _, param := range required {
+		if _, ok := params[param]; !ok {
+			missed = append(missed, param)
+		}
+	}
+	if len(missed) > 0 {
+		return fmt.Errorf("missing required action params: %v", strings.Join(missed, ","))
+	}
+
+	unsupported := make([]string, 0, len(params))
+	for param, val := range params {
+		switch param {
+		case "server":
+			if _, err := dnsServerAddr(val); err != nil {
+				return fmt.Errorf("invalid action param %s=%s: %v", param, val, err)
+			}
+		case "zone", "record", "key":
+			if _, err := dnsName(val); err != nil {
+				return fmt.Errorf("invalid action param %s=%s: %v", param, val, err)
+			}
+		case "ttl":
+			if _, err := strconv.ParseUint(val, 10, 31); err != nil {
+				return fmt.Errorf("invalid action param %s=%s", param, val)
+			}
+		case "secret":
+			if secret, err := base64.StdEncoding.DecodeString(val); err != nil || len(secret) == 0 {
+				return fmt.Errorf("invalid action param %s, base64 required", param)
+			}
+		case "algorithm":
+			if _, ok := tsigAlgorithms[strings.ToLower(val)]; !ok {
+				return fmt.Errorf("invalid action param %s=%s", param, val)
+			}
+		case "tcp":
+			if _, err := utils.String2bool(val); err != nil {
+				return fmt.Errorf("invalid action param %s=%s", param, val)
+			}
+		default:
+			unsupported = append(unsupported, param)
+		}
+	}
+	if len(unsupported) > 0 {
+		return fmt.Errorf("unsupported action params: %s", strings.Join(unsupported, ","))
+	}
+
+	zone, _ := dnsName(params["zone"])
+	record, _ := dnsName(params["record"])
+	if !strings.EqualFold(record, zone) && !strings.HasSuffix(strings.ToLower(record), "."+strings.ToLower(zone)) {
+		return fmt.Errorf("action param record %s not within zone %s", record, zone)
+	}
+	_, hasKey := params["key"]
+	_, hasSecret := params["secret"]
+	if hasKey != hasSecret {
+		return fmt.Errorf("action params key and secret must be given together")
+	}
+	if _, ok := params["algorithm"]; ok && !hasKey {
+		return fmt.Errorf("action param algorithm requires key")
+	}
+	return nil
+}
+
+func (a *DnsUpdateAction) create(target *utils.L3L4Addr, params map[string]string,
+	extras ...interface{}) (ActionMethod, error) {
+	if target == nil || len(target.IP) == 0 {
+		return nil, fmt.Errorf("no target address for %s actioner", dnsUpdateActionerName)
+	}
+
+	if err := a.validate(params); err != nil {
+		return nil, fmt.Errorf("%s actioner param validation failed: %v", dnsUpdateActionerName, err)
+	}
+
+	actioner := &DnsUpdateAction{
+		target: target.DeepCopy(),
+		ttl:    dnsUpdateDefaultTTL,
+	}
+	actioner.server, _ = dnsServerAddr(params["server"])
+	actioner.zone, _ = dnsName(params["zone"])
+	actioner.record, _ = dnsName(params["record"])
+	if val, ok := params["ttl"]; ok {
+		ttl, _ := strconv.ParseUint(val, 10, 31)
+		actioner.ttl = uint32(ttl)
+	}
+	if val, ok := params["tcp"]; ok {
+		actioner.tcp, _ = utils.String2bool(val)
+	}
+	if val, ok := params["key"]; ok {
+		algorithm := dnsUpdateDefaultAlgo
+		if algo, ok := params["algorithm"]; ok {
+			algorithm = strings.ToLower(algo)
+		}
+		name, _ := dnsName(val)
+		secret, _ := base64.StdEncoding.DecodeString(params["secret"])
+		actioner.tsig = &tsigKey{
+			name:      strings.ToLower(name),
+			algorithm: algorithm + ".",
+			hash:      tsigAlgorithms[algorithm],
+			secret:    secret,
+		}
+	}
+	return actioner, nil
+}
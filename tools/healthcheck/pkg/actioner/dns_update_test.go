@@ -0,0 +1,351 @@
+// /*
+// Copyright 2025 IQiYi Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// */
+
+package actioner
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/types"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/utils"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+const testTSIGSecret = "c2VjcmV0LWZvci1kcHZzLWhlYWx0aGNoZWNr" // "secret-for-dpvs-healthcheck"
+
+// fakeDnsServer serves RFC 2136 updates over UDP and TCP on the same port.
+type fakeDnsServer struct {
+	key    *tsigKey // nil to accept unsigned updates
+	strict bool     // reply YXRRSET/NXRRSET on adding existing/deleting missing records
+	silent bool     // never reply
+	badMAC bool     // corrupt the signature of replies
+
+	lock    sync.Mutex
+	records map[string]struct{} // "name type ip"
+	updates int
+}
+
+func startFakeDnsServer(t *testing.T, s *fakeDnsServer) string {
+	t.Helper()
+	s.records = make(map[string]struct{})
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln, err := net.Listen("tcp", pc.LocalAddr().String())
+	if err != nil {
+		pc.Close()
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { pc.Close(); ln.Close() })
+
+	go func() {
+		buf := make([]byte, 65535)
+		for {
+			n, addr, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if resp := s.handle(buf[:n]); resp != nil {
+				pc.WriteTo(resp, addr)
+			}
+		}
+	}()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				var size [2]byte
+				if _, err := io.ReadFull(conn, size[:]); err != nil {
+					return
+				}
+				req := make([]byte, binary.BigEndian.Uint16(size[:]))
+				if _, err := io.ReadFull(conn, req); err != nil {
+					return
+				}
+				if resp := s.handle(req); resp != nil {
+					conn.Write(append(binary.BigEndian.AppendUint16(nil, uint16(len(resp))), resp...))
+				}
+			}()
+		}
+	}()
+	return pc.LocalAddr().String()
+}
+
+func (s *fakeDnsServer) handle(req []byte) []byte {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.silent {
+		return nil
+	}
+
+	var p dnsmessage.Parser
+	hdr, err := p.Start(req)
+	if err != nil {
+		return nil
+	}
+	reply := func(rcode dnsmessage.RCode, reqMAC []byte) []byte {
+		b := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: hdr.ID, Response: true,
+			OpCode: dnsOpcodeUpdate, RCode: rcode})
+		resp, _ := b.Finish()
+		if s.key != nil && reqMAC != nil {
+			resp, _ = s.key.sign(resp, reqMAC, time.Now())
+			if s.badMAC {
+				resp[len(resp)-10] ^= 0xff
+			}
+		}
+		return resp
+	}
+
+	var reqMAC []byte
+	if s.key != nil {
+		if reqMAC, err = s.key.verify(req, nil, time.Now()); err != nil {
+			return reply(dnsRCodeNotAuth, nil)
+		}
+	}
+	zone, err := p.Question()
+	if err != nil || hdr.OpCode != dnsOpcodeUpdate || zone.Type != dnsmessage.TypeSOA {
+		return reply(dnsmessage.RCodeFormatError, reqMAC)
+	}
+	p.SkipAllQuestions()
+	p.SkipAllAnswers()
+	for {
+		h, err := p.AuthorityHeader()
+		if err == dnsmessage.ErrSectionDone {
+			break
+		}
+		if err != nil {
+			return reply(dnsmessage.RCodeFormatError, reqMAC)
+		}
+		if !strings.HasSuffix(h.Name.String(), zone.Name.String()) {
+			return reply(dnsRCodeNotZone, reqMAC)
+		}
+		var ip net.IP
+		switch h.Type {
+		case dnsmessage.TypeA:
+			r, _ := p.AResource()
+			ip = net.IP(r.A[:])
+		case dnsmessage.TypeAAAA:
+			r, _ := p.AAAAResource()
+			ip = net.IP(r.AAAA[:])
+		default:
+			return reply(dnsmessage.RCodeNotImplemented, reqMAC)
+		}
+		key := h.Name.String() + " " + h.Type.String() + " " + ip.String()
+		_, exists := s.records[key]
+		switch h.Class {
+		case dnsmessage.ClassINET:
+			if exists && s.strict {
+				return reply(dnsRCodeYXRRSet, reqMAC)
+			}
+			s.records[key] = struct{}{}
+		case dnsClassNone:
+			if !exists && s.strict {
+				return reply(dnsRCodeNXRRSet, reqMAC)
+			}
+			delete(s.records, key)
+		default:
+			return reply(dnsmessage.RCodeFormatError, reqMAC)
+		}
+	}
+	s.updates++
+	return reply(dnsmessage.RCodeSuccess, reqMAC)
+}
+
+func (s *fakeDnsServer) expect(t *testing.T, records ...string) {
+	t.Helper()
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	got := make([]string, 0, len(s.records))
+	for rr := range s.records {
+		got = append(got, rr)
+	}
+	sort.Strings(got)
+	if strings.Join(got, ",") != strings.Join(records, ",") {
+		t.Errorf("expect records %v, got %v", records, got)
+	}
+}
+
+func newTestDnsUpdate(t *testing.T, ip string, params map[string]string) ActionMethod {
+	t.Helper()
+	target := &utils.L3L4Addr{IP: net.ParseIP(ip), Port: 80, Proto: utils.IPProtoTCP}
+	act, err := NewActioner(dnsUpdateActionerName, target, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return act
+}
+
+func TestDnsUpdateAction(t *testing.T) {
+	key := &tsigKey{name: "dpvs-key.", algorithm: "hmac-sha256.", hash: tsigAlgorithms["hmac-sha256"],
+		secret: []byte("secret-for-dpvs-healthcheck")}
+	for _, tc := range []struct {
+		name   string
+		server *fakeDnsServer
+		params map[string]string
+	}{
+		{"unsigned", &fakeDnsServer{}, map[string]string{}},
+		{"tsig", &fakeDnsServer{key: key}, map[string]string{"key": "DPVS-Key", "secret": testTSIGSecret}},
+		{"tsig over tcp", &fakeDnsServer{key: key}, map[string]string{"key": "dpvs-key.",
+			"secret": testTSIGSecret, "algorithm": "HMAC-SHA256", "tcp": "yes"}},
+		{"strict server", &fakeDnsServer{strict: true}, map[string]string{}},
+	} {
+		tc.params["server"] = startFakeDnsServer(t, tc.server)
+		tc.params["zone"] = "example.com"
+		tc.params["record"] = "vip.example.com."
+		v4 := newTestDnsUpdate(t, "192.168.88.1", tc.params)
+		v6 := newTestDnsUpdate(t, "2001:db8::1", tc.params)
+
+		// Repeated actions are idempotent.
+		for i := 0; i < 2; i++ {
+			if _, err := v4.Act(types.Healthy, time.Second); err != nil {
+				t.Errorf("%s: unexpected error to add record: %v", tc.name, err)
+			}
+		}
+		if _, err := v6.Act(types.Healthy, time.Second); err != nil {
+			t.Errorf("%s: unexpected error to add record: %v", tc.name, err)
+		}
+		tc.server.expect(t, "vip.example.com. TypeA 192.168.88.1", "vip.example.com. TypeAAAA 2001:db8::1")
+
+		for i := 0; i < 2; i++ {
+			if _, err := v4.Act(types.Unhealthy, time.Second); err != nil {
+				t.Errorf("%s: unexpected error to delete record: %v", tc.name, err)
+			}
+		}
+		tc.server.expect(t, "vip.example.com. TypeAAAA 2001:db8::1")
+	}
+
+	// failures
+	server := startFakeDnsServer(t, &fakeDnsServer{key: key})
+	params := map[string]string{"server": server, "zone": "example.com", "record": "vip.example.com",
+		"key": "dpvs-key", "secret": "d3Jvbmc="}
+	if _, err := newTestDnsUpdate(t, "192.168.88.1", params).Act(types.Healthy, time.Second); err == nil ||
+		!strings.Contains(err.Error(), "NOTAUTH") {
+		t.Errorf("expect NOTAUTH error with wrong secret, got %v", err)
+	}
+	delete(params, "key")
+	delete(params, "secret")
+	if _, err := newTestDnsUpdate(t, "192.168.88.1", params).Act(types.Healthy, time.Second); err == nil {
+		t.Errorf("expect error for unsigned update")
+	}
+
+	params = map[string]string{"server": startFakeDnsServer(t, &fakeDnsServer{key: key, badMAC: true}),
+		"zone": "example.com", "record": "vip.example.com", "key": "dpvs-key", "secret": testTSIGSecret}
+	if _, err := newTestDnsUpdate(t, "192.168.88.1", params).Act(types.Healthy, time.Second); err == nil ||
+		!strings.Contains(err.Error(), "mismatched") {
+		t.Errorf("expect signature error of response, got %v", err)
+	}
+
+	params = map[string]string{"server": startFakeDnsServer(t, &fakeDnsServer{}), "zone": "example.com",
+		"record": "vip.example.com", "key": "dpvs-key", "secret": testTSIGSecret}
+	if _, err := newTestDnsUpdate(t, "192.168.88.1", params).Act(types.Healthy, time.Second); err == nil ||
+		!strings.Contains(err.Error(), "TSIG record missing") {
+		t.Errorf("expect error of unsigned response, got %v", err)
+	}
+}
+
+func TestDnsUpdateActionTimeout(t *testing.T) {
+	for _, tcp := range []string{"false", "true"} {
+		server := startFakeDnsServer(t, &fakeDnsServer{silent: true})
+		act := newTestDnsUpdate(t, "192.168.88.1", map[string]string{"server": server,
+			"zone": "example.com", "record": "vip.example.com", "tcp": tcp})
+		start := time.Now()
+		if _, err := act.Act(types.Healthy, 200*time.Millisecond); err == nil {
+			t.Errorf("tcp %s: expect timeout error", tcp)
+		}
+		if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+			t.Errorf("tcp %s: action not return in time: %v", tcp, elapsed)
+		}
+	}
+}
+
+func TestTSIGTimeSkew(t *testing.T) {
+	key := &tsigKey{name: "dpvs-key.", algorithm: "hmac-sha1.", hash: tsigAlgorithms["hmac-sha1"],
+		secret: []byte("secret")}
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: 1, OpCode: dnsOpcodeUpdate})
+	msg, _ := b.Finish()
+	now := time.Now()
+	signed, mac := key.sign(msg, nil, now)
+	if got, err := key.verify(signed, nil, now.Add(time.Minute)); err != nil || string(got) != string(mac) {
+		t.Errorf("unexpected verification error: %v", err)
+	}
+	if _, err := key.verify(signed, nil, now.Add(tsigFudge*time.Second+time.Minute)); err == nil {
+		t.Errorf("expect error of time skew")
+	}
+	if _, err := key.verify(signed, mac, now); err == nil {
+		t.Errorf("expect error of request MAC mismatched")
+	}
+}
+
+func TestDnsUpdateParams(t *testing.T) {
+	valid := []map[string]string{
+		{"server": "127.0.0.1", "zone": "example.com", "record": "example.com"},
+		{"server": "ns1.example.com:5353", "zone": "example.com.", "record": "www.Example.com",
+			"ttl": "0", "key": "k", "secret": testTSIGSecret, "algorithm": "hmac-sha512", "tcp": "yes"},
+		{"server": "[2001:db8::53]:53", "zone": "example.com", "record": "vip.example.com"},
+		{"server": "2001:db8::53", "zone": "example.com", "record": "vip.example.com"},
+	}
+	for _, params := range valid {
+		if err := Validate(dnsUpdateActionerName, params); err != nil {
+			t.Errorf("unexpected error for %v: %v", params, err)
+		}
+	}
+
+	base := func(extra map[string]string) map[string]string {
+		params := map[string]string{"server": "127.0.0.1", "zone": "example.com", "record": "vip.example.com"}
+		for k, v := range extra {
+			params[k] = v
+		}
+		return params
+	}
+	invalid := []map[string]string{
+		{"zone": "example.com", "record": "vip.example.com"},
+		base(map[string]string{"server": "127.0.0.1:dns"}),
+		base(map[string]string{"server": ":53"}),
+		base(map[string]string{"zone": "example..com"}),
+		base(map[string]string{"record": "vip.example.org"}),
+		base(map[string]string{"record": "vipexample.com"}),
+		base(map[string]string{"record": strings.Repeat("a", 64) + ".example.com"}),
+		base(map[string]string{"ttl": "-1"}),
+		base(map[string]string{"key": "k"}),
+		base(map[string]string{"secret": testTSIGSecret}),
+		base(map[string]string{"key": "k", "secret": "not base64!"}),
+		base(map[string]string{"key": "k", "secret": testTSIGSecret, "algorithm": "hmac-md5"}),
+		base(map[string]string{"algorithm": "hmac-sha256"}),
+		base(map[string]string{"tcp": "maybe"}),
+		base(map[string]string{"port": "53"}),
+	}
+	for _, params := range invalid {
+		if err := Validate(dnsUpdateActionerName, params); err == nil {
+			t.Errorf("expect error for %v", params)
+		}
+	}
+
+	if _, err := NewActioner(dnsUpdateActionerName, nil, base(nil)); err == nil {
+		t.Errorf("expect error for no target")
+	}
+}
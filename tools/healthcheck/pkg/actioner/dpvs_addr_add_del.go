@@ -72,7 +72,8 @@ func (a *DpvsAddrAction) Act(signal types.State, timeout time.Duration,
 	defer cancel()
 
 	if err := comm.AddDelDeviceAddr(isAdd, a.apiServer, a.ifname, addr, ctx); err != nil {
-		glog.Errorf("%s actioner %v %s failed: %v", dpvsAddrActionerName, addr, operation, err)
+		logLimiter.Errorf(dpvsAddrActionerName+" actions failed",
+			"%s actioner %v %s failed: %v", dpvsAddrActionerName, addr, operation, err)
 		return nil, err
 	}
 
@@ -23,6 +23,12 @@ name                value
 -------------------------------------------------------
 ifname              linux network interface name
 with-route          also add a host route
+strict              fail deletion if the address is not on ifname
+allow-missing-link  skip the check of ifname existence for the interface created later
+gateway             route the target via the gateway, implies with-route
+tunnel-dev          route the target on the tunnel interface, implies with-route
+encap               encapsulation of tunnel-dev to check: ipip, gre, sit, ip6tnl, ip6gre
+netns               network namespace name or path of ifname and tunnel-dev
 dpvs-ifname         dpvs netif port name
 
 -------------------------------------------------------
@@ -101,11 +107,14 @@ func (a *DpvsAddrKernelRouteAction) validate(params map[string]string) error {
 			if len(val) == 0 {
 				return fmt.Errorf("empty action param %s", param)
 			}
-			// TODO: check if the interface exists on the system
-		case "with-route":
+		case "with-route", "strict", "allow-missing-link":
 			if _, err := utils.String2bool(val); err != nil {
 				return fmt.Errorf("invalid action param %s=%s", param, val)
 			}
+		case "gateway", "tunnel-dev", "encap":
+			// checked in validateRoute
+		case "netns":
+			// checked in validateNetns
 		case "dpvs-ifname":
 			if len(val) == 0 {
 				return fmt.Errorf("empty action param %s", param)
@@ -119,7 +128,15 @@ func (a *DpvsAddrKernelRouteAction) validate(params map[string]string) error {
 		return fmt.Errorf("unsupported action params: %s", strings.Join(unsupported, ","))
 	}
 
-	return nil
+	h, err := validateNetns(params)
+	if err != nil {
+		return err
+	}
+	defer h.Close()
+	if err := validateLink(h, params); err != nil {
+		return err
+	}
+	return validateRoute(h, params)
 }
 
 func (a *DpvsAddrKernelRouteAction) create(target *utils.L3L4Addr, params map[string]string,
@@ -131,8 +148,15 @@ func (a *DpvsAddrKernelRouteAction) create(target *utils.L3L4Addr, params map[st
 	if err := a.validate(params); err != nil {
 		return nil, fmt.Errorf("%s actioner param validation failed: %v", addrRouteActionerName, err)
 	}
-	krtParams := map[string]string{"ifname": params["ifname"], "with-route": params["with-route"]}
-	daddrParams := map[string]string{"dpvs-ifname": params["dpvs-ifname"]}
+	krtParams := make(map[string]string)
+	daddrParams := make(map[string]string)
+	for param, val := range params {
+		if param == "dpvs-ifname" {
+			daddrParams[param] = val
+		} else {
+			krtParams[param] = val
+		}
+	}
 
 	daddrAction, err := a.DpvsAddrAction.create(target, daddrParams, extras...)
 	if err != nil {
@@ -23,6 +23,12 @@ name                value
 -------------------------------------------------
 ifname              network interface name
 with-route          also add a host route
+strict              fail deletion if the address is not on ifname
+allow-missing-link  skip the check of ifname existence for the interface created later
+gateway             route the target via the gateway, implies with-route
+tunnel-dev          route the target on the tunnel interface, implies with-route
+encap               encapsulation of tunnel-dev to check: ipip, gre, sit, ip6tnl, ip6gre
+netns               network namespace name or path of ifname and tunnel-dev
 
 -------------------------------------------------
 */
@@ -39,6 +45,7 @@ import (
 	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/types"
 	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/utils"
 	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netns"
 	"golang.org/x/sys/unix"
 )
 
@@ -54,16 +61,90 @@ type KernelRouteAction struct {
 	target    *utils.L3L4Addr
 	ifname    string
 	withRoute bool
+	strict    bool
+	gateway   net.IP // nil for the directly connected route
+	tunnelDev string // route on ifname if empty
+	netns     string // the current network namespace if empty
 }
 
-func findLinkByAddr(addr net.IP) (netlink.Link, error) {
-	links, err := netlink.LinkList()
+// tunnelEncaps maps the values of param "encap" to the link types of netlink.
+var tunnelEncaps = map[string]string{
+	"ipip":   "ipip",
+	"gre":    "gre",
+	"sit":    "sit",
+	"ip6tnl": "ip6tnl",
+	"ip6gre": "ip6gre",
+}
+
+// hostRoute returns the host route `dst` to add or delete. By default, it's a
+// directly connected route on `link`. With a gateway, it's routed via the
+// gateway on the interface the kernel resolves, or on the tunnel interface
+// `tunnel` as an onlink route, where the gateway is the peer of the tunnel.
+// Without a gateway, it's a directly connected route on `tunnel`.
+func (a *KernelRouteAction) hostRoute(link, tunnel netlink.Link, dst *net.IPNet,
+	scope netlink.Scope) *netlink.Route {
+	route := &netlink.Route{Dst: dst}
+	switch {
+	case len(a.gateway) > 0 && tunnel != nil:
+		route.LinkIndex = tunnel.Attrs().Index
+		route.Gw = a.gateway
+		route.Flags = int(netlink.FLAG_ONLINK)
+	case len(a.gateway) > 0:
+		route.Gw = a.gateway
+	case tunnel != nil:
+		route.LinkIndex = tunnel.Attrs().Index
+		route.Scope = netlink.SCOPE_LINK
+	default:
+		route.LinkIndex = link.Attrs().Index
+		route.Scope = scope
+	}
+	return route
+}
+
+// routeDesc describes the host route to `addr` for logs.
+func (a *KernelRouteAction) routeDesc(addr net.IP) string {
+	desc := addr.String()
+	if len(a.gateway) > 0 {
+		desc += " via " + a.gateway.String()
+	}
+	if len(a.tunnelDev) > 0 {
+		return desc + " dev " + a.tunnelDev
+	}
+	if len(a.gateway) > 0 {
+		return desc
+	}
+	return desc + " dev " + a.ifname
+}
+
+// netlinkHandle returns the netlink handle to the network namespace `name`, or
+// to the current one if empty. The handle should be closed after use.
+func netlinkHandle(name string) (*netlink.Handle, error) {
+	if len(name) == 0 {
+		return &netlink.Handle{}, nil
+	}
+	file, err := utils.OpenNetns(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	// The netlink sockets are created in the namespace, and stay there after
+	// the file is closed.
+	handle, err := netlink.NewHandleAt(netns.NsHandle(file.Fd()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open netlink handle in network namespace %s: %v",
+			utils.NetnsPath(name), err)
+	}
+	return handle, nil
+}
+
+func findLinkByAddr(h *netlink.Handle, addr net.IP) (netlink.Link, error) {
+	links, err := h.LinkList()
 	if err != nil {
 		return nil, fmt.Errorf("failed to list links: %w", err)
 	}
 
 	for _, link := range links {
-		addrs, err := netlink.AddrList(link, netlink.FAMILY_ALL)
+		addrs, err := h.AddrList(link, netlink.FAMILY_ALL)
 		if err != nil {
 			continue
 		}
@@ -77,6 +158,19 @@ func findLinkByAddr(addr net.IP) (netlink.Link, error) {
 	return nil, fmt.Errorf("address %v not found on any interface", addr)
 }
 
+func linkHasAddr(h *netlink.Handle, link netlink.Link, addr net.IP) (bool, error) {
+	addrs, err := h.AddrList(link, netlink.FAMILY_ALL)
+	if err != nil {
+		return false, fmt.Errorf("failed to get addrs on %s: %w", link.Attrs().Name, err)
+	}
+	for _, a := range addrs {
+		if a.IP.Equal(addr) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
 func isExistError(err error) bool {
 	//return err == unix.EEXIST || err.Error() == "file exists"
 	return errors.Is(err, unix.EEXIST)
@@ -100,7 +194,9 @@ func (a *KernelRouteAction) Act(signal types.State, timeout time.Duration,
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
-	glog.V(7).Infof("starting %s actioner %v ...", kernelRouteActionerName, addr)
+	// The detail explains why the action is taken, and is logged for triage.
+	why := detailSuffix(data)
+	glog.V(7).Infof("starting %s actioner %v ...%s", kernelRouteActionerName, addr, why)
 
 	done := make(chan error, 1)
 
@@ -113,18 +209,40 @@ func (a *KernelRouteAction) Act(signal types.State, timeout time.Duration,
 			//	 Find ifname by IP is not feasible to deletion operation.
 
 			if len(a.ifname) == 0 {
-				if link, err = findLinkByAddr(addr); err != nil {
+				if link, err = findLinkByAddr(h, addr); err != nil {
 					done <- fmt.Errorf("failed to find link for address: %w", err)
 					return
 				}
 			}
 		*/
-		link, err = netlink.LinkByName(a.ifname)
+		h, err := netlinkHandle(a.netns)
+		if err != nil {
+			done <- err
+			return
+		}
+		defer h.Close()
+
+		link, err = h.LinkByName(a.ifname)
 		if err != nil {
 			done <- fmt.Errorf("failed to get link by name: %w", err)
 			return
 		}
 
+		// The routes on a tunnel interface are gone with the interface, so a
+		// missing tunnel interface fails the addition only.
+		var tunnel netlink.Link
+		tunnelMissing := false
+		if len(a.tunnelDev) > 0 {
+			if tunnel, err = h.LinkByName(a.tunnelDev); err != nil {
+				if signal != types.Unhealthy {
+					done <- fmt.Errorf("failed to get tunnel link by name: %w", err)
+					return
+				}
+				glog.V(8).Infof("Warning: tunnel link %s of route %v not found: %v\n", a.tunnelDev, addr, err)
+				tunnelMissing = true
+			}
+		}
+
 		var ipNet *net.IPNet
 		if addr.To4() != nil {
 			ipNet = &net.IPNet{IP: addr, Mask: net.CIDRMask(32, 32)}
@@ -132,10 +250,17 @@ func (a *KernelRouteAction) Act(signal types.State, timeout time.Duration,
 			ipNet = &net.IPNet{IP: addr, Mask: net.CIDRMask(128, 128)}
 		}
 
+		// Link-local VIPs are valid on the link only, so the address and the
+		// host route are set with the link scope rather than the universe one.
 		ipAddr := &netlink.Addr{IPNet: ipNet}
+		scope := netlink.SCOPE_UNIVERSE
+		if addr.IsLinkLocalUnicast() {
+			scope = netlink.SCOPE_LINK
+			ipAddr.Scope = int(scope)
+		}
 
 		if signal != types.Unhealthy { // ADD
-			if err := netlink.AddrAdd(link, ipAddr); err != nil {
+			if err := h.AddrAdd(link, ipAddr); err != nil {
 				if isExistError(err) {
 					glog.V(8).Infof("Warning: adding address %v already exists: %v\n", addr, err)
 				} else {
@@ -145,19 +270,36 @@ func (a *KernelRouteAction) Act(signal types.State, timeout time.Duration,
 			}
 
 			if a.withRoute {
-				route := netlink.Route{
-					LinkIndex: link.Attrs().Index,
-					Dst:       ipAddr.IPNet,
-				}
-				if err := netlink.RouteAdd(&route); err != nil {
+				route := a.hostRoute(link, tunnel, ipAddr.IPNet, scope)
+				if err := h.RouteAdd(route); err != nil {
 					if !isExistError(err) {
-						done <- fmt.Errorf("failed to add host route %v to %s: %w", addr, a.ifname, err)
+						done <- fmt.Errorf("failed to add host route %s: %w", a.routeDesc(addr), err)
 						return
 					}
 				}
 			}
 		} else { // DELETE
-			if err := netlink.AddrDel(link, ipAddr); err != nil {
+			// Verify the address is on ifname before deleting it. The address may have
+			// been moved to another interface, and deleting nothing silently leaves it
+			// lingering there.
+			found, err := linkHasAddr(h, link, addr)
+			if err != nil {
+				done <- err
+				return
+			}
+			if !found {
+				if other, err := findLinkByAddr(h, addr); err == nil {
+					logLimiter.Warningf(kernelRouteActionerName+" addresses found on other interfaces",
+						"%s actioner: deleting address %v found on %s rather than %s, leave it untouched",
+						kernelRouteActionerName, addr, other.Attrs().Name, a.ifname)
+				} else {
+					glog.V(8).Infof("Warning: deleting address %v does not exist on %s\n", addr, a.ifname)
+				}
+				if a.strict {
+					done <- fmt.Errorf("address %v to delete not found on %s", addr, a.ifname)
+					return
+				}
+			} else if err := h.AddrDel(link, ipAddr); err != nil {
 				if isNotExistError(err) {
 					glog.V(8).Infof("Warning: deleting address %v does not exist: %v\n", addr, err)
 				} else {
@@ -166,14 +308,13 @@ func (a *KernelRouteAction) Act(signal types.State, timeout time.Duration,
 				}
 			}
 
-			if a.withRoute {
-				route := netlink.Route{
-					LinkIndex: link.Attrs().Index,
-					Dst:       ipAddr.IPNet,
-				}
-				if err := netlink.RouteDel(&route); err != nil {
+			// The route is deleted exactly as added, including the gateway, so
+			// that the routes to the target added by others are left untouched.
+			if a.withRoute && !tunnelMissing {
+				route := a.hostRoute(link, tunnel, ipAddr.IPNet, scope)
+				if err := h.RouteDel(route); err != nil {
 					if !isNotExistError(err) {
-						done <- fmt.Errorf("failed to delete route %v from %s: %w", addr, a.ifname, err)
+						done <- fmt.Errorf("failed to delete route %s: %w", a.routeDesc(addr), err)
 						return
 					}
 				}
@@ -189,15 +330,17 @@ func (a *KernelRouteAction) Act(signal types.State, timeout time.Duration,
 
 	select {
 	case <-ctx.Done():
-		glog.Errorf("%s actioner %v %s timeout", kernelRouteActionerName, addr, operation)
+		logLimiter.Errorf(kernelRouteActionerName+" actions timeout",
+			"%s actioner %v %s timeout%s", kernelRouteActionerName, addr, operation, why)
 		return nil, ctx.Err()
 	case err := <-done:
 		if err != nil {
-			glog.Errorf("%s actioner %v %s failed: %v", kernelRouteActionerName, addr, operation, err)
+			logLimiter.Errorf(kernelRouteActionerName+" actions failed",
+				"%s actioner %v %s failed: %v%s", kernelRouteActionerName, addr, operation, err, why)
 			return nil, err
 		}
 	}
-	glog.V(6).Infof("%s actioner %v %s succeed", kernelRouteActionerName, addr, operation)
+	glog.V(6).Infof("%s actioner %v %s succeed%s", kernelRouteActionerName, addr, operation, why)
 	return nil, nil
 }
 
@@ -220,11 +363,14 @@ func (a *KernelRouteAction) validate(params map[string]string) error {
 			if len(val) == 0 {
 				return fmt.Errorf("empty action param %s", param)
 			}
-			// TODO: check if the interface exists on the system
-		case "with-route":
+		case "with-route", "strict", "allow-missing-link":
 			if _, err := utils.String2bool(val); err != nil {
 				return fmt.Errorf("invalid action param %s=%s", param, val)
 			}
+		case "gateway", "tunnel-dev", "encap":
+			// checked in validateRoute
+		case "netns":
+			// checked in validateNetns
 		default:
 			unsupported = append(unsupported, param)
 		}
@@ -233,6 +379,108 @@ func (a *KernelRouteAction) validate(params map[string]string) error {
 		return fmt.Errorf("unsupported action params: %s", strings.Join(unsupported, ","))
 	}
 
+	h, err := validateNetns(params)
+	if err != nil {
+		return err
+	}
+	defer h.Close()
+	if err := validateLink(h, params); err != nil {
+		return err
+	}
+	return validateRoute(h, params)
+}
+
+// validateNetns checks the network namespace of param "netns" exists, and
+// returns the netlink handle to it, or to the current one if the param is
+// empty, for the checks of the links and routes.
+func validateNetns(params map[string]string) (*netlink.Handle, error) {
+	name := params["netns"]
+	h, err := netlinkHandle(name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid action param netns=%s: %v", name, err)
+	}
+	return h, nil
+}
+
+// validateRoute checks the params "gateway", "tunnel-dev" and "encap" of the
+// host route. The gateway must be directly reachable unless it's routed on the
+// tunnel interface, whose existence and encapsulation are checked unless param
+// "allow-missing-link" is true.
+func validateRoute(h *netlink.Handle, params map[string]string) error {
+	gwParam, hasGateway := params["gateway"]
+	tunnelDev, hasTunnel := params["tunnel-dev"]
+	encap, hasEncap := params["encap"]
+	if !hasGateway && !hasTunnel && !hasEncap {
+		return nil
+	}
+	if val, ok := params["with-route"]; ok {
+		if withRoute, _ := utils.String2bool(val); !withRoute {
+			return fmt.Errorf("action params gateway/tunnel-dev/encap conflict with with-route=%s", val)
+		}
+	}
+
+	var gateway net.IP
+	if hasGateway {
+		if gateway = net.ParseIP(gwParam); gateway == nil || gateway.IsUnspecified() {
+			return fmt.Errorf("invalid action param gateway=%s", gwParam)
+		}
+	}
+	if hasTunnel && len(tunnelDev) == 0 {
+		return fmt.Errorf("empty action param tunnel-dev")
+	}
+	if hasEncap {
+		if !hasTunnel {
+			return fmt.Errorf("action param encap requires tunnel-dev")
+		}
+		if _, ok := tunnelEncaps[encap]; !ok {
+			return fmt.Errorf("invalid action param encap=%s", encap)
+		}
+	}
+
+	if hasTunnel {
+		if allow, _ := utils.String2bool(params["allow-missing-link"]); allow {
+			return nil
+		}
+		link, err := h.LinkByName(tunnelDev)
+		if err != nil {
+			return fmt.Errorf("invalid action param tunnel-dev=%s: %v, "+
+				"set allow-missing-link=true if it's created later", tunnelDev, err)
+		}
+		if hasEncap && link.Type() != tunnelEncaps[encap] {
+			return fmt.Errorf("action param tunnel-dev=%s is a %s link rather than encap=%s",
+				tunnelDev, link.Type(), encap)
+		}
+		return nil
+	}
+
+	if gateway != nil {
+		routes, err := h.RouteGet(gateway)
+		if err != nil {
+			return fmt.Errorf("action param gateway=%s unreachable: %v", gwParam, err)
+		}
+		for _, route := range routes {
+			if len(route.Gw) > 0 {
+				return fmt.Errorf("action param gateway=%s not directly reachable, routed via %v",
+					gwParam, route.Gw)
+			}
+		}
+	}
+	return nil
+}
+
+// validateLink checks if the network interface of param "ifname" exists on the
+// system, unless param "allow-missing-link" is true for the interface created
+// later, so that a typo'd ifname is found at config load time rather than when
+// failover.
+func validateLink(h *netlink.Handle, params map[string]string) error {
+	if allow, _ := utils.String2bool(params["allow-missing-link"]); allow {
+		return nil
+	}
+	ifname := params["ifname"]
+	if _, err := h.LinkByName(ifname); err != nil {
+		return fmt.Errorf("invalid action param ifname=%s: %v, "+
+			"set allow-missing-link=true if it's created later", ifname, err)
+	}
 	return nil
 }
 
@@ -245,11 +493,29 @@ func (a *KernelRouteAction) create(target *utils.L3L4Addr, params map[string]str
 	if err := a.validate(params); err != nil {
 		return nil, fmt.Errorf("%s actioner param validation failed: %v", kernelRouteActionerName, err)
 	}
+	if len(target.Zone) > 0 && target.Zone != params["ifname"] {
+		return nil, fmt.Errorf("%s actioner target zone %q mismatches ifname %q",
+			kernelRouteActionerName, target.Zone, params["ifname"])
+	}
 
 	withRoute, _ := utils.String2bool(params["with-route"])
+	strict, _ := utils.String2bool(params["strict"])
+	gateway := net.ParseIP(params["gateway"])
+	if gateway != nil && (gateway.To4() == nil) != (target.IP.To4() == nil) {
+		return nil, fmt.Errorf("%s actioner gateway %v mismatches the address family of target %v",
+			kernelRouteActionerName, gateway, target.IP)
+	}
+	tunnelDev := params["tunnel-dev"]
+	if gateway != nil || len(tunnelDev) > 0 {
+		withRoute = true
+	}
 	return &KernelRouteAction{
 		target:    target.DeepCopy(),
 		ifname:    params["ifname"],
 		withRoute: withRoute,
+		strict:    strict,
+		gateway:   gateway,
+		tunnelDev: tunnelDev,
+		netns:     params["netns"],
 	}, nil
 }
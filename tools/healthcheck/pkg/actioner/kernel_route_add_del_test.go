@@ -0,0 +1,275 @@
+// /*
+// Copyright 2025 IQiYi Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// */
+
+package actioner
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/types"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/utils"
+	"github.com/vishvananda/netlink"
+)
+
+func TestKernelRouteValidateLink(t *testing.T) {
+	const missing = "hc-missing0"
+	for name, method := range map[string]ActionMethod{
+		kernelRouteActionerName: &KernelRouteAction{},
+		addrRouteActionerName:   &DpvsAddrKernelRouteAction{},
+	} {
+		params := func(kv ...string) map[string]string {
+			res := map[string]string{"dpvs-ifname": "dpdk0"}
+			if name == kernelRouteActionerName {
+				res = map[string]string{}
+			}
+			for i := 0; i+1 < len(kv); i += 2 {
+				res[kv[i]] = kv[i+1]
+			}
+			return res
+		}
+
+		if err := method.validate(params("ifname", "lo")); err != nil {
+			t.Errorf("%s: unexpected error with existing ifname: %v", name, err)
+		}
+		err := method.validate(params("ifname", missing))
+		if err == nil || !strings.Contains(err.Error(), "ifname="+missing) ||
+			!strings.Contains(err.Error(), "allow-missing-link=true") {
+			t.Errorf("%s: expect error with missing ifname, got %v", name, err)
+		}
+		if err := method.validate(params("ifname", missing, "allow-missing-link", "true")); err != nil {
+			t.Errorf("%s: unexpected error with allow-missing-link: %v", name, err)
+		}
+		if err := method.validate(params("ifname", missing, "allow-missing-link", "no")); err == nil {
+			t.Errorf("%s: expect error with allow-missing-link=no", name)
+		}
+		if err := method.validate(params("ifname", "lo", "allow-missing-link", "maybe")); err == nil {
+			t.Errorf("%s: expect error with invalid allow-missing-link", name)
+		}
+	}
+}
+
+func TestKernelRouteValidateRoute(t *testing.T) {
+	const missing = "hc-missing0"
+	for i, c := range []struct {
+		params map[string]string
+		valid  bool
+	}{
+		{map[string]string{"gateway": "127.0.0.1"}, true},
+		{map[string]string{"gateway": "127.0.0.1", "with-route": "yes"}, true},
+		{map[string]string{"gateway": "127.0.0.1", "with-route": "no"}, false},
+		{map[string]string{"gateway": "127.0.0.x"}, false},
+		{map[string]string{"gateway": "0.0.0.0"}, false},
+		{map[string]string{"gateway": "198.51.100.1"}, false}, // not directly reachable
+		{map[string]string{"tunnel-dev": "lo"}, true},
+		{map[string]string{"tunnel-dev": ""}, false},
+		{map[string]string{"tunnel-dev": missing}, false},
+		{map[string]string{"tunnel-dev": missing, "allow-missing-link": "true"}, true},
+		{map[string]string{"tunnel-dev": missing, "allow-missing-link": "true", "encap": "gre",
+			"gateway": "198.51.100.1"}, true},
+		{map[string]string{"tunnel-dev": "lo", "encap": "ipip"}, false}, // lo is not an ipip link
+		{map[string]string{"tunnel-dev": "lo", "encap": "vxlan"}, false},
+		{map[string]string{"encap": "ipip"}, false},
+	} {
+		for name, method := range map[string]ActionMethod{
+			kernelRouteActionerName: &KernelRouteAction{},
+			addrRouteActionerName:   &DpvsAddrKernelRouteAction{},
+		} {
+			params := map[string]string{"ifname": "lo"}
+			if name == addrRouteActionerName {
+				params["dpvs-ifname"] = "dpdk0"
+			}
+			for k, v := range c.params {
+				params[k] = v
+			}
+			if err := method.validate(params); (err == nil) != c.valid {
+				t.Errorf("case %d %s: expect valid %v, got error %v", i, name, c.valid, err)
+			}
+		}
+	}
+}
+
+func TestKernelRouteHostRoute(t *testing.T) {
+	target := &utils.L3L4Addr{IP: net.ParseIP("192.168.88.1")}
+	create := func(params map[string]string) *KernelRouteAction {
+		params["ifname"] = "lo"
+		params["allow-missing-link"] = "true"
+		method, err := (&KernelRouteAction{}).create(target, params)
+		if err != nil {
+			t.Fatalf("failed to create actioner with %v: %v", params, err)
+		}
+		return method.(*KernelRouteAction)
+	}
+	link := &netlink.Dummy{LinkAttrs: netlink.LinkAttrs{Name: "lo", Index: 1}}
+	tunnel := &netlink.Iptun{LinkAttrs: netlink.LinkAttrs{Name: "tunl1", Index: 7}}
+	dst := &net.IPNet{IP: target.IP, Mask: net.CIDRMask(32, 32)}
+	gateway := net.ParseIP("10.0.0.1")
+
+	a := create(map[string]string{"with-route": "true"})
+	route := a.hostRoute(link, nil, dst, netlink.SCOPE_UNIVERSE)
+	if route.LinkIndex != 1 || route.Gw != nil || route.Flags != 0 || route.Scope != netlink.SCOPE_UNIVERSE {
+		t.Errorf("directly connected route: unexpected %+v", route)
+	}
+
+	a = create(map[string]string{"gateway": "10.0.0.1", "tunnel-dev": "tunl1"})
+	if !a.withRoute {
+		t.Errorf("expect gateway implies with-route")
+	}
+	route = a.hostRoute(link, tunnel, dst, netlink.SCOPE_UNIVERSE)
+	if route.LinkIndex != 7 || !route.Gw.Equal(gateway) || route.Flags != int(netlink.FLAG_ONLINK) {
+		t.Errorf("tunnel route via gateway: unexpected %+v", route)
+	}
+	if desc := a.routeDesc(target.IP); desc != "192.168.88.1 via 10.0.0.1 dev tunl1" {
+		t.Errorf("unexpected route description %q", desc)
+	}
+
+	a = create(map[string]string{"tunnel-dev": "tunl1"})
+	route = a.hostRoute(link, tunnel, dst, netlink.SCOPE_UNIVERSE)
+	if route.LinkIndex != 7 || route.Gw != nil || route.Scope != netlink.SCOPE_LINK {
+		t.Errorf("tunnel route: unexpected %+v", route)
+	}
+
+	a = &KernelRouteAction{target: target, ifname: "lo", withRoute: true, gateway: gateway}
+	route = a.hostRoute(link, nil, dst, netlink.SCOPE_UNIVERSE)
+	if route.LinkIndex != 0 || !route.Gw.Equal(gateway) || route.Flags != 0 {
+		t.Errorf("route via gateway: unexpected %+v", route)
+	}
+
+	_, err := (&KernelRouteAction{}).create(target, map[string]string{"ifname": "lo",
+		"gateway": "fd00::1", "tunnel-dev": "tunl1", "allow-missing-link": "true"})
+	if err == nil {
+		t.Errorf("expect gateway of another address family fails")
+	}
+}
+
+// netnsTestNet sets up two network namespaces connected by a veth pair.
+//
+//	(netns hcrt-a) hcrt0 10.252.0.1/24 <--> hcrt1 10.252.0.2/24 (netns hcrt-b)
+func netnsTestNet(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("root privilege required")
+	}
+	if _, err := exec.LookPath("ip"); err != nil {
+		t.Skip("iproute2 not found")
+	}
+	run := func(args ...string) error {
+		if out, err := exec.Command("ip", args...).CombinedOutput(); err != nil {
+			return fmt.Errorf("ip %s: %v: %s", strings.Join(args, " "), err, bytes.TrimSpace(out))
+		}
+		return nil
+	}
+	cleanup := func() {
+		exec.Command("ip", "netns", "del", "hcrt-a").Run()
+		exec.Command("ip", "netns", "del", "hcrt-b").Run()
+	}
+	cleanup()
+	t.Cleanup(cleanup)
+
+	steps := [][]string{
+		{"netns", "add", "hcrt-a"},
+		{"netns", "add", "hcrt-b"},
+		{"-n", "hcrt-a", "link", "add", "hcrt0", "type", "veth", "peer", "name", "hcrt1", "netns", "hcrt-b"},
+		{"-n", "hcrt-a", "addr", "add", "10.252.0.1/24", "dev", "hcrt0"},
+		{"-n", "hcrt-b", "addr", "add", "10.252.0.2/24", "dev", "hcrt1"},
+		{"-n", "hcrt-a", "link", "set", "hcrt0", "up"},
+		{"-n", "hcrt-b", "link", "set", "hcrt1", "up"},
+	}
+	for _, step := range steps {
+		if err := run(step...); err != nil {
+			t.Skipf("failed to set up test network: %v", err)
+		}
+	}
+}
+
+func TestKernelRouteValidateNetns(t *testing.T) {
+	a := &KernelRouteAction{}
+	err := a.validate(map[string]string{"ifname": "lo", "netns": "hc-missing-ns"})
+	if err == nil || !strings.Contains(err.Error(), "netns=hc-missing-ns") ||
+		!strings.Contains(err.Error(), "not found") {
+		t.Errorf("expect error with missing netns, got %v", err)
+	}
+	if err := a.validate(map[string]string{"ifname": "lo", "netns": ""}); err != nil {
+		t.Errorf("unexpected error with empty netns: %v", err)
+	}
+	if err := a.validate(map[string]string{"ifname": "lo", "netns": "/proc/self/ns/net"}); err != nil {
+		t.Errorf("unexpected error with netns path: %v", err)
+	}
+}
+
+func TestKernelRouteNetns(t *testing.T) {
+	netnsTestNet(t)
+
+	target := &utils.L3L4Addr{IP: net.ParseIP("10.252.0.10")}
+	if _, err := (&KernelRouteVerdictAction{}).create(target, map[string]string{"ifname": "hcrt0"}); err == nil {
+		t.Fatalf("expect error with ifname out of the current netns")
+	}
+	method, err := (&KernelRouteVerdictAction{}).create(target, map[string]string{"ifname": "hcrt0",
+		"with-route": "true", "gateway": "10.252.0.2", "netns": "hcrt-a"})
+	if err != nil {
+		t.Fatalf("failed to create actioner in netns: %v", err)
+	}
+	a := method.(*KernelRouteVerdictAction)
+
+	h, err := netlinkHandle("hcrt-a")
+	if err != nil {
+		t.Fatalf("failed to open netlink handle: %v", err)
+	}
+	defer h.Close()
+	link, err := h.LinkByName("hcrt0")
+	if err != nil {
+		t.Fatalf("failed to get link hcrt0: %v", err)
+	}
+	hasRoute := func() bool {
+		routes, err := h.RouteListFiltered(netlink.FAMILY_V4, &netlink.Route{Gw: net.ParseIP("10.252.0.2")},
+			netlink.RT_FILTER_GW)
+		if err != nil {
+			t.Fatalf("failed to list routes: %v", err)
+		}
+		for _, route := range routes {
+			if route.Dst != nil && route.Dst.IP.Equal(target.IP) {
+				return true
+			}
+		}
+		return false
+	}
+
+	timeout := time.Second
+	for _, signal := range []types.State{types.Healthy, types.Unhealthy} {
+		if _, err := a.Act(signal, timeout); err != nil {
+			t.Fatalf("failed to act %v in netns: %v", signal, err)
+		}
+		found, err := linkHasAddr(h, link, target.IP)
+		if err != nil {
+			t.Fatalf("failed to check address: %v", err)
+		}
+		if found != (signal == types.Healthy) || hasRoute() != (signal == types.Healthy) {
+			t.Errorf("act %v in netns: unexpected address %v, route %v", signal, found, hasRoute())
+		}
+		if state, err := a.Verdict(timeout); err != nil || state != signal {
+			t.Errorf("verdict after act %v: got %v, %v", signal, state, err)
+		}
+		// Nothing is done to the current namespace.
+		if link, err := findLinkByAddr(&netlink.Handle{}, target.IP); err == nil {
+			t.Errorf("address %v leaks into the current netns on %s", target.IP, link.Attrs().Name)
+		}
+	}
+}
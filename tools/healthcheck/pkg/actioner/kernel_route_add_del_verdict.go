@@ -23,6 +23,12 @@ name                value
 -------------------------------------------------
 ifname              network interface name
 with-route          also add a host route
+strict              fail deletion if the address is not on ifname
+allow-missing-link  skip the check of ifname existence for the interface created later
+gateway             route the target via the gateway, implies with-route
+tunnel-dev          route the target on the tunnel interface, implies with-route
+encap               encapsulation of tunnel-dev to check: ipip, gre, sit, ip6tnl, ip6gre
+netns               network namespace name or path of ifname and tunnel-dev
 
 -------------------------------------------------
 */
@@ -32,7 +38,6 @@ import (
 	"fmt"
 	"time"
 
-	"github.com/golang/glog"
 	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/types"
 	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/utils"
 	"github.com/vishvananda/netlink"
@@ -55,7 +60,7 @@ type KernelRouteVerdictAction struct {
 
 func (a *KernelRouteVerdictAction) Act(signal types.State, timeout time.Duration,
 	data ...interface{}) (interface{}, error) {
-	return a.KernelRouteAction.Act(signal, timeout, data)
+	return a.KernelRouteAction.Act(signal, timeout, data...)
 }
 
 func (a *KernelRouteVerdictAction) create(target *utils.L3L4Addr, params map[string]string,
@@ -88,12 +93,19 @@ func (a *KernelRouteVerdictAction) Verdict(timeout time.Duration) (types.State,
 	done := make(chan error, 1)
 
 	go func() {
-		link, err := netlink.LinkByName(a.ifname)
+		h, err := netlinkHandle(a.netns)
+		if err != nil {
+			done <- err
+			return
+		}
+		defer h.Close()
+
+		link, err := h.LinkByName(a.ifname)
 		if err != nil {
 			done <- fmt.Errorf("failed to get link by name: %w", err)
 			return
 		}
-		addrs, err := netlink.AddrList(link, netlink.FAMILY_ALL)
+		addrs, err := h.AddrList(link, netlink.FAMILY_ALL)
 		if err != nil {
 			done <- fmt.Errorf("failed to get addrs on %s: %w", a.ifname, err)
 			return
@@ -111,7 +123,8 @@ func (a *KernelRouteVerdictAction) Verdict(timeout time.Duration) (types.State,
 
 	select {
 	case <-ctx.Done():
-		glog.Warningf("%s actioner %v verdict timeout", kernelRouteVerdictActionerName, targetIP)
+		logLimiter.Warningf(kernelRouteVerdictActionerName+" verdicts timeout",
+			"%s actioner %v verdict timeout", kernelRouteVerdictActionerName, targetIP)
 		return types.Unknown, ctx.Err()
 	case err := <-done:
 		if err != nil {
@@ -0,0 +1,125 @@
+// /*
+// Copyright 2025 IQiYi Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// */
+
+package actioner
+
+/*
+Recording Actioner Params:
+-------------------------------------------------
+name                value
+-------------------------------------------------
+
+-------------------------------------------------
+
+The actioner is an in-memory fake for tests, which records the calls to Act
+instead of taking any action. It's unavailable until RegisterRecordingAction
+is called, so it never shows up in the production configs.
+*/
+
+import (
+	"sync"
+	"time"
+
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/types"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/utils"
+)
+
+var _ ActionMethod = (*RecordingAction)(nil)
+
+const RecordingActionerName = "Recording"
+
+// ActionCall is a call to Act recorded by RecordingAction.
+type ActionCall struct {
+	Target  *utils.L3L4Addr // target of the actioner, nil if not bound
+	Signal  types.State
+	Timeout time.Duration
+	Data    []interface{}
+	Time    time.Time
+}
+
+type actionRecords struct {
+	lock   sync.Mutex
+	calls  []ActionCall
+	result interface{}
+	err    error
+}
+
+// RecordingAction is an ActionMethod recording the calls it receives. The
+// actioners created from it by NewActioner share the records with it, so that
+// the calls to all targets are available from the registered one in order.
+type RecordingAction struct {
+	target  *utils.L3L4Addr
+	records *actionRecords
+}
+
+// NewRecordingAction returns a RecordingAction with empty records.
+func NewRecordingAction() *RecordingAction {
+	return &RecordingAction{records: &actionRecords{}}
+}
+
+// RegisterRecordingAction registers `a` as the actioner RecordingActionerName.
+// Registering again replaces the one registered before.
+func RegisterRecordingAction(a *RecordingAction) {
+	methods[RecordingActionerName] = a
+}
+
+func (a *RecordingAction) Act(signal types.State, timeout time.Duration,
+	data ...interface{}) (interface{}, error) {
+	call := ActionCall{
+		Target:  a.target.DeepCopy(),
+		Signal:  signal,
+		Timeout: timeout,
+		Data:    append([]interface{}(nil), data...),
+		Time:    time.Now(),
+	}
+	a.records.lock.Lock()
+	defer a.records.lock.Unlock()
+	a.records.calls = append(a.records.calls, call)
+	return a.records.result, a.records.err
+}
+
+// Calls returns the calls recorded in order.
+func (a *RecordingAction) Calls() []ActionCall {
+	a.records.lock.Lock()
+	defer a.records.lock.Unlock()
+	return append([]ActionCall(nil), a.records.calls...)
+}
+
+// SetResult makes the subsequent calls to Act return `result` and `err`.
+func (a *RecordingAction) SetResult(result interface{}, err error) {
+	a.records.lock.Lock()
+	a.records.result, a.records.err = result, err
+	a.records.lock.Unlock()
+}
+
+// Reset forgets the calls recorded.
+func (a *RecordingAction) Reset() {
+	a.records.lock.Lock()
+	a.records.calls = nil
+	a.records.lock.Unlock()
+}
+
+func (a *RecordingAction) create(target *utils.L3L4Addr, params map[string]string,
+	extras ...interface{}) (ActionMethod, error) {
+	return &RecordingAction{
+		target:  target.DeepCopy(),
+		records: a.records,
+	}, nil
+}
+
+func (a *RecordingAction) validate(params map[string]string) error {
+	return nil
+}
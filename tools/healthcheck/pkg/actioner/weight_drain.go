@@ -0,0 +1,308 @@
+// /*
+// Copyright 2025 IQiYi Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// */
+
+package actioner
+
+/*
+WeightDrain Actioner Params:
+-------------------------------------------------------
+name                value
+-------------------------------------------------------
+drain-timeout       duration to drain an unhealthy backend before removing it, 0 to drain forever
+restore-weight      weight restored for a backend recovered from drained
+
+-------------------------------------------------------
+
+Unlike BackendUpdate which inhibits an unhealthy backend at once, WeightDrain
+drains it in two stages. The backend's weight is set to 0 first, so that no new
+connections are scheduled to it while the existing ones finish gracefully. If it
+is still unhealthy after `drain-timeout`, it's removed by the `inhibited` flag.
+A recovered backend gets its weight restored, which is `restore-weight` if given,
+or else the weight given by the VS, or the weight before drained if 0 is given.
+The `restore-weight` applies to the recovery only, so the backends never drained
+and the later weights given by the VS, such as the intermediate weights of
+slow-start ramps, are kept as they are.
+*/
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/comm"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/types"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/utils"
+)
+
+var _ ActionMethod = (*WeightDrainAction)(nil)
+var _ ActionMethodWithStop = (*WeightDrainAction)(nil)
+var _ ActionMethodWithDispatch = (*WeightDrainAction)(nil)
+
+const weightDrainActionerName = "WeightDrain"
+
+// weightDrainRetryInterval is the interval to retry a failed removal.
+var weightDrainRetryInterval = 5 * time.Second
+
+func init() {
+	registerMethod(weightDrainActionerName, &WeightDrainAction{})
+}
+
+// weightUpdater pushes the weights and inhibited flags of backends in `vs` to
+// dpvs, and returns the new VS if `vs` is outdated, as comm.UpdateCheckState.
+type weightUpdater func(ctx context.Context, vs *comm.VirtualServer) (*comm.VirtualServer, error)
+
+// drainState tracks an unhealthy backend being drained.
+type drainState struct {
+	addr    utils.L3L4Addr
+	weight  uint16 // the pre-drain weight, 0 if unknown
+	since   time.Time
+	removed bool // removed after drain-timeout
+	timer   *time.Timer
+}
+
+type WeightDrainAction struct {
+	name          string
+	drainTimeout  time.Duration
+	restoreWeight uint16
+	update        weightUpdater
+
+	lock     sync.Mutex
+	dispatch ActionDispatch
+	stopped  bool
+	vs       comm.VirtualServer     // the latest VS acted, without RSs
+	timeout  time.Duration          // the latest action timeout
+	weights  map[string]uint16      // weights of healthy backends, keyed by L3L4Addr::String()
+	drains   map[string]*drainState // backends being drained, keyed by L3L4Addr::String()
+}
+
+// SetDispatch sets the dispatch for removals of the backends drained for
+// drain-timeout, so that they are paced as the actions by Act.
+func (a *WeightDrainAction) SetDispatch(dispatch ActionDispatch) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.dispatch = dispatch
+}
+
+// Stop stops the drain timers, and no backend is removed by the actioner
+// afterwards.
+func (a *WeightDrainAction) Stop() {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.stopped = true
+	for _, d := range a.drains {
+		if d.timer != nil {
+			d.timer.Stop()
+		}
+	}
+}
+
+func (a *WeightDrainAction) Act(signal types.State, timeout time.Duration,
+	data ...interface{}) (interface{}, error) {
+	if timeout <= 0 {
+		return nil, fmt.Errorf("zero timeout on actioner %s", a.name)
+	}
+	if len(data) < 1 {
+		return nil, fmt.Errorf("%s missing backend data", a.name)
+	}
+	vs, ok := data[0].(*comm.VirtualServer)
+	if !ok || vs == nil || len(vs.RSs) == 0 {
+		return nil, fmt.Errorf("invalid backend data for %s", a.name)
+	}
+
+	glog.V(7).Infof("starting %s actioner %s ...", weightDrainActionerName, a.name)
+
+	a.lock.Lock()
+	a.vs = comm.VirtualServer{Version: vs.Version, Addr: vs.Addr}
+	a.timeout = timeout
+	drained := vs.DeepCopy()
+	for i := range drained.RSs {
+		a.translate(&drained.RSs[i])
+	}
+	a.lock.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	newVS, err := a.update(ctx, drained)
+	if err != nil {
+		logLimiter.Errorf(weightDrainActionerName+" actions failed",
+			"%s actioner %s (VS: %v) failed: %v", weightDrainActionerName, a.name, *drained, err)
+	} else if newVS != nil {
+		glog.Warningf("%s actioner %s (VS: %v) outdated and returned newVS %v",
+			weightDrainActionerName, a.name, *drained, newVS)
+	} else {
+		glog.V(6).Infof("%s actioner %s (VS %v) succeed", weightDrainActionerName, a.name, *drained)
+	}
+
+	return newVS, err
+}
+
+// translate converts the backend state of `rs` given by the VS, which is
+// inhibited if unhealthy, into the weight and inhibited flag to push to dpvs.
+// It's idempotent when the same state repeats. The caller must hold the lock.
+func (a *WeightDrainAction) translate(rs *comm.RealServer) {
+	key := rs.Addr.String()
+	if rs.Inhibited {
+		d, ok := a.drains[key]
+		if !ok {
+			d = &drainState{addr: rs.Addr, weight: a.weights[key], since: time.Now()}
+			a.drains[key] = d
+			if a.drainTimeout > 0 && !a.stopped {
+				d.timer = time.AfterFunc(a.drainTimeout, func() { a.remove(key, d) })
+			}
+			glog.Infof("%s actioner %s starts draining backend %s, pre-drain weight %d",
+				weightDrainActionerName, a.name, key, d.weight)
+		}
+		rs.Weight = 0
+		rs.Inhibited = d.removed
+		return
+	}
+
+	weight := rs.Weight
+	if d, ok := a.drains[key]; ok {
+		if d.timer != nil {
+			d.timer.Stop()
+		}
+		delete(a.drains, key)
+		if a.restoreWeight > 0 {
+			weight = a.restoreWeight
+		} else if weight == 0 {
+			weight = d.weight
+		}
+		glog.Infof("%s actioner %s stops draining backend %s after %v",
+			weightDrainActionerName, a.name, key, time.Since(d.since).Round(time.Millisecond))
+	} else if weight == 0 {
+		weight = a.weights[key]
+	}
+	rs.Weight = weight
+	if weight > 0 {
+		a.weights[key] = weight
+	}
+}
+
+// remove removes the backend `key` which is still drained by `d` after
+// drain-timeout, and retries in weightDrainRetryInterval if failed.
+func (a *WeightDrainAction) remove(key string, d *drainState) {
+	a.lock.Lock()
+	if a.stopped || a.drains[key] != d || d.removed {
+		a.lock.Unlock()
+		return
+	}
+	vs := a.vs
+	vs.RSs = []comm.RealServer{{Addr: d.addr, Weight: 0, Inhibited: true}}
+	timeout := a.timeout
+	dispatch := a.dispatch
+	a.lock.Unlock()
+
+	job := func(timeout time.Duration) (interface{}, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		return a.update(ctx, &vs)
+	}
+	var resp interface{}
+	var err error
+	if dispatch != nil {
+		resp, err = dispatch(key, timeout, job)
+	} else {
+		resp, err = job(timeout)
+	}
+	if newVS, ok := resp.(*comm.VirtualServer); err == nil && ok && newVS != nil {
+		err = fmt.Errorf("outdated vs version %d", vs.Version)
+	}
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if a.stopped {
+		return
+	}
+	if a.drains[key] != d {
+		// The backend recovered during the removal, and the weight restoration
+		// is pushed after it, which overrides the removal.
+		return
+	}
+	if err != nil {
+		logLimiter.Errorf(weightDrainActionerName+" actions failed",
+			"%s actioner %s removes drained backend %s failed: %v, retry in %v",
+			weightDrainActionerName, a.name, key, err, weightDrainRetryInterval)
+		d.timer = time.AfterFunc(weightDrainRetryInterval, func() { a.remove(key, d) })
+		return
+	}
+	d.removed = true
+	glog.Infof("%s actioner %s removed backend %s drained for %v", weightDrainActionerName,
+		a.name, key, time.Since(d.since).Round(time.Millisecond))
+}
+
+func (a *WeightDrainAction) validate(params map[string]string) error {
+	unsupported := make([]string, 0, len(params))
+	for param, val := range params {
+		switch param {
+		case "drain-timeout":
+			if d, err := time.ParseDuration(val); err != nil || d < 0 {
+				return fmt.Errorf("invalid action param %s=%s", param, val)
+			}
+		case "restore-weight":
+			if w, err := strconv.ParseUint(val, 10, 16); err != nil || w == 0 {
+				return fmt.Errorf("invalid action param %s=%s", param, val)
+			}
+		default:
+			unsupported = append(unsupported, param)
+		}
+	}
+	if len(unsupported) > 0 {
+		return fmt.Errorf("unsupported action params: %s", strings.Join(unsupported, ","))
+	}
+	return nil
+}
+
+func (a *WeightDrainAction) create(target *utils.L3L4Addr, params map[string]string,
+	extras ...interface{}) (ActionMethod, error) {
+	if err := a.validate(params); err != nil {
+		return nil, fmt.Errorf("%s actioner param validation failed: %v", weightDrainActionerName, err)
+	}
+
+	actioner := &WeightDrainAction{
+		name:    target.String(),
+		update:  a.update,
+		weights: make(map[string]uint16),
+		drains:  make(map[string]*drainState),
+	}
+	if val, ok := params["drain-timeout"]; ok {
+		actioner.drainTimeout, _ = time.ParseDuration(val)
+	}
+	if val, ok := params["restore-weight"]; ok {
+		weight, _ := strconv.ParseUint(val, 10, 16)
+		actioner.restoreWeight = uint16(weight)
+	}
+
+	if actioner.update == nil {
+		var apiServer string
+		if len(extras) > 0 {
+			apiServer, _ = extras[0].(string)
+		}
+		if len(apiServer) == 0 {
+			return nil, fmt.Errorf("%s actioner misses dpvs api server config", weightDrainActionerName)
+		}
+		actioner.update = func(ctx context.Context, vs *comm.VirtualServer) (*comm.VirtualServer, error) {
+			return comm.UpdateCheckState(apiServer, vs, ctx)
+		}
+	}
+
+	return actioner, nil
+}
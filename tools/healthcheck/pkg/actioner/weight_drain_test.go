@@ -0,0 +1,253 @@
+// /*
+// Copyright 2025 IQiYi Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// */
+
+package actioner
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/comm"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/types"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/utils"
+)
+
+// fakeDpvs records the backends pushed by weightUpdater.
+type fakeDpvs struct {
+	lock  sync.Mutex
+	calls [][]comm.RealServer
+	errs  []error // returned by the calls in order
+}
+
+func (f *fakeDpvs) update(ctx context.Context, vs *comm.VirtualServer) (*comm.VirtualServer, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.calls = append(f.calls, append([]comm.RealServer(nil), vs.RSs...))
+	if len(f.errs) > 0 {
+		err := f.errs[0]
+		f.errs = f.errs[1:]
+		return nil, err
+	}
+	return nil, nil
+}
+
+// expect checks the backend pushed by the n-th call.
+func (f *fakeDpvs) expect(t *testing.T, n int, ip string, weight uint16, inhibited bool) {
+	t.Helper()
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if len(f.calls) != n+1 {
+		t.Fatalf("expect %d calls, got %d: %v", n+1, len(f.calls), f.calls)
+	}
+	for _, rs := range f.calls[n] {
+		if rs.Addr.IP.String() == ip {
+			if rs.Weight != weight || rs.Inhibited != inhibited {
+				t.Errorf("call %d: expect %s weight %d inhibited %v, got %+v",
+					n, ip, weight, inhibited, rs)
+			}
+			return
+		}
+	}
+	t.Errorf("call %d: backend %s not found in %v", n, ip, f.calls[n])
+}
+
+func newTestWeightDrain(t *testing.T, dpvs *fakeDpvs, params map[string]string) *WeightDrainAction {
+	vip := &utils.L3L4Addr{IP: net.ParseIP("192.168.100.1"), Port: 80, Proto: utils.IPProtoTCP}
+	act, err := (&WeightDrainAction{update: dpvs.update}).create(vip, params)
+	if err != nil {
+		t.Fatalf("failed to create actioner: %v", err)
+	}
+	return act.(*WeightDrainAction)
+}
+
+// actBackend acts a single backend, which is inhibited if unhealthy.
+func actBackend(t *testing.T, a *WeightDrainAction, ip string, weight uint16, healthy bool) {
+	t.Helper()
+	vs := &comm.VirtualServer{
+		Version: 1,
+		Addr:    utils.L3L4Addr{IP: net.ParseIP("192.168.100.1"), Port: 80, Proto: utils.IPProtoTCP},
+		RSs: []comm.RealServer{{
+			Addr:      utils.L3L4Addr{IP: net.ParseIP(ip), Port: 8080, Proto: utils.IPProtoTCP},
+			Weight:    weight,
+			Inhibited: !healthy,
+		}},
+	}
+	if _, err := a.Act(types.Unknown, time.Second, vs); err != nil {
+		t.Fatalf("act %s: unexpected error: %v", ip, err)
+	}
+}
+
+func TestWeightDrainTwoStages(t *testing.T) {
+	dpvs := &fakeDpvs{}
+	a := newTestWeightDrain(t, dpvs, map[string]string{"drain-timeout": "100ms"})
+
+	actBackend(t, a, "192.168.88.30", 100, true)
+	dpvs.expect(t, 0, "192.168.88.30", 100, false)
+
+	// Stage 1: drained by weight 0, idempotent when the signal repeats.
+	actBackend(t, a, "192.168.88.30", 0, false)
+	dpvs.expect(t, 1, "192.168.88.30", 0, false)
+	time.Sleep(50 * time.Millisecond)
+	actBackend(t, a, "192.168.88.30", 0, false)
+	dpvs.expect(t, 2, "192.168.88.30", 0, false)
+
+	// Stage 2: removed once after drain-timeout since the first signal.
+	time.Sleep(100 * time.Millisecond)
+	dpvs.expect(t, 3, "192.168.88.30", 0, true)
+	time.Sleep(100 * time.Millisecond)
+	dpvs.expect(t, 3, "192.168.88.30", 0, true)
+	actBackend(t, a, "192.168.88.30", 0, false)
+	dpvs.expect(t, 4, "192.168.88.30", 0, true)
+
+	// The pre-drain weight is restored if no weight is given.
+	actBackend(t, a, "192.168.88.30", 0, true)
+	dpvs.expect(t, 5, "192.168.88.30", 100, false)
+}
+
+func TestWeightDrainRecovered(t *testing.T) {
+	dpvs := &fakeDpvs{}
+	a := newTestWeightDrain(t, dpvs, map[string]string{"drain-timeout": "100ms"})
+
+	actBackend(t, a, "192.168.88.30", 100, true)
+	actBackend(t, a, "192.168.88.30", 0, false)
+	actBackend(t, a, "192.168.88.30", 80, true)
+	dpvs.expect(t, 2, "192.168.88.30", 80, false)
+
+	// Recovered within drain-timeout, never removed.
+	time.Sleep(200 * time.Millisecond)
+	dpvs.expect(t, 2, "192.168.88.30", 80, false)
+
+	// Drain forever with zero drain-timeout.
+	dpvs = &fakeDpvs{}
+	a = newTestWeightDrain(t, dpvs, nil)
+	actBackend(t, a, "192.168.88.31", 100, true)
+	actBackend(t, a, "192.168.88.31", 0, false)
+	time.Sleep(100 * time.Millisecond)
+	dpvs.expect(t, 1, "192.168.88.31", 0, false)
+}
+
+func TestWeightDrainRestoreWeight(t *testing.T) {
+	dpvs := &fakeDpvs{}
+	a := newTestWeightDrain(t, dpvs, map[string]string{"restore-weight": "30"})
+
+	// The backend never drained keeps its weight given by the VS.
+	actBackend(t, a, "192.168.88.30", 100, true)
+	dpvs.expect(t, 0, "192.168.88.30", 100, false)
+	actBackend(t, a, "192.168.88.30", 80, true)
+	dpvs.expect(t, 1, "192.168.88.30", 80, false)
+
+	// Set to restore-weight when recovered from drained.
+	actBackend(t, a, "192.168.88.30", 0, false)
+	dpvs.expect(t, 2, "192.168.88.30", 0, false)
+	actBackend(t, a, "192.168.88.30", 100, true)
+	dpvs.expect(t, 3, "192.168.88.30", 30, false)
+	actBackend(t, a, "192.168.88.30", 0, false)
+	actBackend(t, a, "192.168.88.30", 0, true)
+	dpvs.expect(t, 5, "192.168.88.30", 30, false)
+
+	// Set even if the weight given is less than restore-weight, and the later
+	// slow-start ramp weights are kept.
+	actBackend(t, a, "192.168.88.30", 0, false)
+	dpvs.expect(t, 6, "192.168.88.30", 0, false)
+	actBackend(t, a, "192.168.88.30", 10, true)
+	dpvs.expect(t, 7, "192.168.88.30", 30, false)
+	for i, weight := range []uint16{20, 40, 100} {
+		actBackend(t, a, "192.168.88.30", weight, true)
+		dpvs.expect(t, 8+i, "192.168.88.30", weight, false)
+	}
+}
+
+func TestWeightDrainRemoveRetry(t *testing.T) {
+	saved := weightDrainRetryInterval
+	weightDrainRetryInterval = 100 * time.Millisecond
+	defer func() { weightDrainRetryInterval = saved }()
+
+	dpvs := &fakeDpvs{}
+	a := newTestWeightDrain(t, dpvs, map[string]string{"drain-timeout": "50ms"})
+	actBackend(t, a, "192.168.88.30", 100, true)
+	actBackend(t, a, "192.168.88.30", 0, false)
+
+	dpvs.lock.Lock()
+	dpvs.errs = []error{errors.New("dpvs-agent unavailable")}
+	dpvs.lock.Unlock()
+	time.Sleep(80 * time.Millisecond)
+	dpvs.expect(t, 2, "192.168.88.30", 0, true)
+	time.Sleep(100 * time.Millisecond)
+	dpvs.expect(t, 3, "192.168.88.30", 0, true)
+	time.Sleep(150 * time.Millisecond)
+	dpvs.expect(t, 3, "192.168.88.30", 0, true)
+}
+
+func TestWeightDrainDispatchStop(t *testing.T) {
+	dpvs := &fakeDpvs{}
+	a := newTestWeightDrain(t, dpvs, map[string]string{"drain-timeout": "50ms"})
+	var dispatched []string
+	a.SetDispatch(func(item string, timeout time.Duration,
+		job func(timeout time.Duration) (interface{}, error)) (interface{}, error) {
+		dispatched = append(dispatched, item)
+		return job(timeout)
+	})
+
+	// The removal after drain-timeout is run by the dispatch.
+	actBackend(t, a, "192.168.88.30", 100, true)
+	actBackend(t, a, "192.168.88.30", 0, false)
+	time.Sleep(100 * time.Millisecond)
+	dpvs.expect(t, 2, "192.168.88.30", 0, true)
+	if len(dispatched) != 1 || dispatched[0] != "192.168.88.30-TCP-8080" {
+		t.Errorf("expect removal of 192.168.88.30-TCP-8080 dispatched, got %v", dispatched)
+	}
+
+	// No backend is removed after the actioner stopped.
+	actBackend(t, a, "192.168.88.31", 100, true)
+	actBackend(t, a, "192.168.88.31", 0, false)
+	dpvs.expect(t, 4, "192.168.88.31", 0, false)
+	a.Stop()
+	time.Sleep(100 * time.Millisecond)
+	dpvs.expect(t, 4, "192.168.88.31", 0, false)
+	if len(dispatched) != 1 {
+		t.Errorf("unexpected removals dispatched after stopped: %v", dispatched)
+	}
+}
+
+func TestWeightDrainParams(t *testing.T) {
+	a := &WeightDrainAction{}
+	for _, params := range []map[string]string{
+		{"drain-timeout": "-1s"},
+		{"drain-timeout": "1"},
+		{"restore-weight": "0"},
+		{"restore-weight": "65536"},
+		{"unknown": "x"},
+	} {
+		if err := a.validate(params); err == nil {
+			t.Errorf("expect params %v invalid", params)
+		}
+	}
+	if err := a.validate(map[string]string{"drain-timeout": "5m", "restore-weight": "100"}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	vip := &utils.L3L4Addr{IP: net.ParseIP("192.168.100.1"), Port: 80, Proto: utils.IPProtoTCP}
+	if _, err := a.create(vip, nil); err == nil {
+		t.Errorf("expect error without dpvs api server")
+	}
+	if _, err := a.create(vip, nil, "http://127.0.0.1:8082"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,416 @@
+// /*
+// Copyright 2025 IQiYi Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// */
+
+package checker
+
+/*
+ARP Checker Params:
+-------------------------------------------------------------
+name                value
+-------------------------------------------------------------
+ifname              interface to send the request out, required
+expect-mac          MAC address the target must reply with
+-------------------------------------------------------------
+
+The checker verifies L2 reachability of the target, which is required by
+backends in DR mode. It sends an ARP request (IPv4) or an NDP Neighbor
+Solicitation (IPv6) for the target out of `ifname` with an AF_PACKET socket,
+and the target is Healthy only when a reply with a valid MAC arrives in time.
+The kernel neighbor table is left untouched because the exchange bypasses the
+kernel's neighbor subsystem, and the socket is bound to `ifname` so that the
+interfaces sharing a subnet are told apart. It requires CAP_NET_RAW.
+*/
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/types"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/utils"
+)
+
+var _ CheckMethodEx = (*ARPChecker)(nil)
+
+const (
+	ethHeaderLen   = 14
+	ethMinFrameLen = 60 // without FCS
+	ethTypeARP     = 0x0806
+	ethTypeIPv4    = 0x0800
+	ethTypeIPv6    = 0x86dd
+
+	arpPacketLen = 28
+	arpOpRequest = 1
+	arpOpReply   = 2
+
+	ipv6HeaderLen    = 40
+	ndpHopLimit      = 255
+	ndpSolicitLen    = 24 // without options
+	ndpOptSourceLL   = 1
+	ndpOptTargetLL   = 2
+	ICMP6_NEIGH_SOL  = 135
+	ICMP6_NEIGH_ADVT = 136
+)
+
+var ethBroadcast = net.HardwareAddr{0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+
+type ARPChecker struct {
+	ifname    string
+	expectMAC net.HardwareAddr
+}
+
+func init() {
+	registerMethod(CheckMethodARP, &ARPChecker{})
+}
+
+func (c *ARPChecker) Check(target *utils.L3L4Addr, timeout time.Duration) (types.State, error) {
+	return checkWithTimeout(c, target, timeout)
+}
+
+func (c *ARPChecker) CheckContext(ctx context.Context, target *utils.L3L4Addr) (types.State, error) {
+	res, err := c.CheckEx(ctx, target)
+	return res.State, err
+}
+
+func (c *ARPChecker) CheckEx(ctx context.Context, target *utils.L3L4Addr) (*CheckResult, error) {
+	start := time.Now()
+	timeout, err := checkTimeout(ctx, "ARP", start)
+	if err != nil {
+		return checkError(start, err)
+	}
+
+	ifi, err := net.InterfaceByName(c.ifname)
+	if err != nil {
+		return checkError(start, fmt.Errorf("ARP check on %s: %v", c.ifname, err))
+	}
+	if len(ifi.HardwareAddr) != 6 {
+		return checkError(start, fmt.Errorf("ARP check on %s: not an ethernet interface", c.ifname))
+	}
+
+	kind, ip := "ARP", target.IP.String()
+	srcIP := neighSourceIP(ifi, target.IP)
+	var request []byte
+	var ethType uint16
+	if target.IP.To4() != nil {
+		request = encodeARPRequest(ifi.HardwareAddr, srcIP, target.IP)
+		ethType = ethTypeARP
+	} else {
+		kind = "NDP"
+		request = encodeNDPSolicit(ifi.HardwareAddr, srcIP, target.IP)
+		ethType = ethTypeIPv6
+	}
+	glog.V(9).Infof("Start %s check to %v on %s from %v ...", kind, ip, c.ifname, srcIP)
+
+	conn, err := openPacketSocket(ifi.Index, ethType)
+	if err != nil {
+		return checkError(start, fmt.Errorf("%s check on %s: %v", kind, c.ifname, err))
+	}
+	defer conn.Close()
+	defer closeOnCancel(ctx, conn)()
+	conn.SetDeadline(start.Add(timeout))
+
+	if _, err = conn.Write(request); err != nil {
+		return checkFailed(kind, ip, start, errReason(err, false), "failed to send request: %v", err), nil
+	}
+
+	buf := make([]byte, 2048)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return checkFailed(kind, ip, start, errReason(err, false), "no reply: %v", err), nil
+		}
+		var mac net.HardwareAddr
+		var ok bool
+		if ethType == ethTypeARP {
+			mac, ok = parseARPReply(buf[:n], target.IP)
+		} else {
+			mac, ok = parseNDPAdvert(buf[:n], target.IP)
+		}
+		if !ok {
+			continue
+		}
+		if c.expectMAC != nil && !bytes.Equal(mac, c.expectMAC) {
+			return checkFailed(kind, ip, start, ReasonPayloadMismatch,
+				"replied from unexpected MAC %v", mac), nil
+		}
+		glog.V(9).Infof("%s check %v replied from %v", kind, ip, mac)
+		return checkSucceed(kind, ip, start), nil
+	}
+}
+
+func (c *ARPChecker) DefaultParams() map[string]string {
+	return map[string]string{
+		"ifname":     "",
+		"expect-mac": "",
+	}
+}
+
+func (c *ARPChecker) validate(params map[string]string) error {
+	unsupported := make([]string, 0, len(params))
+	for param, val := range params {
+		switch param {
+		case "ifname":
+			if len(val) == 0 {
+				return fmt.Errorf("empty arp checker param: %s", param)
+			}
+		case "expect-mac":
+			if mac, err := net.ParseMAC(val); err != nil || len(mac) != 6 {
+				return fmt.Errorf("invalid arp checker param value: %s:%s", param, val)
+			}
+		default:
+			unsupported = append(unsupported, param)
+		}
+	}
+
+	if len(unsupported) > 0 {
+		return fmt.Errorf("unsupported arp checker params: %q", strings.Join(unsupported, ","))
+	}
+	if _, ok := params["ifname"]; !ok {
+		return fmt.Errorf("missing arp checker param: ifname")
+	}
+	return nil
+}
+
+func (c *ARPChecker) create(params map[string]string) (CheckMethod, error) {
+	if err := c.validate(params); err != nil {
+		return nil, fmt.Errorf("arp checker param validation failed: %v", err)
+	}
+
+	checker := &ARPChecker{
+		ifname: params["ifname"],
+	}
+	if val, ok := params["expect-mac"]; ok {
+		checker.expectMAC, _ = net.ParseMAC(val)
+	}
+	return checker, nil
+}
+
+// neighSourceIP chooses the source address of the neighbor request for `target`
+// from the addresses of `ifi`. The address in the same subnet of the target is
+// preferred, then the IPv6 link-local address, and then any address of the same
+// family. It returns nil if no address is available, and the unspecified source
+// address is used in that case as in a duplicate address detection.
+func neighSourceIP(ifi *net.Interface, target net.IP) net.IP {
+	addrs, err := ifi.Addrs()
+	if err != nil {
+		return nil
+	}
+	isV4 := target.To4() != nil
+	var linkLocal, other net.IP
+	for _, addr := range addrs {
+		ipnet, ok := addr.(*net.IPNet)
+		if !ok || (ipnet.IP.To4() != nil) != isV4 {
+			continue
+		}
+		if ipnet.Contains(target) {
+			return ipnet.IP
+		}
+		if linkLocal == nil && ipnet.IP.IsLinkLocalUnicast() {
+			linkLocal = ipnet.IP
+		}
+		if other == nil {
+			other = ipnet.IP
+		}
+	}
+	if !isV4 && linkLocal != nil {
+		return linkLocal
+	}
+	return other
+}
+
+func htons(v uint16) uint16 {
+	return v<<8 | v>>8
+}
+
+// openPacketSocket opens an AF_PACKET socket of ethernet protocol `ethType`
+// bound to interface `ifindex`.
+func openPacketSocket(ifindex int, ethType uint16) (*os.File, error) {
+	// Open with protocol 0 that receives nothing, so that no frame from other
+	// interfaces is received before the socket is bound.
+	fd, err := syscall.Socket(syscall.AF_PACKET, syscall.SOCK_RAW|syscall.SOCK_CLOEXEC|
+		syscall.SOCK_NONBLOCK, 0)
+	if err != nil {
+		return nil, os.NewSyscallError("socket", err)
+	}
+	sa := &syscall.SockaddrLinklayer{
+		Protocol: htons(ethType),
+		Ifindex:  ifindex,
+	}
+	if err = syscall.Bind(fd, sa); err != nil {
+		syscall.Close(fd)
+		return nil, os.NewSyscallError("bind", err)
+	}
+	// The fd is nonblocking, and thus the file supports deadlines.
+	return os.NewFile(uintptr(fd), "packet"), nil
+}
+
+// validMAC returns true if `mac` is a unicast ethernet address.
+func validMAC(mac net.HardwareAddr) bool {
+	if len(mac) != 6 || mac[0]&0x01 != 0 {
+		return false
+	}
+	return !bytes.Equal(mac, make(net.HardwareAddr, 6))
+}
+
+func encodeEthHeader(frame []byte, dst, src net.HardwareAddr, ethType uint16) {
+	copy(frame[0:6], dst)
+	copy(frame[6:12], src)
+	binary.BigEndian.PutUint16(frame[12:14], ethType)
+}
+
+// encodeARPRequest returns an ethernet frame of broadcast ARP request for
+// `dstIP`. The source IP is zero if `srcIP` is nil, i.e., an ARP probe.
+func encodeARPRequest(srcMAC net.HardwareAddr, srcIP, dstIP net.IP) []byte {
+	frame := make([]byte, ethMinFrameLen)
+	encodeEthHeader(frame, ethBroadcast, srcMAC, ethTypeARP)
+	arp := frame[ethHeaderLen:]
+	binary.BigEndian.PutUint16(arp[0:2], 1) // hardware type: ethernet
+	binary.BigEndian.PutUint16(arp[2:4], ethTypeIPv4)
+	arp[4] = 6 // hardware address length
+	arp[5] = 4 // protocol address length
+	binary.BigEndian.PutUint16(arp[6:8], arpOpRequest)
+	copy(arp[8:14], srcMAC)
+	copy(arp[14:18], srcIP.To4())
+	// target hardware address arp[18:24] is unknown and left zero
+	copy(arp[24:28], dstIP.To4())
+	return frame
+}
+
+// parseARPReply returns the sender MAC if `frame` is an ARP reply from `target`
+// with a valid sender MAC.
+func parseARPReply(frame []byte, target net.IP) (net.HardwareAddr, bool) {
+	if len(frame) < ethHeaderLen+arpPacketLen ||
+		binary.BigEndian.Uint16(frame[12:14]) != ethTypeARP {
+		return nil, false
+	}
+	arp := frame[ethHeaderLen:]
+	if binary.BigEndian.Uint16(arp[0:2]) != 1 || binary.BigEndian.Uint16(arp[2:4]) != ethTypeIPv4 ||
+		arp[4] != 6 || arp[5] != 4 || binary.BigEndian.Uint16(arp[6:8]) != arpOpReply {
+		return nil, false
+	}
+	if !net.IP(arp[14:18]).Equal(target) {
+		return nil, false
+	}
+	mac := append(net.HardwareAddr(nil), arp[8:14]...)
+	if !validMAC(mac) {
+		return nil, false
+	}
+	return mac, true
+}
+
+// solicitedNodeAddr returns the solicited-node multicast address of `ip`.
+func solicitedNodeAddr(ip net.IP) net.IP {
+	addr := net.ParseIP("ff02::1:ff00:0")
+	copy(addr[13:], ip.To16()[13:])
+	return addr
+}
+
+// icmp6Checksum returns the ICMPv6 checksum of `msg` with the IPv6 pseudo header.
+func icmp6Checksum(src, dst net.IP, msg []byte) uint16 {
+	buf := make([]byte, 40+len(msg))
+	copy(buf[0:16], src.To16())
+	copy(buf[16:32], dst.To16())
+	binary.BigEndian.PutUint32(buf[32:36], uint32(len(msg)))
+	buf[39] = syscall.IPPROTO_ICMPV6
+	copy(buf[40:], msg)
+	return icmpChecksum(buf)
+}
+
+// encodeNDPSolicit returns an ethernet frame of multicast Neighbor Solicitation
+// for `target`. The unspecified source address is used if `srcIP` is nil, and
+// the Source Link-Layer Address option is omitted in that case as RFC 4861 says.
+func encodeNDPSolicit(srcMAC net.HardwareAddr, srcIP, target net.IP) []byte {
+	if srcIP == nil {
+		srcIP = net.IPv6unspecified
+	}
+	dstIP := solicitedNodeAddr(target)
+	dstMAC := net.HardwareAddr{0x33, 0x33, dstIP[12], dstIP[13], dstIP[14], dstIP[15]}
+
+	msgLen := ndpSolicitLen
+	if !srcIP.IsUnspecified() {
+		msgLen += 8
+	}
+	frame := make([]byte, ethHeaderLen+ipv6HeaderLen+msgLen)
+	encodeEthHeader(frame, dstMAC, srcMAC, ethTypeIPv6)
+
+	ip6 := frame[ethHeaderLen:]
+	ip6[0] = 0x60 // version 6
+	binary.BigEndian.PutUint16(ip6[4:6], uint16(msgLen))
+	ip6[6] = syscall.IPPROTO_ICMPV6
+	ip6[7] = ndpHopLimit
+	copy(ip6[8:24], srcIP.To16())
+	copy(ip6[24:40], dstIP)
+
+	msg := ip6[ipv6HeaderLen:]
+	msg[0] = ICMP6_NEIGH_SOL
+	copy(msg[8:24], target.To16())
+	if msgLen > ndpSolicitLen {
+		msg[24] = ndpOptSourceLL
+		msg[25] = 1 // in units of 8 octets
+		copy(msg[26:32], srcMAC)
+	}
+	binary.BigEndian.PutUint16(msg[2:4], icmp6Checksum(srcIP, dstIP, msg))
+	return frame
+}
+
+// parseNDPAdvert returns the target MAC if `frame` is a valid Neighbor
+// Advertisement for `target` with a valid MAC.
+func parseNDPAdvert(frame []byte, target net.IP) (net.HardwareAddr, bool) {
+	if len(frame) < ethHeaderLen+ipv6HeaderLen+ndpSolicitLen ||
+		binary.BigEndian.Uint16(frame[12:14]) != ethTypeIPv6 {
+		return nil, false
+	}
+	ip6 := frame[ethHeaderLen:]
+	msgLen := int(binary.BigEndian.Uint16(ip6[4:6]))
+	if ip6[0]>>4 != 6 || ip6[6] != syscall.IPPROTO_ICMPV6 || ip6[7] != ndpHopLimit ||
+		msgLen < ndpSolicitLen || ipv6HeaderLen+msgLen > len(ip6) {
+		return nil, false
+	}
+	msg := ip6[ipv6HeaderLen : ipv6HeaderLen+msgLen]
+	if msg[0] != ICMP6_NEIGH_ADVT || msg[1] != 0 || !net.IP(msg[8:24]).Equal(target) {
+		return nil, false
+	}
+	if icmp6Checksum(net.IP(ip6[8:24]), net.IP(ip6[24:40]), msg) != 0 {
+		return nil, false
+	}
+
+	// Use the Target Link-Layer Address option, or the source MAC of the frame
+	// if the option is absent.
+	mac := net.HardwareAddr(frame[6:12])
+	for opts := msg[ndpSolicitLen:]; len(opts) >= 8; {
+		optLen := int(opts[1]) * 8
+		if optLen == 0 || optLen > len(opts) {
+			return nil, false
+		}
+		if opts[0] == ndpOptTargetLL {
+			mac = net.HardwareAddr(opts[2:8])
+			break
+		}
+		opts = opts[optLen:]
+	}
+	mac = append(net.HardwareAddr(nil), mac...)
+	if !validMAC(mac) {
+		return nil, false
+	}
+	return mac, true
+}
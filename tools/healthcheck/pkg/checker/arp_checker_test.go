@@ -0,0 +1,320 @@
+// /*
+// Copyright 2025 IQiYi Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// */
+
+package checker
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/types"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/utils"
+)
+
+var (
+	arpTestLocalMAC  = net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0x01}
+	arpTestTargetMAC = net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0x02}
+)
+
+// encodeARPReply returns an ARP reply frame from `srcIP` at `srcMAC`.
+func encodeARPReply(srcMAC net.HardwareAddr, srcIP, dstIP net.IP) []byte {
+	frame := encodeARPRequest(srcMAC, srcIP, dstIP)
+	copy(frame[0:6], arpTestLocalMAC)
+	binary.BigEndian.PutUint16(frame[ethHeaderLen+6:ethHeaderLen+8], arpOpReply)
+	copy(frame[ethHeaderLen+18:ethHeaderLen+24], arpTestLocalMAC)
+	return frame
+}
+
+// encodeNDPAdvert returns a Neighbor Advertisement frame for `target`, with the
+// Target Link-Layer Address option of `tllMAC` if not nil.
+func encodeNDPAdvert(srcMAC, tllMAC net.HardwareAddr, target, dstIP net.IP) []byte {
+	msgLen := ndpSolicitLen
+	if tllMAC != nil {
+		msgLen += 8
+	}
+	frame := make([]byte, ethHeaderLen+ipv6HeaderLen+msgLen)
+	encodeEthHeader(frame, arpTestLocalMAC, srcMAC, ethTypeIPv6)
+	ip6 := frame[ethHeaderLen:]
+	ip6[0] = 0x60
+	binary.BigEndian.PutUint16(ip6[4:6], uint16(msgLen))
+	ip6[6] = 58
+	ip6[7] = ndpHopLimit
+	copy(ip6[8:24], target.To16())
+	copy(ip6[24:40], dstIP.To16())
+	msg := ip6[ipv6HeaderLen:]
+	msg[0] = ICMP6_NEIGH_ADVT
+	msg[4] = 0x60 // solicited, override
+	copy(msg[8:24], target.To16())
+	if tllMAC != nil {
+		msg[24] = ndpOptTargetLL
+		msg[25] = 1
+		copy(msg[26:32], tllMAC)
+	}
+	binary.BigEndian.PutUint16(msg[2:4], icmp6Checksum(target, dstIP, msg))
+	return frame
+}
+
+func TestARPPacket(t *testing.T) {
+	local, target := net.ParseIP("192.168.88.1"), net.ParseIP("192.168.88.30")
+
+	req := encodeARPRequest(arpTestLocalMAC, local, target)
+	if len(req) != ethMinFrameLen || !bytes.Equal(req[0:6], ethBroadcast) ||
+		!bytes.Equal(req[6:12], arpTestLocalMAC) || binary.BigEndian.Uint16(req[12:14]) != ethTypeARP {
+		t.Fatalf("bad ethernet header of ARP request: % x", req[:ethHeaderLen])
+	}
+	expect := []byte{
+		0x00, 0x01, 0x08, 0x00, 0x06, 0x04, 0x00, 0x01, // ethernet, IPv4, request
+		0x02, 0x00, 0x00, 0x00, 0x00, 0x01, 192, 168, 88, 1, // sender
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 192, 168, 88, 30, // target
+	}
+	if got := req[ethHeaderLen : ethHeaderLen+arpPacketLen]; !bytes.Equal(got, expect) {
+		t.Errorf("bad ARP request:\n got % x\nwant % x", got, expect)
+	}
+	if probe := encodeARPRequest(arpTestLocalMAC, nil, target); !bytes.Equal(probe[28:32], []byte{0, 0, 0, 0}) {
+		t.Errorf("expected zero sender IP of ARP probe, got %v", net.IP(probe[28:32]))
+	}
+	if _, ok := parseARPReply(req, target); ok {
+		t.Errorf("ARP request parsed as reply")
+	}
+
+	reply := encodeARPReply(arpTestTargetMAC, target, local)
+	if mac, ok := parseARPReply(reply, target); !ok || !bytes.Equal(mac, arpTestTargetMAC) {
+		t.Errorf("expected reply from %v, got %v, %v", arpTestTargetMAC, mac, ok)
+	}
+	if _, ok := parseARPReply(reply, net.ParseIP("192.168.88.31")); ok {
+		t.Errorf("reply from other IP accepted")
+	}
+	if _, ok := parseARPReply(reply[:40], target); ok {
+		t.Errorf("truncated reply accepted")
+	}
+	for _, mac := range []net.HardwareAddr{ethBroadcast, make(net.HardwareAddr, 6),
+		{0x01, 0x00, 0x5e, 0x00, 0x00, 0x01}} {
+		if _, ok := parseARPReply(encodeARPReply(mac, target, local), target); ok {
+			t.Errorf("reply with invalid MAC %v accepted", mac)
+		}
+	}
+}
+
+func TestNDPPacket(t *testing.T) {
+	local, target := net.ParseIP("fe80::1"), net.ParseIP("2001:db8::1234:5678")
+
+	req := encodeNDPSolicit(arpTestLocalMAC, local, target)
+	if len(req) != ethHeaderLen+ipv6HeaderLen+ndpSolicitLen+8 {
+		t.Fatalf("bad NS length %d", len(req))
+	}
+	if expect := (net.HardwareAddr{0x33, 0x33, 0xff, 0x34, 0x56, 0x78}); !bytes.Equal(req[0:6], expect) {
+		t.Errorf("expected destination MAC %v, got %v", expect, net.HardwareAddr(req[0:6]))
+	}
+	ip6 := req[ethHeaderLen:]
+	if !net.IP(ip6[24:40]).Equal(net.ParseIP("ff02::1:ff34:5678")) || ip6[7] != ndpHopLimit ||
+		!net.IP(ip6[8:24]).Equal(local) {
+		t.Errorf("bad IPv6 header of NS: % x", ip6[:ipv6HeaderLen])
+	}
+	msg := ip6[ipv6HeaderLen:]
+	if msg[0] != ICMP6_NEIGH_SOL || !net.IP(msg[8:24]).Equal(target) ||
+		msg[24] != ndpOptSourceLL || !bytes.Equal(msg[26:32], arpTestLocalMAC) {
+		t.Errorf("bad NS message: % x", msg)
+	}
+	if cs := icmp6Checksum(local, net.IP(ip6[24:40]), msg); cs != 0 {
+		t.Errorf("bad NS checksum, verified as %x", cs)
+	}
+	dad := encodeNDPSolicit(arpTestLocalMAC, nil, target)
+	if len(dad) != ethHeaderLen+ipv6HeaderLen+ndpSolicitLen ||
+		!net.IP(dad[ethHeaderLen+8:ethHeaderLen+24]).IsUnspecified() {
+		t.Errorf("expected NS from unspecified address without options, got % x", dad)
+	}
+	if _, ok := parseNDPAdvert(req, target); ok {
+		t.Errorf("NS parsed as NA")
+	}
+
+	advert := encodeNDPAdvert(arpTestTargetMAC, arpTestTargetMAC, target, local)
+	if mac, ok := parseNDPAdvert(advert, target); !ok || !bytes.Equal(mac, arpTestTargetMAC) {
+		t.Errorf("expected NA from %v, got %v, %v", arpTestTargetMAC, mac, ok)
+	}
+	// the source MAC of the frame is used without TLLA option
+	advert = encodeNDPAdvert(arpTestTargetMAC, nil, target, local)
+	if mac, ok := parseNDPAdvert(advert, target); !ok || !bytes.Equal(mac, arpTestTargetMAC) {
+		t.Errorf("expected NA from %v without option, got %v, %v", arpTestTargetMAC, mac, ok)
+	}
+	if _, ok := parseNDPAdvert(advert, local); ok {
+		t.Errorf("NA for other target accepted")
+	}
+	advert[ethHeaderLen+ipv6HeaderLen+8] ^= 0xff // corrupt the target, and thus the checksum
+	if _, ok := parseNDPAdvert(advert, target); ok {
+		t.Errorf("NA with bad checksum accepted")
+	}
+	advert = encodeNDPAdvert(arpTestTargetMAC, ethBroadcast, target, local)
+	if _, ok := parseNDPAdvert(advert, target); ok {
+		t.Errorf("NA with invalid MAC accepted")
+	}
+}
+
+func TestARPCheckerParams(t *testing.T) {
+	for _, params := range []map[string]string{
+		{},
+		{"ifname": ""},
+		{"ifname": "eth0", "expect-mac": "xx:yy"},
+		{"ifname": "eth0", "expect-mac": "00:00:00:00:fe:80:00:00:00:00:00:00:02:00:5e:10:00:00:00:01"},
+		{"ifname": "eth0", "unknown": "x"},
+	} {
+		if _, err := (&ARPChecker{}).create(params); err == nil {
+			t.Errorf("expected error for params %v", params)
+		}
+	}
+	checker, err := (&ARPChecker{}).create(map[string]string{"ifname": "eth0", "expect-mac": "02-00-00-00-00-02"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if c := checker.(*ARPChecker); c.ifname != "eth0" || !bytes.Equal(c.expectMAC, arpTestTargetMAC) {
+		t.Errorf("unexpected checker: %+v", c)
+	}
+
+	res, err := CheckExTimeout(checker, &utils.L3L4Addr{IP: net.ParseIP("192.168.88.30")}, 0)
+	if err == nil || res.State != types.Unknown {
+		t.Errorf("expected error on zero timeout, got %v, %v", res, err)
+	}
+}
+
+// arpTestNet sets up two network namespaces connected to the current one with
+// veth pairs, whose local ends share the same subnet, as follows.
+//
+//	hcarp0 10.250.0.1/24 fd00:250::1/64 <--> hcarp1 10.250.0.2/24 fd00:250::2/64 (netns hcarp-ns1)
+//	hcarp2 10.250.0.4/24 fd00:250::4/64 <--> hcarp3 10.250.0.3/24 fd00:250::3/64 (netns hcarp-ns2)
+func arpTestNet(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("root privilege required")
+	}
+	if _, err := exec.LookPath("ip"); err != nil {
+		t.Skip("iproute2 not found")
+	}
+	run := func(args ...string) error {
+		if out, err := exec.Command("ip", args...).CombinedOutput(); err != nil {
+			return fmt.Errorf("ip %s: %v: %s", strings.Join(args, " "), err, bytes.TrimSpace(out))
+		}
+		return nil
+	}
+	cleanup := func() {
+		exec.Command("ip", "link", "del", "hcarp0").Run()
+		exec.Command("ip", "link", "del", "hcarp2").Run()
+		exec.Command("ip", "netns", "del", "hcarp-ns1").Run()
+		exec.Command("ip", "netns", "del", "hcarp-ns2").Run()
+	}
+	cleanup()
+	t.Cleanup(cleanup)
+
+	for _, link := range []struct {
+		local, localID string
+		peer, peerID   string
+		ns             string
+	}{
+		{"hcarp0", "1", "hcarp1", "2", "hcarp-ns1"},
+		{"hcarp2", "4", "hcarp3", "3", "hcarp-ns2"},
+	} {
+		steps := [][]string{
+			{"netns", "add", link.ns},
+			{"link", "add", link.local, "type", "veth", "peer", "name", link.peer},
+			{"link", "set", link.peer, "netns", link.ns},
+			{"addr", "add", "10.250.0." + link.localID + "/24", "dev", link.local},
+			{"addr", "add", "fd00:250::" + link.localID + "/64", "dev", link.local, "nodad"},
+			{"link", "set", link.local, "up"},
+			{"-n", link.ns, "addr", "add", "10.250.0." + link.peerID + "/24", "dev", link.peer},
+			{"-n", link.ns, "addr", "add", "fd00:250::" + link.peerID + "/64", "dev", link.peer, "nodad"},
+			{"-n", link.ns, "link", "set", link.peer, "up"},
+		}
+		for _, step := range steps {
+			if err := run(step...); err != nil {
+				t.Skipf("failed to set up test network: %v", err)
+			}
+		}
+	}
+}
+
+func peerMAC(t *testing.T, ns, ifname string) net.HardwareAddr {
+	out, err := exec.Command("ip", "-n", ns, "-o", "link", "show", ifname).Output()
+	if err != nil {
+		t.Fatalf("failed to get MAC of %s: %v", ifname, err)
+	}
+	fields := strings.Fields(string(out))
+	for i, field := range fields {
+		if field == "link/ether" && i+1 < len(fields) {
+			mac, err := net.ParseMAC(fields[i+1])
+			if err != nil {
+				t.Fatalf("failed to parse MAC of %s: %v", ifname, err)
+			}
+			return mac
+		}
+	}
+	t.Fatalf("no MAC found for %s: %s", ifname, out)
+	return nil
+}
+
+func TestARPCheckerVeth(t *testing.T) {
+	arpTestNet(t)
+	mac1 := peerMAC(t, "hcarp-ns1", "hcarp1")
+
+	timeout := 500 * time.Millisecond
+	cases := []struct {
+		ifname    string
+		ip        string
+		expectMAC string
+		state     types.State
+		reason    Reason
+	}{
+		{"hcarp0", "10.250.0.2", "", types.Healthy, ReasonNone},
+		{"hcarp0", "10.250.0.2", mac1.String(), types.Healthy, ReasonNone},
+		{"hcarp0", "10.250.0.2", "02:00:00:00:00:02", types.Unhealthy, ReasonPayloadMismatch},
+		{"hcarp0", "10.250.0.3", "", types.Unhealthy, ReasonTimeout},
+		{"hcarp2", "10.250.0.2", "", types.Unhealthy, ReasonTimeout}, // same subnet, wrong segment
+		{"hcarp2", "10.250.0.3", "", types.Healthy, ReasonNone},
+		{"hcarp0", "fd00:250::2", "", types.Healthy, ReasonNone},
+		{"hcarp0", "fd00:250::2", mac1.String(), types.Healthy, ReasonNone},
+		{"hcarp0", "fd00:250::3", "", types.Unhealthy, ReasonTimeout},
+		{"hcarp2", "fd00:250::2", "", types.Unhealthy, ReasonTimeout},
+		{"hcarp2", "fd00:250::3", "", types.Healthy, ReasonNone},
+	}
+	for _, c := range cases {
+		params := map[string]string{"ifname": c.ifname}
+		if len(c.expectMAC) > 0 {
+			params["expect-mac"] = c.expectMAC
+		}
+		checker, err := (&ARPChecker{}).create(params)
+		if err != nil {
+			t.Fatalf("failed to create arp checker: %v", err)
+		}
+		target := &utils.L3L4Addr{IP: net.ParseIP(c.ip)}
+		expectResult(t, c.ifname+" "+c.ip, checker, target, timeout, c.state, c.reason)
+	}
+
+	// The exchanges leave no entry in the local neighbor table.
+	for _, dev := range []string{"hcarp0", "hcarp2"} {
+		out, err := exec.Command("ip", "neigh", "show", "dev", dev).CombinedOutput()
+		if err != nil {
+			t.Fatalf("failed to show neighbors of %s: %v", dev, err)
+		}
+		for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+			if len(line) > 0 && !strings.HasPrefix(line, "fe80:") {
+				t.Errorf("neighbor table of %s polluted: %s", dev, line)
+			}
+		}
+	}
+}
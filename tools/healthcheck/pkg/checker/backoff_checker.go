@@ -0,0 +1,153 @@
+// /*
+// Copyright 2025 IQiYi Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// */
+
+package checker
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/types"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/utils"
+)
+
+var _ CheckMethodEx = (*BackoffChecker)(nil)
+
+type backoffState struct {
+	result CheckResult   // last known result
+	window time.Duration // current backoff window
+	next   time.Time     // probes before the time are skipped
+}
+
+// BackoffChecker wraps a CheckMethod and backs off probes to failing targets
+// exponentially. After a failure, probes arriving within the backoff window are
+// skipped with the last known state returned, and the window doubles on each
+// consecutive failure up to `max`. A successful probe resets the window.
+type BackoffChecker struct {
+	inner CheckMethod
+	base  time.Duration
+	max   time.Duration
+
+	lock    sync.Mutex
+	targets map[string]*backoffState // keyed by L3L4Addr::String()
+
+	now func() time.Time
+}
+
+// NewBackoffChecker returns a BackoffChecker wrapping `inner` with the initial
+// backoff window `base` and the maximum backoff window `max`.
+func NewBackoffChecker(inner CheckMethod, base, max time.Duration) *BackoffChecker {
+	if max < base {
+		max = base
+	}
+	return &BackoffChecker{
+		inner:   inner,
+		base:    base,
+		max:     max,
+		targets: make(map[string]*backoffState),
+		now:     time.Now,
+	}
+}
+
+func (c *BackoffChecker) Check(target *utils.L3L4Addr, timeout time.Duration) (types.State, error) {
+	return checkWithTimeout(c, target, timeout)
+}
+
+func (c *BackoffChecker) CheckContext(ctx context.Context, target *utils.L3L4Addr) (types.State, error) {
+	res, err := c.CheckEx(ctx, target)
+	return res.State, err
+}
+
+func (c *BackoffChecker) CheckEx(ctx context.Context, target *utils.L3L4Addr) (*CheckResult, error) {
+	key := target.String()
+	now := c.now()
+
+	c.lock.Lock()
+	if bs, ok := c.targets[key]; ok && now.Before(bs.next) {
+		res := bs.result
+		c.lock.Unlock()
+		glog.V(9).Infof("Backoff check %v %v: probe skipped, backoff until %v",
+			key, res.State, bs.next.Format(time.StampMilli))
+		res.Latency = 0
+		res.Detail = fmt.Sprintf("probe skipped, backoff until %v, last: %s",
+			bs.next.Format(time.StampMilli), res.Detail)
+		return &res, nil
+	}
+	c.lock.Unlock()
+
+	res, err := CheckEx(ctx, c.inner, target)
+	if err != nil {
+		// The check is not executed actually, keep backoff state unchanged.
+		return res, err
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	switch res.State {
+	case types.Healthy:
+		delete(c.targets, key)
+	case types.Unhealthy:
+		bs, ok := c.targets[key]
+		if !ok {
+			bs = &backoffState{}
+			c.targets[key] = bs
+		}
+		if bs.window == 0 {
+			bs.window = c.base
+		} else if bs.window *= 2; bs.window > c.max {
+			bs.window = c.max
+		}
+		bs.result = *res
+		bs.next = c.now().Add(bs.window)
+		glog.V(9).Infof("Backoff check %v %v: back off %v", key, res.State, bs.window)
+	}
+	return res, nil
+}
+
+// Reset clears the backoff state of `target`.
+func (c *BackoffChecker) Reset(target *utils.L3L4Addr) {
+	c.lock.Lock()
+	delete(c.targets, target.String())
+	c.lock.Unlock()
+}
+
+func (c *BackoffChecker) DefaultParams() map[string]string {
+	if c.inner == nil {
+		return map[string]string{}
+	}
+	return c.inner.DefaultParams()
+}
+
+func (c *BackoffChecker) validate(params map[string]string) error {
+	if c.inner == nil {
+		return fmt.Errorf("backoff checker without inner checker")
+	}
+	return c.inner.validate(params)
+}
+
+func (c *BackoffChecker) create(params map[string]string) (CheckMethod, error) {
+	if err := c.validate(params); err != nil {
+		return nil, fmt.Errorf("backoff checker param validation failed: %v", err)
+	}
+	inner, err := c.inner.create(params)
+	if err != nil {
+		return nil, err
+	}
+	return NewBackoffChecker(inner, c.base, c.max), nil
+}
@@ -0,0 +1,136 @@
+// /*
+// Copyright 2025 IQiYi Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// */
+
+package checker
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/types"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/utils"
+)
+
+// fakeChecker returns states in sequence and counts the probes per target.
+type fakeChecker struct {
+	lock   sync.Mutex
+	states []types.State
+	probes map[string]int
+}
+
+func (c *fakeChecker) Check(target *utils.L3L4Addr, timeout time.Duration) (types.State, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.probes == nil {
+		c.probes = make(map[string]int)
+	}
+	c.probes[target.String()]++
+	state := c.states[0]
+	if len(c.states) > 1 {
+		c.states = c.states[1:]
+	}
+	return state, nil
+}
+
+func (c *fakeChecker) CheckContext(ctx context.Context, target *utils.L3L4Addr) (types.State, error) {
+	deadline, _ := ctx.Deadline()
+	return c.Check(target, time.Until(deadline))
+}
+
+func (c *fakeChecker) validate(params map[string]string) error { return nil }
+
+func (c *fakeChecker) DefaultParams() map[string]string { return map[string]string{"fake": ""} }
+
+func (c *fakeChecker) create(params map[string]string) (CheckMethod, error) {
+	return &fakeChecker{states: c.states}, nil
+}
+
+func TestBackoffChecker(t *testing.T) {
+	target := &utils.L3L4Addr{IP: net.ParseIP("192.168.88.30"), Port: 80, Proto: utils.IPProtoTCP}
+	other := &utils.L3L4Addr{IP: net.ParseIP("192.168.88.31"), Port: 80, Proto: utils.IPProtoTCP}
+	inner := &fakeChecker{states: []types.State{
+		types.Unhealthy, types.Unhealthy, types.Unhealthy, types.Unhealthy, types.Healthy,
+	}}
+
+	now := time.Unix(1700000000, 0)
+	checker := NewBackoffChecker(inner, time.Second, 3*time.Second)
+	checker.now = func() time.Time { return now }
+
+	steps := []struct {
+		advance time.Duration
+		expect  types.State
+		probes  int // accumulated probes executed by the inner checker
+	}{
+		{0, types.Unhealthy, 1},                       // window 1s
+		{500 * time.Millisecond, types.Unhealthy, 1},  // skipped
+		{500 * time.Millisecond, types.Unhealthy, 2},  // window 2s
+		{1500 * time.Millisecond, types.Unhealthy, 2}, // skipped
+		{500 * time.Millisecond, types.Unhealthy, 3},  // window 3s (max)
+		{2 * time.Second, types.Unhealthy, 3},         // skipped
+		{time.Second, types.Unhealthy, 4},             // window 3s (max)
+		{3 * time.Second, types.Healthy, 5},           // reset
+		{0, types.Healthy, 6},
+	}
+	for i, step := range steps {
+		now = now.Add(step.advance)
+		state, err := checker.Check(target, time.Second)
+		if err != nil {
+			t.Fatalf("step %d: unexpected error: %v", i, err)
+		}
+		if state != step.expect {
+			t.Errorf("step %d: expect state %v, got %v", i, step.expect, state)
+		}
+		if probes := inner.probes[target.String()]; probes != step.probes {
+			t.Errorf("step %d: expect %d probes, got %d", i, step.probes, probes)
+		}
+	}
+
+	// backoff state is per target
+	if _, err := checker.Check(other, time.Second); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if probes := inner.probes[other.String()]; probes != 1 {
+		t.Errorf("expect 1 probe on %v, got %d", other, probes)
+	}
+}
+
+func TestBackoffCheckerConcurrency(t *testing.T) {
+	inner := &fakeChecker{states: []types.State{types.Unhealthy}}
+	checker := NewBackoffChecker(inner, time.Hour, time.Hour)
+
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			target := &utils.L3L4Addr{IP: net.IPv4(10, 0, 0, byte(i%4)), Port: 80, Proto: utils.IPProtoTCP}
+			for j := 0; j < 100; j++ {
+				checker.Check(target, time.Second)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	for key, probes := range inner.probes {
+		// concurrent first probes may all pass, but no more after the backoff set
+		if probes > 4 {
+			t.Errorf("target %s probed %d times within backoff window", key, probes)
+		}
+	}
+}
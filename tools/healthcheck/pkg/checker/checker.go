@@ -17,6 +17,7 @@
 package checker
 
 import (
+	"context"
 	"fmt"
 	"sort"
 	"strings"
@@ -38,44 +39,82 @@ var (
 		0x0D, 0x0A, 0x0D, 0x0A, 0x00, 0x0D, 0x0A, 0x51,
 		0x55, 0x49, 0x54, 0x0A, 0x20, 0x00, 0x00, 0x00,
 	}
+
+	// logLimiter bounds the warnings of checkers when targets fail massively,
+	// the details are still available at verbosity utils.LogVerboseSuppressed.
+	logLimiter = utils.NewLogLimiter(utils.DefaultLogBurst, utils.DefaultLogPeriod)
 )
 
 type CheckMethod interface {
 	// Check executes a healthcheck procedure of the method once.
 	// The function MUST return in or immediately after `timeout` time.
+	// It's a thin wrapper of CheckContext with a context of `timeout`.
 	Check(target *utils.L3L4Addr, timeout time.Duration) (types.State, error)
+	// CheckContext executes a healthcheck procedure of the method once.
+	// The function MUST return in or immediately after the deadline of ctx,
+	// and SHOULD return as soon as possible when ctx is canceled.
+	CheckContext(ctx context.Context, target *utils.L3L4Addr) (types.State, error)
 	// create validates the given params, returns an instance of the checker
 	// method, and binds params to it.
 	create(params map[string]string) (CheckMethod, error)
 	// validate checks if the "params" given are valid for creating a checker.
 	validate(params map[string]string) error
+	// DefaultParams returns all params supported by the method with their default
+	// values, and empty value means the param is unset by default.
+	DefaultParams() map[string]string
 }
 
 type Method uint16
 
 const (
-	_                  Method = iota
-	CheckMethodNone           // "1, none"
-	CheckMethodTCP            // "2, tcp"
-	CheckMethodUDP            // "3, udp"
-	CheckMethodPing           // "4, ping"
-	CheckMethodUDPPing        // "5, udpping"
-	CheckMethodHTTP           // "6, http"
+	_                      Method = iota
+	CheckMethodNone               // "1, none"
+	CheckMethodTCP                // "2, tcp"
+	CheckMethodUDP                // "3, udp"
+	CheckMethodPing               // "4, ping"
+	CheckMethodUDPPing            // "5, udpping"
+	CheckMethodHTTP               // "6, http"
+	CheckMethodMySQL              // "7, mysql"
+	CheckMethodGRPC               // "8, grpc"
+	CheckMethodARP                // "9, arp"
+	CheckMethodMemcached          // "10, memcached"
+	CheckMethodComposite          // "11, composite"
+	CheckMethodRemoteAgent        // "12, remote-agent"
+	CheckMethodSNMP               // "13, snmp"
+	CheckMethodWebSocket          // "14, websocket"
+	CheckMethodPlugin             // "15, plugin"
 	// TODO: add new check methods here
 
-	CheckMethodAuto    Method = 10000 // "automatically inferred from protocol"
-	CheckMethodPassive Method = 65535 // "passive", dpvs internal checker, ignore it
+	CheckMethodAuto     Method = 10000 // "automatically inferred from protocol"
+	CheckMethodScripted Method = 10001 // "scripted", fake for tests, see RegisterScriptedChecker
+	CheckMethodPassive  Method = 65535 // "passive", dpvs internal checker, ignore it
 )
 
 var methods map[Method]CheckMethod
 
+// registerMethod registers the check method of `kind` in the init functions
+// of checkers. It panics if `kind` is registered already, so that a checker
+// mistakenly registered twice never overrides another one silently.
 func registerMethod(kind Method, method CheckMethod) {
 	if methods == nil {
 		methods = make(map[Method]CheckMethod)
 	}
+	if _, ok := methods[kind]; ok {
+		panic(fmt.Sprintf("check method %d-%s registered twice", int(kind), kind))
+	}
 	methods[kind] = method
 }
 
+// RegisteredMethods returns a copy of the registered check methods keyed by
+// their kinds. CheckMethodAuto and CheckMethodPassive are not included.
+func RegisteredMethods() map[Method]CheckMethod {
+	res := make(map[Method]CheckMethod, len(methods))
+	for kind, method := range methods {
+		res[kind] = method
+	}
+	return res
+}
+
 func DumpMethods() []string {
 	mtds := make([]int, len(methods)+2)
 	mtds[0] = int(CheckMethodAuto)
@@ -94,6 +133,30 @@ func DumpMethods() []string {
 	return res
 }
 
+// DefaultParams returns the default params of method `kind`. The method of
+// CheckMethodAuto is resolved by TranslateAuto with the given protocol.
+func DefaultParams(kind Method, proto utils.IPProto) (map[string]string, error) {
+	if kind == CheckMethodAuto {
+		kind = kind.TranslateAuto(proto)
+	}
+	method, ok := methods[kind]
+	if !ok {
+		return nil, fmt.Errorf("unsupported checker type: %s", kind)
+	}
+	return method.DefaultParams(), nil
+}
+
+// DumpMethodParams returns the default params of all registered methods.
+func DumpMethodParams() map[Method]map[string]string {
+	res := make(map[Method]map[string]string, len(methods))
+	for kind, method := range methods {
+		res[kind] = method.DefaultParams()
+	}
+	return res
+}
+
+// Validate checks the configs of method `kind`, where the param variables are
+// expanded by utils.SampleParamVars.
 func Validate(kind Method, configs map[string]string) error {
 	if kind == CheckMethodAuto {
 		// auto method always uses default configs
@@ -103,15 +166,33 @@ func Validate(kind Method, configs map[string]string) error {
 	if !ok {
 		return fmt.Errorf("unsupported checker type: %s", kind)
 	}
-	return method.validate(configs)
+	expanded, err := utils.ExpandParams(configs, utils.SampleParamVars)
+	if err != nil {
+		return err
+	}
+	return method.validate(expanded)
 }
 
+// NewChecker creates the checker method of `kind` for `target`, which is not
+// bound to any virtual service. See NewServiceChecker.
 func NewChecker(kind Method, target *utils.L3L4Addr, configs map[string]string) (CheckMethod, error) {
+	return NewServiceChecker(kind, target, nil, configs)
+}
+
+// NewServiceChecker creates the checker method of `kind` for `target` of the
+// virtual service `vip`, where the param variables in `configs` are expanded
+// for them. The variables of a nil `target` or `vip` are unavailable.
+func NewServiceChecker(kind Method, target, vip *utils.L3L4Addr,
+	configs map[string]string) (CheckMethod, error) {
 	method, ok := methods[kind]
 	if !ok {
 		return nil, fmt.Errorf("unsupported checker type %q", kind)
 	}
-	checker, err := method.create(configs)
+	params, err := utils.ExpandParams(configs, &utils.ParamVars{Target: target, VIP: vip})
+	if err != nil {
+		return nil, fmt.Errorf("checker params expansion failed: %v", err)
+	}
+	checker, err := method.create(params)
 	if err != nil {
 		return nil, fmt.Errorf("checker create failed: %v", err)
 	}
@@ -131,8 +212,28 @@ func ParseMethod(name string) Method {
 		return CheckMethodUDPPing
 	case "http":
 		return CheckMethodHTTP
+	case "mysql":
+		return CheckMethodMySQL
+	case "grpc":
+		return CheckMethodGRPC
+	case "arp":
+		return CheckMethodARP
+	case "memcached":
+		return CheckMethodMemcached
+	case "composite":
+		return CheckMethodComposite
+	case "remote-agent":
+		return CheckMethodRemoteAgent
+	case "snmp":
+		return CheckMethodSNMP
+	case "websocket":
+		return CheckMethodWebSocket
+	case "plugin":
+		return CheckMethodPlugin
 	case "none":
 		return CheckMethodNone
+	case "scripted":
+		return CheckMethodScripted
 
 	case "auto":
 		return CheckMethodAuto
@@ -154,10 +255,30 @@ func (m Method) String() string {
 		return "none"
 	case CheckMethodHTTP:
 		return "http"
+	case CheckMethodMySQL:
+		return "mysql"
+	case CheckMethodGRPC:
+		return "grpc"
+	case CheckMethodARP:
+		return "arp"
+	case CheckMethodMemcached:
+		return "memcached"
+	case CheckMethodComposite:
+		return "composite"
+	case CheckMethodRemoteAgent:
+		return "remote-agent"
+	case CheckMethodSNMP:
+		return "snmp"
+	case CheckMethodWebSocket:
+		return "websocket"
+	case CheckMethodPlugin:
+		return "plugin"
 	case CheckMethodPassive:
 		return "passive"
 	case CheckMethodAuto:
 		return "auto"
+	case CheckMethodScripted:
+		return "scripted"
 	default:
 		return fmt.Sprintf("unknown(%d)", m)
 	}
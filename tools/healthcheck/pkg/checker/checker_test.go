@@ -17,11 +17,24 @@
 package checker
 
 import (
+	"bytes"
+	"context"
 	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
 	"os"
+	"os/exec"
+	"reflect"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/golang/glog"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/types"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/utils"
 )
 
 func TestMain(m *testing.M) {
@@ -35,3 +48,370 @@ func TestMain(m *testing.M) {
 	glog.Flush()
 	os.Exit(rc)
 }
+
+func TestDefaultParams(t *testing.T) {
+	all := DumpMethodParams()
+	if len(all) != len(methods) {
+		t.Fatalf("expect default params of %d methods, got %d", len(methods), len(all))
+	}
+	// params required by methods without default values
+	required := map[Method]map[string]string{
+		CheckMethodARP:         {"ifname": "lo"},
+		CheckMethodComposite:   {"a.method": "tcp", "b.method": "none"},
+		CheckMethodRemoteAgent: {"agent": "127.0.0.1:8080"},
+		CheckMethodSNMP:        {"oid": "1.3.6.1.2.1.1.3.0"},
+		CheckMethodPlugin:      {"grpc-plugin": "unix:///var/run/hc-plugin.sock"},
+	}
+	for kind, defaults := range all {
+		// Default params must be accepted by the method itself.
+		params := make(map[string]string)
+		for param, val := range defaults {
+			if len(val) > 0 {
+				params[param] = val
+			}
+		}
+		for param, val := range required[kind] {
+			params[param] = val
+		}
+		if _, err := methods[kind].create(params); err != nil {
+			t.Errorf("%s: default params %v rejected: %v", kind, params, err)
+		}
+		if err := methods[kind].validate(map[string]string{"no-such-param": "x"}); err == nil &&
+			kind != CheckMethodNone {
+			t.Errorf("%s: unsupported param accepted", kind)
+		}
+
+		// The returned map must be a copy.
+		defaults["no-such-param"] = "x"
+		got, err := DefaultParams(kind, utils.IPProtoTCP)
+		if err != nil {
+			t.Errorf("%s: failed to get default params: %v", kind, err)
+		} else if _, ok := got["no-such-param"]; ok {
+			t.Errorf("%s: default params modified by caller", kind)
+		}
+	}
+
+	for proto, kind := range map[utils.IPProto]Method{
+		utils.IPProtoTCP:  CheckMethodTCP,
+		utils.IPProtoUDP:  CheckMethodUDPPing,
+		utils.IPProtoICMP: CheckMethodPing,
+	} {
+		got, err := DefaultParams(CheckMethodAuto, proto)
+		if err != nil || !reflect.DeepEqual(got, methods[kind].DefaultParams()) {
+			t.Errorf("auto(%s): expect default params of %s, got %v, %v", proto, kind, got, err)
+		}
+	}
+	if _, err := DefaultParams(CheckMethodPassive, utils.IPProtoTCP); err == nil {
+		t.Errorf("expect no default params for %s", CheckMethodPassive)
+	}
+
+	params, _ := DefaultParams(CheckMethodUDPPing, utils.IPProtoUDP)
+	if _, ok := params[ParamPrivileged]; !ok {
+		t.Errorf("expect ping params in udpping default params %v", params)
+	}
+	if _, ok := params["send"]; !ok {
+		t.Errorf("expect udp params in udpping default params %v", params)
+	}
+}
+
+func TestRegisteredMethods(t *testing.T) {
+	registered := RegisteredMethods()
+	for kind := CheckMethodNone; kind <= CheckMethodPlugin; kind++ {
+		if _, ok := registered[kind]; !ok {
+			t.Errorf("check method %d-%s not registered", int(kind), kind)
+		}
+		delete(registered, kind)
+	}
+	if len(registered) > 0 {
+		t.Errorf("unexpected check methods registered: %v", registered)
+	}
+
+	// The returned map must be a copy.
+	RegisteredMethods()[CheckMethodAuto] = &NoneChecker{}
+	if _, ok := RegisteredMethods()[CheckMethodAuto]; ok {
+		t.Errorf("registered methods modified by caller")
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("expect panic with duplicate check method")
+			}
+		}()
+		registerMethod(CheckMethodTCP, &NoneChecker{})
+	}()
+	if _, ok := methods[CheckMethodTCP].(*TCPChecker); !ok {
+		t.Errorf("check method tcp overridden: %+v", methods[CheckMethodTCP])
+	}
+
+	// The scripted checker is replaced when registered again.
+	c1, c2 := NewScriptedChecker([]types.State{types.Healthy}), NewScriptedChecker([]types.State{types.Unhealthy})
+	RegisterScriptedChecker(c1)
+	RegisterScriptedChecker(c2)
+	defer delete(methods, CheckMethodScripted)
+	if RegisteredMethods()[CheckMethodScripted] != c2 {
+		t.Errorf("scripted check method not replaced")
+	}
+}
+
+func TestCheckContextCancel(t *testing.T) {
+	mute := startTCPServer(t, func(conn *net.TCPConn) { io.Copy(io.Discard, conn) })
+	blackhole := blackholeTCPPort(t)
+	silent, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer silent.Close()
+	udpTarget := &utils.L3L4Addr{IP: net.ParseIP("127.0.0.1"),
+		Port: uint16(silent.LocalAddr().(*net.UDPAddr).Port), Proto: utils.IPProtoUDP}
+
+	create := func(kind Method, params map[string]string) CheckMethod {
+		method, err := methods[kind].create(params)
+		if err != nil {
+			t.Fatalf("failed to create %s checker: %v", kind, err)
+		}
+		return method
+	}
+	for _, tc := range []struct {
+		name   string
+		method CheckMethod
+		target *utils.L3L4Addr
+	}{
+		{"tcp dialing", create(CheckMethodTCP, nil), blackhole},
+		{"tcp reading", create(CheckMethodTCP, map[string]string{"send": "ping", "receive": "pong"}), mute},
+		{"udp reading", create(CheckMethodUDP, map[string]string{"send": "ping", "receive": "pong"}), udpTarget},
+		{"http", create(CheckMethodHTTP, nil), mute},
+		{"mysql", create(CheckMethodMySQL, nil), mute},
+		{"grpc", create(CheckMethodGRPC, nil), mute},
+		{"memcached", create(CheckMethodMemcached, nil), mute},
+	} {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		time.AfterFunc(100*time.Millisecond, cancel)
+		start := time.Now()
+		state, _ := tc.method.CheckContext(ctx, tc.target)
+		if elapsed := time.Since(start); elapsed > 2*time.Second {
+			t.Errorf("%s: check not canceled in time, returned %v after %v", tc.name, state, elapsed)
+		}
+		cancel()
+	}
+
+	// Check is a wrapper of CheckContext with timeout.
+	start := time.Now()
+	if state, err := create(CheckMethodTCP, nil).Check(blackhole, 200*time.Millisecond); err != nil ||
+		state != types.Unhealthy {
+		t.Errorf("expect %v on timeout, got %v, %v", types.Unhealthy, state, err)
+	} else if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("check timeout too late: %v", elapsed)
+	}
+	if _, err := create(CheckMethodTCP, nil).CheckContext(context.Background(), mute); err == nil {
+		t.Errorf("expect error for context without deadline")
+	}
+}
+
+// linkLocalTestNet sets up two network namespaces connected to the current one
+// with veth pairs, whose ends have IPv6 link-local addresses only, as follows.
+//
+//	hcll0 fe80::250:1/64 <--> hcll1 fe80::250:2/64 (netns hcll-ns1)
+//	hcll2 fe80::250:4/64 <--> hcll3 fe80::250:3/64 (netns hcll-ns2)
+func linkLocalTestNet(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("root privilege required")
+	}
+	if _, err := exec.LookPath("ip"); err != nil {
+		t.Skip("iproute2 not found")
+	}
+	run := func(args ...string) error {
+		if out, err := exec.Command("ip", args...).CombinedOutput(); err != nil {
+			return fmt.Errorf("ip %s: %v: %s", strings.Join(args, " "), err, bytes.TrimSpace(out))
+		}
+		return nil
+	}
+	cleanup := func() {
+		exec.Command("ip", "link", "del", "hcll0").Run()
+		exec.Command("ip", "link", "del", "hcll2").Run()
+		exec.Command("ip", "netns", "del", "hcll-ns1").Run()
+		exec.Command("ip", "netns", "del", "hcll-ns2").Run()
+	}
+	cleanup()
+	t.Cleanup(cleanup)
+
+	for _, link := range []struct {
+		local, localID string
+		peer, peerID   string
+		ns             string
+	}{
+		{"hcll0", "1", "hcll1", "2", "hcll-ns1"},
+		{"hcll2", "4", "hcll3", "3", "hcll-ns2"},
+	} {
+		steps := [][]string{
+			{"netns", "add", link.ns},
+			{"link", "add", link.local, "type", "veth", "peer", "name", link.peer},
+			{"link", "set", link.peer, "netns", link.ns},
+			{"addr", "add", "fe80::250:" + link.localID + "/64", "dev", link.local, "nodad"},
+			{"link", "set", link.local, "up"},
+			{"-n", link.ns, "addr", "add", "fe80::250:" + link.peerID + "/64", "dev", link.peer, "nodad"},
+			{"-n", link.ns, "link", "set", link.peer, "up"},
+		}
+		for _, step := range steps {
+			if err := run(step...); err != nil {
+				t.Skipf("failed to set up test network: %v", err)
+			}
+		}
+	}
+}
+
+func TestLinkLocalTargets(t *testing.T) {
+	linkLocalTestNet(t)
+
+	local := &utils.L3L4Addr{IP: net.ParseIP("fe80::250:1"), Zone: "hcll0"}
+	ln, err := net.Listen("tcp6", local.Addr())
+	if err != nil {
+		t.Fatalf("failed to listen on %s: %v", local.Addr(), err)
+	}
+	defer ln.Close()
+	go http.Serve(ln, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "pong")
+	}))
+	tcpTarget := local.DeepCopy()
+	tcpTarget.Port, tcpTarget.Proto = uint16(ln.Addr().(*net.TCPAddr).Port), utils.IPProtoTCP
+
+	pc, err := net.ListenPacket("udp6", local.Addr())
+	if err != nil {
+		t.Fatalf("failed to listen on %s: %v", local.Addr(), err)
+	}
+	defer pc.Close()
+	go func() {
+		buf := make([]byte, 64)
+		for {
+			n, from, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			pc.WriteTo(buf[:n], from)
+		}
+	}()
+	udpTarget := local.DeepCopy()
+	udpTarget.Port, udpTarget.Proto = uint16(pc.LocalAddr().(*net.UDPAddr).Port), utils.IPProtoUDP
+
+	create := func(kind Method, params map[string]string) CheckMethod {
+		method, err := methods[kind].create(params)
+		if err != nil {
+			t.Fatalf("failed to create %s checker: %v", kind, err)
+		}
+		return method
+	}
+	peer := func(ip, zone string) *utils.L3L4Addr {
+		return &utils.L3L4Addr{IP: net.ParseIP(ip), Zone: zone}
+	}
+	echo := map[string]string{"send": "ping", "receive": "ping"}
+	timeout := 500 * time.Millisecond
+	for _, tc := range []struct {
+		name   string
+		method CheckMethod
+		target *utils.L3L4Addr
+		state  types.State
+		reason Reason
+	}{
+		{"tcp", create(CheckMethodTCP, nil), tcpTarget, types.Healthy, ReasonNone},
+		{"http", create(CheckMethodHTTP, map[string]string{"response": "pong"}), tcpTarget,
+			types.Healthy, ReasonNone},
+		{"udp", create(CheckMethodUDP, echo), udpTarget, types.Healthy, ReasonNone},
+		{"udpping", create(CheckMethodUDPPing, echo), udpTarget, types.Healthy, ReasonNone},
+		{"ping local", create(CheckMethodPing, nil), local, types.Healthy, ReasonNone},
+		{"ping peer", create(CheckMethodPing, nil), peer("fe80::250:2", "hcll0"), types.Healthy, ReasonNone},
+		{"ping peer by index", create(CheckMethodPing, nil), peer("fe80::250:3",
+			strconv.Itoa(linkIndex(t, "hcll2"))), types.Healthy, ReasonNone},
+		{"ping peer on wrong link", create(CheckMethodPing, nil), peer("fe80::250:2", "hcll2"),
+			types.Unhealthy, ReasonTimeout},
+	} {
+		expectResult(t, tc.name+" "+tc.target.String(), tc.method, tc.target, timeout, tc.state, tc.reason)
+	}
+
+	res, err := CheckExTimeout(create(CheckMethodPing, nil), peer("fe80::250:2", "nonexist"), timeout)
+	if err != nil || res.State != types.Unhealthy {
+		t.Errorf("expect %v on invalid zone, got %v, %v", types.Unhealthy, res, err)
+	}
+}
+
+func linkIndex(t *testing.T, ifname string) int {
+	ifi, err := net.InterfaceByName(ifname)
+	if err != nil {
+		t.Fatalf("failed to get interface %s: %v", ifname, err)
+	}
+	return ifi.Index
+}
+
+func TestCheckerParamVars(t *testing.T) {
+	vip := &utils.L3L4Addr{IP: net.ParseIP("10.0.0.1"), Port: 80, Proto: utils.IPProtoTCP}
+	rs1 := &utils.L3L4Addr{IP: net.ParseIP("192.168.88.10"), Port: 8080, Proto: utils.IPProtoTCP}
+	rs2 := &utils.L3L4Addr{IP: net.ParseIP("2001:db8::10"), Port: 8081, Proto: utils.IPProtoTCP}
+
+	// The params are shared by the targets of a checker config.
+	params := map[string]string{"host": "{ip}.backend.internal", "uri": "http://{addr}/?vip={vip}:{vport}"}
+	c1, err := NewServiceChecker(CheckMethodHTTP, rs1, vip, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c2, err := NewServiceChecker(CheckMethodHTTP, rs2, vip, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		checker   *HTTPChecker
+		host, uri string
+	}{
+		{c1.(*HTTPChecker), "192.168.88.10.backend.internal", "http://192.168.88.10:8080/?vip=10.0.0.1:80"},
+		{c2.(*HTTPChecker), "2001:db8::10.backend.internal", "http://[2001:db8::10]:8081/?vip=10.0.0.1:80"},
+	} {
+		if tc.checker.host != tc.host || tc.checker.uri != tc.uri {
+			t.Errorf("expect host %q uri %q, got %q %q", tc.host, tc.uri, tc.checker.host, tc.checker.uri)
+		}
+	}
+	if params["host"] != "{ip}.backend.internal" {
+		t.Errorf("shared params modified: %v", params)
+	}
+
+	// Creating a checker must change neither the registered method nor the
+	// checkers created before.
+	udpParams := map[string]string{"send": "ping {port}", "receive": "pong"}
+	u1, err := NewChecker(CheckMethodUDP, rs1, udpParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	udpParams["send"], udpParams["receive"] = "hello {ip}", "world"
+	if _, err := NewChecker(CheckMethodUDP, rs2, udpParams); err != nil {
+		t.Fatal(err)
+	}
+	ex := u1.(*UDPChecker).exchanges
+	if len(ex) != 1 || string(ex[0].send.expand(nil)) != "ping 8080" || string(ex[0].receive) != "pong" {
+		t.Errorf("udp checker changed by another one: %+v", ex)
+	}
+	if !reflect.DeepEqual(methods[CheckMethodUDP], &UDPChecker{}) {
+		t.Errorf("registered udp method changed: %+v", methods[CheckMethodUDP])
+	}
+
+	// The variables of a nil target or vip are unavailable.
+	if _, err := NewChecker(CheckMethodHTTP, rs1, params); err == nil {
+		t.Errorf("expect error of {vip} without virtual service")
+	}
+	if _, err := NewServiceChecker(CheckMethodHTTP, nil, vip, params); err == nil {
+		t.Errorf("expect error of {ip} without target")
+	}
+
+	if err := Validate(CheckMethodHTTP, params); err != nil {
+		t.Errorf("unexpected validation error: %v", err)
+	}
+	for _, params := range []map[string]string{
+		{"host": "{hostname}.backend.internal"},
+		{"a.method": "tcp", "b.method": "http", "b.host": "{rs}"},
+		{"status": "{vport}-{ip}"}, // validated after expanded
+	} {
+		kind := CheckMethodHTTP
+		if _, ok := params["a.method"]; ok {
+			kind = CheckMethodComposite
+		}
+		if err := Validate(kind, params); err == nil {
+			t.Errorf("expect %v invalid", params)
+		}
+	}
+}
@@ -0,0 +1,357 @@
+// /*
+// Copyright 2025 IQiYi Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// */
+
+package checker
+
+/*
+Composite Checker Params:
+-------------------------------------------------------------
+name                value
+-------------------------------------------------------------
+policy              and(default) | or | primary-fallback
+timeout-split       share of the timeout for child a in primary-fallback, (0, 1), default 0.5
+a.method            method name of child a, required
+b.method            method name of child b, required
+a.PARAM, b.PARAM    param PARAM of child a and b respectively
+prxoy-protocol      v1 | v2, derived from dpvs, passed to children supporting it
+quic                yes | no | true | false, derived from dpvs, passed to children supporting it
+-------------------------------------------------------------
+
+The checker combines the verdicts of two child methods on the same target, for
+example, a direct probe from the local node and a "remote-agent" query for the
+view of a partner node. A child resulting in Unknown abstains, and the other
+child decides alone. The result is Unknown only if both children abstain.
+
+  and               Unhealthy if any child is Unhealthy, otherwise Healthy.
+  or                Healthy if any child is Healthy, otherwise Unhealthy.
+  primary-fallback  child a decides, and child b is checked only if a abstains.
+
+The children of "and" and "or" are checked concurrently within the whole
+timeout, and the one still in progress is canceled once the result is
+determined. The children of "primary-fallback" are checked in order, child a is
+given timeout-split of the timeout, and child b the rest.
+
+Auto, passive and composite methods are not allowed as a child.
+*/
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/types"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/utils"
+)
+
+var _ CheckMethodEx = (*CompositeChecker)(nil)
+
+const (
+	CompositePolicyAnd             = "and"
+	CompositePolicyOr              = "or"
+	CompositePolicyPrimaryFallback = "primary-fallback"
+)
+
+const compositeDefaultTimeoutSplit = 0.5
+
+// compositeChildPrefixes are the param namespaces of the children in order.
+var compositeChildPrefixes = [2]string{"a.", "b."}
+
+type CompositeChecker struct {
+	policy   string
+	split    float64
+	children [2]CheckMethod
+}
+
+type compositeChildResult struct {
+	name string
+	res  *CheckResult
+	err  error
+}
+
+func (r *compositeChildResult) String() string {
+	if r.err != nil {
+		return fmt.Sprintf("%s: %v", r.name, r.err)
+	}
+	if len(r.res.Detail) == 0 {
+		return fmt.Sprintf("%s: %v", r.name, r.res.State)
+	}
+	return fmt.Sprintf("%s: %v, %s", r.name, r.res.State, r.res.Detail)
+}
+
+func init() {
+	registerMethod(CheckMethodComposite, &CompositeChecker{})
+}
+
+func (c *CompositeChecker) Check(target *utils.L3L4Addr, timeout time.Duration) (types.State, error) {
+	return checkWithTimeout(c, target, timeout)
+}
+
+func (c *CompositeChecker) CheckContext(ctx context.Context, target *utils.L3L4Addr) (types.State, error) {
+	res, err := c.CheckEx(ctx, target)
+	return res.State, err
+}
+
+func (c *CompositeChecker) CheckEx(ctx context.Context, target *utils.L3L4Addr) (*CheckResult, error) {
+	start := time.Now()
+	timeout, err := checkTimeout(ctx, "Composite", start)
+	if err != nil {
+		return checkError(start, err)
+	}
+
+	var results []*compositeChildResult
+	if c.policy == CompositePolicyPrimaryFallback {
+		results = c.checkInOrder(ctx, target, timeout)
+	} else {
+		results = c.checkConcurrently(ctx, target)
+	}
+	return c.combine(target, start, results)
+}
+
+// checkInOrder checks child a within its share of `timeout`, and then child b
+// within the rest if a abstains.
+func (c *CompositeChecker) checkInOrder(ctx context.Context, target *utils.L3L4Addr,
+	timeout time.Duration) []*compositeChildResult {
+	actx, cancel := context.WithTimeout(ctx, time.Duration(float64(timeout)*c.split))
+	res, err := CheckEx(actx, c.children[0], target)
+	cancel()
+	results := []*compositeChildResult{{name: "a", res: res, err: err}}
+	if res.State != types.Unknown {
+		return results
+	}
+	glog.V(9).Infof("Composite check %v: child a abstains, fall back to child b", target)
+	res, err = CheckEx(ctx, c.children[1], target)
+	return append(results, &compositeChildResult{name: "b", res: res, err: err})
+}
+
+// checkConcurrently checks both children concurrently, and returns as soon as
+// the result is determined with the other child canceled.
+func (c *CompositeChecker) checkConcurrently(ctx context.Context,
+	target *utils.L3L4Addr) []*compositeChildResult {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	decisive := types.Unhealthy
+	if c.policy == CompositePolicyOr {
+		decisive = types.Healthy
+	}
+
+	// Buffered for all children so that the late one never blocks.
+	ch := make(chan *compositeChildResult, len(c.children))
+	for i, child := range c.children {
+		go func(name string, child CheckMethod) {
+			res, err := CheckEx(ctx, child, target)
+			ch <- &compositeChildResult{name: name, res: res, err: err}
+		}(strings.TrimSuffix(compositeChildPrefixes[i], "."), child)
+	}
+
+	results := make([]*compositeChildResult, 0, len(c.children))
+	for range c.children {
+		r := <-ch
+		results = append(results, r)
+		if r.res.State == decisive {
+			break
+		}
+	}
+	// Keep the results in order of children to combine deterministically.
+	if len(results) > 1 && results[0].name > results[1].name {
+		results[0], results[1] = results[1], results[0]
+	}
+	return results
+}
+
+// combine determines the result from the results of children by the policy.
+func (c *CompositeChecker) combine(target *utils.L3L4Addr, start time.Time,
+	results []*compositeChildResult) (*CheckResult, error) {
+	addr := target.String()
+	details := make([]string, 0, len(results))
+	var healthy, unhealthy *compositeChildResult
+	for _, r := range results {
+		details = append(details, r.String())
+		switch r.res.State {
+		case types.Healthy:
+			if healthy == nil {
+				healthy = r
+			}
+		case types.Unhealthy:
+			if unhealthy == nil {
+				unhealthy = r
+			}
+		}
+	}
+
+	var failed *compositeChildResult
+	switch c.policy {
+	case CompositePolicyAnd:
+		failed = unhealthy
+		if failed == nil && healthy != nil {
+			return checkSucceed("Composite", addr, start), nil
+		}
+	case CompositePolicyOr:
+		if healthy != nil {
+			return checkSucceed("Composite", addr, start), nil
+		}
+		failed = unhealthy
+	case CompositePolicyPrimaryFallback:
+		// Only the last child checked concerns.
+		last := results[len(results)-1]
+		if last.res.State == types.Healthy {
+			return checkSucceed("Composite", addr, start), nil
+		}
+		if last.res.State == types.Unhealthy {
+			failed = last
+		}
+	}
+
+	if failed == nil {
+		// All children abstain.
+		return checkError(start, fmt.Errorf("%s", strings.Join(details, "; ")))
+	}
+	return checkFailed("Composite", addr, start, failed.res.Reason, "%s", strings.Join(details, "; ")), nil
+}
+
+// compositeChildParams returns the method and params of the child of param
+// namespace `prefix`. The params derived from dpvs are passed to the child if
+// supported and not given explicitly.
+func compositeChildParams(prefix string, params map[string]string) (Method, map[string]string, error) {
+	name, ok := params[prefix+"method"]
+	if !ok {
+		return 0, nil, fmt.Errorf("composite checker param %smethod required", prefix)
+	}
+	kind := ParseMethod(name)
+	switch kind {
+	case 0, CheckMethodAuto, CheckMethodPassive, CheckMethodComposite:
+		return 0, nil, fmt.Errorf("invalid composite checker param value: %smethod:%s", prefix, name)
+	}
+	method, ok := methods[kind]
+	if !ok {
+		return 0, nil, fmt.Errorf("invalid composite checker param value: %smethod:%s", prefix, name)
+	}
+
+	child := make(map[string]string)
+	for param, val := range params {
+		if strings.HasPrefix(param, prefix) && param != prefix+"method" {
+			child[strings.TrimPrefix(param, prefix)] = val
+		}
+	}
+	defaults := method.DefaultParams()
+	for _, param := range []string{ParamProxyProto, ParamQuic} {
+		val, ok := params[param]
+		if !ok {
+			continue
+		}
+		if _, ok := child[param]; ok {
+			continue
+		}
+		if _, ok := defaults[param]; ok {
+			child[param] = val
+		}
+	}
+
+	if err := method.validate(child); err != nil {
+		return 0, nil, fmt.Errorf("composite checker child %s%s: %v", prefix, kind, err)
+	}
+	return kind, child, nil
+}
+
+// CompositeChildren returns the methods and params of the children of the
+// composite checker with `params` in order.
+func CompositeChildren(params map[string]string) ([]Method, []map[string]string, error) {
+	kinds := make([]Method, 0, len(compositeChildPrefixes))
+	children := make([]map[string]string, 0, len(compositeChildPrefixes))
+	for _, prefix := range compositeChildPrefixes {
+		kind, child, err := compositeChildParams(prefix, params)
+		if err != nil {
+			return nil, nil, err
+		}
+		kinds = append(kinds, kind)
+		children = append(children, child)
+	}
+	return kinds, children, nil
+}
+
+func (c *CompositeChecker) DefaultParams() map[string]string {
+	return map[string]string{
+		"policy":        CompositePolicyAnd,
+		"timeout-split": strconv.FormatFloat(compositeDefaultTimeoutSplit, 'f', -1, 64),
+		"a.method":      "",
+		"b.method":      "",
+	}
+}
+
+func (c *CompositeChecker) validate(params map[string]string) error {
+	unsupported := make([]string, 0, len(params))
+	for param, val := range params {
+		switch {
+		case param == "policy":
+			switch val {
+			case CompositePolicyAnd, CompositePolicyOr, CompositePolicyPrimaryFallback:
+			default:
+				return fmt.Errorf("invalid composite checker param value: %s:%s", param, val)
+			}
+		case param == "timeout-split":
+			split, err := strconv.ParseFloat(val, 64)
+			if err != nil || split <= 0 || split >= 1 {
+				return fmt.Errorf("invalid composite checker param value: %s:%s", param, val)
+			}
+		case param == ParamProxyProto, param == ParamQuic:
+			// Derived from dpvs, and validated by the children supporting it.
+		case strings.HasPrefix(param, compositeChildPrefixes[0]),
+			strings.HasPrefix(param, compositeChildPrefixes[1]):
+			// Validated by the children.
+		default:
+			unsupported = append(unsupported, param)
+		}
+	}
+
+	if len(unsupported) > 0 {
+		return fmt.Errorf("unsupported composite checker params: %q", strings.Join(unsupported, ","))
+	}
+	for _, prefix := range compositeChildPrefixes {
+		if _, _, err := compositeChildParams(prefix, params); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *CompositeChecker) create(params map[string]string) (CheckMethod, error) {
+	if err := c.validate(params); err != nil {
+		return nil, fmt.Errorf("composite checker param validation failed: %v", err)
+	}
+
+	checker := &CompositeChecker{
+		policy: CompositePolicyAnd,
+		split:  compositeDefaultTimeoutSplit,
+	}
+	if val, ok := params["policy"]; ok {
+		checker.policy = val
+	}
+	if val, ok := params["timeout-split"]; ok {
+		checker.split, _ = strconv.ParseFloat(val, 64)
+	}
+	for i, prefix := range compositeChildPrefixes {
+		kind, child, _ := compositeChildParams(prefix, params)
+		method, err := NewChecker(kind, nil, child)
+		if err != nil {
+			return nil, fmt.Errorf("composite checker child %s%s: %v", prefix, kind, err)
+		}
+		checker.children[i] = method
+	}
+	return checker, nil
+}
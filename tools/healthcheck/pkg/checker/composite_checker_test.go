@@ -0,0 +1,171 @@
+// /*
+// Copyright 2025 IQiYi Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// */
+
+package checker
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/types"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/utils"
+)
+
+func newCompositeChecker(t *testing.T, params map[string]string) *CompositeChecker {
+	t.Helper()
+	c, err := (&CompositeChecker{}).create(params)
+	if err != nil {
+		t.Fatalf("failed to create composite checker with %v: %v", params, err)
+	}
+	return c.(*CompositeChecker)
+}
+
+func TestCompositeCheckerPolicy(t *testing.T) {
+	RegisterScriptedChecker(NewScriptedChecker(nil))
+	defer delete(methods, CheckMethodScripted)
+
+	target := &utils.L3L4Addr{IP: net.ParseIP("192.168.88.30"), Port: 80, Proto: utils.IPProtoTCP}
+	H, U, X := types.Healthy, types.Unhealthy, types.Unknown
+	names := map[types.State]string{H: "up", U: "down", X: "unknown"}
+
+	// expected results indexed by states of child a and b
+	expects := map[string]map[[2]types.State]types.State{
+		CompositePolicyAnd: {
+			{H, H}: H, {H, U}: U, {H, X}: H,
+			{U, H}: U, {U, U}: U, {U, X}: U,
+			{X, H}: H, {X, U}: U, {X, X}: X,
+		},
+		CompositePolicyOr: {
+			{H, H}: H, {H, U}: H, {H, X}: H,
+			{U, H}: H, {U, U}: U, {U, X}: U,
+			{X, H}: H, {X, U}: U, {X, X}: X,
+		},
+		CompositePolicyPrimaryFallback: {
+			{H, H}: H, {H, U}: H, {H, X}: H,
+			{U, H}: U, {U, U}: U, {U, X}: U,
+			{X, H}: H, {X, U}: U, {X, X}: X,
+		},
+	}
+	for policy, cases := range expects {
+		for states, expect := range cases {
+			c := newCompositeChecker(t, map[string]string{
+				"policy":   policy,
+				"a.method": "scripted",
+				"a.states": names[states[0]],
+				"b.method": "scripted",
+				"b.states": names[states[1]],
+			})
+			res, err := CheckExTimeout(c, target, time.Second)
+			if res.State != expect || (err != nil) != (expect == X) {
+				t.Errorf("%s %v: expect %v, got %v, %v", policy, states, expect, res.State, err)
+			}
+			if expect == U && (res.Reason != ReasonUnknown || !strings.Contains(res.Detail, "scripted failure")) {
+				t.Errorf("%s %v: unexpected failure %v: %s", policy, states, res.Reason, res.Detail)
+			}
+			if policy == CompositePolicyPrimaryFallback {
+				checks := c.children[1].(*ScriptedChecker).Checks()
+				if fallback := states[0] == X; (checks == 1) != fallback {
+					t.Errorf("%s %v: child b checked %d times", policy, states, checks)
+				}
+			}
+		}
+	}
+}
+
+func TestCompositeCheckerTimeout(t *testing.T) {
+	RegisterScriptedChecker(NewScriptedChecker(nil))
+	defer delete(methods, CheckMethodScripted)
+
+	blackhole := blackholeTCPPort(t)
+	timeout := time.Second
+	cases := []struct {
+		name    string
+		params  map[string]string
+		state   types.State
+		reason  Reason
+		elapsed time.Duration // upper bound of the time elapsed
+	}{
+		{"and, a timeout, b up", map[string]string{"policy": "and", "a.method": "tcp",
+			"b.method": "scripted", "b.states": "up"}, types.Unhealthy, ReasonDialTimeout, 2 * timeout},
+		{"and, a timeout, b down", map[string]string{"policy": "and", "a.method": "tcp",
+			"b.method": "scripted", "b.states": "down"}, types.Unhealthy, ReasonUnknown, timeout / 2},
+		{"or, a timeout, b up", map[string]string{"policy": "or", "a.method": "tcp",
+			"b.method": "scripted", "b.states": "up"}, types.Healthy, ReasonNone, timeout / 2},
+		{"or, a timeout, b down", map[string]string{"policy": "or", "a.method": "tcp",
+			"b.method": "scripted", "b.states": "down"}, types.Unhealthy, ReasonDialTimeout, 2 * timeout},
+		{"primary-fallback, a timeout", map[string]string{"policy": "primary-fallback",
+			"timeout-split": "0.25", "a.method": "tcp", "b.method": "scripted", "b.states": "up"},
+			types.Unhealthy, ReasonDialTimeout, timeout / 2},
+		{"primary-fallback, a unknown, b timeout", map[string]string{"policy": "primary-fallback",
+			"timeout-split": "0.25", "a.method": "scripted", "a.states": "unknown", "b.method": "tcp"},
+			types.Unhealthy, ReasonDialTimeout, 2 * timeout},
+	}
+	for _, c := range cases {
+		start := time.Now()
+		expectResult(t, c.name, newCompositeChecker(t, c.params), blackhole, timeout, c.state, c.reason)
+		// expectResult checks twice
+		if elapsed := time.Since(start); elapsed > 2*c.elapsed {
+			t.Errorf("%s: took %v", c.name, elapsed)
+		}
+	}
+}
+
+func TestCompositeCheckerParams(t *testing.T) {
+	RegisterScriptedChecker(NewScriptedChecker(nil))
+	defer delete(methods, CheckMethodScripted)
+
+	c := newCompositeChecker(t, map[string]string{
+		"a.method":      "tcp",
+		"a.send":        "ping",
+		"a.receive":     "pong",
+		"b.method":      "scripted",
+		ParamProxyProto: "v2",
+	})
+	if c.policy != CompositePolicyAnd || c.split != compositeDefaultTimeoutSplit {
+		t.Errorf("unexpected defaults: policy %s, timeout-split %v", c.policy, c.split)
+	}
+	if tcp, ok := c.children[0].(*TCPChecker); !ok || string(tcp.send.expand(nil)) != "ping" || tcp.proxyProto != "v2" {
+		t.Errorf("unexpected child a: %+v", c.children[0])
+	}
+	if _, ok := c.children[1].(*ScriptedChecker); !ok {
+		t.Errorf("unexpected child b: %+v", c.children[1])
+	}
+	explicit := newCompositeChecker(t, map[string]string{"a.method": "tcp", "b.method": "none",
+		"a." + ParamProxyProto: "v1", ParamProxyProto: "v2"})
+	if tcp := explicit.children[0].(*TCPChecker); tcp.proxyProto != "v1" {
+		t.Errorf("explicit child param overridden by %s", tcp.proxyProto)
+	}
+
+	for _, params := range []map[string]string{
+		{"b.method": "tcp"},
+		{"a.method": "tcp"},
+		{"a.method": "no-such-method", "b.method": "tcp"},
+		{"a.method": "auto", "b.method": "tcp"},
+		{"a.method": "composite", "b.method": "tcp"},
+		{"a.method": "tcp", "b.method": "tcp", "send": "ping"},
+		{"a.method": "tcp", "b.method": "tcp", "a.no-such-param": "x"},
+		{"a.method": "tcp", "b.method": "remote-agent"},
+		{"a.method": "tcp", "b.method": "tcp", "policy": "xor"},
+		{"a.method": "tcp", "b.method": "tcp", "timeout-split": "1"},
+		{"a.method": "tcp", "b.method": "tcp", "timeout-split": "0"},
+	} {
+		if _, err := (&CompositeChecker{}).create(params); err == nil {
+			t.Errorf("invalid params accepted: %v", params)
+		}
+	}
+}
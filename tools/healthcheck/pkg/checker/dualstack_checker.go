@@ -0,0 +1,161 @@
+// /*
+// Copyright 2025 IQiYi Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// */
+
+package checker
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/types"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/utils"
+)
+
+var _ CheckMethodEx = (*DualStackChecker)(nil)
+
+// DualStackChecker wraps a CheckMethod, typically a TCPChecker, to check a
+// target reachable over both IPv4 and IPv6. It probes all addresses of the
+// target concurrently like Happy Eyeballs, returns Healthy as soon as one
+// succeeds with the others canceled, and Unhealthy only if all fail within
+// the timeout.
+type DualStackChecker struct {
+	inner CheckMethod
+	addrs []*utils.L3L4Addr // alternative addresses of the target
+}
+
+// NewDualStackChecker returns a DualStackChecker wrapping `inner`. The `addrs`,
+// derived with utils.DualStackAddrs or utils.LookupDualStackAddrs, are probed
+// besides the target given to Check.
+func NewDualStackChecker(inner CheckMethod, addrs []*utils.L3L4Addr) *DualStackChecker {
+	return &DualStackChecker{
+		inner: inner,
+		addrs: addrs,
+	}
+}
+
+// candidates returns the deduplicated addresses to probe for `target`.
+func (c *DualStackChecker) candidates(target *utils.L3L4Addr) []*utils.L3L4Addr {
+	addrs := make([]*utils.L3L4Addr, 0, len(c.addrs)+1)
+	seen := make(map[string]struct{}, len(c.addrs)+1)
+	for _, addr := range append([]*utils.L3L4Addr{target}, c.addrs...) {
+		if addr == nil || len(addr.IP) == 0 {
+			continue
+		}
+		if _, ok := seen[addr.String()]; ok {
+			continue
+		}
+		seen[addr.String()] = struct{}{}
+		addrs = append(addrs, addr)
+	}
+	return addrs
+}
+
+func (c *DualStackChecker) Check(target *utils.L3L4Addr, timeout time.Duration) (types.State, error) {
+	return checkWithTimeout(c, target, timeout)
+}
+
+func (c *DualStackChecker) CheckContext(ctx context.Context, target *utils.L3L4Addr) (types.State, error) {
+	res, err := c.CheckEx(ctx, target)
+	return res.State, err
+}
+
+func (c *DualStackChecker) CheckEx(ctx context.Context, target *utils.L3L4Addr) (*CheckResult, error) {
+	start := time.Now()
+	addrs := c.candidates(target)
+	if len(addrs) == 0 {
+		return checkError(start, fmt.Errorf("no address to check"))
+	}
+	if len(addrs) == 1 {
+		return CheckEx(ctx, c.inner, addrs[0])
+	}
+
+	// The probes still in progress are canceled once the result is determined.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type probe struct {
+		addr *utils.L3L4Addr
+		res  *CheckResult
+		err  error
+	}
+	// Buffered for all probes so that the late ones never block.
+	probes := make(chan probe, len(addrs))
+	for _, addr := range addrs {
+		go func(addr *utils.L3L4Addr) {
+			res, err := CheckEx(ctx, c.inner, addr)
+			probes <- probe{addr: addr, res: res, err: err}
+		}(addr)
+	}
+
+	var failed *CheckResult
+	var lastErr error
+	details := make([]string, 0, len(addrs))
+	for range addrs {
+		p := <-probes
+		if p.err != nil {
+			lastErr = p.err
+			details = append(details, fmt.Sprintf("%s: %v", p.addr.Addr(), p.err))
+			continue
+		}
+		if p.res.State == types.Healthy {
+			glog.V(9).Infof("Dual-stack check %v %v: succeeded via %s", target, types.Healthy, p.addr.Addr())
+			res := *p.res
+			res.Latency = time.Since(start)
+			return &res, nil
+		}
+		if failed == nil {
+			failed = p.res
+		}
+		details = append(details, fmt.Sprintf("%s: %s", p.addr.Addr(), p.res.Detail))
+	}
+
+	if failed == nil {
+		// None of the checks is executed actually.
+		return checkError(start, lastErr)
+	}
+	res := *failed
+	res.Latency = time.Since(start)
+	res.Detail = strings.Join(details, "; ")
+	return &res, nil
+}
+
+func (c *DualStackChecker) DefaultParams() map[string]string {
+	if c.inner == nil {
+		return map[string]string{}
+	}
+	return c.inner.DefaultParams()
+}
+
+func (c *DualStackChecker) validate(params map[string]string) error {
+	if c.inner == nil {
+		return fmt.Errorf("dual-stack checker without inner checker")
+	}
+	return c.inner.validate(params)
+}
+
+func (c *DualStackChecker) create(params map[string]string) (CheckMethod, error) {
+	if err := c.validate(params); err != nil {
+		return nil, fmt.Errorf("dual-stack checker param validation failed: %v", err)
+	}
+	inner, err := c.inner.create(params)
+	if err != nil {
+		return nil, err
+	}
+	return NewDualStackChecker(inner, c.addrs), nil
+}
@@ -0,0 +1,99 @@
+// /*
+// Copyright 2025 IQiYi Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// */
+
+package checker
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/types"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/utils"
+)
+
+func TestDualStackChecker(t *testing.T) {
+	ln6, err := net.Listen("tcp6", "[::1]:0")
+	if err != nil {
+		t.Skipf("IPv6 loopback unavailable: %v", err)
+	}
+	defer ln6.Close()
+	go func() {
+		for {
+			conn, err := ln6.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	up6 := tcpTarget(ln6.Addr())
+	up4 := startTCPServer(t, func(conn *net.TCPConn) {})
+
+	ln, err := net.Listen("tcp6", "[::1]:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	down6 := tcpTarget(ln.Addr())
+	ln.Close()
+	down4 := closedTCPPort(t)
+	blackhole4 := blackholeTCPPort(t)
+
+	timeout := 2 * time.Second
+	tcp := &TCPChecker{}
+
+	// v4 stack times out while v6 stack works
+	start := time.Now()
+	expectResult(t, "blackhole v4 up v6", NewDualStackChecker(tcp, []*utils.L3L4Addr{up6}),
+		blackhole4, timeout, types.Healthy, ReasonNone)
+	if elapsed := time.Since(start); elapsed > timeout/2 {
+		t.Errorf("expected to return once v6 succeeded, took %v", elapsed)
+	}
+
+	expectResult(t, "up v4 down v6", NewDualStackChecker(tcp, []*utils.L3L4Addr{down6}),
+		up4, timeout, types.Healthy, ReasonNone)
+	expectResult(t, "single address", NewDualStackChecker(tcp, nil),
+		down4, timeout, types.Unhealthy, ReasonConnRefused)
+
+	// both stacks fail
+	res, err := CheckExTimeout(NewDualStackChecker(tcp, []*utils.L3L4Addr{down4, down6}), nil, timeout)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.State != types.Unhealthy || res.Reason != ReasonConnRefused {
+		t.Errorf("expect %v(%v), got %v(%v)", types.Unhealthy, ReasonConnRefused, res.State, res.Reason)
+	}
+	if !strings.Contains(res.Detail, down4.Addr()) || !strings.Contains(res.Detail, down6.Addr()) {
+		t.Errorf("expected failures of both stacks in detail, got %q", res.Detail)
+	}
+
+	if _, err := CheckExTimeout(NewDualStackChecker(tcp, nil), nil, timeout); err == nil {
+		t.Errorf("expected error without address to check")
+	}
+
+	// params are passed to the inner checker
+	checker, err := NewDualStackChecker(tcp, []*utils.L3L4Addr{up6}).create(map[string]string{"send": "ping"})
+	if err != nil {
+		t.Fatalf("failed to create dual-stack checker: %v", err)
+	}
+	if inner := checker.(*DualStackChecker).inner.(*TCPChecker); string(inner.send.expand(nil)) != "ping" {
+		t.Errorf("params not passed to inner checker: %+v", inner)
+	}
+	if _, err := NewDualStackChecker(tcp, nil).create(map[string]string{"bad": "x"}); err == nil {
+		t.Errorf("expected error for invalid params")
+	}
+}
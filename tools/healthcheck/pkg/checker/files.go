@@ -0,0 +1,125 @@
+// /*
+// Copyright 2025 IQiYi Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// */
+
+package checker
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/utils"
+)
+
+// Params of the files loaded when checkers are created, rather than per check.
+const (
+	ParamSendFile    = "send-file"    // send payload of tcp and udp checkers
+	ParamReceiveFile = "receive-file" // receive payload of tcp and udp checkers
+	ParamCAFile      = "ca-file"      // PEM CA certificates to verify the TLS server
+	ParamCertFile    = "cert-file"    // PEM client certificate for mutual TLS
+	ParamKeyFile     = "key-file"     // PEM private key of cert-file
+)
+
+// maxPayloadFileSize limits the payload files, which are kept in memory.
+const maxPayloadFileSize = 1 << 20
+
+// readPayloadFile returns the content of the payload file `path`, which is an
+// alternative to the inline payload param and decoded in the same way.
+func readPayloadFile(path string) (string, error) {
+	if len(path) == 0 {
+		return "", fmt.Errorf("empty file path")
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, maxPayloadFileSize+1))
+	if err != nil {
+		return "", err
+	}
+	if len(data) == 0 {
+		return "", fmt.Errorf("empty file %s", path)
+	}
+	if len(data) > maxPayloadFileSize {
+		return "", fmt.Errorf("file %s exceeds %d bytes", path, maxPayloadFileSize)
+	}
+	return string(data), nil
+}
+
+// payloadFile returns the payload in file `path` given by the file param of
+// the inline payload param `inline`, which conflicts with it.
+func payloadFile(params map[string]string, inline, path string) (string, error) {
+	if _, ok := params[inline]; ok {
+		return "", fmt.Errorf("conflicts with param %s", inline)
+	}
+	return readPayloadFile(path)
+}
+
+// tlsFiles is the TLS material loaded from the files of ParamCAFile,
+// ParamCertFile and ParamKeyFile.
+type tlsFiles struct {
+	rootCAs *x509.CertPool
+	certs   []tls.Certificate
+}
+
+// loadTLSFiles loads the TLS files given in `params`, and returns nil if none
+// is given. The cert-file and key-file must be given together, and ca-file is
+// useless unless the server is verified with param "tls-verify".
+func loadTLSFiles(params map[string]string) (*tlsFiles, error) {
+	_, ca := params[ParamCAFile]
+	_, cert := params[ParamCertFile]
+	_, key := params[ParamKeyFile]
+	if !ca && !cert && !key {
+		return nil, nil
+	}
+	files := &tlsFiles{}
+	if ca {
+		if verify, err := utils.String2bool(params["tls-verify"]); err == nil && !verify {
+			return nil, fmt.Errorf("param %s conflicts with tls-verify %s", ParamCAFile, params["tls-verify"])
+		}
+		pem, err := os.ReadFile(params[ParamCAFile])
+		if err != nil {
+			return nil, fmt.Errorf("invalid param %s: %v", ParamCAFile, err)
+		}
+		files.rootCAs = x509.NewCertPool()
+		if !files.rootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("invalid param %s: no certificate found in %s", ParamCAFile, params[ParamCAFile])
+		}
+	}
+	if cert != key {
+		return nil, fmt.Errorf("params %s and %s must be given together", ParamCertFile, ParamKeyFile)
+	}
+	if cert {
+		pair, err := tls.LoadX509KeyPair(params[ParamCertFile], params[ParamKeyFile])
+		if err != nil {
+			return nil, fmt.Errorf("invalid params %s and %s: %v", ParamCertFile, ParamKeyFile, err)
+		}
+		files.certs = []tls.Certificate{pair}
+	}
+	return files, nil
+}
+
+// apply sets the loaded TLS material to `config`, and returns it.
+func (f *tlsFiles) apply(config *tls.Config) *tls.Config {
+	if f != nil {
+		config.RootCAs = f.rootCAs
+		config.Certificates = f.certs
+	}
+	return config
+}
@@ -0,0 +1,303 @@
+// /*
+// Copyright 2025 IQiYi Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// */
+
+package checker
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/types"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/utils"
+)
+
+// writeTestFile writes `data` to file `name` in a temporary directory, and
+// returns its path.
+func writeTestFile(t *testing.T, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatalf("failed to write %s: %v", path, err)
+	}
+	return path
+}
+
+// testCert is a certificate with its key, signed by the CA if parent is given.
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	der  []byte
+}
+
+func newTestCert(t *testing.T, name string, parent *testCert, usage x509.ExtKeyUsage) *testCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	signer, signerKey := tmpl, key
+	if parent == nil {
+		tmpl.IsCA, tmpl.BasicConstraintsValid = true, true
+		tmpl.KeyUsage |= x509.KeyUsageCertSign
+		tmpl.ExtKeyUsage, tmpl.IPAddresses = nil, nil
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCert{cert: cert, key: key, der: der}
+}
+
+// write writes the certificate and key in PEM, and returns the paths.
+func (c *testCert) write(t *testing.T, name string) (string, string) {
+	t.Helper()
+	keyDER, err := x509.MarshalECPrivateKey(c.key)
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+	return writeTestFile(t, name+".crt", pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.der})),
+		writeTestFile(t, name+".key", pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+}
+
+func (c *testCert) tlsCert() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{c.der}, PrivateKey: c.key, Leaf: c.cert}
+}
+
+func TestReadPayloadFile(t *testing.T) {
+	data := "GET / HTTP/1.0\r\n\r\n"
+	if got, err := readPayloadFile(writeTestFile(t, "payload", []byte(data))); err != nil || got != data {
+		t.Errorf("unexpected payload %q, %v", got, err)
+	}
+	large := strings.Repeat("x", maxPayloadFileSize)
+	if got, err := readPayloadFile(writeTestFile(t, "large", []byte(large))); err != nil || got != large {
+		t.Errorf("unexpected payload of %d bytes, %v", len(got), err)
+	}
+	for _, path := range []string{
+		"",
+		filepath.Join(t.TempDir(), "missing"),
+		t.TempDir(),
+		writeTestFile(t, "empty", nil),
+		writeTestFile(t, "too-large", []byte(large+"x")),
+	} {
+		if _, err := readPayloadFile(path); err == nil {
+			t.Errorf("expect error with payload file %q", path)
+		}
+	}
+}
+
+func TestTLSFilesParams(t *testing.T) {
+	ca := newTestCert(t, "ca", nil, 0)
+	client := newTestCert(t, "client", ca, x509.ExtKeyUsageClientAuth)
+	caFile, caKeyFile := ca.write(t, "ca")
+	certFile, keyFile := client.write(t, "client")
+	otherKeyFile := writeTestFile(t, "other.key", []byte("not a key"))
+	missing := filepath.Join(t.TempDir(), "missing")
+
+	files, err := loadTLSFiles(map[string]string{ParamCAFile: caFile, ParamCertFile: certFile, ParamKeyFile: keyFile})
+	if err != nil || files == nil || files.rootCAs == nil || len(files.certs) != 1 {
+		t.Fatalf("unexpected tls files %+v, %v", files, err)
+	}
+	config := files.apply(&tls.Config{ServerName: "example.com"})
+	if config.ServerName != "example.com" || config.RootCAs != files.rootCAs || len(config.Certificates) != 1 {
+		t.Errorf("tls files not applied: %+v", config)
+	}
+	if files, err := loadTLSFiles(map[string]string{"tls-verify": "no"}); err != nil || files != nil {
+		t.Errorf("expect no tls files, got %+v, %v", files, err)
+	}
+	if config := (*tlsFiles)(nil).apply(&tls.Config{}); config.RootCAs != nil || config.Certificates != nil {
+		t.Errorf("nil tls files applied: %+v", config)
+	}
+
+	for _, tc := range []struct {
+		kind   Method
+		params map[string]string
+		valid  bool
+	}{
+		{CheckMethodHTTP, map[string]string{"https": "yes", ParamCAFile: caFile}, true},
+		{CheckMethodHTTP, map[string]string{"uri": "https://127.0.0.1/", ParamCertFile: certFile, ParamKeyFile: keyFile}, true},
+		{CheckMethodHTTP, map[string]string{ParamCAFile: caFile, "tls-verify": "false"}, false},
+		{CheckMethodHTTP, map[string]string{ParamCertFile: certFile, ParamKeyFile: keyFile, "tls-verify": "false"}, true},
+		{CheckMethodHTTP, map[string]string{ParamCertFile: certFile}, false},
+		{CheckMethodHTTP, map[string]string{ParamKeyFile: keyFile}, false},
+		{CheckMethodHTTP, map[string]string{ParamCertFile: certFile, ParamKeyFile: otherKeyFile}, false},
+		{CheckMethodHTTP, map[string]string{ParamCertFile: caFile, ParamKeyFile: keyFile}, false},
+		{CheckMethodHTTP, map[string]string{ParamCAFile: keyFile}, false},
+		{CheckMethodHTTP, map[string]string{ParamCAFile: missing}, false},
+		{CheckMethodHTTP, map[string]string{ParamCAFile: ""}, false},
+		{CheckMethodHTTP, map[string]string{ParamCAFile: caFile, "http-version": "2c"}, false},
+		{CheckMethodGRPC, map[string]string{"tls": "yes", ParamCAFile: caFile, ParamCertFile: certFile, ParamKeyFile: keyFile}, true},
+		{CheckMethodGRPC, map[string]string{ParamCAFile: caFile}, false},
+		{CheckMethodGRPC, map[string]string{"tls": "yes", ParamCertFile: certFile, ParamKeyFile: caKeyFile}, false},
+		{CheckMethodWebSocket, map[string]string{"tls": "yes", ParamCertFile: certFile, ParamKeyFile: keyFile}, true},
+		{CheckMethodWebSocket, map[string]string{"tls": "no", ParamCertFile: certFile, ParamKeyFile: keyFile}, false},
+		{CheckMethodWebSocket, map[string]string{"tls": "yes", ParamKeyFile: ""}, false},
+	} {
+		err := Validate(tc.kind, tc.params)
+		if tc.valid && err != nil {
+			t.Errorf("%s: expect %v valid, got %v", tc.kind, tc.params, err)
+		} else if !tc.valid && err == nil {
+			t.Errorf("%s: expect %v invalid", tc.kind, tc.params)
+		}
+	}
+}
+
+func TestTLSFilesCreate(t *testing.T) {
+	ca := newTestCert(t, "ca", nil, 0)
+	client := newTestCert(t, "client", ca, x509.ExtKeyUsageClientAuth)
+	caFile, _ := ca.write(t, "ca")
+	certFile, keyFile := client.write(t, "client")
+	target := &utils.L3L4Addr{IP: net.ParseIP("127.0.0.1"), Port: 443, Proto: utils.IPProtoTCP}
+
+	for kind, params := range map[Method]map[string]string{
+		CheckMethodHTTP:      {"https": "yes"},
+		CheckMethodGRPC:      {"tls": "yes"},
+		CheckMethodWebSocket: {"tls": "yes"},
+	} {
+		params[ParamCAFile], params[ParamCertFile], params[ParamKeyFile] = caFile, certFile, keyFile
+		method, err := NewChecker(kind, target, params)
+		if err != nil {
+			t.Fatalf("%s: failed to create checker: %v", kind, err)
+		}
+		var files *tlsFiles
+		switch c := method.(type) {
+		case *HTTPChecker:
+			files = c.tlsFiles
+		case *GRPCChecker:
+			files = c.tlsFiles
+		case *WebSocketChecker:
+			files = c.tlsFiles
+		}
+		if files == nil || files.rootCAs == nil || len(files.certs) != 1 {
+			t.Errorf("%s: tls files not loaded: %+v", kind, files)
+		}
+
+		// A file gone, such as in rotation, fails the creation rather than
+		// falling back to no client certificate or the system CAs.
+		params[ParamCAFile] = caFile + ".rotating"
+		if _, err := NewChecker(kind, target, params); err == nil {
+			t.Errorf("%s: expect error with missing ca-file", kind)
+		}
+		params[ParamCAFile], params[ParamKeyFile] = caFile, keyFile+".rotating"
+		if _, err := NewChecker(kind, target, params); err == nil {
+			t.Errorf("%s: expect error with missing key-file", kind)
+		}
+	}
+}
+
+func TestTLSFilesMutualTLS(t *testing.T) {
+	ca := newTestCert(t, "ca", nil, 0)
+	server := newTestCert(t, "server", ca, x509.ExtKeyUsageServerAuth)
+	client := newTestCert(t, "client", ca, x509.ExtKeyUsageClientAuth)
+	other := newTestCert(t, "other", newTestCert(t, "other-ca", nil, 0), x509.ExtKeyUsageClientAuth)
+	caFile, _ := ca.write(t, "ca")
+	certFile, keyFile := client.write(t, "client")
+	otherCertFile, otherKeyFile := other.write(t, "other")
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	start := func(handler http.Handler, h2 bool) *httptest.Server {
+		ts := httptest.NewUnstartedServer(handler)
+		ts.EnableHTTP2 = h2
+		ts.TLS = &tls.Config{
+			Certificates: []tls.Certificate{server.tlsCert()},
+			ClientCAs:    pool,
+			ClientAuth:   tls.RequireAndVerifyClientCert,
+		}
+		ts.Config.ErrorLog = log.New(io.Discard, "", 0) // failed handshakes expected
+		ts.StartTLS()
+		t.Cleanup(ts.Close)
+		return ts
+	}
+	httpTarget := tcpTarget(start(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}), false).Listener.Addr())
+	wsTarget := tcpTarget(start(&fakeWebSocket{}, false).Listener.Addr())
+	grpcTarget := tcpTarget(start(&fakeHealthServer{
+		statuses: map[string]grpcServingStatus{"": grpcStatusServing}}, true).Listener.Addr())
+
+	mtls := map[string]string{ParamCAFile: caFile, ParamCertFile: certFile, ParamKeyFile: keyFile}
+	with := func(params map[string]string, extra ...string) map[string]string {
+		res := make(map[string]string)
+		for k, v := range params {
+			res[k] = v
+		}
+		for i := 0; i+1 < len(extra); i += 2 {
+			res[extra[i]] = extra[i+1]
+		}
+		return res
+	}
+	for _, tc := range []struct {
+		name   string
+		kind   Method
+		target string
+		params map[string]string
+		expect types.State
+	}{
+		{"http", CheckMethodHTTP, "http", with(mtls, "https", "yes"), types.Healthy},
+		{"http no client cert", CheckMethodHTTP, "http", map[string]string{"https": "yes", ParamCAFile: caFile}, types.Unhealthy},
+		{"http untrusted client cert", CheckMethodHTTP, "http", with(mtls, "https", "yes",
+			ParamCertFile, otherCertFile, ParamKeyFile, otherKeyFile), types.Unhealthy},
+		{"http untrusted server", CheckMethodHTTP, "http", map[string]string{"https": "yes",
+			ParamCertFile: certFile, ParamKeyFile: keyFile}, types.Unhealthy},
+		{"http unverified server", CheckMethodHTTP, "http", map[string]string{"https": "yes", "tls-verify": "no",
+			ParamCertFile: certFile, ParamKeyFile: keyFile}, types.Healthy},
+		{"http2", CheckMethodHTTP, "grpc", with(mtls, "https", "yes", "http-version", "2"), types.Healthy},
+		{"websocket", CheckMethodWebSocket, "ws", with(mtls, "tls", "yes", "ping", "yes"), types.Healthy},
+		{"websocket no client cert", CheckMethodWebSocket, "ws", map[string]string{"tls": "yes", ParamCAFile: caFile}, types.Unhealthy},
+		{"grpc", CheckMethodGRPC, "grpc", with(mtls, "tls", "yes"), types.Healthy},
+		{"grpc no client cert", CheckMethodGRPC, "grpc", map[string]string{"tls": "yes", ParamCAFile: caFile}, types.Unhealthy},
+	} {
+		target := map[string]*utils.L3L4Addr{"http": httpTarget, "ws": wsTarget, "grpc": grpcTarget}[tc.target]
+		checker, err := NewChecker(tc.kind, target, tc.params)
+		if err != nil {
+			t.Fatalf("%s: failed to create checker: %v", tc.name, err)
+		}
+		state, err := checker.Check(target, time.Second)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+		} else if state != tc.expect {
+			t.Errorf("%s: expect %v, got %v", tc.name, tc.expect, state)
+		}
+	}
+}
@@ -0,0 +1,313 @@
+// /*
+// Copyright 2025 IQiYi Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// */
+
+package checker
+
+/*
+GRPC Checker Params:
+-------------------------------------------------------------
+name                value
+-------------------------------------------------------------
+service             service name in HealthCheckRequest, "" for the server
+tls                 yes | no | true | false, case insensitive
+sni-host            TLS server name and :authority of the request
+tls-verify          yes | no | true | false, case insensitive
+ca-file             PEM CA certificates to verify the server, system ones by default
+cert-file           PEM client certificate for mutual TLS, requires key-file
+key-file            PEM private key of cert-file
+prxoy-protocol      v1 | v2
+netns               network namespace name or path to check from
+-------------------------------------------------------------
+
+The checker calls grpc.health.v1.Health/Check over HTTP/2, cleartext (h2c) by
+default, and maps the response status SERVING to Healthy, and others to Unhealthy.
+A new connection is made for each check, and closed when the check finished.
+The ca-file, cert-file and key-file require tls, and are loaded when the
+checker is created.
+*/
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/grpcwire"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/types"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/utils"
+	"golang.org/x/net/http2"
+)
+
+var _ CheckMethod = (*GRPCChecker)(nil)
+
+const (
+	grpcHealthCheckPath = "/grpc.health.v1.Health/Check"
+	grpcMaxMessageSize  = 4096
+)
+
+// grpc.health.v1.HealthCheckResponse.ServingStatus
+type grpcServingStatus uint64
+
+const (
+	grpcStatusUnknown        grpcServingStatus = 0
+	grpcStatusServing        grpcServingStatus = 1
+	grpcStatusNotServing     grpcServingStatus = 2
+	grpcStatusServiceUnknown grpcServingStatus = 3
+)
+
+func (s grpcServingStatus) String() string {
+	switch s {
+	case grpcStatusUnknown:
+		return "UNKNOWN"
+	case grpcStatusServing:
+		return "SERVING"
+	case grpcStatusNotServing:
+		return "NOT_SERVING"
+	case grpcStatusServiceUnknown:
+		return "SERVICE_UNKNOWN"
+	}
+	return fmt.Sprintf("ServingStatus(%d)", uint64(s))
+}
+
+type GRPCChecker struct {
+	service    string
+	tls        bool
+	sniHost    string
+	tlsVerify  bool
+	tlsFiles   *tlsFiles
+	proxyProto string // "v1", "v2"
+	netns      string
+}
+
+func init() {
+	registerMethod(CheckMethodGRPC, &GRPCChecker{})
+}
+
+func (c *GRPCChecker) Check(target *utils.L3L4Addr, timeout time.Duration) (types.State, error) {
+	return checkWithTimeout(c, target, timeout)
+}
+
+func (c *GRPCChecker) CheckContext(ctx context.Context, target *utils.L3L4Addr) (types.State, error) {
+	if _, err := checkTimeout(ctx, "GRPC", time.Now()); err != nil {
+		return types.Unknown, err
+	}
+
+	addr := target.Addr()
+	glog.V(9).Infof("Start GRPC check to %s ...", addr)
+
+	conn, err := c.dial(ctx, target)
+	if err != nil {
+		glog.V(9).Infof("GRPC check %v %v: failed to connect: %v", addr, types.Unhealthy, err)
+		return types.Unhealthy, nil
+	}
+	defer conn.Close()
+	defer closeOnCancel(ctx, conn)()
+
+	status, err := c.call(ctx, conn, addr)
+	if err != nil {
+		glog.V(9).Infof("GRPC check %v %v: %v", addr, types.Unhealthy, err)
+		return types.Unhealthy, nil
+	}
+	if status != grpcStatusServing {
+		glog.V(9).Infof("GRPC check %v %v: service %q %v", addr, types.Unhealthy, c.service, status)
+		return types.Unhealthy, nil
+	}
+
+	glog.V(9).Infof("GRPC check %v %v: succeed", addr, types.Healthy)
+	return types.Healthy, nil
+}
+
+// dial connects to target, and negotiates TLS with ALPN "h2" if enabled.
+func (c *GRPCChecker) dial(ctx context.Context, target *utils.L3L4Addr) (net.Conn, error) {
+	var dialer net.Dialer
+	conn, err := netnsDial(ctx, &dialer, c.netns, target.Network(), target.Addr())
+	if err != nil {
+		return nil, err
+	}
+	deadline, _ := ctx.Deadline()
+	if err = conn.SetDeadline(deadline); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if "v2" == c.proxyProto {
+		err = utils.WriteFull(conn, proxyProtoV2LocalCmd)
+	} else if "v1" == c.proxyProto {
+		err = utils.WriteFull(conn, []byte(proxyProtoV1LocalCmd))
+	}
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to send proxy protocol data: %v", err)
+	}
+
+	if !c.tls {
+		return conn, nil
+	}
+	serverName := c.sniHost
+	if len(serverName) == 0 {
+		serverName = target.IP.String()
+	}
+	tlsConn := tls.Client(conn, c.tlsFiles.apply(&tls.Config{
+		ServerName:         serverName,
+		InsecureSkipVerify: !c.tlsVerify,
+		NextProtos:         []string{http2.NextProtoTLS},
+	}))
+	if err = tlsConn.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("tls handshake failed: %v", err)
+	}
+	if proto := tlsConn.ConnectionState().NegotiatedProtocol; proto != http2.NextProtoTLS {
+		tlsConn.Close()
+		return nil, fmt.Errorf("unexpected application protocol %q", proto)
+	}
+	return tlsConn, nil
+}
+
+// call sends a HealthCheckRequest on conn, and returns the serving status responded.
+func (c *GRPCChecker) call(ctx context.Context, conn net.Conn, addr string) (grpcServingStatus, error) {
+	tr := &http2.Transport{AllowHTTP: true}
+	cc, err := tr.NewClientConn(conn)
+	if err != nil {
+		return grpcStatusUnknown, fmt.Errorf("failed to create http2 connection: %v", err)
+	}
+	defer cc.Close()
+
+	scheme, authority := "http", addr
+	if c.tls {
+		scheme = "https"
+	}
+	if len(c.sniHost) > 0 {
+		authority = c.sniHost
+	}
+	data, err := grpcwire.Call(ctx, cc, scheme+"://"+authority+grpcHealthCheckPath,
+		grpcHealthCheckRequest(c.service), grpcMaxMessageSize)
+	if err != nil {
+		var statusErr *grpcwire.StatusError
+		if errors.As(err, &statusErr) && statusErr.Code == grpcwire.StatusNotFound {
+			// the service is not registered
+			return grpcStatusServiceUnknown, nil
+		}
+		return grpcStatusUnknown, err
+	}
+	return grpcParseHealthCheckResponse(data)
+}
+
+// grpcHealthCheckRequest encodes the message of HealthCheckRequest.
+func grpcHealthCheckRequest(service string) []byte {
+	return grpcwire.AppendStringField(nil, 1, service)
+}
+
+// grpcParseHealthCheckResponse decodes the message of HealthCheckResponse.
+func grpcParseHealthCheckResponse(data []byte) (grpcServingStatus, error) {
+	status := grpcStatusUnknown
+	if err := grpcwire.WalkFields(data, func(field int, num uint64, _ []byte) error {
+		if field == 1 {
+			status = grpcServingStatus(num)
+		}
+		return nil
+	}); err != nil {
+		return grpcStatusUnknown, fmt.Errorf("invalid response message: %v", err)
+	}
+	return status, nil
+}
+
+func (c *GRPCChecker) DefaultParams() map[string]string {
+	return map[string]string{
+		"service":       "",
+		"tls":           "false",
+		"sni-host":      "",
+		"tls-verify":    "true",
+		ParamCAFile:     "",
+		ParamCertFile:   "",
+		ParamKeyFile:    "",
+		ParamProxyProto: "",
+		ParamNetns:      "",
+	}
+}
+
+func (c *GRPCChecker) validate(params map[string]string) error {
+	_, err := c.parse(params)
+	return err
+}
+
+// parse validates params, and returns the TLS files loaded.
+func (c *GRPCChecker) parse(params map[string]string) (*tlsFiles, error) {
+	unsupported := make([]string, 0, len(params))
+	for param, val := range params {
+		switch param {
+		case "service":
+		case "tls", "tls-verify":
+			if _, err := utils.String2bool(val); err != nil {
+				return nil, fmt.Errorf("invalid grpc checker param %s:%s", param, params[param])
+			}
+		case "sni-host", ParamCAFile, ParamCertFile, ParamKeyFile:
+			if len(val) == 0 {
+				return nil, fmt.Errorf("empty grpc checker param: %s", param)
+			}
+		case ParamProxyProto:
+			val = strings.ToLower(val)
+			if val != "v1" && val != "v2" {
+				return nil, fmt.Errorf("invalid grpc checker param %s:%s", param, params[param])
+			}
+		case ParamNetns:
+			if err := utils.ValidateNetns(val); err != nil {
+				return nil, fmt.Errorf("invalid grpc checker param %s:%s, %v", param, val, err)
+			}
+		default:
+			unsupported = append(unsupported, param)
+		}
+	}
+
+	if len(unsupported) > 0 {
+		return nil, fmt.Errorf("unsupported grpc checker params: %q", strings.Join(unsupported, ","))
+	}
+
+	files, err := loadTLSFiles(params)
+	if err != nil {
+		return nil, fmt.Errorf("invalid grpc checker tls files: %v", err)
+	}
+	if useTLS, _ := utils.String2bool(params["tls"]); files != nil && !useTLS {
+		return nil, fmt.Errorf("grpc checker tls files require tls")
+	}
+	return files, nil
+}
+
+func (c *GRPCChecker) create(params map[string]string) (CheckMethod, error) {
+	files, err := c.parse(params)
+	if err != nil {
+		return nil, fmt.Errorf("grpc checker param validation failed: %v", err)
+	}
+
+	checker := &GRPCChecker{ // init and set default value
+		service:    params["service"],
+		sniHost:    params["sni-host"],
+		tlsVerify:  true,
+		proxyProto: strings.ToLower(params[ParamProxyProto]),
+		netns:      params[ParamNetns],
+	}
+	if val, ok := params["tls"]; ok {
+		checker.tls, _ = utils.String2bool(val)
+	}
+	if val, ok := params["tls-verify"]; ok {
+		checker.tlsVerify, _ = utils.String2bool(val)
+	}
+	checker.tlsFiles = files
+	return checker, nil
+}
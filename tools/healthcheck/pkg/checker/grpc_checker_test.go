@@ -0,0 +1,276 @@
+// /*
+// Copyright 2025 IQiYi Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// */
+
+package checker
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/types"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/utils"
+	"golang.org/x/net/http2"
+)
+
+// fakeHealthServer implements grpc.health.v1.Health/Check on the wire.
+type fakeHealthServer struct {
+	lock     sync.Mutex
+	statuses map[string]grpcServingStatus // registered services
+	code     string                       // grpc-status to respond if not "0"
+	delay    time.Duration
+	timeout  string // grpc-timeout of the last request
+}
+
+func (s *fakeHealthServer) set(service string, status grpcServingStatus) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.statuses[service] = status
+}
+
+func (s *fakeHealthServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.URL.Path != grpcHealthCheckPath ||
+		r.Header.Get("Content-Type") != "application/grpc" {
+		w.WriteHeader(http.StatusUnsupportedMediaType)
+		return
+	}
+	data, err := io.ReadAll(r.Body)
+	if err != nil || len(data) < 5 || int(binary.BigEndian.Uint32(data[1:5])) != len(data)-5 {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	service := ""
+	if msg := data[5:]; len(msg) > 0 {
+		size, n := binary.Uvarint(msg[1:])
+		service = string(msg[1+n : 1+n+int(size)])
+	}
+
+	s.lock.Lock()
+	s.timeout = r.Header.Get("Grpc-Timeout")
+	status, ok := s.statuses[service]
+	code, delay := s.code, s.delay
+	s.lock.Unlock()
+
+	select {
+	case <-time.After(delay):
+	case <-r.Context().Done():
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/grpc")
+	if len(code) > 0 || !ok { // Trailers-Only response
+		if len(code) == 0 {
+			code = "5"
+		}
+		w.Header().Set("Grpc-Status", code)
+		w.Header().Set("Grpc-Message", "unknown service")
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	var msg []byte
+	if status != grpcStatusUnknown {
+		msg = binary.AppendUvarint([]byte{0x08}, uint64(status))
+	}
+	frame := make([]byte, 5, 5+len(msg))
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(msg)))
+	w.Write(append(frame, msg...))
+	w.Header().Set(http.TrailerPrefix+"Grpc-Status", "0")
+}
+
+// startH2C serves s with HTTP/2 over cleartext.
+func (s *fakeHealthServer) startH2C(t *testing.T) *utils.L3L4Addr {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go (&http2.Server{}).ServeConn(conn, &http2.ServeConnOpts{Handler: s})
+		}
+	}()
+	port := ln.Addr().(*net.TCPAddr).Port
+	return &utils.L3L4Addr{IP: net.ParseIP("127.0.0.1"), Port: uint16(port), Proto: utils.IPProtoTCP}
+}
+
+// startTLS serves s with HTTP/2 over TLS.
+func (s *fakeHealthServer) startTLS(t *testing.T) *utils.L3L4Addr {
+	ts := httptest.NewUnstartedServer(s)
+	ts.EnableHTTP2 = true
+	ts.StartTLS()
+	t.Cleanup(ts.Close)
+	port := ts.Listener.Addr().(*net.TCPAddr).Port
+	return &utils.L3L4Addr{IP: net.ParseIP("127.0.0.1"), Port: uint16(port), Proto: utils.IPProtoTCP}
+}
+
+func TestGRPCChecker(t *testing.T) {
+	server := &fakeHealthServer{statuses: map[string]grpcServingStatus{
+		"":            grpcStatusServing,
+		"app.Greeter": grpcStatusServing,
+	}}
+	h2cTarget := server.startH2C(t)
+	tlsTarget := server.startTLS(t)
+	timeout := time.Second
+
+	check := func(name string, target *utils.L3L4Addr, params map[string]string, expect types.State) {
+		checker, err := (&GRPCChecker{}).create(params)
+		if err != nil {
+			t.Fatalf("%s: failed to create GRPC checker: %v", name, err)
+		}
+		state, err := checker.Check(target, timeout)
+		if err != nil {
+			t.Errorf("%s: failed to execute GRPC checker: %v", name, err)
+		} else if state != expect {
+			t.Errorf("%s: expect %v, got %v", name, expect, state)
+		}
+	}
+	service := map[string]string{"service": "app.Greeter"}
+	tlsService := map[string]string{"service": "app.Greeter", "tls": "true", "tls-verify": "false"}
+
+	check("server", h2cTarget, nil, types.Healthy)
+	check("service", h2cTarget, service, types.Healthy)
+	check("tls", tlsTarget, tlsService, types.Healthy)
+	check("tls sni", tlsTarget, map[string]string{"tls": "yes", "tls-verify": "no",
+		"sni-host": "example.com"}, types.Healthy)
+	check("tls untrusted", tlsTarget, map[string]string{"tls": "true"}, types.Unhealthy)
+	check("h2c to tls", tlsTarget, nil, types.Unhealthy)
+	check("tls to h2c", h2cTarget, tlsService, types.Unhealthy)
+	check("unknown service", h2cTarget, map[string]string{"service": "app.Unknown"}, types.Unhealthy)
+
+	for _, status := range []grpcServingStatus{grpcStatusNotServing, grpcStatusServiceUnknown, grpcStatusUnknown} {
+		server.set("app.Greeter", status)
+		check(status.String(), h2cTarget, service, types.Unhealthy)
+		check(status.String()+" tls", tlsTarget, tlsService, types.Unhealthy)
+		check(status.String()+" server", h2cTarget, nil, types.Healthy)
+	}
+	server.set("app.Greeter", grpcStatusServing)
+	check("serving again", h2cTarget, service, types.Healthy)
+
+	server.lock.Lock()
+	server.code = "12" // UNIMPLEMENTED
+	server.lock.Unlock()
+	check("unimplemented", h2cTarget, nil, types.Unhealthy)
+	server.lock.Lock()
+	server.code = ""
+	server.lock.Unlock()
+
+	// closed port
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	closed := *h2cTarget
+	closed.Port = uint16(ln.Addr().(*net.TCPAddr).Port)
+	ln.Close()
+	check("closed port", &closed, nil, types.Unhealthy)
+}
+
+func TestGRPCCheckerTimeout(t *testing.T) {
+	server := &fakeHealthServer{
+		statuses: map[string]grpcServingStatus{"": grpcStatusServing},
+		delay:    5 * time.Second,
+	}
+	target := server.startH2C(t)
+	timeout := 300 * time.Millisecond
+
+	checker, _ := (&GRPCChecker{}).create(nil)
+	start := time.Now()
+	state, err := checker.Check(target, timeout)
+	elapsed := time.Since(start)
+	if err != nil || state != types.Unhealthy {
+		t.Errorf("expect %v, got %v, %v", types.Unhealthy, state, err)
+	}
+	if elapsed > timeout+100*time.Millisecond {
+		t.Errorf("check not bounded by timeout, elapsed %v", elapsed)
+	}
+
+	server.lock.Lock()
+	grpcTimeout := server.timeout
+	server.lock.Unlock()
+	ms, err := strconv.Atoi(strings.TrimSuffix(grpcTimeout, "m"))
+	if err != nil || !strings.HasSuffix(grpcTimeout, "m") || ms <= 0 || ms > int(timeout.Milliseconds()) {
+		t.Errorf("unexpected grpc-timeout %q for check timeout %v", grpcTimeout, timeout)
+	}
+}
+
+func TestGRPCCheckerNoLeak(t *testing.T) {
+	server := &fakeHealthServer{statuses: map[string]grpcServingStatus{"": grpcStatusServing}}
+	h2cTarget := server.startH2C(t)
+	tlsTarget := server.startTLS(t)
+	h2cChecker, _ := (&GRPCChecker{}).create(nil)
+	tlsChecker, _ := (&GRPCChecker{}).create(map[string]string{"tls": "true", "tls-verify": "false"})
+
+	// warm up
+	h2cChecker.Check(h2cTarget, time.Second)
+	tlsChecker.Check(tlsTarget, time.Second)
+	time.Sleep(100 * time.Millisecond)
+	base := runtime.NumGoroutine()
+
+	for i := 0; i < 20; i++ {
+		if state, _ := h2cChecker.Check(h2cTarget, time.Second); state != types.Healthy {
+			t.Fatalf("expect %v, got %v", types.Healthy, state)
+		}
+		if state, _ := tlsChecker.Check(tlsTarget, time.Second); state != types.Healthy {
+			t.Fatalf("expect tls %v, got %v", types.Healthy, state)
+		}
+	}
+
+	n := 0
+	for i := 0; i < 50; i++ {
+		if n = runtime.NumGoroutine(); n <= base {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Errorf("goroutines leaked: %d before checks, %d after", base, n)
+}
+
+func TestGRPCCheckerParams(t *testing.T) {
+	for _, tc := range []struct {
+		params map[string]string
+		valid  bool
+	}{
+		{nil, true},
+		{map[string]string{"service": "app.Greeter", "tls": "true", "sni-host": "example.com",
+			"tls-verify": "no", ParamProxyProto: "v2"}, true},
+		{map[string]string{"service": ""}, true},
+		{map[string]string{"tls": "maybe"}, false},
+		{map[string]string{"tls-verify": ""}, false},
+		{map[string]string{"sni-host": ""}, false},
+		{map[string]string{ParamProxyProto: "v3"}, false},
+		{map[string]string{"uri": "/"}, false},
+	} {
+		err := (&GRPCChecker{}).validate(tc.params)
+		if tc.valid && err != nil {
+			t.Errorf("expect %v valid, got %v", tc.params, err)
+		} else if !tc.valid && err == nil {
+			t.Errorf("expect %v invalid", tc.params)
+		}
+	}
+}
@@ -22,21 +22,69 @@ HTTP Checker Params:
 name                value
 -------------------------------------------------------------
 method				GET | PUT | POST | HEAD
-host                target host
+host                Host header, defaults to the target address
 uri                 target http URI
 https               yes | no | true | false, case insensitive
 tls-verify          yes | no | true | false, case insensitive
+sni-host            TLS server name, defaults to the host of uri
+ca-file             PEM CA certificates to verify the server, system ones by default
+cert-file           PEM client certificate for mutual TLS, requires key-file
+key-file            PEM private key of cert-file
 proxy               yes | no | true | false, case insensitive
 prxoy-protocol      v1 | v2
-
-request-headers     KEY::VALUE;;KEY::VALUE ...
-request             request data
-response-codes      [CODE-CODE|CODE],[CODE-CODE|CODE] ...
+follow-redirects    yes | no | true | false, case insensitive
+max-redirects       max redirects to follow, default 10
+http-version        1.1 | 2 | 2c | 3, default 1.1, or 3 if quic
+strict-version      yes | no | true | false, case insensitive
+quic                yes | no | true | false, derived from dpvs
+
+header              Name: value,Name: value ...
+header1, header2 .. Name: value, for values containing commas
+request-headers     KEY::VALUE;;KEY::VALUE ..., deprecated by header
+body                request body
+request             request body, deprecated by body
+content-type        Content-Type header of the request body
+status              [CODE-CODE|CODE],[CODE-CODE|CODE] ..., such as 200,204,300-399
+response-codes      [CODE-CODE|CODE],[CODE-CODE|CODE] ..., overridden by status
 response			expected response data
+connect-timeout     duration to connect, capped by the check timeout
+read-timeout        duration to do TLS handshake, request and response after connected
+websocket           yes | no | true | false, check the WebSocket upgrade of uri
+ws-ping             yes | no | true | false, send a ping frame once upgraded
+netns               network namespace name or path to check from
+max-latency         duration from dialing to the response headers, over which the check fails
 -------------------------------------------------------------
 
-TODO:
-  Add supports for QUIC/HTTP3.
+The status codes allowed default to 200-499, and those given by status must be
+within 100-999.
+
+A 3xx response is evaluated against the status codes directly unless
+follow-redirects is enabled, in which case the final response is evaluated
+and the check fails if more than max-redirects redirects are met.
+
+The http-version "2" negotiates HTTP/2 via TLS ALPN, thus requires https, and
+"2c" speaks HTTP/2 over cleartext TCP with prior knowledge. A response of other
+HTTP version is accepted unless strict-version is enabled. The http-version "3"
+speaks HTTP/3 over QUIC, which is always over TLS whether https is set or not,
+and works with neither proxy nor proxy-protocol. The connect-timeout bounds the
+QUIC handshake. The http-version defaults to "3" when quic is true.
+
+Request headers are applied in the order of request-headers, header and the
+numbered headerN params. A "Host" header is equivalent to the host param,
+which takes precedence. The response param is not allowed with HEAD method
+for there is no response body.
+
+In websocket mode, a GET request of the RFC 6455 opening handshake is sent
+with a random Sec-WebSocket-Key, and only a "101 Switching Protocols" response
+with the matching Sec-WebSocket-Accept is healthy, where the status codes are
+not used. If ws-ping is enabled, a ping frame is sent once upgraded and its
+pong is expected within the check timeout. The connection is closed with a
+close frame at last. It works with https, sni-host and proxy-protocol, but not
+with HTTP/2.
+
+The ca-file, cert-file and key-file are loaded when the checker is created,
+and used by https. The backends requiring client certificates are checked with
+cert-file and key-file.
 
 */
 
@@ -50,16 +98,24 @@ import (
 	"net"
 	"net/http"
 	"net/url"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/golang/glog"
 	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/types"
 	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/utils"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/websocket"
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+	"golang.org/x/net/http/httpguts"
+	"golang.org/x/net/http2"
 )
 
-var _ CheckMethod = (*HTTPChecker)(nil)
+var _ CheckMethodEx = (*HTTPChecker)(nil)
 
 var httpAllowddMethod = map[string]struct{}{
 	"GET":  struct{}{},
@@ -68,24 +124,91 @@ var httpAllowddMethod = map[string]struct{}{
 	"HEAD": struct{}{},
 }
 
+const httpDefaultMaxRedirects = 10
+
+// HTTP protocol versions supported by param "http-version".
+const (
+	httpVersion11 = "1.1"
+	httpVersion2  = "2"
+	httpVersion2C = "2c" // HTTP/2 over cleartext TCP, aka h2c
+	httpVersion3  = "3"
+)
+
+// http3ReadTimeout is the message of HTTP/3 connections closed by read-timeout.
+const http3ReadTimeout = "read timeout"
+
+// httpNumberedHeaderParam matches the numbered header params, such as "header1".
+var httpNumberedHeaderParam = regexp.MustCompile(`^header([0-9]+)$`)
+
+type httpHeader struct {
+	name  string
+	value string
+}
+
 type HttpCodeRange struct {
 	Start int // inclusive
 	End   int // inclusive
 }
 
+const (
+	httpMinStatus = 100
+	httpMaxStatus = 999
+)
+
+// httpStatusSet is a bitmap of HTTP status codes to match a response code in
+// constant time. Codes out of 0-httpMaxStatus are never matched.
+type httpStatusSet [(httpMaxStatus + 64) / 64]uint64
+
+func newHttpStatusSet(ranges []HttpCodeRange) *httpStatusSet {
+	set := &httpStatusSet{}
+	for _, r := range ranges {
+		start, end := r.Start, r.End
+		if start < 0 {
+			start = 0
+		}
+		if end > httpMaxStatus {
+			end = httpMaxStatus
+		}
+		for code := start; code <= end; code++ {
+			set[code/64] |= 1 << (code % 64)
+		}
+	}
+	return set
+}
+
+func (s *httpStatusSet) contains(code int) bool {
+	return code >= 0 && code <= httpMaxStatus && s[code/64]&(1<<(code%64)) != 0
+}
+
 type HTTPChecker struct {
 	method        string
 	host          string
 	uri           string
 	https         bool
 	tlsVerify     bool
+	sniHost       string
+	tlsFiles      *tlsFiles
 	proxy         bool
 	proxyProtocol string
 
-	requestHeaders       map[string]string
+	followRedirects bool
+	maxRedirects    int
+
+	version       string
+	strictVersion bool
+
+	requestHeaders       []httpHeader
 	request              []byte
-	responseCodesAllowed []HttpCodeRange
+	responseCodesAllowed *httpStatusSet
 	response             []byte
+
+	timeouts phaseTimeouts
+
+	websocket bool
+	wsPing    bool
+
+	netns      string
+	maxLatency time.Duration
 }
 
 func init() {
@@ -93,204 +216,561 @@ func init() {
 }
 
 func (c *HTTPChecker) Check(target *utils.L3L4Addr, timeout time.Duration) (types.State, error) {
-	if timeout <= time.Duration(0) {
-		return types.Unknown, fmt.Errorf("zero timeout on HTTP check")
+	return checkWithTimeout(c, target, timeout)
+}
+
+func (c *HTTPChecker) CheckContext(ctx context.Context, target *utils.L3L4Addr) (types.State, error) {
+	res, err := c.CheckEx(ctx, target)
+	return res.State, err
+}
+
+func (c *HTTPChecker) CheckEx(ctx context.Context, target *utils.L3L4Addr) (*CheckResult, error) {
+	start := time.Now()
+	timeout, err := checkTimeout(ctx, "HTTP", start)
+	if err != nil {
+		return checkError(start, err)
 	}
 	addr := target.Addr()
 	glog.V(9).Infof("Start HTTP check to %s ...", addr)
 
-	if len(c.host) == 0 {
-		c.host = addr
+	deadline := start.Add(timeout)
+	if limit := c.timeouts.limit(start, deadline); limit.Before(deadline) {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, limit)
+		defer cancel()
 	}
 
 	// 1. Create a http client.
 	u, err := url.Parse(c.uri)
 	if err != nil {
-		return types.Unknown, fmt.Errorf("url parse failed -- url: %v, error: %v", c.uri, err)
+		return checkError(start, fmt.Errorf("url parse failed -- url: %v, error: %v", c.uri, err))
 	}
-	if c.https || strings.HasPrefix(c.uri, "https://") {
+	if c.https || c.version == httpVersion3 || strings.HasPrefix(c.uri, "https://") {
 		u.Scheme = "https"
 	} else {
 		u.Scheme = "http"
 	}
 	if len(u.Host) == 0 {
-		u.Host = c.host
+		u.Host = addr
 	}
 
 	proxy := (func(*http.Request) (*url.URL, error))(nil)
 	if c.proxy {
 		proxy = http.ProxyURL(u)
 	}
-	tlsConfig := &tls.Config{
+	tlsConfig := c.tlsFiles.apply(&tls.Config{
+		ServerName:         c.sniHost,
 		InsecureSkipVerify: !c.tlsVerify,
-	}
-	tr := &http.Transport{
-		Proxy:               proxy,
-		TLSClientConfig:     tlsConfig,
-		TLSHandshakeTimeout: timeout,
-	}
-	if len(c.proxyProtocol) > 0 {
-		tr.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
-			conn, err := (&net.Dialer{
-				Timeout: timeout,
-			}).DialContext(ctx, network, addr)
-			if err != nil {
-				return nil, err
+	})
+
+	// Connecting and redirecting results are recorded to classify the failure.
+	var lock sync.Mutex
+	var connected bool
+	var redirectErr error
+	dial := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := netnsDial(ctx, &net.Dialer{
+			Timeout: c.timeouts.dialTimeout(start, deadline),
+		}, c.netns, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		lock.Lock()
+		connected = true
+		lock.Unlock()
+		// The upgraded websocket connection is no longer bound to ctx.
+		if c.timeouts.read > 0 || c.websocket {
+			conn.SetDeadline(c.timeouts.readDeadline(start, time.Now(), deadline))
+		}
+		// Alternatively, use the go-proxyproto package:
+		//   https://pkg.go.dev/github.com/pires/go-proxyproto
+		if "v2" == c.proxyProtocol {
+			if err = utils.WriteFull(conn, proxyProtoV2LocalCmd); err != nil {
+				conn.Close()
+				return nil, fmt.Errorf("failed to send proxy protocol v2 data: %w", err)
 			}
-			// Alternatively, use the go-proxyproto package:
-			//   https://pkg.go.dev/github.com/pires/go-proxyproto
-			if "v2" == c.proxyProtocol {
-				if err = utils.WriteFull(conn, proxyProtoV2LocalCmd); err != nil {
-					return nil, fmt.Errorf("failed to send proxy protocol v2 data: %v", err)
-				}
-			} else if "v1" == c.proxyProtocol {
-				if err = utils.WriteFull(conn, []byte(proxyProtoV1LocalCmd)); err != nil {
-					return nil, fmt.Errorf("failed to send proxy protocol v1 data: %v", err)
-				}
+		} else if "v1" == c.proxyProtocol {
+			if err = utils.WriteFull(conn, []byte(proxyProtoV1LocalCmd)); err != nil {
+				conn.Close()
+				return nil, fmt.Errorf("failed to send proxy protocol v1 data: %w", err)
 			}
-			return conn, nil
+		}
+		return conn, nil
+	}
+
+	var tr http.RoundTripper
+	if c.version == httpVersion3 {
+		h3, closeH3 := c.http3Transport(tlsConfig, start, deadline, func() {
+			lock.Lock()
+			connected = true
+			lock.Unlock()
+		})
+		defer closeH3()
+		tr = h3
+	} else if c.version == httpVersion2C {
+		// HTTP/2 with prior knowledge, TLS is never used for the "http" scheme.
+		tr = &http2.Transport{
+			AllowHTTP: true,
+			DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+				return dial(ctx, network, addr)
+			},
 		}
 	} else {
-		tr.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
-			conn, err := (&net.Dialer{
-				Timeout: timeout,
-			}).DialContext(ctx, network, addr)
-			if err != nil {
-				return nil, err
-			}
-			return conn, nil
+		tr = &http.Transport{
+			Proxy:               proxy,
+			DialContext:         dial,
+			TLSClientConfig:     tlsConfig,
+			TLSHandshakeTimeout: timeout,
+			ForceAttemptHTTP2:   c.version == httpVersion2,
 		}
 	}
 
 	client := &http.Client{
 		Transport: tr,
 		Timeout:   timeout,
-		CheckRedirect: func(req *http.Request, viva []*http.Request) error {
-			return errors.New("redirect not permitted")
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if !c.followRedirects {
+				return http.ErrUseLastResponse
+			}
+			if len(via) > c.maxRedirects {
+				lock.Lock()
+				redirectErr = fmt.Errorf("stopped after %d redirects", c.maxRedirects)
+				lock.Unlock()
+				return redirectErr
+			}
+			return nil
 		},
 	}
 
+	if c.websocket {
+		// The response body of 101 is wrapped as read-only with client
+		// timeout, and ctx bounds the handshake anyway.
+		client.Timeout = 0
+	}
+
+	defer client.CloseIdleConnections()
+
 	// 2. Send http request and check response.
 	var reqBody io.Reader = nil
 	if len(c.request) > 0 {
 		reqBody = bytes.NewBuffer(c.request)
 	}
-	req, err := http.NewRequest(c.method, c.uri, reqBody)
-	req.URL = u
+	req, err := http.NewRequestWithContext(ctx, c.method, u.String(), reqBody)
+	if err != nil {
+		return checkError(start, fmt.Errorf("failed to create http request: %v", err))
+	}
+	for _, hdr := range c.requestHeaders {
+		if http.CanonicalHeaderKey(hdr.name) == "Host" {
+			req.Host = hdr.value
+			continue
+		}
+		req.Header.Add(hdr.name, hdr.value)
+	}
+	if len(c.host) > 0 {
+		req.Host = c.host
+	}
+	var wsKey string
+	if c.websocket {
+		if wsKey, err = websocket.NewKey(); err != nil {
+			return checkError(start, fmt.Errorf("failed to generate websocket key: %v", err))
+		}
+		req.Header.Set("Upgrade", "websocket")
+		req.Header.Set("Connection", "Upgrade")
+		req.Header.Set("Sec-WebSocket-Key", wsKey)
+		req.Header.Set("Sec-WebSocket-Version", websocket.Version)
+	}
 
-	// If we received a response we want to process it, even in the
-	// presence of an error - a redirect 3xx will result in both the
-	// response and an error being returned.
+	// A 3xx response is returned without error if redirects are not
+	// followed, and an error is returned if too many redirects are met.
 	resp, err := client.Do(req)
-	if resp == nil {
-		glog.V(9).Infof("HTTP check %v %v: failed to send request, err: %v",
-			addr, types.Unhealthy, err)
-		return types.Unhealthy, nil
+	if err != nil {
+		if resp != nil && resp.Body != nil {
+			resp.Body.Close()
+		}
+		lock.Lock()
+		reason := errReason(err, !connected)
+		if redirectErr != nil {
+			reason = ReasonBadStatus
+		}
+		lock.Unlock()
+		var h3Err *http3.Error
+		if errors.As(err, &h3Err) && !h3Err.Remote && h3Err.ErrorMessage == http3ReadTimeout {
+			reason = ReasonTimeout
+		}
+		return checkFailed("HTTP", addr, start, reason, "failed to send request, err: %v", err), nil
 	}
 	if resp.Body != nil {
 		defer resp.Body.Close()
 	}
+	latency := time.Since(start)
 
-	// check response code
-	codeOk := false
-	for _, cr := range c.responseCodesAllowed {
-		if resp.StatusCode >= cr.Start && resp.StatusCode <= cr.End {
-			codeOk = true
-			break
+	// check protocol version
+	if major := c.versionMajor(); resp.ProtoMajor != major {
+		if c.strictVersion {
+			return checkFailed("HTTP", addr, start, ReasonProtocolError,
+				"unexpected protocol %s, want HTTP/%s", resp.Proto, c.version), nil
 		}
+		glog.V(9).Infof("HTTP check %s: protocol downgraded to %s from HTTP/%s",
+			addr, resp.Proto, c.version)
+	}
+
+	if c.websocket {
+		return checkLatency("HTTP", addr, c.checkWebSocket(resp, wsKey, addr, start), latency,
+			c.maxLatency), nil
 	}
-	if !codeOk {
-		glog.V(9).Infof("HTTP check %v %v: unexpected response code %d", addr,
-			types.Unhealthy, resp.StatusCode)
-		return types.Unhealthy, nil
+
+	// check response code
+	if !c.responseCodesAllowed.contains(resp.StatusCode) {
+		return checkFailed("HTTP", addr, start, ReasonBadStatus,
+			"unexpected response code %d", resp.StatusCode), nil
 	}
 
 	// check response body
 	if len(c.response) == 0 {
-		glog.V(9).Infof("HTTP check %v %v: succeed", addr, types.Healthy)
-		return types.Healthy, nil
+		return checkLatency("HTTP", addr, checkSucceed("HTTP", addr, start), latency, c.maxLatency), nil
 	}
 
 	if resp.Body != nil {
 		buf := make([]byte, len(c.response))
 		n, err := io.ReadFull(resp.Body, buf)
-		if err != nil && err != io.ErrUnexpectedEOF {
-			glog.V(9).Infof("HTTP check %v %v: failed to read response", addr, types.Unhealthy)
-			return types.Unhealthy, nil
+		if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+			return checkFailed("HTTP", addr, start, errReason(err, false),
+				"failed to read response: %v", err), nil
+		}
+		if !bytes.Equal(buf[:n], c.response) {
+			return checkFailed("HTTP", addr, start, ReasonPayloadMismatch,
+				"unexpected response - %q", string(buf[:n])), nil
 		}
-		if !bytes.Equal(buf, c.response) {
-			glog.V(9).Infof("HTTP check %v %v: unexpected response - %q", addr,
-				types.Unhealthy, string(buf[:n]))
-			return types.Unhealthy, nil
+	}
+
+	return checkLatency("HTTP", addr, checkSucceed("HTTP", addr, start), latency, c.maxLatency), nil
+}
+
+// checkWebSocket checks the response of the websocket upgrade request with
+// `key`, and finishes the upgraded connection.
+func (c *HTTPChecker) checkWebSocket(resp *http.Response, key, addr string,
+	start time.Time) *CheckResult {
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		return checkFailed("HTTP", addr, start, ReasonBadStatus,
+			"unexpected response code %d, want %d", resp.StatusCode, http.StatusSwitchingProtocols)
+	}
+	if err := websocket.CheckUpgrade(resp.Header, key); err != nil {
+		return checkFailed("HTTP", addr, start, ReasonProtocolError, "%v", err)
+	}
+	conn, ok := resp.Body.(io.ReadWriter)
+	if !ok {
+		return checkFailed("HTTP", addr, start, ReasonProtocolError,
+			"upgraded connection not writable")
+	}
+	if res := websocketFinish("HTTP", conn, conn, addr, start, c.wsPing); res != nil {
+		return res
+	}
+	return checkSucceed("HTTP", addr, start)
+}
+
+// http3Transport returns the HTTP/3 transport over QUIC of the check started at
+// `start` with `deadline`, whose UDP sockets are created in the netns of the
+// checker, and `connected` is called once a QUIC connection is established.
+// The returned func closes the transport along with its sockets.
+func (c *HTTPChecker) http3Transport(tlsConfig *tls.Config, start, deadline time.Time,
+	connected func()) (*http3.Transport, func()) {
+	var lock sync.Mutex
+	var cleanups []func()
+	tr := &http3.Transport{
+		TLSClientConfig: tlsConfig,
+		Dial: func(ctx context.Context, addr string, tlsCfg *tls.Config,
+			cfg *quic.Config) (quic.EarlyConnection, error) {
+			raddr, err := net.ResolveUDPAddr("udp", addr)
+			if err != nil {
+				return nil, err
+			}
+			pconn, err := netnsListenPacket(c.netns, "udp", ":0")
+			if err != nil {
+				return nil, err
+			}
+			dialCtx, cancel := context.WithTimeout(ctx, c.timeouts.dialTimeout(start, deadline))
+			defer cancel()
+			conn, err := quic.DialEarly(dialCtx, pconn, raddr, tlsCfg, cfg)
+			if err != nil {
+				pconn.Close()
+				return nil, err
+			}
+			connected()
+			cleanup := func() { pconn.Close() }
+			if c.timeouts.read > 0 {
+				// QUIC connections have no I/O deadline, close it at the deadline instead.
+				readDeadline := c.timeouts.readDeadline(start, time.Now(), deadline)
+				timer := time.AfterFunc(time.Until(readDeadline), func() {
+					conn.CloseWithError(quic.ApplicationErrorCode(http3.ErrCodeNoError), http3ReadTimeout)
+				})
+				cleanup = func() {
+					timer.Stop()
+					pconn.Close()
+				}
+			}
+			lock.Lock()
+			cleanups = append(cleanups, cleanup)
+			lock.Unlock()
+			return conn, nil
+		},
+	}
+	return tr, func() {
+		tr.Close()
+		lock.Lock()
+		defer lock.Unlock()
+		for _, cleanup := range cleanups {
+			cleanup()
 		}
 	}
+}
 
-	glog.V(9).Infof("HTTP check %v %v: succeed", addr, types.Healthy)
-	return types.Healthy, nil
+// versionMajor returns the major number of the HTTP version of the checker.
+func (c *HTTPChecker) versionMajor() int {
+	switch c.version {
+	case httpVersion2, httpVersion2C:
+		return 2
+	case httpVersion3:
+		return 3
+	}
+	return 1
+}
+
+func (c *HTTPChecker) DefaultParams() map[string]string {
+	return map[string]string{
+		"method":            "GET",
+		"host":              "",
+		"uri":               "/",
+		"https":             "false",
+		"tls-verify":        "true",
+		"sni-host":          "",
+		ParamCAFile:         "",
+		ParamCertFile:       "",
+		ParamKeyFile:        "",
+		"proxy":             "false",
+		ParamProxyProto:     "",
+		"follow-redirects":  "false",
+		"max-redirects":     strconv.Itoa(httpDefaultMaxRedirects),
+		"http-version":      httpVersion11,
+		"strict-version":    "false",
+		ParamQuic:           "",
+		"header":            "",
+		"request-headers":   "",
+		"body":              "",
+		"request":           "",
+		"content-type":      "",
+		"status":            "",
+		"response-codes":    "200-299,300-399,400-499",
+		"response":          "",
+		ParamConnectTimeout: "",
+		ParamReadTimeout:    "",
+		"websocket":         "false",
+		"ws-ping":           "false",
+		ParamNetns:          "",
+		ParamMaxLatency:     "",
+	}
 }
 
 func (c *HTTPChecker) validate(params map[string]string) error {
+	_, err := c.parse(params)
+	return err
+}
+
+// parse validates params, and returns the TLS files loaded.
+func (c *HTTPChecker) parse(params map[string]string) (*tlsFiles, error) {
 	unsupported := make([]string, 0, len(params))
 	for param, val := range params {
 		switch param {
 		case "method":
-			if _, ok := httpAllowddMethod[val]; !ok {
-				return fmt.Errorf("unsupported http method: %s", val)
+			if _, ok := httpAllowddMethod[strings.ToUpper(val)]; !ok {
+				return nil, fmt.Errorf("unsupported http method: %s", val)
 			}
 		case "host":
 			if len(val) == 0 {
-				return fmt.Errorf("empty http checker param: %s", param)
+				return nil, fmt.Errorf("empty http checker param: %s", param)
 			}
 		case "uri":
 			if len(val) == 0 {
-				return fmt.Errorf("empty http checker param: %s", param)
+				return nil, fmt.Errorf("empty http checker param: %s", param)
 			}
 		case "https":
 			if _, err := utils.String2bool(val); err != nil {
-				return fmt.Errorf("invalid http checker param %s:%s", param, params[param])
+				return nil, fmt.Errorf("invalid http checker param %s:%s", param, params[param])
 			}
 		case "tls-verify":
 			if _, err := utils.String2bool(val); err != nil {
-				return fmt.Errorf("invalid http checker param %s:%s", param, params[param])
+				return nil, fmt.Errorf("invalid http checker param %s:%s", param, params[param])
 			}
-		case "proxy":
+		case "sni-host", ParamCAFile, ParamCertFile, ParamKeyFile:
+			if len(val) == 0 {
+				return nil, fmt.Errorf("empty http checker param: %s", param)
+			}
+		case "proxy", "websocket", "ws-ping":
 			if _, err := utils.String2bool(val); err != nil {
-				return fmt.Errorf("invalid http checker param %s:%s", param, params[param])
+				return nil, fmt.Errorf("invalid http checker param %s:%s", param, params[param])
 			}
 		case ParamProxyProto:
 			val = strings.ToLower(val)
 			if val != "v1" && val != "v2" {
-				return fmt.Errorf("invalid http checker param %s:%s", param, params[param])
+				return nil, fmt.Errorf("invalid http checker param %s:%s", param, params[param])
+			}
+		case "follow-redirects":
+			if _, err := utils.String2bool(val); err != nil {
+				return nil, fmt.Errorf("invalid http checker param %s:%s", param, params[param])
+			}
+		case "max-redirects":
+			if n, err := strconv.Atoi(val); err != nil || n < 0 {
+				return nil, fmt.Errorf("invalid http checker param %s:%s", param, params[param])
+			}
+		case "http-version":
+			switch strings.ToLower(val) {
+			case httpVersion11, httpVersion2, httpVersion2C, httpVersion3:
+			default:
+				return nil, fmt.Errorf("invalid http checker param %s:%s", param, val)
+			}
+		case "strict-version":
+			if _, err := utils.String2bool(val); err != nil {
+				return nil, fmt.Errorf("invalid http checker param %s:%s", param, params[param])
+			}
+		case ParamQuic:
+			if _, err := utils.String2bool(val); err != nil {
+				return nil, fmt.Errorf("invalid http checker param %s:%s", param, params[param])
 			}
 		case "request-headers":
 			if _, err := parseHttpHeaderParam(val); err != nil {
-				return fmt.Errorf("invalid http checker param %s:%s", param, val)
+				return nil, fmt.Errorf("invalid http checker param %s:%s", param, val)
+			}
+		case "header":
+			if _, err := parseHttpHeaderListParam(val); err != nil {
+				return nil, fmt.Errorf("invalid http checker param %s:%s, %v", param, val, err)
 			}
-		case "request":
+		case "body", "request", "content-type":
 			if len(val) == 0 {
-				return fmt.Errorf("empty http checker param: %s", param)
+				return nil, fmt.Errorf("empty http checker param: %s", param)
 			}
 		case "response-codes":
 			if _, err := parseHttpCodesParam(val); err != nil {
-				return fmt.Errorf("invalid http checker response codes %s: %v", val, err)
+				return nil, fmt.Errorf("invalid http checker response codes %s: %v", val, err)
+			}
+		case "status":
+			ranges, err := parseHttpCodesParam(val)
+			if err != nil {
+				return nil, fmt.Errorf("invalid http checker status %s: %v", val, err)
+			}
+			for _, r := range ranges {
+				if r.Start < httpMinStatus || r.End > httpMaxStatus {
+					return nil, fmt.Errorf("invalid http checker status %s: code out of %d-%d",
+						val, httpMinStatus, httpMaxStatus)
+				}
 			}
 		case "response":
 			if len(val) == 0 {
-				return fmt.Errorf("empty http checker param: %s", param)
+				return nil, fmt.Errorf("empty http checker param: %s", param)
+			}
+		case ParamConnectTimeout, ParamReadTimeout:
+			var timeouts phaseTimeouts
+			if _, err := timeouts.set(param, val); err != nil {
+				return nil, fmt.Errorf("invalid http checker param %s:%s, %v", param, val, err)
+			}
+		case ParamNetns:
+			if err := utils.ValidateNetns(val); err != nil {
+				return nil, fmt.Errorf("invalid http checker param %s:%s, %v", param, val, err)
+			}
+		case ParamMaxLatency:
+			if _, err := parsePhaseTimeout(val); err != nil {
+				return nil, fmt.Errorf("invalid http checker param %s:%s, %v", param, val, err)
 			}
 		default:
+			if httpNumberedHeaderParam.MatchString(param) {
+				if _, err := parseHttpHeader(val); err != nil {
+					return nil, fmt.Errorf("invalid http checker param %s:%s, %v", param, val, err)
+				}
+				continue
+			}
 			unsupported = append(unsupported, param)
 		}
 	}
 
 	if len(unsupported) > 0 {
-		return fmt.Errorf("unsupported http checker params: %q", strings.Join(unsupported, ","))
+		return nil, fmt.Errorf("unsupported http checker params: %q", strings.Join(unsupported, ","))
+	}
+
+	if _, ok := params["body"]; ok {
+		if _, ok := params["request"]; ok {
+			return nil, fmt.Errorf("http checker param body conflicts with request")
+		}
+	}
+	if _, ok := params["response"]; ok && strings.ToUpper(params["method"]) == "HEAD" {
+		return nil, fmt.Errorf("http checker param response conflicts with HEAD method")
+	}
+
+	version := httpParamVersion(params)
+	if ws, _ := utils.String2bool(params["websocket"]); ws {
+		if method, ok := params["method"]; ok && strings.ToUpper(method) != "GET" {
+			return nil, fmt.Errorf("http checker param websocket conflicts with %s method", method)
+		}
+		if version != httpVersion11 {
+			return nil, fmt.Errorf("http checker param websocket conflicts with http-version %s", version)
+		}
+		for _, param := range []string{"body", "request", "response", "status", "response-codes"} {
+			if _, ok := params[param]; ok {
+				return nil, fmt.Errorf("http checker param websocket conflicts with %s", param)
+			}
+		}
+	} else if ping, _ := utils.String2bool(params["ws-ping"]); ping {
+		return nil, fmt.Errorf("http checker param ws-ping requires websocket")
+	}
+
+	files, err := loadTLSFiles(params)
+	if err != nil {
+		return nil, fmt.Errorf("invalid http checker tls files: %v", err)
+	}
+
+	https, _ := utils.String2bool(params["https"])
+	https = https || strings.HasPrefix(params["uri"], "https://")
+	switch version {
+	case httpVersion2:
+		if !https {
+			return nil, fmt.Errorf("http-version %s requires https, or use %s for cleartext",
+				httpVersion2, httpVersion2C)
+		}
+	case httpVersion2C:
+		if https {
+			return nil, fmt.Errorf("http-version %s conflicts with https", httpVersion2C)
+		}
+		if files != nil {
+			return nil, fmt.Errorf("http-version %s conflicts with tls files", httpVersion2C)
+		}
+		if proxy, _ := utils.String2bool(params["proxy"]); proxy {
+			return nil, fmt.Errorf("http-version %s conflicts with proxy", httpVersion2C)
+		}
+	case httpVersion3:
+		if _, ok := params["https"]; (ok && !https) || strings.HasPrefix(params["uri"], "http://") {
+			return nil, fmt.Errorf("http-version %s conflicts with cleartext http", httpVersion3)
+		}
+		if proxy, _ := utils.String2bool(params["proxy"]); proxy {
+			return nil, fmt.Errorf("http-version %s conflicts with proxy", httpVersion3)
+		}
+		if _, ok := params[ParamProxyProto]; ok {
+			return nil, fmt.Errorf("http-version %s conflicts with %s", httpVersion3, ParamProxyProto)
+		}
+	}
+	return files, nil
+}
+
+// httpParamVersion returns the HTTP version given by params, which defaults to
+// HTTP/3 for quic services, and HTTP/1.1 for others.
+func httpParamVersion(params map[string]string) string {
+	if val, ok := params["http-version"]; ok {
+		return strings.ToLower(val)
+	}
+	if quic, _ := utils.String2bool(params[ParamQuic]); quic {
+		return httpVersion3
 	}
-	return nil
+	return httpVersion11
 }
 
 func (c *HTTPChecker) create(params map[string]string) (CheckMethod, error) {
-	if err := c.validate(params); err != nil {
+	files, err := c.parse(params)
+	if err != nil {
 		return nil, fmt.Errorf("http checker param validation failed: %v", err)
 	}
 
@@ -300,11 +780,13 @@ func (c *HTTPChecker) create(params map[string]string) (CheckMethod, error) {
 		https:                false,
 		tlsVerify:            true,
 		proxy:                false,
-		responseCodesAllowed: []HttpCodeRange{{200, 299}, {300, 399}, {400, 499}},
+		maxRedirects:         httpDefaultMaxRedirects,
+		version:              httpVersion11,
+		responseCodesAllowed: newHttpStatusSet([]HttpCodeRange{{200, 299}, {300, 399}, {400, 499}}),
 	}
 
 	if val, ok := params["method"]; ok {
-		checker.method = val
+		checker.method = strings.ToUpper(val)
 	}
 
 	if val, ok := params["host"]; ok {
@@ -323,6 +805,12 @@ func (c *HTTPChecker) create(params map[string]string) (CheckMethod, error) {
 		checker.tlsVerify, _ = utils.String2bool(val)
 	}
 
+	if val, ok := params["sni-host"]; ok {
+		checker.sniHost = val
+	}
+
+	checker.tlsFiles = files
+
 	if val, ok := params["proxy"]; ok {
 		checker.proxy, _ = utils.String2bool(val)
 	}
@@ -331,22 +819,93 @@ func (c *HTTPChecker) create(params map[string]string) (CheckMethod, error) {
 		checker.proxyProtocol = strings.ToLower(val)
 	}
 
+	if val, ok := params["follow-redirects"]; ok {
+		checker.followRedirects, _ = utils.String2bool(val)
+	}
+
+	if val, ok := params["max-redirects"]; ok {
+		checker.maxRedirects, _ = strconv.Atoi(val)
+	}
+
+	checker.version = httpParamVersion(params)
+
+	if val, ok := params["strict-version"]; ok {
+		checker.strictVersion, _ = utils.String2bool(val)
+	}
+
 	if val, ok := params["request-headers"]; ok {
-		checker.requestHeaders, _ = parseHttpHeaderParam(val)
+		legacy, _ := parseHttpHeaderParam(val)
+		names := make([]string, 0, len(legacy))
+		for name := range legacy {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			checker.requestHeaders = append(checker.requestHeaders, httpHeader{name, legacy[name]})
+		}
+	}
+
+	if val, ok := params["header"]; ok {
+		hdrs, _ := parseHttpHeaderListParam(val)
+		checker.requestHeaders = append(checker.requestHeaders, hdrs...)
+	}
+
+	numbered := make([]int, 0)
+	for param := range params {
+		if m := httpNumberedHeaderParam.FindStringSubmatch(param); m != nil {
+			n, _ := strconv.Atoi(m[1])
+			numbered = append(numbered, n)
+		}
+	}
+	sort.Ints(numbered)
+	for _, n := range numbered {
+		hdr, _ := parseHttpHeader(params["header"+strconv.Itoa(n)])
+		checker.requestHeaders = append(checker.requestHeaders, hdr)
 	}
 
 	if val, ok := params["request"]; ok {
 		checker.request = []byte(val)
 	}
 
-	if val, ok := params["response-codes"]; ok {
-		checker.responseCodesAllowed, _ = parseHttpCodesParam(val)
+	if val, ok := params["body"]; ok {
+		checker.request = []byte(val)
+	}
+
+	if val, ok := params["content-type"]; ok {
+		checker.requestHeaders = append(checker.requestHeaders, httpHeader{"Content-Type", val})
+	}
+
+	if val, ok := params["status"]; ok {
+		ranges, _ := parseHttpCodesParam(val)
+		checker.responseCodesAllowed = newHttpStatusSet(ranges)
+	} else if val, ok := params["response-codes"]; ok {
+		ranges, _ := parseHttpCodesParam(val)
+		checker.responseCodesAllowed = newHttpStatusSet(ranges)
 	}
 
 	if val, ok := params["response"]; ok {
 		checker.response = []byte(val)
 	}
 
+	if val, ok := params[ParamConnectTimeout]; ok {
+		checker.timeouts.set(ParamConnectTimeout, val)
+	}
+	if val, ok := params[ParamReadTimeout]; ok {
+		checker.timeouts.set(ParamReadTimeout, val)
+	}
+
+	if val, ok := params["websocket"]; ok {
+		checker.websocket, _ = utils.String2bool(val)
+	}
+	if val, ok := params["ws-ping"]; ok {
+		checker.wsPing, _ = utils.String2bool(val)
+	}
+
+	checker.netns = params[ParamNetns]
+	if val, ok := params[ParamMaxLatency]; ok {
+		checker.maxLatency, _ = parsePhaseTimeout(val)
+	}
+
 	return checker, nil
 }
 
@@ -369,6 +928,38 @@ func parseHttpHeaderParam(headers string) (map[string]string, error) {
 	return parsed, nil
 }
 
+// parseHttpHeader parses a request header in the form of "Name: value".
+func parseHttpHeader(header string) (httpHeader, error) {
+	name, value, ok := strings.Cut(header, ":")
+	if !ok {
+		return httpHeader{}, fmt.Errorf("missing colon in http header %q", header)
+	}
+	name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+	if !httpguts.ValidHeaderFieldName(name) {
+		return httpHeader{}, fmt.Errorf("invalid http header name %q", name)
+	}
+	if !httpguts.ValidHeaderFieldValue(value) {
+		return httpHeader{}, fmt.Errorf("invalid http header value %q", value)
+	}
+	return httpHeader{name, value}, nil
+}
+
+// parseHttpHeaderListParam parses comma separated request headers in the form
+// of "Name: value,Name: value". Use the numbered header params for the header
+// values containing commas.
+func parseHttpHeaderListParam(headers string) ([]httpHeader, error) {
+	parts := strings.Split(headers, ",")
+	parsed := make([]httpHeader, 0, len(parts))
+	for _, part := range parts {
+		hdr, err := parseHttpHeader(part)
+		if err != nil {
+			return nil, err
+		}
+		parsed = append(parsed, hdr)
+	}
+	return parsed, nil
+}
+
 func parseHttpCodesParam(codes string) ([]HttpCodeRange, error) {
 	parts := strings.Split(codes, ",")
 	result := make([]HttpCodeRange, 0, len(parts))
@@ -17,27 +17,39 @@
 package checker
 
 import (
+	"bytes"
+	"crypto/tls"
+	"io"
 	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/types"
 	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/utils"
+	"github.com/quic-go/quic-go/http3"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 )
 
 var http_targets = []utils.L3L4Addr{
-	{net.ParseIP("192.168.88.30"), 80, utils.IPProtoTCP},
-	{net.ParseIP("192.168.88.30"), 443, utils.IPProtoTCP},
-	{net.ParseIP("2001::30"), 80, utils.IPProtoTCP},
-	{net.ParseIP("2001::30"), 443, utils.IPProtoTCP},
+	{IP: net.ParseIP("192.168.88.30"), Port: 80, Proto: utils.IPProtoTCP},
+	{IP: net.ParseIP("192.168.88.30"), Port: 443, Proto: utils.IPProtoTCP},
+	{IP: net.ParseIP("2001::30"), Port: 80, Proto: utils.IPProtoTCP},
+	{IP: net.ParseIP("2001::30"), Port: 443, Proto: utils.IPProtoTCP},
 
 	// control group of proxy protocol
-	{net.ParseIP("192.168.88.30"), 8002, utils.IPProtoTCP},
-	{net.ParseIP("2001::30"), 8002, utils.IPProtoTCP},
+	{IP: net.ParseIP("192.168.88.30"), Port: 8002, Proto: utils.IPProtoTCP},
+	{IP: net.ParseIP("2001::30"), Port: 8002, Proto: utils.IPProtoTCP},
 }
 
 var http_proxy_proto_targets = []utils.L3L4Addr{
-	{net.ParseIP("192.168.88.30"), 8002, utils.IPProtoTCP},
-	{net.ParseIP("2001::30"), 8002, utils.IPProtoTCP},
+	{IP: net.ParseIP("192.168.88.30"), Port: 8002, Proto: utils.IPProtoTCP},
+	{IP: net.ParseIP("2001::30"), Port: 8002, Proto: utils.IPProtoTCP},
 }
 
 var http_url_targets = []string{
@@ -119,3 +131,579 @@ func TestHttpChecker(t *testing.T) {
 		}
 	}
 }
+
+func TestHttpCheckerRedirects(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+	mux.HandleFunc("/redirect", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/ok", http.StatusFound)
+	})
+	mux.HandleFunc("/auth", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/login", http.StatusFound)
+	})
+	mux.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	})
+	mux.HandleFunc("/loop", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/loop", http.StatusFound)
+	})
+	mux.HandleFunc("/vhost", func(w http.ResponseWriter, r *http.Request) {
+		if r.Host != "www.example.com" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		http.Redirect(w, r, "/vhost/ok", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/vhost/ok", func(w http.ResponseWriter, r *http.Request) {
+		if r.Host != "www.example.com" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte("ok"))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	port := server.Listener.Addr().(*net.TCPAddr).Port
+	target := &utils.L3L4Addr{IP: net.ParseIP("127.0.0.1"), Port: uint16(port), Proto: utils.IPProtoTCP}
+
+	for _, tc := range []struct {
+		name   string
+		params map[string]string
+		expect types.State
+	}{
+		{"redirect as success", map[string]string{"uri": "/redirect", "response-codes": "200-399"}, types.Healthy},
+		{"redirect as failure", map[string]string{"uri": "/redirect", "response-codes": "200"}, types.Unhealthy},
+		{"redirect exact code", map[string]string{"uri": "/auth", "response-codes": "302"}, types.Healthy},
+		{"follow", map[string]string{"uri": "/redirect", "response-codes": "200",
+			"follow-redirects": "true", "response": "ok"}, types.Healthy},
+		{"follow to failure", map[string]string{"uri": "/auth", "response-codes": "200-399",
+			"follow-redirects": "yes"}, types.Unhealthy},
+		{"follow loop", map[string]string{"uri": "/loop", "response-codes": "200-399",
+			"follow-redirects": "true", "max-redirects": "3"}, types.Unhealthy},
+		{"max redirects", map[string]string{"uri": "/redirect", "response-codes": "200",
+			"follow-redirects": "true", "max-redirects": "1"}, types.Healthy},
+		{"zero redirects", map[string]string{"uri": "/redirect", "response-codes": "200-399",
+			"follow-redirects": "true", "max-redirects": "0"}, types.Unhealthy},
+		{"host header", map[string]string{"uri": "/vhost/ok", "host": "www.example.com",
+			"response-codes": "200"}, types.Healthy},
+		{"wrong host header", map[string]string{"uri": "/vhost/ok", "response-codes": "200"}, types.Unhealthy},
+		{"host header follow", map[string]string{"uri": "/vhost", "host": "www.example.com",
+			"response-codes": "200", "follow-redirects": "true", "response": "ok"}, types.Healthy},
+	} {
+		checker, err := (&HTTPChecker{}).create(tc.params)
+		if err != nil {
+			t.Fatalf("%s: failed to create http checker: %v", tc.name, err)
+		}
+		state, err := checker.Check(target, time.Second)
+		if err != nil {
+			t.Errorf("%s: failed to execute http checker: %v", tc.name, err)
+		} else if state != tc.expect {
+			t.Errorf("%s: expect %v, got %v", tc.name, tc.expect, state)
+		}
+	}
+
+	for _, params := range []map[string]string{
+		{"follow-redirects": "maybe"},
+		{"max-redirects": "-1"},
+		{"max-redirects": "many"},
+	} {
+		if _, err := (&HTTPChecker{}).create(params); err == nil {
+			t.Errorf("expect %v invalid", params)
+		}
+	}
+}
+
+func TestHttpCheckerStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		code, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/"))
+		if err != nil {
+			code = http.StatusBadRequest
+		}
+		w.WriteHeader(code)
+	}))
+	defer server.Close()
+	port := server.Listener.Addr().(*net.TCPAddr).Port
+	target := &utils.L3L4Addr{IP: net.ParseIP("127.0.0.1"), Port: uint16(port), Proto: utils.IPProtoTCP}
+
+	for _, tc := range []struct {
+		params map[string]string
+		codes  map[int]types.State
+	}{
+		{map[string]string{},
+			map[int]types.State{200: types.Healthy, 302: types.Healthy, 404: types.Healthy, 503: types.Unhealthy}},
+		{map[string]string{"status": "200,204,301"},
+			map[int]types.State{200: types.Healthy, 204: types.Healthy, 301: types.Healthy,
+				201: types.Unhealthy, 302: types.Unhealthy}},
+		{map[string]string{"status": "200-299, 404"},
+			map[int]types.State{200: types.Healthy, 299: types.Healthy, 404: types.Healthy,
+				300: types.Unhealthy, 403: types.Unhealthy}},
+		{map[string]string{"status": "503", "response-codes": "200"},
+			map[int]types.State{503: types.Healthy, 200: types.Unhealthy}},
+		{map[string]string{"response-codes": "0-999"},
+			map[int]types.State{200: types.Healthy, 599: types.Healthy}},
+	} {
+		for code, expect := range tc.codes {
+			tc.params["uri"] = "/" + strconv.Itoa(code)
+			checker, err := (&HTTPChecker{}).create(tc.params)
+			if err != nil {
+				t.Fatalf("%v: failed to create http checker: %v", tc.params, err)
+			}
+			if state, err := checker.Check(target, time.Second); err != nil || state != expect {
+				t.Errorf("%v: code %d expect %v, got %v, %v", tc.params, code, expect, state, err)
+			}
+		}
+	}
+
+	for _, status := range []string{"", "abc", "200,", "200-", "-200", "300-200", "200-299-399",
+		"99", "200,1000", "0-999"} {
+		if _, err := (&HTTPChecker{}).create(map[string]string{"status": status}); err == nil {
+			t.Errorf("invalid status %q accepted", status)
+		}
+	}
+
+	set := newHttpStatusSet([]HttpCodeRange{{200, 204}, {404, 404}, {900, 2000}})
+	for code, expect := range map[int]bool{-1: false, 0: false, 199: false, 200: true, 204: true,
+		205: false, 404: true, 999: true, 1000: false} {
+		if set.contains(code) != expect {
+			t.Errorf("status set: code %d expect %v", code, expect)
+		}
+	}
+}
+
+func TestHttpCheckerVersion(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/hang" {
+			<-r.Context().Done()
+			return
+		}
+		w.Write([]byte(r.Proto))
+	})
+
+	h1 := httptest.NewServer(handler)
+	defer h1.Close()
+	h1tls := httptest.NewTLSServer(handler)
+	defer h1tls.Close()
+	h2 := httptest.NewUnstartedServer(handler)
+	h2.EnableHTTP2 = true
+	h2.StartTLS()
+	defer h2.Close()
+	h2clear := httptest.NewServer(h2c.NewHandler(handler, &http2.Server{}))
+	defer h2clear.Close()
+
+	targetOf := func(server *httptest.Server) *utils.L3L4Addr {
+		port := server.Listener.Addr().(*net.TCPAddr).Port
+		return &utils.L3L4Addr{IP: net.ParseIP("127.0.0.1"), Port: uint16(port), Proto: utils.IPProtoTCP}
+	}
+
+	for _, tc := range []struct {
+		name   string
+		server *httptest.Server
+		params map[string]string
+		expect types.State
+	}{
+		{"http/1.1", h1, map[string]string{"strict-version": "true", "response": "HTTP/1.1"}, types.Healthy},
+		{"http/1.1 over tls", h2, map[string]string{"https": "true", "tls-verify": "false",
+			"http-version": "1.1", "strict-version": "true", "response": "HTTP/1.1"}, types.Healthy},
+		{"http/2", h2, map[string]string{"https": "true", "tls-verify": "false",
+			"http-version": "2", "strict-version": "true", "response": "HTTP/2.0"}, types.Healthy},
+		{"http/2 downgraded", h1tls, map[string]string{"https": "true", "tls-verify": "false",
+			"http-version": "2", "response": "HTTP/1.1"}, types.Healthy},
+		{"http/2 downgraded strictly", h1tls, map[string]string{"https": "true", "tls-verify": "false",
+			"http-version": "2", "strict-version": "true"}, types.Unhealthy},
+		{"h2c", h2clear, map[string]string{"http-version": "2c", "strict-version": "true",
+			"response": "HTTP/2.0"}, types.Healthy},
+		{"h2c to http/1.1 server", h1, map[string]string{"http-version": "2c"}, types.Unhealthy},
+		{"h2c timeout", h2clear, map[string]string{"http-version": "2c", "uri": "/hang"}, types.Unhealthy},
+		{"h2c with quic", h2clear, map[string]string{"http-version": "2C", ParamQuic: "true"}, types.Healthy},
+	} {
+		checker, err := (&HTTPChecker{}).create(tc.params)
+		if err != nil {
+			t.Fatalf("%s: failed to create http checker: %v", tc.name, err)
+		}
+		start := time.Now()
+		res, err := CheckExTimeout(checker, targetOf(tc.server), 500*time.Millisecond)
+		if err != nil {
+			t.Errorf("%s: failed to execute http checker: %v", tc.name, err)
+			continue
+		}
+		if res.State != tc.expect {
+			t.Errorf("%s: expect %v, got %v, %s", tc.name, tc.expect, res.State, res.Detail)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("%s: check not return in time: %v", tc.name, elapsed)
+		}
+	}
+
+	for _, params := range []map[string]string{
+		{"http-version": "1.0"},
+		{"http-version": "2"},
+		{"http-version": "2", "https": "false", "uri": "http://www.example.com/"},
+		{"http-version": "3", "https": "false"},
+		{"http-version": "3", "uri": "http://www.example.com/"},
+		{"http-version": "3", "proxy": "true"},
+		{"http-version": "3", ParamProxyProto: "v2"},
+		{ParamQuic: "true", "websocket": "true"},
+		{"strict-version": "maybe"},
+		{ParamQuic: "sure"},
+		{"http-version": "2c", "https": "true"},
+		{"http-version": "2c", "uri": "https://www.example.com/"},
+		{"http-version": "2c", "proxy": "true"},
+	} {
+		if _, err := (&HTTPChecker{}).create(params); err == nil {
+			t.Errorf("expect %v invalid", params)
+		}
+	}
+}
+
+func TestHttpCheckerHTTP3(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/hang" {
+			<-r.Context().Done()
+			return
+		}
+		w.Write([]byte(r.Proto))
+	})
+	// Borrow the self-signed certificate of httptest.
+	h1tls := httptest.NewTLSServer(handler)
+	h1tls.Close()
+
+	pconn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen udp: %v", err)
+	}
+	server := &http3.Server{
+		Handler:   handler,
+		TLSConfig: http3.ConfigureTLSConfig(&tls.Config{Certificates: h1tls.TLS.Certificates}),
+	}
+	go server.Serve(pconn)
+	defer server.Close()
+	port := uint16(pconn.LocalAddr().(*net.UDPAddr).Port)
+	target := &utils.L3L4Addr{IP: net.ParseIP("127.0.0.1"), Port: port, Proto: utils.IPProtoUDP}
+
+	// A UDP port never responding.
+	silent, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen udp: %v", err)
+	}
+	defer silent.Close()
+	silentTarget := &utils.L3L4Addr{IP: net.ParseIP("127.0.0.1"),
+		Port: uint16(silent.LocalAddr().(*net.UDPAddr).Port), Proto: utils.IPProtoUDP}
+
+	for _, tc := range []struct {
+		name   string
+		target *utils.L3L4Addr
+		params map[string]string
+		expect types.State
+		reason Reason
+	}{
+		{"http/3", target, map[string]string{"http-version": "3", "tls-verify": "false",
+			"strict-version": "true", "response": "HTTP/3.0"}, types.Healthy, ReasonNone},
+		{"http/3 for quic", target, map[string]string{ParamQuic: "true", "tls-verify": "false",
+			"strict-version": "true", "https": "true"}, types.Healthy, ReasonNone},
+		{"http/3 unverified", target, map[string]string{"http-version": "3"},
+			types.Unhealthy, ReasonTLSFailure},
+		{"http/3 timeout", target, map[string]string{"http-version": "3", "tls-verify": "false",
+			"uri": "/hang"}, types.Unhealthy, ReasonTimeout},
+		{"http/3 read timeout", target, map[string]string{"http-version": "3", "tls-verify": "false",
+			"uri": "/hang", ParamReadTimeout: "100ms"}, types.Unhealthy, ReasonTimeout},
+		{"http/3 no response", silentTarget, map[string]string{"http-version": "3",
+			ParamConnectTimeout: "100ms"}, types.Unhealthy, ReasonDialTimeout},
+	} {
+		checker, err := (&HTTPChecker{}).create(tc.params)
+		if err != nil {
+			t.Fatalf("%s: failed to create http checker: %v", tc.name, err)
+		}
+		start := time.Now()
+		res, err := CheckExTimeout(checker, tc.target, 500*time.Millisecond)
+		if err != nil {
+			t.Errorf("%s: failed to execute http checker: %v", tc.name, err)
+			continue
+		}
+		if res.State != tc.expect || res.Reason != tc.reason {
+			t.Errorf("%s: expect %v(%v), got %v(%v), %s", tc.name, tc.expect, tc.reason,
+				res.State, res.Reason, res.Detail)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("%s: check not return in time: %v", tc.name, elapsed)
+		}
+	}
+}
+
+// proxyProtoListener accepts connections prefixed with `prefix` only, and
+// strips the prefix before serving.
+type proxyProtoListener struct {
+	net.Listener
+	prefix []byte
+}
+
+func (l *proxyProtoListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, len(l.prefix))
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		if _, err := io.ReadFull(conn, buf); err != nil || !bytes.Equal(buf, l.prefix) {
+			conn.Close()
+			continue
+		}
+		conn.SetReadDeadline(time.Time{})
+		return conn, nil
+	}
+}
+
+func TestHttpCheckerRequest(t *testing.T) {
+	type seenRequest struct {
+		method string
+		uri    string
+		host   string
+		header http.Header
+		body   string
+	}
+	seen := make(chan seenRequest, 1)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		select {
+		case seen <- seenRequest{r.Method, r.RequestURI, r.Host, r.Header.Clone(), string(body)}:
+		default:
+		}
+		if r.Method == "HEAD" {
+			// The response body is never sent, and the checker must not wait for it.
+			w.Header().Set("Content-Length", "1024")
+			w.WriteHeader(http.StatusOK)
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+			return
+		}
+		w.Write([]byte("ok"))
+	})
+
+	plain := httptest.NewServer(handler)
+	defer plain.Close()
+	servers := map[string]*httptest.Server{"": plain}
+	for _, pp := range []struct {
+		version string
+		prefix  []byte
+	}{
+		{"v1", []byte(proxyProtoV1LocalCmd)},
+		{"v2", proxyProtoV2LocalCmd},
+	} {
+		server := httptest.NewUnstartedServer(handler)
+		server.Listener = &proxyProtoListener{server.Listener, pp.prefix}
+		server.Start()
+		defer server.Close()
+		servers[pp.version] = server
+	}
+
+	// Headers added by the http client itself are not compared.
+	ignored := []string{"User-Agent", "Accept-Encoding", "Content-Length"}
+
+	for _, tc := range []struct {
+		name   string
+		params map[string]string
+		expect seenRequest
+	}{
+		{"default", map[string]string{},
+			seenRequest{method: "GET", uri: "/", header: http.Header{}}},
+		{"json post", map[string]string{
+			"method":       "POST",
+			"uri":          "/healthz?verbose=1",
+			"host":         "app.example.com",
+			"header":       "Authorization: Bearer t0ken,X-Probe: dpvs",
+			"body":         `{"check":"deep"}`,
+			"content-type": "application/json",
+		}, seenRequest{
+			method: "POST",
+			uri:    "/healthz?verbose=1",
+			host:   "app.example.com",
+			header: http.Header{
+				"X-Probe":       {"dpvs"},
+				"Content-Type":  {"application/json"},
+				"Authorization": {"Bearer t0ken"},
+			},
+			body: `{"check":"deep"}`,
+		}},
+		{"numbered headers", map[string]string{
+			"method":  "put",
+			"header":  "X-Multi: 1",
+			"header2": "Accept: text/plain, application/json",
+			"header1": "X-Multi: 2",
+			"request": "data",
+		}, seenRequest{
+			method: "PUT",
+			uri:    "/",
+			header: http.Header{
+				"Accept":  {"text/plain, application/json"},
+				"X-Multi": {"1", "2"},
+			},
+			body: "data",
+		}},
+		{"host header", map[string]string{"header": "Host: vhost.example.com"},
+			seenRequest{method: "GET", uri: "/", host: "vhost.example.com", header: http.Header{}}},
+		{"host param precedence", map[string]string{"header": "host: vhost.example.com", "host": "app.example.com"},
+			seenRequest{method: "GET", uri: "/", host: "app.example.com", header: http.Header{}}},
+		{"legacy request headers", map[string]string{"request-headers": "X-B::2;;X-A::1"},
+			seenRequest{method: "GET", uri: "/", header: http.Header{"X-A": {"1"}, "X-B": {"2"}}}},
+		{"head", map[string]string{"method": "HEAD", "header": "X-Probe: head"},
+			seenRequest{method: "HEAD", uri: "/", header: http.Header{"X-Probe": {"head"}}}},
+		{"proxy protocol v1", map[string]string{ParamProxyProto: "v1", "method": "POST", "body": "ppv1"},
+			seenRequest{method: "POST", uri: "/", header: http.Header{}, body: "ppv1"}},
+		{"proxy protocol v2", map[string]string{ParamProxyProto: "v2", "method": "POST", "body": "ppv2",
+			"header": "X-Probe: ppv2"},
+			seenRequest{method: "POST", uri: "/", header: http.Header{"X-Probe": {"ppv2"}}, body: "ppv2"}},
+	} {
+		server := servers[tc.params[ParamProxyProto]]
+		target := tcpTarget(server.Listener.Addr())
+		if len(tc.expect.host) == 0 {
+			tc.expect.host = target.Addr()
+		}
+
+		checker, err := (&HTTPChecker{}).create(tc.params)
+		if err != nil {
+			t.Fatalf("%s: failed to create http checker: %v", tc.name, err)
+		}
+		start := time.Now()
+		res, err := CheckExTimeout(checker, target, time.Second)
+		if err != nil {
+			t.Fatalf("%s: failed to execute http checker: %v", tc.name, err)
+		}
+		if res.State != types.Healthy {
+			t.Errorf("%s: expect healthy, got %v, %s", tc.name, res.State, res.Detail)
+		}
+		if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+			t.Errorf("%s: check took too long: %v", tc.name, elapsed)
+		}
+
+		var got seenRequest
+		select {
+		case got = <-seen:
+		case <-time.After(time.Second):
+			t.Fatalf("%s: no request seen by server", tc.name)
+		}
+		for _, name := range ignored {
+			got.header.Del(name)
+		}
+		if got.method != tc.expect.method || got.uri != tc.expect.uri ||
+			got.host != tc.expect.host || got.body != tc.expect.body {
+			t.Errorf("%s: expect request %+v, got %+v", tc.name, tc.expect, got)
+		}
+		if !reflect.DeepEqual(got.header, tc.expect.header) {
+			t.Errorf("%s: expect headers %v, got %v", tc.name, tc.expect.header, got.header)
+		}
+	}
+
+	for _, params := range []map[string]string{
+		{"method": "DELETE"},
+		{"header": "X-Probe"},
+		{"header": "X Probe: dpvs"},
+		{"header": "X-Probe: dpvs,"},
+		{"header": "Accept: text/plain, application/json"},
+		{"header1": "X-Probe: bad\r\nvalue"},
+		{"header1": ": dpvs"},
+		{"headerx": "X-Probe: dpvs"},
+		{"content-type": ""},
+		{"body": ""},
+		{"body": "a", "request": "b"},
+		{"method": "HEAD", "response": "ok"},
+	} {
+		if _, err := (&HTTPChecker{}).create(params); err == nil {
+			t.Errorf("expect %v invalid", params)
+		}
+	}
+}
+
+func TestHttpCheckerWebSocket(t *testing.T) {
+	timeout := 500 * time.Millisecond
+
+	newChecker := func(params map[string]string) CheckMethod {
+		t.Helper()
+		params["websocket"] = "true"
+		method, err := NewChecker(CheckMethodHTTP, nil, params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return method
+	}
+	start := func(s *fakeWebSocket) *utils.L3L4Addr {
+		ts := httptest.NewServer(s)
+		t.Cleanup(ts.Close)
+		return tcpTarget(ts.Listener.Addr())
+	}
+
+	target := start(&fakeWebSocket{})
+	expectResult(t, "upgraded", newChecker(map[string]string{}), target, timeout, types.Healthy, ReasonNone)
+	expectResult(t, "pong", newChecker(map[string]string{"ws-ping": "yes"}), target, timeout,
+		types.Healthy, ReasonNone)
+	plain, _ := NewChecker(CheckMethodHTTP, nil, map[string]string{"status": "200"})
+	expectResult(t, "plain get", plain, target, timeout, types.Unhealthy, ReasonBadStatus)
+
+	target = start(&fakeWebSocket{uri: "/ws?v=1", host: "ws.example.com", chatty: true})
+	expectResult(t, "uri, host and frames skipped", newChecker(map[string]string{"uri": "/ws?v=1",
+		"host": "ws.example.com", "ws-ping": "true"}), target, timeout, types.Healthy, ReasonNone)
+
+	target = start(&fakeWebSocket{status: http.StatusOK})
+	expectResult(t, "not upgraded", newChecker(map[string]string{}), target, timeout,
+		types.Unhealthy, ReasonBadStatus)
+
+	target = start(&fakeWebSocket{accept: "s3pPLMBiTxaQ9kYGzzhZRbK+xOo="})
+	expectResult(t, "bad accept", newChecker(map[string]string{}), target, timeout,
+		types.Unhealthy, ReasonProtocolError)
+
+	target = start(&fakeWebSocket{noPong: true})
+	expectResult(t, "no pong", newChecker(map[string]string{"ws-ping": "yes"}), target, timeout,
+		types.Unhealthy, ReasonTimeout)
+
+	target = start(&fakeWebSocket{closeCode: 1011})
+	expectResult(t, "closed", newChecker(map[string]string{"ws-ping": "yes"}), target, timeout,
+		types.Unhealthy, ReasonConnReset)
+
+	// https with sni-host
+	sni := make(chan string, 1)
+	tlsServer := httptest.NewUnstartedServer(&fakeWebSocket{})
+	tlsServer.TLS = &tls.Config{GetConfigForClient: func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+		select {
+		case sni <- hello.ServerName:
+		default:
+		}
+		return nil, nil
+	}}
+	tlsServer.StartTLS()
+	t.Cleanup(tlsServer.Close)
+	expectResult(t, "https", newChecker(map[string]string{"https": "true", "tls-verify": "false",
+		"sni-host": "ws.example.com", "ws-ping": "yes"}), tcpTarget(tlsServer.Listener.Addr()), timeout,
+		types.Healthy, ReasonNone)
+	select {
+	case name := <-sni:
+		if name != "ws.example.com" {
+			t.Errorf("unexpected tls server name %q", name)
+		}
+	default:
+		t.Errorf("no tls handshake seen by server")
+	}
+
+	// proxy protocol
+	ppServer := httptest.NewUnstartedServer(&fakeWebSocket{})
+	ppServer.Listener = &proxyProtoListener{ppServer.Listener, proxyProtoV2LocalCmd}
+	ppServer.Start()
+	t.Cleanup(ppServer.Close)
+	expectResult(t, "proxy protocol", newChecker(map[string]string{ParamProxyProto: "v2", "ws-ping": "yes"}),
+		tcpTarget(ppServer.Listener.Addr()), timeout, types.Healthy, ReasonNone)
+
+	for _, params := range []map[string]string{
+		{"websocket": "maybe"},
+		{"ws-ping": "true"},
+		{"websocket": "true", "method": "POST"},
+		{"websocket": "true", "http-version": "2"},
+		{"websocket": "true", "body": "hello"},
+		{"websocket": "true", "response": "ok"},
+		{"websocket": "true", "status": "200"},
+		{"sni-host": ""},
+	} {
+		if _, err := (&HTTPChecker{}).create(params); err == nil {
+			t.Errorf("expect %v invalid", params)
+		}
+	}
+}
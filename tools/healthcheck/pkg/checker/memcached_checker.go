@@ -0,0 +1,330 @@
+// /*
+// Copyright 2025 IQiYi Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// */
+
+package checker
+
+/*
+Memcached Checker Params:
+-----------------------------------
+name                value
+-----------------------------------
+key                 key for the set/get roundtrip
+value               value for the set/get roundtrip, default a timestamp
+prxoy-protocol      v1 | v2
+netns               network namespace name or path to check from
+------------------------------------
+
+The checker speaks the ASCII protocol. It issues a "version" command and
+expects a "VERSION ..." reply. If `key` is given, it stores `value` to the key
+with "set", and reads it back with "get" to verify the roundtrip. The key
+expires in memcachedKeyExpiry seconds.
+
+TODO:
+  Add supports for the binary protocol.
+*/
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/types"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/utils"
+)
+
+var _ CheckMethodEx = (*MemcachedChecker)(nil)
+
+const (
+	memcachedMaxKeyLen  = 250
+	memcachedMaxLineLen = 1024 // large enough for reply lines other than data
+	memcachedKeyExpiry  = 60   // in seconds
+)
+
+type MemcachedChecker struct {
+	key        string
+	value      string
+	proxyProto string // "v1", "v2"
+	netns      string
+}
+
+// memcachedError is an error reply from server, such as "SERVER_ERROR ...".
+type memcachedError string
+
+func (e memcachedError) Error() string {
+	return string(e)
+}
+
+func init() {
+	registerMethod(CheckMethodMemcached, &MemcachedChecker{})
+}
+
+func (c *MemcachedChecker) Check(target *utils.L3L4Addr, timeout time.Duration) (types.State, error) {
+	return checkWithTimeout(c, target, timeout)
+}
+
+func (c *MemcachedChecker) CheckContext(ctx context.Context, target *utils.L3L4Addr) (types.State, error) {
+	res, err := c.CheckEx(ctx, target)
+	return res.State, err
+}
+
+func (c *MemcachedChecker) CheckEx(ctx context.Context, target *utils.L3L4Addr) (*CheckResult, error) {
+	start := time.Now()
+	timeout, err := checkTimeout(ctx, "Memcached", start)
+	if err != nil {
+		return checkError(start, err)
+	}
+
+	addr := target.Addr()
+	glog.V(9).Infof("Start Memcached check to %s ...", addr)
+
+	dial := net.Dialer{
+		Timeout: timeout,
+	}
+	conn, err := netnsDial(ctx, &dial, c.netns, target.Network(), addr)
+	if err != nil {
+		return checkFailed("Memcached", addr, start, errReason(err, true), "failed to dial: %v", err), nil
+	}
+	defer conn.Close()
+	defer closeOnCancel(ctx, conn)()
+
+	if err = conn.SetDeadline(start.Add(timeout)); err != nil {
+		return checkFailed("Memcached", addr, start, ReasonUnknown, "failed to set deadline"), nil
+	}
+
+	if "v2" == c.proxyProto {
+		if err = utils.WriteFull(conn, proxyProtoV2LocalCmd); err != nil {
+			return checkFailed("Memcached", addr, start, errReason(err, false),
+				"failed to send proxy protocol v2 data: %v", err), nil
+		}
+	} else if "v1" == c.proxyProto {
+		if err = utils.WriteFull(conn, []byte(proxyProtoV1LocalCmd)); err != nil {
+			return checkFailed("Memcached", addr, start, errReason(err, false),
+				"failed to send proxy protocol v1 data: %v", err), nil
+		}
+	}
+
+	r := bufio.NewReaderSize(conn, memcachedMaxLineLen)
+
+	reply, err := memcachedCommand(conn, r, "version\r\n")
+	if err != nil {
+		return checkFailed("Memcached", addr, start, memcachedReason(err),
+			"version command failed: %v", err), nil
+	}
+	if !strings.HasPrefix(reply, "VERSION ") {
+		return checkFailed("Memcached", addr, start, ReasonProtocolError,
+			"unexpected version reply %q", reply), nil
+	}
+	glog.V(9).Infof("Memcached check %v: server %s", addr, reply)
+
+	if len(c.key) > 0 {
+		value := c.value
+		if len(value) == 0 {
+			value = strconv.FormatInt(time.Now().UnixNano(), 10)
+		}
+		if res := c.roundtrip(conn, r, addr, start, value); res != nil {
+			return res, nil
+		}
+	}
+
+	// The server closes the connection without reply.
+	utils.WriteFull(conn, []byte("quit\r\n"))
+
+	return checkSucceed("Memcached", addr, start), nil
+}
+
+// roundtrip stores `value` to the key and reads it back, and returns the
+// failed result if any.
+func (c *MemcachedChecker) roundtrip(conn net.Conn, r *bufio.Reader, addr string,
+	start time.Time, value string) *CheckResult {
+	reply, err := memcachedCommand(conn, r, fmt.Sprintf("set %s 0 %d %d\r\n%s\r\n",
+		c.key, memcachedKeyExpiry, len(value), value))
+	if err != nil {
+		return checkFailed("Memcached", addr, start, memcachedReason(err),
+			"set command failed: %v", err)
+	}
+	if reply != "STORED" {
+		return checkFailed("Memcached", addr, start, ReasonBadStatus,
+			"unexpected set reply %q", reply)
+	}
+
+	reply, err = memcachedCommand(conn, r, fmt.Sprintf("get %s\r\n", c.key))
+	if err != nil {
+		return checkFailed("Memcached", addr, start, memcachedReason(err),
+			"get command failed: %v", err)
+	}
+	if reply == "END" {
+		return checkFailed("Memcached", addr, start, ReasonPayloadMismatch,
+			"key %q not found", c.key)
+	}
+	// VALUE <key> <flags> <bytes> [<cas unique>]
+	fields := strings.Fields(reply)
+	if len(fields) < 4 || fields[0] != "VALUE" || fields[1] != c.key {
+		return checkFailed("Memcached", addr, start, ReasonProtocolError,
+			"unexpected get reply %q", reply)
+	}
+	size, err := strconv.Atoi(fields[3])
+	if err != nil || size < 0 {
+		return checkFailed("Memcached", addr, start, ReasonProtocolError,
+			"unexpected get reply %q", reply)
+	}
+	if size != len(value) {
+		return checkFailed("Memcached", addr, start, ReasonPayloadMismatch,
+			"unexpected value size %d, want %d", size, len(value))
+	}
+	data := make([]byte, size+2)
+	if _, err = io.ReadFull(r, data); err != nil {
+		return checkFailed("Memcached", addr, start, errReason(err, false),
+			"failed to read value: %v", err)
+	}
+	if !bytes.HasSuffix(data, []byte("\r\n")) {
+		return checkFailed("Memcached", addr, start, ReasonProtocolError,
+			"malformed value data block")
+	}
+	if got := string(data[:size]); got != value {
+		return checkFailed("Memcached", addr, start, ReasonPayloadMismatch,
+			"unexpected value %q, want %q", got, value)
+	}
+	if reply, err = memcachedReadLine(r); err != nil {
+		return checkFailed("Memcached", addr, start, errReason(err, false),
+			"failed to read get reply: %v", err)
+	}
+	if reply != "END" {
+		return checkFailed("Memcached", addr, start, ReasonProtocolError,
+			"unexpected get reply end %q", reply)
+	}
+	return nil
+}
+
+// memcachedCommand sends the command `cmd`, and returns the first reply line.
+// An error reply from server is returned as memcachedError.
+func memcachedCommand(conn net.Conn, r *bufio.Reader, cmd string) (string, error) {
+	if err := utils.WriteFull(conn, []byte(cmd)); err != nil {
+		return "", err
+	}
+	line, err := memcachedReadLine(r)
+	if err != nil {
+		return "", err
+	}
+	if line == "ERROR" || strings.HasPrefix(line, "CLIENT_ERROR") ||
+		strings.HasPrefix(line, "SERVER_ERROR") {
+		return "", memcachedError(line)
+	}
+	return line, nil
+}
+
+// memcachedReadLine reads a reply line terminated by "\r\n", and returns it
+// without the terminator.
+func memcachedReadLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadSlice('\n')
+	if err == bufio.ErrBufferFull {
+		return "", fmt.Errorf("reply line too long")
+	}
+	if err != nil {
+		return "", err
+	}
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return "", fmt.Errorf("reply line not terminated by CRLF")
+	}
+	return string(line[:len(line)-2]), nil
+}
+
+// memcachedReason classifies the error of a memcached command.
+func memcachedReason(err error) Reason {
+	if _, ok := err.(memcachedError); ok {
+		return ReasonBadStatus
+	}
+	if _, ok := err.(net.Error); ok || err == io.EOF || err == io.ErrUnexpectedEOF {
+		return errReason(err, false)
+	}
+	return ReasonProtocolError
+}
+
+// memcachedValidKey tells if `key` is a valid key of the ASCII protocol.
+func memcachedValidKey(key string) bool {
+	if len(key) == 0 || len(key) > memcachedMaxKeyLen {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] <= ' ' || key[i] == 0x7f {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *MemcachedChecker) DefaultParams() map[string]string {
+	return map[string]string{
+		"key":           "",
+		"value":         "",
+		ParamProxyProto: "",
+		ParamNetns:      "",
+	}
+}
+
+func (c *MemcachedChecker) validate(params map[string]string) error {
+	unsupported := make([]string, 0, len(params))
+	for param, val := range params {
+		switch param {
+		case "key":
+			if !memcachedValidKey(val) {
+				return fmt.Errorf("invalid memcached checker param value: %s:%s", param, val)
+			}
+		case "value":
+			if len(params["key"]) == 0 {
+				return fmt.Errorf("memcached checker param %s requires key", param)
+			}
+			if strings.ContainsAny(val, "\r\n") {
+				return fmt.Errorf("invalid memcached checker param value: %s:%q", param, val)
+			}
+		case ParamProxyProto:
+			val = strings.ToLower(val)
+			if val != "v1" && val != "v2" {
+				return fmt.Errorf("invalid memcached checker param value: %s:%s", param, params[param])
+			}
+		case ParamNetns:
+			if err := utils.ValidateNetns(val); err != nil {
+				return fmt.Errorf("invalid memcached checker param value: %s:%s: %v", param, val, err)
+			}
+		default:
+			unsupported = append(unsupported, param)
+		}
+	}
+
+	if len(unsupported) > 0 {
+		return fmt.Errorf("unsupported memcached checker params: %q", strings.Join(unsupported, ","))
+	}
+	return nil
+}
+
+func (c *MemcachedChecker) create(params map[string]string) (CheckMethod, error) {
+	if err := c.validate(params); err != nil {
+		return nil, fmt.Errorf("memcached checker param validation failed: %v", err)
+	}
+
+	return &MemcachedChecker{
+		key:        params["key"],
+		value:      params["value"],
+		proxyProto: strings.ToLower(params[ParamProxyProto]),
+		netns:      params[ParamNetns],
+	}, nil
+}
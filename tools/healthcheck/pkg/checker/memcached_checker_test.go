@@ -0,0 +1,199 @@
+// /*
+// Copyright 2025 IQiYi Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// */
+
+package checker
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/types"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/utils"
+)
+
+// fakeMemcached is a memcached server implementing the version, set, get and
+// quit commands of the ASCII protocol.
+type fakeMemcached struct {
+	proxyProto []byte
+	version    string            // reply of version command, "VERSION 1.6.21" if empty
+	setReply   string            // reply of set command, "STORED" if empty
+	corrupt    map[string]string // key -> value, overrides the stored value on get
+	silent     bool              // never reply
+	hangup     bool              // close the connection on accepted
+
+	lock  sync.Mutex
+	store map[string]string
+}
+
+func (s *fakeMemcached) start(t *testing.T) *utils.L3L4Addr {
+	s.store = make(map[string]string)
+	return startTCPServer(t, func(conn *net.TCPConn) {
+		if s.hangup {
+			return
+		}
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+		r := bufio.NewReader(conn)
+		if len(s.proxyProto) > 0 {
+			buf := make([]byte, len(s.proxyProto))
+			if _, err := io.ReadFull(r, buf); err != nil || !bytes.Equal(buf, s.proxyProto) {
+				return
+			}
+		}
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			if s.silent {
+				continue
+			}
+			args := strings.Fields(line)
+			if len(args) == 0 {
+				conn.Write([]byte("ERROR\r\n"))
+				continue
+			}
+			switch args[0] {
+			case "version":
+				version := s.version
+				if len(version) == 0 {
+					version = "VERSION 1.6.21"
+				}
+				conn.Write([]byte(version + "\r\n"))
+			case "set":
+				size, _ := strconv.Atoi(args[4])
+				data := make([]byte, size+2)
+				if _, err := io.ReadFull(r, data); err != nil {
+					return
+				}
+				if len(s.setReply) > 0 {
+					conn.Write([]byte(s.setReply + "\r\n"))
+					continue
+				}
+				s.lock.Lock()
+				s.store[args[1]] = string(data[:size])
+				s.lock.Unlock()
+				conn.Write([]byte("STORED\r\n"))
+			case "get":
+				s.lock.Lock()
+				val, ok := s.store[args[1]]
+				s.lock.Unlock()
+				if v, corrupted := s.corrupt[args[1]]; corrupted {
+					val, ok = v, true
+				}
+				if ok {
+					fmt.Fprintf(conn, "VALUE %s 0 %d\r\n%s\r\n", args[1], len(val), val)
+				}
+				conn.Write([]byte("END\r\n"))
+			case "quit":
+				return
+			default:
+				conn.Write([]byte("ERROR\r\n"))
+			}
+		}
+	})
+}
+
+func TestMemcachedChecker(t *testing.T) {
+	timeout := 500 * time.Millisecond
+
+	for _, tc := range []struct {
+		name   string
+		server *fakeMemcached
+		params map[string]string
+		state  types.State
+		reason Reason
+	}{
+		{"version", &fakeMemcached{}, nil, types.Healthy, ReasonNone},
+		{"roundtrip", &fakeMemcached{}, map[string]string{"key": "dpvs:hc"}, types.Healthy, ReasonNone},
+		{"roundtrip with value", &fakeMemcached{}, map[string]string{"key": "dpvs:hc", "value": "hello world"},
+			types.Healthy, ReasonNone},
+		{"proxy protocol v1", &fakeMemcached{proxyProto: []byte(proxyProtoV1LocalCmd)},
+			map[string]string{ParamProxyProto: "v1", "key": "k"}, types.Healthy, ReasonNone},
+		{"proxy protocol v2", &fakeMemcached{proxyProto: proxyProtoV2LocalCmd},
+			map[string]string{ParamProxyProto: "V2"}, types.Healthy, ReasonNone},
+		{"bad version", &fakeMemcached{version: "HELLO"}, nil, types.Unhealthy, ReasonProtocolError},
+		{"server error", &fakeMemcached{version: "SERVER_ERROR out of memory"}, nil,
+			types.Unhealthy, ReasonBadStatus},
+		{"not stored", &fakeMemcached{setReply: "NOT_STORED"}, map[string]string{"key": "k"},
+			types.Unhealthy, ReasonBadStatus},
+		{"corrupted value", &fakeMemcached{corrupt: map[string]string{"k": "bad"}},
+			map[string]string{"key": "k", "value": "good"}, types.Unhealthy, ReasonPayloadMismatch},
+		{"hangup", &fakeMemcached{hangup: true}, nil, types.Unhealthy, ReasonConnReset},
+		{"silent", &fakeMemcached{silent: true}, nil, types.Unhealthy, ReasonTimeout},
+	} {
+		checker, err := (&MemcachedChecker{}).create(tc.params)
+		if err != nil {
+			t.Fatalf("%s: failed to create memcached checker: %v", tc.name, err)
+		}
+		target := tc.server.start(t)
+		start := time.Now()
+		res, err := CheckExTimeout(checker, target, timeout)
+		if err != nil {
+			t.Errorf("%s: failed to execute memcached checker: %v", tc.name, err)
+			continue
+		}
+		if res.State != tc.state || res.Reason != tc.reason {
+			t.Errorf("%s: expect %v(%v), got %v(%v), %s", tc.name, tc.state, tc.reason,
+				res.State, res.Reason, res.Detail)
+		}
+		if elapsed := time.Since(start); elapsed > timeout+200*time.Millisecond {
+			t.Errorf("%s: check not return in time: %v", tc.name, elapsed)
+		}
+	}
+
+	res, err := CheckExTimeout(&MemcachedChecker{}, closedTCPPort(t), timeout)
+	if err != nil || res.State != types.Unhealthy || res.Reason != ReasonConnRefused {
+		t.Errorf("expect %v(%v) on refused, got %+v, %v", types.Unhealthy, ReasonConnRefused, res, err)
+	}
+}
+
+func TestMemcachedCheckerParams(t *testing.T) {
+	for _, tc := range []struct {
+		params map[string]string
+		valid  bool
+	}{
+		{nil, true},
+		{map[string]string{"key": "dpvs:healthcheck", "value": "v", ParamProxyProto: "v2"}, true},
+		{map[string]string{"key": strings.Repeat("k", memcachedMaxKeyLen)}, true},
+		{map[string]string{"key": strings.Repeat("k", memcachedMaxKeyLen+1)}, false},
+		{map[string]string{"key": "bad key"}, false},
+		{map[string]string{"key": "bad\r\nkey"}, false},
+		{map[string]string{"key": ""}, false},
+		{map[string]string{"value": "v"}, false},
+		{map[string]string{"key": "k", "value": "a\r\nb"}, false},
+		{map[string]string{ParamProxyProto: "v3"}, false},
+		{map[string]string{"binary": "true"}, false},
+	} {
+		_, err := (&MemcachedChecker{}).create(tc.params)
+		if tc.valid && err != nil {
+			t.Errorf("expect %v valid, got %v", tc.params, err)
+		} else if !tc.valid && err == nil {
+			t.Errorf("expect %v invalid", tc.params)
+		}
+	}
+
+	if ParseMethod("Memcached") != CheckMethodMemcached || CheckMethodMemcached.String() != "memcached" {
+		t.Errorf("memcached method not registered properly")
+	}
+}
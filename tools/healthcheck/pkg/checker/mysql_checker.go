@@ -0,0 +1,497 @@
+// /*
+// Copyright 2025 IQiYi Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// */
+
+package checker
+
+/*
+MySQL Checker Params:
+-----------------------------------
+name                value
+-----------------------------------
+user                login user
+password            login password
+database            default database to use on login
+prxoy-protocol      v1 | v2
+netns               network namespace name or path to check from
+------------------------------------
+
+Without `user`, only the initial handshake packet from server is validated.
+Note that MySQL server counts such aborted handshakes as connection errors,
+and blocks the checker host when they exceed `max_connect_errors`. So it's
+recommended to set `user` and grant it the USAGE privilege only.
+
+Supported auth plugins: mysql_native_password, caching_sha2_password.
+*/
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/types"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/utils"
+)
+
+var _ CheckMethod = (*MySQLChecker)(nil)
+
+// MySQL capability flags used by the checker
+const (
+	mysqlClientLongPassword  uint32 = 0x00000001
+	mysqlClientConnectWithDB uint32 = 0x00000008
+	mysqlClientProtocol41    uint32 = 0x00000200
+	mysqlClientTransactions  uint32 = 0x00002000
+	mysqlClientSecureConn    uint32 = 0x00008000
+	mysqlClientPluginAuth    uint32 = 0x00080000
+)
+
+const (
+	mysqlNativePassword      = "mysql_native_password"
+	mysqlCachingSha2Password = "caching_sha2_password"
+
+	mysqlMaxPacketSize = 1 << 16 // large enough for packets in handshake
+	mysqlMaxAuthRounds = 4
+)
+
+type MySQLChecker struct {
+	user       string
+	password   string
+	database   string
+	proxyProto string // "v1", "v2"
+	netns      string
+}
+
+// mysqlError is the ERR packet from server.
+type mysqlError struct {
+	code    uint16
+	state   string
+	message string
+}
+
+func (e *mysqlError) Error() string {
+	if len(e.state) > 0 {
+		return fmt.Sprintf("ERROR %d (%s): %s", e.code, e.state, e.message)
+	}
+	return fmt.Sprintf("ERROR %d: %s", e.code, e.message)
+}
+
+// mysqlHandshake is the initial handshake packet (protocol version 10) from server.
+type mysqlHandshake struct {
+	version  string
+	connId   uint32
+	caps     uint32
+	scramble []byte
+	plugin   string
+}
+
+func init() {
+	registerMethod(CheckMethodMySQL, &MySQLChecker{})
+}
+
+func (c *MySQLChecker) Check(target *utils.L3L4Addr, timeout time.Duration) (types.State, error) {
+	return checkWithTimeout(c, target, timeout)
+}
+
+func (c *MySQLChecker) CheckContext(ctx context.Context, target *utils.L3L4Addr) (types.State, error) {
+	start := time.Now()
+	timeout, err := checkTimeout(ctx, "MySQL", start)
+	if err != nil {
+		return types.Unknown, err
+	}
+
+	addr := target.Addr()
+	glog.V(9).Infof("Start MySQL check to %s ...", addr)
+
+	deadline := start.Add(timeout)
+	dial := net.Dialer{
+		Timeout: timeout,
+	}
+	conn, err := netnsDial(ctx, &dial, c.netns, target.Network(), addr)
+	if err != nil {
+		glog.V(9).Infof("MySQL check %v %v: failed to dial", addr, types.Unhealthy)
+		return types.Unhealthy, nil
+	}
+	defer conn.Close()
+	defer closeOnCancel(ctx, conn)()
+
+	if err = conn.SetDeadline(deadline); err != nil {
+		glog.V(9).Infof("MySQL check %v %v: failed to set deadline", addr, types.Unhealthy)
+		return types.Unhealthy, nil
+	}
+
+	if "v2" == c.proxyProto {
+		if err = utils.WriteFull(conn, proxyProtoV2LocalCmd); err != nil {
+			glog.V(9).Infof("MySQL check %v %v: failed to send proxy protocol v2 data",
+				addr, types.Unhealthy)
+			return types.Unhealthy, nil
+		}
+	} else if "v1" == c.proxyProto {
+		if err = utils.WriteFull(conn, []byte(proxyProtoV1LocalCmd)); err != nil {
+			glog.V(9).Infof("MySQL check %v %v: failed to send proxy protocol v1 data",
+				addr, types.Unhealthy)
+			return types.Unhealthy, nil
+		}
+	}
+
+	_, data, err := mysqlReadPacket(conn)
+	if err != nil {
+		glog.V(9).Infof("MySQL check %v %v: failed to read handshake: %v", addr, types.Unhealthy, err)
+		return types.Unhealthy, nil
+	}
+	hs, err := mysqlParseHandshake(data)
+	if err != nil {
+		glog.V(9).Infof("MySQL check %v %v: invalid handshake: %v", addr, types.Unhealthy, err)
+		return types.Unhealthy, nil
+	}
+	glog.V(9).Infof("MySQL check %v: server version %s, connection id %d", addr, hs.version, hs.connId)
+
+	if len(c.user) == 0 {
+		glog.V(9).Infof("MySQL check %v %v: succeed", addr, types.Healthy)
+		return types.Healthy, nil
+	}
+
+	if err = c.login(conn, hs); err != nil {
+		var serr *mysqlError
+		if errors.As(err, &serr) {
+			logLimiter.Warningf("MySQL logins rejected", "MySQL check %v %v: login as %q rejected: %v",
+				addr, types.Unhealthy, c.user, err)
+		} else {
+			glog.V(9).Infof("MySQL check %v %v: failed to login: %v", addr, types.Unhealthy, err)
+		}
+		return types.Unhealthy, nil
+	}
+	// COM_QUIT, the server closes the connection without response.
+	mysqlWritePacket(conn, 0, []byte{0x01})
+
+	glog.V(9).Infof("MySQL check %v %v: succeed", addr, types.Healthy)
+	return types.Healthy, nil
+}
+
+// login completes the auth handshake, and returns *mysqlError if rejected by server.
+func (c *MySQLChecker) login(conn net.Conn, hs *mysqlHandshake) error {
+	if hs.caps&mysqlClientProtocol41 == 0 || hs.caps&mysqlClientSecureConn == 0 {
+		return fmt.Errorf("unsupported server capabilities 0x%x", hs.caps)
+	}
+
+	// Answer with mysql_native_password if the server's default plugin is not supported,
+	// and the server would switch to a plugin it accepts.
+	plugin := hs.plugin
+	if plugin != mysqlCachingSha2Password {
+		plugin = mysqlNativePassword
+	}
+	scramble := hs.scramble
+	auth := mysqlScramble(plugin, c.password, scramble)
+
+	caps := mysqlClientLongPassword | mysqlClientProtocol41 | mysqlClientTransactions |
+		mysqlClientSecureConn | hs.caps&mysqlClientPluginAuth
+	if len(c.database) > 0 {
+		caps |= hs.caps & mysqlClientConnectWithDB
+	}
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, caps)
+	binary.Write(&buf, binary.LittleEndian, uint32(mysqlMaxPacketSize))
+	buf.WriteByte(33) // utf8_general_ci
+	buf.Write(make([]byte, 23))
+	buf.WriteString(c.user)
+	buf.WriteByte(0)
+	buf.WriteByte(byte(len(auth)))
+	buf.Write(auth)
+	if caps&mysqlClientConnectWithDB != 0 {
+		buf.WriteString(c.database)
+		buf.WriteByte(0)
+	}
+	if caps&mysqlClientPluginAuth != 0 {
+		buf.WriteString(plugin)
+		buf.WriteByte(0)
+	}
+	seq := byte(1)
+	if err := mysqlWritePacket(conn, seq, buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to send handshake response: %v", err)
+	}
+
+	for i := 0; i < mysqlMaxAuthRounds; i++ {
+		pseq, data, err := mysqlReadPacket(conn)
+		if err != nil {
+			return fmt.Errorf("failed to read auth response: %v", err)
+		}
+		seq = pseq + 1
+		if len(data) == 0 {
+			return fmt.Errorf("empty auth response")
+		}
+
+		switch data[0] {
+		case 0x00: // OK
+			return nil
+		case 0xff: // ERR
+			return mysqlParseError(data)
+		case 0xfe: // AuthSwitchRequest
+			name := data[1:]
+			idx := bytes.IndexByte(name, 0)
+			if idx < 0 {
+				return fmt.Errorf("invalid auth switch request")
+			}
+			plugin = string(name[:idx])
+			if plugin != mysqlNativePassword && plugin != mysqlCachingSha2Password {
+				return fmt.Errorf("unsupported auth plugin %q", plugin)
+			}
+			scramble = bytes.TrimRight(name[idx+1:], "\x00")
+			auth = mysqlScramble(plugin, c.password, scramble)
+		case 0x01: // AuthMoreData
+			if plugin != mysqlCachingSha2Password || len(data) < 2 {
+				return fmt.Errorf("unexpected auth more data")
+			}
+			switch {
+			case len(data) == 2 && data[1] == 3: // fast auth succeeded, OK follows
+				continue
+			case len(data) == 2 && data[1] == 4: // full auth, request server's public key
+				auth = []byte{0x02}
+			default: // server's public key in PEM format
+				if auth, err = mysqlEncryptPassword(c.password, scramble, data[1:]); err != nil {
+					return err
+				}
+			}
+		default:
+			return fmt.Errorf("unexpected auth response 0x%02x", data[0])
+		}
+
+		if err = mysqlWritePacket(conn, seq, auth); err != nil {
+			return fmt.Errorf("failed to send auth data: %v", err)
+		}
+	}
+	return fmt.Errorf("too many auth rounds")
+}
+
+func mysqlReadPacket(r io.Reader) (byte, []byte, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 0, nil, err
+	}
+	size := int(header[0]) | int(header[1])<<8 | int(header[2])<<16
+	if size > mysqlMaxPacketSize {
+		return 0, nil, fmt.Errorf("packet too large: %d bytes", size)
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return 0, nil, err
+	}
+	return header[3], data, nil
+}
+
+func mysqlWritePacket(conn net.Conn, seq byte, data []byte) error {
+	pkt := make([]byte, 4+len(data))
+	pkt[0] = byte(len(data))
+	pkt[1] = byte(len(data) >> 8)
+	pkt[2] = byte(len(data) >> 16)
+	pkt[3] = seq
+	copy(pkt[4:], data)
+	return utils.WriteFull(conn, pkt)
+}
+
+func mysqlParseError(data []byte) error {
+	if len(data) < 3 {
+		return fmt.Errorf("invalid error packet")
+	}
+	serr := &mysqlError{code: binary.LittleEndian.Uint16(data[1:3])}
+	msg := data[3:]
+	if len(msg) >= 6 && msg[0] == '#' {
+		serr.state = string(msg[1:6])
+		msg = msg[6:]
+	}
+	serr.message = string(msg)
+	return serr
+}
+
+func mysqlParseHandshake(data []byte) (*mysqlHandshake, error) {
+	if len(data) == 0 {
+		return nil, fmt.Errorf("empty packet")
+	}
+	if data[0] == 0xff {
+		return nil, mysqlParseError(data)
+	}
+	if data[0] != 10 {
+		return nil, fmt.Errorf("unsupported protocol version %d", data[0])
+	}
+
+	hs := &mysqlHandshake{}
+	pos := 1
+	idx := bytes.IndexByte(data[pos:], 0)
+	if idx < 0 {
+		return nil, fmt.Errorf("invalid server version")
+	}
+	hs.version = string(data[pos : pos+idx])
+	pos += idx + 1
+
+	// connection id(4), auth-plugin-data-part-1(8), filler(1), capability flags lower(2)
+	if len(data) < pos+15 || data[pos+12] != 0 {
+		return nil, fmt.Errorf("truncated or malformed packet")
+	}
+	hs.connId = binary.LittleEndian.Uint32(data[pos:])
+	hs.scramble = append([]byte(nil), data[pos+4:pos+12]...)
+	hs.caps = uint32(binary.LittleEndian.Uint16(data[pos+13:]))
+	pos += 15
+	if len(data) == pos {
+		return hs, nil
+	}
+
+	// charset(1), status flags(2), capability flags upper(2), auth-plugin-data length(1), reserved(10)
+	if len(data) < pos+16 {
+		return nil, fmt.Errorf("truncated packet")
+	}
+	hs.caps |= uint32(binary.LittleEndian.Uint16(data[pos+3:])) << 16
+	authLen := int(data[pos+5])
+	pos += 16
+
+	if hs.caps&mysqlClientSecureConn != 0 {
+		n := authLen - 8
+		if n < 13 {
+			n = 13
+		}
+		if len(data) < pos+n {
+			return nil, fmt.Errorf("truncated auth plugin data")
+		}
+		hs.scramble = append(hs.scramble, bytes.TrimRight(data[pos:pos+n], "\x00")...)
+		pos += n
+	}
+	if hs.caps&mysqlClientPluginAuth != 0 {
+		name := data[pos:]
+		if idx = bytes.IndexByte(name, 0); idx >= 0 {
+			name = name[:idx]
+		}
+		hs.plugin = string(name)
+	}
+	return hs, nil
+}
+
+// mysqlScramble computes auth data of password with the plugin's algorithm.
+func mysqlScramble(plugin, password string, scramble []byte) []byte {
+	if len(password) == 0 {
+		return nil
+	}
+
+	var stage1, stage3 []byte
+	if plugin == mysqlCachingSha2Password {
+		// XOR(SHA256(password), SHA256(SHA256(SHA256(password)), scramble))
+		h1 := sha256.Sum256([]byte(password))
+		h2 := sha256.Sum256(h1[:])
+		hash := sha256.New()
+		hash.Write(h2[:])
+		hash.Write(scramble)
+		stage1, stage3 = h1[:], hash.Sum(nil)
+	} else {
+		// XOR(SHA1(password), SHA1(scramble, SHA1(SHA1(password))))
+		h1 := sha1.Sum([]byte(password))
+		h2 := sha1.Sum(h1[:])
+		hash := sha1.New()
+		hash.Write(scramble)
+		hash.Write(h2[:])
+		stage1, stage3 = h1[:], hash.Sum(nil)
+	}
+	for i := range stage3 {
+		stage3[i] ^= stage1[i]
+	}
+	return stage3
+}
+
+// mysqlEncryptPassword encrypts password with server's RSA public key for
+// caching_sha2_password full authentication on plain connections.
+func mysqlEncryptPassword(password string, scramble, key []byte) ([]byte, error) {
+	block, _ := pem.Decode(key)
+	if block == nil {
+		return nil, fmt.Errorf("invalid server public key")
+	}
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid server public key: %v", err)
+	}
+	rsaPub, ok := pub.(*rsa.PublicKey)
+	if !ok || len(scramble) == 0 {
+		return nil, fmt.Errorf("invalid server public key")
+	}
+
+	plain := append([]byte(password), 0)
+	for i := range plain {
+		plain[i] ^= scramble[i%len(scramble)]
+	}
+	return rsa.EncryptOAEP(sha1.New(), rand.Reader, rsaPub, plain, nil)
+}
+
+func (c *MySQLChecker) DefaultParams() map[string]string {
+	return map[string]string{
+		"user":          "",
+		"password":      "",
+		"database":      "",
+		ParamProxyProto: "",
+		ParamNetns:      "",
+	}
+}
+
+func (c *MySQLChecker) validate(params map[string]string) error {
+	unsupported := make([]string, 0, len(params))
+	for param, val := range params {
+		switch param {
+		case "user":
+			if len(val) == 0 {
+				return fmt.Errorf("empty mysql checker param: %s", param)
+			}
+		case "password", "database":
+			if len(params["user"]) == 0 {
+				return fmt.Errorf("mysql checker param %s requires user", param)
+			}
+		case ParamProxyProto:
+			val = strings.ToLower(val)
+			if val != "v1" && val != "v2" {
+				return fmt.Errorf("invalid mysql checker param value: %s:%s", param, params[param])
+			}
+		case ParamNetns:
+			if err := utils.ValidateNetns(val); err != nil {
+				return fmt.Errorf("invalid mysql checker param value: %s:%s: %v", param, val, err)
+			}
+		default:
+			unsupported = append(unsupported, param)
+		}
+	}
+
+	if len(unsupported) > 0 {
+		return fmt.Errorf("unsupported mysql checker params: %q", strings.Join(unsupported, ","))
+	}
+	return nil
+}
+
+func (c *MySQLChecker) create(params map[string]string) (CheckMethod, error) {
+	if err := c.validate(params); err != nil {
+		return nil, fmt.Errorf("mysql checker param validation failed: %v", err)
+	}
+
+	return &MySQLChecker{
+		user:       params["user"],
+		password:   params["password"],
+		database:   params["database"],
+		proxyProto: strings.ToLower(params[ParamProxyProto]),
+		netns:      params[ParamNetns],
+	}, nil
+}
@@ -0,0 +1,350 @@
+// /*
+// Copyright 2025 IQiYi Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// */
+
+package checker
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/pem"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/types"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/utils"
+)
+
+// fakeMySQL is a MySQL server implementing the auth handshake only.
+type fakeMySQL struct {
+	plugin     string            // default auth plugin
+	accounts   map[string]string // user -> password, with mysql_native_password
+	sha2       map[string]string // user -> password, with caching_sha2_password
+	fullAuth   bool              // caching_sha2_password always requires full auth
+	database   string            // the only database existed
+	proxyProto string
+	greetErr   bool // reject clients with ERR
+	silent     bool // never greet
+	key        *rsa.PrivateKey
+}
+
+func (s *fakeMySQL) start(t *testing.T) *utils.L3L4Addr {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				conn.SetDeadline(time.Now().Add(5 * time.Second))
+				s.serve(conn)
+			}()
+		}
+	}()
+	port := ln.Addr().(*net.TCPAddr).Port
+	return &utils.L3L4Addr{IP: net.ParseIP("127.0.0.1"), Port: uint16(port), Proto: utils.IPProtoTCP}
+}
+
+func fakeScramble() []byte {
+	scramble := make([]byte, 20)
+	rand.Read(scramble)
+	for i := range scramble {
+		scramble[i] = scramble[i]%94 + 33 // printable without NUL
+	}
+	return scramble
+}
+
+func (s *fakeMySQL) serve(conn net.Conn) {
+	if s.silent {
+		io.Copy(io.Discard, conn)
+		return
+	}
+	if len(s.proxyProto) > 0 {
+		buf := make([]byte, len(proxyProtoV2LocalCmd))
+		if _, err := io.ReadFull(conn, buf); err != nil || !bytes.Equal(buf, proxyProtoV2LocalCmd) {
+			return
+		}
+	}
+	if s.greetErr {
+		mysqlWritePacket(conn, 0, append([]byte{0xff, 0x10, 0x04}, "Too many connections"...))
+		return
+	}
+
+	scramble := fakeScramble()
+	var greet bytes.Buffer
+	caps := mysqlClientLongPassword | mysqlClientConnectWithDB | mysqlClientProtocol41 |
+		mysqlClientTransactions | mysqlClientSecureConn | mysqlClientPluginAuth
+	greet.WriteByte(10)
+	greet.WriteString("8.0.36-fake\x00")
+	binary.Write(&greet, binary.LittleEndian, uint32(1234))
+	greet.Write(scramble[:8])
+	greet.WriteByte(0)
+	binary.Write(&greet, binary.LittleEndian, uint16(caps))
+	greet.Write([]byte{33, 2, 0})
+	binary.Write(&greet, binary.LittleEndian, uint16(caps>>16))
+	greet.WriteByte(21)
+	greet.Write(make([]byte, 10))
+	greet.Write(scramble[8:])
+	greet.WriteByte(0)
+	greet.WriteString(s.plugin + "\x00")
+	if mysqlWritePacket(conn, 0, greet.Bytes()) != nil {
+		return
+	}
+
+	seq, data, err := mysqlReadPacket(conn)
+	if err != nil || len(data) < 33 {
+		return
+	}
+	clientCaps := binary.LittleEndian.Uint32(data)
+	data = data[32:]
+	idx := bytes.IndexByte(data, 0)
+	user := string(data[:idx])
+	data = data[idx+1:]
+	auth := data[1 : 1+data[0]]
+	data = data[1+data[0]:]
+	database := ""
+	if clientCaps&mysqlClientConnectWithDB != 0 {
+		idx = bytes.IndexByte(data, 0)
+		database = string(data[:idx])
+		data = data[idx+1:]
+	}
+	plugin := string(bytes.TrimRight(data, "\x00"))
+
+	reply := func(data []byte) ([]byte, bool) {
+		seq += 2
+		if mysqlWritePacket(conn, seq-1, data) != nil {
+			return nil, false
+		}
+		pseq, resp, err := mysqlReadPacket(conn)
+		if err != nil || pseq != seq {
+			return nil, false
+		}
+		return resp, true
+	}
+	deny := func(code uint16, state, msg string) {
+		pkt := []byte{0xff, byte(code), byte(code >> 8), '#'}
+		mysqlWritePacket(conn, seq+1, append(append(pkt, state...), msg...))
+	}
+
+	password, ok := s.sha2[user]
+	if ok {
+		if plugin != mysqlCachingSha2Password {
+			if auth, ok = reply(append([]byte("\xfe"+mysqlCachingSha2Password+"\x00"), scramble...)); !ok {
+				return
+			}
+		}
+		h1 := sha256.Sum256([]byte(password))
+		h2 := sha256.Sum256(h1[:])
+		h3 := sha256.Sum256(append(h2[:], scramble...))
+		for i := range h3 {
+			h3[i] ^= h1[i]
+		}
+		switch {
+		case len(password) == 0 && len(auth) == 0:
+		case !s.fullAuth && bytes.Equal(auth, h3[:]):
+			seq++
+			if mysqlWritePacket(conn, seq, []byte{0x01, 0x03}) != nil {
+				return
+			}
+		default:
+			if req, ok := reply([]byte{0x01, 0x04}); !ok || !bytes.Equal(req, []byte{0x02}) {
+				return
+			}
+			der, _ := x509.MarshalPKIXPublicKey(&s.key.PublicKey)
+			key := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+			if auth, ok = reply(append([]byte{0x01}, key...)); !ok {
+				return
+			}
+			plain, err := rsa.DecryptOAEP(sha1.New(), nil, s.key, auth, nil)
+			if err != nil {
+				return
+			}
+			for i := range plain {
+				plain[i] ^= scramble[i%len(scramble)]
+			}
+			if string(plain) != password+"\x00" {
+				deny(1045, "28000", "Access denied for user '"+user+"'")
+				return
+			}
+		}
+	} else if password, ok = s.accounts[user]; ok {
+		if plugin != mysqlNativePassword {
+			if auth, ok = reply(append([]byte("\xfe"+mysqlNativePassword+"\x00"), scramble...)); !ok {
+				return
+			}
+		}
+		// verify the client knows SHA1(password) with SHA1(SHA1(password)) stored
+		stage1 := sha1.Sum([]byte(password))
+		stored := sha1.Sum(stage1[:])
+		h := sha1.Sum(append(append([]byte{}, scramble...), stored[:]...))
+		if len(password) > 0 || len(auth) > 0 {
+			if len(auth) != len(h) {
+				deny(1045, "28000", "Access denied for user '"+user+"'")
+				return
+			}
+			for i := range h {
+				h[i] ^= auth[i]
+			}
+			if sha1.Sum(h[:]) != stored {
+				deny(1045, "28000", "Access denied for user '"+user+"'")
+				return
+			}
+		}
+	} else {
+		deny(1045, "28000", "Access denied for user '"+user+"'")
+		return
+	}
+
+	if len(database) > 0 && database != s.database {
+		deny(1049, "42000", "Unknown database '"+database+"'")
+		return
+	}
+	mysqlWritePacket(conn, seq+1, []byte{0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00})
+	// expect COM_QUIT
+	mysqlReadPacket(conn)
+}
+
+func TestMySQLChecker(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("failed to generate rsa key: %v", err)
+	}
+	accounts := map[string]string{"checker": "s3cret", "nopass": ""}
+	sha2 := map[string]string{"checker2": "s3cret2", "nopass2": ""}
+
+	timeout := time.Second
+	cases := []struct {
+		name   string
+		server fakeMySQL
+		params map[string]string
+		expect types.State
+	}{
+		{"handshake only", fakeMySQL{plugin: mysqlNativePassword},
+			nil, types.Healthy},
+		{"native password", fakeMySQL{plugin: mysqlNativePassword, accounts: accounts},
+			map[string]string{"user": "checker", "password": "s3cret"}, types.Healthy},
+		{"wrong password", fakeMySQL{plugin: mysqlNativePassword, accounts: accounts},
+			map[string]string{"user": "checker", "password": "wrong"}, types.Unhealthy},
+		{"unknown user", fakeMySQL{plugin: mysqlNativePassword, accounts: accounts},
+			map[string]string{"user": "nobody"}, types.Unhealthy},
+		{"empty password", fakeMySQL{plugin: mysqlNativePassword, accounts: accounts},
+			map[string]string{"user": "nopass"}, types.Healthy},
+		{"database", fakeMySQL{plugin: mysqlNativePassword, accounts: accounts, database: "db1"},
+			map[string]string{"user": "checker", "password": "s3cret", "database": "db1"}, types.Healthy},
+		{"unknown database", fakeMySQL{plugin: mysqlNativePassword, accounts: accounts, database: "db1"},
+			map[string]string{"user": "checker", "password": "s3cret", "database": "db2"}, types.Unhealthy},
+		{"caching sha2 fast auth", fakeMySQL{plugin: mysqlCachingSha2Password, sha2: sha2},
+			map[string]string{"user": "checker2", "password": "s3cret2"}, types.Healthy},
+		{"caching sha2 full auth", fakeMySQL{plugin: mysqlCachingSha2Password, sha2: sha2, fullAuth: true, key: key},
+			map[string]string{"user": "checker2", "password": "s3cret2"}, types.Healthy},
+		{"caching sha2 wrong password", fakeMySQL{plugin: mysqlCachingSha2Password, sha2: sha2, key: key},
+			map[string]string{"user": "checker2", "password": "wrong"}, types.Unhealthy},
+		{"caching sha2 empty password", fakeMySQL{plugin: mysqlCachingSha2Password, sha2: sha2},
+			map[string]string{"user": "nopass2"}, types.Healthy},
+		{"switch to native password", fakeMySQL{plugin: mysqlCachingSha2Password, accounts: accounts},
+			map[string]string{"user": "checker", "password": "s3cret"}, types.Healthy},
+		{"switch to caching sha2", fakeMySQL{plugin: "sha256_password", sha2: sha2, key: key},
+			map[string]string{"user": "checker2", "password": "s3cret2"}, types.Healthy},
+		{"proxy protocol", fakeMySQL{plugin: mysqlNativePassword, accounts: accounts, proxyProto: "v2"},
+			map[string]string{"user": "checker", "password": "s3cret", ParamProxyProto: "v2"}, types.Healthy},
+		{"greeting error", fakeMySQL{greetErr: true},
+			nil, types.Unhealthy},
+		{"no greeting", fakeMySQL{silent: true},
+			nil, types.Unhealthy},
+	}
+
+	for _, tc := range cases {
+		target := tc.server.start(t)
+		checker, err := (&MySQLChecker{}).create(tc.params)
+		if err != nil {
+			t.Fatalf("%s: failed to create MySQL checker: %v", tc.name, err)
+		}
+		start := time.Now()
+		state, err := checker.Check(target, timeout)
+		if err != nil {
+			t.Errorf("%s: failed to execute MySQL checker: %v", tc.name, err)
+		} else if state != tc.expect {
+			t.Errorf("%s: expect %v, got %v", tc.name, tc.expect, state)
+		}
+		if elapsed := time.Since(start); elapsed > timeout+100*time.Millisecond {
+			t.Errorf("%s: check not bounded by timeout, elapsed %v", tc.name, elapsed)
+		}
+	}
+
+	// closed port
+	target := (&fakeMySQL{}).start(t)
+	target.Port++
+	checker, _ := (&MySQLChecker{}).create(nil)
+	if state, err := checker.Check(target, timeout); err != nil || state != types.Unhealthy {
+		t.Errorf("closed port: expect %v, got %v, %v", types.Unhealthy, state, err)
+	}
+}
+
+func TestMySQLCheckerParams(t *testing.T) {
+	for _, tc := range []struct {
+		params map[string]string
+		valid  bool
+	}{
+		{nil, true},
+		{map[string]string{"user": "checker", "password": "s3cret", "database": "db1"}, true},
+		{map[string]string{"user": "checker", ParamProxyProto: "v1"}, true},
+		{map[string]string{"user": ""}, false},
+		{map[string]string{"password": "s3cret"}, false},
+		{map[string]string{"database": "db1"}, false},
+		{map[string]string{ParamProxyProto: "v3"}, false},
+		{map[string]string{"user": "checker", "charset": "utf8"}, false},
+	} {
+		err := (&MySQLChecker{}).validate(tc.params)
+		if tc.valid && err != nil {
+			t.Errorf("expect %v valid, got %v", tc.params, err)
+		} else if !tc.valid && err == nil {
+			t.Errorf("expect %v invalid", tc.params)
+		}
+	}
+}
+
+func TestMySQLParseHandshake(t *testing.T) {
+	for _, data := range [][]byte{
+		{},
+		{9, '5', 0},
+		{10, '8', '.', '0'},
+		{10, '8', 0, 1, 0, 0, 0, 1, 2, 3, 4, 5, 6, 7, 8, 1, 0xff, 0xff},
+		{10, '8', 0, 1, 0, 0, 0, 1, 2, 3, 4, 5, 6, 7, 8, 0, 0xff, 0xff, 33},
+	} {
+		if hs, err := mysqlParseHandshake(data); err == nil {
+			t.Errorf("expect handshake %v invalid, got %+v", data, hs)
+		}
+	}
+
+	// pre-4.1 style handshake with the minimal fields
+	hs, err := mysqlParseHandshake([]byte{10, '5', 0, 1, 0, 0, 0, 1, 2, 3, 4, 5, 6, 7, 8, 0, 0x00, 0x02})
+	if err != nil || hs.version != "5" || hs.connId != 1 || hs.caps != uint32(mysqlClientProtocol41) ||
+		len(hs.scramble) != 8 {
+		t.Errorf("unexpected handshake %+v, %v", hs, err)
+	}
+}
@@ -0,0 +1,59 @@
+// /*
+// Copyright 2025 IQiYi Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// */
+
+package checker
+
+import (
+	"context"
+	"net"
+
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/utils"
+)
+
+// ParamNetns is the checker param of the network namespace to check targets
+// from, given as a name under utils.NetnsDir or a path. The sockets to targets
+// are created in the namespace, and the check runs in the current one.
+const ParamNetns = "netns"
+
+// netnsDial connects to `addr` with `dialer`, where the socket is created in
+// the network namespace `netns` if given.
+func netnsDial(ctx context.Context, dialer *net.Dialer, netns, network, addr string) (net.Conn, error) {
+	if len(netns) == 0 {
+		return dialer.DialContext(ctx, network, addr)
+	}
+	var conn net.Conn
+	err := utils.RunInNetns(netns, func() error {
+		var err error
+		conn, err = dialer.DialContext(ctx, network, addr)
+		return err
+	})
+	return conn, err
+}
+
+// netnsListenPacket listens on `laddr` like net.ListenPacket, where the socket
+// is created in the network namespace `netns` if given.
+func netnsListenPacket(netns, network, laddr string) (net.PacketConn, error) {
+	if len(netns) == 0 {
+		return net.ListenPacket(network, laddr)
+	}
+	var conn net.PacketConn
+	err := utils.RunInNetns(netns, func() error {
+		var err error
+		conn, err = net.ListenPacket(network, laddr)
+		return err
+	})
+	return conn, err
+}
@@ -0,0 +1,158 @@
+// /*
+// Copyright 2025 IQiYi Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// */
+
+package checker
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/types"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/utils"
+)
+
+// netnsTestNet sets up two network namespaces connected by a veth pair.
+//
+//	(netns hcns-a) hcns0 10.251.0.1/24 <--> hcns1 10.251.0.2/24 (netns hcns-b)
+func netnsTestNet(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("root privilege required")
+	}
+	if _, err := exec.LookPath("ip"); err != nil {
+		t.Skip("iproute2 not found")
+	}
+	run := func(args ...string) error {
+		if out, err := exec.Command("ip", args...).CombinedOutput(); err != nil {
+			return fmt.Errorf("ip %s: %v: %s", strings.Join(args, " "), err, bytes.TrimSpace(out))
+		}
+		return nil
+	}
+	cleanup := func() {
+		exec.Command("ip", "netns", "del", "hcns-a").Run()
+		exec.Command("ip", "netns", "del", "hcns-b").Run()
+	}
+	cleanup()
+	t.Cleanup(cleanup)
+
+	steps := [][]string{
+		{"netns", "add", "hcns-a"},
+		{"netns", "add", "hcns-b"},
+		{"-n", "hcns-a", "link", "add", "hcns0", "type", "veth", "peer", "name", "hcns1", "netns", "hcns-b"},
+		{"-n", "hcns-a", "addr", "add", "10.251.0.1/24", "dev", "hcns0"},
+		{"-n", "hcns-b", "addr", "add", "10.251.0.2/24", "dev", "hcns1"},
+		{"-n", "hcns-a", "link", "set", "hcns0", "up"},
+		{"-n", "hcns-b", "link", "set", "hcns1", "up"},
+	}
+	for _, step := range steps {
+		if err := run(step...); err != nil {
+			t.Skipf("failed to set up test network: %v", err)
+		}
+	}
+}
+
+func TestNetnsValidate(t *testing.T) {
+	for _, kind := range []CheckMethod{&TCPChecker{}, &UDPChecker{}, &HTTPChecker{}, &PingChecker{}} {
+		method := kind.(interface {
+			create(map[string]string) (CheckMethod, error)
+		})
+		_, err := method.create(map[string]string{ParamNetns: "hc-missing-ns"})
+		if err == nil || !strings.Contains(err.Error(), "not found") {
+			t.Errorf("%T: expect error with missing netns, got %v", kind, err)
+		}
+		if _, err := method.create(map[string]string{ParamNetns: ""}); err != nil {
+			t.Errorf("%T: unexpected error with empty netns: %v", kind, err)
+		}
+		if _, err := method.create(map[string]string{ParamNetns: "/proc/self/ns/net"}); err != nil {
+			t.Errorf("%T: unexpected error with netns path: %v", kind, err)
+		}
+	}
+}
+
+func TestNetnsChecker(t *testing.T) {
+	netnsTestNet(t)
+
+	var ln net.Listener
+	if err := utils.RunInNetns("hcns-b", func() error {
+		var err error
+		ln, err = net.Listen("tcp", "10.251.0.2:0")
+		return err
+	}); err != nil {
+		t.Fatalf("failed to listen in netns hcns-b: %v", err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	port := uint16(ln.Addr().(*net.TCPAddr).Port)
+	target := &utils.L3L4Addr{IP: net.ParseIP("10.251.0.2"), Port: port, Proto: utils.IPProtoTCP}
+
+	inNetns, err := (&TCPChecker{}).create(map[string]string{ParamNetns: "hcns-a"})
+	if err != nil {
+		t.Fatalf("failed to create tcp checker: %v", err)
+	}
+	pinger, err := (&PingChecker{}).create(map[string]string{ParamNetns: "hcns-a"})
+	if err != nil {
+		t.Fatalf("failed to create ping checker: %v", err)
+	}
+
+	// The checks in the namespace run concurrently with the sockets created in
+	// the current namespace, which never see the addresses of the former.
+	timeout := 500 * time.Millisecond
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			if state, err := inNetns.Check(target, timeout); err != nil || state != types.Healthy {
+				t.Errorf("tcp check in netns: expect Healthy, got %v, %v", state, err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if ln, err := net.Listen("tcp", "10.251.0.1:0"); err == nil {
+				ln.Close()
+				t.Errorf("address of netns hcns-a leaks into the current namespace")
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if state, err := pinger.Check(target, timeout); err != nil || state != types.Healthy {
+				t.Errorf("ping check in netns: expect Healthy, got %v, %v", state, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// The port closed in the namespace is refused by the peer namespace.
+	closed := *target
+	closed.Port = port + 1
+	if state, err := inNetns.Check(&closed, timeout); err != nil || state != types.Unhealthy {
+		t.Errorf("tcp check to closed port in netns: expect Unhealthy, got %v, %v", state, err)
+	}
+}
@@ -26,6 +26,7 @@ name                value
 */
 
 import (
+	"context"
 	"time"
 
 	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/types"
@@ -45,6 +46,14 @@ func (c *NoneChecker) Check(target *utils.L3L4Addr, timeout time.Duration) (type
 	return types.Healthy, nil
 }
 
+func (c *NoneChecker) CheckContext(ctx context.Context, target *utils.L3L4Addr) (types.State, error) {
+	return types.Healthy, nil
+}
+
+func (c *NoneChecker) DefaultParams() map[string]string {
+	return map[string]string{}
+}
+
 func (c *NoneChecker) validate(params map[string]string) error {
 	return nil
 }
@@ -0,0 +1,196 @@
+// /*
+// Copyright 2025 IQiYi Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// */
+
+package checker
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/types"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/utils"
+)
+
+var _ CheckMethodEx = (*OverrideChecker)(nil)
+
+// override is a state forced for maintenance until `expires`, or until cleared
+// if `expires` is zero.
+type override struct {
+	state   types.State
+	expires time.Time
+}
+
+func (o *override) expired(now time.Time) bool {
+	return !o.expires.IsZero() && !o.expires.After(now)
+}
+
+// overrides holds the states forced for maintenance, which are consulted by
+// OverrideChecker. It's keyed by L3L4Addr::String().
+var overrides = struct {
+	lock   sync.RWMutex
+	states map[string]override
+}{states: make(map[string]override)}
+
+// overrideKey returns the canonical key of `target`, which is parsed with
+// utils.ParseL3L4Addr so that any of its representations works.
+func overrideKey(target string) string {
+	if addr := utils.ParseL3L4Addr(target); addr != nil {
+		return addr.String()
+	}
+	return target
+}
+
+// SetOverride forces the state of `target` to `state` regardless of probe
+// results until ClearOverride is called, such as pinning a backend to Unhealthy
+// to drain it, or to Healthy to suppress flapping, during planned maintenance.
+// The `state` must be Healthy or Unhealthy, otherwise the override is cleared.
+func SetOverride(target string, state types.State) {
+	SetOverrideUntil(target, state, time.Time{})
+}
+
+// SetOverrideUntil is SetOverride, except that the override is removed once
+// `expires` is reached. Zero `expires` never expires.
+func SetOverrideUntil(target string, state types.State, expires time.Time) {
+	if state != types.Healthy && state != types.Unhealthy {
+		ClearOverride(target)
+		return
+	}
+	key := overrideKey(target)
+	overrides.lock.Lock()
+	overrides.states[key] = override{state: state, expires: expires}
+	overrides.lock.Unlock()
+	if expires.IsZero() {
+		glog.Infof("Checker override: %s forced to %v", key, state)
+	} else {
+		glog.Infof("Checker override: %s forced to %v until %v", key, state,
+			expires.Format(time.RFC3339))
+	}
+}
+
+// ClearOverride removes the state forced of `target` if any.
+func ClearOverride(target string) {
+	key := overrideKey(target)
+	overrides.lock.Lock()
+	_, ok := overrides.states[key]
+	delete(overrides.states, key)
+	overrides.lock.Unlock()
+	if ok {
+		glog.Infof("Checker override: %s removed", key)
+	}
+}
+
+// GetOverride returns the state forced of `target` and when it expires, where
+// zero `expires` never expires, and false if not forced.
+func GetOverride(target string) (state types.State, expires time.Time, ok bool) {
+	o, ok := getOverride(overrideKey(target))
+	return o.state, o.expires, ok
+}
+
+func getOverride(key string) (override, bool) {
+	now := time.Now()
+	overrides.lock.RLock()
+	o, ok := overrides.states[key]
+	overrides.lock.RUnlock()
+	if !ok || !o.expired(now) {
+		return o, ok
+	}
+
+	// Remove the expired one unless it has been replaced.
+	overrides.lock.Lock()
+	if cur, ok := overrides.states[key]; ok && cur.expired(now) {
+		delete(overrides.states, key)
+		glog.Infof("Checker override: %s expired", key)
+	}
+	overrides.lock.Unlock()
+	return override{}, false
+}
+
+// OverrideChecker wraps a CheckMethod and returns the state forced by
+// SetOverride if any. The probe is skipped when the target is forced before
+// the check, and its result is replaced when the target is forced during it.
+// The forced results are marked in CheckResult::Forced.
+type OverrideChecker struct {
+	inner CheckMethod
+}
+
+// NewOverrideChecker returns an OverrideChecker wrapping `inner`.
+func NewOverrideChecker(inner CheckMethod) *OverrideChecker {
+	return &OverrideChecker{inner: inner}
+}
+
+func (c *OverrideChecker) Check(target *utils.L3L4Addr, timeout time.Duration) (types.State, error) {
+	return checkWithTimeout(c, target, timeout)
+}
+
+func (c *OverrideChecker) CheckContext(ctx context.Context, target *utils.L3L4Addr) (types.State, error) {
+	res, err := c.CheckEx(ctx, target)
+	return res.State, err
+}
+
+func (c *OverrideChecker) CheckEx(ctx context.Context, target *utils.L3L4Addr) (*CheckResult, error) {
+	key := target.String()
+	if o, ok := getOverride(key); ok {
+		glog.V(9).Infof("Override check %v %v: probe skipped", key, o.state)
+		return forcedResult(o.state, "probe skipped"), nil
+	}
+
+	res, err := CheckEx(ctx, c.inner, target)
+	if o, ok := getOverride(key); ok {
+		glog.V(9).Infof("Override check %v %v: probed %v replaced", key, o.state, res.State)
+		return forcedResult(o.state, fmt.Sprintf("probed %v replaced", res.State)), nil
+	}
+	return res, err
+}
+
+func forcedResult(state types.State, detail string) *CheckResult {
+	res := &CheckResult{
+		State:  state,
+		Detail: "state forced by override, " + detail,
+		Forced: true,
+	}
+	if state != types.Healthy {
+		res.Reason = ReasonForced
+	}
+	return res
+}
+
+func (c *OverrideChecker) DefaultParams() map[string]string {
+	if c.inner == nil {
+		return map[string]string{}
+	}
+	return c.inner.DefaultParams()
+}
+
+func (c *OverrideChecker) validate(params map[string]string) error {
+	if c.inner == nil {
+		return fmt.Errorf("override checker without inner checker")
+	}
+	return c.inner.validate(params)
+}
+
+func (c *OverrideChecker) create(params map[string]string) (CheckMethod, error) {
+	if err := c.validate(params); err != nil {
+		return nil, fmt.Errorf("override checker param validation failed: %v", err)
+	}
+	inner, err := c.inner.create(params)
+	if err != nil {
+		return nil, err
+	}
+	return NewOverrideChecker(inner), nil
+}
@@ -0,0 +1,125 @@
+// /*
+// Copyright 2025 IQiYi Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// */
+
+package checker
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/types"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/utils"
+)
+
+// blockingChecker blocks the check until `release` is closed.
+type blockingChecker struct {
+	fakeChecker
+	started chan struct{}
+	release chan struct{}
+}
+
+func (c *blockingChecker) CheckContext(ctx context.Context, target *utils.L3L4Addr) (types.State, error) {
+	close(c.started)
+	<-c.release
+	return c.fakeChecker.CheckContext(ctx, target)
+}
+
+func TestOverrideChecker(t *testing.T) {
+	target := &utils.L3L4Addr{IP: net.ParseIP("192.168.88.30"), Port: 80, Proto: utils.IPProtoTCP}
+	inner := &fakeChecker{states: []types.State{types.Unhealthy}}
+	checker := NewOverrideChecker(inner)
+	defer ClearOverride(target.String())
+
+	expect := func(step string, state types.State, reason Reason, forced bool, probes int) {
+		t.Helper()
+		res, err := CheckExTimeout(checker, target, time.Second)
+		if err != nil || res.State != state || res.Reason != reason || res.Forced != forced {
+			t.Errorf("%s: expect %v/%v/%v, got %+v, %v", step, state, reason, forced, res, err)
+		}
+		inner.lock.Lock()
+		defer inner.lock.Unlock()
+		if inner.probes[target.String()] != probes {
+			t.Errorf("%s: expect %d probes, got %d", step, probes, inner.probes[target.String()])
+		}
+	}
+
+	expect("not forced", types.Unhealthy, ReasonUnknown, false, 1)
+
+	// Any representation of the target works.
+	SetOverride("192.168.88.30:80/tcp", types.Healthy)
+	if state, expires, ok := GetOverride(target.String()); !ok || state != types.Healthy || !expires.IsZero() {
+		t.Fatalf("expect override Healthy, got %v, %v, %v", state, expires, ok)
+	}
+	expect("forced healthy", types.Healthy, ReasonNone, true, 1)
+
+	SetOverride(target.String(), types.Unhealthy)
+	expect("forced unhealthy", types.Unhealthy, ReasonForced, true, 1)
+
+	ClearOverride("192.168.88.30-tcp-80")
+	if _, _, ok := GetOverride(target.String()); ok {
+		t.Fatalf("expect override cleared")
+	}
+	expect("cleared", types.Unhealthy, ReasonUnknown, false, 2)
+
+	// States other than Healthy and Unhealthy clear the override.
+	SetOverride(target.String(), types.Healthy)
+	SetOverride(target.String(), types.Unknown)
+	expect("unknown", types.Unhealthy, ReasonUnknown, false, 3)
+
+	// The override is removed once expired.
+	expires := time.Now().Add(50 * time.Millisecond)
+	SetOverrideUntil(target.String(), types.Healthy, expires)
+	if state, got, ok := GetOverride(target.String()); !ok || state != types.Healthy || !got.Equal(expires) {
+		t.Fatalf("expect override Healthy until %v, got %v, %v, %v", expires, state, got, ok)
+	}
+	expect("forced until", types.Healthy, ReasonNone, true, 3)
+	time.Sleep(100 * time.Millisecond)
+	if _, _, ok := GetOverride(target.String()); ok {
+		t.Fatalf("expect override expired")
+	}
+	expect("expired", types.Unhealthy, ReasonUnknown, false, 4)
+}
+
+func TestOverrideCheckerDuringProbe(t *testing.T) {
+	target := &utils.L3L4Addr{IP: net.ParseIP("192.168.88.31"), Port: 80, Proto: utils.IPProtoTCP}
+	inner := &blockingChecker{
+		fakeChecker: fakeChecker{states: []types.State{types.Healthy}},
+		started:     make(chan struct{}),
+		release:     make(chan struct{}),
+	}
+	checker := NewOverrideChecker(inner)
+	defer ClearOverride(target.String())
+
+	var wg sync.WaitGroup
+	var res *CheckResult
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		res, _ = CheckExTimeout(checker, target, time.Second)
+	}()
+
+	// The override set during the probe replaces its result.
+	<-inner.started
+	SetOverride(target.String(), types.Unhealthy)
+	close(inner.release)
+	wg.Wait()
+	if res.State != types.Unhealthy || res.Reason != ReasonForced || !res.Forced {
+		t.Errorf("expect forced Unhealthy, got %+v", res)
+	}
+}
@@ -0,0 +1,135 @@
+// /*
+// Copyright 2025 IQiYi Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// */
+
+package checker
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/utils"
+)
+
+// Encodings of the send/receive payload params.
+const (
+	PayloadEncodingRaw    = "raw"
+	PayloadEncodingHex    = "hex"
+	PayloadEncodingBase64 = "base64"
+)
+
+// payloadTokens are the template tokens allowed in send payloads. They are
+// expanded to the text form of the target address in each check.
+var payloadTokens = map[string]func(target *utils.L3L4Addr) string{
+	".IP": func(target *utils.L3L4Addr) string {
+		return target.IP.String()
+	},
+	".Port": func(target *utils.L3L4Addr) string {
+		return strconv.Itoa(int(target.Port))
+	},
+	".Addr": func(target *utils.L3L4Addr) string {
+		return target.Addr()
+	},
+}
+
+func decodePayload(data, encoding string) ([]byte, error) {
+	switch strings.ToLower(encoding) {
+	case "", PayloadEncodingRaw:
+		return []byte(data), nil
+	case PayloadEncodingHex:
+		return hex.DecodeString(data)
+	case PayloadEncodingBase64:
+		return base64.StdEncoding.DecodeString(data)
+	}
+	return nil, fmt.Errorf("unsupported encoding %q", encoding)
+}
+
+// payloadPart is either decoded literal data, or a template token.
+type payloadPart struct {
+	data  []byte
+	token string
+}
+
+// payloadTemplate is a send payload which may embed the target address with
+// template tokens, such as "{{.IP}}" and "{{.Port}}". The literal data between
+// tokens are decoded separately in the payload encoding.
+type payloadTemplate struct {
+	parts []payloadPart
+	size  int // size of the literal data
+}
+
+// parsePayloadTemplate decodes the payload `data` in `encoding`, and fails on
+// malformed data or unknown template tokens.
+func parsePayloadTemplate(data, encoding string) (*payloadTemplate, error) {
+	tmpl := &payloadTemplate{}
+	for len(data) > 0 {
+		literal := data
+		i := strings.Index(data, "{{")
+		if i >= 0 {
+			literal = data[:i]
+		}
+		if len(literal) > 0 {
+			decoded, err := decodePayload(literal, encoding)
+			if err != nil {
+				return nil, err
+			}
+			tmpl.parts = append(tmpl.parts, payloadPart{data: decoded})
+			tmpl.size += len(decoded)
+		}
+		if i < 0 {
+			break
+		}
+		data = data[i+2:]
+		j := strings.Index(data, "}}")
+		if j < 0 {
+			return nil, fmt.Errorf("unterminated template token %q", "{{"+data)
+		}
+		token := strings.TrimSpace(data[:j])
+		if _, ok := payloadTokens[token]; !ok {
+			return nil, fmt.Errorf("unknown template token %q", "{{"+data[:j]+"}}")
+		}
+		tmpl.parts = append(tmpl.parts, payloadPart{token: token})
+		data = data[j+2:]
+	}
+	return tmpl, nil
+}
+
+// empty returns whether the template generates no data at all.
+func (t *payloadTemplate) empty() bool {
+	return t == nil || len(t.parts) == 0
+}
+
+// expand returns the payload with tokens replaced by the `target` address.
+func (t *payloadTemplate) expand(target *utils.L3L4Addr) []byte {
+	if t.empty() {
+		return nil
+	}
+	if len(t.parts) == 1 && len(t.parts[0].token) == 0 {
+		return t.parts[0].data
+	}
+	var buf bytes.Buffer
+	for _, part := range t.parts {
+		if len(part.token) > 0 {
+			buf.WriteString(payloadTokens[part.token](target))
+		} else {
+			buf.Write(part.data)
+		}
+	}
+	return buf.Bytes()
+}
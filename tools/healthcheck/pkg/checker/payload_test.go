@@ -0,0 +1,74 @@
+// /*
+// Copyright 2025 IQiYi Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// */
+
+package checker
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/utils"
+)
+
+func TestPayloadTemplate(t *testing.T) {
+	v4 := &utils.L3L4Addr{IP: net.ParseIP("192.168.88.30"), Port: 8080, Proto: utils.IPProtoTCP}
+	v6 := &utils.L3L4Addr{IP: net.ParseIP("2001::30"), Port: 53, Proto: utils.IPProtoUDP}
+
+	for _, tc := range []struct {
+		data     string
+		encoding string
+		target   *utils.L3L4Addr
+		expect   []byte
+	}{
+		{"", "", v4, nil},
+		{"hello", "", v4, []byte("hello")},
+		{"GET /{{.IP}}:{{.Port}}", "raw", v4, []byte("GET /192.168.88.30:8080")},
+		{"{{ .Addr }}", "", v6, []byte("[2001::30]:53")},
+		{"{{.IP}}", "hex", v6, []byte("2001::30")},
+		{"00ff{{.Port}}0a", "hex", v4, []byte("\x00\xff8080\n")},
+		{"AAE={{.Port}}", "base64", v6, []byte("\x00\x0153")},
+		{"a{b}c", "", v4, []byte("a{b}c")},
+	} {
+		tmpl, err := parsePayloadTemplate(tc.data, tc.encoding)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", tc.data, err)
+			continue
+		}
+		if got := tmpl.expand(tc.target); !bytes.Equal(got, tc.expect) {
+			t.Errorf("%q: expect %q, got %q", tc.data, tc.expect, got)
+		}
+	}
+
+	for _, tc := range []struct {
+		data     string
+		encoding string
+	}{
+		{"0a0", "hex"},
+		{"zz", "hex"},
+		{"0a{{.IP}}0", "hex"},
+		{"AAJ@", "base64"},
+		{"abc", "utf8"},
+		{"{{.Host}}", ""},
+		{"{{IP}}", ""},
+		{"{{.IP}", ""},
+		{"{{", ""},
+	} {
+		if _, err := parsePayloadTemplate(tc.data, tc.encoding); err == nil {
+			t.Errorf("%q in %q: expect error", tc.data, tc.encoding)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+// /*
+// Copyright 2025 IQiYi Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// */
+
+package checker
+
+import (
+	"fmt"
+	"time"
+)
+
+// Checker params splitting the check timeout into the connect and read phases.
+const (
+	ParamConnectTimeout = "connect-timeout"
+	ParamReadTimeout    = "read-timeout"
+)
+
+// phaseTimeouts bounds the connect and read phases of a check separately, so
+// that a check can fail fast on connect while allowing a longer read. The zero
+// values take the whole check timeout, which is the default behavior. Both of
+// them are capped by the check timeout, and the check never exceeds the larger
+// of them if both are set.
+type phaseTimeouts struct {
+	connect time.Duration
+	read    time.Duration
+}
+
+// parsePhaseTimeout parses the value of ParamConnectTimeout or ParamReadTimeout.
+func parsePhaseTimeout(val string) (time.Duration, error) {
+	d, err := time.ParseDuration(val)
+	if err != nil {
+		return 0, err
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("non-positive duration %v", d)
+	}
+	return d, nil
+}
+
+// set binds the phase timeout `param` to `val`. It returns false if `param` is
+// not a phase timeout param.
+func (p *phaseTimeouts) set(param, val string) (bool, error) {
+	var err error
+	switch param {
+	case ParamConnectTimeout:
+		p.connect, err = parsePhaseTimeout(val)
+	case ParamReadTimeout:
+		p.read, err = parsePhaseTimeout(val)
+	default:
+		return false, nil
+	}
+	return true, err
+}
+
+// limit returns the deadline of the check started at `start` with `deadline`.
+func (p *phaseTimeouts) limit(start, deadline time.Time) time.Time {
+	if p.connect > 0 && p.read > 0 {
+		max := p.connect
+		if p.read > max {
+			max = p.read
+		}
+		if limit := start.Add(max); limit.Before(deadline) {
+			return limit
+		}
+	}
+	return deadline
+}
+
+// dialTimeout returns the timeout to connect in the check started at `start`
+// with `deadline`.
+func (p *phaseTimeouts) dialTimeout(start, deadline time.Time) time.Duration {
+	timeout := p.limit(start, deadline).Sub(start)
+	if p.connect > 0 && p.connect < timeout {
+		return p.connect
+	}
+	return timeout
+}
+
+// readDeadline returns the I/O deadline after connected at `connected` in the
+// check started at `start` with `deadline`.
+func (p *phaseTimeouts) readDeadline(start, connected, deadline time.Time) time.Time {
+	limit := p.limit(start, deadline)
+	if p.read > 0 {
+		if d := connected.Add(p.read); d.Before(limit) {
+			return d
+		}
+	}
+	return limit
+}
@@ -0,0 +1,159 @@
+// /*
+// Copyright 2025 IQiYi Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// */
+
+package checker
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/types"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/utils"
+)
+
+func TestPhaseTimeouts(t *testing.T) {
+	start := time.Now()
+	deadline := start.Add(2 * time.Second)
+	connected := start.Add(100 * time.Millisecond)
+
+	for _, tc := range []struct {
+		name     string
+		timeouts phaseTimeouts
+		dial     time.Duration
+		read     time.Duration // read deadline since start
+	}{
+		{"default", phaseTimeouts{}, 2 * time.Second, 2 * time.Second},
+		{"connect only", phaseTimeouts{connect: 200 * time.Millisecond}, 200 * time.Millisecond, 2 * time.Second},
+		{"read only", phaseTimeouts{read: 500 * time.Millisecond}, 2 * time.Second, 600 * time.Millisecond},
+		{"both", phaseTimeouts{connect: 200 * time.Millisecond, read: time.Second}, 200 * time.Millisecond,
+			time.Second},
+		{"connect larger", phaseTimeouts{connect: time.Second, read: 200 * time.Millisecond}, time.Second,
+			300 * time.Millisecond},
+		{"capped", phaseTimeouts{connect: 3 * time.Second, read: 5 * time.Second}, 2 * time.Second,
+			2 * time.Second},
+	} {
+		if dial := tc.timeouts.dialTimeout(start, deadline); dial != tc.dial {
+			t.Errorf("%s: expect dial timeout %v, got %v", tc.name, tc.dial, dial)
+		}
+		if read := tc.timeouts.readDeadline(start, connected, deadline).Sub(start); read != tc.read {
+			t.Errorf("%s: expect read deadline %v, got %v", tc.name, tc.read, read)
+		}
+	}
+
+	var p phaseTimeouts
+	for _, val := range []string{"0s", "-1s", "1", "abc"} {
+		if ok, err := p.set(ParamReadTimeout, val); !ok || err == nil {
+			t.Errorf("expect %s:%s invalid", ParamReadTimeout, val)
+		}
+	}
+	if ok, _ := p.set("timeout", "1s"); ok {
+		t.Errorf("expect timeout not a phase timeout param")
+	}
+}
+
+// startSilentTCP serves a tcp server which accepts connections but never
+// responds, and returns the target address.
+func startSilentTCP(t *testing.T) *utils.L3L4Addr {
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen tcp: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(func() {
+		cancel()
+		ln.Close()
+	})
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				<-ctx.Done()
+				conn.Close()
+			}()
+		}
+	}()
+	port := ln.Addr().(*net.TCPAddr).Port
+	return &utils.L3L4Addr{IP: net.ParseIP("127.0.0.1"), Port: uint16(port), Proto: utils.IPProtoTCP}
+}
+
+func TestCheckerReadTimeout(t *testing.T) {
+	silent := startSilentTCP(t)
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(300 * time.Millisecond)
+	}))
+	defer slow.Close()
+	slowTarget := &utils.L3L4Addr{IP: net.ParseIP("127.0.0.1"),
+		Port: uint16(slow.Listener.Addr().(*net.TCPAddr).Port), Proto: utils.IPProtoTCP}
+
+	for _, tc := range []struct {
+		name    string
+		method  Method
+		target  *utils.L3L4Addr
+		params  map[string]string
+		expect  types.State
+		elapsed time.Duration // max time elapsed
+	}{
+		{"tcp default", CheckMethodTCP, silent, map[string]string{"send": "a", "receive": "b"},
+			types.Unhealthy, 2 * time.Second},
+		{"tcp read-timeout", CheckMethodTCP, silent, map[string]string{"send": "a", "receive": "b",
+			ParamReadTimeout: "100ms"}, types.Unhealthy, 500 * time.Millisecond},
+		{"tcp both", CheckMethodTCP, silent, map[string]string{"send": "a", "receive": "b",
+			ParamConnectTimeout: "50ms", ParamReadTimeout: "150ms"}, types.Unhealthy, 500 * time.Millisecond},
+		{"http slow", CheckMethodHTTP, slowTarget, map[string]string{ParamConnectTimeout: "100ms"},
+			types.Healthy, time.Second},
+		{"http read-timeout", CheckMethodHTTP, slowTarget, map[string]string{ParamReadTimeout: "100ms"},
+			types.Unhealthy, 250 * time.Millisecond},
+		{"http longer read", CheckMethodHTTP, slowTarget, map[string]string{ParamConnectTimeout: "100ms",
+			ParamReadTimeout: "600ms"}, types.Healthy, time.Second},
+	} {
+		checker, err := NewChecker(tc.method, tc.target, tc.params)
+		if err != nil {
+			t.Fatalf("%s: failed to create checker: %v", tc.name, err)
+		}
+		start := time.Now()
+		state, err := checker.Check(tc.target, time.Second)
+		elapsed := time.Since(start)
+		if err != nil {
+			t.Errorf("%s: failed to execute checker: %v", tc.name, err)
+		} else if state != tc.expect {
+			t.Errorf("%s: expect %v, got %v", tc.name, tc.expect, state)
+		}
+		if elapsed > tc.elapsed {
+			t.Errorf("%s: expect check done in %v, took %v", tc.name, tc.elapsed, elapsed)
+		}
+	}
+}
+
+func TestPhaseTimeoutParams(t *testing.T) {
+	for _, method := range []Method{CheckMethodTCP, CheckMethodUDP, CheckMethodHTTP} {
+		if err := Validate(method, map[string]string{ParamConnectTimeout: "200ms",
+			ParamReadTimeout: "1s"}); err != nil {
+			t.Errorf("%v: expect phase timeouts valid, got %v", method, err)
+		}
+		for _, val := range []string{"0", "-1s", "1"} {
+			if err := Validate(method, map[string]string{ParamConnectTimeout: val}); err == nil {
+				t.Errorf("%v: expect %s:%s invalid", method, ParamConnectTimeout, val)
+			}
+		}
+	}
+}
@@ -17,16 +17,34 @@
 package checker
 
 /*
-Ping Checker Params: None
+Ping Checker Params:
+-----------------------------------
+name                value
+-----------------------------------
+privileged          auto | true | false
+netns               network namespace name or path to check from
+------------------------------------
+
+privileged:
+  - auto: try unprivileged ICMP datagram socket first, and fall back to raw socket
+  - true: use raw socket only, which requires CAP_NET_RAW
+  - false: use unprivileged ICMP datagram socket only, which requires the gid
+    in range of sysctl "net.ipv4.ping_group_range"
 */
 
 import (
 	"bytes"
+	"context"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"math/rand"
 	"net"
 	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/golang/glog"
@@ -34,25 +52,48 @@ import (
 	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/utils"
 )
 
-var _ CheckMethod = (*PingChecker)(nil)
+var _ CheckMethodEx = (*PingChecker)(nil)
+
+var errICMPChecksum = errors.New("bad ICMP checksum")
+
+const (
+	ParamPrivileged = "privileged"
+
+	PingPrivilegedAuto  = "auto"
+	PingPrivilegedTrue  = "true"
+	PingPrivilegedFalse = "false"
+)
 
-var nextPingCheckerId uint16
+var nextPingCheckerId uint32
 
 type PingChecker struct {
-	id     uint16
-	seqnum uint16
+	id         uint16
+	seqnum     uint32 // accessed atomically, only the lower 16 bits are used
+	privileged string
+	netns      string
 }
 
 func init() {
 	registerMethod(CheckMethodPing, &PingChecker{})
 
 	s := rand.NewSource(int64(os.Getpid()))
-	nextPingCheckerId = uint16(s.Int63() & 0xffff)
+	nextPingCheckerId = uint32(s.Int63() & 0xffff)
 }
 
 func (c *PingChecker) Check(target *utils.L3L4Addr, timeout time.Duration) (types.State, error) {
-	if timeout <= time.Duration(0) {
-		return types.Unknown, fmt.Errorf("zero timeout on Ping check")
+	return checkWithTimeout(c, target, timeout)
+}
+
+func (c *PingChecker) CheckContext(ctx context.Context, target *utils.L3L4Addr) (types.State, error) {
+	res, err := c.CheckEx(ctx, target)
+	return res.State, err
+}
+
+func (c *PingChecker) CheckEx(ctx context.Context, target *utils.L3L4Addr) (*CheckResult, error) {
+	start := time.Now()
+	timeout, err := checkTimeout(ctx, "Ping", start)
+	if err != nil {
+		return checkError(start, err)
 	}
 
 	targetCopied := target.DeepCopy()
@@ -61,22 +102,52 @@ func (c *PingChecker) Check(target *utils.L3L4Addr, timeout time.Duration) (type
 	} else {
 		targetCopied.Proto = utils.IPProtoICMPv6
 	}
-	glog.V(9).Infof("Start Ping check to %v ...", targetCopied.IP)
+	ip := targetCopied.ZonedIP()
+	glog.V(9).Infof("Start Ping check to %v ...", ip)
 
-	c.seqnum++
-	echo := newICMPEchoRequest(targetCopied.Proto, c.id, c.seqnum, 64, []byte("DPVS Healthcheck "))
-	if err := exchangeICMPEcho(targetCopied.Network(), targetCopied.IP, timeout, echo); err != nil {
-		glog.V(9).Infof("Ping check %v %v: failed due to %v", targetCopied.IP, types.Unhealthy, err)
-		return types.Unhealthy, nil
+	seqnum := uint16(atomic.AddUint32(&c.seqnum, 1))
+	echo := newICMPEchoRequest(targetCopied.Proto, c.id, seqnum, 64, []byte("DPVS Healthcheck "))
+	dst := &net.IPAddr{IP: targetCopied.IP, Zone: targetCopied.Zone}
+	if err := exchangeICMPEcho(ctx, targetCopied.Network(), dst, timeout, echo,
+		c.privileged, c.netns); err != nil {
+		reason := errReason(err, false)
+		if errors.Is(err, errICMPChecksum) {
+			reason = ReasonProtocolError
+		}
+		return checkFailed("Ping", ip, start, reason, "failed due to %v", err), nil
 	}
 
-	glog.V(9).Infof("Ping check %v %v: succeed", targetCopied.IP, types.Healthy)
-	return types.Healthy, nil
+	return checkSucceed("Ping", ip, start), nil
+}
+
+func (c *PingChecker) DefaultParams() map[string]string {
+	return map[string]string{
+		ParamPrivileged: PingPrivilegedAuto,
+		ParamNetns:      "",
+	}
 }
 
 func (c *PingChecker) validate(params map[string]string) error {
-	if len(params) > 0 {
-		return fmt.Errorf("unsupported ping checker params: %v", params)
+	unsupported := make([]string, 0, len(params))
+	for param, val := range params {
+		switch param {
+		case ParamPrivileged:
+			switch strings.ToLower(val) {
+			case PingPrivilegedAuto, PingPrivilegedTrue, PingPrivilegedFalse:
+			default:
+				return fmt.Errorf("invalid ping checker param value: %s:%s", param, val)
+			}
+		case ParamNetns:
+			if err := utils.ValidateNetns(val); err != nil {
+				return fmt.Errorf("invalid ping checker param value: %s:%s: %v", param, val, err)
+			}
+		default:
+			unsupported = append(unsupported, param)
+		}
+	}
+
+	if len(unsupported) > 0 {
+		return fmt.Errorf("unsupported ping checker params: %q", strings.Join(unsupported, ","))
 	}
 	return nil
 }
@@ -87,10 +158,15 @@ func (c *PingChecker) create(params map[string]string) (CheckMethod, error) {
 	}
 
 	checker := &PingChecker{
-		id:     nextPingCheckerId,
-		seqnum: 0,
+		id:         uint16(atomic.AddUint32(&nextPingCheckerId, 1)),
+		seqnum:     0,
+		privileged: PingPrivilegedAuto,
+	}
+
+	if val, ok := params[ParamPrivileged]; ok {
+		checker.privileged = strings.ToLower(val)
 	}
-	nextPingCheckerId++
+	checker.netns = params[ParamNetns]
 
 	return checker, nil
 }
@@ -169,20 +245,139 @@ func parseICMPEchoReply(msg icmpMsg) (id, seqnum, chksum uint16) {
 	return
 }
 
-func exchangeICMPEcho(network string, ip net.IP, timeout time.Duration, echo icmpMsg) error {
-	c, err := net.ListenPacket(network, "")
+// listenICMP opens an ICMP socket for `network`("ip4:icmp" or "ip6:ipv6-icmp")
+// according to the `privileged` mode, and binds it to `src` if not nil. It
+// returns true if the socket opened is an unprivileged datagram socket.
+func listenICMP(network string, src *net.IPAddr, privileged string) (net.PacketConn, bool, error) {
+	if privileged != PingPrivilegedTrue {
+		conn, err := listenICMPDgram(network, src)
+		if err == nil {
+			return conn, true, nil
+		}
+		if privileged == PingPrivilegedFalse {
+			return nil, false, err
+		}
+		glog.V(9).Infof("Fail to open unprivileged ICMP socket, fall back to raw socket: %v", err)
+	}
+	laddr := ""
+	if src != nil {
+		laddr = src.String()
+	}
+	conn, err := net.ListenPacket(network, laddr)
+	return conn, false, err
+}
+
+// listenICMPDgram opens an ICMP socket with SOCK_DGRAM, i.e., "ping socket".
+// The kernel replaces the echo identifier with the local port of the socket,
+// and delivers only the echo replies matching the identifier to the socket.
+func listenICMPDgram(network string, src *net.IPAddr) (net.PacketConn, error) {
+	var sa syscall.Sockaddr = &syscall.SockaddrInet4{}
+	family, proto := syscall.AF_INET, syscall.IPPROTO_ICMP
+	if strings.HasPrefix(network, "ip6") {
+		sa6 := &syscall.SockaddrInet6{}
+		if src != nil {
+			copy(sa6.Addr[:], src.IP.To16())
+			if ifi, err := zoneInterface(src.Zone); err == nil {
+				sa6.ZoneId = uint32(ifi.Index)
+			}
+		}
+		sa = sa6
+		family, proto = syscall.AF_INET6, syscall.IPPROTO_ICMPV6
+	}
+	fd, err := syscall.Socket(family, syscall.SOCK_DGRAM|syscall.SOCK_CLOEXEC, proto)
+	if err != nil {
+		return nil, os.NewSyscallError("socket", err)
+	}
+	// Bind explicitly so that the echo identifier is allocated beforehand.
+	if err = syscall.Bind(fd, sa); err != nil {
+		syscall.Close(fd)
+		return nil, os.NewSyscallError("bind", err)
+	}
+	f := os.NewFile(uintptr(fd), "icmp-dgram")
+	defer f.Close()
+	return net.FilePacketConn(f)
+}
+
+func addrIP(addr net.Addr) net.IP {
+	switch a := addr.(type) {
+	case *net.IPAddr:
+		return a.IP
+	case *net.UDPAddr:
+		return a.IP
+	}
+	return nil
+}
+
+// zoneInterface returns the interface of an IPv6 zone, which is either an
+// interface name or an interface index.
+func zoneInterface(zone string) (*net.Interface, error) {
+	if idx, err := strconv.Atoi(zone); err == nil {
+		return net.InterfaceByIndex(idx)
+	}
+	return net.InterfaceByName(zone)
+}
+
+// icmpSourceAddr returns the source address to ping `dst` from, or nil if the
+// source can be left to the kernel. The ICMPv6 echo to a zoned link-local
+// address must be sent from an address of the zone interface, which is chosen
+// by the kernel's source address selection with a connected UDP socket, and no
+// packet is sent in the procedure.
+func icmpSourceAddr(dst *net.IPAddr) (*net.IPAddr, error) {
+	if len(dst.Zone) == 0 || dst.IP.To4() != nil || !dst.IP.IsLinkLocalUnicast() {
+		return nil, nil
+	}
+	if _, err := zoneInterface(dst.Zone); err != nil {
+		return nil, fmt.Errorf("invalid zone %q: %w", dst.Zone, err)
+	}
+	conn, err := net.DialUDP("udp6", nil, &net.UDPAddr{IP: dst.IP, Port: 9, Zone: dst.Zone})
+	if err != nil {
+		return nil, fmt.Errorf("no source address to %v: %w", dst, err)
+	}
+	defer conn.Close()
+	laddr := conn.LocalAddr().(*net.UDPAddr)
+	return &net.IPAddr{IP: laddr.IP, Zone: laddr.Zone}, nil
+}
+
+func exchangeICMPEcho(ctx context.Context, network string, dst *net.IPAddr, timeout time.Duration, echo icmpMsg,
+	privileged, netns string) error {
+	var c net.PacketConn
+	var dgram bool
+	// Both the source address lookup and the socket depend on the routes of
+	// the network namespace.
+	err := utils.RunInNetns(netns, func() error {
+		src, err := icmpSourceAddr(dst)
+		if err != nil {
+			return err
+		}
+		c, dgram, err = listenICMP(network, src, privileged)
+		return err
+	})
 	if err != nil {
 		return err
 	}
 	defer c.Close()
+	defer closeOnCancel(ctx, c)()
 
 	c.SetDeadline(time.Now().Add(timeout))
 
-	_, err = c.WriteTo(echo, &net.IPAddr{IP: ip})
+	ip := dst.IP
+	var to net.Addr = dst
+	if dgram {
+		to = &net.UDPAddr{IP: dst.IP, Zone: dst.Zone}
+	}
+	_, err = c.WriteTo(echo, to)
 	if err != nil {
 		return err
 	}
 
+	xid, xseqnum, _ := parseICMPEchoReply(echo)
+	if dgram {
+		// The echo identifier is rewritten by kernel.
+		if laddr, ok := c.LocalAddr().(*net.UDPAddr); ok {
+			xid = uint16(laddr.Port)
+		}
+	}
+
 	reply := make([]byte, 256)
 	for {
 		n, addr, err := c.ReadFrom(reply)
@@ -192,13 +387,12 @@ func exchangeICMPEcho(network string, ip net.IP, timeout time.Duration, echo icm
 		if n < 0 || n > len(reply) {
 			return fmt.Errorf("Unexpect ICMP reply len %d", n)
 		}
-		if !ip.Equal(net.ParseIP(addr.String())) {
+		if !ip.Equal(addrIP(addr)) {
 			continue
 		}
 		if reply[0] != ICMP4_ECHO_REPLY && reply[0] != ICMP6_ECHO_REPLY {
 			continue
 		}
-		xid, xseqnum, _ := parseICMPEchoReply(echo)
 		rid, rseqnum, rchksum := parseICMPEchoReply(reply)
 		if rid != xid || rseqnum != xseqnum {
 			continue
@@ -206,7 +400,7 @@ func exchangeICMPEcho(network string, ip net.IP, timeout time.Duration, echo icm
 		if reply[0] == ICMP4_ECHO_REPLY {
 			cs := icmpChecksum(reply[:n])
 			if cs != 0 {
-				return fmt.Errorf("Bad ICMP checksum: %x, len: %d, data: %v", rchksum, n, reply[:n])
+				return fmt.Errorf("%w: %x, len: %d, data: %v", errICMPChecksum, rchksum, n, reply[:n])
 			}
 		}
 		// TODO(angusc): Validate checksum for IPv6
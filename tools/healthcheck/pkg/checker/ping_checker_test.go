@@ -18,20 +18,22 @@ package checker
 
 import (
 	"net"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/types"
 	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/utils"
 )
 
 var ping_targets = []utils.L3L4Addr{
-	{net.ParseIP("127.0.0.1"), 0, 0},
-	{net.ParseIP("192.168.88.30"), 0, 0},
-	{net.ParseIP("8.8.8.8"), 0, 0},
-	{net.ParseIP("11.22.33.44"), 0, 0},
-	{net.ParseIP("::1"), 0, 0},
-	{net.ParseIP("2001::1"), 0, 0},
-	{net.ParseIP("2001::68"), 0, 0},
+	{IP: net.ParseIP("127.0.0.1")},
+	{IP: net.ParseIP("192.168.88.30")},
+	{IP: net.ParseIP("8.8.8.8")},
+	{IP: net.ParseIP("11.22.33.44")},
+	{IP: net.ParseIP("::1")},
+	{IP: net.ParseIP("2001::1")},
+	{IP: net.ParseIP("2001::68")},
 }
 
 func TestPingChecker(t *testing.T) {
@@ -51,3 +53,93 @@ func TestPingChecker(t *testing.T) {
 		}
 	}
 }
+
+func TestPingCheckerParams(t *testing.T) {
+	valid := []map[string]string{
+		nil,
+		{ParamPrivileged: "auto"},
+		{ParamPrivileged: "TRUE"},
+		{ParamPrivileged: "false"},
+	}
+	for _, params := range valid {
+		if err := (&PingChecker{}).validate(params); err != nil {
+			t.Errorf("unexpected error for ping params %v: %v", params, err)
+		}
+	}
+
+	invalid := []map[string]string{
+		{ParamPrivileged: "yes"},
+		{"send": "hello"},
+	}
+	for _, params := range invalid {
+		if err := (&PingChecker{}).validate(params); err == nil {
+			t.Errorf("expect error for ping params %v", params)
+		}
+	}
+
+	if err := (&UDPPingChecker{}).validate(map[string]string{
+		ParamPrivileged: "false",
+		"send":          "hello",
+	}); err != nil {
+		t.Errorf("unexpected error for udpping params: %v", err)
+	}
+	checker, err := (&UDPPingChecker{}).create(map[string]string{ParamPrivileged: "false"})
+	if err != nil {
+		t.Fatalf("failed to create udpping checker: %v", err)
+	}
+	if c := checker.(*UDPPingChecker); c.privileged != PingPrivilegedFalse {
+		t.Errorf("udpping param not dispatched to ping: privileged %q", c.privileged)
+	}
+}
+
+// pingSocketAvailable reports whether sockets of the `privileged` mode can be opened.
+func pingSocketAvailable(privileged string) bool {
+	conn, _, err := listenICMP("ip4:icmp", nil, privileged)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+func TestPingCheckerPrivileged(t *testing.T) {
+	loopback := &utils.L3L4Addr{IP: net.ParseIP("127.0.0.1")}
+	unreachable := &utils.L3L4Addr{IP: net.ParseIP("198.51.100.1")} // TEST-NET-2
+
+	for _, privileged := range []string{PingPrivilegedAuto, PingPrivilegedTrue, PingPrivilegedFalse} {
+		if !pingSocketAvailable(privileged) {
+			t.Logf("ICMP socket unavailable with privileged %q, skip it", privileged)
+			continue
+		}
+		checker, err := (&PingChecker{}).create(map[string]string{ParamPrivileged: privileged})
+		if err != nil {
+			t.Fatalf("failed to create ping checker: %v", err)
+		}
+
+		// concurrent pings to different targets must not cross-talk
+		var wg sync.WaitGroup
+		states := make([]types.State, 16)
+		for i := range states {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				target := loopback
+				if i%2 == 1 {
+					target = unreachable
+				}
+				states[i], _ = checker.Check(target, 500*time.Millisecond)
+			}(i)
+		}
+		wg.Wait()
+
+		for i, state := range states {
+			expect := types.Healthy
+			if i%2 == 1 {
+				expect = types.Unhealthy
+			}
+			if state != expect {
+				t.Errorf("privileged %q: ping #%d expect %v, got %v", privileged, i, expect, state)
+			}
+		}
+	}
+}
@@ -0,0 +1,156 @@
+// /*
+// Copyright 2025 IQiYi Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// */
+
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/grpcwire"
+	"golang.org/x/net/http2"
+)
+
+const (
+	// execWaitDelay bounds the wait for the output of a killed exec plugin,
+	// whose descendants may hold its stdout and stderr open.
+	execWaitDelay = 100 * time.Millisecond
+	// execMaxStderr limits the stderr of exec plugins kept for the errors.
+	execMaxStderr = 512
+)
+
+// ParseSocket returns the path of the unix socket `uri` in the form of
+// "unix:///path/to/socket".
+func ParseSocket(uri string) (string, error) {
+	path := strings.TrimPrefix(uri, "unix://")
+	if path == uri || !strings.HasPrefix(path, "/") || len(path) < 2 {
+		return "", fmt.Errorf("invalid plugin socket %q, expect unix:///path/to/socket", uri)
+	}
+	return path, nil
+}
+
+// limitedBuffer keeps the first `limit` bytes written, and discards the rest.
+type limitedBuffer struct {
+	bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - b.Len(); len(p) > room {
+		b.truncated = true
+		b.Buffer.Write(p[:room])
+		return len(p), nil
+	}
+	return b.Buffer.Write(p)
+}
+
+// Exec runs the exec plugin `path` for `req`, which reads the request in JSON
+// from its stdin, and writes the response in JSON to its stdout before exit.
+// The plugin runs in its own process group, which is killed once ctx is done,
+// so that no descendants of it are left behind. A plugin killed, exiting with
+// non-zero status, or writing malformed output results in an error.
+func Exec(ctx context.Context, path string, req *Request) (*Response, error) {
+	input, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	stdout := &limitedBuffer{limit: MaxMessageSize}
+	stderr := &limitedBuffer{limit: execMaxStderr}
+
+	cmd := exec.CommandContext(ctx, path)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	cmd.WaitDelay = execWaitDelay
+
+	err = cmd.Run()
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return nil, fmt.Errorf("plugin %s killed: %v", path, ctxErr)
+	}
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); len(msg) > 0 {
+			return nil, fmt.Errorf("plugin %s failed: %v: %s", path, err, msg)
+		}
+		return nil, fmt.Errorf("plugin %s failed: %v", path, err)
+	}
+	if stdout.truncated {
+		return nil, fmt.Errorf("plugin %s output exceeds %d bytes", path, MaxMessageSize)
+	}
+
+	var resp Response
+	if err = json.Unmarshal(stdout.Bytes(), &resp); err != nil {
+		return nil, fmt.Errorf("plugin %s malformed output %q: %v", path, stdout.String(), err)
+	}
+	return &resp, nil
+}
+
+// Client calls the Checker service of a gRPC plugin on a unix socket. The
+// connection is made on the first call, shared by the concurrent calls, and
+// made again once broken, such as when the plugin restarts. It's safe for
+// concurrent use.
+type Client struct {
+	socket string
+	tr     *http2.Transport
+}
+
+// NewClient returns the client of the gRPC plugin on unix socket `socket`.
+func NewClient(socket string) *Client {
+	return &Client{
+		socket: socket,
+		tr: &http2.Transport{
+			AllowHTTP: true,
+			DialTLSContext: func(ctx context.Context, _, _ string, _ *tls.Config) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, "unix", socket)
+			},
+			// A hung plugin is detected by pings if the connection is idle.
+			ReadIdleTimeout: 30 * time.Second,
+			PingTimeout:     5 * time.Second,
+		},
+	}
+}
+
+// Check calls the Check method of the plugin for `req`. The call is canceled
+// once ctx is done, and the deadline of ctx is sent to the plugin.
+func (c *Client) Check(ctx context.Context, req *Request) (*Response, error) {
+	data, err := grpcwire.Call(ctx, c.tr, "http://localhost"+CheckPath, req.Marshal(), MaxMessageSize)
+	if err != nil {
+		return nil, fmt.Errorf("plugin %s call failed: %v", c.socket, err)
+	}
+	var resp Response
+	if err = resp.Unmarshal(data); err != nil {
+		return nil, fmt.Errorf("plugin %s malformed response: %v", c.socket, err)
+	}
+	return &resp, nil
+}
+
+// Close closes the idle connection to the plugin.
+func (c *Client) Close() {
+	c.tr.CloseIdleConnections()
+}
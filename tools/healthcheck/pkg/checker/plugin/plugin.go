@@ -0,0 +1,178 @@
+// /*
+// Copyright 2025 IQiYi Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// */
+
+// Package plugin implements the protocol between the "plugin" check method and
+// the healthcheck plugins, which are the checks of bespoke protocols kept out of
+// the healthcheck program. A plugin is either an executable run once per check,
+// exchanging one JSON request and response over its stdin and stdout, or a
+// long-running gRPC server on a unix socket, serving the Checker service defined
+// in plugin.proto. The messages are encoded without the protobuf runtime, so
+// that no more dependencies are required.
+package plugin
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/grpcwire"
+)
+
+const (
+	// CheckPath is the gRPC method of the Checker service.
+	CheckPath = "/dpvs.healthcheck.plugin.v1.Checker/Check"
+	// MaxMessageSize limits the size of the requests and responses.
+	MaxMessageSize = 64 << 10
+)
+
+// State is the health state reported by plugins.
+type State int32
+
+const (
+	StateUnknown   State = 0
+	StateHealthy   State = 1
+	StateUnhealthy State = 2
+)
+
+func (s State) String() string {
+	switch s {
+	case StateUnknown:
+		return "unknown"
+	case StateHealthy:
+		return "healthy"
+	case StateUnhealthy:
+		return "unhealthy"
+	}
+	return fmt.Sprintf("State(%d)", int32(s))
+}
+
+func (s State) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s *State) UnmarshalText(text []byte) error {
+	for _, state := range []State{StateUnknown, StateHealthy, StateUnhealthy} {
+		if strings.EqualFold(string(text), state.String()) {
+			*s = state
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid state %q", text)
+}
+
+// Request is the CheckRequest message sent to plugins.
+type Request struct {
+	Target    string            `json:"target"` // "IP:port", or "[IP]:port" for IPv6
+	IP        string            `json:"ip"`
+	Port      uint16            `json:"port"`
+	Proto     string            `json:"proto"`
+	Params    map[string]string `json:"params,omitempty"`
+	TimeoutMs uint64            `json:"timeout_ms"`
+}
+
+// Timeout returns the time left for the check.
+func (r *Request) Timeout() time.Duration {
+	return time.Duration(r.TimeoutMs) * time.Millisecond
+}
+
+// Response is the CheckResponse message returned by plugins.
+type Response struct {
+	State  State  `json:"state"`
+	Reason string `json:"reason,omitempty"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// Marshal encodes the request in protobuf.
+func (r *Request) Marshal() []byte {
+	var b []byte
+	b = grpcwire.AppendStringField(b, 1, r.Target)
+	b = grpcwire.AppendStringField(b, 2, r.IP)
+	b = grpcwire.AppendVarintField(b, 3, uint64(r.Port))
+	b = grpcwire.AppendStringField(b, 4, r.Proto)
+	for key, val := range r.Params {
+		var entry []byte
+		entry = grpcwire.AppendStringField(entry, 1, key)
+		entry = grpcwire.AppendStringField(entry, 2, val)
+		b = grpcwire.AppendBytesField(b, 5, entry)
+	}
+	b = grpcwire.AppendVarintField(b, 6, r.TimeoutMs)
+	return b
+}
+
+// Unmarshal decodes the request from protobuf.
+func (r *Request) Unmarshal(data []byte) error {
+	*r = Request{}
+	return grpcwire.WalkFields(data, func(field int, num uint64, buf []byte) error {
+		switch field {
+		case 1:
+			r.Target = string(buf)
+		case 2:
+			r.IP = string(buf)
+		case 3:
+			if num > 0xffff {
+				return fmt.Errorf("invalid port %d", num)
+			}
+			r.Port = uint16(num)
+		case 4:
+			r.Proto = string(buf)
+		case 5:
+			var key, val string
+			if err := grpcwire.WalkFields(buf, func(field int, _ uint64, buf []byte) error {
+				switch field {
+				case 1:
+					key = string(buf)
+				case 2:
+					val = string(buf)
+				}
+				return nil
+			}); err != nil {
+				return err
+			}
+			if r.Params == nil {
+				r.Params = make(map[string]string)
+			}
+			r.Params[key] = val
+		case 6:
+			r.TimeoutMs = num
+		}
+		return nil
+	})
+}
+
+// Marshal encodes the response in protobuf.
+func (r *Response) Marshal() []byte {
+	var b []byte
+	b = grpcwire.AppendVarintField(b, 1, uint64(r.State))
+	b = grpcwire.AppendStringField(b, 2, r.Reason)
+	b = grpcwire.AppendStringField(b, 3, r.Detail)
+	return b
+}
+
+// Unmarshal decodes the response from protobuf.
+func (r *Response) Unmarshal(data []byte) error {
+	*r = Response{}
+	return grpcwire.WalkFields(data, func(field int, num uint64, buf []byte) error {
+		switch field {
+		case 1:
+			r.State = State(num)
+		case 2:
+			r.Reason = string(buf)
+		case 3:
+			r.Detail = string(buf)
+		}
+		return nil
+	})
+}
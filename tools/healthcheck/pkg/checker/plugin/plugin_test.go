@@ -0,0 +1,191 @@
+// /*
+// Copyright 2025 IQiYi Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// */
+
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"net"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/grpcwire"
+	"golang.org/x/net/http2"
+)
+
+func TestMessages(t *testing.T) {
+	for _, req := range []Request{
+		{},
+		{Target: "[2001::1]:80", IP: "2001::1", Port: 80, Proto: "tcp", TimeoutMs: 1500},
+		{Target: "10.0.0.1:53", IP: "10.0.0.1", Port: 53, Proto: "udp",
+			Params: map[string]string{"name": "example.com", "empty": ""}, TimeoutMs: 1},
+	} {
+		var got Request
+		if err := got.Unmarshal(req.Marshal()); err != nil || !reflect.DeepEqual(got, req) {
+			t.Errorf("request %+v: got %+v, %v", req, got, err)
+		}
+	}
+	for _, resp := range []Response{
+		{},
+		{State: StateHealthy, Detail: "ok"},
+		{State: StateUnhealthy, Reason: "timeout", Detail: "no response"},
+	} {
+		var got Response
+		if err := got.Unmarshal(resp.Marshal()); err != nil || got != resp {
+			t.Errorf("response %+v: got %+v, %v", resp, got, err)
+		}
+	}
+
+	// The unknown fields are skipped.
+	data := (&Response{State: StateUnhealthy, Detail: "x"}).Marshal()
+	data = binary.AppendUvarint(data, 9<<3|grpcwire.WireVarint)
+	data = binary.AppendUvarint(data, 300)
+	data = append(data, 10<<3|1, 1, 2, 3, 4, 5, 6, 7, 8)
+	data = grpcwire.AppendStringField(data, 11, "future")
+	var resp Response
+	if err := resp.Unmarshal(data); err != nil || resp.State != StateUnhealthy || resp.Detail != "x" {
+		t.Errorf("response with unknown fields: got %+v, %v", resp, err)
+	}
+	if err := resp.Unmarshal(data[:len(data)-1]); err == nil {
+		t.Errorf("expect error with truncated response")
+	}
+	if err := resp.Unmarshal([]byte{0x0a, 0x80}); err == nil {
+		t.Errorf("expect error with malformed response")
+	}
+}
+
+func TestStateText(t *testing.T) {
+	data, err := json.Marshal(&Response{State: StateUnhealthy, Reason: "timeout"})
+	if err != nil || string(data) != `{"state":"unhealthy","reason":"timeout"}` {
+		t.Errorf("unexpected JSON %s, %v", data, err)
+	}
+	var resp Response
+	if err := json.Unmarshal([]byte(`{"state":"Healthy","detail":"ok"}`), &resp); err != nil ||
+		resp.State != StateHealthy || resp.Detail != "ok" {
+		t.Errorf("unexpected response %+v, %v", resp, err)
+	}
+	if err := json.Unmarshal([]byte(`{"state":"up"}`), &resp); err == nil {
+		t.Errorf("expect error with invalid state")
+	}
+	resp = Response{}
+	if err := json.Unmarshal([]byte(`{}`), &resp); err != nil || resp.State != StateUnknown {
+		t.Errorf("expect unknown state by default, got %+v, %v", resp, err)
+	}
+}
+
+func TestParseSocket(t *testing.T) {
+	if path, err := ParseSocket("unix:///var/run/hc.sock"); err != nil || path != "/var/run/hc.sock" {
+		t.Errorf("unexpected socket path %q, %v", path, err)
+	}
+	for _, uri := range []string{"", "/var/run/hc.sock", "unix://hc.sock", "unix:///", "tcp://127.0.0.1:80"} {
+		if _, err := ParseSocket(uri); err == nil {
+			t.Errorf("expect error with socket %q", uri)
+		}
+	}
+}
+
+func TestServeExec(t *testing.T) {
+	var got Request
+	check := func(ctx context.Context, req *Request) *Response {
+		got = *req
+		if _, ok := ctx.Deadline(); !ok {
+			return &Response{State: StateUnknown, Detail: "no deadline"}
+		}
+		return &Response{State: StateHealthy, Detail: "ok"}
+	}
+	in := `{"target":"10.0.0.1:80","ip":"10.0.0.1","port":80,"proto":"tcp","params":{"k":"v"},"timeout_ms":200}`
+	var out bytes.Buffer
+	if err := ServeExec(strings.NewReader(in), &out, check); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.Target != "10.0.0.1:80" || got.Port != 80 || got.Params["k"] != "v" || got.Timeout() != 200*time.Millisecond {
+		t.Errorf("unexpected request %+v", got)
+	}
+	if out.String() != `{"state":"healthy","detail":"ok"}`+"\n" {
+		t.Errorf("unexpected output %q", out.String())
+	}
+	if err := ServeExec(strings.NewReader("oops"), &out, check); err == nil {
+		t.Errorf("expect error with malformed request")
+	}
+}
+
+// serveRaw serves `handler` over HTTP/2 cleartext on a unix socket.
+func serveRaw(t *testing.T, handler http.Handler) string {
+	socket := filepath.Join(t.TempDir(), "raw.sock")
+	ln, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		server := &http2.Server{}
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go server.ServeConn(conn, &http2.ServeConnOpts{Handler: handler})
+		}
+	}()
+	return socket
+}
+
+func TestClient(t *testing.T) {
+	socket := serveRaw(t, Handler(func(ctx context.Context, req *Request) *Response {
+		deadline, ok := ctx.Deadline()
+		if !ok || time.Until(deadline) > time.Second {
+			return &Response{State: StateUnknown, Detail: "bad deadline"}
+		}
+		return &Response{State: StateUnhealthy, Reason: req.Params["reason"], Detail: req.Target}
+	}))
+	client := NewClient(socket)
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	resp, err := client.Check(ctx, &Request{Target: "10.0.0.1:80", Params: map[string]string{"reason": "timeout"}})
+	if err != nil || *resp != (Response{State: StateUnhealthy, Reason: "timeout", Detail: "10.0.0.1:80"}) {
+		t.Errorf("unexpected response %+v, %v", resp, err)
+	}
+
+	for name, handler := range map[string]http.HandlerFunc{
+		"malformed response": func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/grpc")
+			w.Header().Set("Trailer", "Grpc-Status")
+			w.Write([]byte("oops"))
+			w.Header().Set("Grpc-Status", "0")
+		},
+		"grpc-status": func(w http.ResponseWriter, r *http.Request) {
+			writeStatus(w, grpcUnimplemented, "unknown method")
+		},
+		"http status": func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+		},
+	} {
+		client := NewClient(serveRaw(t, handler))
+		if resp, err := client.Check(ctx, &Request{}); err == nil {
+			t.Errorf("%s: expect error, got %+v", name, resp)
+		}
+		client.Close()
+	}
+}
@@ -0,0 +1,112 @@
+// /*
+// Copyright 2025 IQiYi Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// */
+
+// The sample healthcheck plugin, which checks the banner of TCP services, such
+// as "SSH-2.0-" of SSH servers and "220 " of SMTP servers, by the params
+//
+//	send    data sent once connected, optional
+//	expect  prefix of the data expected from the target, required
+//
+// It works as an exec plugin by default, such as
+//
+//	method: plugin
+//	params:
+//	  exec: /usr/local/bin/hc-banner
+//	  plugin.expect: "SSH-2.0-"
+//
+// or as a gRPC plugin on the unix socket given by -listen, such as
+//
+//	hc-banner -listen unix:///var/run/hc-banner.sock
+//
+// with the checker param "grpc-plugin: unix:///var/run/hc-banner.sock".
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"time"
+
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/checker/plugin"
+)
+
+func check(ctx context.Context, req *plugin.Request) *plugin.Response {
+	expect := req.Params["expect"]
+	if len(expect) == 0 {
+		return &plugin.Response{State: plugin.StateUnknown, Detail: "param expect required"}
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", req.Target)
+	if err != nil {
+		return &plugin.Response{State: plugin.StateUnhealthy, Reason: "unreachable",
+			Detail: fmt.Sprintf("failed to connect: %v", err)}
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if send := req.Params["send"]; len(send) > 0 {
+		if _, err = conn.Write([]byte(send)); err != nil {
+			return &plugin.Response{State: plugin.StateUnhealthy, Reason: "conn-reset",
+				Detail: fmt.Sprintf("failed to send: %v", err)}
+		}
+	}
+	banner := make([]byte, len(expect))
+	n, err := io.ReadFull(conn, banner)
+	if !bytes.HasPrefix([]byte(expect), banner[:n]) {
+		return &plugin.Response{State: plugin.StateUnhealthy, Reason: "payload-mismatch",
+			Detail: fmt.Sprintf("unexpected banner %q", banner[:n])}
+	}
+	if err != nil {
+		reason := "conn-reset"
+		if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+			reason = "timeout"
+		}
+		return &plugin.Response{State: plugin.StateUnhealthy, Reason: reason,
+			Detail: fmt.Sprintf("failed to read banner: %v", err)}
+	}
+	return &plugin.Response{State: plugin.StateHealthy, Detail: fmt.Sprintf("banner %q", banner[:n])}
+}
+
+func main() {
+	listen := flag.String("listen", "", "serve as a gRPC plugin on the unix socket, such as unix:///var/run/hc.sock")
+	flag.Parse()
+
+	if len(*listen) == 0 {
+		if err := plugin.ServeExec(os.Stdin, os.Stdout, check); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	ln, err := plugin.Listen(*listen)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fmt.Fprintf(os.Stderr, "%s serving on %s\n", time.Now().Format(time.RFC3339), *listen)
+	if err = plugin.Serve(ln, check); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
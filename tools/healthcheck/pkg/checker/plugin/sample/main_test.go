@@ -0,0 +1,66 @@
+// /*
+// Copyright 2025 IQiYi Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// */
+
+package main
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/checker/plugin"
+)
+
+func TestCheck(t *testing.T) {
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Write([]byte("SSH-2.0-OpenSSH_9.6\r\n"))
+			conn.Close()
+		}
+	}()
+	closed, _ := net.Listen("tcp4", "127.0.0.1:0")
+	closed.Close()
+
+	for _, tc := range []struct {
+		target string
+		expect string
+		state  plugin.State
+		reason string
+	}{
+		{ln.Addr().String(), "SSH-2.0-", plugin.StateHealthy, ""},
+		{ln.Addr().String(), "220 ", plugin.StateUnhealthy, "payload-mismatch"},
+		{ln.Addr().String(), "SSH-2.0-OpenSSH_9.6\r\nmore", plugin.StateUnhealthy, "conn-reset"},
+		{closed.Addr().String(), "SSH-2.0-", plugin.StateUnhealthy, "unreachable"},
+		{ln.Addr().String(), "", plugin.StateUnknown, ""},
+	} {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		resp := check(ctx, &plugin.Request{Target: tc.target, Params: map[string]string{"expect": tc.expect}})
+		cancel()
+		if resp.State != tc.state || resp.Reason != tc.reason {
+			t.Errorf("%s expect %q: expect %v(%s), got %+v", tc.target, tc.expect, tc.state, tc.reason, resp)
+		}
+	}
+}
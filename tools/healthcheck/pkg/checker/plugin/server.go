@@ -0,0 +1,175 @@
+// /*
+// Copyright 2025 IQiYi Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// */
+
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/grpcwire"
+	"golang.org/x/net/http2"
+)
+
+// CheckFunc checks the target of `req`, and should return before ctx is done,
+// whose deadline is the timeout of the check.
+type CheckFunc func(ctx context.Context, req *Request) *Response
+
+// gRPC status codes used by the server.
+const (
+	grpcInvalidArgument = "3"
+	grpcUnimplemented   = "12"
+	grpcInternal        = "13"
+)
+
+type handler struct {
+	check CheckFunc
+}
+
+// Handler returns the http.Handler serving the Checker service with `check`,
+// which must be served over HTTP/2, such as by Serve.
+func Handler(check CheckFunc) http.Handler {
+	return &handler{check: check}
+}
+
+// writeStatus writes a Trailers-Only response of the gRPC status `code`.
+func writeStatus(w http.ResponseWriter, code, msg string) {
+	w.Header().Set("Content-Type", "application/grpc")
+	w.Header().Set("Grpc-Status", code)
+	if len(msg) > 0 {
+		w.Header().Set("Grpc-Message", msg)
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || !strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+		w.WriteHeader(http.StatusUnsupportedMediaType)
+		return
+	}
+	if r.URL.Path != CheckPath {
+		writeStatus(w, grpcUnimplemented, "unknown method "+r.URL.Path)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, MaxMessageSize+5))
+	if err != nil {
+		writeStatus(w, grpcInternal, err.Error())
+		return
+	}
+	var req Request
+	data, err := grpcwire.Unframe(body)
+	if err == nil {
+		err = req.Unmarshal(data)
+	}
+	if err != nil {
+		writeStatus(w, grpcInvalidArgument, "malformed request: "+err.Error())
+		return
+	}
+
+	ctx := r.Context()
+	if val := r.Header.Get("Grpc-Timeout"); len(val) > 0 {
+		timeout, err := grpcwire.ParseTimeout(val)
+		if err != nil {
+			writeStatus(w, grpcInvalidArgument, err.Error())
+			return
+		}
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	resp, err := h.call(ctx, &req)
+	if err != nil {
+		writeStatus(w, grpcInternal, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/grpc")
+	w.Header().Set("Trailer", "Grpc-Status")
+	w.WriteHeader(http.StatusOK)
+	w.Write(grpcwire.Frame(resp.Marshal()))
+	w.Header().Set("Grpc-Status", grpcwire.StatusOK)
+}
+
+// call calls the CheckFunc, where the panic and the nil response are errors.
+func (h *handler) call(ctx context.Context, req *Request) (resp *Response, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("check panic: %v", r)
+		}
+	}()
+	if resp = h.check(ctx, req); resp == nil {
+		return nil, fmt.Errorf("no response")
+	}
+	return resp, nil
+}
+
+// Serve serves the Checker service with `check` over HTTP/2 cleartext on `ln`,
+// and returns when `ln` is closed.
+func Serve(ln net.Listener, check CheckFunc) error {
+	server := &http2.Server{}
+	opts := &http2.ServeConnOpts{Handler: Handler(check)}
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return err
+		}
+		go func() {
+			defer conn.Close()
+			server.ServeConn(conn, opts)
+		}()
+	}
+}
+
+// Listen listens on the unix socket `uri` in the form of "unix:///path", where
+// the stale socket file left by the previous run is removed.
+func Listen(uri string) (net.Listener, error) {
+	path, err := ParseSocket(uri)
+	if err != nil {
+		return nil, err
+	}
+	if info, err := os.Lstat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		os.Remove(path)
+	}
+	return net.Listen("unix", path)
+}
+
+// ServeExec serves one check as an exec plugin with `check`, which reads the
+// request from `r`, and writes the response to `w`, usually the stdin and the
+// stdout of the plugin.
+func ServeExec(r io.Reader, w io.Writer, check CheckFunc) error {
+	var req Request
+	if err := json.NewDecoder(io.LimitReader(r, MaxMessageSize)).Decode(&req); err != nil {
+		return fmt.Errorf("malformed request: %v", err)
+	}
+	ctx := context.Background()
+	if timeout := req.Timeout(); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	resp := check(ctx, &req)
+	if resp == nil {
+		return fmt.Errorf("no response")
+	}
+	return json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,213 @@
+// /*
+// Copyright 2025 IQiYi Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// */
+
+package checker
+
+/*
+Plugin Checker Params:
+-------------------------------------------------------------
+name                value
+-------------------------------------------------------------
+exec                absolute path of the executable plugin
+grpc-plugin         unix socket of the gRPC plugin, unix:///path/to/socket
+plugin.NAME         param NAME passed to the plugin
+-------------------------------------------------------------
+
+The checker delegates checks to a plugin given by either exec or grpc-plugin,
+see package plugin for the protocol. The exec plugin is run once per check with
+the request in JSON on its stdin, and its process group is killed on timeout.
+The gRPC plugin is a long-running server, whose connection is shared by all the
+checkers of the same socket, and the calls are canceled on timeout.
+
+The healthy and unhealthy states reported by plugins are the results, and the
+reason of unhealthy is one of the check reasons, such as "timeout". The unknown
+state, a plugin failing or timeout, and malformed responses result in Unknown
+with an error.
+*/
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/checker/plugin"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/types"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/utils"
+)
+
+var _ CheckMethodEx = (*PluginChecker)(nil)
+
+const pluginParamPrefix = "plugin."
+
+type PluginChecker struct {
+	exec   string // path of the exec plugin
+	socket string // socket path of the gRPC plugin
+	params map[string]string
+}
+
+// pluginClients caches the clients of gRPC plugins by socket path, which are
+// shared by checkers and never closed.
+var pluginClients = struct {
+	lock    sync.Mutex
+	clients map[string]*plugin.Client
+}{clients: make(map[string]*plugin.Client)}
+
+func pluginClient(socket string) *plugin.Client {
+	pluginClients.lock.Lock()
+	defer pluginClients.lock.Unlock()
+	client, ok := pluginClients.clients[socket]
+	if !ok {
+		client = plugin.NewClient(socket)
+		pluginClients.clients[socket] = client
+	}
+	return client
+}
+
+func init() {
+	registerMethod(CheckMethodPlugin, &PluginChecker{})
+}
+
+func (c *PluginChecker) Check(target *utils.L3L4Addr, timeout time.Duration) (types.State, error) {
+	return checkWithTimeout(c, target, timeout)
+}
+
+func (c *PluginChecker) CheckContext(ctx context.Context, target *utils.L3L4Addr) (types.State, error) {
+	res, err := c.CheckEx(ctx, target)
+	return res.State, err
+}
+
+func (c *PluginChecker) name() string {
+	if len(c.exec) > 0 {
+		return c.exec
+	}
+	return c.socket
+}
+
+func (c *PluginChecker) CheckEx(ctx context.Context, target *utils.L3L4Addr) (*CheckResult, error) {
+	start := time.Now()
+	timeout, err := checkTimeout(ctx, "Plugin", start)
+	if err != nil {
+		return checkError(start, err)
+	}
+
+	addr := target.Addr()
+	glog.V(9).Infof("Start Plugin check to %s via %s ...", addr, c.name())
+
+	req := &plugin.Request{
+		Target:    addr,
+		IP:        target.ZonedIP(),
+		Port:      target.Port,
+		Proto:     strings.ToLower(target.Proto.String()),
+		Params:    c.params,
+		TimeoutMs: uint64(timeout.Milliseconds()),
+	}
+	var resp *plugin.Response
+	if len(c.exec) > 0 {
+		resp, err = plugin.Exec(ctx, c.exec, req)
+	} else {
+		resp, err = pluginClient(c.socket).Check(ctx, req)
+	}
+	if err != nil {
+		return checkError(start, err)
+	}
+
+	switch resp.State {
+	case plugin.StateHealthy:
+		res := checkSucceed("Plugin", addr, start)
+		res.Detail = resp.Detail
+		return res, nil
+	case plugin.StateUnhealthy:
+		detail := resp.Detail
+		if len(detail) == 0 {
+			detail = "reported by plugin " + c.name()
+		}
+		return checkFailed("Plugin", addr, start, parseReason(resp.Reason), "%s", detail), nil
+	case plugin.StateUnknown:
+		return checkError(start, fmt.Errorf("plugin %s reported unknown state: %s", c.name(), resp.Detail))
+	}
+	return checkError(start, fmt.Errorf("plugin %s reported invalid %v", c.name(), resp.State))
+}
+
+func (c *PluginChecker) DefaultParams() map[string]string {
+	return map[string]string{
+		"exec":        "",
+		"grpc-plugin": "",
+	}
+}
+
+func (c *PluginChecker) validate(params map[string]string) error {
+	unsupported := make([]string, 0, len(params))
+	for param, val := range params {
+		switch param {
+		case "exec":
+			if !filepath.IsAbs(val) {
+				return fmt.Errorf("invalid plugin checker param value: %s:%s, absolute path required",
+					param, val)
+			}
+			info, err := os.Stat(val)
+			if err != nil {
+				return fmt.Errorf("invalid plugin checker param value: %s:%s, %v", param, val, err)
+			}
+			if !info.Mode().IsRegular() || info.Mode().Perm()&0111 == 0 {
+				return fmt.Errorf("invalid plugin checker param value: %s:%s, not executable", param, val)
+			}
+		case "grpc-plugin":
+			if _, err := plugin.ParseSocket(val); err != nil {
+				return fmt.Errorf("invalid plugin checker param value: %s:%s, %v", param, val, err)
+			}
+		default:
+			if strings.HasPrefix(param, pluginParamPrefix) && len(param) > len(pluginParamPrefix) {
+				continue
+			}
+			unsupported = append(unsupported, param)
+		}
+	}
+
+	if len(unsupported) > 0 {
+		return fmt.Errorf("unsupported plugin checker params: %q", strings.Join(unsupported, ","))
+	}
+	_, hasExec := params["exec"]
+	_, hasGRPC := params["grpc-plugin"]
+	if hasExec == hasGRPC {
+		return fmt.Errorf("plugin checker requires either param exec or grpc-plugin")
+	}
+	return nil
+}
+
+func (c *PluginChecker) create(params map[string]string) (CheckMethod, error) {
+	if err := c.validate(params); err != nil {
+		return nil, fmt.Errorf("plugin checker param validation failed: %v", err)
+	}
+
+	checker := &PluginChecker{
+		exec:   params["exec"],
+		params: make(map[string]string),
+	}
+	if val, ok := params["grpc-plugin"]; ok {
+		checker.socket, _ = plugin.ParseSocket(val)
+	}
+	for param, val := range params {
+		if strings.HasPrefix(param, pluginParamPrefix) {
+			checker.params[strings.TrimPrefix(param, pluginParamPrefix)] = val
+		}
+	}
+	return checker, nil
+}
@@ -0,0 +1,253 @@
+// /*
+// Copyright 2025 IQiYi Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// */
+
+package checker
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/checker/plugin"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/types"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/utils"
+)
+
+// pluginTestScript behaves as the "mode" param of the request, and saves the
+// request to "$0.req", and the pid of the process it hangs on to "$0.pid".
+const pluginTestScript = `#!/bin/sh
+req=$(cat)
+echo "$req" > "$0.req"
+case "$req" in
+*'"mode":"healthy"'*) echo '{"state":"healthy","detail":"ok"}' ;;
+*'"mode":"unhealthy"'*) echo '{"state":"unhealthy","reason":"bad-status","detail":"status 503"}' ;;
+*'"mode":"unknown"'*) echo '{"state":"unknown","detail":"not sure"}' ;;
+*'"mode":"malformed"'*) echo 'oops' ;;
+*'"mode":"crash"'*) echo 'boom' >&2; exit 3 ;;
+*'"mode":"hang"'*) sleep 30 & echo $! > "$0.pid"; wait ;;
+esac
+`
+
+func pluginTestCheck(ctx context.Context, req *plugin.Request) *plugin.Response {
+	switch req.Params["mode"] {
+	case "healthy":
+		return &plugin.Response{State: plugin.StateHealthy, Detail: "ok"}
+	case "unhealthy":
+		return &plugin.Response{State: plugin.StateUnhealthy, Reason: "bad-status", Detail: "status 503"}
+	case "unknown":
+		return &plugin.Response{State: plugin.StateUnknown, Detail: "not sure"}
+	case "malformed":
+		return nil
+	case "crash":
+		panic("boom")
+	case "hang":
+		time.Sleep(3 * time.Second) // ctx ignored
+	}
+	return &plugin.Response{State: plugin.StateHealthy}
+}
+
+// pluginTestServer serves pluginTestCheck on a unix socket, and counts the
+// connections accepted.
+type pluginTestServer struct {
+	net.Listener
+	lock     sync.Mutex
+	conns    []net.Conn
+	accepted int32
+}
+
+func startPluginServer(t *testing.T, socket string) *pluginTestServer {
+	ln, err := plugin.Listen("unix://" + socket)
+	if err != nil {
+		t.Fatalf("failed to listen on %s: %v", socket, err)
+	}
+	s := &pluginTestServer{Listener: ln}
+	go plugin.Serve(s, pluginTestCheck)
+	t.Cleanup(s.shutdown)
+	return s
+}
+
+func (s *pluginTestServer) Accept() (net.Conn, error) {
+	conn, err := s.Listener.Accept()
+	if err == nil {
+		atomic.AddInt32(&s.accepted, 1)
+		s.lock.Lock()
+		s.conns = append(s.conns, conn)
+		s.lock.Unlock()
+	}
+	return conn, err
+}
+
+// shutdown closes the listener and the connections, as if the plugin crashes.
+func (s *pluginTestServer) shutdown() {
+	s.Close()
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for _, conn := range s.conns {
+		conn.Close()
+	}
+	s.conns = nil
+}
+
+func TestPluginChecker(t *testing.T) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "plugin.sh")
+	if err := os.WriteFile(script, []byte(pluginTestScript), 0755); err != nil {
+		t.Fatalf("failed to write plugin script: %v", err)
+	}
+	socket := filepath.Join(dir, "plugin.sock")
+	server := startPluginServer(t, socket)
+
+	target := &utils.L3L4Addr{IP: net.ParseIP("192.168.88.10"), Port: 8080, Proto: utils.IPProtoTCP}
+	timeout := 500 * time.Millisecond
+	for _, transport := range []map[string]string{
+		{"exec": script},
+		{"grpc-plugin": "unix://" + socket},
+	} {
+		for _, tc := range []struct {
+			mode   string
+			state  types.State
+			reason Reason
+			errMsg string
+		}{
+			{"healthy", types.Healthy, ReasonNone, ""},
+			{"unhealthy", types.Unhealthy, ReasonBadStatus, ""},
+			{"unknown", types.Unknown, ReasonUnknown, "reported unknown state: not sure"},
+			{"malformed", types.Unknown, ReasonUnknown, ""},
+			{"crash", types.Unknown, ReasonUnknown, ""},
+			{"hang", types.Unknown, ReasonUnknown, ""},
+		} {
+			params := map[string]string{"plugin.mode": tc.mode}
+			for k, v := range transport {
+				params[k] = v
+			}
+			name := tc.mode + " " + params["exec"] + params["grpc-plugin"]
+			checker, err := NewChecker(CheckMethodPlugin, target, params)
+			if err != nil {
+				t.Fatalf("%s: failed to create checker: %v", name, err)
+			}
+			start := time.Now()
+			res, err := CheckExTimeout(checker, target, timeout)
+			if elapsed := time.Since(start); elapsed > timeout+500*time.Millisecond {
+				t.Errorf("%s: expect the timeout enforced, took %v", name, elapsed)
+			}
+			if res.State != tc.state || res.Reason != tc.reason {
+				t.Errorf("%s: expect %v(%v), got %v(%v): %s", name, tc.state, tc.reason,
+					res.State, res.Reason, res.Detail)
+			}
+			if (err != nil) != (tc.state == types.Unknown) {
+				t.Errorf("%s: unexpected error %v", name, err)
+			} else if err != nil && !strings.Contains(err.Error(), tc.errMsg) {
+				t.Errorf("%s: expect error %q, got %v", name, tc.errMsg, err)
+			}
+		}
+	}
+
+	// The request sent to the exec plugin.
+	data, err := os.ReadFile(script + ".req")
+	if err != nil {
+		t.Fatalf("failed to read the request saved: %v", err)
+	}
+	var req plugin.Request
+	if err = json.Unmarshal(data, &req); err != nil {
+		t.Fatalf("malformed request %q: %v", data, err)
+	}
+	if req.Target != "192.168.88.10:8080" || req.IP != "192.168.88.10" || req.Port != 8080 ||
+		req.Proto != "tcp" || req.Params["mode"] != "hang" || len(req.Params) != 1 ||
+		req.TimeoutMs == 0 || req.TimeoutMs > uint64(timeout.Milliseconds()) {
+		t.Errorf("unexpected request %+v", req)
+	}
+
+	// The descendants of the hanging exec plugin are killed.
+	data, err = os.ReadFile(script + ".pid")
+	if err != nil {
+		t.Fatalf("failed to read the pid saved: %v", err)
+	}
+	pid, _ := strconv.Atoi(strings.TrimSpace(string(data)))
+	time.Sleep(100 * time.Millisecond)
+	if stat, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat"); err == nil {
+		// a zombie is dead already, which is reaped by its new parent later
+		if fields := strings.Fields(string(stat)); len(fields) < 3 || fields[2] != "Z" {
+			t.Errorf("descendant %d of the hanging plugin is left running", pid)
+		}
+	}
+
+	// The connection to the gRPC plugin is shared by all the checks, even if
+	// some of them are canceled.
+	if accepted := atomic.LoadInt32(&server.accepted); accepted != 1 {
+		t.Errorf("expect 1 connection to the gRPC plugin, got %d", accepted)
+	}
+
+	// The gRPC plugin restarts.
+	checker, err := NewChecker(CheckMethodPlugin, target, map[string]string{
+		"grpc-plugin": "unix://" + socket, "plugin.mode": "healthy"})
+	if err != nil {
+		t.Fatalf("failed to create checker: %v", err)
+	}
+	server.shutdown()
+	if state, err := checker.Check(target, timeout); err == nil || state != types.Unknown {
+		t.Errorf("expect Unknown with error when the gRPC plugin is down, got %v, %v", state, err)
+	}
+	startPluginServer(t, socket)
+	if state, err := checker.Check(target, timeout); err != nil || state != types.Healthy {
+		t.Errorf("expect Healthy after the gRPC plugin restarts, got %v, %v", state, err)
+	}
+}
+
+func TestPluginCheckerParams(t *testing.T) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "plugin.sh")
+	if err := os.WriteFile(script, []byte(pluginTestScript), 0755); err != nil {
+		t.Fatalf("failed to write plugin script: %v", err)
+	}
+	data := filepath.Join(dir, "data")
+	if err := os.WriteFile(data, nil, 0644); err != nil {
+		t.Fatalf("failed to write data file: %v", err)
+	}
+
+	for _, tc := range []struct {
+		params map[string]string
+		valid  bool
+	}{
+		{map[string]string{"exec": script}, true},
+		{map[string]string{"exec": script, "plugin.mode": "healthy", "plugin.x": ""}, true},
+		{map[string]string{"grpc-plugin": "unix:///var/run/hc-foo.sock"}, true},
+		{map[string]string{}, false},
+		{map[string]string{"exec": script, "grpc-plugin": "unix:///var/run/hc-foo.sock"}, false},
+		{map[string]string{"exec": "plugin.sh"}, false},
+		{map[string]string{"exec": filepath.Join(dir, "missing")}, false},
+		{map[string]string{"exec": data}, false},
+		{map[string]string{"exec": dir}, false},
+		{map[string]string{"grpc-plugin": "/var/run/hc-foo.sock"}, false},
+		{map[string]string{"grpc-plugin": "unix://hc-foo.sock"}, false},
+		{map[string]string{"exec": script, "plugin.": "x"}, false},
+		{map[string]string{"exec": script, "mode": "healthy"}, false},
+	} {
+		_, err := NewChecker(CheckMethodPlugin, nil, tc.params)
+		if tc.valid && err != nil {
+			t.Errorf("unexpected error with %v: %v", tc.params, err)
+		} else if !tc.valid && err == nil {
+			t.Errorf("expect error with %v", tc.params)
+		}
+	}
+}
@@ -0,0 +1,231 @@
+// /*
+// Copyright 2025 IQiYi Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// */
+
+package checker
+
+/*
+Remote Agent Checker Params:
+-----------------------------------
+name                value
+-----------------------------------
+agent               host:port of the admin API of the partner agent, required
+vs                  VS ID, only the target in the VS is concerned if given
+max-age             the remote verdict checked before max-age ago is stale
+------------------------------------
+
+The checker asks a partner healthcheck agent for its view of the target via
+"GET /targets/{addr}" of the admin API, rather than probing the target itself.
+The target is Healthy if the agent judges it Healthy in any of the concerned
+VSes, and Unhealthy if in none is it Healthy but in some Unhealthy. The result
+is Unknown if the agent is unreachable, the target is not checked by the agent,
+or all the remote verdicts are Unknown or stale.
+*/
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/types"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/utils"
+)
+
+var _ CheckMethodEx = (*RemoteAgentChecker)(nil)
+
+const remoteAgentMaxResponse = 1 << 20
+
+type RemoteAgentChecker struct {
+	agent  string
+	vs     string
+	maxAge time.Duration // 0 means no limit
+}
+
+// remoteTargetInfo is the subset of manager.TargetInfo concerned by the checker.
+type remoteTargetInfo struct {
+	VS         string     `json:"vs"`
+	State      string     `json:"state"`
+	LastCheck  *time.Time `json:"last-check,omitempty"`
+	LastResult *struct {
+		Reason string `json:"reason"`
+		Detail string `json:"detail,omitempty"`
+	} `json:"last-result,omitempty"`
+}
+
+func init() {
+	registerMethod(CheckMethodRemoteAgent, &RemoteAgentChecker{})
+}
+
+func (c *RemoteAgentChecker) Check(target *utils.L3L4Addr, timeout time.Duration) (types.State, error) {
+	return checkWithTimeout(c, target, timeout)
+}
+
+func (c *RemoteAgentChecker) CheckContext(ctx context.Context, target *utils.L3L4Addr) (types.State, error) {
+	res, err := c.CheckEx(ctx, target)
+	return res.State, err
+}
+
+func (c *RemoteAgentChecker) CheckEx(ctx context.Context, target *utils.L3L4Addr) (*CheckResult, error) {
+	start := time.Now()
+	timeout, err := checkTimeout(ctx, "RemoteAgent", start)
+	if err != nil {
+		return checkError(start, err)
+	}
+
+	addr := target.String()
+	glog.V(9).Infof("Start RemoteAgent check to %s via %s ...", addr, c.agent)
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: (&net.Dialer{
+				Timeout: timeout,
+			}).DialContext,
+		},
+		Timeout: timeout,
+	}
+	defer client.CloseIdleConnections()
+
+	uri := "http://" + c.agent + "/targets/" + url.PathEscape(addr)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		return checkError(start, fmt.Errorf("failed to create agent request: %v", err))
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return checkError(start, fmt.Errorf("agent %s unavailable: %v", c.agent, err))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return checkError(start, fmt.Errorf("target %s not checked by agent %s", addr, c.agent))
+	}
+	if resp.StatusCode != http.StatusOK {
+		return checkError(start, fmt.Errorf("unexpected response code %d from agent %s",
+			resp.StatusCode, c.agent))
+	}
+	var infos []remoteTargetInfo
+	if err = json.NewDecoder(io.LimitReader(resp.Body, remoteAgentMaxResponse)).Decode(&infos); err != nil {
+		return checkError(start, fmt.Errorf("malformed response from agent %s: %v", c.agent, err))
+	}
+
+	var unhealthy *remoteTargetInfo
+	concerned, stale := 0, 0
+	for i := range infos {
+		info := &infos[i]
+		if len(c.vs) > 0 && info.VS != c.vs {
+			continue
+		}
+		concerned++
+		if c.maxAge > 0 && (info.LastCheck == nil || start.Sub(*info.LastCheck) > c.maxAge) {
+			stale++
+			continue
+		}
+		switch info.State {
+		case types.Healthy.String():
+			return checkSucceed("RemoteAgent", addr, start), nil
+		case types.Unhealthy.String():
+			if unhealthy == nil {
+				unhealthy = info
+			}
+		}
+	}
+	if unhealthy != nil {
+		reason, detail := ReasonUnknown, ""
+		if res := unhealthy.LastResult; res != nil {
+			reason, detail = parseReason(res.Reason), res.Detail
+		}
+		return checkFailed("RemoteAgent", addr, start, reason,
+			"agent %s judges Unhealthy in vs %s: %s", c.agent, unhealthy.VS, detail), nil
+	}
+	if concerned == 0 {
+		return checkError(start, fmt.Errorf("target %s not checked by agent %s in vs %s",
+			addr, c.agent, c.vs))
+	}
+	if stale > 0 {
+		return checkError(start, fmt.Errorf("agent %s verdict on %s stale, %d of %d older than %v",
+			c.agent, addr, stale, concerned, c.maxAge))
+	}
+	return checkError(start, fmt.Errorf("agent %s verdict on %s unknown", c.agent, addr))
+}
+
+// parseReason returns the Reason of name `name`, or ReasonUnknown if no match.
+func parseReason(name string) Reason {
+	for r := ReasonNone; r <= ReasonLatency; r++ {
+		if r.String() == name {
+			return r
+		}
+	}
+	return ReasonUnknown
+}
+
+func (c *RemoteAgentChecker) DefaultParams() map[string]string {
+	return map[string]string{
+		"agent":   "",
+		"vs":      "",
+		"max-age": "",
+	}
+}
+
+func (c *RemoteAgentChecker) validate(params map[string]string) error {
+	unsupported := make([]string, 0, len(params))
+	for param, val := range params {
+		switch param {
+		case "agent":
+			if _, _, err := net.SplitHostPort(val); err != nil || strings.ContainsAny(val, "/?#") {
+				return fmt.Errorf("invalid remote-agent checker param value: %s:%s", param, val)
+			}
+		case "vs":
+			if len(val) == 0 {
+				return fmt.Errorf("invalid remote-agent checker param value: %s:%s", param, val)
+			}
+		case "max-age":
+			if d, err := time.ParseDuration(val); err != nil || d < 0 {
+				return fmt.Errorf("invalid remote-agent checker param value: %s:%s", param, val)
+			}
+		default:
+			unsupported = append(unsupported, param)
+		}
+	}
+
+	if len(unsupported) > 0 {
+		return fmt.Errorf("unsupported remote-agent checker params: %q", strings.Join(unsupported, ","))
+	}
+	if _, ok := params["agent"]; !ok {
+		return fmt.Errorf("remote-agent checker param agent required")
+	}
+	return nil
+}
+
+func (c *RemoteAgentChecker) create(params map[string]string) (CheckMethod, error) {
+	if err := c.validate(params); err != nil {
+		return nil, fmt.Errorf("remote-agent checker param validation failed: %v", err)
+	}
+
+	checker := &RemoteAgentChecker{
+		agent: params["agent"],
+		vs:    params["vs"],
+	}
+	if val, ok := params["max-age"]; ok {
+		checker.maxAge, _ = time.ParseDuration(val)
+	}
+	return checker, nil
+}
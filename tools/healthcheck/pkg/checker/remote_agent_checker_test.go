@@ -0,0 +1,135 @@
+// /*
+// Copyright 2025 IQiYi Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// */
+
+package checker
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/types"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/utils"
+)
+
+// startRemoteAgent serves "GET /targets/{addr}" with `infos` keyed by target,
+// which are formatted with the time of last check.
+func startRemoteAgent(t *testing.T, infos map[string]string) string {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var info string
+		target, err := utils.ParseL3L4AddrE(strings.TrimPrefix(r.URL.Path, "/targets/"))
+		if err == nil {
+			for addr, val := range infos {
+				if utils.ParseL3L4Addr(addr).String() == target.String() {
+					info = val
+				}
+			}
+		}
+		if r.Method != http.MethodGet || len(info) == 0 {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, info, time.Now().Format(time.RFC3339Nano))
+	}))
+	t.Cleanup(srv.Close)
+	return strings.TrimPrefix(srv.URL, "http://")
+}
+
+func TestRemoteAgentChecker(t *testing.T) {
+	target := func(s string) *utils.L3L4Addr {
+		return utils.ParseL3L4Addr(s)
+	}
+	agent := startRemoteAgent(t, map[string]string{
+		"192.168.88.30:80/tcp": `[{"vs":"vs1","target":"192.168.88.30:80/tcp","state":"Healthy",
+			"last-check":"%s","last-result":{"reason":"none","latency":"1ms"}}]`,
+		"192.168.88.31:80/tcp": `[{"vs":"vs1","target":"192.168.88.31:80/tcp","state":"Unhealthy",
+			"last-check":"%s","last-result":{"reason":"conn-refused","latency":"1ms",
+			"detail":"failed to dial"}}]`,
+		"192.168.88.32:80/tcp": `[{"vs":"vs1","state":"Unhealthy","last-check":"%[1]s"},
+			{"vs":"vs2","state":"Healthy","last-check":"%[1]s"}]`,
+		"192.168.88.33:80/tcp": `[{"vs":"vs1","state":"Healthy","last-check":"2025-01-01T00:00:00Z"},
+			{"vs":"vs2","state":"Unknown","last-check":"%s"}]`,
+		"[fe80::1%eth1]:80/tcp": `[{"vs":"vs1","state":"Healthy","last-check":"%s"}]`,
+	})
+	create := func(params map[string]string) CheckMethod {
+		c, err := (&RemoteAgentChecker{}).create(params)
+		if err != nil {
+			t.Fatalf("failed to create remote-agent checker with %v: %v", params, err)
+		}
+		return c
+	}
+	timeout := time.Second
+	plain := create(map[string]string{"agent": agent})
+
+	expectResult(t, "healthy", plain, target("192.168.88.30:80/tcp"), timeout, types.Healthy, ReasonNone)
+	expectResult(t, "unhealthy", plain, target("192.168.88.31:80/tcp"), timeout,
+		types.Unhealthy, ReasonConnRefused)
+	expectResult(t, "healthy in any vs", plain, target("192.168.88.32:80/tcp"), timeout,
+		types.Healthy, ReasonNone)
+	expectResult(t, "unhealthy in vs1", create(map[string]string{"agent": agent, "vs": "vs1"}),
+		target("192.168.88.32:80/tcp"), timeout, types.Unhealthy, ReasonUnknown)
+	expectResult(t, "zoned target", plain, target("[fe80::1%eth1]:80/tcp"), timeout,
+		types.Healthy, ReasonNone)
+
+	down := closedTCPPort(t)
+	for _, c := range []struct {
+		name   string
+		method CheckMethod
+		target string
+	}{
+		{"not checked", plain, "192.168.88.40:80/tcp"},
+		{"not checked in vs", create(map[string]string{"agent": agent, "vs": "vs3"}), "192.168.88.30:80/tcp"},
+		{"stale", create(map[string]string{"agent": agent, "max-age": "1m"}), "192.168.88.33:80/tcp"},
+		{"agent down", create(map[string]string{"agent": down.Addr()}), "192.168.88.30:80/tcp"},
+	} {
+		res, err := CheckExTimeout(c.method, target(c.target), timeout)
+		if err == nil || res.State != types.Unknown {
+			t.Errorf("%s: expect %v with error, got %v, %v", c.name, types.Unknown, res.State, err)
+		}
+	}
+
+	// A backend is down only if both the local and the remote checks fail.
+	RegisterScriptedChecker(NewScriptedChecker(nil))
+	defer delete(methods, CheckMethodScripted)
+	for addr, state := range map[string]types.State{
+		"192.168.88.30:80/tcp": types.Healthy,
+		"192.168.88.31:80/tcp": types.Unhealthy,
+		"192.168.88.40:80/tcp": types.Unhealthy,
+	} {
+		c := newCompositeChecker(t, map[string]string{"policy": "or", "a.method": "scripted",
+			"a.states": "down", "b.method": "remote-agent", "b.agent": agent})
+		if res, err := CheckExTimeout(c, target(addr), timeout); err != nil || res.State != state {
+			t.Errorf("composite %s: expect %v, got %v, %v", addr, state, res.State, err)
+		}
+	}
+
+	for _, params := range []map[string]string{
+		{},
+		{"agent": "localhost"},
+		{"agent": "localhost:8080/targets"},
+		{"agent": agent, "vs": ""},
+		{"agent": agent, "max-age": "-1s"},
+		{"agent": agent, "no-such-param": "x"},
+	} {
+		if _, err := (&RemoteAgentChecker{}).create(params); err == nil {
+			t.Errorf("invalid params accepted: %v", params)
+		}
+	}
+}
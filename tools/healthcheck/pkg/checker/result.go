@@ -0,0 +1,266 @@
+// /*
+// Copyright 2025 IQiYi Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// */
+
+package checker
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/types"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/utils"
+)
+
+// Reason classifies the cause of a check result.
+type Reason uint16
+
+const (
+	ReasonNone            Reason = iota // "none", check succeeded
+	ReasonUnknown                       // "unknown", unclassified failure
+	ReasonDialTimeout                   // "dial-timeout"
+	ReasonConnRefused                   // "conn-refused", TCP RST or ICMP port unreachable
+	ReasonConnReset                     // "conn-reset", connection reset or closed by peer
+	ReasonUnreachable                   // "unreachable", host or network unreachable
+	ReasonTimeout                       // "timeout", no response in time after connected
+	ReasonTLSFailure                    // "tls-failure"
+	ReasonBadStatus                     // "bad-status", unexpected status such as HTTP code
+	ReasonPayloadMismatch               // "payload-mismatch"
+	ReasonProtocolError                 // "protocol-error", malformed response
+	ReasonForced                        // "forced", state forced by override, see SetOverride
+	ReasonLatency                       // "latency", succeeded but slower than the latency threshold
+)
+
+func (r Reason) String() string {
+	switch r {
+	case ReasonNone:
+		return "none"
+	case ReasonUnknown:
+		return "unknown"
+	case ReasonDialTimeout:
+		return "dial-timeout"
+	case ReasonConnRefused:
+		return "conn-refused"
+	case ReasonConnReset:
+		return "conn-reset"
+	case ReasonUnreachable:
+		return "unreachable"
+	case ReasonTimeout:
+		return "timeout"
+	case ReasonTLSFailure:
+		return "tls-failure"
+	case ReasonBadStatus:
+		return "bad-status"
+	case ReasonPayloadMismatch:
+		return "payload-mismatch"
+	case ReasonProtocolError:
+		return "protocol-error"
+	case ReasonForced:
+		return "forced"
+	case ReasonLatency:
+		return "latency"
+	}
+	return fmt.Sprintf("Reason(%d)", r)
+}
+
+func (r Reason) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+// CheckResult is the result of a check with diagnostics.
+type CheckResult struct {
+	State   types.State
+	Reason  Reason
+	Latency time.Duration // time elapsed until the result is determined
+	Detail  string        // human readable description, optional
+	Forced  bool          // state forced by override rather than probed
+}
+
+// CheckMethodEx is a CheckMethod reporting the check result with diagnostics.
+type CheckMethodEx interface {
+	CheckMethod
+	// CheckEx executes a healthcheck procedure like CheckContext, and returns
+	// the result with diagnostics. The returned result is never nil.
+	CheckEx(ctx context.Context, target *utils.L3L4Addr) (*CheckResult, error)
+}
+
+// CheckEx executes a healthcheck of `method` within the deadline of ctx, and
+// returns the result with diagnostics. The method not implementing CheckMethodEx
+// is adapted, whose failure reason is always ReasonUnknown.
+func CheckEx(ctx context.Context, method CheckMethod, target *utils.L3L4Addr) (*CheckResult, error) {
+	if m, ok := method.(CheckMethodEx); ok {
+		return m.CheckEx(ctx, target)
+	}
+	start := time.Now()
+	state, err := method.CheckContext(ctx, target)
+	res := &CheckResult{State: state, Latency: time.Since(start)}
+	if state != types.Healthy {
+		res.Reason = ReasonUnknown
+	}
+	if err != nil {
+		res.Detail = err.Error()
+	}
+	return res, err
+}
+
+// CheckExTimeout is CheckEx with a context of `timeout`.
+func CheckExTimeout(method CheckMethod, target *utils.L3L4Addr, timeout time.Duration) (*CheckResult, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return CheckEx(ctx, method, target)
+}
+
+// checkWithTimeout runs CheckContext of `method` with a context of `timeout`,
+// which implements Check of the method.
+func checkWithTimeout(method CheckMethod, target *utils.L3L4Addr, timeout time.Duration) (types.State, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return method.CheckContext(ctx, target)
+}
+
+// checkTimeout returns the time left for a check of `kind` started at `start`
+// before the deadline of ctx. It fails if no time left or ctx has no deadline.
+func checkTimeout(ctx context.Context, kind string, start time.Time) (time.Duration, error) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return 0, fmt.Errorf("no deadline on %s check", kind)
+	}
+	if err := ctx.Err(); err == context.Canceled {
+		return 0, fmt.Errorf("%s check canceled", kind)
+	}
+	timeout := deadline.Sub(start)
+	if timeout <= 0 {
+		return 0, fmt.Errorf("zero timeout on %s check", kind)
+	}
+	return timeout, nil
+}
+
+// closeOnCancel closes `c` once ctx is canceled to abort the blocking I/O on it
+// immediately. The deadline of ctx is not concerned, which should be applied to
+// `c` by the caller. The returned function must be called when `c` is done with.
+func closeOnCancel(ctx context.Context, c io.Closer) func() {
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			if ctx.Err() == context.Canceled {
+				c.Close()
+			}
+		case <-done:
+		}
+	}()
+	return func() { close(done) }
+}
+
+// checkSucceed logs the success of a check, and returns its result.
+func checkSucceed(kind, addr string, start time.Time) *CheckResult {
+	glog.V(9).Infof("%s check %v %v: succeed", kind, addr, types.Healthy)
+	return &CheckResult{State: types.Healthy, Latency: time.Since(start)}
+}
+
+// ParamMaxLatency is the checker param of the latency threshold, over which a
+// check fails even if it succeeds otherwise.
+const ParamMaxLatency = "max-latency"
+
+// checkLatency applies `maxLatency` to the succeeded result `res` of a check
+// with the `latency` measured from dialing to the first response. The result
+// turns Unhealthy if the latency exceeds `maxLatency`, and is given the latency
+// in the detail otherwise. It does nothing if `maxLatency` is not set or `res`
+// is not Healthy.
+func checkLatency(kind, addr string, res *CheckResult, latency, maxLatency time.Duration) *CheckResult {
+	if maxLatency <= 0 || res.State != types.Healthy {
+		return res
+	}
+	latency = latency.Round(time.Microsecond)
+	if latency > maxLatency {
+		detail := fmt.Sprintf("latency %v exceeds max-latency %v", latency, maxLatency)
+		glog.V(9).Infof("%s check %v %v: %s", kind, addr, types.Unhealthy, detail)
+		return &CheckResult{
+			State:   types.Unhealthy,
+			Reason:  ReasonLatency,
+			Latency: res.Latency,
+			Detail:  detail,
+		}
+	}
+	res.Detail = fmt.Sprintf("latency %v", latency)
+	return res
+}
+
+// checkFailed logs the failure of a check, and returns its result with the
+// log message as detail.
+func checkFailed(kind, addr string, start time.Time, reason Reason, format string,
+	args ...interface{}) *CheckResult {
+	detail := fmt.Sprintf(format, args...)
+	glog.V(9).Infof("%s check %v %v: %s", kind, addr, types.Unhealthy, detail)
+	return &CheckResult{
+		State:   types.Unhealthy,
+		Reason:  reason,
+		Latency: time.Since(start),
+		Detail:  detail,
+	}
+}
+
+// checkError returns the result of a check failed to execute.
+func checkError(start time.Time, err error) (*CheckResult, error) {
+	return &CheckResult{
+		State:   types.Unknown,
+		Reason:  ReasonUnknown,
+		Latency: time.Since(start),
+		Detail:  err.Error(),
+	}, err
+}
+
+// errReason classifies a network error, `dialing` tells if err occurs when
+// establishing the connection.
+func errReason(err error, dialing bool) Reason {
+	var netErr net.Error
+	var recordErr tls.RecordHeaderError
+	var verifyErr *tls.CertificateVerificationError
+	var authorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidErr x509.CertificateInvalidError
+
+	switch {
+	case err == nil:
+		return ReasonNone
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return ReasonConnRefused
+	case errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.EPIPE),
+		errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return ReasonConnReset
+	case errors.Is(err, syscall.EHOSTUNREACH), errors.Is(err, syscall.ENETUNREACH):
+		return ReasonUnreachable
+	case errors.As(err, &recordErr), errors.As(err, &verifyErr), errors.As(err, &authorityErr),
+		errors.As(err, &hostnameErr), errors.As(err, &invalidErr),
+		strings.Contains(err.Error(), "tls: "),
+		strings.Contains(err.Error(), "HTTP response to HTTPS client"):
+		return ReasonTLSFailure
+	case errors.As(err, &netErr) && netErr.Timeout():
+		if dialing {
+			return ReasonDialTimeout
+		}
+		return ReasonTimeout
+	}
+	return ReasonUnknown
+}
@@ -0,0 +1,368 @@
+// /*
+// Copyright 2025 IQiYi Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// */
+
+package checker
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/types"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/utils"
+)
+
+func tcpTarget(addr net.Addr) *utils.L3L4Addr {
+	a := addr.(*net.TCPAddr)
+	return &utils.L3L4Addr{IP: a.IP, Port: uint16(a.Port), Proto: utils.IPProtoTCP}
+}
+
+// startTCPServer serves each connection with `handle` in a new goroutine.
+func startTCPServer(t *testing.T, handle func(conn *net.TCPConn)) *utils.L3L4Addr {
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				handle(conn.(*net.TCPConn))
+			}()
+		}
+	}()
+	return tcpTarget(ln.Addr())
+}
+
+// closedTCPPort returns a target whose port is not listened.
+func closedTCPPort(t *testing.T) *utils.L3L4Addr {
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	target := tcpTarget(ln.Addr())
+	ln.Close()
+	return target
+}
+
+// blackholeTCPPort returns a target whose connecting times out, which listens
+// with zero backlog, and the backlog is occupied.
+func blackholeTCPPort(t *testing.T) *utils.L3L4Addr {
+	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_STREAM|syscall.SOCK_CLOEXEC, 0)
+	if err != nil {
+		t.Fatalf("failed to create socket: %v", err)
+	}
+	t.Cleanup(func() { syscall.Close(fd) })
+	if err = syscall.Bind(fd, &syscall.SockaddrInet4{Addr: [4]byte{127, 0, 0, 1}}); err != nil {
+		t.Fatalf("failed to bind: %v", err)
+	}
+	if err = syscall.Listen(fd, 0); err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	sa, _ := syscall.Getsockname(fd)
+	target := &utils.L3L4Addr{IP: net.ParseIP("127.0.0.1"), Port: uint16(sa.(*syscall.SockaddrInet4).Port),
+		Proto: utils.IPProtoTCP}
+	conn, err := net.DialTimeout("tcp4", target.Addr(), time.Second)
+	if err != nil {
+		t.Fatalf("failed to occupy the backlog: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return target
+}
+
+func expectResult(t *testing.T, name string, method CheckMethod, target *utils.L3L4Addr,
+	timeout time.Duration, state types.State, reason Reason) {
+	t.Helper()
+	start := time.Now()
+	res, err := CheckExTimeout(method, target, timeout)
+	if err != nil {
+		t.Errorf("%s: unexpected error: %v", name, err)
+		return
+	}
+	if res.State != state || res.Reason != reason {
+		t.Errorf("%s: expect %v(%v), got %v(%v): %s", name, state, reason, res.State, res.Reason, res.Detail)
+	}
+	if res.Latency <= 0 || res.Latency > time.Since(start) {
+		t.Errorf("%s: invalid latency %v", name, res.Latency)
+	}
+	if state, err := method.Check(target, timeout); err != nil || state != res.State {
+		t.Errorf("%s: Check returns %v, %v inconsistent with CheckEx", name, state, err)
+	}
+}
+
+func TestCheckResultTCP(t *testing.T) {
+	echo := startTCPServer(t, func(conn *net.TCPConn) {
+		buf := make([]byte, 4)
+		if _, err := io.ReadFull(conn, buf); err != nil {
+			return
+		}
+		switch string(buf) {
+		case "ping":
+			conn.Write([]byte("pong"))
+		case "bang":
+			conn.Write([]byte("bong"))
+		case "shut":
+			conn.Write([]byte("po"))
+		case "rset":
+			conn.SetLinger(0)
+		case "mute":
+			io.Copy(io.Discard, conn)
+		}
+	})
+	create := func(send string) CheckMethod {
+		checker, err := (&TCPChecker{}).create(map[string]string{"send": send, "receive": "pong"})
+		if err != nil {
+			t.Fatalf("failed to create tcp checker: %v", err)
+		}
+		return checker
+	}
+	timeout := 300 * time.Millisecond
+
+	expectResult(t, "succeed", create("ping"), echo, timeout, types.Healthy, ReasonNone)
+	expectResult(t, "connect only", &TCPChecker{}, echo, timeout, types.Healthy, ReasonNone)
+	expectResult(t, "mismatch", create("bang"), echo, timeout, types.Unhealthy, ReasonPayloadMismatch)
+	expectResult(t, "closed early", create("shut"), echo, timeout, types.Unhealthy, ReasonConnReset)
+	expectResult(t, "reset", create("rset"), echo, timeout, types.Unhealthy, ReasonConnReset)
+	expectResult(t, "no response", create("mute"), echo, timeout, types.Unhealthy, ReasonTimeout)
+	expectResult(t, "refused", create("ping"), closedTCPPort(t), timeout, types.Unhealthy, ReasonConnRefused)
+	expectResult(t, "dial timeout", create("ping"), blackholeTCPPort(t), timeout,
+		types.Unhealthy, ReasonDialTimeout)
+}
+
+func TestCheckResultUDP(t *testing.T) {
+	server := startUDPScript(t, map[string][][]byte{"ping": {[]byte("pong")}, "bang": {[]byte("bong")}})
+	create := func(send string) CheckMethod {
+		checker, err := (&UDPChecker{}).create(map[string]string{"send": send, "receive": "pong"})
+		if err != nil {
+			t.Fatalf("failed to create udp checker: %v", err)
+		}
+		return checker
+	}
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen udp: %v", err)
+	}
+	port := conn.LocalAddr().(*net.UDPAddr).Port
+	conn.Close()
+	closed := &utils.L3L4Addr{IP: net.ParseIP("127.0.0.1"), Port: uint16(port), Proto: utils.IPProtoUDP}
+	timeout := 300 * time.Millisecond
+
+	expectResult(t, "succeed", create("ping"), server, timeout, types.Healthy, ReasonNone)
+	expectResult(t, "mismatch", create("bang"), server, timeout, types.Unhealthy, ReasonPayloadMismatch)
+	expectResult(t, "no response", create("mute"), server, timeout, types.Unhealthy, ReasonTimeout)
+	expectResult(t, "refused", create("ping"), closed, timeout, types.Unhealthy, ReasonConnRefused)
+	expectResult(t, "silent", &UDPChecker{}, server, timeout, types.Healthy, ReasonTimeout)
+}
+
+func TestCheckResultHTTP(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("ok")) })
+	mux.HandleFunc("/error", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(2 * time.Second):
+		case <-r.Context().Done():
+		}
+	})
+	mux.HandleFunc("/loop", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/loop", http.StatusFound)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	tlsServer := httptest.NewTLSServer(mux)
+	defer tlsServer.Close()
+	target := tcpTarget(server.Listener.Addr())
+	tlsTarget := tcpTarget(tlsServer.Listener.Addr())
+
+	create := func(params map[string]string) CheckMethod {
+		checker, err := (&HTTPChecker{}).create(params)
+		if err != nil {
+			t.Fatalf("failed to create http checker: %v", err)
+		}
+		return checker
+	}
+	timeout := 500 * time.Millisecond
+
+	expectResult(t, "succeed", create(map[string]string{"uri": "/ok", "response": "ok"}),
+		target, timeout, types.Healthy, ReasonNone)
+	expectResult(t, "bad status", create(map[string]string{"uri": "/error"}),
+		target, timeout, types.Unhealthy, ReasonBadStatus)
+	expectResult(t, "mismatch", create(map[string]string{"uri": "/ok", "response": "okay"}),
+		target, timeout, types.Unhealthy, ReasonPayloadMismatch)
+	expectResult(t, "too many redirects", create(map[string]string{"uri": "/loop",
+		"follow-redirects": "true", "max-redirects": "2"}), target, timeout, types.Unhealthy, ReasonBadStatus)
+	expectResult(t, "timeout", create(map[string]string{"uri": "/slow"}),
+		target, timeout, types.Unhealthy, ReasonTimeout)
+	expectResult(t, "refused", create(map[string]string{"uri": "/ok"}),
+		closedTCPPort(t), timeout, types.Unhealthy, ReasonConnRefused)
+	expectResult(t, "dial timeout", create(map[string]string{"uri": "/ok"}),
+		blackholeTCPPort(t), timeout, types.Unhealthy, ReasonDialTimeout)
+	expectResult(t, "tls", create(map[string]string{"uri": "/ok", "https": "true", "tls-verify": "false"}),
+		tlsTarget, timeout, types.Healthy, ReasonNone)
+	expectResult(t, "tls untrusted", create(map[string]string{"uri": "/ok", "https": "true"}),
+		tlsTarget, timeout, types.Unhealthy, ReasonTLSFailure)
+	expectResult(t, "tls to plain", create(map[string]string{"uri": "/ok", "https": "true"}),
+		target, timeout, types.Unhealthy, ReasonTLSFailure)
+}
+
+func TestCheckResultPing(t *testing.T) {
+	if _, _, err := listenICMP("ip4:icmp", nil, PingPrivilegedAuto); err != nil {
+		t.Skipf("ICMP socket unavailable: %v", err)
+	}
+	ping, _ := (&PingChecker{}).create(nil)
+	localhost := &utils.L3L4Addr{IP: net.ParseIP("127.0.0.1"), Port: 53, Proto: utils.IPProtoUDP}
+	expectResult(t, "ping", ping, localhost, time.Second, types.Healthy, ReasonNone)
+
+	udpping, _ := (&UDPPingChecker{PingChecker: &PingChecker{}, UDPChecker: &UDPChecker{}}).create(
+		map[string]string{"send": "ping", "receive": "pong"})
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen udp: %v", err)
+	}
+	closed := *localhost
+	closed.Port = uint16(conn.LocalAddr().(*net.UDPAddr).Port)
+	conn.Close()
+	expectResult(t, "udpping refused", udpping, &closed, time.Second, types.Unhealthy, ReasonConnRefused)
+	server := startUDPScript(t, map[string][][]byte{"ping": {[]byte("pong")}})
+	expectResult(t, "udpping", udpping, server, time.Second, types.Healthy, ReasonNone)
+}
+
+func TestCheckResultMaxLatency(t *testing.T) {
+	slowTCP := startTCPServer(t, func(conn *net.TCPConn) {
+		buf := make([]byte, 4)
+		if _, err := io.ReadFull(conn, buf); err != nil {
+			return
+		}
+		time.Sleep(200 * time.Millisecond)
+		conn.Write(buf)
+	})
+	slowHTTP := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer slowHTTP.Close()
+	httpTarget := tcpTarget(slowHTTP.Listener.Addr())
+
+	for _, tc := range []struct {
+		name   string
+		method Method
+		target *utils.L3L4Addr
+		params map[string]string
+		state  types.State
+		reason Reason
+	}{
+		{"tcp fast", CheckMethodTCP, slowTCP, map[string]string{"send": "ping", "receive": "ping",
+			ParamMaxLatency: "800ms"}, types.Healthy, ReasonNone},
+		{"tcp slow", CheckMethodTCP, slowTCP, map[string]string{"send": "ping", "receive": "ping",
+			ParamMaxLatency: "100ms"}, types.Unhealthy, ReasonLatency},
+		{"tcp connect only", CheckMethodTCP, slowTCP, map[string]string{ParamMaxLatency: "100ms"},
+			types.Healthy, ReasonNone},
+		{"http fast", CheckMethodHTTP, httpTarget, map[string]string{ParamMaxLatency: "800ms"},
+			types.Healthy, ReasonNone},
+		{"http slow", CheckMethodHTTP, httpTarget, map[string]string{ParamMaxLatency: "100ms"},
+			types.Unhealthy, ReasonLatency},
+	} {
+		checker, err := NewChecker(tc.method, tc.target, tc.params)
+		if err != nil {
+			t.Fatalf("%s: failed to create checker: %v", tc.name, err)
+		}
+		expectResult(t, tc.name, checker, tc.target, time.Second, tc.state, tc.reason)
+		res, _ := CheckExTimeout(checker, tc.target, time.Second)
+		if !strings.HasPrefix(res.Detail, "latency ") {
+			t.Errorf("%s: expect latency in detail, got %q", tc.name, res.Detail)
+		}
+	}
+
+	for _, method := range []Method{CheckMethodTCP, CheckMethodHTTP} {
+		for _, val := range []string{"", "0", "-1s", "100"} {
+			if _, err := NewChecker(method, slowTCP, map[string]string{ParamMaxLatency: val}); err == nil {
+				t.Errorf("%v: expect error with %s=%q", method, ParamMaxLatency, val)
+			}
+		}
+	}
+}
+
+func TestCheckResultAdapter(t *testing.T) {
+	target := &utils.L3L4Addr{IP: net.ParseIP("127.0.0.1"), Port: 80, Proto: utils.IPProtoTCP}
+	fake := &fakeChecker{states: []types.State{types.Healthy, types.Unhealthy}}
+	if _, ok := CheckMethod(fake).(CheckMethodEx); ok {
+		t.Fatalf("fakeChecker should not implement CheckMethodEx")
+	}
+	for _, expect := range []*CheckResult{
+		{State: types.Healthy, Reason: ReasonNone},
+		{State: types.Unhealthy, Reason: ReasonUnknown},
+	} {
+		res, err := CheckExTimeout(fake, target, time.Second)
+		if err != nil || res.State != expect.State || res.Reason != expect.Reason || res.Latency <= 0 {
+			t.Errorf("adapted: expect %+v, got %+v, %v", expect, res, err)
+		}
+	}
+
+	res, err := CheckExTimeout(&TCPChecker{}, target, 0)
+	if err == nil || res == nil || res.State != types.Unknown || res.Reason != ReasonUnknown {
+		t.Errorf("expect error result on zero timeout, got %+v, %v", res, err)
+	}
+
+	// Skipped probes of backoff checker keep the last reason.
+	backoff := NewBackoffChecker(&TCPChecker{}, time.Minute, time.Minute)
+	closed := closedTCPPort(t)
+	expectResult(t, "backoff", backoff, closed, time.Second, types.Unhealthy, ReasonConnRefused)
+	res, _ = CheckExTimeout(backoff, closed, time.Second)
+	if res.State != types.Unhealthy || res.Reason != ReasonConnRefused || res.Latency != 0 {
+		t.Errorf("unexpected skipped result: %+v", res)
+	}
+}
+
+func TestErrReason(t *testing.T) {
+	timeoutErr := &net.OpError{Op: "dial", Net: "tcp", Err: os.ErrDeadlineExceeded}
+	for _, tc := range []struct {
+		err     error
+		dialing bool
+		expect  Reason
+	}{
+		{nil, false, ReasonNone},
+		{timeoutErr, true, ReasonDialTimeout},
+		{timeoutErr, false, ReasonTimeout},
+		{&net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}, true, ReasonConnRefused},
+		{&net.OpError{Op: "read", Err: os.NewSyscallError("read", syscall.ECONNRESET)}, false, ReasonConnReset},
+		{fmt.Errorf("read: %w", io.EOF), false, ReasonConnReset},
+		{&net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.EHOSTUNREACH)}, true, ReasonUnreachable},
+		{tls.RecordHeaderError{Msg: "first record does not look like a TLS handshake"}, false, ReasonTLSFailure},
+		{errors.New("something wrong"), false, ReasonUnknown},
+	} {
+		if got := errReason(tc.err, tc.dialing); got != tc.expect {
+			t.Errorf("errReason(%v, %v): expect %v, got %v", tc.err, tc.dialing, tc.expect, got)
+		}
+	}
+	if text, _ := ReasonPayloadMismatch.MarshalText(); string(text) != "payload-mismatch" {
+		t.Errorf("unexpected reason text %q", text)
+	}
+}
@@ -0,0 +1,210 @@
+// /*
+// Copyright 2025 IQiYi Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// */
+
+package checker
+
+/*
+Scripted Checker Params:
+-------------------------------------------------------------
+name                value
+-------------------------------------------------------------
+states              comma-separated states, healthy|unhealthy|unknown
+loop                true | false(default), replay states from the start
+-------------------------------------------------------------
+
+The checker is an in-memory fake for tests, which returns a predetermined
+sequence of states without touching network. Each check consumes one state,
+and the last state is repeated when the sequence is exhausted unless `loop` is
+set. An unknown state is returned as a check error. The states are shared by
+all targets, but every checker created by NewChecker has its own sequence.
+
+The method CheckMethodScripted is unavailable until RegisterScriptedChecker is
+called, so it never shows up in the production configs.
+*/
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/types"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/utils"
+)
+
+var _ CheckMethodEx = (*ScriptedChecker)(nil)
+
+// ScriptedChecker is a CheckMethod returning a predetermined sequence of states.
+type ScriptedChecker struct {
+	states []types.State
+	loop   bool
+
+	lock    sync.Mutex
+	next    int
+	targets []string // targets checked in order, for assertion in tests
+}
+
+// NewScriptedChecker returns a ScriptedChecker with the sequence of `states`.
+// An empty sequence always results in the unknown state.
+func NewScriptedChecker(states []types.State) *ScriptedChecker {
+	return &ScriptedChecker{
+		states: append([]types.State(nil), states...),
+	}
+}
+
+// WithLoop makes the checker replay states from the start when exhausted.
+func (c *ScriptedChecker) WithLoop() *ScriptedChecker {
+	c.loop = true
+	return c
+}
+
+// RegisterScriptedChecker registers `c` as the template of method
+// CheckMethodScripted, whose states are used by checkers created without the
+// "states" param. Registering again replaces the template.
+func RegisterScriptedChecker(c *ScriptedChecker) {
+	methods[CheckMethodScripted] = c
+}
+
+func (c *ScriptedChecker) Check(target *utils.L3L4Addr, timeout time.Duration) (types.State, error) {
+	return checkWithTimeout(c, target, timeout)
+}
+
+func (c *ScriptedChecker) CheckContext(ctx context.Context, target *utils.L3L4Addr) (types.State, error) {
+	res, err := c.CheckEx(ctx, target)
+	return res.State, err
+}
+
+func (c *ScriptedChecker) CheckEx(ctx context.Context, target *utils.L3L4Addr) (*CheckResult, error) {
+	start := time.Now()
+	if _, err := checkTimeout(ctx, "Scripted", start); err != nil {
+		return checkError(start, err)
+	}
+
+	c.lock.Lock()
+	state := types.Unknown
+	if n := len(c.states); n > 0 {
+		i := c.next
+		if i >= n {
+			if c.loop {
+				i %= n
+			} else {
+				i = n - 1
+			}
+		}
+		state = c.states[i]
+	}
+	seq := c.next
+	c.next++
+	c.targets = append(c.targets, target.String())
+	c.lock.Unlock()
+
+	addr := target.String()
+	switch state {
+	case types.Healthy:
+		return checkSucceed("Scripted", addr, start), nil
+	case types.Unhealthy:
+		return checkFailed("Scripted", addr, start, ReasonUnknown, "scripted failure #%d", seq), nil
+	}
+	return checkError(start, fmt.Errorf("scripted unknown state #%d", seq))
+}
+
+// Checks returns the number of checks executed.
+func (c *ScriptedChecker) Checks() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.next
+}
+
+// Targets returns the targets checked in order.
+func (c *ScriptedChecker) Targets() []string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return append([]string(nil), c.targets...)
+}
+
+// Reset rewinds the sequence to the start, and forgets the targets checked.
+func (c *ScriptedChecker) Reset() {
+	c.lock.Lock()
+	c.next = 0
+	c.targets = nil
+	c.lock.Unlock()
+}
+
+// parseScriptedStates parses the comma-separated states of the "states" param.
+func parseScriptedStates(val string) ([]types.State, error) {
+	var states []types.State
+	for _, s := range strings.Split(val, ",") {
+		switch strings.ToLower(strings.TrimSpace(s)) {
+		case "healthy", "up":
+			states = append(states, types.Healthy)
+		case "unhealthy", "down":
+			states = append(states, types.Unhealthy)
+		case "unknown":
+			states = append(states, types.Unknown)
+		default:
+			return nil, fmt.Errorf("invalid state %q", s)
+		}
+	}
+	return states, nil
+}
+
+func (c *ScriptedChecker) DefaultParams() map[string]string {
+	return map[string]string{
+		"states": "",
+		"loop":   "false",
+	}
+}
+
+func (c *ScriptedChecker) validate(params map[string]string) error {
+	unsupported := make([]string, 0, len(params))
+	for param, val := range params {
+		switch param {
+		case "states":
+			if _, err := parseScriptedStates(val); err != nil {
+				return fmt.Errorf("invalid scripted checker param value: %s:%s, %v", param, val, err)
+			}
+		case "loop":
+			if _, err := utils.String2bool(val); err != nil {
+				return fmt.Errorf("invalid scripted checker param value: %s:%s", param, val)
+			}
+		default:
+			unsupported = append(unsupported, param)
+		}
+	}
+
+	if len(unsupported) > 0 {
+		return fmt.Errorf("unsupported scripted checker params: %q", strings.Join(unsupported, ","))
+	}
+	return nil
+}
+
+func (c *ScriptedChecker) create(params map[string]string) (CheckMethod, error) {
+	if err := c.validate(params); err != nil {
+		return nil, fmt.Errorf("scripted checker param validation failed: %v", err)
+	}
+
+	states, loop := c.states, c.loop
+	if val, ok := params["states"]; ok {
+		states, _ = parseScriptedStates(val)
+	}
+	if val, ok := params["loop"]; ok {
+		loop, _ = utils.String2bool(val)
+	}
+	checker := NewScriptedChecker(states)
+	checker.loop = loop
+	return checker, nil
+}
@@ -0,0 +1,117 @@
+// /*
+// Copyright 2025 IQiYi Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// */
+
+package checker
+
+import (
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/types"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/utils"
+)
+
+func TestScriptedChecker(t *testing.T) {
+	target := &utils.L3L4Addr{IP: net.ParseIP("192.168.88.30"), Port: 80, Proto: utils.IPProtoTCP}
+	timeout := time.Second
+	run := func(c CheckMethod, n int) []types.State {
+		states := make([]types.State, n)
+		for i := range states {
+			res, err := CheckExTimeout(c, target, timeout)
+			if (err != nil) != (res.State == types.Unknown) {
+				t.Errorf("check #%d: unexpected result %v with error %v", i, res, err)
+			}
+			states[i] = res.State
+		}
+		return states
+	}
+	H, U, X := types.Healthy, types.Unhealthy, types.Unknown
+
+	c := NewScriptedChecker([]types.State{H, U, X})
+	if got := run(c, 5); !reflect.DeepEqual(got, []types.State{H, U, X, X, X}) {
+		t.Errorf("unexpected states: %v", got)
+	}
+	if c.Checks() != 5 || len(c.Targets()) != 5 || c.Targets()[0] != target.String() {
+		t.Errorf("unexpected checks %d to %v", c.Checks(), c.Targets())
+	}
+	c.Reset()
+	if got := run(c, 2); !reflect.DeepEqual(got, []types.State{H, U}) || c.Checks() != 2 {
+		t.Errorf("unexpected states after reset: %v", got)
+	}
+
+	c = NewScriptedChecker([]types.State{U, H}).WithLoop()
+	if got := run(c, 5); !reflect.DeepEqual(got, []types.State{U, H, U, H, U}) {
+		t.Errorf("unexpected looped states: %v", got)
+	}
+	if got := run(NewScriptedChecker(nil), 2); !reflect.DeepEqual(got, []types.State{X, X}) {
+		t.Errorf("unexpected states of empty sequence: %v", got)
+	}
+	if state, err := c.Check(target, 0); err == nil || state != types.Unknown {
+		t.Errorf("expect error on zero timeout, got %v, %v", state, err)
+	}
+}
+
+func TestScriptedCheckerRegister(t *testing.T) {
+	defer delete(methods, CheckMethodScripted)
+	target := &utils.L3L4Addr{IP: net.ParseIP("192.168.88.30"), Port: 80, Proto: utils.IPProtoTCP}
+
+	if _, err := NewChecker(CheckMethodScripted, target, nil); err == nil {
+		t.Fatalf("expect scripted checker unavailable before registered")
+	}
+	RegisterScriptedChecker(NewScriptedChecker([]types.State{types.Unhealthy}))
+	if ParseMethod("scripted") != CheckMethodScripted || CheckMethodScripted.String() != "scripted" {
+		t.Errorf("scripted method not parsed")
+	}
+
+	// Checkers created have their own sequences of the template states.
+	for i := 0; i < 2; i++ {
+		c, err := NewChecker(CheckMethodScripted, target, nil)
+		if err != nil {
+			t.Fatalf("failed to create scripted checker: %v", err)
+		}
+		if state, err := c.Check(target, time.Second); err != nil || state != types.Unhealthy {
+			t.Errorf("checker %d: expect %v, got %v, %v", i, types.Unhealthy, state, err)
+		}
+	}
+
+	params := map[string]string{"states": "up, Down,healthy,unknown", "loop": "true"}
+	if err := Validate(CheckMethodScripted, params); err != nil {
+		t.Fatalf("unexpected validation error: %v", err)
+	}
+	c, err := NewChecker(CheckMethodScripted, target, params)
+	if err != nil {
+		t.Fatalf("failed to create scripted checker: %v", err)
+	}
+	expect := []types.State{types.Healthy, types.Unhealthy, types.Healthy, types.Unknown, types.Healthy}
+	for i, state := range expect {
+		if got, _ := c.Check(target, time.Second); got != state {
+			t.Errorf("check #%d: expect %v, got %v", i, state, got)
+		}
+	}
+
+	for _, params := range []map[string]string{
+		{"states": "up,maybe"},
+		{"states": ""},
+		{"loop": "sometimes"},
+		{"state": "up"},
+	} {
+		if err := Validate(CheckMethodScripted, params); err == nil {
+			t.Errorf("expect invalid params %v", params)
+		}
+	}
+}
@@ -0,0 +1,743 @@
+// /*
+// Copyright 2025 IQiYi Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// */
+
+package checker
+
+/*
+SNMP Checker Params:
+-------------------------------------------------------------
+name                value
+-------------------------------------------------------------
+oid                 OID to get, such as 1.3.6.1.2.1.1.3.0, required
+port                UDP port of the SNMP agent overriding the target port, default 161
+expect-value        expected value of the OID in text form, any value if unset
+community           community of SNMPv2c, default public
+username            user name of SNMPv3, SNMPv3 is used if given
+auth-protocol       md5 | sha | sha256, default sha, used with auth-password
+auth-password       authentication passphrase of SNMPv3, at least 8 characters
+priv-protocol       des | aes, default aes, used with priv-password
+priv-password       privacy passphrase of SNMPv3, at least 8 characters, requires auth-password
+netns               network namespace name or path to check from
+-------------------------------------------------------------
+
+The checker sends a GetRequest of `oid` over UDP, and the target is Healthy if
+a GetResponse with the value of the OID is received within the timeout. The
+error-status in the response, and the exceptions noSuchObject, noSuchInstance
+and endOfMibView are Unhealthy. A malformed response results in Unknown.
+
+The value is compared with expect-value in text form, that's, integers,
+counters, gauges and timeticks in decimal, OIDs in dotted decimal, IpAddress
+in dotted quad, and octet strings as they are.
+
+SNMPv3 uses the User-based Security Model (RFC 3414) with the security level
+implied by the passwords given, that's, noAuthNoPriv, authNoPriv or authPriv.
+The authoritative engine is discovered before each GetRequest. The protocol
+"sha256" is HMAC-192-SHA-256 (RFC 7860), and "aes" is AES-128 in CFB mode
+(RFC 3826).
+*/
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/types"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/utils"
+)
+
+var _ CheckMethodEx = (*SNMPChecker)(nil)
+
+const (
+	snmpDefaultPort      = 161
+	snmpDefaultCommunity = "public"
+	snmpMaxMessageSize   = 65507 // max UDP payload over IPv4
+
+	snmpVersion2c = 1 // version number in message of SNMPv2c
+	snmpVersion3  = 3
+)
+
+// BER tags used by SNMP.
+const (
+	berInteger     = 0x02
+	berOctetString = 0x04
+	berNull        = 0x05
+	berOID         = 0x06
+	berSequence    = 0x30
+
+	snmpIPAddress = 0x40
+	snmpCounter32 = 0x41
+	snmpGauge32   = 0x42
+	snmpTimeTicks = 0x43
+	snmpOpaque    = 0x44
+	snmpCounter64 = 0x46
+
+	snmpNoSuchObject   = 0x80
+	snmpNoSuchInstance = 0x81
+	snmpEndOfMibView   = 0x82
+
+	snmpGetRequest  = 0xa0
+	snmpGetResponse = 0xa2
+	snmpReport      = 0xa8
+)
+
+// snmpErrorStatus are names of error-status in response PDU, RFC 3416.
+var snmpErrorStatus = []string{"noError", "tooBig", "noSuchName", "badValue", "readOnly",
+	"genErr", "noAccess", "wrongType", "wrongLength", "wrongEncoding", "wrongValue",
+	"noCreation", "inconsistentValue", "resourceUnavailable", "commitFailed",
+	"undoFailed", "authorizationError", "notWritable", "inconsistentName"}
+
+var (
+	// errSNMPMalformed is wrapped by the errors of decoding SNMP messages.
+	errSNMPMalformed = errors.New("malformed SNMP message")
+	// errSNMPMismatch means the message is not a response to the request sent.
+	errSNMPMismatch = errors.New("mismatched SNMP response")
+)
+
+// snmpIOError is an I/O error of the SNMP transport, which fails the check.
+// Other errors of SNMP exchanges, such as malformed messages, make it Unknown.
+type snmpIOError struct {
+	err error
+}
+
+func (e *snmpIOError) Error() string {
+	return e.err.Error()
+}
+
+func (e *snmpIOError) Unwrap() error {
+	return e.err
+}
+
+type SNMPChecker struct {
+	oid       []uint32
+	port      uint16
+	expect    string
+	hasExpect bool
+	community string
+	usm       *snmpUSM // nil for SNMPv2c
+	netns     string
+
+	requestID uint32 // accessed atomically, the last request ID used
+}
+
+func init() {
+	registerMethod(CheckMethodSNMP, &SNMPChecker{})
+}
+
+func (c *SNMPChecker) Check(target *utils.L3L4Addr, timeout time.Duration) (types.State, error) {
+	return checkWithTimeout(c, target, timeout)
+}
+
+func (c *SNMPChecker) CheckContext(ctx context.Context, target *utils.L3L4Addr) (types.State, error) {
+	res, err := c.CheckEx(ctx, target)
+	return res.State, err
+}
+
+func (c *SNMPChecker) CheckEx(ctx context.Context, target *utils.L3L4Addr) (*CheckResult, error) {
+	start := time.Now()
+	timeout, err := checkTimeout(ctx, "SNMP", start)
+	if err != nil {
+		return checkError(start, err)
+	}
+
+	agent := target.DeepCopy()
+	agent.Proto = utils.IPProtoUDP
+	agent.Port = c.port
+	addr := agent.Addr()
+	glog.V(9).Infof("Start SNMP check to %s ...", addr)
+
+	dial := net.Dialer{
+		Timeout: timeout,
+	}
+	conn, err := netnsDial(ctx, &dial, c.netns, agent.Network(), addr)
+	if err != nil {
+		return checkFailed("SNMP", addr, start, errReason(err, true), "failed to dial: %v", err), nil
+	}
+	defer conn.Close()
+	defer closeOnCancel(ctx, conn)()
+
+	if err = conn.SetDeadline(start.Add(timeout)); err != nil {
+		return checkFailed("SNMP", addr, start, ReasonUnknown, "failed to set deadline"), nil
+	}
+
+	buf := make([]byte, snmpMaxMessageSize)
+	var pdu *snmpPDU
+	if c.usm == nil {
+		pdu, err = c.getV2c(conn, buf)
+	} else {
+		pdu, err = c.getV3(conn, buf)
+	}
+	if err != nil {
+		var ioErr *snmpIOError
+		if errors.As(err, &ioErr) {
+			return checkFailed("SNMP", addr, start, errReason(ioErr.err, false),
+				"failed to get %s: %v", snmpFormatOID(c.oid), err), nil
+		}
+		return checkError(start, fmt.Errorf("SNMP check %s: %v", addr, err))
+	}
+	return c.evaluate(pdu, addr, start)
+}
+
+// nextID returns the next request ID, which is also used as SNMPv3 msgID.
+func (c *SNMPChecker) nextID() int32 {
+	return int32(atomic.AddUint32(&c.requestID, 1) & 0x7fffffff)
+}
+
+// getV2c gets the OID with SNMPv2c, and returns the response PDU.
+func (c *SNMPChecker) getV2c(conn net.Conn, buf []byte) (*snmpPDU, error) {
+	id := c.nextID()
+	req := &snmpPDU{typ: snmpGetRequest, requestID: id, oid: c.oid, valueTag: berNull}
+	return snmpExchange(conn, buf, snmpEncodeV2c(c.community, req), func(data []byte) (*snmpPDU, error) {
+		community, pdu, err := snmpDecodeV2c(data)
+		if err != nil {
+			return nil, err
+		}
+		if pdu.requestID != id || community != c.community {
+			return nil, errSNMPMismatch
+		}
+		return pdu, nil
+	})
+}
+
+// evaluate returns the check result of the response PDU.
+func (c *SNMPChecker) evaluate(pdu *snmpPDU, addr string, start time.Time) (*CheckResult, error) {
+	oid := snmpFormatOID(c.oid)
+	switch pdu.typ {
+	case snmpGetResponse:
+	case snmpReport:
+		return checkFailed("SNMP", addr, start, ReasonBadStatus, "report %s received",
+			snmpReportName(pdu.oid)), nil
+	default:
+		return checkError(start, fmt.Errorf("%w: unexpected PDU type 0x%02x from %s",
+			errSNMPMalformed, pdu.typ, addr))
+	}
+
+	if pdu.errorStatus != 0 {
+		status := strconv.FormatInt(pdu.errorStatus, 10)
+		if pdu.errorStatus > 0 && pdu.errorStatus < int64(len(snmpErrorStatus)) {
+			status = snmpErrorStatus[pdu.errorStatus]
+		}
+		return checkFailed("SNMP", addr, start, ReasonBadStatus, "get %s: error-status %s at index %d",
+			oid, status, pdu.errorIndex), nil
+	}
+	if !snmpOIDEqual(pdu.oid, c.oid) {
+		return checkError(start, fmt.Errorf("%w: unexpected OID %s in response from %s",
+			errSNMPMalformed, snmpFormatOID(pdu.oid), addr))
+	}
+	switch pdu.valueTag {
+	case snmpNoSuchObject:
+		return checkFailed("SNMP", addr, start, ReasonBadStatus, "get %s: noSuchObject", oid), nil
+	case snmpNoSuchInstance:
+		return checkFailed("SNMP", addr, start, ReasonBadStatus, "get %s: noSuchInstance", oid), nil
+	case snmpEndOfMibView:
+		return checkFailed("SNMP", addr, start, ReasonBadStatus, "get %s: endOfMibView", oid), nil
+	}
+	value, err := snmpFormatValue(pdu.valueTag, pdu.value)
+	if err != nil {
+		return checkError(start, fmt.Errorf("%w: %v in response from %s", errSNMPMalformed, err, addr))
+	}
+	glog.V(9).Infof("SNMP check %v: %s = %q", addr, oid, value)
+	if c.hasExpect && value != c.expect {
+		return checkFailed("SNMP", addr, start, ReasonPayloadMismatch,
+			"unexpected value %q of %s, want %q", value, oid, c.expect), nil
+	}
+	return checkSucceed("SNMP", addr, start), nil
+}
+
+// snmpExchange sends the request message `req`, and reads messages into `buf`
+// until one is accepted as the response, skipping the mismatched ones.
+func snmpExchange(conn net.Conn, buf, req []byte,
+	accept func(data []byte) (*snmpPDU, error)) (*snmpPDU, error) {
+	if err := utils.WriteFull(conn, req); err != nil {
+		return nil, &snmpIOError{err}
+	}
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, &snmpIOError{err}
+		}
+		pdu, err := accept(buf[:n])
+		if err == errSNMPMismatch {
+			glog.V(9).Infof("SNMP response from %v skipped: %v", conn.RemoteAddr(), err)
+			continue
+		}
+		return pdu, err
+	}
+}
+
+// snmpPDU is a PDU of SNMP with at most one variable binding.
+type snmpPDU struct {
+	typ         byte
+	requestID   int32
+	errorStatus int64
+	errorIndex  int64
+	oid         []uint32 // nil if no variable binding
+	valueTag    byte
+	value       []byte
+}
+
+func (p *snmpPDU) encode() []byte {
+	var varbinds []byte
+	if p.oid != nil {
+		varbinds = berTLV(berSequence, berEncodeOID(p.oid), berTLV(p.valueTag, p.value))
+	}
+	return berTLV(p.typ, berInt(int64(p.requestID)), berInt(p.errorStatus), berInt(p.errorIndex),
+		berTLV(berSequence, varbinds))
+}
+
+// snmpDecodePDU decodes the PDU at the head of `d`. Only the first variable
+// binding is concerned.
+func snmpDecodePDU(d *berDecoder) (*snmpPDU, error) {
+	tag, content, err := d.next()
+	if err != nil {
+		return nil, err
+	}
+	if tag&0xe0 != 0xa0 {
+		return nil, fmt.Errorf("%w: unexpected PDU tag 0x%02x", errSNMPMalformed, tag)
+	}
+	pd := &berDecoder{content}
+	pdu := &snmpPDU{typ: tag}
+	id, err := pd.int()
+	if err != nil {
+		return nil, err
+	}
+	pdu.requestID = int32(id)
+	if pdu.errorStatus, err = pd.int(); err != nil {
+		return nil, err
+	}
+	if pdu.errorIndex, err = pd.int(); err != nil {
+		return nil, err
+	}
+	varbinds, err := pd.expect(berSequence)
+	if err != nil {
+		return nil, err
+	}
+	if len(varbinds) == 0 {
+		return pdu, nil
+	}
+	vd := &berDecoder{varbinds}
+	varbind, err := vd.expect(berSequence)
+	if err != nil {
+		return nil, err
+	}
+	vbd := &berDecoder{varbind}
+	oid, err := vbd.expect(berOID)
+	if err != nil {
+		return nil, err
+	}
+	if pdu.oid, err = berParseOID(oid); err != nil {
+		return nil, err
+	}
+	if pdu.valueTag, pdu.value, err = vbd.next(); err != nil {
+		return nil, err
+	}
+	return pdu, nil
+}
+
+// snmpEncodeV2c returns the SNMPv2c message of `pdu`.
+func snmpEncodeV2c(community string, pdu *snmpPDU) []byte {
+	return berTLV(berSequence, berInt(snmpVersion2c), berTLV(berOctetString, []byte(community)), pdu.encode())
+}
+
+// snmpDecodeV2c decodes a SNMPv2c message, and returns its community and PDU.
+func snmpDecodeV2c(data []byte) (string, *snmpPDU, error) {
+	d := &berDecoder{data}
+	msg, err := d.expect(berSequence)
+	if err != nil {
+		return "", nil, err
+	}
+	md := &berDecoder{msg}
+	version, err := md.int()
+	if err != nil {
+		return "", nil, err
+	}
+	if version != snmpVersion2c {
+		return "", nil, fmt.Errorf("%w: unexpected version %d", errSNMPMalformed, version)
+	}
+	community, err := md.expect(berOctetString)
+	if err != nil {
+		return "", nil, err
+	}
+	pdu, err := snmpDecodePDU(md)
+	if err != nil {
+		return "", nil, err
+	}
+	return string(community), pdu, nil
+}
+
+// snmpReportName returns the name of the usmStats counter reported.
+func snmpReportName(oid []uint32) string {
+	names := []string{"usmStatsUnsupportedSecLevels", "usmStatsNotInTimeWindows",
+		"usmStatsUnknownUserNames", "usmStatsUnknownEngineIDs", "usmStatsWrongDigests",
+		"usmStatsDecryptionErrors"}
+	usmStats := []uint32{1, 3, 6, 1, 6, 3, 15, 1, 1}
+	if len(oid) >= len(usmStats)+1 && snmpOIDEqual(oid[:len(usmStats)], usmStats) {
+		if i := oid[len(usmStats)]; i >= 1 && int(i) <= len(names) {
+			return names[i-1]
+		}
+	}
+	return snmpFormatOID(oid)
+}
+
+// snmpFormatValue returns the text form of a value in variable binding.
+func snmpFormatValue(tag byte, content []byte) (string, error) {
+	switch tag {
+	case berInteger:
+		v, err := berParseInt(content)
+		if err != nil {
+			return "", err
+		}
+		return strconv.FormatInt(v, 10), nil
+	case berOctetString, snmpOpaque:
+		return string(content), nil
+	case berNull:
+		return "", nil
+	case berOID:
+		oid, err := berParseOID(content)
+		if err != nil {
+			return "", err
+		}
+		return snmpFormatOID(oid), nil
+	case snmpIPAddress:
+		if len(content) != net.IPv4len {
+			return "", fmt.Errorf("invalid IpAddress length %d", len(content))
+		}
+		return net.IP(content).String(), nil
+	case snmpCounter32, snmpGauge32, snmpTimeTicks, snmpCounter64:
+		v, err := berParseUint(content)
+		if err != nil {
+			return "", err
+		}
+		return strconv.FormatUint(v, 10), nil
+	}
+	return "", fmt.Errorf("unsupported value type 0x%02x", tag)
+}
+
+// snmpParseOID parses an OID in dotted decimal, a leading dot is allowed.
+func snmpParseOID(s string) ([]uint32, error) {
+	arcs := strings.Split(strings.TrimPrefix(s, "."), ".")
+	if len(arcs) < 2 || len(arcs) > 128 {
+		return nil, fmt.Errorf("invalid OID %q", s)
+	}
+	oid := make([]uint32, len(arcs))
+	for i, arc := range arcs {
+		v, err := strconv.ParseUint(arc, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid OID %q", s)
+		}
+		oid[i] = uint32(v)
+	}
+	if oid[0] > 2 || oid[0] < 2 && oid[1] >= 40 {
+		return nil, fmt.Errorf("invalid OID %q", s)
+	}
+	return oid, nil
+}
+
+func snmpFormatOID(oid []uint32) string {
+	arcs := make([]string, len(oid))
+	for i, arc := range oid {
+		arcs[i] = strconv.FormatUint(uint64(arc), 10)
+	}
+	return strings.Join(arcs, ".")
+}
+
+func snmpOIDEqual(a, b []uint32) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// berTLV returns the BER encoding with tag `tag` and the concatenated contents.
+func berTLV(tag byte, contents ...[]byte) []byte {
+	n := 0
+	for _, content := range contents {
+		n += len(content)
+	}
+	b := make([]byte, 0, n+6)
+	b = append(b, tag)
+	if n < 0x80 {
+		b = append(b, byte(n))
+	} else {
+		var length [4]byte
+		binary.BigEndian.PutUint32(length[:], uint32(n))
+		i := 0
+		for length[i] == 0 {
+			i++
+		}
+		b = append(b, 0x80|byte(len(length)-i))
+		b = append(b, length[i:]...)
+	}
+	for _, content := range contents {
+		b = append(b, content...)
+	}
+	return b
+}
+
+// berInt returns the BER encoding of INTEGER `v` in the minimal octets.
+func berInt(v int64) []byte {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], uint64(v))
+	i := 0
+	for i < len(b)-1 && (b[i] == 0 && b[i+1]&0x80 == 0 || b[i] == 0xff && b[i+1]&0x80 != 0) {
+		i++
+	}
+	return berTLV(berInteger, b[i:])
+}
+
+// berEncodeOID returns the BER encoding of OBJECT IDENTIFIER `oid`, which has
+// at least 2 arcs.
+func berEncodeOID(oid []uint32) []byte {
+	var content []byte
+	for i, arc := range oid[1:] {
+		v := uint64(arc)
+		if i == 0 {
+			v += uint64(oid[0]) * 40
+		}
+		var base128 [10]byte
+		j := len(base128) - 1
+		base128[j] = byte(v & 0x7f)
+		for v >>= 7; v > 0; v >>= 7 {
+			j--
+			base128[j] = byte(v&0x7f) | 0x80
+		}
+		content = append(content, base128[j:]...)
+	}
+	return berTLV(berOID, content)
+}
+
+// berDecoder decodes the BER encodings in `data` in order.
+type berDecoder struct {
+	data []byte
+}
+
+// next decodes the next encoding, and returns its tag and content. Only the
+// single octet tags and the definite lengths are supported.
+func (d *berDecoder) next() (byte, []byte, error) {
+	if len(d.data) < 2 {
+		return 0, nil, fmt.Errorf("%w: truncated", errSNMPMalformed)
+	}
+	tag, n, rest := d.data[0], int(d.data[1]), d.data[2:]
+	if tag&0x1f == 0x1f {
+		return 0, nil, fmt.Errorf("%w: unsupported tag 0x%02x", errSNMPMalformed, tag)
+	}
+	if n&0x80 != 0 {
+		octets := n & 0x7f
+		if octets == 0 || octets > 3 || len(rest) < octets {
+			return 0, nil, fmt.Errorf("%w: invalid length", errSNMPMalformed)
+		}
+		n = 0
+		for _, b := range rest[:octets] {
+			n = n<<8 | int(b)
+		}
+		rest = rest[octets:]
+	}
+	if n > len(rest) {
+		return 0, nil, fmt.Errorf("%w: truncated", errSNMPMalformed)
+	}
+	d.data = rest[n:]
+	return tag, rest[:n], nil
+}
+
+// expect decodes the next encoding, which must be of tag `tag`.
+func (d *berDecoder) expect(tag byte) ([]byte, error) {
+	got, content, err := d.next()
+	if err != nil {
+		return nil, err
+	}
+	if got != tag {
+		return nil, fmt.Errorf("%w: unexpected tag 0x%02x, want 0x%02x", errSNMPMalformed, got, tag)
+	}
+	return content, nil
+}
+
+// int decodes the next encoding as INTEGER.
+func (d *berDecoder) int() (int64, error) {
+	content, err := d.expect(berInteger)
+	if err != nil {
+		return 0, err
+	}
+	return berParseInt(content)
+}
+
+func berParseInt(content []byte) (int64, error) {
+	if len(content) == 0 || len(content) > 8 {
+		return 0, fmt.Errorf("%w: invalid integer length %d", errSNMPMalformed, len(content))
+	}
+	v := int64(int8(content[0]))
+	for _, b := range content[1:] {
+		v = v<<8 | int64(b)
+	}
+	return v, nil
+}
+
+// berParseUint parses the unsigned integers of SNMP, such as Counter64. The
+// values with the most significant bit set but no leading zero octet, which
+// are sent by some agents, are accepted.
+func berParseUint(content []byte) (uint64, error) {
+	if len(content) == 0 || len(content) > 9 || len(content) == 9 && content[0] != 0 {
+		return 0, fmt.Errorf("%w: invalid unsigned integer", errSNMPMalformed)
+	}
+	var v uint64
+	for _, b := range content {
+		v = v<<8 | uint64(b)
+	}
+	return v, nil
+}
+
+func berParseOID(content []byte) ([]uint32, error) {
+	if len(content) == 0 || content[len(content)-1]&0x80 != 0 {
+		return nil, fmt.Errorf("%w: invalid OID", errSNMPMalformed)
+	}
+	var oid []uint32
+	var v uint64
+	for _, b := range content {
+		v = v<<7 | uint64(b&0x7f)
+		if v > 0xffffffff+80 {
+			return nil, fmt.Errorf("%w: OID arc overflow", errSNMPMalformed)
+		}
+		if b&0x80 != 0 {
+			continue
+		}
+		if len(oid) == 0 {
+			first := v / 40
+			if first > 2 {
+				first = 2
+			}
+			oid = append(oid, uint32(first), uint32(v-first*40))
+		} else {
+			oid = append(oid, uint32(v))
+		}
+		v = 0
+	}
+	return oid, nil
+}
+
+func (c *SNMPChecker) DefaultParams() map[string]string {
+	return map[string]string{
+		"oid":           "",
+		"port":          strconv.Itoa(snmpDefaultPort),
+		"expect-value":  "",
+		"community":     snmpDefaultCommunity,
+		"username":      "",
+		"auth-protocol": SNMPAuthSHA,
+		"auth-password": "",
+		"priv-protocol": SNMPPrivAES,
+		"priv-password": "",
+		ParamNetns:      "",
+	}
+}
+
+func (c *SNMPChecker) validate(params map[string]string) error {
+	unsupported := make([]string, 0, len(params))
+	for param, val := range params {
+		switch param {
+		case "oid":
+			if _, err := snmpParseOID(val); err != nil {
+				return fmt.Errorf("invalid snmp checker param value: %s:%s", param, val)
+			}
+		case "port":
+			if port, err := strconv.ParseUint(val, 10, 16); err != nil || port == 0 {
+				return fmt.Errorf("invalid snmp checker param value: %s:%s", param, val)
+			}
+		case "expect-value", "community":
+		case "username":
+			if len(val) == 0 || len(val) > 32 {
+				return fmt.Errorf("invalid snmp checker param value: %s:%s", param, val)
+			}
+		case "auth-protocol":
+			if snmpAuthHash(strings.ToLower(val)) == nil {
+				return fmt.Errorf("invalid snmp checker param value: %s:%s", param, val)
+			}
+		case "priv-protocol":
+			switch strings.ToLower(val) {
+			case SNMPPrivDES, SNMPPrivAES:
+			default:
+				return fmt.Errorf("invalid snmp checker param value: %s:%s", param, val)
+			}
+		case "auth-password", "priv-password":
+			if len(val) < 8 {
+				return fmt.Errorf("snmp checker param %s shorter than 8 characters", param)
+			}
+			if len(params["username"]) == 0 {
+				return fmt.Errorf("snmp checker param %s requires username", param)
+			}
+			if param == "priv-password" && len(params["auth-password"]) == 0 {
+				return fmt.Errorf("snmp checker param %s requires auth-password", param)
+			}
+		case ParamNetns:
+			if err := utils.ValidateNetns(val); err != nil {
+				return fmt.Errorf("invalid snmp checker param value: %s:%s: %v", param, val, err)
+			}
+		default:
+			unsupported = append(unsupported, param)
+		}
+	}
+
+	if len(unsupported) > 0 {
+		return fmt.Errorf("unsupported snmp checker params: %q", strings.Join(unsupported, ","))
+	}
+	if _, ok := params["oid"]; !ok {
+		return fmt.Errorf("snmp checker param oid required")
+	}
+	return nil
+}
+
+func (c *SNMPChecker) create(params map[string]string) (CheckMethod, error) {
+	if err := c.validate(params); err != nil {
+		return nil, fmt.Errorf("snmp checker param validation failed: %v", err)
+	}
+
+	checker := &SNMPChecker{
+		port:      snmpDefaultPort,
+		community: snmpDefaultCommunity,
+		requestID: rand.Uint32(),
+		netns:     params[ParamNetns],
+	}
+	checker.oid, _ = snmpParseOID(params["oid"])
+	if val, ok := params["port"]; ok {
+		port, _ := strconv.ParseUint(val, 10, 16)
+		checker.port = uint16(port)
+	}
+	checker.expect, checker.hasExpect = params["expect-value"]
+	if val, ok := params["community"]; ok {
+		checker.community = val
+	}
+	if user := params["username"]; len(user) > 0 {
+		authProto, privProto := SNMPAuthSHA, SNMPPrivAES
+		if val, ok := params["auth-protocol"]; ok {
+			authProto = strings.ToLower(val)
+		}
+		if val, ok := params["priv-protocol"]; ok {
+			privProto = strings.ToLower(val)
+		}
+		checker.usm = newSNMPUSM(user, authProto, params["auth-password"],
+			privProto, params["priv-password"])
+	}
+	return checker, nil
+}
@@ -0,0 +1,466 @@
+// /*
+// Copyright 2025 IQiYi Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// */
+
+package checker
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/types"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/utils"
+)
+
+func unhex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(strings.ReplaceAll(s, " ", ""))
+	if err != nil {
+		t.Fatalf("invalid hex %q: %v", s, err)
+	}
+	return b
+}
+
+func TestSNMPCodec(t *testing.T) {
+	sysUpTime := []uint32{1, 3, 6, 1, 2, 1, 1, 3, 0}
+
+	// GetRequest of sysUpTime.0 with request-id 1
+	req := &snmpPDU{typ: snmpGetRequest, requestID: 1, oid: sysUpTime, valueTag: berNull}
+	expect := unhex(t, "30 26 02 01 01 04 06 70 75 62 6c 69 63 a0 19 02 01 01 02 01 00 02 01 00"+
+		"30 0e 30 0c 06 08 2b 06 01 02 01 01 03 00 05 00")
+	if got := snmpEncodeV2c("public", req); !bytes.Equal(got, expect) {
+		t.Errorf("unexpected GetRequest encoding % x", got)
+	}
+
+	// GetResponse of sysUpTime.0 = Timeticks: 16909060
+	community, pdu, err := snmpDecodeV2c(unhex(t, "30 2a 02 01 01 04 06 70 75 62 6c 69 63 a2 1d"+
+		"02 01 01 02 01 00 02 01 00 30 12 30 10 06 08 2b 06 01 02 01 01 03 00 43 04 01 02 03 04"))
+	if err != nil {
+		t.Fatalf("failed to decode GetResponse: %v", err)
+	}
+	if community != "public" || pdu.typ != snmpGetResponse || pdu.requestID != 1 ||
+		pdu.errorStatus != 0 || !snmpOIDEqual(pdu.oid, sysUpTime) || pdu.valueTag != snmpTimeTicks {
+		t.Errorf("unexpected GetResponse %s %+v", community, pdu)
+	}
+	if value, err := snmpFormatValue(pdu.valueTag, pdu.value); err != nil || value != "16909060" {
+		t.Errorf("unexpected value %q, %v", value, err)
+	}
+
+	// GetResponse of sysUpTime.0 = noSuchInstance
+	_, pdu, err = snmpDecodeV2c(unhex(t, "30 26 02 01 01 04 06 70 75 62 6c 69 63 a2 19"+
+		"02 01 01 02 01 00 02 01 00 30 0e 30 0c 06 08 2b 06 01 02 01 01 03 00 81 00"))
+	if err != nil || pdu.valueTag != snmpNoSuchInstance {
+		t.Errorf("unexpected noSuchInstance response %+v, %v", pdu, err)
+	}
+
+	// GetResponse with error-status noSuchName(2) at index 1
+	_, pdu, err = snmpDecodeV2c(unhex(t, "30 26 02 01 01 04 06 70 75 62 6c 69 63 a2 19"+
+		"02 01 01 02 01 02 02 01 01 30 0e 30 0c 06 08 2b 06 01 02 01 01 03 00 05 00"))
+	if err != nil || pdu.errorStatus != 2 || pdu.errorIndex != 1 {
+		t.Errorf("unexpected error-status response %+v, %v", pdu, err)
+	}
+
+	for _, c := range []struct {
+		v     int64
+		bytes string
+	}{
+		{0, "02 01 00"}, {127, "02 01 7f"}, {128, "02 02 00 80"}, {-1, "02 01 ff"},
+		{-128, "02 01 80"}, {-129, "02 02 ff 7f"}, {65507, "02 03 00 ff e3"},
+	} {
+		if got := berInt(c.v); !bytes.Equal(got, unhex(t, c.bytes)) {
+			t.Errorf("berInt(%d): unexpected encoding % x", c.v, got)
+		}
+		if got, err := (&berDecoder{unhex(t, c.bytes)}).int(); err != nil || got != c.v {
+			t.Errorf("berDecoder.int(%s): got %d, %v", c.bytes, got, err)
+		}
+	}
+
+	for oid, bytes_ := range map[string]string{
+		"1.3.6.1.4.1.2021.10.1.3.1": "06 0b 2b 06 01 04 01 8f 65 0a 01 03 01",
+		"2.999.3":                   "06 03 88 37 03",
+		"0.0":                       "06 01 00",
+	} {
+		parsed, err := snmpParseOID(oid)
+		if err != nil {
+			t.Errorf("failed to parse OID %s: %v", oid, err)
+			continue
+		}
+		if got := berEncodeOID(parsed); !bytes.Equal(got, unhex(t, bytes_)) {
+			t.Errorf("OID %s: unexpected encoding % x", oid, got)
+		}
+		decoded, err := berParseOID(unhex(t, bytes_)[2:])
+		if err != nil || snmpFormatOID(decoded) != oid {
+			t.Errorf("OID %s: decoded %v, %v", oid, decoded, err)
+		}
+	}
+	for _, oid := range []string{"", "1", "1.3.a", "3.1", "1.40", "1.3.6.4294967296"} {
+		if _, err := snmpParseOID(oid); err == nil {
+			t.Errorf("invalid OID %q accepted", oid)
+		}
+	}
+
+	long := berTLV(berOctetString, make([]byte, 200))
+	if !bytes.Equal(long[:3], []byte{0x04, 0x81, 0xc8}) || len(long) != 203 {
+		t.Errorf("unexpected long form length % x", long[:3])
+	}
+	if _, content, err := (&berDecoder{long}).next(); err != nil || len(content) != 200 {
+		t.Errorf("failed to decode long form length: %v", err)
+	}
+
+	for tag, c := range map[byte]struct {
+		content string
+		value   string
+	}{
+		berOctetString: {"4c 69 6e 75 78", "Linux"},
+		berInteger:     {"ff 38", "-200"},
+		berOID:         {"2b 06 01 04 01", "1.3.6.1.4.1"},
+		snmpIPAddress:  {"c0 a8 58 1e", "192.168.88.30"},
+		snmpCounter32:  {"00 ff ff ff ff", "4294967295"},
+		snmpGauge32:    {"80", "128"},
+		snmpCounter64:  {"00 ff ff ff ff ff ff ff ff", "18446744073709551615"},
+		berNull:        {"", ""},
+	} {
+		if got, err := snmpFormatValue(tag, unhex(t, c.content)); err != nil || got != c.value {
+			t.Errorf("value of type 0x%02x: expect %q, got %q, %v", tag, c.value, got, err)
+		}
+	}
+
+	for _, malformed := range []string{
+		"",
+		"30",
+		"30 05 02 01 01",
+		"30 84 00 00 00 01 00",
+		"30 03 02 01 00",
+		"30 06 02 01 03 04 01 00",
+		"1f 01 00",
+		"30 26 02 01 01 04 06 70 75 62 6c 69 63 02 19 02 01 01 02 01 00 02 01 00" +
+			"30 0e 30 0c 06 08 2b 06 01 02 01 01 03 00 05 00",
+		"30 26 02 01 01 04 06 70 75 62 6c 69 63 a2 19 02 01 01 02 01 00 02 01 00" +
+			"30 0e 30 0c 06 08 2b 06 01 02 01 01 03 80 05 00",
+	} {
+		if _, _, err := snmpDecodeV2c(unhex(t, malformed)); !errors.Is(err, errSNMPMalformed) {
+			t.Errorf("malformed message %q: unexpected error %v", malformed, err)
+		}
+	}
+}
+
+func TestSNMPKeys(t *testing.T) {
+	// RFC 3414 A.3.1 and A.3.2
+	engineID := unhex(t, "00 00 00 00 00 00 00 00 00 00 00 02")
+	for proto, expect := range map[string]string{
+		SNMPAuthMD5: "52 6f 5e ed 9f cc e2 6f 89 64 c2 93 07 87 d8 2b",
+		SNMPAuthSHA: "66 95 fe bc 92 88 e3 62 82 23 5f c7 15 1f 12 84 97 b3 8f 3f",
+	} {
+		hash := snmpAuthHash(proto)
+		key := snmpLocalizeKey(hash, snmpPasswordToKey(hash, "maplesyrup"), engineID)
+		if !bytes.Equal(key, unhex(t, expect)) {
+			t.Errorf("%s: unexpected localized key % x", proto, key)
+		}
+	}
+}
+
+type snmpTestVar struct {
+	tag   byte
+	value []byte
+}
+
+// startSNMPAgent serves SNMP over UDP on `ip` with `handle`, which returns the
+// messages to respond to a request. The returned target is of the agent port.
+func startSNMPAgent(t *testing.T, ip string, handle func(req []byte) [][]byte) *utils.L3L4Addr {
+	conn, err := net.ListenPacket("udp", net.JoinHostPort(ip, "0"))
+	if err != nil {
+		t.Skipf("failed to listen on %s: %v", ip, err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	go func() {
+		buf := make([]byte, snmpMaxMessageSize)
+		for {
+			n, from, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			for _, resp := range handle(append([]byte(nil), buf[:n]...)) {
+				conn.WriteTo(resp, from)
+			}
+		}
+	}()
+	a := conn.LocalAddr().(*net.UDPAddr)
+	return &utils.L3L4Addr{IP: a.IP, Port: uint16(a.Port), Proto: utils.IPProtoUDP}
+}
+
+// snmpTestResponse returns the GetResponse to `req` from `mib`.
+func snmpTestResponse(req *snmpPDU, mib map[string]snmpTestVar) *snmpPDU {
+	resp := &snmpPDU{typ: snmpGetResponse, requestID: req.requestID, oid: req.oid,
+		valueTag: snmpNoSuchObject}
+	if v, ok := mib[snmpFormatOID(req.oid)]; ok {
+		resp.valueTag, resp.value = v.tag, v.value
+	}
+	return resp
+}
+
+var snmpTestMIB = map[string]snmpTestVar{
+	"1.3.6.1.2.1.1.1.0":        {berOctetString, []byte("DNS appliance")},
+	"1.3.6.1.2.1.1.3.0":        {snmpTimeTicks, []byte{0x01, 0x02, 0x03, 0x04}},
+	"1.3.6.1.4.1.2021.10.1.1":  {snmpNoSuchInstance, nil},
+	"1.3.6.1.4.1.99999.1.1.0":  {berInteger, []byte{0x01}},
+	"1.3.6.1.4.1.99999.1.99.0": {0x47, []byte{0x01}},
+	"1.3.6.1.4.1.99999.4.0":    {berInteger, []byte{0x01}},
+}
+
+func TestSNMPChecker(t *testing.T) {
+	// responds by OID with the community "public"
+	agent := startSNMPAgent(t, "127.0.0.1", func(req []byte) [][]byte {
+		community, pdu, err := snmpDecodeV2c(req)
+		if err != nil || community != "public" {
+			return nil
+		}
+		switch snmpFormatOID(pdu.oid) {
+		case "1.3.6.1.4.1.99999.2.0": // genErr
+			return [][]byte{snmpEncodeV2c(community, &snmpPDU{typ: snmpGetResponse,
+				requestID: pdu.requestID, errorStatus: 5, errorIndex: 1, oid: pdu.oid, valueTag: berNull})}
+		case "1.3.6.1.4.1.99999.3.0": // malformed
+			return [][]byte{{0x30, 0x05, 0x02, 0x01}}
+		case "1.3.6.1.4.1.99999.4.0": // stale response first
+			stale := snmpTestResponse(pdu, snmpTestMIB)
+			stale.requestID++
+			stale.value = []byte{0x02}
+			return [][]byte{snmpEncodeV2c(community, stale),
+				snmpEncodeV2c(community, snmpTestResponse(pdu, snmpTestMIB))}
+		case "1.3.6.1.4.1.99999.6.0": // response of another OID
+			return [][]byte{snmpEncodeV2c(community, snmpTestResponse(&snmpPDU{requestID: pdu.requestID,
+				oid: []uint32{1, 3, 6, 1, 4, 1, 99999, 1, 1, 0}}, snmpTestMIB))}
+		case "1.3.6.1.4.1.99999.5.0": // silent
+			return nil
+		}
+		return [][]byte{snmpEncodeV2c(community, snmpTestResponse(pdu, snmpTestMIB))}
+	})
+	create := func(params map[string]string) CheckMethod {
+		params["port"] = strconv.Itoa(int(agent.Port))
+		checker, err := (&SNMPChecker{}).create(params)
+		if err != nil {
+			t.Fatalf("failed to create snmp checker with %v: %v", params, err)
+		}
+		return checker
+	}
+	// The port of the target is overridden.
+	target := &utils.L3L4Addr{IP: agent.IP, Port: 53, Proto: utils.IPProtoTCP}
+	timeout := time.Second
+
+	expectResult(t, "sysUpTime", create(map[string]string{"oid": "1.3.6.1.2.1.1.3.0"}),
+		target, timeout, types.Healthy, ReasonNone)
+	expectResult(t, "expected sysUpTime", create(map[string]string{"oid": ".1.3.6.1.2.1.1.3.0",
+		"expect-value": "16909060"}), target, timeout, types.Healthy, ReasonNone)
+	expectResult(t, "expected sysDescr", create(map[string]string{"oid": "1.3.6.1.2.1.1.1.0",
+		"expect-value": "DNS appliance"}), target, timeout, types.Healthy, ReasonNone)
+	expectResult(t, "unexpected sysDescr", create(map[string]string{"oid": "1.3.6.1.2.1.1.1.0",
+		"expect-value": "firewall"}), target, timeout, types.Unhealthy, ReasonPayloadMismatch)
+	expectResult(t, "noSuchObject", create(map[string]string{"oid": "1.3.6.1.2.1.1.2.0"}),
+		target, timeout, types.Unhealthy, ReasonBadStatus)
+	expectResult(t, "noSuchInstance", create(map[string]string{"oid": "1.3.6.1.4.1.2021.10.1.1"}),
+		target, timeout, types.Unhealthy, ReasonBadStatus)
+	expectResult(t, "error-status", create(map[string]string{"oid": "1.3.6.1.4.1.99999.2.0"}),
+		target, timeout, types.Unhealthy, ReasonBadStatus)
+	expectResult(t, "stale response skipped", create(map[string]string{"oid": "1.3.6.1.4.1.99999.4.0",
+		"expect-value": "1"}), target, timeout, types.Healthy, ReasonNone)
+	expectResult(t, "no response", create(map[string]string{"oid": "1.3.6.1.4.1.99999.5.0"}),
+		target, timeout/4, types.Unhealthy, ReasonTimeout)
+	expectResult(t, "wrong community", create(map[string]string{"oid": "1.3.6.1.2.1.1.3.0",
+		"community": "private"}), target, timeout/4, types.Unhealthy, ReasonTimeout)
+
+	for name, method := range map[string]CheckMethod{
+		"malformed":          create(map[string]string{"oid": "1.3.6.1.4.1.99999.3.0"}),
+		"unsupported type":   create(map[string]string{"oid": "1.3.6.1.4.1.99999.1.99.0"}),
+		"unexpected OID":     create(map[string]string{"oid": "1.3.6.1.4.1.99999.6.0"}),
+		"zero timeout check": create(map[string]string{"oid": "1.3.6.1.2.1.1.3.0"}),
+	} {
+		to := timeout
+		if strings.HasPrefix(name, "zero") {
+			to = 0
+		}
+		if res, err := CheckExTimeout(method, target, to); err == nil || res.State != types.Unknown {
+			t.Errorf("%s: expect %v with error, got %v, %v", name, types.Unknown, res.State, err)
+		}
+	}
+
+	closed, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	closed.Close()
+	refused, _ := (&SNMPChecker{}).create(map[string]string{"oid": "1.3.6.1.2.1.1.3.0",
+		"port": strconv.Itoa(closed.LocalAddr().(*net.UDPAddr).Port)})
+	expectResult(t, "port unreachable", refused, target, timeout, types.Unhealthy, ReasonConnRefused)
+
+	agent6 := startSNMPAgent(t, "::1", func(req []byte) [][]byte {
+		community, pdu, err := snmpDecodeV2c(req)
+		if err != nil {
+			return nil
+		}
+		return [][]byte{snmpEncodeV2c(community, snmpTestResponse(pdu, snmpTestMIB))}
+	})
+	checker6, _ := (&SNMPChecker{}).create(map[string]string{"oid": "1.3.6.1.2.1.1.3.0",
+		"port": strconv.Itoa(int(agent6.Port))})
+	expectResult(t, "IPv6", checker6, &utils.L3L4Addr{IP: agent6.IP, Port: 53, Proto: utils.IPProtoUDP},
+		timeout, types.Healthy, ReasonNone)
+}
+
+// snmpTestV3Agent is a fake SNMPv3 agent of a single user.
+type snmpTestV3Agent struct {
+	engineID []byte
+	usm      *snmpUSM
+	keys     *snmpKeys
+}
+
+func newSNMPTestV3Agent(user, authProto, authPassword, privProto, privPassword string) *snmpTestV3Agent {
+	a := &snmpTestV3Agent{
+		engineID: []byte{0x80, 0x00, 0x1f, 0x88, 0x04, 'd', 'p', 'v', 's'},
+		usm:      newSNMPUSM(user, authProto, authPassword, privProto, privPassword),
+	}
+	a.keys = a.usm.localize(a.engineID)
+	return a
+}
+
+func (a *snmpTestV3Agent) handle(req []byte) [][]byte {
+	m, pdu, err := a.usm.decode(req, a.keys)
+	if m == nil {
+		return nil
+	}
+	report := func(stat uint32) [][]byte {
+		var id int32
+		if pdu != nil {
+			id = pdu.requestID
+		}
+		resp, _ := a.usm.encode(&snmpV3Message{msgID: m.msgID, engineID: a.engineID, boots: 7,
+			time: 1234, user: m.user, pdu: (&snmpPDU{typ: snmpReport, requestID: id,
+				oid: []uint32{1, 3, 6, 1, 6, 3, 15, 1, 1, stat, 0}, valueTag: snmpCounter32,
+				value: []byte{1}}).encode()}, nil)
+		return [][]byte{resp}
+	}
+	switch {
+	case errors.Is(err, errSNMPAuthFailed):
+		return report(5) // usmStatsWrongDigests
+	case err != nil && m.flags&snmpFlagPriv != 0:
+		return report(6) // usmStatsDecryptionErrors
+	case err != nil:
+		return nil
+	case len(m.engineID) == 0:
+		return report(4) // usmStatsUnknownEngineIDs
+	case m.user != a.usm.user:
+		return report(3) // usmStatsUnknownUserNames
+	case m.flags&(snmpFlagAuth|snmpFlagPriv) != a.usm.flags():
+		return report(1) // usmStatsUnsupportedSecLevels
+	}
+	resp, err := a.usm.encode(&snmpV3Message{msgID: m.msgID, flags: a.usm.flags(),
+		engineID: a.engineID, boots: 7, time: 1234, user: m.user,
+		pdu: snmpTestResponse(pdu, snmpTestMIB).encode()}, a.keys)
+	if err != nil {
+		return nil
+	}
+	return [][]byte{resp}
+}
+
+func TestSNMPCheckerV3(t *testing.T) {
+	timeout := time.Second
+	for _, c := range []struct {
+		name   string
+		agent  [5]string // user, auth protocol and password, priv protocol and password
+		params map[string]string
+		state  types.State
+		detail string
+	}{
+		{"noAuthNoPriv", [5]string{"monitor"},
+			map[string]string{"username": "monitor"}, types.Healthy, ""},
+		{"authNoPriv md5", [5]string{"monitor", SNMPAuthMD5, "maplesyrup"},
+			map[string]string{"username": "monitor", "auth-protocol": "MD5", "auth-password": "maplesyrup"},
+			types.Healthy, ""},
+		{"authNoPriv sha256", [5]string{"monitor", SNMPAuthSHA256, "maplesyrup"},
+			map[string]string{"username": "monitor", "auth-protocol": "sha256", "auth-password": "maplesyrup"},
+			types.Healthy, ""},
+		{"authPriv sha aes", [5]string{"monitor", SNMPAuthSHA, "maplesyrup", SNMPPrivAES, "privsecret"},
+			map[string]string{"username": "monitor", "auth-password": "maplesyrup",
+				"priv-password": "privsecret"}, types.Healthy, ""},
+		{"authPriv md5 des", [5]string{"monitor", SNMPAuthMD5, "maplesyrup", SNMPPrivDES, "privsecret"},
+			map[string]string{"username": "monitor", "auth-protocol": "md5", "auth-password": "maplesyrup",
+				"priv-protocol": "des", "priv-password": "privsecret"}, types.Healthy, ""},
+		{"wrong auth password", [5]string{"monitor", SNMPAuthSHA, "maplesyrup"},
+			map[string]string{"username": "monitor", "auth-password": "pancakes"},
+			types.Unhealthy, "usmStatsWrongDigests"},
+		{"wrong priv password", [5]string{"monitor", SNMPAuthSHA, "maplesyrup", SNMPPrivAES, "privsecret"},
+			map[string]string{"username": "monitor", "auth-password": "maplesyrup",
+				"priv-password": "wrongsecret"}, types.Unhealthy, "usmStatsDecryptionErrors"},
+		{"unknown user", [5]string{"admin"},
+			map[string]string{"username": "monitor"}, types.Unhealthy, "usmStatsUnknownUserNames"},
+		{"lower security level", [5]string{"monitor", SNMPAuthSHA, "maplesyrup"},
+			map[string]string{"username": "monitor"}, types.Unhealthy, "usmStatsUnsupportedSecLevels"},
+	} {
+		a := newSNMPTestV3Agent(c.agent[0], c.agent[1], c.agent[2], c.agent[3], c.agent[4])
+		agent := startSNMPAgent(t, "127.0.0.1", a.handle)
+		c.params["oid"] = "1.3.6.1.2.1.1.1.0"
+		c.params["expect-value"] = "DNS appliance"
+		c.params["port"] = strconv.Itoa(int(agent.Port))
+		checker, err := (&SNMPChecker{}).create(c.params)
+		if err != nil {
+			t.Fatalf("%s: failed to create snmp checker: %v", c.name, err)
+		}
+		res, err := CheckExTimeout(checker, agent, timeout)
+		if err != nil || res.State != c.state || !strings.Contains(res.Detail, c.detail) {
+			t.Errorf("%s: expect %v %s, got %v %s, %v", c.name, c.state, c.detail, res.State, res.Detail, err)
+		}
+	}
+
+	// An agent responding with other keys
+	a := newSNMPTestV3Agent("monitor", SNMPAuthSHA, "maplesyrup", "", "")
+	evil := newSNMPTestV3Agent("monitor", SNMPAuthSHA, "pancakes!", "", "")
+	agent := startSNMPAgent(t, "127.0.0.1", func(req []byte) [][]byte {
+		m, pdu, err := a.usm.decode(req, a.keys)
+		if err != nil || len(m.engineID) == 0 {
+			return a.handle(req)
+		}
+		resp, _ := evil.usm.encode(&snmpV3Message{msgID: m.msgID, flags: snmpFlagAuth,
+			engineID: a.engineID, user: m.user, pdu: snmpTestResponse(pdu, snmpTestMIB).encode()}, evil.keys)
+		return [][]byte{resp}
+	})
+	checker, _ := (&SNMPChecker{}).create(map[string]string{"oid": "1.3.6.1.2.1.1.1.0",
+		"username": "monitor", "auth-password": "maplesyrup", "port": strconv.Itoa(int(agent.Port))})
+	if res, err := CheckExTimeout(checker, agent, timeout); err == nil || res.State != types.Unknown {
+		t.Errorf("unauthenticated response: expect %v with error, got %v, %v", types.Unknown, res.State, err)
+	}
+}
+
+func TestSNMPCheckerParams(t *testing.T) {
+	for _, params := range []map[string]string{
+		{},
+		{"oid": "1.3.6.1.2.1.1.3.0", "port": "0"},
+		{"oid": "1.3.6.1.2.1.1.3.0", "port": "65536"},
+		{"oid": "1.3.6.1.2.1.1.3.0", "username": ""},
+		{"oid": "1.3.6.1.2.1.1.3.0", "username": strings.Repeat("u", 33)},
+		{"oid": "1.3.6.1.2.1.1.3.0", "auth-password": "maplesyrup"},
+		{"oid": "1.3.6.1.2.1.1.3.0", "username": "monitor", "auth-password": "short"},
+		{"oid": "1.3.6.1.2.1.1.3.0", "username": "monitor", "auth-protocol": "sha512"},
+		{"oid": "1.3.6.1.2.1.1.3.0", "username": "monitor", "priv-password": "privsecret"},
+		{"oid": "1.3.6.1.2.1.1.3.0", "username": "monitor", "priv-protocol": "3des"},
+		{"oid": "1.3.6.1.2.1.1.3.0", "no-such-param": "x"},
+	} {
+		if _, err := (&SNMPChecker{}).create(params); err == nil {
+			t.Errorf("invalid params accepted: %v", params)
+		}
+	}
+}
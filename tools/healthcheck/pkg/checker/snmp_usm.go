@@ -0,0 +1,495 @@
+// /*
+// Copyright 2025 IQiYi Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// */
+
+package checker
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"math/rand"
+	"net"
+	"sync/atomic"
+)
+
+// SNMPv3 authentication and privacy protocols.
+const (
+	SNMPAuthMD5    = "md5"
+	SNMPAuthSHA    = "sha"
+	SNMPAuthSHA256 = "sha256"
+
+	SNMPPrivDES = "des"
+	SNMPPrivAES = "aes"
+)
+
+// msgFlags of SNMPv3 message.
+const (
+	snmpFlagAuth       = 0x01
+	snmpFlagPriv       = 0x02
+	snmpFlagReportable = 0x04
+)
+
+const snmpSecurityModelUSM = 3
+
+var errSNMPAuthFailed = errors.New("SNMP authentication failed")
+
+// snmpUSM is a user of the User-based Security Model, RFC 3414.
+type snmpUSM struct {
+	user      string
+	authProto string // "" for noAuthNoPriv
+	authKey   []byte // digested from the password, not localized
+	privProto string // "" for noPriv
+	privKey   []byte // digested from the password, not localized
+
+	salt uint64 // accessed atomically
+}
+
+// snmpKeys are the keys of a user localized to an authoritative engine.
+type snmpKeys struct {
+	auth []byte
+	priv []byte
+}
+
+// snmpV3Message is a SNMPv3 message with the USM security parameters.
+type snmpV3Message struct {
+	msgID    int32
+	flags    byte
+	engineID []byte // authoritative engine ID
+	boots    int32
+	time     int32
+	user     string
+	pdu      []byte // encoded PDU, not set in the decoded message
+}
+
+// newSNMPUSM returns a USM user, whose security level is implied by the
+// passwords given. Digesting the passwords is expensive, so it's done once.
+func newSNMPUSM(user, authProto, authPassword, privProto, privPassword string) *snmpUSM {
+	u := &snmpUSM{
+		user: user,
+		salt: rand.Uint64(),
+	}
+	if len(authPassword) > 0 {
+		u.authProto = authProto
+		u.authKey = snmpPasswordToKey(snmpAuthHash(authProto), authPassword)
+		if len(privPassword) > 0 {
+			u.privProto = privProto
+			u.privKey = snmpPasswordToKey(snmpAuthHash(authProto), privPassword)
+		}
+	}
+	return u
+}
+
+// snmpAuthHash returns the hash function of authentication protocol `proto`,
+// or nil if unsupported.
+func snmpAuthHash(proto string) func() hash.Hash {
+	switch proto {
+	case SNMPAuthMD5:
+		return md5.New
+	case SNMPAuthSHA:
+		return sha1.New
+	case SNMPAuthSHA256:
+		return sha256.New
+	}
+	return nil
+}
+
+// snmpAuthParamsLen returns the length of the truncated HMAC.
+func snmpAuthParamsLen(proto string) int {
+	if proto == SNMPAuthSHA256 {
+		return 24
+	}
+	return 12
+}
+
+// snmpPasswordToKey digests the password into a key with the algorithm in
+// RFC 3414 A.2, which hashes 1MB of the repeated password.
+func snmpPasswordToKey(newHash func() hash.Hash, password string) []byte {
+	h := newHash()
+	buf := make([]byte, 64)
+	for i, n := 0, 0; n < 1048576; n += len(buf) {
+		for j := range buf {
+			buf[j] = password[i%len(password)]
+			i++
+		}
+		h.Write(buf)
+	}
+	return h.Sum(nil)
+}
+
+// snmpLocalizeKey localizes the key to the authoritative engine.
+func snmpLocalizeKey(newHash func() hash.Hash, key, engineID []byte) []byte {
+	h := newHash()
+	h.Write(key)
+	h.Write(engineID)
+	h.Write(key)
+	return h.Sum(nil)
+}
+
+func (u *snmpUSM) flags() byte {
+	var flags byte
+	if len(u.authProto) > 0 {
+		flags |= snmpFlagAuth
+	}
+	if len(u.privProto) > 0 {
+		flags |= snmpFlagPriv
+	}
+	return flags
+}
+
+// localize returns the keys localized to the authoritative engine.
+func (u *snmpUSM) localize(engineID []byte) *snmpKeys {
+	keys := &snmpKeys{}
+	if len(u.authProto) > 0 {
+		keys.auth = snmpLocalizeKey(snmpAuthHash(u.authProto), u.authKey, engineID)
+	}
+	if len(u.privProto) > 0 {
+		keys.priv = snmpLocalizeKey(snmpAuthHash(u.authProto), u.privKey, engineID)
+	}
+	return keys
+}
+
+// mac returns the truncated HMAC of the whole message.
+func (u *snmpUSM) mac(key, msg []byte) []byte {
+	h := hmac.New(snmpAuthHash(u.authProto), key)
+	h.Write(msg)
+	return h.Sum(nil)[:snmpAuthParamsLen(u.authProto)]
+}
+
+// encrypt encrypts the scoped PDU, and returns the cipher text and the
+// privacy parameters.
+func (u *snmpUSM) encrypt(key, plain []byte, boots, time int32) ([]byte, []byte, error) {
+	salt := make([]byte, 8)
+	switch u.privProto {
+	case SNMPPrivDES:
+		// RFC 3414 8.1.1.1, the salt is engineBoots followed by a local integer.
+		binary.BigEndian.PutUint32(salt, uint32(boots))
+		binary.BigEndian.PutUint32(salt[4:], uint32(atomic.AddUint64(&u.salt, 1)))
+		block, err := des.NewCipher(key[:8])
+		if err != nil {
+			return nil, nil, err
+		}
+		iv := make([]byte, des.BlockSize)
+		for i := range iv {
+			iv[i] = key[8+i] ^ salt[i]
+		}
+		if pad := len(plain) % des.BlockSize; pad > 0 {
+			plain = append(plain[:len(plain):len(plain)], make([]byte, des.BlockSize-pad)...)
+		}
+		data := make([]byte, len(plain))
+		cipher.NewCBCEncrypter(block, iv).CryptBlocks(data, plain)
+		return data, salt, nil
+	case SNMPPrivAES:
+		// RFC 3826 3.1.2.1, the salt is a local 64-bit integer.
+		binary.BigEndian.PutUint64(salt, atomic.AddUint64(&u.salt, 1))
+		block, err := aes.NewCipher(key[:16])
+		if err != nil {
+			return nil, nil, err
+		}
+		data := make([]byte, len(plain))
+		cipher.NewCFBEncrypter(block, snmpAESIV(boots, time, salt)).XORKeyStream(data, plain)
+		return data, salt, nil
+	}
+	return nil, nil, fmt.Errorf("unsupported privacy protocol %q", u.privProto)
+}
+
+// decrypt decrypts the scoped PDU with the privacy parameters `salt`. The
+// result may have paddings trailing.
+func (u *snmpUSM) decrypt(key, data, salt []byte, boots, time int32) ([]byte, error) {
+	if len(salt) != 8 {
+		return nil, fmt.Errorf("%w: invalid privacy parameters", errSNMPMalformed)
+	}
+	plain := make([]byte, len(data))
+	switch u.privProto {
+	case SNMPPrivDES:
+		if len(data)%des.BlockSize != 0 {
+			return nil, fmt.Errorf("%w: invalid encrypted data length %d", errSNMPMalformed, len(data))
+		}
+		block, err := des.NewCipher(key[:8])
+		if err != nil {
+			return nil, err
+		}
+		iv := make([]byte, des.BlockSize)
+		for i := range iv {
+			iv[i] = key[8+i] ^ salt[i]
+		}
+		cipher.NewCBCDecrypter(block, iv).CryptBlocks(plain, data)
+	case SNMPPrivAES:
+		block, err := aes.NewCipher(key[:16])
+		if err != nil {
+			return nil, err
+		}
+		cipher.NewCFBDecrypter(block, snmpAESIV(boots, time, salt)).XORKeyStream(plain, data)
+	default:
+		return nil, fmt.Errorf("unsupported privacy protocol %q", u.privProto)
+	}
+	return plain, nil
+}
+
+// snmpAESIV returns the IV of AES, RFC 3826 3.1.2.1.
+func snmpAESIV(boots, time int32, salt []byte) []byte {
+	iv := make([]byte, aes.BlockSize)
+	binary.BigEndian.PutUint32(iv, uint32(boots))
+	binary.BigEndian.PutUint32(iv[4:], uint32(time))
+	copy(iv[8:], salt)
+	return iv
+}
+
+// encode returns the SNMPv3 message of `m` secured with `keys` according to
+// its flags. The keys may be nil for noAuthNoPriv messages.
+func (u *snmpUSM) encode(m *snmpV3Message, keys *snmpKeys) ([]byte, error) {
+	scoped := berTLV(berSequence, berTLV(berOctetString, m.engineID), berTLV(berOctetString), m.pdu)
+	var authParams, privParams []byte
+	if m.flags&snmpFlagPriv != 0 {
+		data, salt, err := u.encrypt(keys.priv, scoped, m.boots, m.time)
+		if err != nil {
+			return nil, err
+		}
+		scoped, privParams = berTLV(berOctetString, data), salt
+	}
+	if m.flags&snmpFlagAuth != 0 {
+		authParams = make([]byte, snmpAuthParamsLen(u.authProto))
+	}
+	privTLV := berTLV(berOctetString, privParams)
+	secParams := berTLV(berSequence,
+		berTLV(berOctetString, m.engineID),
+		berInt(int64(m.boots)),
+		berInt(int64(m.time)),
+		berTLV(berOctetString, []byte(m.user)),
+		berTLV(berOctetString, authParams),
+		privTLV)
+	header := berTLV(berSequence,
+		berInt(int64(m.msgID)),
+		berInt(snmpMaxMessageSize),
+		berTLV(berOctetString, []byte{m.flags}),
+		berInt(snmpSecurityModelUSM))
+	msg := berTLV(berSequence, berInt(snmpVersion3), header, berTLV(berOctetString, secParams), scoped)
+
+	if m.flags&snmpFlagAuth != 0 {
+		// The authentication parameters are the last but one of the security
+		// parameters, which are followed by the scoped PDU immediately.
+		end := len(msg) - len(scoped) - len(privTLV)
+		copy(msg[end-len(authParams):end], u.mac(keys.auth, msg))
+	}
+	return msg, nil
+}
+
+// decode decodes the SNMPv3 message in `data`, and verifies and decrypts it
+// with `keys` according to its flags. The keys may be nil if the message is
+// expected unauthenticated, such as the report of engine discovery. The
+// message header is returned once decoded, even if the verification fails.
+func (u *snmpUSM) decode(data []byte, keys *snmpKeys) (*snmpV3Message, *snmpPDU, error) {
+	// The authentication parameters are zeroed in place for verification.
+	data = append([]byte(nil), data...)
+	d := &berDecoder{data}
+	content, err := d.expect(berSequence)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(d.data) > 0 {
+		return nil, nil, fmt.Errorf("%w: trailing data", errSNMPMalformed)
+	}
+	md := &berDecoder{content}
+	version, err := md.int()
+	if err != nil {
+		return nil, nil, err
+	}
+	if version != snmpVersion3 {
+		return nil, nil, fmt.Errorf("%w: unexpected version %d", errSNMPMalformed, version)
+	}
+
+	header, err := md.expect(berSequence)
+	if err != nil {
+		return nil, nil, err
+	}
+	hd := &berDecoder{header}
+	m := &snmpV3Message{}
+	ints := make([]int64, 2) // msgID, msgMaxSize
+	for i := range ints {
+		if ints[i], err = hd.int(); err != nil {
+			return nil, nil, err
+		}
+	}
+	m.msgID = int32(ints[0])
+	flags, err := hd.expect(berOctetString)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(flags) != 1 {
+		return nil, nil, fmt.Errorf("%w: invalid msgFlags", errSNMPMalformed)
+	}
+	m.flags = flags[0]
+	if model, err := hd.int(); err != nil {
+		return nil, nil, err
+	} else if model != snmpSecurityModelUSM {
+		return nil, nil, fmt.Errorf("%w: unsupported security model %d", errSNMPMalformed, model)
+	}
+
+	secOctets, err := md.expect(berOctetString)
+	if err != nil {
+		return nil, nil, err
+	}
+	sd := &berDecoder{secOctets}
+	secParams, err := sd.expect(berSequence)
+	if err != nil {
+		return nil, nil, err
+	}
+	spd := &berDecoder{secParams}
+	if m.engineID, err = spd.expect(berOctetString); err != nil {
+		return nil, nil, err
+	}
+	for _, v := range []*int32{&m.boots, &m.time} {
+		i, err := spd.int()
+		if err != nil {
+			return nil, nil, err
+		}
+		if i < 0 || i > 0x7fffffff {
+			return nil, nil, fmt.Errorf("%w: invalid engine boots or time", errSNMPMalformed)
+		}
+		*v = int32(i)
+	}
+	user, err := spd.expect(berOctetString)
+	if err != nil {
+		return nil, nil, err
+	}
+	m.user = string(user)
+	authParams, err := spd.expect(berOctetString)
+	if err != nil {
+		return nil, nil, err
+	}
+	privParams, err := spd.expect(berOctetString)
+	if err != nil {
+		return nil, nil, err
+	}
+	m.engineID = append([]byte(nil), m.engineID...)
+
+	if m.flags&snmpFlagAuth != 0 {
+		if keys == nil || len(keys.auth) == 0 {
+			return m, nil, fmt.Errorf("unexpected authenticated message")
+		}
+		if len(authParams) != snmpAuthParamsLen(u.authProto) {
+			return m, nil, fmt.Errorf("%w: invalid authentication parameters", errSNMPMalformed)
+		}
+		digest := append([]byte(nil), authParams...)
+		for i := range authParams {
+			authParams[i] = 0
+		}
+		if !hmac.Equal(digest, u.mac(keys.auth, data)) {
+			return m, nil, errSNMPAuthFailed
+		}
+	}
+
+	var scoped []byte
+	if m.flags&snmpFlagPriv != 0 {
+		if keys == nil || len(keys.priv) == 0 || m.flags&snmpFlagAuth == 0 {
+			return m, nil, fmt.Errorf("unexpected encrypted message")
+		}
+		encrypted, err := md.expect(berOctetString)
+		if err != nil {
+			return m, nil, err
+		}
+		plain, err := u.decrypt(keys.priv, encrypted, privParams, m.boots, m.time)
+		if err != nil {
+			return m, nil, err
+		}
+		if scoped, err = (&berDecoder{plain}).expect(berSequence); err != nil {
+			return m, nil, err
+		}
+	} else if scoped, err = md.expect(berSequence); err != nil {
+		return m, nil, err
+	}
+
+	scd := &berDecoder{scoped}
+	for i := 0; i < 2; i++ { // contextEngineID, contextName
+		if _, err = scd.expect(berOctetString); err != nil {
+			return m, nil, err
+		}
+	}
+	pdu, err := snmpDecodePDU(scd)
+	if err != nil {
+		return m, nil, err
+	}
+	return m, pdu, nil
+}
+
+// getV3 gets the OID with SNMPv3, and returns the response PDU. The
+// authoritative engine is discovered with an unauthenticated request first.
+func (c *SNMPChecker) getV3(conn net.Conn, buf []byte) (*snmpPDU, error) {
+	u := c.usm
+
+	id := c.nextID()
+	discovery, err := u.encode(&snmpV3Message{
+		msgID: id,
+		flags: snmpFlagReportable,
+		pdu:   (&snmpPDU{typ: snmpGetRequest, requestID: id}).encode(),
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+	var engine *snmpV3Message
+	if _, err = snmpExchange(conn, buf, discovery, func(data []byte) (*snmpPDU, error) {
+		m, pdu, err := u.decode(data, nil)
+		if err != nil {
+			return nil, err
+		}
+		if m.msgID != id {
+			return nil, errSNMPMismatch
+		}
+		if len(m.engineID) == 0 {
+			return nil, fmt.Errorf("%w: no engine ID discovered", errSNMPMalformed)
+		}
+		engine = m
+		return pdu, nil
+	}); err != nil {
+		return nil, err
+	}
+
+	keys := u.localize(engine.engineID)
+	id = c.nextID()
+	req, err := u.encode(&snmpV3Message{
+		msgID:    id,
+		flags:    snmpFlagReportable | u.flags(),
+		engineID: engine.engineID,
+		boots:    engine.boots,
+		time:     engine.time,
+		user:     u.user,
+		pdu:      (&snmpPDU{typ: snmpGetRequest, requestID: id, oid: c.oid, valueTag: berNull}).encode(),
+	}, keys)
+	if err != nil {
+		return nil, err
+	}
+	return snmpExchange(conn, buf, req, func(data []byte) (*snmpPDU, error) {
+		m, pdu, err := u.decode(data, keys)
+		if err != nil {
+			return nil, err
+		}
+		if m.msgID != id {
+			return nil, errSNMPMismatch
+		}
+		// Reports may be unauthenticated, but responses may not.
+		if pdu.typ != snmpReport && m.flags&(snmpFlagAuth|snmpFlagPriv) != u.flags() {
+			return nil, fmt.Errorf("response with security level lower than request")
+		}
+		return pdu, nil
+	})
+}
@@ -23,11 +23,27 @@ name                value
 -----------------------------------
 send                non-empty string
 receive             non-empty string
+send-file           file of send, loaded when the checker is created
+receive-file        file of receive, loaded when the checker is created
+send-encoding       raw | hex | base64, encoding of send
+receive-encoding    raw | hex | base64, encoding of receive
 prxoy-protocol      v1 | v2
+connect-timeout     duration to connect, capped by the check timeout
+read-timeout        duration to exchange data after connected
+netns               network namespace name or path to check from
+max-latency         duration from dialing to the response, over which the check fails
 ------------------------------------
+
+The send may embed the target address with template tokens {{.IP}}, {{.Port}}
+and {{.Addr}}, which are expanded in text form per check.
+
+The send-file and receive-file are alternatives to send and receive for large
+payloads, whose content is decoded the same as the inline ones.
 */
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"net"
@@ -39,12 +55,15 @@ import (
 	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/utils"
 )
 
-var _ CheckMethod = (*TCPChecker)(nil)
+var _ CheckMethodEx = (*TCPChecker)(nil)
 
 type TCPChecker struct {
-	send       string
-	receive    string
+	send       *payloadTemplate
+	receive    []byte
 	proxyProto string // "v1", "v2"
+	timeouts   phaseTimeouts
+	netns      string
+	maxLatency time.Duration
 }
 
 func init() {
@@ -52,62 +71,68 @@ func init() {
 }
 
 func (c *TCPChecker) Check(target *utils.L3L4Addr, timeout time.Duration) (types.State, error) {
-	if timeout <= time.Duration(0) {
-		return types.Unknown, fmt.Errorf("zero timeout on TCP check")
+	return checkWithTimeout(c, target, timeout)
+}
+
+func (c *TCPChecker) CheckContext(ctx context.Context, target *utils.L3L4Addr) (types.State, error) {
+	res, err := c.CheckEx(ctx, target)
+	return res.State, err
+}
+
+func (c *TCPChecker) CheckEx(ctx context.Context, target *utils.L3L4Addr) (*CheckResult, error) {
+	start := time.Now()
+	timeout, err := checkTimeout(ctx, "TCP", start)
+	if err != nil {
+		return checkError(start, err)
 	}
 
 	network := target.Network()
 	addr := target.Addr()
 	glog.V(9).Infof("Start TCP check to %s ...", addr)
 
-	start := time.Now()
 	deadline := start.Add(timeout)
 
 	dial := net.Dialer{
-		Timeout: timeout,
+		Timeout: c.timeouts.dialTimeout(start, deadline),
 	}
-	conn, err := dial.Dial(network, addr)
+	conn, err := netnsDial(ctx, &dial, c.netns, network, addr)
 	if err != nil {
-		glog.V(9).Infof("TCP check %v %v: failed to dial", addr, types.Unhealthy)
-		return types.Unhealthy, nil
+		return checkFailed("TCP", addr, start, errReason(err, true), "failed to dial: %v", err), nil
 	}
 	defer conn.Close()
+	defer closeOnCancel(ctx, conn)()
 
 	tcpConn, ok := conn.(*net.TCPConn)
 	if !ok {
-		glog.V(9).Infof("TCP check %v %v: failed to create tcp socket", addr, types.Unhealthy)
-		return types.Unhealthy, nil
+		return checkFailed("TCP", addr, start, ReasonUnknown, "failed to create tcp socket"), nil
 	}
 
-	if len(c.send) == 0 && len(c.receive) == 0 {
-		glog.V(9).Infof("TCP check %v %v: succeed", addr, types.Healthy)
-		return types.Healthy, nil
+	if c.send.empty() && len(c.receive) == 0 {
+		return checkLatency("TCP", addr, checkSucceed("TCP", addr, start), time.Since(start),
+			c.maxLatency), nil
 	}
 
-	err = tcpConn.SetDeadline(deadline)
+	err = tcpConn.SetDeadline(c.timeouts.readDeadline(start, time.Now(), deadline))
 	if err != nil {
-		glog.V(9).Infof("TCP check %v %v: failed to set deadline", addr, types.Unhealthy)
-		return types.Unhealthy, nil
+		return checkFailed("TCP", addr, start, ReasonUnknown, "failed to set deadline"), nil
 	}
 
 	if "v2" == c.proxyProto {
 		if err = utils.WriteFull(tcpConn, proxyProtoV2LocalCmd); err != nil {
-			glog.V(9).Infof("TCP check %v %v: failed to send proxy protocol v2 data",
-				addr, types.Unhealthy)
-			return types.Unhealthy, nil
+			return checkFailed("TCP", addr, start, errReason(err, false),
+				"failed to send proxy protocol v2 data: %v", err), nil
 		}
 	} else if "v1" == c.proxyProto {
 		if err = utils.WriteFull(tcpConn, []byte(proxyProtoV1LocalCmd)); err != nil {
-			glog.V(9).Infof("TCP check %v %v: failed to send proxy protocol v1 data",
-				addr, types.Unhealthy)
-			return types.Unhealthy, nil
+			return checkFailed("TCP", addr, start, errReason(err, false),
+				"failed to send proxy protocol v1 data: %v", err), nil
 		}
 	}
 
-	if len(c.send) > 0 {
-		if err = utils.WriteFull(tcpConn, []byte(c.send)); err != nil {
-			glog.V(9).Infof("TCP check %v %v: failed to send request", addr, types.Unhealthy)
-			return types.Unhealthy, nil
+	if send := c.send.expand(target); len(send) > 0 {
+		if err = utils.WriteFull(tcpConn, send); err != nil {
+			return checkFailed("TCP", addr, start, errReason(err, false),
+				"failed to send request: %v", err), nil
 		}
 	}
 
@@ -115,63 +140,108 @@ func (c *TCPChecker) Check(target *utils.L3L4Addr, timeout time.Duration) (types
 		buf := make([]byte, len(c.receive))
 		n, err := io.ReadFull(tcpConn, buf)
 		if err != nil {
-			glog.V(9).Infof("TCP check %v %v: failed to read response", addr, types.Unhealthy)
-			return types.Unhealthy, nil
+			if err == io.ErrUnexpectedEOF && !bytes.HasPrefix(c.receive, buf[:n]) {
+				return checkFailed("TCP", addr, start, ReasonPayloadMismatch,
+					"unexpected response %q", buf[:n]), nil
+			}
+			return checkFailed("TCP", addr, start, errReason(err, false),
+				"failed to read response: %v", err), nil
 		}
-		got := string(buf[:n])
-		if got != c.receive {
-			glog.V(9).Infof("TCP check %v %v: unexpected response", addr, types.Unhealthy)
-			return types.Unhealthy, nil
+		if !bytes.Equal(buf[:n], c.receive) {
+			return checkFailed("TCP", addr, start, ReasonPayloadMismatch,
+				"unexpected response %q", buf[:n]), nil
 		}
 	}
 
-	glog.V(9).Infof("TCP check %v %v: succeed", addr, types.Healthy)
-	return types.Healthy, nil
+	// The latency is up to the response if any, or the request sent.
+	return checkLatency("TCP", addr, checkSucceed("TCP", addr, start), time.Since(start),
+		c.maxLatency), nil
 }
 
-func (c *TCPChecker) validate(params map[string]string) error {
+func (c *TCPChecker) DefaultParams() map[string]string {
+	return map[string]string{
+		"send":              "",
+		"receive":           "",
+		ParamSendFile:       "",
+		ParamReceiveFile:    "",
+		"send-encoding":     PayloadEncodingRaw,
+		"receive-encoding":  PayloadEncodingRaw,
+		ParamProxyProto:     "",
+		ParamConnectTimeout: "",
+		ParamReadTimeout:    "",
+		ParamNetns:          "",
+		ParamMaxLatency:     "",
+	}
+}
+
+// parse validates params and returns the checker bound with them.
+func (c *TCPChecker) parse(params map[string]string) (*TCPChecker, error) {
+	checker := &TCPChecker{}
 	unsupported := make([]string, 0, len(params))
 	for param, val := range params {
+		var err error
 		switch param {
 		case "send":
 			if len(val) == 0 {
-				return fmt.Errorf("empty tcp checker param: %s", param)
+				return nil, fmt.Errorf("empty tcp checker param: %s", param)
 			}
+			checker.send, err = parsePayloadTemplate(val, params["send-encoding"])
 		case "receive":
 			if len(val) == 0 {
-				return fmt.Errorf("empty tcp checker param: %s", param)
+				return nil, fmt.Errorf("empty tcp checker param: %s", param)
+			}
+			checker.receive, err = decodePayload(val, params["receive-encoding"])
+		case ParamSendFile:
+			var data string
+			if data, err = payloadFile(params, "send", val); err == nil {
+				checker.send, err = parsePayloadTemplate(data, params["send-encoding"])
+			}
+		case ParamReceiveFile:
+			var data string
+			if data, err = payloadFile(params, "receive", val); err == nil {
+				checker.receive, err = decodePayload(data, params["receive-encoding"])
+			}
+		case "send-encoding", "receive-encoding":
+			if _, err := decodePayload("", val); err != nil {
+				return nil, fmt.Errorf("invalid tcp checker param value: %s:%s", param, val)
 			}
 		case ParamProxyProto:
 			val = strings.ToLower(val)
 			if val != "v1" && val != "v2" {
-				return fmt.Errorf("invalid tcp checker param value: %s:%s", param, params[param])
+				return nil, fmt.Errorf("invalid tcp checker param value: %s:%s", param, params[param])
 			}
+			checker.proxyProto = val
+		case ParamNetns:
+			err = utils.ValidateNetns(val)
+			checker.netns = val
+		case ParamMaxLatency:
+			checker.maxLatency, err = parsePhaseTimeout(val)
 		default:
-			unsupported = append(unsupported, param)
+			var ok bool
+			if ok, err = checker.timeouts.set(param, val); !ok {
+				unsupported = append(unsupported, param)
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid tcp checker param value: %s:%s: %v", param, val, err)
 		}
 	}
 
 	if len(unsupported) > 0 {
-		return fmt.Errorf("unsupported tcp checker params: %q", strings.Join(unsupported, ","))
+		return nil, fmt.Errorf("unsupported tcp checker params: %q", strings.Join(unsupported, ","))
 	}
-	return nil
+	return checker, nil
+}
+
+func (c *TCPChecker) validate(params map[string]string) error {
+	_, err := c.parse(params)
+	return err
 }
 
 func (c *TCPChecker) create(params map[string]string) (CheckMethod, error) {
-	if err := c.validate(params); err != nil {
+	checker, err := c.parse(params)
+	if err != nil {
 		return nil, fmt.Errorf("tcp checker param validation failed: %v", err)
 	}
-
-	checker := &TCPChecker{}
-
-	if val, ok := params["send"]; ok {
-		c.send = val
-	}
-	if val, ok := params["receive"]; ok {
-		c.receive = val
-	}
-	if val, ok := params[ParamProxyProto]; ok {
-		c.proxyProto = val
-	}
 	return checker, nil
 }
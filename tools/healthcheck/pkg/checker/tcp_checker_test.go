@@ -21,16 +21,17 @@ import (
 	"testing"
 	"time"
 
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/types"
 	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/utils"
 )
 
 var tcp_targets = []utils.L3L4Addr{
-	{net.ParseIP("192.168.88.130"), 80, utils.IPProtoTCP},
-	{net.ParseIP("11.22.33.44"), 80, utils.IPProtoTCP},
-	{net.ParseIP("192.168.88.130"), 8383, utils.IPProtoTCP},
-	{net.ParseIP("2001::30"), 80, utils.IPProtoTCP},
-	{net.ParseIP("1234:5678::9"), 80, utils.IPProtoTCP},
-	{net.ParseIP("2001::30"), 8383, utils.IPProtoTCP},
+	{IP: net.ParseIP("192.168.88.130"), Port: 80, Proto: utils.IPProtoTCP},
+	{IP: net.ParseIP("11.22.33.44"), Port: 80, Proto: utils.IPProtoTCP},
+	{IP: net.ParseIP("192.168.88.130"), Port: 8383, Proto: utils.IPProtoTCP},
+	{IP: net.ParseIP("2001::30"), Port: 80, Proto: utils.IPProtoTCP},
+	{IP: net.ParseIP("1234:5678::9"), Port: 80, Proto: utils.IPProtoTCP},
+	{IP: net.ParseIP("2001::30"), Port: 8383, Proto: utils.IPProtoTCP},
 }
 
 func TestTCPChecker(t *testing.T) {
@@ -53,3 +54,116 @@ func TestTCPChecker(t *testing.T) {
 		}
 	}
 }
+
+// startTCPScript serves a scripted tcp server, which replies script[req] to
+// the first request req of each connection, and returns the target address.
+func startTCPScript(t *testing.T, script map[string][]byte) *utils.L3L4Addr {
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen tcp: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				buf := make([]byte, 1024)
+				n, err := conn.Read(buf)
+				if err != nil {
+					return
+				}
+				conn.Write(script[string(buf[:n])])
+			}()
+		}
+	}()
+	port := ln.Addr().(*net.TCPAddr).Port
+	return &utils.L3L4Addr{IP: net.ParseIP("127.0.0.1"), Port: uint16(port), Proto: utils.IPProtoTCP}
+}
+
+func TestTCPCheckerPayload(t *testing.T) {
+	target := startTCPScript(t, map[string][]byte{
+		"\x00\x01ping":      []byte("\x00\x02pong\xff"),
+		"hello":             []byte("hi"),
+		"127.0.0.1:ping":    []byte("pong"),
+		"\x01127.0.0.1\x02": []byte("\x03"),
+	})
+	sendFile := writeTestFile(t, "send", []byte("\x00\x01ping"))
+	receiveFile := writeTestFile(t, "receive", []byte("\x00\x02pong\xff"))
+	hexFile := writeTestFile(t, "send.hex", []byte("01{{.IP}}02"))
+
+	for _, tc := range []struct {
+		name   string
+		params map[string]string
+		expect types.State
+	}{
+		{"no payload", nil, types.Healthy},
+		{"raw", map[string]string{"send": "hello", "receive": "hi"}, types.Healthy},
+		{"raw mismatch", map[string]string{"send": "hello", "receive": "ho"}, types.Unhealthy},
+		{"short response", map[string]string{"send": "hello", "receive": "hi, there"}, types.Unhealthy},
+		{"hex", map[string]string{"send": "000170696e67", "receive": "0002706F6E67FF",
+			"send-encoding": "hex", "receive-encoding": "hex"}, types.Healthy},
+		{"hex mismatch", map[string]string{"send": "000170696e67", "receive": "0002706f6e67fe",
+			"send-encoding": "hex", "receive-encoding": "hex"}, types.Unhealthy},
+		{"base64", map[string]string{"send": "AAFwaW5n", "receive": "AAJwb25n/w==",
+			"send-encoding": "base64", "receive-encoding": "base64"}, types.Healthy},
+		{"template", map[string]string{"send": "{{.IP}}:ping", "receive": "pong"}, types.Healthy},
+		{"hex template", map[string]string{"send": "01{{.IP}}02", "receive": "03",
+			"send-encoding": "hex", "receive-encoding": "hex"}, types.Healthy},
+		{"files", map[string]string{"send-file": sendFile, "receive-file": receiveFile}, types.Healthy},
+		{"file mismatch", map[string]string{"send-file": sendFile, "receive": "pong"}, types.Unhealthy},
+		{"hex template file", map[string]string{"send-file": hexFile, "receive": "03",
+			"send-encoding": "hex", "receive-encoding": "hex"}, types.Healthy},
+	} {
+		checker, err := (&TCPChecker{}).create(tc.params)
+		if err != nil {
+			t.Fatalf("%s: failed to create TCP checker: %v", tc.name, err)
+		}
+		state, err := checker.Check(target, 300*time.Millisecond)
+		if err != nil {
+			t.Errorf("%s: failed to execute TCP checker: %v", tc.name, err)
+		} else if state != tc.expect {
+			t.Errorf("%s: expect %v, got %v", tc.name, tc.expect, state)
+		}
+	}
+}
+
+func TestTCPCheckerParams(t *testing.T) {
+	file := writeTestFile(t, "payload", []byte("hello"))
+	for _, tc := range []struct {
+		params map[string]string
+		valid  bool
+	}{
+		{nil, true},
+		{map[string]string{"send": "a", "receive": "b", "send-encoding": "raw",
+			"receive-encoding": "raw", ParamProxyProto: "V1"}, true},
+		{map[string]string{"send": "0a0B", "send-encoding": "HEX"}, true},
+		{map[string]string{"send": "GET {{.Addr}} {{ .IP }} {{.Port}}"}, true},
+		{map[string]string{"send": "0a0", "send-encoding": "hex"}, false},
+		{map[string]string{"receive": "zz", "receive-encoding": "hex"}, false},
+		{map[string]string{"receive": "AAJ@", "receive-encoding": "base64"}, false},
+		{map[string]string{"send": "a", "send-encoding": "utf8"}, false},
+		{map[string]string{"send": "{{.Proto}}"}, false},
+		{map[string]string{"send": "{{.IP"}, false},
+		{map[string]string{"send": ""}, false},
+		{map[string]string{"receive": ""}, false},
+		{map[string]string{"send-file": file, "receive-file": file}, true},
+		{map[string]string{"send-file": file, "send": "a"}, false},
+		{map[string]string{"receive-file": file, "receive": "a"}, false},
+		{map[string]string{"send-file": file, "send-encoding": "hex"}, false},
+		{map[string]string{"send-file": file + ".missing"}, false},
+		{map[string]string{"receive-file": ""}, false},
+		{map[string]string{ParamProxyProto: "v3"}, false},
+		{map[string]string{"expect": "a"}, false},
+	} {
+		err := (&TCPChecker{}).validate(tc.params)
+		if tc.valid && err != nil {
+			t.Errorf("expect %v valid, got %v", tc.params, err)
+		} else if !tc.valid && err == nil {
+			t.Errorf("expect %v invalid", tc.params)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+// /*
+// Copyright 2025 IQiYi Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// */
+
+package checker
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/utils"
+)
+
+// Timing is the schedule of a checker. A target is checked every `interval`
+// and each check is given `timeout`. Once the target turns Unhealthy, it's
+// re-probed every `down-retry-interval` instead, which is usually shorter than
+// `interval` to notice the recovery sooner. Zero `down-retry-interval` means
+// the same as `interval`.
+//
+// Timings are inherited in the order of global defaults, virtual server, and
+// real server, where the zero fields take the values from upper levels.
+type Timing struct {
+	Interval          time.Duration `yaml:"interval"`
+	Timeout           time.Duration `yaml:"timeout"`
+	DownRetryInterval time.Duration `yaml:"down-retry-interval"`
+}
+
+func (t *Timing) Valid() error {
+	if t.Interval <= 0 {
+		return fmt.Errorf("invalid checker interval %v", t.Interval)
+	}
+	if t.Timeout <= 0 {
+		return fmt.Errorf("invalid checker timeout %v", t.Timeout)
+	}
+	if t.Timeout >= t.Interval {
+		return fmt.Errorf("checker timeout %v not less than interval %v", t.Timeout, t.Interval)
+	}
+	if t.DownRetryInterval < 0 {
+		return fmt.Errorf("invalid checker down-retry-interval %v", t.DownRetryInterval)
+	}
+	if t.DownRetryInterval > 0 {
+		if t.DownRetryInterval > t.Interval {
+			return fmt.Errorf("checker down-retry-interval %v greater than interval %v",
+				t.DownRetryInterval, t.Interval)
+		}
+		if t.Timeout >= t.DownRetryInterval {
+			return fmt.Errorf("checker timeout %v not less than down-retry-interval %v",
+				t.Timeout, t.DownRetryInterval)
+		}
+	}
+	return nil
+}
+
+func (t *Timing) MergeDefault(defaultTiming *Timing) {
+	if t.Interval == 0 {
+		t.Interval = defaultTiming.Interval
+	}
+	if t.Timeout == 0 {
+		t.Timeout = defaultTiming.Timeout
+	}
+	if t.DownRetryInterval == 0 {
+		t.DownRetryInterval = defaultTiming.DownRetryInterval
+	}
+}
+
+// Resolved returns a copy of t with all the fields set explicitly.
+func (t *Timing) Resolved() Timing {
+	resolved := *t
+	if resolved.DownRetryInterval == 0 {
+		resolved.DownRetryInterval = resolved.Interval
+	}
+	return resolved
+}
+
+// NewTimedChecker validates the timing of the checker and creates the checker
+// method as NewServiceChecker does. It returns the resolved timing alongside the
+// method for scheduling.
+func NewTimedChecker(kind Method, target, vip *utils.L3L4Addr, configs map[string]string,
+	timing *Timing) (CheckMethod, Timing, error) {
+	if err := timing.Valid(); err != nil {
+		return nil, Timing{}, err
+	}
+	method, err := NewServiceChecker(kind, target, vip, configs)
+	if err != nil {
+		return nil, Timing{}, err
+	}
+	return method, timing.Resolved(), nil
+}
@@ -0,0 +1,106 @@
+// /*
+// Copyright 2025 IQiYi Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// */
+
+package checker
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/utils"
+)
+
+func TestTimingValid(t *testing.T) {
+	tests := []struct {
+		name   string
+		timing Timing
+		err    string // substring of the error, empty if valid
+	}{
+		{"valid", Timing{Interval: 3 * time.Second, Timeout: 2 * time.Second}, ""},
+		{"down retry", Timing{Interval: 3 * time.Second, Timeout: time.Second,
+			DownRetryInterval: 2 * time.Second}, ""},
+		{"down retry equals interval", Timing{Interval: 3 * time.Second, Timeout: time.Second,
+			DownRetryInterval: 3 * time.Second}, ""},
+		{"zero interval", Timing{Timeout: time.Second}, "invalid checker interval"},
+		{"negative interval", Timing{Interval: -time.Second, Timeout: time.Second},
+			"invalid checker interval"},
+		{"zero timeout", Timing{Interval: time.Second}, "invalid checker timeout"},
+		{"negative timeout", Timing{Interval: time.Second, Timeout: -time.Second},
+			"invalid checker timeout"},
+		{"timeout equals interval", Timing{Interval: time.Second, Timeout: time.Second},
+			"not less than interval"},
+		{"timeout exceeds interval", Timing{Interval: time.Second, Timeout: 2 * time.Second},
+			"not less than interval"},
+		{"negative down retry", Timing{Interval: 3 * time.Second, Timeout: time.Second,
+			DownRetryInterval: -time.Second}, "invalid checker down-retry-interval"},
+		{"down retry exceeds interval", Timing{Interval: 3 * time.Second, Timeout: time.Second,
+			DownRetryInterval: 4 * time.Second}, "greater than interval"},
+		{"timeout exceeds down retry", Timing{Interval: 3 * time.Second, Timeout: 2 * time.Second,
+			DownRetryInterval: time.Second}, "not less than down-retry-interval"},
+	}
+	for _, tc := range tests {
+		err := tc.timing.Valid()
+		if len(tc.err) == 0 {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %v", tc.name, err)
+			}
+		} else if err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("%s: expect error %q, got %v", tc.name, tc.err, err)
+		}
+	}
+}
+
+func TestTimingResolve(t *testing.T) {
+	global := Timing{Interval: 3 * time.Second, Timeout: 2 * time.Second}
+	tests := []struct {
+		name   string
+		timing Timing
+		expect Timing
+	}{
+		{"inherit all", Timing{}, Timing{3 * time.Second, 2 * time.Second, 3 * time.Second}},
+		{"override interval", Timing{Interval: 5 * time.Second},
+			Timing{5 * time.Second, 2 * time.Second, 5 * time.Second}},
+		{"override timeout", Timing{Timeout: time.Second},
+			Timing{3 * time.Second, time.Second, 3 * time.Second}},
+		{"override down retry", Timing{DownRetryInterval: 2500 * time.Millisecond},
+			Timing{3 * time.Second, 2 * time.Second, 2500 * time.Millisecond}},
+	}
+	for _, tc := range tests {
+		timing := tc.timing
+		timing.MergeDefault(&global)
+		if got := timing.Resolved(); got != tc.expect {
+			t.Errorf("%s: expect %+v, got %+v", tc.name, tc.expect, got)
+		}
+	}
+}
+
+func TestNewTimedChecker(t *testing.T) {
+	target := utils.ParseL3L4Addr("127.0.0.1:80")
+	timing := Timing{Interval: 3 * time.Second, Timeout: time.Second}
+	method, resolved, err := NewTimedChecker(CheckMethodTCP, target, nil, nil, &timing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if method == nil || resolved.DownRetryInterval != timing.Interval {
+		t.Errorf("unexpected method %v or resolved timing %+v", method, resolved)
+	}
+
+	timing.Timeout = timing.Interval
+	if _, _, err := NewTimedChecker(CheckMethodTCP, target, nil, nil, &timing); err == nil {
+		t.Error("expect error on timeout not less than interval")
+	}
+}
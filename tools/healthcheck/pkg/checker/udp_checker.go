@@ -18,19 +18,47 @@ package checker
 
 /*
 UDP Checker Params:
------------------------------------
+-------------------------------------------------------------
 name                value
------------------------------------
-send                non-empty string
-receive             non-empty string
+-------------------------------------------------------------
+send                non-empty string, alias of send1
+receive             non-empty string, alias of receive1
+send-file           file of send, loaded when the checker is created
+receive-file        file of receive, loaded when the checker is created
+sendN               data to send in the N-th exchange, N starts from 1
+receiveN            data expected in the N-th exchange, N starts from 1
+send-encoding       raw | hex | base64, encoding of all sendN
+receive-encoding    raw | hex | base64, encoding of all receiveN
+match               exact | prefix | contains, match mode of all receiveN
 prxoy-protocol      v2
-------------------------------------
+connect-timeout     duration to connect, capped by the check timeout
+read-timeout        duration to do all exchanges after connected
+netns               network namespace name or path to check from
+-------------------------------------------------------------
+
+Exchanges are executed in order within the check timeout, and each of them
+sends its data and waits for the response. A response spanning multiple
+datagrams is accumulated until it matches or the timeout expires. Any response
+is accepted if receiveN is empty. If both send and receive are empty, the
+check succeeds unless ICMP port unreachable is received.
+
+The sendN may embed the target address with template tokens {{.IP}}, {{.Port}}
+and {{.Addr}}, which are expanded in text form per check. Data around tokens
+are decoded separately in send-encoding.
+
+The send-file and receive-file are alternatives to send and receive for large
+payloads, whose content is decoded the same as the inline ones.
 */
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"net"
+	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/golang/glog"
@@ -38,12 +66,34 @@ import (
 	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/utils"
 )
 
-var _ CheckMethod = (*UDPChecker)(nil)
+var _ CheckMethodEx = (*UDPChecker)(nil)
+
+const (
+	udpMaxPayload   = 65536
+	udpMaxExchanges = 16
+)
+
+const (
+	UDPEncodingRaw    = PayloadEncodingRaw
+	UDPEncodingHex    = PayloadEncodingHex
+	UDPEncodingBase64 = PayloadEncodingBase64
+
+	UDPMatchExact    = "exact"
+	UDPMatchPrefix   = "prefix"
+	UDPMatchContains = "contains"
+)
+
+type udpExchange struct {
+	send    *payloadTemplate
+	receive []byte
+}
 
 type UDPChecker struct {
-	send       string
-	receive    string
+	exchanges  []udpExchange
+	match      string
 	proxyProto string // "v2"
+	timeouts   phaseTimeouts
+	netns      string
 }
 
 func init() {
@@ -51,130 +101,298 @@ func init() {
 }
 
 func (c *UDPChecker) Check(target *utils.L3L4Addr, timeout time.Duration) (types.State, error) {
-	if timeout <= time.Duration(0) {
-		return types.Unknown, fmt.Errorf("zero timeout on UDP check")
+	return checkWithTimeout(c, target, timeout)
+}
+
+func (c *UDPChecker) CheckContext(ctx context.Context, target *utils.L3L4Addr) (types.State, error) {
+	res, err := c.CheckEx(ctx, target)
+	return res.State, err
+}
+
+func (c *UDPChecker) CheckEx(ctx context.Context, target *utils.L3L4Addr) (*CheckResult, error) {
+	start := time.Now()
+	timeout, err := checkTimeout(ctx, "UDP", start)
+	if err != nil {
+		return checkError(start, err)
 	}
 
 	network := target.Network()
 	addr := target.Addr()
 	glog.V(9).Infof("Start UDP check to %s ...", addr)
 
-	start := time.Now()
 	deadline := start.Add(timeout)
 
 	dial := net.Dialer{
-		Timeout: timeout,
+		Timeout: c.timeouts.dialTimeout(start, deadline),
 	}
-	conn, err := dial.Dial(network, addr)
+	conn, err := netnsDial(ctx, &dial, c.netns, network, addr)
 	if err != nil {
-		glog.V(9).Infof("UDP check %v %v: failed to dial", addr, types.Unhealthy)
-		return types.Unhealthy, nil
+		return checkFailed("UDP", addr, start, errReason(err, true), "failed to dial: %v", err), nil
 	}
 	defer conn.Close()
+	defer closeOnCancel(ctx, conn)()
 
 	udpConn, ok := conn.(*net.UDPConn)
 	if !ok {
-		glog.V(9).Infof("UDP check %v %v: failed to create udp socket", addr, types.Unhealthy)
-		return types.Unhealthy, nil
+		return checkFailed("UDP", addr, start, ReasonUnknown, "failed to create udp socket"), nil
 	}
 
-	err = udpConn.SetDeadline(deadline)
+	err = udpConn.SetDeadline(c.timeouts.readDeadline(start, time.Now(), deadline))
 	if err != nil {
-		glog.V(9).Infof("UDP check %v %v: failed to set deadline", addr, types.Unhealthy)
-		return types.Unhealthy, nil
+		return checkFailed("UDP", addr, start, ReasonUnknown, "failed to set deadline"), nil
 	}
 
 	if "v2" == c.proxyProto {
 		if err = utils.WriteFull(udpConn, proxyProtoV2LocalCmd); err != nil {
-			glog.V(9).Infof("UDP check %v %v: failed to send proxy protocol v2 data",
-				addr, types.Unhealthy)
-			return types.Unhealthy, nil
+			return checkFailed("UDP", addr, start, errReason(err, false),
+				"failed to send proxy protocol v2 data: %v", err), nil
 		}
 	}
 
-	if len(c.send) > 0 {
-		err = utils.WriteFull(udpConn, []byte(c.send))
-	} else {
-		_, err = udpConn.Write([]byte{})
-	}
-	if err != nil {
-		glog.V(9).Infof("UDP check %v %v: failed to write", addr, types.Unhealthy)
-		return types.Unhealthy, nil
+	exchanges := c.exchanges
+	if len(exchanges) == 0 {
+		exchanges = []udpExchange{{}}
 	}
+	buf := make([]byte, udpMaxPayload)
+	for i, ex := range exchanges {
+		if send := ex.send.expand(target); len(send) > 0 {
+			err = utils.WriteFull(udpConn, send)
+		} else {
+			_, err = udpConn.Write([]byte{})
+		}
+		if err != nil {
+			if isConnRefused(err) {
+				return checkFailed("UDP", addr, start, ReasonConnRefused,
+					"connection refused (port unreachable)"), nil
+			}
+			return checkFailed("UDP", addr, start, errReason(err, false),
+				"failed to write in exchange %d: %v", i+1, err), nil
+		}
 
-	buf := make([]byte, len(c.receive))
-	n, _, err := udpConn.ReadFrom(buf)
-	if err != nil {
-		if len(c.send) == 0 && len(c.receive) == 0 {
-			if neterr, ok := err.(net.Error); ok {
-				if neterr.Timeout() {
-					// Intuitively, we should assign types.Unknown to the check result.
-					// But it can lead to inconsistent problem when health states changed.
-					// Thus return types.Healthy instead.
-					glog.V(9).Infof("UDP check %v %v: i/o timeout, state %v returned", addr,
-						types.Unknown, types.Healthy)
-					return types.Healthy, nil
+		got := 0
+		for {
+			n, _, err := udpConn.ReadFrom(buf[got:])
+			if err != nil {
+				// ICMP port unreachable is reported as ECONNREFUSED on connected udp socket.
+				// It means the service is down definitely, even if no response is expected.
+				if isConnRefused(err) {
+					return checkFailed("UDP", addr, start, ReasonConnRefused,
+						"connection refused (port unreachable)"), nil
+				}
+				if len(exchanges) == 1 && ex.send.empty() && len(ex.receive) == 0 {
+					if neterr, ok := err.(net.Error); ok {
+						if neterr.Timeout() {
+							// Intuitively, we should assign types.Unknown to the check result.
+							// But it can lead to inconsistent problem when health states changed.
+							// Thus return types.Healthy instead.
+							glog.V(9).Infof("UDP check %v %v: i/o timeout, state %v returned", addr,
+								types.Unknown, types.Healthy)
+							return &CheckResult{
+								State:   types.Healthy,
+								Reason:  ReasonTimeout,
+								Latency: time.Since(start),
+								Detail:  "no response, regarded as healthy",
+							}, nil
+						}
+					}
 				}
+				return checkFailed("UDP", addr, start, errReason(err, false),
+					"failed to read in exchange %d: %v", i+1, err), nil
+			}
+			got += n
+
+			done, matched := udpMatch(buf[:got], ex.receive, c.match)
+			if done && !matched || !done && got == len(buf) {
+				return checkFailed("UDP", addr, start, ReasonPayloadMismatch,
+					"unexpected response in exchange %d - %q", i+1, buf[:got]), nil
+			}
+			if done {
+				break
 			}
 		}
-		glog.V(9).Infof("UDP check %v %v: failed to read", addr, types.Unhealthy)
-		return types.Unhealthy, nil
 	}
 
-	got := string(buf[:n])
-	if got != c.receive {
-		glog.V(9).Infof("UDP check %v %v: unexpected response", addr, types.Unhealthy)
-		return types.Unhealthy, nil
+	return checkSucceed("UDP", addr, start), nil
+}
+
+// udpMatch compares the response `got` with `expect` in the `mode`. It returns
+// done false if more data is required to decide, or whether they are matched.
+func udpMatch(got, expect []byte, mode string) (done, matched bool) {
+	if len(expect) == 0 {
+		return true, true
 	}
+	switch mode {
+	case UDPMatchContains:
+		if bytes.Contains(got, expect) {
+			return true, true
+		}
+		return false, false
+	case UDPMatchPrefix:
+		if len(got) < len(expect) {
+			if bytes.HasPrefix(expect, got) {
+				return false, false
+			}
+			return true, false
+		}
+		return true, bytes.HasPrefix(got, expect)
+	default: // UDPMatchExact
+		if len(got) < len(expect) && bytes.HasPrefix(expect, got) {
+			return false, false
+		}
+		return true, bytes.Equal(got, expect)
+	}
+}
 
-	glog.V(9).Infof("UDP check %v %v: succeed", addr, types.Healthy)
-	return types.Healthy, nil
+func isConnRefused(err error) bool {
+	return errors.Is(err, syscall.ECONNREFUSED)
 }
 
-func (c *UDPChecker) validate(params map[string]string) error {
+func (c *UDPChecker) DefaultParams() map[string]string {
+	return map[string]string{
+		"send":              "",
+		"receive":           "",
+		ParamSendFile:       "",
+		ParamReceiveFile:    "",
+		"send-encoding":     UDPEncodingRaw,
+		"receive-encoding":  UDPEncodingRaw,
+		"match":             UDPMatchExact,
+		ParamProxyProto:     "",
+		ParamConnectTimeout: "",
+		ParamReadTimeout:    "",
+		ParamNetns:          "",
+	}
+}
+
+// parseExchangeParam returns the exchange index and direction of param
+// send, receive, sendN, or receiveN.
+func parseExchangeParam(param string) (int, bool, bool) {
+	var send bool
+	var idx string
+	if strings.HasPrefix(param, "send") {
+		send, idx = true, strings.TrimPrefix(param, "send")
+	} else if strings.HasPrefix(param, "receive") {
+		idx = strings.TrimPrefix(param, "receive")
+	} else {
+		return 0, false, false
+	}
+	if len(idx) == 0 {
+		return 1, send, true
+	}
+	n, err := strconv.Atoi(idx)
+	if err != nil || n < 1 || n > udpMaxExchanges || idx[0] == '0' {
+		return 0, false, false
+	}
+	return n, send, true
+}
+
+// parse validates params and returns the checker bound with them.
+func (c *UDPChecker) parse(params map[string]string) (*UDPChecker, error) {
+	checker := &UDPChecker{match: UDPMatchExact}
+	sendEncoding := params["send-encoding"]
+	receiveEncoding := params["receive-encoding"]
+
 	unsupported := make([]string, 0, len(params))
+	exchanges := make(map[int]*udpExchange)
+	seen := make(map[string]string)
 	for param, val := range params {
 		switch param {
-		case "send":
-			if len(val) == 0 {
-				return fmt.Errorf("empty udp checker param: %s", param)
+		case "send-encoding", "receive-encoding":
+			if _, err := decodePayload("", val); err != nil {
+				return nil, fmt.Errorf("invalid udp checker param value: %s:%s", param, val)
 			}
-		case "receive":
-			if len(val) == 0 {
-				return fmt.Errorf("empty udp checker param: %s", param)
+		case "match":
+			val = strings.ToLower(val)
+			if val != UDPMatchExact && val != UDPMatchPrefix && val != UDPMatchContains {
+				return nil, fmt.Errorf("invalid udp checker param value: %s:%s", param, params[param])
 			}
+			checker.match = val
 		case ParamProxyProto:
 			val = strings.ToLower(val)
 			if val != "v2" {
-				return fmt.Errorf("invalid udp checker param value: %s:%s", param, params[param])
+				return nil, fmt.Errorf("invalid udp checker param value: %s:%s", param, params[param])
+			}
+			checker.proxyProto = val
+		case ParamConnectTimeout, ParamReadTimeout:
+			if _, err := checker.timeouts.set(param, val); err != nil {
+				return nil, fmt.Errorf("invalid udp checker param value: %s:%s: %v", param, val, err)
+			}
+		case ParamNetns:
+			if err := utils.ValidateNetns(val); err != nil {
+				return nil, fmt.Errorf("invalid udp checker param value: %s:%s: %v", param, val, err)
 			}
+			checker.netns = val
 		default:
-			unsupported = append(unsupported, param)
+			name, fromFile := param, false
+			if param == ParamSendFile || param == ParamReceiveFile {
+				name, fromFile = strings.TrimSuffix(param, "-file"), true
+			}
+			idx, send, ok := parseExchangeParam(name)
+			if !ok {
+				unsupported = append(unsupported, param)
+				continue
+			}
+			if len(val) == 0 {
+				return nil, fmt.Errorf("empty udp checker param: %s", param)
+			}
+			key := fmt.Sprintf("%v-%d", send, idx)
+			if other, ok := seen[key]; ok {
+				return nil, fmt.Errorf("conflict udp checker params: %s, %s", other, param)
+			}
+			seen[key] = param
+			ex, ok := exchanges[idx]
+			if !ok {
+				ex = &udpExchange{}
+				exchanges[idx] = ex
+			}
+			var err error
+			var size int
+			path := val
+			if fromFile {
+				if val, err = readPayloadFile(path); err != nil {
+					return nil, fmt.Errorf("invalid udp checker param value: %s:%s: %v", param, path, err)
+				}
+			}
+			if send {
+				ex.send, err = parsePayloadTemplate(val, sendEncoding)
+				if err == nil {
+					size = ex.send.size
+				}
+			} else {
+				ex.receive, err = decodePayload(val, receiveEncoding)
+				size = len(ex.receive)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("invalid udp checker param value: %s:%s: %v", param, path, err)
+			}
+			if size > udpMaxPayload {
+				return nil, fmt.Errorf("udp checker param %s too large", param)
+			}
 		}
 	}
 
 	if len(unsupported) > 0 {
-		return fmt.Errorf("unsupported udp checker params: %q", strings.Join(unsupported, ","))
+		return nil, fmt.Errorf("unsupported udp checker params: %q", strings.Join(unsupported, ","))
 	}
-	return nil
-}
 
-func (c *UDPChecker) create(params map[string]string) (CheckMethod, error) {
-	if err := c.validate(params); err != nil {
-		return nil, fmt.Errorf("udp checker param validation failed: %v", err)
+	for i := 1; i <= len(exchanges); i++ {
+		ex, ok := exchanges[i]
+		if !ok {
+			return nil, fmt.Errorf("udp checker exchange %d missing", i)
+		}
+		checker.exchanges = append(checker.exchanges, *ex)
 	}
+	return checker, nil
+}
 
-	checker := &UDPChecker{}
+func (c *UDPChecker) validate(params map[string]string) error {
+	_, err := c.parse(params)
+	return err
+}
 
-	if val, ok := params["send"]; ok {
-		c.send = val
-	}
-	if val, ok := params["receive"]; ok {
-		c.receive = val
-	}
-	if val, ok := params[ParamProxyProto]; ok {
-		c.proxyProto = val
+func (c *UDPChecker) create(params map[string]string) (CheckMethod, error) {
+	checker, err := c.parse(params)
+	if err != nil {
+		return nil, fmt.Errorf("udp checker param validation failed: %v", err)
 	}
-
 	return checker, nil
 }
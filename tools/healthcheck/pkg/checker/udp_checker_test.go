@@ -17,20 +17,22 @@
 package checker
 
 import (
+	"bytes"
 	"net"
 	"testing"
 	"time"
 
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/types"
 	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/utils"
 )
 
 var udp_targets = []utils.L3L4Addr{
-	{net.ParseIP("192.168.88.130"), 6000, utils.IPProtoUDP},
-	{net.ParseIP("11.22.33.44"), 6000, utils.IPProtoUDP},
-	{net.ParseIP("192.168.88.130"), 6602, utils.IPProtoUDP},
-	{net.ParseIP("2001::30"), 6000, utils.IPProtoUDP},
-	{net.ParseIP("1234:5678::9"), 6000, utils.IPProtoUDP},
-	{net.ParseIP("2001::30"), 6002, utils.IPProtoUDP},
+	{IP: net.ParseIP("192.168.88.130"), Port: 6000, Proto: utils.IPProtoUDP},
+	{IP: net.ParseIP("11.22.33.44"), Port: 6000, Proto: utils.IPProtoUDP},
+	{IP: net.ParseIP("192.168.88.130"), Port: 6602, Proto: utils.IPProtoUDP},
+	{IP: net.ParseIP("2001::30"), Port: 6000, Proto: utils.IPProtoUDP},
+	{IP: net.ParseIP("1234:5678::9"), Port: 6000, Proto: utils.IPProtoUDP},
+	{IP: net.ParseIP("2001::30"), Port: 6002, Proto: utils.IPProtoUDP},
 }
 
 func TestUDPChecker(t *testing.T) {
@@ -53,3 +55,183 @@ func TestUDPChecker(t *testing.T) {
 		}
 	}
 }
+
+func TestUDPCheckerConnRefused(t *testing.T) {
+	// Get a free udp port, and close it so that the kernel replies ICMP port unreachable.
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen udp: %v", err)
+	}
+	port := conn.LocalAddr().(*net.UDPAddr).Port
+	conn.Close()
+
+	target := utils.L3L4Addr{IP: net.ParseIP("127.0.0.1"), Port: uint16(port), Proto: utils.IPProtoUDP}
+	checker, err := (&UDPChecker{}).create(nil)
+	if err != nil {
+		t.Fatalf("Failed to create UDP checker %v: %v", target, err)
+	}
+	state, err := checker.Check(&target, 500*time.Millisecond)
+	if err != nil {
+		t.Fatalf("Failed to execute UDP checker %v: %v", target, err)
+	}
+	if state != types.Unhealthy {
+		t.Errorf("UDP check on closed port %v: expect %v, got %v", target, types.Unhealthy, state)
+	}
+}
+
+func TestUDPCheckerSilentServer(t *testing.T) {
+	// An open port without any response is regarded healthy when send/receive are empty.
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen udp: %v", err)
+	}
+	defer conn.Close()
+	port := conn.LocalAddr().(*net.UDPAddr).Port
+
+	target := utils.L3L4Addr{IP: net.ParseIP("127.0.0.1"), Port: uint16(port), Proto: utils.IPProtoUDP}
+	checker, err := (&UDPChecker{}).create(nil)
+	if err != nil {
+		t.Fatalf("Failed to create UDP checker %v: %v", target, err)
+	}
+	state, err := checker.Check(&target, 200*time.Millisecond)
+	if err != nil {
+		t.Fatalf("Failed to execute UDP checker %v: %v", target, err)
+	}
+	if state != types.Healthy {
+		t.Errorf("UDP check on silent port %v: expect %v, got %v", target, types.Healthy, state)
+	}
+}
+
+// startUDPScript serves a scripted udp server, which replies the datagrams in
+// script[req] to each request req, and returns the target address.
+func startUDPScript(t *testing.T, script map[string][][]byte) *utils.L3L4Addr {
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen udp: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	go func() {
+		buf := make([]byte, udpMaxPayload)
+		for {
+			n, peer, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			for _, resp := range script[string(buf[:n])] {
+				conn.WriteTo(resp, peer)
+			}
+		}
+	}()
+	port := conn.LocalAddr().(*net.UDPAddr).Port
+	return &utils.L3L4Addr{IP: net.ParseIP("127.0.0.1"), Port: uint16(port), Proto: utils.IPProtoUDP}
+}
+
+func TestUDPCheckerExchanges(t *testing.T) {
+	large := bytes.Repeat([]byte("x"), 2000)
+	target := startUDPScript(t, map[string][][]byte{
+		"\x00\x01ping": {[]byte("\x00\x02pong\xff")},
+		"hello":        {[]byte("hi, "), []byte("there")},
+		"step2":        {[]byte("ready")},
+		"ip 127.0.0.1": {[]byte("\x00ok")},
+		"large":        {large},
+		"":             {[]byte("empty")},
+	})
+	sendFile := writeTestFile(t, "send", []byte("\x00\x01ping"))
+	receiveFile := writeTestFile(t, "receive", []byte("\x00\x02pong\xff"))
+	largeFile := writeTestFile(t, "large", large)
+
+	for _, tc := range []struct {
+		name   string
+		params map[string]string
+		expect types.State
+	}{
+		{"raw", map[string]string{"send": "step2", "receive": "ready"}, types.Healthy},
+		{"raw mismatch", map[string]string{"send": "step2", "receive": "readz"}, types.Unhealthy},
+		{"hex mismatch", map[string]string{"send": "000170696e67", "receive": "0002706f6e67fe",
+			"send-encoding": "hex", "receive-encoding": "hex"}, types.Unhealthy},
+		{"hex match", map[string]string{"send": "000170696E67", "receive": "0002706f6e67ff",
+			"send-encoding": "hex", "receive-encoding": "hex"}, types.Healthy},
+		{"base64", map[string]string{"send": "AAFwaW5n", "receive": "AAJwb25n/w==",
+			"send-encoding": "base64", "receive-encoding": "base64"}, types.Healthy},
+		{"template", map[string]string{"send": "ip {{.IP}}", "receive": "AG9r",
+			"receive-encoding": "base64"}, types.Healthy},
+		{"hex template", map[string]string{"send": "697020{{.IP}}", "receive": "\x00ok",
+			"send-encoding": "hex"}, types.Healthy},
+		{"split response", map[string]string{"send": "hello", "receive": "hi, there"}, types.Healthy},
+		{"prefix", map[string]string{"send": "hello", "receive": "hi", "match": "prefix"}, types.Healthy},
+		{"prefix mismatch", map[string]string{"send": "hello", "receive": "ho", "match": "prefix"}, types.Unhealthy},
+		{"contains", map[string]string{"send": "hello", "receive": "there", "match": "contains"}, types.Healthy},
+		{"contains mismatch", map[string]string{"send": "hello", "receive": "where", "match": "contains"}, types.Unhealthy},
+		{"exact truncated", map[string]string{"send": "large", "receive": "xxxx"}, types.Unhealthy},
+		{"exact large", map[string]string{"send": "large", "receive": string(large)}, types.Healthy},
+		{"any response", map[string]string{"send": "step2"}, types.Healthy},
+		{"no response", map[string]string{"send": "unknown"}, types.Unhealthy},
+		{"empty datagram", map[string]string{"receive": "empty"}, types.Healthy},
+		{"two steps", map[string]string{"send1": "hello", "receive1": "hi, there",
+			"send2": "step2", "receive2": "ready"}, types.Healthy},
+		{"two steps alias", map[string]string{"send": "hello", "receive": "hi, there",
+			"send2": "step2", "receive2": "ready"}, types.Healthy},
+		{"two steps fail", map[string]string{"send1": "hello", "receive1": "hi, there",
+			"send2": "step2", "receive2": "not ready"}, types.Unhealthy},
+		{"files", map[string]string{"send-file": sendFile, "receive-file": receiveFile}, types.Healthy},
+		{"large file", map[string]string{"send": "large", "receive-file": largeFile}, types.Healthy},
+		{"two steps file", map[string]string{"send-file": sendFile, "receive-file": receiveFile,
+			"send2": "step2", "receive2": "ready"}, types.Healthy},
+		{"two steps no response", map[string]string{"send1": "hello", "receive1": "hi, there",
+			"send2": "unknown"}, types.Unhealthy},
+	} {
+		checker, err := (&UDPChecker{}).create(tc.params)
+		if err != nil {
+			t.Fatalf("%s: failed to create UDP checker: %v", tc.name, err)
+		}
+		state, err := checker.Check(target, 300*time.Millisecond)
+		if err != nil {
+			t.Errorf("%s: failed to execute UDP checker: %v", tc.name, err)
+		} else if state != tc.expect {
+			t.Errorf("%s: expect %v, got %v", tc.name, tc.expect, state)
+		}
+	}
+}
+
+func TestUDPCheckerParams(t *testing.T) {
+	file := writeTestFile(t, "payload", []byte("hello"))
+	for _, tc := range []struct {
+		params map[string]string
+		valid  bool
+	}{
+		{nil, true},
+		{map[string]string{"send": "a", "receive": "b", "send-encoding": "raw",
+			"receive-encoding": "raw", "match": "exact", ParamProxyProto: "v2"}, true},
+		{map[string]string{"send1": "a", "receive2": "b", "match": "Contains"}, true},
+		{map[string]string{"send": "0a0B", "send-encoding": "HEX"}, true},
+		{map[string]string{"send": "0a0", "send-encoding": "hex"}, false},
+		{map[string]string{"receive": "zz", "receive-encoding": "hex"}, false},
+		{map[string]string{"receive": "AAJ@", "receive-encoding": "base64"}, false},
+		{map[string]string{"send": "a", "send-encoding": "utf8"}, false},
+		{map[string]string{"send2": "{{.IP}}:{{.Port}}", "send1": "{{.Addr}}"}, true},
+		{map[string]string{"send": "{{.Host}}"}, false},
+		{map[string]string{"send": "0a{{.IP}}0", "send-encoding": "hex"}, false},
+		{map[string]string{"match": "regex"}, false},
+		{map[string]string{"send": ""}, false},
+		{map[string]string{"send": "a", "send1": "b"}, false},
+		{map[string]string{"send1": "a", "send3": "b"}, false},
+		{map[string]string{"send0": "a"}, false},
+		{map[string]string{"send01": "a"}, false},
+		{map[string]string{"send17": "a"}, false},
+		{map[string]string{"sendx": "a"}, false},
+		{map[string]string{ParamProxyProto: "v1"}, false},
+		{map[string]string{"send-file": file, "receive-file": file, "send2": "a"}, true},
+		{map[string]string{"send-file": file, "send": "a"}, false},
+		{map[string]string{"send-file": file, "send1": "a"}, false},
+		{map[string]string{"receive-file": file, "receive": "a"}, false},
+		{map[string]string{"send-file": file + ".missing"}, false},
+		{map[string]string{"send2-file": file}, false},
+	} {
+		err := (&UDPChecker{}).validate(tc.params)
+		if tc.valid && err != nil {
+			t.Errorf("expect %v valid, got %v", tc.params, err)
+		} else if !tc.valid && err == nil {
+			t.Errorf("expect %v invalid", tc.params)
+		}
+	}
+}
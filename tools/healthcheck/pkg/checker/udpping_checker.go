@@ -24,10 +24,13 @@ name                value
 send                non-empty string
 receive             non-empty string
 prxoy-protocol      v2
+privileged          auto | true | false
+netns               network namespace name or path to check from
 ------------------------------------
 */
 
 import (
+	"context"
 	"fmt"
 	"time"
 
@@ -36,7 +39,7 @@ import (
 	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/utils"
 )
 
-var _ CheckMethod = (*UDPPingChecker)(nil)
+var _ CheckMethodEx = (*UDPPingChecker)(nil)
 
 // UDPPingChecker is a composite check method, who firstly performs Ping check,
 // and then executes UDP check only after Ping check succeeds.
@@ -46,37 +49,84 @@ type UDPPingChecker struct {
 	*UDPChecker
 }
 
+// udppingPingTimeoutSplit is the share of the timeout for the Ping check, so
+// that the UDP check is always left the rest even if Ping replies slowly.
+const udppingPingTimeoutSplit = 0.5
+
 func init() {
 	registerMethod(CheckMethodUDPPing, &UDPPingChecker{})
 }
 
 func (c *UDPPingChecker) Check(target *utils.L3L4Addr, timeout time.Duration) (types.State, error) {
-	if timeout <= time.Duration(0) {
-		return types.Unknown, fmt.Errorf("zero timeout on UDPPing check")
-	}
+	return checkWithTimeout(c, target, timeout)
+}
+
+func (c *UDPPingChecker) CheckContext(ctx context.Context, target *utils.L3L4Addr) (types.State, error) {
+	res, err := c.CheckEx(ctx, target)
+	return res.State, err
+}
 
+func (c *UDPPingChecker) CheckEx(ctx context.Context, target *utils.L3L4Addr) (*CheckResult, error) {
 	start := time.Now()
+	timeout, err := checkTimeout(ctx, "UDPPing", start)
+	if err != nil {
+		return checkError(start, err)
+	}
+
 	addr := target.Addr()
 	glog.V(9).Infof("Start UDPPing check to %v ...", addr)
 
-	state, err := c.PingChecker.Check(target, timeout)
+	pingCtx, cancel := context.WithTimeout(ctx, time.Duration(float64(timeout)*udppingPingTimeoutSplit))
+	res, err := c.PingChecker.CheckEx(pingCtx, target)
+	cancel()
 	if err != nil {
-		return types.Unknown, err
+		return res, err
 	}
-	if state == types.Unhealthy {
+	if res.State == types.Unhealthy {
 		glog.V(9).Infof("UDPPing check %v %v: ping check failed", addr, types.Unhealthy)
-		return types.Unhealthy, nil
+		res.Detail = "ping check " + res.Detail
+		return res, nil
 	}
 
-	state, err = c.UDPChecker.Check(target, time.Until(start.Add(timeout)))
-	glog.V(9).Infof("UDPPing check %v %v", addr, state)
-	return state, err
+	res, err = c.UDPChecker.CheckEx(ctx, target)
+	res.Latency = time.Since(start)
+	glog.V(9).Infof("UDPPing check %v %v", addr, res.State)
+	return res, err
 }
 
-func (c *UDPPingChecker) validate(params map[string]string) error {
-	// PingChecker requires no params.
+// splitParams separates the params for PingChecker from those for UDPChecker.
+func (c *UDPPingChecker) splitParams(params map[string]string) (map[string]string,
+	map[string]string) {
+	pingParams := make(map[string]string)
+	udpParams := make(map[string]string)
+	for param, val := range params {
+		switch param {
+		case ParamPrivileged:
+			pingParams[param] = val
+		case ParamNetns:
+			pingParams[param] = val
+			udpParams[param] = val
+		default:
+			udpParams[param] = val
+		}
+	}
+	return pingParams, udpParams
+}
+
+func (c *UDPPingChecker) DefaultParams() map[string]string {
+	params := c.UDPChecker.DefaultParams()
+	for param, val := range c.PingChecker.DefaultParams() {
+		params[param] = val
+	}
+	return params
+}
 
-	return c.UDPChecker.validate(params)
+func (c *UDPPingChecker) validate(params map[string]string) error {
+	pingParams, udpParams := c.splitParams(params)
+	if err := c.PingChecker.validate(pingParams); err != nil {
+		return err
+	}
+	return c.UDPChecker.validate(udpParams)
 }
 
 func (c *UDPPingChecker) create(params map[string]string) (CheckMethod, error) {
@@ -84,11 +134,12 @@ func (c *UDPPingChecker) create(params map[string]string) (CheckMethod, error) {
 		return nil, fmt.Errorf("udpping param checker validation failed: %v", err)
 	}
 
-	pingChecker, err := c.PingChecker.create(nil)
+	pingParams, udpParams := c.splitParams(params)
+	pingChecker, err := c.PingChecker.create(pingParams)
 	if err != nil {
 		return nil, fmt.Errorf("fail to create udpping checker: %v", err)
 	}
-	udpChecker, err := c.UDPChecker.create(params)
+	udpChecker, err := c.UDPChecker.create(udpParams)
 	if err != nil {
 		return nil, fmt.Errorf("fail to create udping checker: %v", err)
 	}
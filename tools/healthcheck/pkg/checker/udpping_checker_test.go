@@ -25,12 +25,12 @@ import (
 )
 
 var udpping_targets = []utils.L3L4Addr{
-	{net.ParseIP("192.168.88.130"), 6000, utils.IPProtoUDP},
-	{net.ParseIP("11.22.33.44"), 6000, utils.IPProtoUDP},
-	{net.ParseIP("192.168.88.130"), 6602, utils.IPProtoUDP},
-	{net.ParseIP("2001::30"), 6000, utils.IPProtoUDP},
-	{net.ParseIP("1234:5678::9"), 6000, utils.IPProtoUDP},
-	{net.ParseIP("2001::30"), 6002, utils.IPProtoUDP},
+	{IP: net.ParseIP("192.168.88.130"), Port: 6000, Proto: utils.IPProtoUDP},
+	{IP: net.ParseIP("11.22.33.44"), Port: 6000, Proto: utils.IPProtoUDP},
+	{IP: net.ParseIP("192.168.88.130"), Port: 6602, Proto: utils.IPProtoUDP},
+	{IP: net.ParseIP("2001::30"), Port: 6000, Proto: utils.IPProtoUDP},
+	{IP: net.ParseIP("1234:5678::9"), Port: 6000, Proto: utils.IPProtoUDP},
+	{IP: net.ParseIP("2001::30"), Port: 6002, Proto: utils.IPProtoUDP},
 }
 
 func TestUDPPingChecker(t *testing.T) {
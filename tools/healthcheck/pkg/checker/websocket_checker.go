@@ -0,0 +1,345 @@
+// /*
+// Copyright 2025 IQiYi Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// */
+
+package checker
+
+/*
+WebSocket Checker Params:
+-------------------------------------------------------------
+name                value
+-------------------------------------------------------------
+uri                 request URI of the upgrade handshake, default "/"
+host                Host header and TLS server name, defaults to the target address
+origin              Origin header, optional
+tls                 yes | no | true | false, case insensitive
+tls-verify          yes | no | true | false, case insensitive
+ca-file             PEM CA certificates to verify the server, system ones by default
+cert-file           PEM client certificate for mutual TLS, requires key-file
+key-file            PEM private key of cert-file
+ping                yes | no | true | false, send a ping frame and expect the pong
+prxoy-protocol      v1 | v2
+netns               network namespace name or path to check from
+-------------------------------------------------------------
+
+The checker performs the opening handshake of RFC 6455, and requires a "101
+Switching Protocols" response with the Sec-WebSocket-Accept derived from the
+Sec-WebSocket-Key sent. If ping is enabled, a ping frame is sent once upgraded,
+and the pong echoing its payload is expected, where the other frames received
+in between are skipped. The connection is closed with a close frame at last.
+The ca-file, cert-file and key-file require tls, and are loaded when the
+checker is created.
+*/
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/types"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/utils"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/websocket"
+)
+
+var _ CheckMethodEx = (*WebSocketChecker)(nil)
+
+const websocketMaxFrames = 64 // frames skipped at most while waiting for the pong
+
+type WebSocketChecker struct {
+	uri        string
+	host       string
+	origin     string
+	tls        bool
+	tlsVerify  bool
+	tlsFiles   *tlsFiles
+	ping       bool
+	proxyProto string // "v1", "v2"
+	netns      string
+}
+
+func init() {
+	registerMethod(CheckMethodWebSocket, &WebSocketChecker{})
+}
+
+func (c *WebSocketChecker) Check(target *utils.L3L4Addr, timeout time.Duration) (types.State, error) {
+	return checkWithTimeout(c, target, timeout)
+}
+
+func (c *WebSocketChecker) CheckContext(ctx context.Context, target *utils.L3L4Addr) (types.State, error) {
+	res, err := c.CheckEx(ctx, target)
+	return res.State, err
+}
+
+func (c *WebSocketChecker) CheckEx(ctx context.Context, target *utils.L3L4Addr) (*CheckResult, error) {
+	start := time.Now()
+	timeout, err := checkTimeout(ctx, "WebSocket", start)
+	if err != nil {
+		return checkError(start, err)
+	}
+
+	addr := target.Addr()
+	glog.V(9).Infof("Start WebSocket check to %s ...", addr)
+
+	dial := net.Dialer{
+		Timeout: timeout,
+	}
+	rawConn, err := netnsDial(ctx, &dial, c.netns, target.Network(), addr)
+	if err != nil {
+		return checkFailed("WebSocket", addr, start, errReason(err, true), "failed to dial: %v", err), nil
+	}
+	defer rawConn.Close()
+	defer closeOnCancel(ctx, rawConn)()
+
+	if err = rawConn.SetDeadline(start.Add(timeout)); err != nil {
+		return checkFailed("WebSocket", addr, start, ReasonUnknown, "failed to set deadline"), nil
+	}
+
+	if "v2" == c.proxyProto {
+		if err = utils.WriteFull(rawConn, proxyProtoV2LocalCmd); err != nil {
+			return checkFailed("WebSocket", addr, start, errReason(err, false),
+				"failed to send proxy protocol v2 data: %v", err), nil
+		}
+	} else if "v1" == c.proxyProto {
+		if err = utils.WriteFull(rawConn, []byte(proxyProtoV1LocalCmd)); err != nil {
+			return checkFailed("WebSocket", addr, start, errReason(err, false),
+				"failed to send proxy protocol v1 data: %v", err), nil
+		}
+	}
+
+	host := c.host
+	if len(host) == 0 {
+		host = addr
+	}
+
+	conn := rawConn
+	if c.tls {
+		serverName := host
+		if name, _, err := net.SplitHostPort(host); err == nil {
+			serverName = name
+		}
+		tlsConn := tls.Client(rawConn, c.tlsFiles.apply(&tls.Config{
+			ServerName:         serverName,
+			InsecureSkipVerify: !c.tlsVerify,
+			NextProtos:         []string{"http/1.1"},
+		}))
+		if err = tlsConn.HandshakeContext(ctx); err != nil {
+			return checkFailed("WebSocket", addr, start, ReasonTLSFailure,
+				"tls handshake failed: %v", err), nil
+		}
+		conn = tlsConn
+	}
+
+	// 1. opening handshake
+	key, err := websocket.NewKey()
+	if err != nil {
+		return checkError(start, fmt.Errorf("failed to generate websocket key: %v", err))
+	}
+	req := fmt.Sprintf("GET %s HTTP/1.1\r\nHost: %s\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n"+
+		"Sec-WebSocket-Key: %s\r\nSec-WebSocket-Version: %s\r\n", c.uri, host, key, websocket.Version)
+	if len(c.origin) > 0 {
+		req += fmt.Sprintf("Origin: %s\r\n", c.origin)
+	}
+	req += "\r\n"
+	if err = utils.WriteFull(conn, []byte(req)); err != nil {
+		return checkFailed("WebSocket", addr, start, errReason(err, false),
+			"failed to send upgrade request: %v", err), nil
+	}
+
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, &http.Request{Method: http.MethodGet})
+	if err != nil {
+		reason := errReason(err, false)
+		if reason == ReasonUnknown {
+			reason = ReasonProtocolError
+		}
+		return checkFailed("WebSocket", addr, start, reason,
+			"failed to read upgrade response: %v", err), nil
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		resp.Body.Close()
+		return checkFailed("WebSocket", addr, start, ReasonBadStatus,
+			"unexpected response code %d", resp.StatusCode), nil
+	}
+	if err = websocket.CheckUpgrade(resp.Header, key); err != nil {
+		return checkFailed("WebSocket", addr, start, ReasonProtocolError, "%v", err), nil
+	}
+
+	// 2. ping-pong and closing
+	if res := websocketFinish("WebSocket", conn, r, addr, start, c.ping); res != nil {
+		return res, nil
+	}
+	return checkSucceed("WebSocket", addr, start), nil
+}
+
+// websocketFinish exchanges a ping-pong on the upgraded connection `w` and
+// `r` if `ping`, and then closes it with a close frame. It returns the failed
+// result if any.
+func websocketFinish(kind string, w io.Writer, r io.Reader, addr string, start time.Time,
+	ping bool) *CheckResult {
+	if ping {
+		payload := make([]byte, 8)
+		binary.BigEndian.PutUint64(payload, uint64(time.Now().UnixNano()))
+		if err := websocket.WriteClientFrame(w, websocket.OpPing, payload); err != nil {
+			return checkFailed(kind, addr, start, errReason(err, false), "failed to send ping: %v", err)
+		}
+		if res := websocketWaitPong(kind, r, addr, start, payload); res != nil {
+			return res
+		}
+	}
+
+	// The server may have closed the connection already, and the error is ignored.
+	websocket.WriteClientFrame(w, websocket.OpClose, websocket.ClosePayload(websocket.CloseNormal, ""))
+	return nil
+}
+
+// websocketWaitPong reads frames until the pong of `payload`, and returns the
+// failed result if any. The payload of data frames is discarded.
+func websocketWaitPong(kind string, r io.Reader, addr string, start time.Time,
+	payload []byte) *CheckResult {
+	for i := 0; i < websocketMaxFrames; i++ {
+		h, data, err := websocket.ReadServerFrame(r, websocket.MaxControlPayload)
+		if err == websocket.ErrFrameTooLarge && !h.Opcode.IsControl() {
+			continue
+		}
+		if err != nil {
+			reason := errReason(err, false)
+			if reason == ReasonUnknown {
+				reason = ReasonProtocolError
+			}
+			return checkFailed(kind, addr, start, reason, "failed to read pong: %v", err)
+		}
+		switch h.Opcode {
+		case websocket.OpPong:
+			if bytes.Equal(data, payload) {
+				return nil
+			}
+			glog.V(9).Infof("%s check %v: unsolicited pong skipped", kind, addr)
+		case websocket.OpClose:
+			return checkFailed(kind, addr, start, ReasonConnReset,
+				"connection closed by server with code %d", websocket.CloseCode(data))
+		}
+	}
+	return checkFailed(kind, addr, start, ReasonProtocolError,
+		"no pong in %d frames", websocketMaxFrames)
+}
+
+func (c *WebSocketChecker) DefaultParams() map[string]string {
+	return map[string]string{
+		"uri":           "/",
+		"host":          "",
+		"origin":        "",
+		"tls":           "false",
+		"tls-verify":    "true",
+		ParamCAFile:     "",
+		ParamCertFile:   "",
+		ParamKeyFile:    "",
+		"ping":          "false",
+		ParamProxyProto: "",
+		ParamNetns:      "",
+	}
+}
+
+func (c *WebSocketChecker) validate(params map[string]string) error {
+	_, err := c.parse(params)
+	return err
+}
+
+// parse validates params, and returns the TLS files loaded.
+func (c *WebSocketChecker) parse(params map[string]string) (*tlsFiles, error) {
+	unsupported := make([]string, 0, len(params))
+	for param, val := range params {
+		switch param {
+		case "uri":
+			if !strings.HasPrefix(val, "/") || strings.ContainsAny(val, " \r\n") {
+				return nil, fmt.Errorf("invalid websocket checker param value: %s:%s", param, val)
+			}
+		case "host", "origin":
+			if len(val) == 0 || strings.ContainsAny(val, " \r\n") {
+				return nil, fmt.Errorf("invalid websocket checker param value: %s:%q", param, val)
+			}
+		case "tls", "tls-verify", "ping":
+			if _, err := utils.String2bool(val); err != nil {
+				return nil, fmt.Errorf("invalid websocket checker param value: %s:%s", param, val)
+			}
+		case ParamCAFile, ParamCertFile, ParamKeyFile:
+			if len(val) == 0 {
+				return nil, fmt.Errorf("empty websocket checker param: %s", param)
+			}
+		case ParamProxyProto:
+			val = strings.ToLower(val)
+			if val != "v1" && val != "v2" {
+				return nil, fmt.Errorf("invalid websocket checker param value: %s:%s", param, params[param])
+			}
+		case ParamNetns:
+			if err := utils.ValidateNetns(val); err != nil {
+				return nil, fmt.Errorf("invalid websocket checker param value: %s:%s: %v", param, val, err)
+			}
+		default:
+			unsupported = append(unsupported, param)
+		}
+	}
+
+	if len(unsupported) > 0 {
+		return nil, fmt.Errorf("unsupported websocket checker params: %q", strings.Join(unsupported, ","))
+	}
+
+	files, err := loadTLSFiles(params)
+	if err != nil {
+		return nil, fmt.Errorf("invalid websocket checker tls files: %v", err)
+	}
+	if useTLS, _ := utils.String2bool(params["tls"]); files != nil && !useTLS {
+		return nil, fmt.Errorf("websocket checker tls files require tls")
+	}
+	return files, nil
+}
+
+func (c *WebSocketChecker) create(params map[string]string) (CheckMethod, error) {
+	files, err := c.parse(params)
+	if err != nil {
+		return nil, fmt.Errorf("websocket checker param validation failed: %v", err)
+	}
+
+	checker := &WebSocketChecker{
+		uri:        "/",
+		host:       params["host"],
+		origin:     params["origin"],
+		tlsVerify:  true,
+		proxyProto: strings.ToLower(params[ParamProxyProto]),
+		netns:      params[ParamNetns],
+	}
+	if val, ok := params["uri"]; ok {
+		checker.uri = val
+	}
+	if val, ok := params["tls"]; ok {
+		checker.tls, _ = utils.String2bool(val)
+	}
+	if val, ok := params["tls-verify"]; ok {
+		checker.tlsVerify, _ = utils.String2bool(val)
+	}
+	if val, ok := params["ping"]; ok {
+		checker.ping, _ = utils.String2bool(val)
+	}
+	checker.tlsFiles = files
+	return checker, nil
+}
@@ -0,0 +1,193 @@
+// /*
+// Copyright 2025 IQiYi Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// */
+
+package checker
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/types"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/websocket"
+)
+
+// fakeWebSocket is a websocket server handling the opening handshake and the
+// ping frames.
+type fakeWebSocket struct {
+	status    int    // response code of the handshake, 101 if zero
+	accept    string // Sec-WebSocket-Accept, derived from the key if empty
+	noPong    bool   // ignore ping frames
+	closeCode uint16 // send a close frame with the code on ping if nonzero
+	chatty    bool   // send a text frame and an unsolicited pong before the pong
+	uri       string // expected request URI, "/" if empty
+	host      string // expected Host header, the server address if empty
+}
+
+func (s *fakeWebSocket) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	uri, host := s.uri, s.host
+	if len(uri) == 0 {
+		uri = "/"
+	}
+	if len(host) == 0 {
+		host = r.Context().Value(http.LocalAddrContextKey).(net.Addr).String()
+	}
+	if r.RequestURI != uri || r.Host != host || r.Header.Get("Sec-WebSocket-Version") != "13" ||
+		!websocket.HeaderHasToken(r.Header, "Connection", "upgrade") {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if s.status != 0 && s.status != http.StatusSwitchingProtocols {
+		w.WriteHeader(s.status)
+		return
+	}
+
+	conn, rw, err := w.(http.Hijacker).Hijack()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	accept := s.accept
+	if len(accept) == 0 {
+		accept = websocket.AcceptKey(r.Header.Get("Sec-WebSocket-Key"))
+	}
+	rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\n" +
+		"Connection: Upgrade\r\nSec-WebSocket-Accept: " + accept + "\r\n\r\n")
+	rw.Flush()
+
+	for {
+		h, payload, err := websocket.ReadFrame(rw.Reader, websocket.MaxControlPayload)
+		if err != nil || !h.Masked || h.Opcode == websocket.OpClose {
+			return
+		}
+		if h.Opcode != websocket.OpPing || s.noPong {
+			continue
+		}
+		if s.closeCode != 0 {
+			fakeWebSocketWriteFrame(conn, websocket.OpClose, websocket.ClosePayload(int(s.closeCode), ""))
+			return
+		}
+		if s.chatty {
+			fakeWebSocketWriteFrame(conn, websocket.OpText, make([]byte, 300))
+			fakeWebSocketWriteFrame(conn, websocket.OpPong, []byte("unsolicited"))
+		}
+		fakeWebSocketWriteFrame(conn, websocket.OpPong, payload)
+	}
+}
+
+// fakeWebSocketWriteFrame writes an unmasked frame from server.
+func fakeWebSocketWriteFrame(w io.Writer, op websocket.Opcode, payload []byte) {
+	websocket.WriteFrame(w, websocket.Header{Fin: true, Opcode: op}, payload)
+}
+
+func TestWebSocketChecker(t *testing.T) {
+	timeout := 500 * time.Millisecond
+
+	newChecker := func(params map[string]string) CheckMethod {
+		t.Helper()
+		method, err := NewChecker(CheckMethodWebSocket, nil, params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return method
+	}
+	start := func(s *fakeWebSocket) *httptest.Server {
+		ts := httptest.NewServer(s)
+		t.Cleanup(ts.Close)
+		return ts
+	}
+
+	ts := start(&fakeWebSocket{})
+	target := tcpTarget(ts.Listener.Addr())
+	expectResult(t, "upgraded", newChecker(nil), target, timeout, types.Healthy, ReasonNone)
+	expectResult(t, "pong", newChecker(map[string]string{"ping": "yes"}), target, timeout,
+		types.Healthy, ReasonNone)
+
+	ts = start(&fakeWebSocket{uri: "/ws?v=1", host: "ws.example.com"})
+	target = tcpTarget(ts.Listener.Addr())
+	expectResult(t, "uri and host", newChecker(map[string]string{"uri": "/ws?v=1",
+		"host": "ws.example.com"}), target, timeout, types.Healthy, ReasonNone)
+	expectResult(t, "wrong uri", newChecker(nil), target, timeout, types.Unhealthy, ReasonBadStatus)
+
+	ts = start(&fakeWebSocket{chatty: true})
+	expectResult(t, "frames skipped", newChecker(map[string]string{"ping": "yes"}),
+		tcpTarget(ts.Listener.Addr()), timeout, types.Healthy, ReasonNone)
+
+	ts = start(&fakeWebSocket{status: http.StatusOK})
+	expectResult(t, "not upgraded", newChecker(nil), tcpTarget(ts.Listener.Addr()), timeout,
+		types.Unhealthy, ReasonBadStatus)
+
+	ts = start(&fakeWebSocket{accept: "s3pPLMBiTxaQ9kYGzzhZRbK+xOo="})
+	expectResult(t, "bad accept", newChecker(nil), tcpTarget(ts.Listener.Addr()), timeout,
+		types.Unhealthy, ReasonProtocolError)
+
+	ts = start(&fakeWebSocket{noPong: true})
+	target = tcpTarget(ts.Listener.Addr())
+	expectResult(t, "no pong without ping", newChecker(nil), target, timeout, types.Healthy, ReasonNone)
+	expectResult(t, "no pong", newChecker(map[string]string{"ping": "yes"}), target, timeout,
+		types.Unhealthy, ReasonTimeout)
+
+	ts = start(&fakeWebSocket{closeCode: 1011})
+	expectResult(t, "closed", newChecker(map[string]string{"ping": "yes"}),
+		tcpTarget(ts.Listener.Addr()), timeout, types.Unhealthy, ReasonConnReset)
+
+	tlsServer := httptest.NewTLSServer(&fakeWebSocket{})
+	t.Cleanup(tlsServer.Close)
+	target = tcpTarget(tlsServer.Listener.Addr())
+	expectResult(t, "tls", newChecker(map[string]string{"tls": "yes", "tls-verify": "no",
+		"ping": "yes"}), target, timeout, types.Healthy, ReasonNone)
+	expectResult(t, "tls unverified", newChecker(map[string]string{"tls": "yes"}), target, timeout,
+		types.Unhealthy, ReasonTLSFailure)
+	expectResult(t, "tls expected", newChecker(nil), target, timeout,
+		types.Unhealthy, ReasonBadStatus)
+
+	expectResult(t, "refused", newChecker(nil), closedTCPPort(t), timeout,
+		types.Unhealthy, ReasonConnRefused)
+}
+
+func TestWebSocketCheckerParams(t *testing.T) {
+	valid := []map[string]string{
+		nil,
+		{"uri": "/chat", "host": "ws.example.com:8080", "origin": "http://example.com"},
+		{"tls": "true", "tls-verify": "false", "ping": "yes", "proxy-protocol": "V2"},
+	}
+	for _, params := range valid {
+		if err := Validate(CheckMethodWebSocket, params); err != nil {
+			t.Errorf("unexpected error for %v: %v", params, err)
+		}
+	}
+
+	invalid := []map[string]string{
+		{"uri": "chat"},
+		{"uri": "/a b"},
+		{"host": ""},
+		{"origin": "a\r\nb"},
+		{"tls": "maybe"},
+		{"ping": "1x"},
+		{"proxy-protocol": "v3"},
+		{"path": "/"},
+	}
+	for _, params := range invalid {
+		if err := Validate(CheckMethodWebSocket, params); err == nil {
+			t.Errorf("expect error for %v", params)
+		}
+	}
+}
@@ -0,0 +1,243 @@
+// /*
+// Copyright 2025 IQiYi Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// */
+
+// Package grpcwire implements the minimal gRPC client over HTTP/2 shared by the
+// grpc check method and the gRPC plugins, with the length-prefixed framing, the
+// grpc-timeout header, the grpc-status handling, and the protobuf wire format
+// of the messages, so that no gRPC and protobuf runtime is required.
+package grpcwire
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// gRPC status codes.
+const (
+	StatusOK       = "0"
+	StatusNotFound = "5"
+)
+
+// Protobuf wire types supported.
+const (
+	WireVarint = 0
+	WireBytes  = 2
+)
+
+// ErrMalformed is returned for the messages not in the protobuf wire format.
+var ErrMalformed = errors.New("malformed message")
+
+// StatusError is the error of a call failed with non-OK grpc-status.
+type StatusError struct {
+	Code    string
+	Message string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("rpc failed with grpc-status %q: %s", e.Code, e.Message)
+}
+
+// Call calls the gRPC method `url` with the encoded message `msg` via `rt`,
+// and returns the encoded response message, which is limited to `maxSize`
+// bytes. The deadline of ctx is sent in grpc-timeout header, and the call
+// failed with non-OK grpc-status returns *StatusError.
+func Call(ctx context.Context, rt http.RoundTripper, url string, msg []byte, maxSize int) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(Frame(msg)))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/grpc")
+	req.Header.Set("TE", "trailers")
+	if deadline, ok := ctx.Deadline(); ok {
+		req.Header.Set("Grpc-Timeout", FormatTimeout(time.Until(deadline)))
+	}
+
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected http status %d", resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "application/grpc") {
+		return nil, fmt.Errorf("unexpected content type %q", ct)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, int64(maxSize)+5))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %v", err)
+	}
+
+	// grpc-status is in trailers, or in headers for Trailers-Only responses.
+	code, text := resp.Trailer.Get("Grpc-Status"), resp.Trailer.Get("Grpc-Message")
+	if len(code) == 0 {
+		code, text = resp.Header.Get("Grpc-Status"), resp.Header.Get("Grpc-Message")
+	}
+	if code != StatusOK {
+		return nil, &StatusError{Code: code, Message: text}
+	}
+
+	data, err := Unframe(body)
+	if err != nil {
+		return nil, fmt.Errorf("malformed response: %v", err)
+	}
+	return data, nil
+}
+
+// Frame encodes `msg` as a length-prefixed gRPC message.
+func Frame(msg []byte) []byte {
+	buf := make([]byte, 5, 5+len(msg))
+	binary.BigEndian.PutUint32(buf[1:], uint32(len(msg)))
+	return append(buf, msg...)
+}
+
+// Unframe decodes a length-prefixed gRPC message.
+func Unframe(data []byte) ([]byte, error) {
+	if len(data) < 5 {
+		return nil, fmt.Errorf("truncated message")
+	}
+	if data[0] != 0 {
+		return nil, fmt.Errorf("compressed message not supported")
+	}
+	if size := binary.BigEndian.Uint32(data[1:5]); uint32(len(data)-5) != size {
+		return nil, fmt.Errorf("invalid message size %d", size)
+	}
+	return data[5:], nil
+}
+
+// FormatTimeout formats `d` as the value of grpc-timeout header.
+func FormatTimeout(d time.Duration) string {
+	if d < time.Millisecond {
+		d = time.Millisecond
+	}
+	// at most 8 digits
+	if ms := d.Milliseconds(); ms < 1e8 {
+		return fmt.Sprintf("%dm", ms)
+	}
+	return fmt.Sprintf("%dS", int64(d.Seconds()))
+}
+
+// ParseTimeout parses the value of grpc-timeout header.
+func ParseTimeout(val string) (time.Duration, error) {
+	if len(val) < 2 || len(val) > 9 {
+		return 0, fmt.Errorf("invalid grpc-timeout %q", val)
+	}
+	var n uint64
+	for _, c := range val[:len(val)-1] {
+		if c < '0' || c > '9' {
+			return 0, fmt.Errorf("invalid grpc-timeout %q", val)
+		}
+		n = n*10 + uint64(c-'0')
+	}
+	d := time.Duration(n)
+	switch val[len(val)-1] {
+	case 'H':
+		return d * time.Hour, nil
+	case 'M':
+		return d * time.Minute, nil
+	case 'S':
+		return d * time.Second, nil
+	case 'm':
+		return d * time.Millisecond, nil
+	case 'u':
+		return d * time.Microsecond, nil
+	case 'n':
+		return d, nil
+	}
+	return 0, fmt.Errorf("invalid grpc-timeout %q", val)
+}
+
+// AppendVarintField appends the varint field `field` of `val` to `b`, where
+// zero is the default and omitted.
+func AppendVarintField(b []byte, field int, val uint64) []byte {
+	if val == 0 {
+		return b
+	}
+	b = binary.AppendUvarint(b, uint64(field<<3|WireVarint))
+	return binary.AppendUvarint(b, val)
+}
+
+// AppendBytesField appends the length-delimited field `field` of `val` to `b`.
+func AppendBytesField(b []byte, field int, val []byte) []byte {
+	b = binary.AppendUvarint(b, uint64(field<<3|WireBytes))
+	b = binary.AppendUvarint(b, uint64(len(val)))
+	return append(b, val...)
+}
+
+// AppendStringField appends the string field `field` of `val` to `b`, where
+// the empty string is the default and omitted.
+func AppendStringField(b []byte, field int, val string) []byte {
+	if len(val) == 0 {
+		return b
+	}
+	return AppendBytesField(b, field, []byte(val))
+}
+
+// WalkFields calls `fn` on each field of the encoded message `data`, with the
+// value of varint fields in `num`, or length-delimited fields in `buf`. The
+// fields of other wire types are skipped.
+func WalkFields(data []byte, fn func(field int, num uint64, buf []byte) error) error {
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		if n <= 0 {
+			return ErrMalformed
+		}
+		data = data[n:]
+		field := int(key >> 3)
+		switch key & 0x7 {
+		case WireVarint:
+			val, n := binary.Uvarint(data)
+			if n <= 0 {
+				return ErrMalformed
+			}
+			data = data[n:]
+			if err := fn(field, val, nil); err != nil {
+				return err
+			}
+		case 1: // 64-bit
+			if len(data) < 8 {
+				return ErrMalformed
+			}
+			data = data[8:]
+		case WireBytes:
+			size, n := binary.Uvarint(data)
+			if n <= 0 || uint64(len(data)-n) < size {
+				return ErrMalformed
+			}
+			val := data[n : n+int(size)]
+			data = data[n+int(size):]
+			if err := fn(field, 0, val); err != nil {
+				return err
+			}
+		case 5: // 32-bit
+			if len(data) < 4 {
+				return ErrMalformed
+			}
+			data = data[4:]
+		default:
+			return ErrMalformed
+		}
+	}
+	return nil
+}
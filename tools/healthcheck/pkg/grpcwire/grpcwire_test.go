@@ -0,0 +1,171 @@
+// /*
+// Copyright 2025 IQiYi Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// */
+
+package grpcwire
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"golang.org/x/net/http2"
+)
+
+func TestTimeoutHeader(t *testing.T) {
+	for d, expect := range map[time.Duration]string{
+		0:                       "1m",
+		1500 * time.Microsecond: "1m",
+		2 * time.Second:         "2000m",
+		30 * time.Hour:          "108000S",
+	} {
+		if got := FormatTimeout(d); got != expect {
+			t.Errorf("FormatTimeout(%v): expect %q, got %q", d, expect, got)
+		}
+	}
+	for _, d := range []time.Duration{time.Millisecond, 1500 * time.Millisecond, 30 * time.Hour} {
+		got, err := ParseTimeout(FormatTimeout(d))
+		if err != nil || got != d {
+			t.Errorf("timeout %v: got %v, %v", d, got, err)
+		}
+	}
+	for val, expect := range map[string]time.Duration{"2H": 2 * time.Hour, "5M": 5 * time.Minute,
+		"100u": 100 * time.Microsecond, "7n": 7} {
+		if got, err := ParseTimeout(val); err != nil || got != expect {
+			t.Errorf("grpc-timeout %q: expect %v, got %v, %v", val, expect, got, err)
+		}
+	}
+	for _, val := range []string{"", "m", "1", "1x", "-1m", "123456789m"} {
+		if _, err := ParseTimeout(val); err == nil {
+			t.Errorf("expect error with grpc-timeout %q", val)
+		}
+	}
+}
+
+func TestFrame(t *testing.T) {
+	msg := AppendStringField(nil, 1, "svc")
+	if got, err := Unframe(Frame(msg)); err != nil || !bytes.Equal(got, msg) {
+		t.Errorf("unexpected message %x, %v", got, err)
+	}
+	for _, data := range [][]byte{
+		{0, 0, 0},
+		{1, 0, 0, 0, 0},
+		{0, 0, 0, 0, 2, 1},
+	} {
+		if _, err := Unframe(data); err == nil {
+			t.Errorf("expect error with message %x", data)
+		}
+	}
+}
+
+func TestWalkFields(t *testing.T) {
+	var data []byte
+	data = AppendVarintField(data, 1, 300)
+	data = append(data, 2<<3|1, 1, 2, 3, 4, 5, 6, 7, 8)
+	data = AppendStringField(data, 3, "x")
+	data = append(data, 4<<3|5, 1, 2, 3, 4)
+	var num uint64
+	var str string
+	if err := WalkFields(data, func(field int, n uint64, buf []byte) error {
+		switch field {
+		case 1:
+			num = n
+		case 3:
+			str = string(buf)
+		}
+		return nil
+	}); err != nil || num != 300 || str != "x" {
+		t.Errorf("unexpected fields %d %q, %v", num, str, err)
+	}
+	noop := func(int, uint64, []byte) error { return nil }
+	for _, data := range [][]byte{{0x0a, 0x80}, {0x0a, 0x05, 'x'}, {0x08}, {0x0b}} {
+		if err := WalkFields(data, noop); !errors.Is(err, ErrMalformed) {
+			t.Errorf("expect malformed message %x, got %v", data, err)
+		}
+	}
+}
+
+func TestCall(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	var timeout string
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		timeout = r.Header.Get("Grpc-Timeout")
+		w.Header().Set("Content-Type", "application/grpc")
+		switch r.URL.Path {
+		case "/echo":
+			body := new(bytes.Buffer)
+			body.ReadFrom(r.Body)
+			w.Header().Set("Trailer", "Grpc-Status")
+			w.WriteHeader(http.StatusOK)
+			w.Write(body.Bytes())
+			w.Header().Set("Grpc-Status", StatusOK)
+		case "/missing":
+			w.Header().Set("Grpc-Status", StatusNotFound)
+			w.Header().Set("Grpc-Message", "no service")
+			w.WriteHeader(http.StatusOK)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	go func() {
+		server := &http2.Server{}
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go server.ServeConn(conn, &http2.ServeConnOpts{Handler: handler})
+		}
+	}()
+	tr := &http2.Transport{
+		AllowHTTP: true,
+		DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, network, ln.Addr().String())
+		},
+	}
+	defer tr.CloseIdleConnections()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	msg := AppendStringField(nil, 1, "hello")
+	if got, err := Call(ctx, tr, "http://localhost/echo", msg, 64); err != nil || !bytes.Equal(got, msg) {
+		t.Errorf("unexpected response %x, %v", got, err)
+	}
+	if d, err := ParseTimeout(timeout); err != nil || d <= 0 || d > 2*time.Second {
+		t.Errorf("unexpected grpc-timeout %q", timeout)
+	}
+
+	var statusErr *StatusError
+	if _, err := Call(ctx, tr, "http://localhost/missing", msg, 64); !errors.As(err, &statusErr) ||
+		statusErr.Code != StatusNotFound || statusErr.Message != "no service" {
+		t.Errorf("expect grpc-status %s, got %v", StatusNotFound, err)
+	}
+	if _, err := Call(ctx, tr, "http://localhost/other", msg, 64); err == nil || errors.As(err, &statusErr) {
+		t.Errorf("expect http status error, got %v", err)
+	}
+	if _, err := Call(ctx, tr, "http://localhost/echo", bytes.Repeat([]byte{'x'}, 100), 64); err == nil {
+		t.Errorf("expect error with oversized response")
+	}
+}
@@ -0,0 +1,515 @@
+// /*
+// Copyright 2025 IQiYi Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// */
+
+package manager
+
+/*
+Admin HTTP API:
+-----------------------------------------------------------------------
+method  uri                         description
+-----------------------------------------------------------------------
+GET     /targets                    list all checked targets
+GET     /targets/{addr}             show checked target {addr}
+GET     /targets/{addr}/history     show recent check results of {addr}
+POST    /targets/{addr}/override    force state of {addr} for a limited time
+DELETE  /targets/{addr}/override    remove the forced state of {addr}
+GET     /methods                    list check methods and default params
+-----------------------------------------------------------------------
+{addr} has the format of L3L4Addr::String(), e.g., 192.168.88.30-TCP-80.
+Body of override POST: {"state":"healthy|unhealthy","ttl":"5m"}
+*/
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/checker"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/types"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/utils"
+)
+
+// StateOverride is the state forced by admin API or checker.SetOverride.
+type StateOverride struct {
+	State   string     `json:"state"`
+	Expires *time.Time `json:"expires,omitempty"` // nil if forced until cleared
+}
+
+// overrideInfo returns the state forced of `target`, and nil if not forced.
+func overrideInfo(target string) *StateOverride {
+	state, expires, ok := checker.GetOverride(target)
+	if !ok {
+		return nil
+	}
+	info := &StateOverride{State: state.String()}
+	if !expires.IsZero() {
+		info.Expires = &expires
+	}
+	return info
+}
+
+// TargetInfo is the checking details of a target exported by admin API.
+type TargetInfo struct {
+	VS         VSID              `json:"vs"`
+	Target     string            `json:"target"`
+	Method     string            `json:"method"`
+	Params     map[string]string `json:"params,omitempty"`
+	State      string            `json:"state"`
+	Since      time.Time         `json:"since"`
+	Count      uint              `json:"count"` // consecutive checks in State
+	LastCheck  *time.Time        `json:"last-check,omitempty"`
+	LastError  string            `json:"last-error,omitempty"`
+	LastResult *CheckResultInfo  `json:"last-result,omitempty"`
+	Stats      TargetStats       `json:"stats"`
+	Override   *StateOverride    `json:"override,omitempty"`
+	Flap       *FlapInfo         `json:"flap,omitempty"`
+	Latency    *LatencyInfo      `json:"latency,omitempty"`
+	History    *HistorySummary   `json:"history,omitempty"`
+}
+
+// FlapInfo is the flap detection status of a target exported by admin API.
+type FlapInfo struct {
+	Transitions int        `json:"transitions"` // noticed state transitions within flap-window
+	Holddown    *time.Time `json:"holddown-until,omitempty"`
+}
+
+// LatencyInfo is the moving average latency of a target exported by admin API.
+type LatencyInfo struct {
+	Average  string `json:"average"`
+	Samples  int    `json:"samples"`
+	Degraded bool   `json:"degraded"`
+}
+
+// HistorySummary is the statistics of recent check results exported by admin API.
+type HistorySummary struct {
+	Records     int        `json:"records"`
+	Healthy     int        `json:"healthy"`
+	Unhealthy   int        `json:"unhealthy"`
+	Unknown     int        `json:"unknown"`
+	Transitions int        `json:"transitions"`     // state changes between consecutive results
+	Since       *time.Time `json:"since,omitempty"` // time of the oldest record
+	AvgLatency  string     `json:"avg-latency,omitempty"`
+}
+
+// CheckRecord is a recent check result exported by admin API.
+type CheckRecord struct {
+	Time    time.Time `json:"time"`
+	State   string    `json:"state"`
+	Reason  string    `json:"reason,omitempty"`
+	Latency string    `json:"latency"`
+}
+
+// TargetHistory is the recent check results of a target exported by admin API.
+type TargetHistory struct {
+	VS      VSID          `json:"vs"`
+	Target  string        `json:"target"`
+	Records []CheckRecord `json:"records"`
+}
+
+// CheckResultInfo is the diagnostics of a check exported by admin API.
+type CheckResultInfo struct {
+	Reason  string `json:"reason"`
+	Latency string `json:"latency"`
+	Detail  string `json:"detail,omitempty"`
+}
+
+type TargetStats struct {
+	Up          uint64 `json:"up"`
+	Down        uint64 `json:"down"`
+	UpNoticed   uint64 `json:"up-noticed"`
+	DownNoticed uint64 `json:"down-noticed"`
+	Timeout     uint64 `json:"timeout"`
+	Error       uint64 `json:"error"`
+}
+
+// MethodInfo is a check method with its default params exported by admin API.
+type MethodInfo struct {
+	Method uint16            `json:"method"`
+	Name   string            `json:"name"`
+	Params map[string]string `json:"default-params,omitempty"`
+	Auto   map[string]string `json:"auto,omitempty"` // protocol -> method, for auto only
+}
+
+// stateOverride is the state forced by admin API, and nil stateOverride
+// removes the forced state.
+type stateOverride struct {
+	state   types.State
+	expires time.Time
+}
+
+type targetEntry struct {
+	info     TargetInfo
+	override chan struct{}
+	history  *checkHistory // nil if disabled
+}
+
+// TargetDB indexes all running checkers for admin API.
+type TargetDB struct {
+	lock    sync.Mutex
+	entries map[string]*targetEntry // keyed by Checker::schedID
+}
+
+var targetDB *TargetDB
+
+func init() {
+	targetDB = NewTargetDB()
+}
+
+func NewTargetDB() *TargetDB {
+	return &TargetDB{
+		entries: make(map[string]*targetEntry),
+	}
+}
+
+// Register adds a checker, which is notified of its forced state through
+// `override`, and whose recent check results are read from `history`.
+func (db *TargetDB) Register(key string, info *TargetInfo, override chan struct{},
+	history *checkHistory) {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	db.entries[key] = &targetEntry{info: *info, override: override, history: history}
+}
+
+func (db *TargetDB) Unregister(key string) {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	delete(db.entries, key)
+}
+
+// Update refreshes the checking details of a registered checker.
+func (db *TargetDB) Update(key string, info *TargetInfo) {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	if entry, ok := db.entries[key]; ok {
+		entry.info = *info
+	}
+}
+
+func (db *TargetDB) list(match func(info *TargetInfo) bool) []TargetInfo {
+	db.lock.Lock()
+	infos := make([]TargetInfo, 0, len(db.entries))
+	for _, entry := range db.entries {
+		if !match(&entry.info) {
+			continue
+		}
+		info := entry.info
+		info.Override = overrideInfo(info.Target)
+		info.History = entry.history.summary()
+		infos = append(infos, info)
+	}
+	db.lock.Unlock()
+
+	sort.Slice(infos, func(i, j int) bool {
+		if infos[i].VS != infos[j].VS {
+			return infos[i].VS < infos[j].VS
+		}
+		return infos[i].Target < infos[j].Target
+	})
+	return infos
+}
+
+// List returns checking details of all targets sorted by VS and target.
+func (db *TargetDB) List() []TargetInfo {
+	return db.list(func(*TargetInfo) bool { return true })
+}
+
+// Get returns checking details of `target` in every VS it belongs to.
+func (db *TargetDB) Get(target *utils.L3L4Addr) []TargetInfo {
+	addr := target.String()
+	return db.list(func(info *TargetInfo) bool { return info.Target == addr })
+}
+
+// History returns recent check results of `target` in every VS it belongs to.
+func (db *TargetDB) History(target *utils.L3L4Addr) []TargetHistory {
+	addr := target.String()
+	db.lock.Lock()
+	entries := make([]*targetEntry, 0, 1)
+	for _, entry := range db.entries {
+		if entry.info.Target == addr {
+			entries = append(entries, entry)
+		}
+	}
+	db.lock.Unlock()
+
+	res := make([]TargetHistory, 0, len(entries))
+	for _, entry := range entries {
+		hist := TargetHistory{
+			VS:      entry.info.VS,
+			Target:  entry.info.Target,
+			Records: entry.history.list(),
+		}
+		if hist.Records == nil {
+			hist.Records = []CheckRecord{}
+		}
+		res = append(res, hist)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].VS < res[j].VS })
+	return res
+}
+
+// Override forces the state of `target` with checker.SetOverrideUntil, or
+// removes it with checker.ClearOverride if `o` is nil, and notifies checkers of
+// `target` to take effect immediately. It returns the number of the checkers,
+// and nothing is changed if none.
+func (db *TargetDB) Override(target *utils.L3L4Addr, o *stateOverride) int {
+	addr := target.String()
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	entries := make([]*targetEntry, 0, 1)
+	for _, entry := range db.entries {
+		if entry.info.Target == addr {
+			entries = append(entries, entry)
+		}
+	}
+	if len(entries) == 0 {
+		return 0
+	}
+
+	if o != nil {
+		checker.SetOverrideUntil(addr, o.state, o.expires)
+	} else {
+		checker.ClearOverride(addr)
+	}
+	for _, entry := range entries {
+		// The checker reads the forced state when notified, so a pending
+		// notification covers this one.
+		select {
+		case entry.override <- struct{}{}:
+		default:
+		}
+	}
+	return len(entries)
+}
+
+type adminServer struct {
+	addr        string
+	allowRemote bool
+	db          *TargetDB
+	server      *http.Server
+}
+
+func NewAdminServer(conf *types.AppConf) *adminServer {
+	s := &adminServer{
+		addr:        conf.AdminAddr,
+		allowRemote: conf.AdminAllowRemote,
+		db:          targetDB,
+	}
+	s.server = &http.Server{
+		Addr:    conf.AdminAddr,
+		Handler: s.handler(),
+	}
+	return s
+}
+
+// isLoopbackAddr reports whether the listen address `addr` accepts only local
+// connections.
+func isLoopbackAddr(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func (s *adminServer) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/targets", s.targetsHandler)
+	mux.HandleFunc("/targets/", s.targetHandler)
+	mux.HandleFunc("/methods", s.methodsHandler)
+	return mux
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(data)
+	w.Write([]byte("\n"))
+}
+
+func writeError(w http.ResponseWriter, code int, format string, args ...interface{}) {
+	writeJSON(w, code, map[string]string{"error": fmt.Sprintf(format, args...)})
+}
+
+func (s *adminServer) targetsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method %s not allowed", r.Method)
+		return
+	}
+	writeJSON(w, http.StatusOK, s.db.List())
+}
+
+func (s *adminServer) targetHandler(w http.ResponseWriter, r *http.Request) {
+	// The target itself may contain a slash, such as "192.168.88.30:80/tcp".
+	addr, action := strings.Trim(strings.TrimPrefix(r.URL.Path, "/targets/"), "/"), ""
+	if i := strings.LastIndexByte(addr, '/'); i >= 0 && utils.ParseIPProto(addr[i+1:]) == 0 {
+		addr, action = addr[:i], addr[i+1:]
+	}
+	if len(action) > 0 && action != "override" && action != "history" {
+		writeError(w, http.StatusNotFound, "invalid uri %s", r.URL.Path)
+		return
+	}
+	target, err := utils.ParseL3L4AddrE(addr)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid target: %v", err)
+		return
+	}
+	if target.Proto == 0 {
+		writeError(w, http.StatusBadRequest, "protocol required in target %q", addr)
+		return
+	}
+
+	if action == "override" {
+		s.overrideHandler(w, r, target)
+		return
+	}
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method %s not allowed", r.Method)
+		return
+	}
+	if action == "history" {
+		hists := s.db.History(target)
+		if len(hists) == 0 {
+			writeError(w, http.StatusNotFound, "target %s not found", target)
+			return
+		}
+		writeJSON(w, http.StatusOK, hists)
+		return
+	}
+	infos := s.db.Get(target)
+	if len(infos) == 0 {
+		writeError(w, http.StatusNotFound, "target %s not found", target)
+		return
+	}
+	writeJSON(w, http.StatusOK, infos)
+}
+
+func (s *adminServer) methodsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method %s not allowed", r.Method)
+		return
+	}
+
+	var infos []MethodInfo
+	for kind, params := range checker.DumpMethodParams() {
+		infos = append(infos, MethodInfo{
+			Method: uint16(kind),
+			Name:   kind.String(),
+			Params: params,
+		})
+	}
+	auto := checker.CheckMethodAuto
+	infos = append(infos, MethodInfo{
+		Method: uint16(auto),
+		Name:   auto.String(),
+		Auto: map[string]string{
+			utils.IPProtoTCP.String(): auto.TranslateAuto(utils.IPProtoTCP).String(),
+			utils.IPProtoUDP.String(): auto.TranslateAuto(utils.IPProtoUDP).String(),
+			"others":                  auto.TranslateAuto(0).String(),
+		},
+	})
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Method < infos[j].Method })
+	writeJSON(w, http.StatusOK, infos)
+}
+
+type overrideRequest struct {
+	State string `json:"state"`
+	TTL   string `json:"ttl"`
+}
+
+func (s *adminServer) overrideHandler(w http.ResponseWriter, r *http.Request, target *utils.L3L4Addr) {
+	if r.Method != http.MethodPost && r.Method != http.MethodDelete {
+		writeError(w, http.StatusMethodNotAllowed, "method %s not allowed", r.Method)
+		return
+	}
+	if !s.allowRemote && !isLoopbackAddr(s.addr) {
+		writeError(w, http.StatusForbidden,
+			"override denied on non-loopback admin address %s without -admin-allow-remote", s.addr)
+		return
+	}
+
+	var o *stateOverride
+	if r.Method == http.MethodPost {
+		var req overrideRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid override request: %v", err)
+			return
+		}
+		o = &stateOverride{}
+		switch strings.ToLower(req.State) {
+		case "healthy":
+			o.state = types.Healthy
+		case "unhealthy":
+			o.state = types.Unhealthy
+		default:
+			writeError(w, http.StatusBadRequest, "invalid override state %q", req.State)
+			return
+		}
+		ttl, err := time.ParseDuration(req.TTL)
+		if err != nil || ttl <= 0 {
+			writeError(w, http.StatusBadRequest, "invalid override ttl %q", req.TTL)
+			return
+		}
+		o.expires = time.Now().Add(ttl)
+	}
+
+	if n := s.db.Override(target, o); n == 0 {
+		writeError(w, http.StatusNotFound, "target %s not found", target)
+		return
+	}
+	if o != nil {
+		glog.Infof("Admin API: %s forced %s until %v by %s", target, o.state,
+			o.expires.Format(time.RFC3339), r.RemoteAddr)
+	} else {
+		glog.Infof("Admin API: %s override removed by %s", target, r.RemoteAddr)
+	}
+	writeJSON(w, http.StatusOK, s.db.Get(target))
+}
+
+func (s *adminServer) Run() {
+	glog.Infof("Starting admin http server listening on %s ...", s.addr)
+	if err := s.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		glog.Errorf("Admin http server started failed: %v", err)
+	}
+	glog.Info("Admin http server finished.")
+}
+
+func (s *adminServer) Shutdown() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := s.server.Shutdown(ctx); err != nil {
+		glog.Warningf("Fail to shutdown admin server: %v.", err)
+	} else {
+		glog.Info("Admin server shutdown succeeded.")
+	}
+}
@@ -0,0 +1,397 @@
+// /*
+// Copyright 2025 IQiYi Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// */
+
+package manager
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/checker"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/comm"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/types"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/utils"
+)
+
+// newTestAdmin creates an admin server and a registered checker not running.
+func newTestAdmin(t *testing.T, addr string, allowRemote bool) (*adminServer, *Checker) {
+	savedDB := targetDB
+	t.Cleanup(func() { targetDB = savedDB })
+	targetDB = NewTargetDB()
+
+	svc := &comm.VirtualServer{
+		Version:   1,
+		Addr:      utils.L3L4Addr{IP: net.ParseIP("192.168.100.1"), Port: 80, Proto: utils.IPProtoTCP},
+		DestCheck: checker.CheckMethodNone,
+	}
+	_, vs := newTestVS(t, svc, &vsConfDefault.QuorumConf)
+	vs.va.m.appConf.AdminAddr = addr
+	vs.va.m.appConf.AdminAllowRemote = allowRemote
+
+	target := utils.L3L4Addr{IP: net.ParseIP("192.168.200.1"), Port: 8080, Proto: utils.IPProtoTCP}
+	ck, err := NewChecker(&target, vs.conf.GetCheckerConf(), vs)
+	if err != nil {
+		t.Fatalf("failed to create checker: %v", err)
+	}
+	targetDB.Register(ck.schedID, ck.targetInfo(), ck.override, ck.history)
+	t.Cleanup(func() { checker.ClearOverride(target.String()) })
+
+	return NewAdminServer(&vs.va.m.appConf), ck
+}
+
+func adminRequest(t *testing.T, s *adminServer, method, uri, body string) (int, []TargetInfo) {
+	req := httptest.NewRequest(method, uri, strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	s.handler().ServeHTTP(rec, req)
+
+	var infos []TargetInfo
+	if rec.Code == http.StatusOK {
+		if err := json.Unmarshal(rec.Body.Bytes(), &infos); err != nil {
+			t.Fatalf("%s %s: invalid response %q: %v", method, uri, rec.Body.String(), err)
+		}
+	}
+	return rec.Code, infos
+}
+
+// recvNotice returns the state notice sent from checker to VS.
+func recvNotice(t *testing.T, ck *Checker) types.State {
+	select {
+	case notice := <-ck.vs.notify:
+		return notice.state
+	default:
+		t.Fatalf("no state notice from checker %s", ck.UUID())
+	}
+	return types.Unknown
+}
+
+func TestAdminTargets(t *testing.T) {
+	s, ck := newTestAdmin(t, "127.0.0.1:8899", false)
+	uri := "/targets/192.168.200.1-TCP-8080"
+
+	ck.doCheckResult(&checkResult{state: types.Healthy, timeout: time.Second})
+	ck.doCheckResult(&checkResult{state: types.Healthy, timeout: time.Second})
+
+	code, infos := adminRequest(t, s, http.MethodGet, "/targets", "")
+	if code != http.StatusOK || len(infos) != 1 {
+		t.Fatalf("list targets: expect 1 target, got %d, %v", code, infos)
+	}
+	info := infos[0]
+	if info.VS != ck.vs.id || info.Target != ck.target.String() || info.Method != "none" ||
+		info.State != "Healthy" || info.Count != 2 || info.LastCheck == nil ||
+		info.Stats.Up != 2 || info.Override != nil {
+		t.Errorf("unexpected target info: %+v", info)
+	}
+
+	ck.doCheckResult(&checkResult{state: types.Unhealthy, timeout: time.Second,
+		result: &checker.CheckResult{State: types.Unhealthy, Reason: checker.ReasonBadStatus,
+			Latency: time.Millisecond, Detail: "unexpected response code 500"}})
+	code, infos = adminRequest(t, s, http.MethodGet, uri, "")
+	if code != http.StatusOK || len(infos) != 1 || infos[0].LastResult == nil ||
+		*infos[0].LastResult != (CheckResultInfo{"bad-status", "1ms", "unexpected response code 500"}) {
+		t.Errorf("get target: unexpected last result %d, %+v", code, infos)
+	}
+
+	ck.doCheckResult(&checkResult{err: errors.New("connection reset"), timeout: time.Second})
+	code, infos = adminRequest(t, s, http.MethodGet, uri, "")
+	if code != http.StatusOK || len(infos) != 1 || infos[0].LastError != "connection reset" ||
+		infos[0].Stats.Error != 1 {
+		t.Errorf("get target: unexpected response %d, %+v", code, infos)
+	}
+	for _, alias := range []string{"/targets/192.168.200.1:8080/tcp", "/targets/192.168.200.1-tcp-8080"} {
+		if code, aliased := adminRequest(t, s, http.MethodGet, alias, ""); code != http.StatusOK ||
+			len(aliased) != 1 || aliased[0].Target != info.Target {
+			t.Errorf("get target %s: unexpected response %d, %+v", alias, code, aliased)
+		}
+	}
+
+	for uri, expect := range map[string]int{
+		"/targets/192.168.200.2-TCP-8080":          http.StatusNotFound,
+		"/targets/192.168.200.1-TCP-8080/unknown":  http.StatusNotFound,
+		"/targets/192.168.200.300-TCP-8080":        http.StatusBadRequest,
+		"/targets/192.168.200.1-XXX-8080":          http.StatusBadRequest,
+		"/targets/192.168.200.1:8080":              http.StatusBadRequest,
+		"/targets/192.168.200.1:8080/udp":          http.StatusNotFound,
+		"/targets/192.168.200.1:8080/tcp/unknown":  http.StatusNotFound,
+		"/targets/192.168.200.1:8080/tcp/override": http.StatusMethodNotAllowed,
+		"/targets/192.168.200.2-TCP-8080/override": http.StatusMethodNotAllowed,
+	} {
+		if code, _ := adminRequest(t, s, http.MethodGet, uri, ""); code != expect {
+			t.Errorf("GET %s: expect status %d, got %d", uri, expect, code)
+		}
+	}
+	if code, _ := adminRequest(t, s, http.MethodPut, "/targets", ""); code != http.StatusMethodNotAllowed {
+		t.Errorf("PUT /targets: expect status %d, got %d", http.StatusMethodNotAllowed, code)
+	}
+}
+
+func TestAdminOverride(t *testing.T) {
+	s, ck := newTestAdmin(t, "127.0.0.1:8899", false)
+	uri := "/targets/192.168.200.1-TCP-8080/override"
+
+	for i := uint(0); i <= ck.conf.UpRetry; i++ {
+		ck.doCheckResult(&checkResult{state: types.Healthy, timeout: time.Second})
+	}
+	if state := recvNotice(t, ck); state != types.Healthy {
+		t.Fatalf("expect %v notice, got %v", types.Healthy, state)
+	}
+
+	for _, body := range []string{
+		``, `{`, `{"state":"down","ttl":"5m"}`, `{"state":"unhealthy"}`, `{"state":"unhealthy","ttl":"-1s"}`,
+	} {
+		if code, _ := adminRequest(t, s, http.MethodPost, uri, body); code != http.StatusBadRequest {
+			t.Errorf("POST %s: expect status %d, got %d", body, http.StatusBadRequest, code)
+		}
+	}
+	if code, _ := adminRequest(t, s, http.MethodPost, "/targets/192.168.200.2-TCP-8080/override",
+		`{"state":"unhealthy","ttl":"5m"}`); code != http.StatusNotFound {
+		t.Errorf("override unknown target: expect status %d, got %d", http.StatusNotFound, code)
+	}
+
+	// force unhealthy, which takes effect immediately and bypasses checks
+	code, infos := adminRequest(t, s, http.MethodPost, uri, `{"state":"Unhealthy","ttl":"5m"}`)
+	if code != http.StatusOK || len(infos) != 1 || infos[0].Override == nil ||
+		infos[0].Override.State != "Unhealthy" || infos[0].Override.Expires == nil {
+		t.Fatalf("override: unexpected response %d, %+v", code, infos)
+	}
+	if state, _, ok := checker.GetOverride(ck.target.String()); !ok || state != types.Unhealthy {
+		t.Errorf("expect override forced by checker.SetOverrideUntil, got %v, %v", state, ok)
+	}
+	<-ck.override
+	ck.doOverride()
+	if state := recvNotice(t, ck); state != types.Unhealthy {
+		t.Errorf("expect forced %v notice, got %v", types.Unhealthy, state)
+	}
+	ck.doCheckResult(&checkResult{state: types.Healthy, timeout: time.Second})
+	if _, infos := adminRequest(t, s, http.MethodGet, "/targets", ""); infos[0].State != "Unhealthy" {
+		t.Errorf("expect check result ignored while forced, got state %s", infos[0].State)
+	}
+
+	// remove the override, and check results take effect again
+	if code, infos := adminRequest(t, s, http.MethodDelete, uri, ""); code != http.StatusOK ||
+		infos[0].Override != nil {
+		t.Errorf("delete override: unexpected response %d, %+v", code, infos)
+	}
+	<-ck.override
+	ck.doOverride()
+	if _, ok := ck.forcedState(); ok {
+		t.Errorf("forced state not removed")
+	}
+	for i := uint(0); i <= ck.conf.UpRetry; i++ {
+		ck.doCheckResult(&checkResult{state: types.Healthy, timeout: time.Second})
+	}
+	if state := recvNotice(t, ck); state != types.Healthy {
+		t.Errorf("expect %v notice after override removed, got %v", types.Healthy, state)
+	}
+}
+
+func TestAdminOverrideExpire(t *testing.T) {
+	s, ck := newTestAdmin(t, "127.0.0.1:8899", false)
+	uri := "/targets/192.168.200.1-TCP-8080/override"
+
+	if code, _ := adminRequest(t, s, http.MethodPost, uri, `{"state":"unhealthy","ttl":"50ms"}`); code != http.StatusOK {
+		t.Fatalf("override: expect status %d, got %d", http.StatusOK, code)
+	}
+	<-ck.override
+	ck.doOverride()
+	if state := recvNotice(t, ck); state != types.Unhealthy {
+		t.Errorf("expect forced %v notice, got %v", types.Unhealthy, state)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if _, infos := adminRequest(t, s, http.MethodGet, "/targets", ""); infos[0].Override != nil {
+		t.Errorf("expired override listed: %+v", infos[0].Override)
+	}
+	if _, ok := ck.forcedState(); ok {
+		t.Errorf("forced state not removed after expired")
+	}
+	for i := uint(0); i <= ck.conf.UpRetry; i++ {
+		ck.doCheckResult(&checkResult{state: types.Healthy, timeout: time.Second})
+	}
+	if state := recvNotice(t, ck); state != types.Healthy {
+		t.Errorf("expect %v notice after override expired, got %v", types.Healthy, state)
+	}
+}
+
+func TestCheckerOverrideRetries(t *testing.T) {
+	s, ck := newTestAdmin(t, "127.0.0.1:8899", false)
+	ck.conf.UpRetry, ck.conf.DownRetry = 1, 2
+
+	check := func() {
+		method := checker.NewOverrideChecker(ck.method)
+		res, err := checker.CheckExTimeout(method, &ck.target, time.Second)
+		ck.doCheckResult(&checkResult{state: res.State, err: err, timeout: time.Second, result: res})
+	}
+	noNotice := func(step string) {
+		t.Helper()
+		select {
+		case notice := <-ck.vs.notify:
+			t.Errorf("%s: unexpected notice %v", step, notice.state)
+		default:
+		}
+	}
+
+	check()
+	noNotice("first probe")
+	check()
+	if state := recvNotice(t, ck); state != types.Healthy {
+		t.Fatalf("expect %v notice after up-retry, got %v", types.Healthy, state)
+	}
+
+	// The forced state takes effect immediately regardless of down-retry.
+	checker.SetOverride(ck.target.String(), types.Unhealthy)
+	check()
+	if state := recvNotice(t, ck); state != types.Unhealthy {
+		t.Fatalf("expect forced %v notice, got %v", types.Unhealthy, state)
+	}
+	check()
+	noNotice("forced again")
+	// The admin API reports the same forced state.
+	if _, infos := adminRequest(t, s, http.MethodGet, "/targets", ""); infos[0].Override == nil ||
+		infos[0].Override.State != "Unhealthy" || infos[0].Override.Expires != nil {
+		t.Errorf("unexpected override listed: %+v", infos[0].Override)
+	}
+	if ck.lastResult == nil || ck.lastResult.Reason != checker.ReasonForced {
+		t.Errorf("unexpected last result: %+v", ck.lastResult)
+	}
+
+	// The probed states are subject to retries after the override is cleared.
+	checker.ClearOverride(ck.target.String())
+	check()
+	noNotice("cleared")
+	check()
+	if state := recvNotice(t, ck); state != types.Healthy {
+		t.Fatalf("expect %v notice after up-retry, got %v", types.Healthy, state)
+	}
+}
+
+func TestAdminOverrideRemote(t *testing.T) {
+	uri := "/targets/192.168.200.1-TCP-8080/override"
+	body := `{"state":"healthy","ttl":"5m"}`
+
+	for _, tc := range []struct {
+		addr        string
+		allowRemote bool
+		expect      int
+	}{
+		{"127.0.0.1:8899", false, http.StatusOK},
+		{"[::1]:8899", false, http.StatusOK},
+		{"localhost:8899", false, http.StatusOK},
+		{":8899", false, http.StatusForbidden},
+		{"0.0.0.0:8899", false, http.StatusForbidden},
+		{"192.168.100.1:8899", false, http.StatusForbidden},
+		{"192.168.100.1:8899", true, http.StatusOK},
+	} {
+		s, _ := newTestAdmin(t, tc.addr, tc.allowRemote)
+		if code, _ := adminRequest(t, s, http.MethodPost, uri, body); code != tc.expect {
+			t.Errorf("override on %s (allow-remote %v): expect status %d, got %d",
+				tc.addr, tc.allowRemote, tc.expect, code)
+		}
+		if code, _ := adminRequest(t, s, http.MethodGet, "/targets", ""); code != http.StatusOK {
+			t.Errorf("list targets on %s: expect status %d, got %d", tc.addr, http.StatusOK, code)
+		}
+	}
+}
+
+func TestAdminMethods(t *testing.T) {
+	s, _ := newTestAdmin(t, "127.0.0.1:8899", false)
+
+	req := httptest.NewRequest(http.MethodGet, "/methods", nil)
+	rec := httptest.NewRecorder()
+	s.handler().ServeHTTP(rec, req)
+	var infos []MethodInfo
+	if err := json.Unmarshal(rec.Body.Bytes(), &infos); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("list methods: unexpected response %d, %q", rec.Code, rec.Body.String())
+	}
+
+	found := make(map[string]MethodInfo)
+	for _, info := range infos {
+		found[info.Name] = info
+	}
+	if info, ok := found["http"]; !ok || info.Method != uint16(checker.CheckMethodHTTP) ||
+		info.Params["uri"] != "/" || info.Params["method"] != "GET" {
+		t.Errorf("unexpected http method info: %+v", info)
+	}
+	if ping, ok := found["ping"]; !ok || ping.Params[checker.ParamPrivileged] != checker.PingPrivilegedAuto {
+		t.Errorf("unexpected ping method info: %+v", ping)
+	}
+	if auto, ok := found["auto"]; !ok || auto.Auto["TCP"] != "tcp" || auto.Auto["UDP"] != "udpping" ||
+		auto.Auto["others"] != "ping" {
+		t.Errorf("unexpected auto method info: %+v", auto)
+	}
+
+	if code, _ := adminRequest(t, s, http.MethodPost, "/methods", ""); code != http.StatusMethodNotAllowed {
+		t.Errorf("POST /methods: expect status %d, got %d", http.StatusMethodNotAllowed, code)
+	}
+}
+
+func TestAdminHistory(t *testing.T) {
+	s, ck := newTestAdmin(t, "127.0.0.1:8899", false)
+	uri := "/targets/192.168.200.1-TCP-8080/history"
+
+	ck.doCheckResult(&checkResult{state: types.Healthy, timeout: time.Second,
+		result: &checker.CheckResult{State: types.Healthy, Latency: time.Millisecond}})
+	ck.doCheckResult(&checkResult{state: types.Unhealthy, timeout: time.Second,
+		result: &checker.CheckResult{State: types.Unhealthy, Reason: checker.ReasonConnRefused,
+			Latency: 2 * time.Millisecond}})
+	ck.doCheckResult(&checkResult{err: errors.New("connection reset"), timeout: time.Second})
+
+	req := httptest.NewRequest(http.MethodGet, uri, nil)
+	rec := httptest.NewRecorder()
+	s.handler().ServeHTTP(rec, req)
+	var hists []TargetHistory
+	if err := json.Unmarshal(rec.Body.Bytes(), &hists); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("get history: unexpected response %d, %q", rec.Code, rec.Body.String())
+	}
+	if len(hists) != 1 || hists[0].VS != ck.vs.id || hists[0].Target != ck.target.String() ||
+		len(hists[0].Records) != 3 {
+		t.Fatalf("get history: unexpected history %+v", hists)
+	}
+	for i, expect := range []CheckRecord{
+		{State: "Healthy", Latency: "1ms"},
+		{State: "Unhealthy", Reason: "conn-refused", Latency: "2ms"},
+		{State: "Unknown", Latency: "0s"},
+	} {
+		got := hists[0].Records[i]
+		if got.State != expect.State || got.Reason != expect.Reason || got.Latency != expect.Latency {
+			t.Errorf("record %d: expect %+v, got %+v", i, expect, got)
+		}
+	}
+
+	// The history summary is included in target details.
+	_, infos := adminRequest(t, s, http.MethodGet, "/targets", "")
+	if len(infos) != 1 || infos[0].History == nil || infos[0].History.Records != 3 ||
+		infos[0].History.Transitions != 1 || infos[0].History.Unknown != 1 {
+		t.Errorf("unexpected history summary in target info: %+v", infos)
+	}
+
+	for uri, expect := range map[string]int{
+		"/targets/192.168.200.2-TCP-8080/history": http.StatusNotFound,
+		"/targets/192.168.200.1:8080/tcp/history": http.StatusOK,
+	} {
+		if code, _ := adminRequest(t, s, http.MethodGet, uri, ""); code != expect {
+			t.Errorf("GET %s: expect status %d, got %d", uri, expect, code)
+		}
+	}
+	if code, _ := adminRequest(t, s, http.MethodPost, uri, ""); code != http.StatusMethodNotAllowed {
+		t.Errorf("POST %s: expect status %d, got %d", uri, http.StatusMethodNotAllowed, code)
+	}
+}
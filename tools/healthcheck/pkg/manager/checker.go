@@ -17,11 +17,15 @@
 package manager
 
 import (
+	"context"
 	"fmt"
+	"os"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/golang/glog"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/actioner"
 	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/checker"
 	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/types"
 	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/utils"
@@ -29,6 +33,14 @@ import (
 
 var CheckerThreads, HealthCheckThreads ThreadStats
 
+// checkLogLimiter bounds the check failure logs when targets fail massively.
+var checkLogLimiter = utils.NewLogLimiter(utils.DefaultLogBurst, utils.DefaultLogPeriod)
+
+// eventLog logs the check failures, state transitions and action outcomes,
+// which is replaced in NewManager according to the app config.
+var eventLog = utils.NewEventLogger(utils.LogFormatText, utils.DefaultLogThrottleInterval,
+	os.Stdout, checkLogLimiter)
+
 // CheckerID represents VS-scoped Checker ID.
 // It has the format of L3L4Addr::String().
 type CheckerID string
@@ -42,6 +54,7 @@ type Checker struct {
 	id     CheckerID
 	target utils.L3L4Addr
 	conf   CheckerConf
+	timing checker.Timing // resolved from conf
 
 	// status members
 	state types.State
@@ -49,9 +62,22 @@ type Checker struct {
 	since time.Time
 	stats Statistics // downFailed: check error; upFailed: check timeout
 
-	method      checker.CheckMethod
-	checkTicker *time.Ticker
-	vs          *VirtualService // Restrictions: only access to its read-only/thread-safe members
+	// admin members
+	lastCheck  time.Time
+	lastErr    error
+	lastResult *checker.CheckResult // diagnostics of the last check
+	history    *checkHistory        // recent check results, nil if disabled
+
+	// flap members
+	flap      *flapDetector
+	flapTimer *time.Timer // fires at the end of hold-down
+
+	latency *latencyTracker // moving average latency of successful probes
+
+	method    checker.CheckMethod
+	scheduled bool
+	schedID   string          // unique even if a checker of the same UUID is recreated
+	vs        *VirtualService // Restrictions: only access to its read-only/thread-safe members
 
 	// metric members
 	metricTaint  bool
@@ -59,8 +85,18 @@ type Checker struct {
 	metric       chan<- Metric
 
 	// thread-safe members
-	update chan CheckerConf
-	quit   chan bool
+	update   chan CheckerConf
+	result   chan checkResult
+	override chan struct{} // state forced by admin API, see doOverride
+	quit     chan bool
+}
+
+type checkResult struct {
+	state   types.State
+	err     error
+	elapsed time.Duration
+	timeout time.Duration
+	result  *checker.CheckResult
 }
 
 func NewChecker(target *utils.L3L4Addr, conf *CheckerConf, vs *VirtualService) (*Checker, error) {
@@ -72,7 +108,8 @@ func NewChecker(target *utils.L3L4Addr, conf *CheckerConf, vs *VirtualService) (
 	ckid := CheckerID(target.String())
 	confCopied := conf.DeepCopy()
 
-	method, err := checker.NewChecker(confCopied.Method, target, confCopied.MethodParams)
+	method, timing, err := checker.NewTimedChecker(confCopied.Method, target, &vs.subject,
+		confCopied.MethodParams, &confCopied.Timing)
 	if err != nil {
 		return nil, fmt.Errorf("fail to create checker method %v: %v", confCopied.Method, err)
 	}
@@ -81,20 +118,37 @@ func NewChecker(target *utils.L3L4Addr, conf *CheckerConf, vs *VirtualService) (
 		id:     ckid,
 		target: *target,
 		conf:   *confCopied,
+		timing: timing,
 
 		state: types.Unknown,
 		since: time.Now(),
 
-		method:      method,
-		checkTicker: nil, // init it in func `Run`
-		vs:          vs,
+		history: newCheckHistory(vs.va.m.appConf.CheckHistory),
+		flap:    newFlapDetector(&confCopied.FlapConf),
+		latency: newLatencyTracker(&confCopied.LatencyConf),
+
+		method:    method,
+		scheduled: false, // schedule it in func `Run`
+		vs:        vs,
 
 		metricTaint:  true,
 		metricTicker: nil, // init it in func `Run`
 		metric:       vs.metric,
 
-		update: make(chan CheckerConf, 1),
-		quit:   make(chan bool, 1),
+		update:   make(chan CheckerConf, 1),
+		result:   make(chan checkResult, 1),
+		override: make(chan struct{}, 1),
+		quit:     make(chan bool, 1),
+	}
+
+	checker.schedID = fmt.Sprintf("%s@%p", checker.UUID(), checker)
+
+	if seed, ok := stateDB.Consume(checker.UUID()); ok {
+		glog.Infof("Checker %s restored state %v (count %d) since %v", checker.UUID(),
+			seed.State, seed.Count, seed.Since.Format(time.RFC3339))
+		checker.state = seed.State
+		checker.since = seed.Since
+		checker.count = seed.Count
 	}
 
 	return checker, nil
@@ -105,14 +159,198 @@ func (c *Checker) UUID() string {
 	return fmt.Sprintf("%s/%s", c.vs.id, c.id)
 }
 
+// noticedState returns the state that has been confirmed with retries, i.e.,
+// the state noticed to VS, or types.Unknown if the state is not confirmed yet.
+func (c *Checker) noticedState() types.State {
+	switch c.state {
+	case types.Healthy:
+		if c.count > c.conf.UpRetry {
+			return types.Healthy
+		}
+	case types.Unhealthy:
+		if c.count > c.conf.DownRetry {
+			return types.Unhealthy
+		}
+	}
+	return types.Unknown
+}
+
+// assume takes `state` as if it has been confirmed with retries, unless a state
+// has been restored. It must be called before the checker runs.
+func (c *Checker) assume(state types.State) {
+	if c.state != types.Unknown {
+		return
+	}
+	c.state = state
+	c.since = time.Now()
+	if state == types.Healthy {
+		c.count = c.conf.UpRetry + 1
+	} else {
+		c.count = c.conf.DownRetry + 1
+	}
+}
+
+func (c *Checker) persistState() {
+	if len(c.vs.va.m.appConf.StateFile) == 0 {
+		return
+	}
+	stateDB.Update(&TargetState{
+		VS:      c.vs.id,
+		Target:  c.target,
+		State:   c.state,
+		Since:   c.since,
+		Count:   c.count,
+		Updated: time.Now(),
+	})
+}
+
+func (c *Checker) adminEnabled() bool {
+	return len(c.vs.va.m.appConf.AdminAddr) > 0
+}
+
+func (c *Checker) targetInfo() *TargetInfo {
+	info := &TargetInfo{
+		VS:     c.vs.id,
+		Target: c.target.String(),
+		Method: c.conf.Method.String(),
+		State:  c.state.String(),
+		Since:  c.since,
+		Count:  c.count,
+		Stats: TargetStats{
+			Up:          c.stats.up,
+			Down:        c.stats.down,
+			UpNoticed:   c.stats.upNoticed,
+			DownNoticed: c.stats.downNoticed,
+			Timeout:     c.stats.upFailed,
+			Error:       c.stats.downFailed,
+		},
+	}
+	if len(c.conf.MethodParams) > 0 {
+		info.Params = make(map[string]string, len(c.conf.MethodParams))
+		for k, v := range c.conf.MethodParams {
+			info.Params[k] = v
+		}
+	}
+	if !c.lastCheck.IsZero() {
+		lastCheck := c.lastCheck
+		info.LastCheck = &lastCheck
+	}
+	if c.lastErr != nil {
+		info.LastError = c.lastErr.Error()
+	}
+	if c.lastResult != nil {
+		info.LastResult = &CheckResultInfo{
+			Reason:  c.lastResult.Reason.String(),
+			Latency: c.lastResult.Latency.String(),
+			Detail:  c.lastResult.Detail,
+		}
+	}
+	info.Override = overrideInfo(info.Target)
+	if c.latency.enabled() {
+		info.Latency = &LatencyInfo{
+			Average:  c.latency.average().String(),
+			Samples:  len(c.latency.samples),
+			Degraded: c.latency.degraded,
+		}
+	}
+	if c.flap.enabled() {
+		info.Flap = &FlapInfo{Transitions: c.flap.count(time.Now())}
+		if c.flapTimer != nil {
+			until := c.flap.until
+			info.Flap.Holddown = &until
+		}
+	}
+	return info
+}
+
+func (c *Checker) reportTarget() {
+	if !c.adminEnabled() {
+		return
+	}
+	targetDB.Update(c.schedID, c.targetInfo())
+}
+
+// forcedState returns the state forced for the target, see checker.SetOverride.
+func (c *Checker) forcedState() (types.State, bool) {
+	state, _, ok := checker.GetOverride(c.target.String())
+	return state, ok
+}
+
+// doOverride makes the state forced for the target take effect immediately
+// regardless of retries, rather than on the next check. The check results are
+// ignored while forced, and take effect again once the forced state is removed
+// or expired.
+func (c *Checker) doOverride() {
+	defer c.reportTarget()
+
+	state, ok := c.forcedState()
+	if !ok {
+		glog.Infof("Checker %s forced state removed", c.UUID())
+		return
+	}
+	glog.Infof("Checker %s forced to %v", c.UUID(), state)
+
+	if state != c.state {
+		c.state = state
+		c.since = time.Now()
+		c.updateInterval()
+	}
+	// Take effect immediately regardless of retries.
+	if state == types.Healthy {
+		c.count = c.conf.UpRetry + 1
+	} else {
+		c.count = c.conf.DownRetry + 1
+	}
+	c.metricTaint = true
+	c.sendNotice()
+}
+
+// actionDetail describes the current state of the checker for actioners.
+func (c *Checker) actionDetail() *actioner.ActionDetail {
+	detail := &actioner.ActionDetail{
+		Target: c.target.DeepCopy(),
+		Method: c.conf.Method.String(),
+		Time:   time.Now(),
+	}
+	_, forced := c.forcedState()
+	switch {
+	case forced:
+		detail.Message = fmt.Sprintf("%v forced by override", c.state)
+	case c.flapTimer != nil:
+		detail.Message = fmt.Sprintf("%v held down for flapping", c.state)
+	case c.lastResult != nil && c.lastResult.Reason != checker.ReasonNone:
+		detail.Message = fmt.Sprintf("%v, reason: %v, %s", c.state, c.lastResult.Reason,
+			c.lastResult.Detail)
+	default:
+		detail.Message = c.state.String()
+	}
+	return detail
+}
+
 func (c *Checker) sendNotice() {
-	glog.V(5).Infof("Checker %v sending %v notice to VS", c.UUID(), c.state)
+	if c.state == types.Unhealthy && c.lastResult != nil {
+		glog.V(5).Infof("Checker %v sending %v notice to VS, reason: %v, %s", c.UUID(), c.state,
+			c.lastResult.Reason, c.lastResult.Detail)
+	} else {
+		glog.V(5).Infof("Checker %v sending %v notice to VS", c.UUID(), c.state)
+	}
 	if c.state == types.Unknown {
 		return
 	}
+	ev := c.logEvent(utils.LogEventTransition, utils.LogLevelInfo)
+	ev.Message = fmt.Sprintf("Checker %s state %v noticed", c.UUID(), c.state)
+	if c.state == types.Unhealthy && c.lastResult != nil {
+		ev.Reason = c.lastResult.Reason.String()
+		ev.SetLatency(c.lastResult.Latency)
+		ev.Error = c.lastResult.Detail
+		ev.Message += fmt.Sprintf(", reason: %v, %s", c.lastResult.Reason, c.lastResult.Detail)
+	}
+	eventLog.LogTransition(ev)
 	c.vs.notify <- BackendState{
-		id:    c.id,
-		state: c.state,
+		id:       c.id,
+		state:    c.state,
+		degraded: c.latency.degraded,
+		detail:   c.actionDetail(),
 	}
 	if c.state == types.Unhealthy {
 		c.stats.downNoticed++
@@ -122,25 +360,116 @@ func (c *Checker) sendNotice() {
 	c.metricTaint = true
 }
 
+// noticeDegraded notices VS that the Healthy backend turns degraded or recovers
+// from it, so that its weight is adjusted. The state is unchanged.
+func (c *Checker) noticeDegraded() {
+	ev := c.logEvent(utils.LogEventTransition, utils.LogLevelInfo)
+	ev.SetLatency(c.latency.average())
+	if c.latency.degraded {
+		ev.Message = fmt.Sprintf("Checker %s degraded with average latency %v over degraded-latency %v",
+			c.UUID(), c.latency.average(), c.conf.DegradedLatency)
+	} else {
+		ev.Message = fmt.Sprintf("Checker %s recovered from degraded with average latency %v",
+			c.UUID(), c.latency.average())
+	}
+	eventLog.LogTransition(ev)
+	c.vs.notify <- BackendState{
+		id:       c.id,
+		state:    c.state,
+		degraded: c.latency.degraded,
+		detail:   c.actionDetail(),
+	}
+	c.metricTaint = true
+}
+
+// checkLatency judges the Healthy check result `res` by the moving average
+// latency of the successful probes. The result turns Unhealthy if the backend
+// is slower than max-latency, and VS is noticed if the backend turns degraded
+// or recovers from it.
+func (c *Checker) checkLatency(res *checkResult) {
+	if !c.latency.enabled() || res.result == nil || res.result.Forced {
+		return
+	}
+	degraded := c.latency.degraded
+	if c.latency.add(res.result.Latency) {
+		slow := *res.result
+		slow.State = types.Unhealthy
+		slow.Reason = checker.ReasonLatency
+		slow.Detail = fmt.Sprintf("average latency %v of last %d probes exceeds max-latency %v",
+			c.latency.average(), len(c.latency.samples), c.conf.MaxLatency)
+		res.state = types.Unhealthy
+		res.result = &slow
+	}
+	c.metricTaint = true
+	if _, forced := c.forcedState(); c.latency.degraded != degraded &&
+		c.noticedState() == types.Healthy && !forced && c.flapTimer == nil {
+		c.noticeDegraded()
+	}
+}
+
+// noticeFlap feeds the state to be noticed to the flap detector, and holds down
+// the checker if it starts flapping. It must be called before sendNotice, so
+// that only the state determined by the flap policy is noticed.
+func (c *Checker) noticeFlap() {
+	now := time.Now()
+	if !c.flap.notice(c.state, now) {
+		return
+	}
+
+	holddown := c.flap.until.Sub(now)
+	checkLogLimiter.Warningf("checkers flapping", "Checker %s flapping with more than %d transitions "+
+		"in %v, hold down for %v with policy %s", c.UUID(), c.conf.FlapThreshold, c.conf.FlapWindow,
+		holddown, c.conf.FlapPolicy)
+	c.flapTimer = time.NewTimer(holddown)
+	c.metricTaint = true
+
+	if c.conf.FlapPolicy == FlapPolicyUnhealthy && c.state != types.Unhealthy {
+		c.state = types.Unhealthy
+		c.since = now
+		c.count = c.conf.DownRetry + 1
+		c.updateInterval()
+		c.flap.last = c.state
+		c.persistState()
+	}
+}
+
+// doFlapRelease ends the hold-down of a flapping checker, and the check results
+// take effect again.
+func (c *Checker) doFlapRelease() {
+	defer c.reportTarget()
+
+	if c.flapTimer != nil {
+		c.flapTimer.Stop()
+		c.flapTimer = nil
+	}
+	c.flap.release()
+	c.metricTaint = true
+	glog.Infof("Checker %s flapping hold-down ended in state %v", c.UUID(), c.state)
+}
+
 func (c *Checker) doPostCheck(newState types.State) {
 	if newState != c.state {
 		c.state = newState
 		c.since = time.Now()
 		c.count = 0
+		c.updateInterval()
 	}
 	c.count++
+	c.persistState()
 
 	switch newState {
 	case types.Healthy:
 		c.stats.up++
 		c.metricTaint = true
 		if c.count == c.conf.UpRetry+1 {
+			c.noticeFlap()
 			c.sendNotice()
 		}
 	case types.Unhealthy:
 		c.stats.down++
 		c.metricTaint = true
 		if c.count == c.conf.DownRetry+1 {
+			c.noticeFlap()
 			c.sendNotice()
 		}
 	}
@@ -152,12 +481,17 @@ func (c *Checker) doUpdate(conf *CheckerConf) {
 	}
 
 	skip := false
+	reschedule := false
 
-	if conf.Interval != c.conf.Interval {
-		glog.Infof("Updating Interval of checker %s: %v->%v", c.UUID(), c.conf.Interval, conf.Interval)
-		c.checkTicker.Stop()
-		c.checkTicker = time.NewTicker(conf.Interval)
+	if conf.Interval != c.conf.Interval || conf.DownRetryInterval != c.conf.DownRetryInterval {
+		glog.Infof("Updating Interval/DownRetryInterval of checker %s: %v/%v->%v/%v", c.UUID(),
+			c.conf.Interval, c.conf.DownRetryInterval, conf.Interval, conf.DownRetryInterval)
 		c.conf.Interval = conf.Interval
+		c.conf.DownRetryInterval = conf.DownRetryInterval
+		c.timing = c.conf.Timing.Resolved()
+		if err := c.vs.va.m.scheduler.Update(c.schedID, c.interval()); err != nil {
+			reschedule = true
+		}
 	}
 	if conf.DownRetry != c.conf.DownRetry {
 		glog.Infof("Updating DownRetry of checker %s: %v->%v", c.UUID(), c.conf.DownRetry, conf.DownRetry)
@@ -173,61 +507,232 @@ func (c *Checker) doUpdate(conf *CheckerConf) {
 			c.sendNotice()
 		}
 	}
+	if conf.FlapConf != c.conf.FlapConf {
+		glog.Infof("Updating FlapConf of checker %s: %+v->%+v", c.UUID(), c.conf.FlapConf, conf.FlapConf)
+		c.conf.FlapConf = conf.FlapConf
+		c.flap.setConf(&conf.FlapConf)
+		if !c.flap.enabled() && c.flapTimer != nil {
+			c.doFlapRelease()
+		}
+	}
+	if conf.LatencyConf != c.conf.LatencyConf {
+		glog.Infof("Updating LatencyConf of checker %s: %+v->%+v", c.UUID(), c.conf.LatencyConf, conf.LatencyConf)
+		c.conf.LatencyConf = conf.LatencyConf
+		degraded := c.latency.degraded
+		c.latency.setConf(&conf.LatencyConf)
+		if degraded && c.noticedState() == types.Healthy {
+			c.noticeDegraded()
+		}
+	}
 	if conf.Timeout != c.conf.Timeout {
 		glog.Infof("Updating Timeout of checker %s: %v->%v", c.UUID(), c.conf.Timeout, conf.Timeout)
 		c.conf.Timeout = conf.Timeout
+		c.timing = c.conf.Timing.Resolved()
+		reschedule = true
 	}
 	if !conf.DeepEqual(&c.conf) { // method or its params changed
 		glog.Infof("Updating Method of checker %s: %v(%v)->%v(%v)", c.UUID(), c.conf.Method,
 			c.conf.MethodParams, conf.Method, conf.MethodParams)
-		method, err := checker.NewChecker(conf.Method, &c.target, conf.MethodParams)
+		method, err := checker.NewServiceChecker(conf.Method, &c.target, &c.vs.subject, conf.MethodParams)
 		if err != nil {
 			glog.Errorf("fail to update checker method %v-%v: %v",
 				c.conf.Method, conf.Method, err)
 			skip = true
 		} else {
 			c.method = method
+			reschedule = true
 		}
 	}
 
+	if reschedule {
+		c.schedule()
+	}
+
 	if !skip {
 		glog.V(5).Infof("CheckerConf for %s updated successfully", c.UUID())
 		c.conf = *conf
 	} else {
 		glog.Warningf("CheckerConf for %s partially updated", c.UUID())
 	}
+	c.reportTarget()
 }
 
-func (c *Checker) doCheck() {
-	glog.V(9).Infof("Checking %s ...", c.UUID())
-	ch := make(chan types.State)
-
-	go func() {
-		// TODO: Determine a way to ensure that this go routine does not linger.
-		HealthCheckThreads.RunningInc()
-		if state, err := c.method.Check(&c.target, c.conf.Timeout); err != nil {
-			glog.Warningf("Checker %s executes healthcheck failed: %v", c.UUID(), err)
-			ch <- types.Unknown
-		} else {
-			ch <- state
+// schedule registers the check job of the checker to the scheduler, replacing
+// the previous one if any. The job captures the current method and timeout, so
+// it must be called again whenever any of them changes.
+func (c *Checker) schedule() {
+	uuid := c.UUID()
+	method, target, timeout := c.method, c.target, c.timing.Timeout
+	// Consult the states forced for maintenance, see checker.SetOverride.
+	method = checker.NewOverrideChecker(method)
+	result := c.result
+
+	job := func(ctx context.Context) {
+		glog.V(9).Infof("Checking %s ...", uuid)
+		start := time.Now()
+		checkCtx, cancel := context.WithTimeout(ctx, timeout)
+		checked, err := checker.CheckEx(checkCtx, method, &target)
+		cancel()
+		if ctx.Err() != nil {
+			// The job is canceled, and the result is meaningless.
+			return
 		}
-		HealthCheckThreads.RunningDec()
-		HealthCheckThreads.FinishedInc()
-	}()
-
-	select {
-	case state := <-ch:
-		if state != types.Unknown {
-			c.doPostCheck(state)
-		} else {
-			c.stats.downFailed++
-			c.metricTaint = true
+		res := checkResult{
+			state:   checked.State,
+			err:     err,
+			elapsed: time.Since(start),
+			timeout: timeout,
+			result:  checked,
 		}
-	case <-time.After(c.conf.Timeout + time.Second):
+		select {
+		case result <- res:
+		case <-ctx.Done():
+		}
+	}
+
+	if err := c.vs.va.m.scheduler.Add(c.schedID, c.interval(), job); err != nil {
+		glog.Errorf("Checker %s scheduled failed: %v", uuid, err)
+		return
+	}
+	c.scheduled = true
+}
+
+// interval returns the current check interval of the checker, which is
+// shortened to the down-retry-interval while the target is Unhealthy.
+func (c *Checker) interval() time.Duration {
+	if c.state == types.Unhealthy {
+		return c.timing.DownRetryInterval
+	}
+	return c.timing.Interval
+}
+
+// updateInterval applies the current check interval to the scheduler. It must
+// be called whenever the state changes from or to Unhealthy.
+func (c *Checker) updateInterval() {
+	if !c.scheduled || c.timing.DownRetryInterval == c.timing.Interval {
+		return
+	}
+	if err := c.vs.va.m.scheduler.Update(c.schedID, c.interval()); err != nil {
+		glog.Warningf("Checker %s updates check interval failed: %v", c.UUID(), err)
+	}
+}
+
+func (c *Checker) doCheckResult(res *checkResult) {
+	defer c.reportTarget()
+
+	if res.err == nil && res.state == types.Healthy && res.elapsed <= res.timeout+time.Second {
+		c.checkLatency(res)
+	}
+	c.lastCheck = time.Now()
+	c.lastErr = res.err
+	c.lastResult = res.result
+	c.recordHistory(res)
+	if res.elapsed > res.timeout+time.Second {
+		c.lastErr = fmt.Errorf("check timeout after %v", res.elapsed)
 		c.stats.upFailed++
 		c.metricTaint = true
-		glog.Warningf("Checker %s executes healthcheck timeout", c.UUID())
+		ev := c.logEvent(utils.LogEventCheck, utils.LogLevelWarning)
+		ev.State = types.Unknown.String()
+		ev.Reason = checker.ReasonTimeout.String()
+		ev.SetLatency(res.elapsed)
+		ev.Error = c.lastErr.Error()
+		ev.Message = fmt.Sprintf("Checker %s executes healthcheck timeout", c.UUID())
+		eventLog.LogFailure(c.logKey("checks timeout"), ev)
+		return
+	}
+	// Only the forced state checked by checker.OverrideChecker counts while
+	// forced, and the stale results probed before are ignored.
+	if state, ok := c.forcedState(); ok &&
+		(res.result == nil || !res.result.Forced || res.state != state) {
+		glog.V(9).Infof("Checker %s check result %v ignored, state forced to %v",
+			c.UUID(), res.state, state)
+		return
+	}
+	if c.flapTimer != nil {
+		glog.V(9).Infof("Checker %s check result %v ignored, flapping held down until %v",
+			c.UUID(), res.state, c.flap.until.Format(time.RFC3339))
+		return
+	}
+	if res.err != nil {
+		ev := c.logEvent(utils.LogEventCheck, utils.LogLevelWarning)
+		ev.State = types.Unknown.String()
+		ev.Reason = checker.ReasonUnknown.String()
+		if res.result != nil {
+			ev.Reason = res.result.Reason.String()
+		}
+		ev.SetLatency(res.elapsed)
+		ev.Error = res.err.Error()
+		ev.Message = fmt.Sprintf("Checker %s executes healthcheck failed: %v", c.UUID(), res.err)
+		eventLog.LogFailure(c.logKey("checks failed"), ev)
+		res.state = types.Unknown
+	} else if res.state == types.Unhealthy && res.result != nil {
+		ev := c.logEvent(utils.LogEventCheck, utils.LogLevelInfo)
+		ev.State = res.state.String()
+		ev.Reason = res.result.Reason.String()
+		ev.SetLatency(res.result.Latency)
+		ev.Error = res.result.Detail
+		ev.Message = fmt.Sprintf("Checker %s check %v, reason: %v, %s", c.UUID(), res.state,
+			res.result.Reason, res.result.Detail)
+		eventLog.LogFailure("", ev)
+	}
+	if res.state != types.Unknown {
+		if res.result != nil && res.result.Forced {
+			c.skipRetries(res.state)
+		}
+		c.doPostCheck(res.state)
+	} else {
+		c.stats.downFailed++
+		c.metricTaint = true
+	}
+}
+
+// skipRetries makes the forced `state` checked by checker.OverrideChecker take
+// effect in the following doPostCheck regardless of retries. The retries apply
+// again to the probed states after the override is cleared.
+func (c *Checker) skipRetries(state types.State) {
+	retry := c.conf.DownRetry
+	if state == types.Healthy {
+		retry = c.conf.UpRetry
 	}
+	if state != c.state {
+		c.state = state
+		c.since = time.Now()
+		c.count = 0
+		c.updateInterval()
+	}
+	if c.count < retry {
+		c.count = retry
+	}
+}
+
+// recordHistory adds the check result to the history of the checker.
+func (c *Checker) recordHistory(res *checkResult) {
+	state, reason, latency := res.state, checker.ReasonNone, res.elapsed
+	if res.result != nil {
+		reason, latency = res.result.Reason, res.result.Latency
+	}
+	if res.err != nil || res.elapsed > res.timeout+time.Second {
+		state = types.Unknown
+	}
+	c.history.add(c.lastCheck, state, reason, latency)
+}
+
+// logEvent returns a LogEvent of the checker in its current state.
+func (c *Checker) logEvent(event, level string) *utils.LogEvent {
+	return &utils.LogEvent{
+		Level:  level,
+		Event:  event,
+		Target: string(c.id),
+		VIP:    string(c.vs.id),
+		Method: c.conf.Method.String(),
+		State:  c.state.String(),
+	}
+}
+
+// logKey returns the key of checkLogLimiter for the method of c, such as
+// "UDP checks failed".
+func (c *Checker) logKey(what string) string {
+	return fmt.Sprintf("%s %s", strings.ToUpper(c.conf.Method.String()), what)
 }
 
 func (c *Checker) doMetricSend() {
@@ -246,6 +751,18 @@ func (c *Checker) doMetricSend() {
 		},
 		stats: c.stats,
 	}
+	if c.state == types.Unhealthy && c.lastResult != nil && c.lastResult.Reason != checker.ReasonNone {
+		metric.extras = []string{"reason=" + c.lastResult.Reason.String()}
+	}
+	if c.flapTimer != nil {
+		metric.extras = append(metric.extras, "flapping")
+	}
+	if c.latency.enabled() && len(c.latency.samples) > 0 {
+		metric.extras = append(metric.extras, "latency="+c.latency.average().String())
+		if c.latency.degraded {
+			metric.extras = append(metric.extras, "degraded")
+		}
+	}
 	c.metric <- metric
 
 	c.metricTaint = false
@@ -283,16 +800,23 @@ func (c *Checker) Run(wg *sync.WaitGroup, start <-chan time.Time) {
 		<-start
 	}
 
-	if c.checkTicker == nil {
-		c.checkTicker = time.NewTicker(c.conf.Interval)
+	if !c.scheduled {
+		c.schedule()
 	}
 	if c.metricTicker == nil {
 		c.metricTicker = time.NewTicker(c.vs.va.m.appConf.MetricDelay)
 	}
+	if c.adminEnabled() {
+		targetDB.Register(c.schedID, c.targetInfo(), c.override, c.history)
+	}
 
 	glog.V(5).Infof("Checker %v loop started\n", uuid)
 
 	for {
+		var flapC <-chan time.Time
+		if c.flapTimer != nil {
+			flapC = c.flapTimer.C
+		}
 		select {
 		case <-c.quit:
 			CheckerThreads.RunningDec()
@@ -301,8 +825,12 @@ func (c *Checker) Run(wg *sync.WaitGroup, start <-chan time.Time) {
 			return
 		case conf := <-c.update:
 			c.doUpdate(&conf)
-		case <-c.checkTicker.C:
-			c.doCheck()
+		case res := <-c.result:
+			c.doCheckResult(&res)
+		case <-c.override:
+			c.doOverride()
+		case <-flapC:
+			c.doFlapRelease()
 		case <-c.metricTicker.C:
 			c.doMetricSend()
 		}
@@ -310,8 +838,15 @@ func (c *Checker) Run(wg *sync.WaitGroup, start <-chan time.Time) {
 }
 
 func (c *Checker) cleanup() {
-	if c.checkTicker != nil {
-		c.checkTicker.Stop()
+	if c.scheduled {
+		// Cancel the pending or in-flight check job promptly.
+		c.vs.va.m.scheduler.Remove(c.schedID)
+	}
+	stateDB.Remove(c.UUID())
+	targetDB.Unregister(c.schedID)
+	eventLog.Forget(string(c.vs.id), string(c.id))
+	if c.flapTimer != nil {
+		c.flapTimer.Stop()
 	}
 	if c.metricTicker != nil {
 		c.metricTicker.Stop()
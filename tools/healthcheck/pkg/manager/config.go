@@ -22,11 +22,14 @@ import (
 	"fmt"
 	"io/ioutil"
 	"reflect"
+	"sort"
 	"time"
 
+	"github.com/golang/glog"
 	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/actioner"
 	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/checker"
 	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/comm"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/utils"
 	"gopkg.in/yaml.v2"
 )
 
@@ -118,19 +121,261 @@ func (va *VAConf) MergeDefault(defaultConf *VAConf) {
 	va.ActionConf.MergeDefault(&defaultConf.ActionConf)
 }
 
+const (
+	QuorumInitUp   = "up"
+	QuorumInitDown = "down"
+)
+
+// QuorumConf determines the VS health state from the aggregate health of its
+// backends. VS is down when healthy backends fall below `quorum-down`, and is
+// up again only when healthy backends reach `quorum-up`. Both thresholds default
+// to `quorum-count`, or to `quorum-percent` of total backends if it's specified,
+// and `quorum-up`/`quorum-down` take the same unit.
+//
+// +k8s:deepcopy-gen=true
+type QuorumConf struct {
+	QuorumCount     uint   `yaml:"quorum-count"`
+	QuorumPercent   uint   `yaml:"quorum-percent"`
+	QuorumUp        uint   `yaml:"quorum-up"`
+	QuorumDown      uint   `yaml:"quorum-down"`
+	QuorumInitState string `yaml:"quorum-init-state"`
+}
+
+func (qc *QuorumConf) Valid() error {
+	if qc.QuorumCount > 0 && qc.QuorumPercent > 0 {
+		return errors.New("quorum-count and quorum-percent are mutually exclusive")
+	}
+	if qc.QuorumCount == 0 && qc.QuorumPercent == 0 {
+		return errors.New("neither quorum-count nor quorum-percent specified")
+	}
+	if qc.QuorumPercent > 100 {
+		return fmt.Errorf("invalid quorum-percent: %d", qc.QuorumPercent)
+	}
+	if qc.QuorumPercent > 0 && (qc.QuorumUp > 100 || qc.QuorumDown > 100) {
+		return fmt.Errorf("invalid quorum-up/quorum-down percent: %d/%d", qc.QuorumUp, qc.QuorumDown)
+	}
+	if qc.UpThreshold() < qc.DownThreshold() {
+		return fmt.Errorf("quorum-up %d less than quorum-down %d", qc.UpThreshold(), qc.DownThreshold())
+	}
+	if qc.QuorumInitState != QuorumInitUp && qc.QuorumInitState != QuorumInitDown {
+		return fmt.Errorf("invalid quorum-init-state: %q", qc.QuorumInitState)
+	}
+	return nil
+}
+
+func (qc *QuorumConf) DeepEqual(other *QuorumConf) bool {
+	return reflect.DeepEqual(qc, other)
+}
+
+func (qc *QuorumConf) MergeDefault(defaultConf *QuorumConf) {
+	if qc.QuorumCount == 0 && qc.QuorumPercent == 0 {
+		qc.QuorumCount = defaultConf.QuorumCount
+		qc.QuorumPercent = defaultConf.QuorumPercent
+		if qc.QuorumUp == 0 && qc.QuorumDown == 0 {
+			qc.QuorumUp = defaultConf.QuorumUp
+			qc.QuorumDown = defaultConf.QuorumDown
+		}
+	}
+	if len(qc.QuorumInitState) == 0 {
+		qc.QuorumInitState = defaultConf.QuorumInitState
+	}
+}
+
+func (qc *QuorumConf) quorum() uint {
+	if qc.QuorumPercent > 0 {
+		return qc.QuorumPercent
+	}
+	return qc.QuorumCount
+}
+
+// UpThreshold returns the threshold, in count or percent, to regain quorum.
+func (qc *QuorumConf) UpThreshold() uint {
+	if qc.QuorumUp > 0 {
+		return qc.QuorumUp
+	}
+	return qc.quorum()
+}
+
+// DownThreshold returns the threshold, in count or percent, below which quorum is lost.
+func (qc *QuorumConf) DownThreshold() uint {
+	if qc.QuorumDown > 0 {
+		return qc.QuorumDown
+	}
+	return qc.quorum()
+}
+
+// RampConf configures the slow start of backends recovered from Unhealthy.
+// Backend weight is ramped from `ramp-start-weight` to its configured weight in
+// `ramp-steps` increments over `ramp-duration`. Zero `ramp-duration` disables it.
+//
+// +k8s:deepcopy-gen=true
+type RampConf struct {
+	RampStartWeight uint          `yaml:"ramp-start-weight"`
+	RampDuration    time.Duration `yaml:"ramp-duration"`
+	RampSteps       uint          `yaml:"ramp-steps"`
+}
+
+func (rc *RampConf) Valid() error {
+	if rc.RampDuration < 0 {
+		return fmt.Errorf("invalid ramp-duration: %v", rc.RampDuration)
+	}
+	if rc.RampDuration > 0 {
+		if rc.RampSteps == 0 {
+			return errors.New("zero ramp-steps")
+		}
+		if rc.RampStepInterval() <= 0 {
+			return fmt.Errorf("ramp-duration %v too short for %d ramp-steps", rc.RampDuration, rc.RampSteps)
+		}
+	}
+	return nil
+}
+
+func (rc *RampConf) DeepEqual(other *RampConf) bool {
+	return reflect.DeepEqual(rc, other)
+}
+
+func (rc *RampConf) MergeDefault(defaultConf *RampConf) {
+	if rc.RampStartWeight == 0 {
+		rc.RampStartWeight = defaultConf.RampStartWeight
+	}
+	if rc.RampDuration == 0 {
+		rc.RampDuration = defaultConf.RampDuration
+	}
+	if rc.RampSteps == 0 {
+		rc.RampSteps = defaultConf.RampSteps
+	}
+}
+
+// RampStepInterval returns the time interval between two ramp steps.
+func (rc *RampConf) RampStepInterval() time.Duration {
+	if rc.RampSteps == 0 {
+		return 0
+	}
+	return rc.RampDuration / time.Duration(rc.RampSteps)
+}
+
+const (
+	InitStateUnknown       = "unknown-until-first-check"
+	InitStateAssumeHealthy = "assume-healthy"
+)
+
+// DiscoveryConf configures how VS follows the backends discovered from dpvs.
+// A backend removed from dpvs is still checked for `debounce-window`, and it's
+// taken back as if never removed if it reappears within the window, so that the
+// backends flapping in the service list cause no checker churn or action thrash.
+// A negative `debounce-window` removes backends immediately. Newly discovered
+// backends start in `init-state`, and `cleanup-actioner` is signaled Unhealthy
+// with the backend as target when it's finally removed, if configured.
+//
+// +k8s:deepcopy-gen=true
+type DiscoveryConf struct {
+	DebounceWindow      time.Duration     `yaml:"debounce-window"`
+	InitState           string            `yaml:"init-state"`
+	CleanupActioner     string            `yaml:"cleanup-actioner"`
+	CleanupActionParams map[string]string `yaml:"cleanup-action-params"`
+}
+
+func (dc *DiscoveryConf) Valid() error {
+	if dc.InitState != InitStateUnknown && dc.InitState != InitStateAssumeHealthy {
+		return fmt.Errorf("invalid init-state: %q", dc.InitState)
+	}
+	if len(dc.CleanupActioner) == 0 {
+		if len(dc.CleanupActionParams) > 0 {
+			return errors.New("cleanup-action-params given without cleanup-actioner")
+		}
+		return nil
+	}
+	if err := actioner.Validate(dc.CleanupActioner, dc.CleanupActionParams); err != nil {
+		return fmt.Errorf("invalid cleanup-actioner: %v", err)
+	}
+	return nil
+}
+
+func (dc *DiscoveryConf) DeepEqual(other *DiscoveryConf) bool {
+	return reflect.DeepEqual(dc, other)
+}
+
+func (dc *DiscoveryConf) MergeDefault(defaultConf *DiscoveryConf) {
+	if dc.DebounceWindow == 0 {
+		dc.DebounceWindow = defaultConf.DebounceWindow
+	}
+	if len(dc.InitState) == 0 {
+		dc.InitState = defaultConf.InitState
+	}
+	if len(dc.CleanupActioner) == 0 {
+		dc.CleanupActioner = defaultConf.CleanupActioner
+		if len(dc.CleanupActionParams) == 0 && len(defaultConf.CleanupActionParams) > 0 {
+			dc.CleanupActionParams = make(map[string]string, len(defaultConf.CleanupActionParams))
+			for name, val := range defaultConf.CleanupActionParams {
+				dc.CleanupActionParams[name] = val
+			}
+		}
+	}
+}
+
+// debounced returns whether the removal of backends is debounced.
+func (dc *DiscoveryConf) debounced() bool {
+	return dc.DebounceWindow > 0
+}
+
+// RSConf overrides the configs of a real server in the virtual server. The
+// zero fields are inherited from the virtual server.
+//
+// +k8s:deepcopy-gen=true
+type RSConf struct {
+	checker.Timing `yaml:",inline"`
+}
+
+func (rc *RSConf) Valid() error {
+	return rc.Timing.Valid()
+}
+
+func (rc *RSConf) MergeDefault(defaultConf *CheckerConf) {
+	rc.Timing.MergeDefault(&defaultConf.Timing)
+}
+
 // +k8s:deepcopy-gen=true
 type VSConf struct {
-	CheckerConf `yaml:",inline"`
-	ActionConf  `yaml:",inline"`
+	CheckerConf   `yaml:",inline"`
+	ActionConf    `yaml:",inline"`
+	QuorumConf    `yaml:",inline"`
+	RampConf      `yaml:",inline"`
+	DiscoveryConf `yaml:",inline"`
+	// RealServers is keyed by the CheckerID of the real server, such as
+	// "192.168.88.30-TCP-80".
+	RealServers map[CheckerID]RSConf `yaml:"real-servers,omitempty"`
 }
 
 func (vs *VSConf) Valid() error {
 	if err := vs.CheckerConf.Valid(); err != nil {
 		return err
 	}
+	for id, rs := range vs.RealServers {
+		if addr, err := utils.ParseL3L4AddrE(string(id)); err != nil || addr.String() != string(id) {
+			return fmt.Errorf("real-servers/%s: invalid real server, expect the form of IP-PROTO-PORT", id)
+		}
+		if err := rs.Valid(); err != nil {
+			return fmt.Errorf("real-servers/%s: %v", id, err)
+		}
+		if err := vs.LatencyConf.validTimeout(rs.Timeout); err != nil {
+			return fmt.Errorf("real-servers/%s: %v", id, err)
+		}
+		if err := vs.CheckerConf.validProbeLatency(rs.Timeout); err != nil {
+			return fmt.Errorf("real-servers/%s: %v", id, err)
+		}
+	}
 	if err := vs.ActionConf.Valid(); err != nil {
 		return err
 	}
+	if err := vs.QuorumConf.Valid(); err != nil {
+		return err
+	}
+	if err := vs.RampConf.Valid(); err != nil {
+		return err
+	}
+	if err := vs.DiscoveryConf.Valid(); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -141,12 +386,32 @@ func (vs *VSConf) DeepEqual(other *VSConf) bool {
 func (vs *VSConf) MergeDefault(defaultConf *VSConf) {
 	vs.CheckerConf.MergeDefault(&defaultConf.CheckerConf)
 	vs.ActionConf.MergeDefault(&defaultConf.ActionConf)
+	vs.QuorumConf.MergeDefault(&defaultConf.QuorumConf)
+	vs.RampConf.MergeDefault(&defaultConf.RampConf)
+	vs.DiscoveryConf.MergeDefault(&defaultConf.DiscoveryConf)
+	for id, rs := range vs.RealServers {
+		rs.MergeDefault(&vs.CheckerConf)
+		vs.RealServers[id] = rs
+	}
 }
 
 func (c *VSConf) GetCheckerConf() *CheckerConf {
 	return &c.CheckerConf
 }
 
+// GetRSCheckerConf returns the CheckerConf for real server `id`, with the
+// overrides of the real server applied if any.
+func (c *VSConf) GetRSCheckerConf(id CheckerID) *CheckerConf {
+	rs, ok := c.RealServers[id]
+	if !ok {
+		return &c.CheckerConf
+	}
+	conf := c.CheckerConf.DeepCopy()
+	rs.MergeDefault(&c.CheckerConf)
+	conf.Timing = rs.Timing
+	return conf
+}
+
 func (c *VSConf) GetActionConf() *ActionConf {
 	return &c.ActionConf
 }
@@ -164,39 +429,194 @@ func (c *VSConf) MergeDpvsCheckerConf(vs *comm.VirtualServer, params map[string]
 	}
 
 	if vs.ProxyProto&comm.ProxyProtoV1 == comm.ProxyProtoV1 {
-		params[checker.ParamProxyProto] = "v1"
+		rc[checker.ParamProxyProto] = "v1"
 	} else if vs.ProxyProto&comm.ProxyProtoV2 == comm.ProxyProtoV2 {
-		params[checker.ParamProxyProto] = "v2"
+		rc[checker.ParamProxyProto] = "v2"
 	}
 
 	if vs.Quic {
-		params[checker.ParamQuic] = "true"
+		rc[checker.ParamQuic] = "true"
 	}
 
 	return rc
 }
 
+const (
+	FlapPolicyUnhealthy = "unhealthy"
+	FlapPolicyHold      = "hold"
+)
+
+// FlapConf configures the flap detection of backends. A backend is flapping if
+// its noticed state transits between Healthy and Unhealthy more than
+// `flap-threshold` times within `flap-window`, and then it's held down for
+// `flap-holddown`, during which the check results are ignored. The policy
+// "unhealthy" forces the backend Unhealthy in hold-down, and "hold" keeps its
+// current state. Zero `flap-threshold` disables it.
+//
+// +k8s:deepcopy-gen=true
+type FlapConf struct {
+	FlapThreshold uint          `yaml:"flap-threshold"`
+	FlapWindow    time.Duration `yaml:"flap-window"`
+	FlapHolddown  time.Duration `yaml:"flap-holddown"`
+	FlapPolicy    string        `yaml:"flap-policy"`
+}
+
+func (fc *FlapConf) Valid() error {
+	if fc.FlapThreshold == 0 {
+		return nil
+	}
+	if fc.FlapWindow <= 0 {
+		return fmt.Errorf("invalid flap-window: %v", fc.FlapWindow)
+	}
+	if fc.FlapHolddown <= 0 {
+		return fmt.Errorf("invalid flap-holddown: %v", fc.FlapHolddown)
+	}
+	if fc.FlapPolicy != FlapPolicyUnhealthy && fc.FlapPolicy != FlapPolicyHold {
+		return fmt.Errorf("invalid flap-policy: %q", fc.FlapPolicy)
+	}
+	return nil
+}
+
+func (fc *FlapConf) MergeDefault(defaultConf *FlapConf) {
+	if fc.FlapThreshold == 0 {
+		fc.FlapThreshold = defaultConf.FlapThreshold
+	}
+	if fc.FlapWindow == 0 {
+		fc.FlapWindow = defaultConf.FlapWindow
+	}
+	if fc.FlapHolddown == 0 {
+		fc.FlapHolddown = defaultConf.FlapHolddown
+	}
+	if len(fc.FlapPolicy) == 0 {
+		fc.FlapPolicy = defaultConf.FlapPolicy
+	}
+}
+
+// LatencyConf configures the latency thresholds of backends, which apply to
+// the moving average latency of the last `latency-samples` successful probes.
+// A backend slower than `max-latency` is Unhealthy, and a backend slower than
+// `degraded-latency` stays Healthy but its weight is reduced to
+// `degraded-weight-percent` of the configured one. Zero thresholds disable them.
+//
+// +k8s:deepcopy-gen=true
+type LatencyConf struct {
+	MaxLatency            time.Duration `yaml:"max-latency"`
+	DegradedLatency       time.Duration `yaml:"degraded-latency"`
+	DegradedWeightPercent uint          `yaml:"degraded-weight-percent"`
+	LatencySamples        uint          `yaml:"latency-samples"`
+}
+
+func (lc *LatencyConf) Valid() error {
+	if lc.MaxLatency < 0 {
+		return fmt.Errorf("invalid max-latency: %v", lc.MaxLatency)
+	}
+	if lc.DegradedLatency < 0 {
+		return fmt.Errorf("invalid degraded-latency: %v", lc.DegradedLatency)
+	}
+	if lc.DegradedLatency > 0 {
+		if lc.MaxLatency > 0 && lc.DegradedLatency >= lc.MaxLatency {
+			return fmt.Errorf("degraded-latency %v not less than max-latency %v",
+				lc.DegradedLatency, lc.MaxLatency)
+		}
+		if lc.DegradedWeightPercent == 0 || lc.DegradedWeightPercent > 100 {
+			return fmt.Errorf("invalid degraded-weight-percent: %d", lc.DegradedWeightPercent)
+		}
+	}
+	if lc.LatencySamples == 0 {
+		return fmt.Errorf("invalid latency-samples: %d", lc.LatencySamples)
+	}
+	return nil
+}
+
+// validTimeout checks the latency thresholds are reachable within the check `timeout`.
+func (lc *LatencyConf) validTimeout(timeout time.Duration) error {
+	if lc.MaxLatency > 0 && lc.MaxLatency >= timeout {
+		return fmt.Errorf("max-latency %v not less than timeout %v", lc.MaxLatency, timeout)
+	}
+	if lc.DegradedLatency > 0 && lc.DegradedLatency >= timeout {
+		return fmt.Errorf("degraded-latency %v not less than timeout %v", lc.DegradedLatency, timeout)
+	}
+	return nil
+}
+
+func (lc *LatencyConf) enabled() bool {
+	return lc.MaxLatency > 0 || lc.DegradedLatency > 0
+}
+
+func (lc *LatencyConf) MergeDefault(defaultConf *LatencyConf) {
+	if lc.MaxLatency == 0 {
+		lc.MaxLatency = defaultConf.MaxLatency
+	}
+	if lc.DegradedLatency == 0 {
+		lc.DegradedLatency = defaultConf.DegradedLatency
+	}
+	if lc.DegradedWeightPercent == 0 {
+		lc.DegradedWeightPercent = defaultConf.DegradedWeightPercent
+	}
+	if lc.LatencySamples == 0 {
+		lc.LatencySamples = defaultConf.LatencySamples
+	}
+}
+
 // +k8s:deepcopy-gen=true
 type CheckerConf struct {
-	Method       checker.Method    `yaml:"method"`
-	Interval     time.Duration     `yaml:"interval"`
-	DownRetry    uint              `yaml:"down-retry"`
-	UpRetry      uint              `yaml:"up-retry"`
-	Timeout      time.Duration     `yaml:"timeout"`
-	MethodParams map[string]string `yaml:"method-params"`
+	Method         checker.Method `yaml:"method"`
+	checker.Timing `yaml:",inline"`
+	DownRetry      uint              `yaml:"down-retry"`
+	UpRetry        uint              `yaml:"up-retry"`
+	MethodParams   map[string]string `yaml:"method-params"`
+	FlapConf       `yaml:",inline"`
+	LatencyConf    `yaml:",inline"`
 }
 
 func (c *CheckerConf) Valid() error {
-	if c.Interval <= 0 {
-		return fmt.Errorf("invalid checker interval %v", c.Interval)
+	if err := c.Timing.Valid(); err != nil {
+		return err
 	}
-	if c.Timeout <= 0 {
-		return fmt.Errorf("invalid checker timeout %v", c.Timeout)
+	if err := c.FlapConf.Valid(); err != nil {
+		return err
+	}
+	if err := c.LatencyConf.Valid(); err != nil {
+		return err
+	}
+	if err := c.LatencyConf.validTimeout(c.Timeout); err != nil {
+		return err
+	}
+	if err := c.validProbeLatency(c.Timeout); err != nil {
+		return err
+	}
+	if c.probeMaxLatency() > 0 && c.MaxLatency > 0 {
+		glog.Warningf("Both method param %s=%v of single probes and max-latency %v of average "+
+			"latency are set, a backend is Unhealthy if either is exceeded", checker.ParamMaxLatency,
+			c.probeMaxLatency(), c.MaxLatency)
 	}
 
 	return checker.Validate(c.Method, c.MethodParams)
 }
 
+// probeMaxLatency returns the max-latency method param, which applies to the
+// latency of single probes, or 0 if it's unset or invalid.
+func (c *CheckerConf) probeMaxLatency() time.Duration {
+	val, ok := c.MethodParams[checker.ParamMaxLatency]
+	if !ok {
+		return 0
+	}
+	d, err := time.ParseDuration(val)
+	if err != nil || d < 0 {
+		return 0
+	}
+	return d
+}
+
+// validProbeLatency checks the max-latency method param is reachable within
+// the check `timeout`.
+func (c *CheckerConf) validProbeLatency(timeout time.Duration) error {
+	if d := c.probeMaxLatency(); d > 0 && d >= timeout {
+		return fmt.Errorf("method param %s %v not less than timeout %v", checker.ParamMaxLatency, d, timeout)
+	}
+	return nil
+}
+
 func (c *CheckerConf) DeepEqual(other *CheckerConf) bool {
 	return reflect.DeepEqual(c, other)
 }
@@ -220,9 +640,7 @@ func (c *CheckerConf) MergeDefault(defaultConf *CheckerConf) {
 			}
 		}
 	}
-	if c.Interval == 0 {
-		c.Interval = defaultConf.Interval
-	}
+	c.Timing.MergeDefault(&defaultConf.Timing)
 	if c.DownRetry == 0 {
 		c.DownRetry = defaultConf.DownRetry
 	} else if c.DownRetry == ZERORETRY {
@@ -233,9 +651,8 @@ func (c *CheckerConf) MergeDefault(defaultConf *CheckerConf) {
 	} else if c.UpRetry == ZERORETRY {
 		c.UpRetry = 0
 	}
-	if c.Timeout == 0 {
-		c.Timeout = defaultConf.Timeout
-	}
+	c.FlapConf.MergeDefault(&defaultConf.FlapConf)
+	c.LatencyConf.MergeDefault(&defaultConf.LatencyConf)
 
 	if len(c.MethodParams) == 0 {
 		// TODO: Support method-dependent default params.
@@ -264,6 +681,62 @@ func (c *Conf) GetVSConf(id VSID) *VSConf {
 	return &c.vsGlobal
 }
 
+// Diff returns the changes from c to other in a human readable format, such as
+// "+virtual-servers/192.168.88.1-TCP-80", and empty if no change.
+func (c *Conf) Diff(other *Conf) []string {
+	var diffs []string
+	if !c.vaGlobal.DeepEqual(&other.vaGlobal) {
+		diffs = append(diffs, "~global/virtual-address")
+	}
+	if !c.vsGlobal.DeepEqual(&other.vsGlobal) {
+		diffs = append(diffs, "~global/virtual-server")
+	}
+
+	vaids := make(map[VAID]struct{})
+	for vaid := range c.vaConf {
+		vaids[vaid] = struct{}{}
+	}
+	for vaid := range other.vaConf {
+		vaids[vaid] = struct{}{}
+	}
+	vaDiffs := make([]string, 0)
+	for vaid := range vaids {
+		old, ok1 := c.vaConf[vaid]
+		cur, ok2 := other.vaConf[vaid]
+		if !ok1 {
+			vaDiffs = append(vaDiffs, fmt.Sprintf("+virtual-addresses/%s", vaid))
+		} else if !ok2 {
+			vaDiffs = append(vaDiffs, fmt.Sprintf("-virtual-addresses/%s", vaid))
+		} else if !old.DeepEqual(&cur) {
+			vaDiffs = append(vaDiffs, fmt.Sprintf("~virtual-addresses/%s", vaid))
+		}
+	}
+	sort.Strings(vaDiffs)
+
+	vsids := make(map[VSID]struct{})
+	for vsid := range c.vsConf {
+		vsids[vsid] = struct{}{}
+	}
+	for vsid := range other.vsConf {
+		vsids[vsid] = struct{}{}
+	}
+	vsDiffs := make([]string, 0)
+	for vsid := range vsids {
+		old, ok1 := c.vsConf[vsid]
+		cur, ok2 := other.vsConf[vsid]
+		if !ok1 {
+			vsDiffs = append(vsDiffs, fmt.Sprintf("+virtual-servers/%s", vsid))
+		} else if !ok2 {
+			vsDiffs = append(vsDiffs, fmt.Sprintf("-virtual-servers/%s", vsid))
+		} else if !old.DeepEqual(&cur) {
+			vsDiffs = append(vsDiffs, fmt.Sprintf("~virtual-servers/%s", vsid))
+		}
+	}
+	sort.Strings(vsDiffs)
+
+	return append(append(diffs, vaDiffs...), vsDiffs...)
+}
+
 var (
 	vaConfDefault VAConf = VAConf{
 		Disable:    false,
@@ -305,16 +778,44 @@ var (
 	vsConfDefault VSConf = VSConf{
 		CheckerConf: CheckerConf{
 			Method:    checker.CheckMethodAuto,
-			Interval:  3 * time.Second,
 			DownRetry: 1,
 			UpRetry:   1,
-			Timeout:   2 * time.Second,
+			Timing: checker.Timing{
+				Interval:          3 * time.Second,
+				Timeout:           2 * time.Second,
+				DownRetryInterval: 0, // same as interval
+			},
+			FlapConf: FlapConf{
+				FlapThreshold: 0, // disabled
+				FlapWindow:    10 * time.Minute,
+				FlapHolddown:  10 * time.Minute,
+				FlapPolicy:    FlapPolicyUnhealthy,
+			},
+			LatencyConf: LatencyConf{
+				MaxLatency:            0, // disabled
+				DegradedLatency:       0, // disabled
+				DegradedWeightPercent: 50,
+				LatencySamples:        3,
+			},
 		},
 		ActionConf: ActionConf{
 			Actioner:       "BackendUpdate",
 			ActionTimeout:  2 * time.Second,
 			ActionSyncTime: 15 * time.Second,
 		},
+		QuorumConf: QuorumConf{
+			QuorumCount:     1,
+			QuorumInitState: QuorumInitUp,
+		},
+		RampConf: RampConf{
+			RampStartWeight: 1,
+			RampDuration:    0, // disabled
+			RampSteps:       10,
+		},
+		DiscoveryConf: DiscoveryConf{
+			DebounceWindow: 30 * time.Second,
+			InitState:      InitStateUnknown,
+		},
 	}
 
 	confDefault Conf = Conf{
@@ -412,11 +913,9 @@ func (fc *ConfFileLayout) Translate() (*Conf, error) {
 	}, nil
 }
 
-func LoadFileConf(filename string) (*Conf, error) {
-	if len(filename) == 0 {
-		return &confDefault, nil
-	}
-
+// loadFileLayout loads the config file, and returns it merged with the
+// default configs and validated.
+func loadFileLayout(filename string) (*ConfFileLayout, error) {
 	data, err := ioutil.ReadFile(filename)
 	if err != nil {
 		return nil, err
@@ -434,7 +933,19 @@ func LoadFileConf(filename string) (*Conf, error) {
 	if err = fileConf.Validate(); err != nil {
 		return nil, fmt.Errorf("Invalid config from file: %v", err)
 	}
-	GetAppManager().cfgFileReloader.SetRaw(&fileConf)
+	return &fileConf, nil
+}
+
+func LoadFileConf(filename string) (*Conf, error) {
+	if len(filename) == 0 {
+		return &confDefault, nil
+	}
+
+	fileConf, err := loadFileLayout(filename)
+	if err != nil {
+		return nil, err
+	}
+	GetAppManager().cfgFileReloader.SetRaw(fileConf)
 
 	return fileConf.Translate()
 }
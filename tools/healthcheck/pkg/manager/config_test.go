@@ -0,0 +1,206 @@
+// /*
+// Copyright 2025 IQiYi Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// */
+
+package manager
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/checker"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/comm"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/types"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/utils"
+	"gopkg.in/yaml.v2"
+)
+
+// loadConfText loads config from text as LoadFileConf does.
+func loadConfText(text string) (*Conf, error) {
+	var fileConf ConfFileLayout
+	if err := yaml.Unmarshal([]byte(text), &fileConf); err != nil {
+		return nil, err
+	}
+	fileConf.Merge(&confDefault)
+	if err := fileConf.Validate(); err != nil {
+		return nil, err
+	}
+	return fileConf.Translate()
+}
+
+const timingConfText = `
+global:
+  virtual-server:
+    interval: 10s
+    timeout: 3s
+    down-retry-interval: 5s
+virtual-servers:
+  192.168.88.1-TCP-80:
+    real-servers:
+      192.168.88.11-TCP-80:
+        timeout: 1s
+      192.168.88.12-TCP-80:
+        interval: 6s
+        timeout: 1s
+        down-retry-interval: 2s
+  192.168.88.2-TCP-80:
+    interval: 8s
+    down-retry-interval: 4s
+    real-servers:
+      192.168.88.21-TCP-80:
+        interval: 4s
+  192.168.88.3-TCP-80:
+    timeout: 1s
+`
+
+func TestTimingInheritance(t *testing.T) {
+	conf, err := loadConfText(timingConfText)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sec := time.Second
+	timing := func(interval, timeout, downRetryInterval time.Duration) checker.Timing {
+		return checker.Timing{Interval: interval, Timeout: timeout, DownRetryInterval: downRetryInterval}
+	}
+	tests := []struct {
+		vs     VSID
+		rs     CheckerID
+		expect checker.Timing
+	}{
+		// global defaults
+		{"192.168.88.9-TCP-80", "192.168.88.91-TCP-80", timing(10*sec, 3*sec, 5*sec)},
+		// virtual server inheriting all from global
+		{"192.168.88.1-TCP-80", "192.168.88.10-TCP-80", timing(10*sec, 3*sec, 5*sec)},
+		// real server overrides
+		{"192.168.88.1-TCP-80", "192.168.88.11-TCP-80", timing(10*sec, 1*sec, 5*sec)},
+		{"192.168.88.1-TCP-80", "192.168.88.12-TCP-80", timing(6*sec, 1*sec, 2*sec)},
+		// virtual server overrides
+		{"192.168.88.2-TCP-80", "192.168.88.20-TCP-80", timing(8*sec, 3*sec, 4*sec)},
+		// real server overrides upon virtual server overrides
+		{"192.168.88.2-TCP-80", "192.168.88.21-TCP-80", timing(4*sec, 3*sec, 4*sec)},
+		{"192.168.88.3-TCP-80", "192.168.88.30-TCP-80", timing(10*sec, 1*sec, 5*sec)},
+	}
+	for _, tc := range tests {
+		got := conf.GetVSConf(tc.vs).GetRSCheckerConf(tc.rs).Timing.Resolved()
+		if got != tc.expect {
+			t.Errorf("%s/%s: expect timing %+v, got %+v", tc.vs, tc.rs, tc.expect, got)
+		}
+	}
+
+	// The overrides of a real server don't leak to the virtual server.
+	if vsConf := conf.GetVSConf("192.168.88.1-TCP-80"); vsConf.Timeout != 3*sec {
+		t.Errorf("expect timeout of virtual server 3s, got %v", vsConf.Timeout)
+	}
+}
+
+func TestTimingValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		err  string // substring of the error
+	}{
+		{"global timeout not less than interval", `
+global:
+  virtual-server:
+    interval: 2s
+    timeout: 2s
+`, "global/virtual-server: checker timeout 2s not less than interval 2s"},
+		{"negative down-retry-interval", `
+virtual-servers:
+  192.168.88.1-TCP-80:
+    down-retry-interval: -1s
+`, "invalid checker down-retry-interval -1s"},
+		{"down-retry-interval greater than interval", `
+virtual-servers:
+  192.168.88.1-TCP-80:
+    down-retry-interval: 4s
+`, "down-retry-interval 4s greater than interval 3s"},
+		{"interval inherited by real server", `
+virtual-servers:
+  192.168.88.1-TCP-80:
+    down-retry-interval: 2500ms
+    real-servers:
+      192.168.88.11-TCP-80:
+        interval: 2s
+        timeout: 1s
+`, "real-servers/192.168.88.11-TCP-80: checker down-retry-interval 2.5s greater than interval 2s"},
+		{"real server timeout", `
+virtual-servers:
+  192.168.88.1-TCP-80:
+    real-servers:
+      192.168.88.11-TCP-80:
+        timeout: 5s
+`, "real-servers/192.168.88.11-TCP-80: checker timeout 5s not less than interval 3s"},
+		{"invalid real server", `
+virtual-servers:
+  192.168.88.1-TCP-80:
+    real-servers:
+      192.168.88.11:
+        timeout: 1s
+`, "real-servers/192.168.88.11: invalid real server"},
+	}
+	for _, tc := range tests {
+		_, err := loadConfText(tc.text)
+		if err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("%s: expect error %q, got %v", tc.name, tc.err, err)
+		}
+	}
+}
+
+func TestCheckerDownRetryInterval(t *testing.T) {
+	svc := &comm.VirtualServer{
+		Version:   1,
+		Addr:      utils.L3L4Addr{IP: net.ParseIP("192.168.100.1"), Port: 80, Proto: utils.IPProtoTCP},
+		DestCheck: checker.CheckMethodNone,
+	}
+	_, vs := newTestVS(t, svc, &vsConfDefault.QuorumConf)
+	ckConf := vs.conf.GetCheckerConf().DeepCopy()
+	ckConf.DownRetry = 0
+	ckConf.UpRetry = 0
+	ckConf.Timing = checker.Timing{Interval: 3 * time.Second, Timeout: time.Second,
+		DownRetryInterval: 2 * time.Second}
+
+	target := utils.L3L4Addr{IP: net.ParseIP("192.168.200.1"), Port: 8080, Proto: utils.IPProtoTCP}
+	ck, err := NewChecker(&target, ckConf, vs)
+	if err != nil {
+		t.Fatalf("failed to create checker: %v", err)
+	}
+	scheduler := vs.va.m.scheduler
+	ck.schedule()
+	defer scheduler.Remove(ck.schedID)
+
+	expectInterval := func(step string, expect time.Duration) {
+		t.Helper()
+		scheduler.lock.Lock()
+		got := scheduler.entries[ck.schedID].interval
+		scheduler.lock.Unlock()
+		if got != expect {
+			t.Errorf("%s: expect interval %v, got %v", step, expect, got)
+		}
+	}
+	expectInterval("initial", 3*time.Second)
+	ck.doCheckResult(&checkResult{state: types.Unhealthy, timeout: time.Second})
+	expectInterval("unhealthy", 2*time.Second)
+	ck.doCheckResult(&checkResult{state: types.Healthy, timeout: time.Second})
+	expectInterval("healthy", 3*time.Second)
+
+	ckConf = ckConf.DeepCopy()
+	ckConf.DownRetryInterval = 1500 * time.Millisecond
+	ck.doUpdate(ckConf)
+	ck.doCheckResult(&checkResult{state: types.Unhealthy, timeout: time.Second})
+	expectInterval("updated", 1500*time.Millisecond)
+}
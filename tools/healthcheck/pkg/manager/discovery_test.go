@@ -0,0 +1,308 @@
+// /*
+// Copyright 2025 IQiYi Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// */
+
+package manager
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/actioner"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/checker"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/comm"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/types"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/utils"
+)
+
+// fakeDpvs is a service source of dpvs whose services are changed by tests.
+type fakeDpvs struct {
+	lock  sync.Mutex
+	svcs  []comm.VirtualServer
+	lists int
+}
+
+func (f *fakeDpvs) list(ctx context.Context) ([]comm.VirtualServer, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.lists++
+	svcs := make([]comm.VirtualServer, len(f.svcs))
+	for i := range f.svcs {
+		f.svcs[i].DeepCopyInto(&svcs[i])
+	}
+	return svcs, nil
+}
+
+func (f *fakeDpvs) count() int {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.lists
+}
+
+// setRSs replaces the backends of the only service with `rss`.
+func (f *fakeDpvs) setRSs(rss ...comm.RealServer) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.svcs[0].Version++
+	f.svcs[0].RSs = rss
+}
+
+// refresh makes the service lister fetch services from f, and waits until the
+// services are applied.
+func (f *fakeDpvs) refresh(t *testing.T, m *Manager) {
+	lists := f.count()
+	trigger(m.svcLister.trigger)
+	for i := 0; i < 100 && f.count() == lists; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if f.count() == lists {
+		t.Fatalf("services not refreshed")
+	}
+	// Wait for the services passed on from VA to VS.
+	time.Sleep(50 * time.Millisecond)
+}
+
+func testRS(i int) comm.RealServer {
+	return comm.RealServer{
+		Addr:   utils.L3L4Addr{IP: net.ParseIP(fmt.Sprintf("192.168.200.%d", i)), Port: 8080, Proto: utils.IPProtoTCP},
+		Weight: 100,
+	}
+}
+
+func rsID(i int) string {
+	rs := testRS(i)
+	return rs.Addr.String()
+}
+
+// cleanupCalls returns the calls to the cleanup actioner, whose targets are backends.
+// The cleanup actions are dispatched in background, so it waits a while for at
+// least `n` calls.
+func cleanupCalls(recorder *actioner.RecordingAction, n int) []actioner.ActionCall {
+	var calls []actioner.ActionCall
+	for i := 0; i < 100; i++ {
+		calls = calls[:0]
+		for _, call := range recorder.Calls() {
+			if call.Target != nil && call.Target.Port == 8080 {
+				calls = append(calls, call)
+			}
+		}
+		if len(calls) >= n {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return calls
+}
+
+func TestVSBackendDebounce(t *testing.T) {
+	recorder := actioner.NewRecordingAction()
+	actioner.RegisterRecordingAction(recorder)
+
+	svc := &comm.VirtualServer{
+		Version:   1,
+		Addr:      utils.L3L4Addr{IP: net.ParseIP("192.168.100.1"), Port: 80, Proto: utils.IPProtoTCP},
+		DestCheck: checker.CheckMethodNone,
+	}
+	_, vs := newTestVS(t, svc, &vsConfDefault.QuorumConf)
+	defer vs.cleanup()
+	vs.conf.DebounceWindow = 200 * time.Millisecond
+	vs.conf.CleanupActioner = actioner.RecordingActionerName
+
+	setRSs := func(rss ...comm.RealServer) {
+		svc.Version++
+		svc.RSs = rss
+		vs.doUpdate(&VSConfExt{VSConf: *vs.conf.DeepCopy(), vs: *svc.DeepCopy()})
+	}
+	rs0, rs1 := CheckerID(rsID(0)), CheckerID(rsID(1))
+
+	setRSs(testRS(0), testRS(1))
+	if len(vs.backends) != 2 || vs.debounce != nil {
+		t.Fatalf("expect 2 backends without debounce, got %d, %v", len(vs.backends), vs.debounce)
+	}
+	created := vs.backends[rs1].checker
+
+	// Removed backend is kept in the window, and no action is taken on it.
+	setRSs(testRS(0))
+	if len(vs.backends) != 2 || vs.backends[rs1].removed.IsZero() || vs.debounce == nil {
+		t.Fatalf("expect backend %s kept in debounce window", rs1)
+	}
+	vs.recvNotice(&BackendState{id: rs1, state: types.Unhealthy})
+	if calls := recorder.Calls(); len(calls) != 0 {
+		t.Errorf("expect no action on removed backend, got %v", calls)
+	}
+	if vs.backends[rs1].checkerState != types.Unhealthy {
+		t.Errorf("expect removed backend state noticed, got %v", vs.backends[rs1].checkerState)
+	}
+
+	// Reappeared backend is taken back with the same checker.
+	setRSs(testRS(0), testRS(1))
+	if rs := vs.backends[rs1]; rs == nil || rs.checker != created || !rs.removed.IsZero() {
+		t.Fatalf("expect backend %s taken back with the same checker", rs1)
+	}
+	if vs.debounce != nil {
+		t.Errorf("expect debounce timer stopped")
+	}
+	if calls := recorder.Calls(); len(calls) != 0 {
+		t.Errorf("expect no action on reappeared backend with Blank VS actioner, got %v", calls)
+	}
+
+	// Backend removed for the window is purged, and the cleanup actioner is signaled.
+	setRSs(testRS(1))
+	if vs.backends[rs0].removed.IsZero() {
+		t.Fatalf("expect backend %s removed", rs0)
+	}
+	now := <-vs.debounce.C
+	vs.debounce = nil
+	if !vs.purgeRemoved(now) {
+		t.Fatalf("expect backend %s purged", rs0)
+	}
+	if _, ok := vs.backends[rs0]; ok || len(vs.backends) != 1 || vs.debounce != nil {
+		t.Fatalf("expect only backend %s left, got %d backends", rs1, len(vs.backends))
+	}
+	calls := cleanupCalls(recorder, 1)
+	if len(calls) != 1 || calls[0].Target.String() != string(rs0) || calls[0].Signal != types.Unhealthy {
+		t.Fatalf("expect a cleanup call to %s, got %v", rs0, calls)
+	}
+	if detail, ok := calls[0].Data[0].(*actioner.ActionDetail); !ok || detail.Message != "removed from dpvs" {
+		t.Errorf("unexpected cleanup action data: %v", calls[0].Data)
+	}
+
+	// Backends are removed immediately without debounce.
+	vs.conf.DebounceWindow = -1
+	setRSs()
+	if len(vs.backends) != 0 || vs.debounce != nil {
+		t.Errorf("expect all backends removed immediately, got %d", len(vs.backends))
+	}
+	if calls := cleanupCalls(recorder, 2); len(calls) != 2 || calls[1].Target.String() != string(rs1) {
+		t.Errorf("expect a cleanup call to %s, got %v", rs1, calls)
+	}
+}
+
+func TestVSBackendInitState(t *testing.T) {
+	svc := &comm.VirtualServer{
+		Version:   1,
+		Addr:      utils.L3L4Addr{IP: net.ParseIP("192.168.100.1"), Port: 80, Proto: utils.IPProtoTCP},
+		DestCheck: checker.CheckMethodNone,
+		RSs:       []comm.RealServer{testRS(0)},
+	}
+	for _, tc := range []struct {
+		initState string
+		expect    types.State
+	}{
+		{InitStateUnknown, types.Unknown},
+		{InitStateAssumeHealthy, types.Healthy},
+	} {
+		_, vs := newTestVS(t, svc, &vsConfDefault.QuorumConf)
+		vs.conf.InitState = tc.initState
+		vs.doUpdate(&VSConfExt{VSConf: *vs.conf.DeepCopy(), vs: *svc.DeepCopy()})
+		rs := vs.backends[CheckerID(rsID(0))]
+		if rs.checkerState != tc.expect || rs.checker.noticedState() != tc.expect {
+			t.Errorf("%s: expect backend in %v, got %v (checker %v)", tc.initState, tc.expect,
+				rs.checkerState, rs.checker.noticedState())
+		}
+		vs.cleanup()
+	}
+}
+
+func TestDiscoveryRefresh(t *testing.T) {
+	recorder := actioner.NewRecordingAction()
+	actioner.RegisterRecordingAction(recorder)
+
+	dpvs := &fakeDpvs{svcs: []comm.VirtualServer{{
+		Version:   1,
+		Addr:      utils.L3L4Addr{IP: net.ParseIP("192.168.100.1"), Port: 80, Proto: utils.IPProtoTCP},
+		DestCheck: checker.CheckMethodNone,
+		RSs:       []comm.RealServer{testRS(1)},
+	}}}
+	env := newReloadEnv(t, nil)
+	env.m.svcLister.list = dpvs.list
+	env.start(`
+virtual-servers:
+  192.168.100.1-TCP-80:
+    debounce-window: 500ms
+    init-state: assume-healthy
+    cleanup-actioner: Recording
+`)
+	defer env.stop()
+
+	const vs = "192.168.100.1-TCP-80"
+	rs1 := fmt.Sprintf("%s/%s", vs, rsID(1))
+	rs2 := fmt.Sprintf("%s/%s", vs, rsID(2))
+	env.converge("initial", map[string]string{rs1: "nonemap[]"})
+	started, finished := CheckerThreads.Running(), CheckerThreads.Finished()
+
+	dpvs.setRSs(testRS(1), testRS(2))
+	dpvs.refresh(t, env.m)
+	env.converge("add rs2", map[string]string{rs1: "nonemap[]", rs2: "nonemap[]"})
+	if running := CheckerThreads.Running(); running != started+1 {
+		t.Errorf("add rs2: expect %d checkers running, got %d", started+1, running)
+	}
+
+	// rs2 flaps in the service list within the debounce window.
+	for i := 0; i < 3; i++ {
+		dpvs.setRSs(testRS(1))
+		dpvs.refresh(t, env.m)
+		dpvs.setRSs(testRS(1), testRS(2))
+		dpvs.refresh(t, env.m)
+	}
+	env.converge("rs2 flapping", map[string]string{rs1: "nonemap[]", rs2: "nonemap[]"})
+	if n := CheckerThreads.Finished(); n != finished {
+		t.Errorf("rs2 flapping: expect no checker stopped, got %d", n-finished)
+	}
+	if calls := cleanupCalls(recorder, 0); len(calls) != 0 {
+		t.Errorf("rs2 flapping: expect no cleanup action, got %v", calls)
+	}
+
+	// rs2 removed for the debounce window.
+	dpvs.setRSs(testRS(1))
+	dpvs.refresh(t, env.m)
+	env.converge("remove rs2", map[string]string{rs1: "nonemap[]"})
+	for i := 0; i < 100 && CheckerThreads.Finished() == finished; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := CheckerThreads.Finished(); n != finished+1 {
+		t.Errorf("remove rs2: expect 1 checker stopped, got %d", n-finished)
+	}
+	calls := cleanupCalls(recorder, 1)
+	if len(calls) != 1 || calls[0].Target.String() != rsID(2) {
+		t.Errorf("remove rs2: expect a cleanup call to rs2, got %v", calls)
+	}
+}
+
+func TestDiscoveryConfValid(t *testing.T) {
+	invalids := []DiscoveryConf{
+		{InitState: "healthy"},
+		{InitState: InitStateUnknown, CleanupActionParams: map[string]string{"script": "a.sh"}},
+		{InitState: InitStateUnknown, CleanupActioner: "Unknown"},
+	}
+	for _, conf := range invalids {
+		if err := conf.Valid(); err == nil {
+			t.Errorf("expect invalid DiscoveryConf: %+v", conf)
+		}
+	}
+
+	conf := DiscoveryConf{DebounceWindow: -1, CleanupActioner: "Blank"}
+	conf.MergeDefault(&vsConfDefault.DiscoveryConf)
+	if err := conf.Valid(); err != nil {
+		t.Errorf("DiscoveryConf %+v merged default invalid: %v", conf, err)
+	}
+	if conf.debounced() || conf.InitState != InitStateUnknown {
+		t.Errorf("unexpected DiscoveryConf merged default: %+v", conf)
+	}
+}
@@ -0,0 +1,345 @@
+// /*
+// Copyright 2025 IQiYi Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// */
+
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/types"
+)
+
+// ErrActionCoalesced is returned to a queued action superseded by a later
+// action of the same target.
+var ErrActionCoalesced = errors.New("action coalesced by a later one")
+
+// ActionJob performs an action procedure within `timeout`.
+type ActionJob func(timeout time.Duration) (interface{}, error)
+
+// ChangeJob performs an incremental action procedure of the changed items
+// `changes` within `timeout`.
+type ChangeJob func(changes []string, timeout time.Duration) (interface{}, error)
+
+// DispatcherStats holds the statistics of an ActionDispatcher.
+type DispatcherStats struct {
+	Pending    int    // number of actions queued currently
+	Running    int    // number of actions running currently
+	Dispatched uint64 // number of actions dispatched
+	Executed   uint64 // number of actions executed
+	Throttled  uint64 // number of actions delayed by rate limits
+	Coalesced  uint64 // number of queued actions dropped for a later one of the same target
+	Expired    uint64 // number of actions dropped for timeout before execution
+}
+
+func (s DispatcherStats) String() string {
+	return fmt.Sprintf("pending %d, running %d, dispatched %d, executed %d, throttled %d, "+
+		"coalesced %d, expired %d", s.Pending, s.Running, s.Dispatched, s.Executed,
+		s.Throttled, s.Coalesced, s.Expired)
+}
+
+// tokenBucket is a token bucket allowing reservations in advance, i.e., tokens
+// can be negative which means the tokens have been reserved by waiting actions.
+type tokenBucket struct {
+	rate   float64 // tokens per second
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// newTokenBucket returns a full bucket, or nil if rate is unlimited.
+func newTokenBucket(limit types.RateLimit, now time.Time) *tokenBucket {
+	if limit.Rate <= 0 {
+		return nil
+	}
+	burst := float64(limit.Burst)
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{
+		rate:   limit.Rate,
+		burst:  burst,
+		tokens: burst,
+		last:   now,
+	}
+}
+
+// delay returns the duration to wait from `now` until a token is available.
+func (b *tokenBucket) delay(now time.Time) time.Duration {
+	if b == nil {
+		return 0
+	}
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens += elapsed.Seconds() * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+		b.last = now
+	}
+	if b.tokens >= 1 {
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+}
+
+func (b *tokenBucket) take() {
+	if b != nil {
+		b.tokens--
+	}
+}
+
+type actionReq struct {
+	turn        chan struct{} // closed when it's the request's turn or it's coalesced
+	coalesced   bool
+	incremental bool
+	changes     []string // changed items of an incremental action
+}
+
+// absorb merges the changed items of the coalesced request `earlier` into req,
+// so that no change is lost when both of them are incremental.
+func (req *actionReq) absorb(earlier *actionReq) {
+	if !req.incremental || !earlier.incremental {
+		return
+	}
+	changes := make([]string, 0, len(earlier.changes)+len(req.changes))
+	seen := make(map[string]struct{}, cap(changes))
+	for _, items := range [][]string{earlier.changes, req.changes} {
+		for _, item := range items {
+			if _, ok := seen[item]; !ok {
+				seen[item] = struct{}{}
+				changes = append(changes, item)
+			}
+		}
+	}
+	req.changes = changes
+}
+
+// actionQueue is the FIFO action queue of a target. Only the latest pending
+// action is kept, the earlier ones are coalesced.
+type actionQueue struct {
+	running bool
+	pending *actionReq
+}
+
+// ActionDispatcher paces actioner invocations. Actions of a target are executed
+// one by one in FIFO order, and if multiple actions queue up for the same target,
+// only the latest one is executed. A full-state action simply supersedes the
+// pending one, while an incremental action takes over the changed items of the
+// pending one, so a target should stick to one kind of actions. Actions are rate limited by a global token
+// bucket and an optional token bucket per actioner type. An action is dropped
+// if its timeout budget, measured from dispatch time, is used up before it can
+// be executed.
+type ActionDispatcher struct {
+	lock   sync.Mutex
+	global *tokenBucket
+	kinds  map[string]*tokenBucket // keyed by actioner name
+	queues map[string]*actionQueue // keyed by target
+
+	dispatched uint64
+	executed   uint64
+	throttled  uint64
+	coalesced  uint64
+	expired    uint64
+}
+
+// NewActionDispatcher creates an ActionDispatcher with the global rate limit
+// and per actioner type rate limits. Zero rate means unlimited.
+func NewActionDispatcher(global types.RateLimit, kinds map[string]types.RateLimit) *ActionDispatcher {
+	now := time.Now()
+	d := &ActionDispatcher{
+		global: newTokenBucket(global, now),
+		kinds:  make(map[string]*tokenBucket),
+		queues: make(map[string]*actionQueue),
+	}
+	for kind, limit := range kinds {
+		if b := newTokenBucket(limit, now); b != nil {
+			d.kinds[kind] = b
+		}
+	}
+	return d
+}
+
+// Stats returns the current statistics of the ActionDispatcher.
+func (d *ActionDispatcher) Stats() DispatcherStats {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	stats := DispatcherStats{
+		Dispatched: d.dispatched,
+		Executed:   d.executed,
+		Throttled:  d.throttled,
+		Coalesced:  d.coalesced,
+		Expired:    d.expired,
+	}
+	for _, q := range d.queues {
+		if q.running {
+			stats.Running++
+		}
+		if q.pending != nil {
+			stats.Pending++
+		}
+	}
+	return stats
+}
+
+// Dispatch runs the full-state `job` of actioner `kind` for `target` under the
+// dispatcher's ordering and rate limits, and returns the job's results. It blocks
+// until the job finishes, or fails with ErrActionCoalesced if superseded by a
+// later action of the same target. The job is called with the remaining timeout
+// budget.
+//
+// A nil ActionDispatcher runs the job immediately.
+func (d *ActionDispatcher) Dispatch(target, kind string, timeout time.Duration,
+	job ActionJob) (interface{}, error) {
+	if d == nil {
+		return job(timeout)
+	}
+	req := &actionReq{turn: make(chan struct{})}
+	return d.dispatch(target, kind, timeout, req, job)
+}
+
+// DispatchChanges is like Dispatch, but for the incremental `job` that acts on
+// the changed items `changes` only. When superseded by a later action of the
+// same target, the changed items are passed on to the later one rather than
+// dropped, and the job is called with the merged changed items.
+func (d *ActionDispatcher) DispatchChanges(target, kind string, timeout time.Duration,
+	changes []string, job ChangeJob) (interface{}, error) {
+	if d == nil {
+		return job(changes, timeout)
+	}
+	req := &actionReq{
+		turn:        make(chan struct{}),
+		incremental: true,
+		changes:     changes,
+	}
+	return d.dispatch(target, kind, timeout, req, func(timeout time.Duration) (interface{}, error) {
+		return job(req.changes, timeout)
+	})
+}
+
+func (d *ActionDispatcher) dispatch(target, kind string, timeout time.Duration,
+	req *actionReq, job ActionJob) (interface{}, error) {
+	deadline := time.Now().Add(timeout)
+
+	d.lock.Lock()
+	d.dispatched++
+	q, ok := d.queues[target]
+	if !ok {
+		q = &actionQueue{}
+		d.queues[target] = q
+	}
+	if q.pending != nil {
+		req.absorb(q.pending)
+		q.pending.coalesced = true
+		close(q.pending.turn)
+		d.coalesced++
+		if d.coalesced&(d.coalesced-1) == 0 { // log at power of 2 times to avoid log flood
+			glog.Warningf("Action dispatcher coalesced %d actions so far, queue depth %d",
+				d.coalesced, d.depthLocked())
+		}
+	}
+	if q.running {
+		q.pending = req
+	} else {
+		q.running = true
+		close(req.turn)
+	}
+	d.lock.Unlock()
+
+	timer := time.NewTimer(timeout)
+	select {
+	case <-req.turn:
+	case <-timer.C:
+	}
+	timer.Stop()
+
+	d.lock.Lock()
+	if req.coalesced {
+		d.lock.Unlock()
+		glog.V(5).Infof("Action %s of %s coalesced by a later one", kind, target)
+		return nil, ErrActionCoalesced
+	}
+	if q.pending == req {
+		q.pending = nil
+		d.expireLocked()
+		d.lock.Unlock()
+		return nil, fmt.Errorf("action %s of %s timeout in queue", kind, target)
+	}
+	// It's the request's turn now, reserve tokens within the remaining budget.
+	now := time.Now()
+	kb := d.kinds[kind]
+	wait := d.global.delay(now)
+	if kwait := kb.delay(now); kwait > wait {
+		wait = kwait
+	}
+	if now.Add(wait).After(deadline) {
+		d.expireLocked()
+		d.doneLocked(target, q)
+		d.lock.Unlock()
+		return nil, fmt.Errorf("action %s of %s timeout for rate limit", kind, target)
+	}
+	d.global.take()
+	kb.take()
+	if wait > 0 {
+		d.throttled++
+	}
+	d.lock.Unlock()
+
+	if wait > 0 {
+		glog.V(7).Infof("Action %s of %s throttled for %v", kind, target, wait)
+		time.Sleep(wait)
+	}
+	resp, err := job(time.Until(deadline))
+
+	d.lock.Lock()
+	d.executed++
+	d.doneLocked(target, q)
+	d.lock.Unlock()
+	return resp, err
+}
+
+func (d *ActionDispatcher) depthLocked() int {
+	depth := 0
+	for _, q := range d.queues {
+		if q.pending != nil {
+			depth++
+		}
+	}
+	return depth
+}
+
+func (d *ActionDispatcher) expireLocked() {
+	d.expired++
+	if d.expired&(d.expired-1) == 0 {
+		glog.Warningf("Action dispatcher dropped %d actions for timeout so far, queue depth %d",
+			d.expired, d.depthLocked())
+	}
+}
+
+// doneLocked finishes the running action of target, and hands over to the
+// pending one if any.
+func (d *ActionDispatcher) doneLocked(target string, q *actionQueue) {
+	q.running = false
+	if next := q.pending; next != nil {
+		q.pending = nil
+		q.running = true
+		close(next.turn)
+		return
+	}
+	delete(d.queues, target)
+}
@@ -0,0 +1,323 @@
+// /*
+// Copyright 2025 IQiYi Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// */
+
+package manager
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/types"
+)
+
+// waitDispatched waits until `n` actions have been dispatched to `d`.
+func waitDispatched(t *testing.T, d *ActionDispatcher, n uint64) {
+	t.Helper()
+	for i := 0; i < 1000; i++ {
+		if d.Stats().Dispatched >= n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("%d actions not dispatched in time: %v", n, d.Stats())
+}
+
+func TestDispatcherRateLimit(t *testing.T) {
+	const flips = 1000
+	limit := types.RateLimit{Rate: 2000, Burst: 100}
+	d := NewActionDispatcher(limit, nil)
+
+	var lock sync.Mutex
+	stamps := make([]time.Time, 0, flips)
+	job := func(timeout time.Duration) (interface{}, error) {
+		lock.Lock()
+		stamps = append(stamps, time.Now())
+		lock.Unlock()
+		return nil, nil
+	}
+
+	start := time.Now()
+	wg := &sync.WaitGroup{}
+	for i := 0; i < flips; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if _, err := d.Dispatch(fmt.Sprintf("va-%d", i), "Blank", 10*time.Second, job); err != nil {
+				t.Errorf("action %d failed: %v", i, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if len(stamps) != flips {
+		t.Fatalf("expected %d actions executed, got %d", flips, len(stamps))
+	}
+	sort.Slice(stamps, func(i, j int) bool { return stamps[i].Before(stamps[j]) })
+	for i, stamp := range stamps {
+		allowed := float64(limit.Burst) + limit.Rate*stamp.Sub(start).Seconds() + 1
+		if float64(i+1) > allowed {
+			t.Fatalf("rate limit exceeded: %d actions executed in %v", i+1, stamp.Sub(start))
+		}
+	}
+	stats := d.Stats()
+	if stats.Executed != flips || stats.Throttled == 0 || stats.Coalesced != 0 || stats.Expired != 0 {
+		t.Errorf("unexpected stats: %v", stats)
+	}
+	if stats.Pending != 0 || stats.Running != 0 {
+		t.Errorf("queues not drained: %v", stats)
+	}
+}
+
+func TestDispatcherTypeRateLimit(t *testing.T) {
+	d := NewActionDispatcher(types.RateLimit{}, map[string]types.RateLimit{
+		"Slow": {Rate: 100, Burst: 10},
+	})
+
+	var lock sync.Mutex
+	executed := make(map[string]int)
+	job := func(kind string) ActionJob {
+		return func(timeout time.Duration) (interface{}, error) {
+			lock.Lock()
+			executed[kind]++
+			lock.Unlock()
+			return nil, nil
+		}
+	}
+
+	start := time.Now()
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 100; i++ {
+		for _, kind := range []string{"Slow", "Fast"} {
+			wg.Add(1)
+			go func(target, kind string) {
+				defer wg.Done()
+				d.Dispatch(target, kind, 200*time.Millisecond, job(kind))
+			}(fmt.Sprintf("%s-%d", kind, i), kind)
+		}
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	if executed["Fast"] != 100 {
+		t.Errorf("expected all unlimited actions executed, got %d", executed["Fast"])
+	}
+	allowed := 10 + int(100*elapsed.Seconds()) + 1
+	if executed["Slow"] > allowed || executed["Slow"] < 20 {
+		t.Errorf("expected limited actions executed in [20, %d], got %d", allowed, executed["Slow"])
+	}
+	if stats := d.Stats(); stats.Expired != uint64(100-executed["Slow"]) {
+		t.Errorf("expected %d expired actions, got stats: %v", 100-executed["Slow"], stats)
+	}
+}
+
+func TestDispatcherCoalescing(t *testing.T) {
+	const targets, flips = 10, 100 // 1000 state flips in total
+	d := NewActionDispatcher(types.RateLimit{}, nil)
+
+	var lock sync.Mutex
+	executed := make(map[string][]int)
+	release := make(chan struct{})
+	job := func(target string, seq int) ActionJob {
+		return func(timeout time.Duration) (interface{}, error) {
+			if seq == 0 {
+				<-release // hold the target busy until all flips queued
+			}
+			lock.Lock()
+			executed[target] = append(executed[target], seq)
+			lock.Unlock()
+			return seq, nil
+		}
+	}
+
+	wg := &sync.WaitGroup{}
+	results := make([][]error, targets)
+	var dispatched uint64
+	for seq := 0; seq < flips; seq++ {
+		for i := 0; i < targets; i++ {
+			if seq == 0 {
+				results[i] = make([]error, flips)
+			}
+			wg.Add(1)
+			go func(i, seq int) {
+				defer wg.Done()
+				target := fmt.Sprintf("va-%d", i)
+				resp, err := d.Dispatch(target, "Blank", 10*time.Second, job(target, seq))
+				if err == nil && resp.(int) != seq {
+					err = fmt.Errorf("got response of action %v", resp)
+				}
+				results[i][seq] = err
+			}(i, seq)
+			dispatched++
+			waitDispatched(t, d, dispatched)
+		}
+	}
+	if stats := d.Stats(); stats.Running != targets || stats.Pending != targets {
+		t.Errorf("expected %d running and %d pending actions, got stats: %v", targets, targets, stats)
+	}
+	close(release)
+	wg.Wait()
+
+	// The flip storm queued behind the running action of a target results in
+	// one call only, which is the latest flip.
+	for i := 0; i < targets; i++ {
+		target := fmt.Sprintf("va-%d", i)
+		if seqs := executed[target]; len(seqs) != 2 || seqs[0] != 0 || seqs[1] != flips-1 {
+			t.Errorf("expected actions [0 %d] executed in order for %s, got %v", flips-1, target, seqs)
+		}
+		for seq, err := range results[i] {
+			if seq == 0 || seq == flips-1 {
+				if err != nil {
+					t.Errorf("action %d of %s failed: %v", seq, target, err)
+				}
+			} else if err != ErrActionCoalesced {
+				t.Errorf("expected action %d of %s coalesced, got %v", seq, target, err)
+			}
+		}
+	}
+	stats := d.Stats()
+	if stats.Executed != 2*targets || stats.Coalesced != targets*(flips-2) || stats.Expired != 0 {
+		t.Errorf("unexpected stats: %v", stats)
+	}
+}
+
+func TestDispatcherMergeChanges(t *testing.T) {
+	const flips = 100
+	d := NewActionDispatcher(types.RateLimit{}, nil)
+
+	var lock sync.Mutex
+	var calls [][]string
+	release := make(chan struct{})
+	job := func(seq int) ChangeJob {
+		return func(changes []string, timeout time.Duration) (interface{}, error) {
+			if seq == 0 {
+				<-release // hold the target busy until all flips queued
+			}
+			lock.Lock()
+			calls = append(calls, append([]string(nil), changes...))
+			lock.Unlock()
+			return nil, nil
+		}
+	}
+
+	wg := &sync.WaitGroup{}
+	errs := make([]error, flips)
+	for seq := 0; seq < flips; seq++ {
+		wg.Add(1)
+		go func(seq int) {
+			defer wg.Done()
+			// backend flips of a VS, some backends flip more than once
+			changes := []string{fmt.Sprintf("rs-%d", seq%30)}
+			_, errs[seq] = d.DispatchChanges("vs-0", "Blank", 10*time.Second, changes, job(seq))
+		}(seq)
+		waitDispatched(t, d, uint64(seq+1))
+	}
+	close(release)
+	wg.Wait()
+
+	for seq, err := range errs {
+		if seq == 0 || seq == flips-1 {
+			if err != nil {
+				t.Errorf("action %d failed: %v", seq, err)
+			}
+		} else if err != ErrActionCoalesced {
+			t.Errorf("expected action %d coalesced, got %v", seq, err)
+		}
+	}
+	if len(calls) != 2 {
+		t.Fatalf("expected 2 calls, got %d: %v", len(calls), calls)
+	}
+	// The coalesced changes are merged into the latest action, in order and
+	// without duplicates.
+	merged := calls[1]
+	if len(merged) != 30 {
+		t.Errorf("expected 30 changed backends merged, got %d: %v", len(merged), merged)
+	}
+	sorted := append([]string(nil), merged...)
+	sort.Strings(sorted)
+	for i := 1; i < len(sorted); i++ {
+		if sorted[i] == sorted[i-1] {
+			t.Errorf("duplicated change %s in %v", sorted[i], merged)
+		}
+	}
+	if merged[0] != "rs-1" || merged[len(merged)-1] != "rs-0" {
+		t.Errorf("unexpected merged changes order: %v", merged)
+	}
+	if stats := d.Stats(); stats.Executed != 2 || stats.Coalesced != flips-2 {
+		t.Errorf("unexpected stats: %v", stats)
+	}
+}
+
+func TestDispatcherTimeoutBudget(t *testing.T) {
+	d := NewActionDispatcher(types.RateLimit{Rate: 1, Burst: 1}, nil)
+
+	var budget time.Duration
+	slow := func(timeout time.Duration) (interface{}, error) {
+		budget = timeout
+		time.Sleep(100 * time.Millisecond)
+		return nil, nil
+	}
+	never := func(timeout time.Duration) (interface{}, error) {
+		t.Errorf("expired action executed")
+		return nil, nil
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if _, err := d.Dispatch("va", "Blank", time.Second, slow); err != nil {
+			t.Errorf("action failed: %v", err)
+		}
+	}()
+	waitDispatched(t, d, 1)
+
+	// queued behind the running action longer than its budget
+	start := time.Now()
+	if _, err := d.Dispatch("va", "Blank", 30*time.Millisecond, never); err == nil {
+		t.Errorf("expected queued action timeout")
+	} else if elapsed := time.Since(start); elapsed > 90*time.Millisecond {
+		t.Errorf("queued action returned too late: %v", elapsed)
+	}
+	<-done
+	if budget <= 900*time.Millisecond || budget > time.Second {
+		t.Errorf("expected job called with the remaining budget, got %v", budget)
+	}
+
+	// no token available within the budget
+	start = time.Now()
+	if _, err := d.Dispatch("va2", "Blank", 200*time.Millisecond, never); err == nil {
+		t.Errorf("expected rate limited action timeout")
+	} else if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+		t.Errorf("rate limited action should fail immediately, took %v", elapsed)
+	}
+
+	stats := d.Stats()
+	if stats.Executed != 1 || stats.Expired != 2 || stats.Pending != 0 || stats.Running != 0 {
+		t.Errorf("unexpected stats: %v", stats)
+	}
+}
+
+func TestDispatcherNil(t *testing.T) {
+	var d *ActionDispatcher
+	resp, err := d.Dispatch("va", "Blank", time.Second, func(timeout time.Duration) (interface{}, error) {
+		return timeout, nil
+	})
+	if err != nil || resp.(time.Duration) != time.Second {
+		t.Errorf("unexpected result of nil dispatcher: %v, %v", resp, err)
+	}
+}
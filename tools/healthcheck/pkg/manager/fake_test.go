@@ -0,0 +1,109 @@
+// /*
+// Copyright 2025 IQiYi Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// */
+
+package manager
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/actioner"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/checker"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/comm"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/types"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/utils"
+)
+
+func TestFakeCheckerActioner(t *testing.T) {
+	checker.RegisterScriptedChecker(checker.NewScriptedChecker(nil))
+	recorder := actioner.NewRecordingAction()
+	actioner.RegisterRecordingAction(recorder)
+
+	svc := comm.VirtualServer{
+		Version:   1,
+		Addr:      utils.L3L4Addr{IP: net.ParseIP("192.168.100.1"), Port: 80, Proto: utils.IPProtoTCP},
+		DestCheck: checker.CheckMethodNone,
+		RSs: []comm.RealServer{{
+			Addr:   utils.L3L4Addr{IP: net.ParseIP("127.0.0.1"), Port: 80, Proto: utils.IPProtoTCP},
+			Weight: 100,
+		}},
+	}
+	env := newReloadEnv(t, []comm.VirtualServer{svc})
+	env.start(`
+virtual-addresses:
+  192.168.100.1:
+    actioner: Recording
+virtual-servers:
+  192.168.100.1-TCP-80:
+    method: 10001
+    method-params:
+      states: up,up,down,down,down,up
+    down-retry: 1
+    up-retry: 0
+    actioner: Recording
+`)
+	var calls []actioner.ActionCall
+	for i := 0; i < 300; i++ {
+		if calls = recorder.Calls(); len(calls) >= 6 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	env.stop()
+
+	// VA goes up once the VS is added. Backend goes down after 2 failures with
+	// down-retry 1, and goes up after the first success with up-retry 0. VA
+	// follows the only VS.
+	expects := []struct {
+		target    string
+		signal    types.State
+		inhibited bool // for VS actions only
+	}{
+		{"192.168.100.1-IPProto(0)-0", types.Healthy, false},
+		{"192.168.100.1-TCP-80", types.Unknown, false},
+		{"192.168.100.1-TCP-80", types.Unknown, true},
+		{"192.168.100.1-IPProto(0)-0", types.Unhealthy, false},
+		{"192.168.100.1-TCP-80", types.Unknown, false},
+		{"192.168.100.1-IPProto(0)-0", types.Healthy, false},
+	}
+	if len(calls) != len(expects) {
+		t.Fatalf("expect %d actions, got %d: %v", len(expects), len(calls), calls)
+	}
+	for i, expect := range expects {
+		call := calls[i]
+		if call.Target.String() != expect.target || call.Signal != expect.signal {
+			t.Errorf("action %d: expect %s %v, got %v %v", i, expect.target, expect.signal,
+				call.Target, call.Signal)
+			continue
+		}
+		if call.Timeout <= 0 || call.Timeout > time.Second*2 {
+			t.Errorf("action %d: unexpected timeout %v", i, call.Timeout)
+		}
+		if call.Target.Proto == 0 {
+			continue
+		}
+		vs, ok := call.Data[0].(*comm.VirtualServer)
+		if !ok || len(vs.RSs) != 1 || vs.RSs[0].Inhibited != expect.inhibited {
+			t.Errorf("action %d: unexpected data %v", i, call.Data)
+		}
+	}
+	// The VA down action tells the failure.
+	if detail, ok := calls[3].Data[0].(*actioner.ActionDetail); !ok || detail.Method != "scripted" ||
+		detail.Target.String() != "127.0.0.1-TCP-80" {
+		t.Errorf("unexpected VA down action data: %v", calls[3].Data)
+	}
+}
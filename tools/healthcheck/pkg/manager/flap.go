@@ -0,0 +1,97 @@
+// /*
+// Copyright 2025 IQiYi Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// */
+
+package manager
+
+import (
+	"time"
+
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/types"
+)
+
+// flapDetector detects the flapping of a backend by counting the transitions
+// of its noticed state within a sliding window of `flap-window`. At most
+// `flap-threshold`+1 transitions are kept, so its memory is bounded.
+//
+// flapDetector is not thread-safe, and should be accessed only in checker's loop.
+type flapDetector struct {
+	conf        FlapConf
+	last        types.State // last noticed state
+	transitions []time.Time // in chronological order, within the window
+	until       time.Time   // end of the hold-down, zero if not flapping
+}
+
+func newFlapDetector(conf *FlapConf) *flapDetector {
+	return &flapDetector{conf: *conf}
+}
+
+func (d *flapDetector) enabled() bool {
+	return d.conf.FlapThreshold > 0
+}
+
+// setConf updates the FlapConf, which takes effect on the next transition.
+func (d *flapDetector) setConf(conf *FlapConf) {
+	d.conf = *conf
+	if !d.enabled() {
+		d.transitions = nil
+	}
+}
+
+// flapping tells if the backend is held down at `now`.
+func (d *flapDetector) flapping(now time.Time) bool {
+	return !d.until.IsZero() && now.Before(d.until)
+}
+
+// expire drops the transitions out of the window ending at `now`.
+func (d *flapDetector) expire(now time.Time) {
+	i := 0
+	for i < len(d.transitions) && now.Sub(d.transitions[i]) > d.conf.FlapWindow {
+		i++
+	}
+	if i > 0 {
+		d.transitions = append(d.transitions[:0], d.transitions[i:]...)
+	}
+}
+
+// count returns the number of transitions within the window ending at `now`.
+func (d *flapDetector) count(now time.Time) int {
+	d.expire(now)
+	return len(d.transitions)
+}
+
+// notice records the noticed state at `now`, and returns true if the backend
+// starts flapping, whose hold-down ends at `until`.
+func (d *flapDetector) notice(state types.State, now time.Time) bool {
+	last := d.last
+	d.last = state
+	if !d.enabled() || last == types.Unknown || state == last || d.flapping(now) {
+		return false
+	}
+
+	d.expire(now)
+	d.transitions = append(d.transitions, now)
+	if uint(len(d.transitions)) <= d.conf.FlapThreshold {
+		return false
+	}
+	d.until = now.Add(d.conf.FlapHolddown)
+	return true
+}
+
+// release ends the hold-down, and the transitions before are forgotten.
+func (d *flapDetector) release() {
+	d.until = time.Time{}
+	d.transitions = nil
+}
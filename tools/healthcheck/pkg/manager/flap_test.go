@@ -0,0 +1,261 @@
+// /*
+// Copyright 2025 IQiYi Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// */
+
+package manager
+
+import (
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/checker"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/comm"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/types"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/utils"
+)
+
+// replayFlaps feeds the detector with alternating states every `interval` from
+// `start`, and returns the index of the state starting flapping, or -1.
+func replayFlaps(d *flapDetector, start time.Time, interval time.Duration, n int) int {
+	state := types.Healthy
+	for i := 0; i < n; i++ {
+		if d.notice(state, start.Add(time.Duration(i)*interval)) {
+			return i
+		}
+		if state == types.Healthy {
+			state = types.Unhealthy
+		} else {
+			state = types.Healthy
+		}
+	}
+	return -1
+}
+
+func TestFlapDetector(t *testing.T) {
+	conf := FlapConf{
+		FlapThreshold: 6,
+		FlapWindow:    10 * time.Minute,
+		FlapHolddown:  10 * time.Minute,
+		FlapPolicy:    FlapPolicyUnhealthy,
+	}
+	start := time.Now()
+
+	for _, tc := range []struct {
+		name     string
+		interval time.Duration
+		n        int
+		expect   int
+	}{
+		// The first notice is not a transition, and the 7th transition at
+		// 7 minutes exceeds the threshold.
+		{"flapping", time.Minute, 100, 7},
+		{"threshold reached only", time.Minute, 7, -1},
+		{"transitions out of window", 2 * time.Minute, 1000, -1},
+		{"sparse transitions", 30 * time.Minute, 1000, -1},
+		{"fast flapping", time.Second, 100, 7},
+	} {
+		d := newFlapDetector(&conf)
+		if got := replayFlaps(d, start, tc.interval, tc.n); got != tc.expect {
+			t.Errorf("%s: expect flapping at %d, got %d", tc.name, tc.expect, got)
+		}
+		if len(d.transitions) > int(conf.FlapThreshold)+1 {
+			t.Errorf("%s: transitions unbounded: %d", tc.name, len(d.transitions))
+		}
+	}
+
+	// repeated states are not transitions
+	d := newFlapDetector(&conf)
+	for i := 0; i < 100; i++ {
+		if d.notice(types.Healthy, start.Add(time.Duration(i)*time.Second)) {
+			t.Fatalf("repeated state detected as flapping")
+		}
+	}
+	if n := d.count(start.Add(100 * time.Second)); n != 0 {
+		t.Errorf("expect no transitions, got %d", n)
+	}
+
+	// hold-down entry and exit
+	at := replayFlaps(d, start, time.Second, 100)
+	now := start.Add(time.Duration(at) * time.Second)
+	if at < 0 || !d.flapping(now) || !d.until.Equal(now.Add(conf.FlapHolddown)) {
+		t.Fatalf("expect flapping until %v, got %d, %v", now.Add(conf.FlapHolddown), at, d.until)
+	}
+	if d.notice(types.Healthy, now.Add(time.Second)) || d.notice(types.Unhealthy, now.Add(2*time.Second)) {
+		t.Errorf("expect no flapping detected again in hold-down")
+	}
+	if d.flapping(now.Add(conf.FlapHolddown)) {
+		t.Errorf("expect hold-down ended")
+	}
+	d.release()
+	if d.flapping(now) || d.count(now) != 0 {
+		t.Errorf("expect transitions forgotten after released")
+	}
+	// The last noticed state is Unhealthy, so the first Healthy is a transition.
+	if got := replayFlaps(d, now.Add(conf.FlapHolddown), time.Minute, 100); got != 6 {
+		t.Errorf("expect flapping again at 6 after released, got %d", got)
+	}
+
+	// disabled
+	d = newFlapDetector(&FlapConf{})
+	if got := replayFlaps(d, start, time.Second, 1000); got != -1 || len(d.transitions) != 0 {
+		t.Errorf("expect disabled detector never flapping, got %d", got)
+	}
+}
+
+func newTestFlapChecker(t *testing.T, conf *FlapConf) *Checker {
+	svc := &comm.VirtualServer{
+		Version:   1,
+		Addr:      utils.L3L4Addr{IP: net.ParseIP("192.168.100.1"), Port: 80, Proto: utils.IPProtoTCP},
+		DestCheck: checker.CheckMethodNone,
+	}
+	_, vs := newTestVS(t, svc, &vsConfDefault.QuorumConf)
+	ckConf := vs.conf.GetCheckerConf().DeepCopy()
+	ckConf.DownRetry = 0
+	ckConf.UpRetry = 0
+	ckConf.FlapConf = *conf
+
+	target := utils.L3L4Addr{IP: net.ParseIP("192.168.200.1"), Port: 8080, Proto: utils.IPProtoTCP}
+	ck, err := NewChecker(&target, ckConf, vs)
+	if err != nil {
+		t.Fatalf("failed to create checker: %v", err)
+	}
+	return ck
+}
+
+// pendingNotices returns the state notices sent from checker to VS.
+func pendingNotices(ck *Checker) []types.State {
+	var states []types.State
+	for {
+		select {
+		case notice := <-ck.vs.notify:
+			states = append(states, notice.state)
+		default:
+			return states
+		}
+	}
+}
+
+func TestCheckerFlap(t *testing.T) {
+	for _, policy := range []string{FlapPolicyUnhealthy, FlapPolicyHold} {
+		ck := newTestFlapChecker(t, &FlapConf{
+			FlapThreshold: 3,
+			FlapWindow:    time.Minute,
+			FlapHolddown:  time.Hour,
+			FlapPolicy:    policy,
+		})
+		check := func(state types.State) {
+			ck.doCheckResult(&checkResult{state: state, timeout: time.Second})
+		}
+
+		// H, U, H, U: 3 transitions reach the threshold only.
+		for _, state := range []types.State{types.Healthy, types.Unhealthy, types.Healthy, types.Unhealthy} {
+			check(state)
+		}
+		if notices := pendingNotices(ck); len(notices) != 4 || ck.flapTimer != nil {
+			t.Fatalf("%s: expect 4 notices without flapping, got %v", policy, notices)
+		}
+
+		// The 4th transition starts flapping.
+		check(types.Healthy)
+		expect := types.Healthy
+		if policy == FlapPolicyUnhealthy {
+			expect = types.Unhealthy
+		}
+		if notices := pendingNotices(ck); len(notices) != 1 || notices[0] != expect {
+			t.Errorf("%s: expect %v notice on flapping, got %v", policy, expect, notices)
+		}
+		if ck.flapTimer == nil || ck.state != expect {
+			t.Fatalf("%s: expect held down in %v, got %v", policy, expect, ck.state)
+		}
+		info := ck.targetInfo()
+		if info.Flap == nil || info.Flap.Holddown == nil || info.Flap.Transitions != 4 {
+			t.Errorf("%s: unexpected flap info %+v", policy, info.Flap)
+		}
+
+		// Check results are ignored in hold-down, but still recorded.
+		for i := 0; i < 10; i++ {
+			check(types.Healthy)
+			check(types.Unhealthy)
+		}
+		if notices := pendingNotices(ck); len(notices) != 0 || ck.state != expect {
+			t.Errorf("%s: expect no notices in hold-down, got %v in %v", policy, notices, ck.state)
+		}
+		if sum := ck.history.summary(); sum.Records != 25 || sum.Transitions != 23 {
+			t.Errorf("%s: unexpected history summary %+v", policy, sum)
+		}
+
+		// Check results take effect again after hold-down.
+		ck.doFlapRelease()
+		if ck.flapTimer != nil || ck.targetInfo().Flap.Holddown != nil {
+			t.Errorf("%s: expect hold-down released", policy)
+		}
+		check(types.Unhealthy)
+		check(types.Healthy)
+		expects := []types.State{types.Unhealthy, types.Healthy}
+		if policy == FlapPolicyUnhealthy {
+			// Unhealthy has been noticed in hold-down.
+			expects = expects[1:]
+		}
+		if notices := pendingNotices(ck); !reflect.DeepEqual(notices, expects) {
+			t.Errorf("%s: expect notices %v after released, got %v", policy, expects, notices)
+		}
+	}
+}
+
+func TestCheckerFlapDisabled(t *testing.T) {
+	ck := newTestFlapChecker(t, &vsConfDefault.FlapConf)
+	state, notices := types.Healthy, 0
+	for i := 0; i < 200; i++ {
+		ck.doCheckResult(&checkResult{state: state, timeout: time.Second})
+		notices += len(pendingNotices(ck))
+		if state == types.Healthy {
+			state = types.Unhealthy
+		} else {
+			state = types.Healthy
+		}
+	}
+	if notices != 200 || ck.flapTimer != nil || ck.targetInfo().Flap != nil {
+		t.Errorf("expect flap detection disabled by default, got %d notices", notices)
+	}
+	if sum := ck.history.summary(); uint(sum.Records) != types.DefaultAppConf.CheckHistory {
+		t.Errorf("expect %d history records, got %d", types.DefaultAppConf.CheckHistory, sum.Records)
+	}
+}
+
+func TestFlapConf(t *testing.T) {
+	for _, tc := range []struct {
+		conf  FlapConf
+		valid bool
+	}{
+		{FlapConf{}, true},
+		{vsConfDefault.FlapConf, true},
+		{FlapConf{6, 10 * time.Minute, 10 * time.Minute, FlapPolicyHold}, true},
+		{FlapConf{6, 0, 10 * time.Minute, FlapPolicyUnhealthy}, false},
+		{FlapConf{6, 10 * time.Minute, 0, FlapPolicyUnhealthy}, false},
+		{FlapConf{6, 10 * time.Minute, 10 * time.Minute, "healthy"}, false},
+	} {
+		if err := tc.conf.Valid(); (err == nil) != tc.valid {
+			t.Errorf("expect %+v valid %v, got %v", tc.conf, tc.valid, err)
+		}
+	}
+
+	conf := FlapConf{FlapThreshold: 6}
+	conf.MergeDefault(&vsConfDefault.FlapConf)
+	if conf != (FlapConf{6, 10 * time.Minute, 10 * time.Minute, FlapPolicyUnhealthy}) {
+		t.Errorf("unexpected merged conf: %+v", conf)
+	}
+}
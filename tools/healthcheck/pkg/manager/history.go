@@ -0,0 +1,143 @@
+// /*
+// Copyright 2025 IQiYi Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// */
+
+package manager
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/checker"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/types"
+)
+
+// checkRecord is a check result kept in checkHistory. It's packed into 16 bytes
+// to bound the memory with tens of thousands of targets.
+type checkRecord struct {
+	at      int64  // unix time in nanoseconds
+	latency uint32 // in microseconds, saturated
+	reason  checker.Reason
+	state   uint8
+}
+
+// checkHistory is a ring buffer of the recent check results of a target. It's
+// written in the checker's loop, and read by admin API concurrently.
+type checkHistory struct {
+	lock    sync.Mutex
+	size    int
+	records []checkRecord // grows up to size, and then is reused circularly
+	next    int           // index of the oldest record once records is full
+}
+
+// newCheckHistory returns a checkHistory keeping the last `size` results, or
+// nil if `size` is zero.
+func newCheckHistory(size uint) *checkHistory {
+	if size == 0 {
+		return nil
+	}
+	return &checkHistory{size: int(size)}
+}
+
+func (h *checkHistory) add(at time.Time, state types.State, reason checker.Reason, latency time.Duration) {
+	if h == nil {
+		return
+	}
+	us := latency.Microseconds()
+	if us > math.MaxUint32 {
+		us = math.MaxUint32
+	} else if us < 0 {
+		us = 0
+	}
+	rec := checkRecord{
+		at:      at.UnixNano(),
+		latency: uint32(us),
+		reason:  reason,
+		state:   uint8(state),
+	}
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if len(h.records) < h.size {
+		h.records = append(h.records, rec)
+		return
+	}
+	h.records[h.next] = rec
+	h.next = (h.next + 1) % h.size
+}
+
+// list returns the records in chronological order.
+func (h *checkHistory) list() []CheckRecord {
+	if h == nil {
+		return nil
+	}
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	res := make([]CheckRecord, 0, len(h.records))
+	for i := range h.records {
+		rec := &h.records[(h.next+i)%len(h.records)]
+		info := CheckRecord{
+			Time:    time.Unix(0, rec.at),
+			State:   types.State(rec.state).String(),
+			Latency: (time.Duration(rec.latency) * time.Microsecond).String(),
+		}
+		if rec.reason != checker.ReasonNone {
+			info.Reason = rec.reason.String()
+		}
+		res = append(res, info)
+	}
+	return res
+}
+
+// summary returns the statistics of the records.
+func (h *checkHistory) summary() *HistorySummary {
+	if h == nil {
+		return nil
+	}
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	sum := &HistorySummary{Records: len(h.records)}
+	if len(h.records) == 0 {
+		return sum
+	}
+	var latency time.Duration
+	last := types.Unknown
+	for i := range h.records {
+		rec := &h.records[(h.next+i)%len(h.records)]
+		state := types.State(rec.state)
+		switch state {
+		case types.Healthy:
+			sum.Healthy++
+		case types.Unhealthy:
+			sum.Unhealthy++
+		default:
+			sum.Unknown++
+		}
+		if state != types.Unknown {
+			if last != types.Unknown && state != last {
+				sum.Transitions++
+			}
+			last = state
+		}
+		latency += time.Duration(rec.latency) * time.Microsecond
+	}
+	since := time.Unix(0, h.records[h.next].at)
+	sum.Since = &since
+	sum.AvgLatency = (latency / time.Duration(len(h.records))).String()
+	return sum
+}
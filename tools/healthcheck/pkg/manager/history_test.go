@@ -0,0 +1,79 @@
+// /*
+// Copyright 2025 IQiYi Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// */
+
+package manager
+
+import (
+	"testing"
+	"time"
+	"unsafe"
+
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/checker"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/types"
+)
+
+func TestCheckHistory(t *testing.T) {
+	if size := unsafe.Sizeof(checkRecord{}); size != 16 {
+		t.Errorf("expect checkRecord of 16 bytes, got %d", size)
+	}
+
+	var nilHist *checkHistory = newCheckHistory(0)
+	nilHist.add(time.Now(), types.Healthy, checker.ReasonNone, time.Millisecond)
+	if nilHist.list() != nil || nilHist.summary() != nil {
+		t.Errorf("expect disabled history empty")
+	}
+
+	h := newCheckHistory(3)
+	if sum := h.summary(); sum.Records != 0 || sum.Since != nil {
+		t.Errorf("unexpected summary of empty history: %+v", sum)
+	}
+	start := time.Unix(1700000000, 0)
+	for i, rec := range []struct {
+		state   types.State
+		reason  checker.Reason
+		latency time.Duration
+	}{
+		{types.Healthy, checker.ReasonNone, time.Millisecond},
+		{types.Unhealthy, checker.ReasonConnRefused, 2 * time.Millisecond},
+		{types.Unknown, checker.ReasonUnknown, 3 * time.Millisecond},
+		{types.Healthy, checker.ReasonNone, 4 * time.Millisecond},
+		{types.Unhealthy, checker.ReasonTimeout, 100 * time.Hour},
+	} {
+		h.add(start.Add(time.Duration(i)*time.Second), rec.state, rec.reason, rec.latency)
+	}
+
+	records := h.list()
+	expects := []CheckRecord{
+		{start.Add(2 * time.Second), "Unknown", "unknown", "3ms"},
+		{start.Add(3 * time.Second), "Healthy", "", "4ms"},
+		{start.Add(4 * time.Second), "Unhealthy", "timeout", "1h11m34.967295s"}, // saturated
+	}
+	if len(records) != len(expects) {
+		t.Fatalf("expect %d records, got %v", len(expects), records)
+	}
+	for i := range expects {
+		if !records[i].Time.Equal(expects[i].Time) || records[i].State != expects[i].State ||
+			records[i].Reason != expects[i].Reason || records[i].Latency != expects[i].Latency {
+			t.Errorf("record %d: expect %+v, got %+v", i, expects[i], records[i])
+		}
+	}
+
+	sum := h.summary()
+	if sum.Records != 3 || sum.Healthy != 1 || sum.Unhealthy != 1 || sum.Unknown != 1 ||
+		sum.Transitions != 1 || sum.Since == nil || !sum.Since.Equal(start.Add(2*time.Second)) {
+		t.Errorf("unexpected summary: %+v", sum)
+	}
+}
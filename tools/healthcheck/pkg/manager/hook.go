@@ -0,0 +1,138 @@
+// /*
+// Copyright 2025 IQiYi Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// */
+
+package manager
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/golang/glog"
+
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/types"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/utils"
+)
+
+// StateHook is a callback fired when a backend transits between Healthy and
+// Unhealthy. It's called asynchronously in a single goroutine shared by all
+// hooks, in the order of the transitions, so it must not block for long.
+type StateHook func(target *utils.L3L4Addr, old, new types.State)
+
+// stateHookQueueSize bounds the transitions pending for the hooks. Transitions
+// are dropped rather than blocking the VS loop when the hooks fall behind.
+const stateHookQueueSize = 4096
+
+type stateEvent struct {
+	target   utils.L3L4Addr
+	old, new types.State
+}
+
+var stateHooks = struct {
+	lock  sync.RWMutex
+	names []string // sorted, hooks are called in the order of their names
+	hooks map[string]StateHook
+	queue chan stateEvent
+	once  sync.Once
+}{
+	hooks: make(map[string]StateHook),
+	queue: make(chan stateEvent, stateHookQueueSize),
+}
+
+// RegisterStateHook registers the hook by name, replacing any hook registered
+// with the same name. Registering a nil hook is the same as UnregisterStateHook.
+func RegisterStateHook(name string, hook StateHook) {
+	if hook == nil {
+		UnregisterStateHook(name)
+		return
+	}
+	stateHooks.once.Do(func() { go runStateHooks() })
+
+	stateHooks.lock.Lock()
+	defer stateHooks.lock.Unlock()
+	if _, ok := stateHooks.hooks[name]; !ok {
+		stateHooks.names = append(stateHooks.names, name)
+		sort.Strings(stateHooks.names)
+	}
+	stateHooks.hooks[name] = hook
+}
+
+// UnregisterStateHook removes the hook registered by name, if any.
+func UnregisterStateHook(name string) {
+	stateHooks.lock.Lock()
+	defer stateHooks.lock.Unlock()
+	if _, ok := stateHooks.hooks[name]; !ok {
+		return
+	}
+	delete(stateHooks.hooks, name)
+	for i, n := range stateHooks.names {
+		if n == name {
+			stateHooks.names = append(stateHooks.names[:i], stateHooks.names[i+1:]...)
+			break
+		}
+	}
+}
+
+// fireStateHooks queues a transition of target for the registered hooks. Only
+// transitions between Healthy and Unhealthy are fired, i.e. a backend's first
+// known state and changes into or out of Unknown are not transitions.
+func fireStateHooks(target *utils.L3L4Addr, old, new types.State) {
+	if old == new || old == types.Unknown || new == types.Unknown {
+		return
+	}
+	stateHooks.lock.RLock()
+	empty := len(stateHooks.hooks) == 0
+	stateHooks.lock.RUnlock()
+	if empty {
+		return
+	}
+
+	select {
+	case stateHooks.queue <- stateEvent{target: *target.DeepCopy(), old: old, new: new}:
+	default:
+		checkLogLimiter.Warningf("state hooks dropped", "State hooks fall behind, "+
+			"transition of %v from %v to %v dropped", target, old, new)
+	}
+}
+
+func runStateHooks() {
+	for ev := range stateHooks.queue {
+		stateHooks.lock.RLock()
+		names := append([]string(nil), stateHooks.names...)
+		hooks := make([]StateHook, len(names))
+		for i, name := range names {
+			hooks[i] = stateHooks.hooks[name]
+		}
+		stateHooks.lock.RUnlock()
+
+		for i, hook := range hooks {
+			if err := callStateHook(hook, &ev); err != nil {
+				glog.Errorf("State hook %q failed on %v %v->%v: %v", names[i], &ev.target, ev.old, ev.new, err)
+			}
+		}
+	}
+}
+
+func callStateHook(hook StateHook, ev *stateEvent) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	target := ev.target
+	hook(&target, ev.old, ev.new)
+	return nil
+}
@@ -0,0 +1,109 @@
+// /*
+// Copyright 2025 IQiYi Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// */
+
+package manager
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/checker"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/comm"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/types"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/utils"
+)
+
+func TestStateHooks(t *testing.T) {
+	svc := &comm.VirtualServer{
+		Version:   1,
+		Addr:      utils.L3L4Addr{IP: net.ParseIP("192.168.100.2"), Port: 80, Proto: utils.IPProtoTCP},
+		DestCheck: checker.CheckMethodNone,
+	}
+	var rss []comm.RealServer
+	for i := 1; i <= 2; i++ {
+		rss = append(rss, comm.RealServer{
+			Addr:   utils.L3L4Addr{IP: net.ParseIP(fmt.Sprintf("192.168.201.%d", i)), Port: 8080, Proto: utils.IPProtoTCP},
+			Weight: 100,
+		})
+	}
+	_, vs := newTestVS(t, svc, &QuorumConf{})
+	defer vs.cleanup()
+	svc.RSs = rss
+	vs.doUpdate(&VSConfExt{VSConf: *vs.conf.DeepCopy(), vs: *svc.DeepCopy()})
+
+	events := make(chan string, 16)
+	RegisterStateHook("test-b", func(target *utils.L3L4Addr, old, new types.State) {
+		events <- fmt.Sprintf("b %v %v->%v", target, old, new)
+	})
+	RegisterStateHook("test-a", func(target *utils.L3L4Addr, old, new types.State) {
+		events <- fmt.Sprintf("a %v %v->%v", target, old, new)
+	})
+	RegisterStateHook("test-panic", func(target *utils.L3L4Addr, old, new types.State) {
+		panic("hook panics")
+	})
+	defer UnregisterStateHook("test-a")
+	defer UnregisterStateHook("test-b")
+	defer UnregisterStateHook("test-panic")
+
+	notice := func(i int, state types.State) {
+		vs.recvNotice(&BackendState{id: CheckerID(rss[i].Addr.String()), state: state})
+	}
+	notice(0, types.Healthy)   // first known state, not a transition
+	notice(1, types.Unhealthy) // first known state, not a transition
+	notice(0, types.Healthy)   // no change
+	notice(0, types.Unhealthy)
+	notice(1, types.Healthy)
+	notice(1, types.Healthy) // no change
+
+	rs0, rs1 := rss[0].Addr.String(), rss[1].Addr.String()
+	expected := []string{
+		fmt.Sprintf("a %s Healthy->Unhealthy", rs0),
+		fmt.Sprintf("b %s Healthy->Unhealthy", rs0),
+		fmt.Sprintf("a %s Unhealthy->Healthy", rs1),
+		fmt.Sprintf("b %s Unhealthy->Healthy", rs1),
+	}
+	var got []string
+	for range expected {
+		select {
+		case ev := <-events:
+			got = append(got, ev)
+		case <-time.After(3 * time.Second):
+			t.Fatalf("state hooks not called, got %v", got)
+		}
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expect state hook calls %v, got %v", expected, got)
+	}
+
+	UnregisterStateHook("test-b")
+	notice(0, types.Healthy)
+	select {
+	case ev := <-events:
+		if want := fmt.Sprintf("a %s Unhealthy->Healthy", rs0); ev != want {
+			t.Errorf("expect state hook call %q, got %q", want, ev)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("state hook not called after unregistering another hook")
+	}
+	select {
+	case ev := <-events:
+		t.Errorf("unexpected state hook call %q", ev)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
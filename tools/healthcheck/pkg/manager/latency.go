@@ -0,0 +1,90 @@
+// /*
+// Copyright 2025 IQiYi Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// */
+
+package manager
+
+import (
+	"time"
+)
+
+// latencyTracker keeps the latencies of the last `latency-samples` successful
+// probes of a backend, and judges the backend by their moving average rather
+// than a single sample, so that a probe delayed occasionally, such as by a GC
+// pause, does not flap the backend.
+//
+// latencyTracker is not thread-safe, and should be accessed only in checker's loop.
+type latencyTracker struct {
+	conf     LatencyConf
+	samples  []time.Duration // grows up to latency-samples, and then is reused circularly
+	next     int             // index of the oldest sample once samples is full
+	degraded bool            // average latency exceeds degraded-latency
+}
+
+func newLatencyTracker(conf *LatencyConf) *latencyTracker {
+	return &latencyTracker{conf: *conf}
+}
+
+func (t *latencyTracker) enabled() bool {
+	return t.conf.enabled()
+}
+
+// setConf updates the LatencyConf, and the samples before are forgotten.
+func (t *latencyTracker) setConf(conf *LatencyConf) {
+	t.conf = *conf
+	t.samples = nil
+	t.next = 0
+	t.degraded = false
+}
+
+// average returns the moving average latency, or zero if no sample yet.
+func (t *latencyTracker) average() time.Duration {
+	if len(t.samples) == 0 {
+		return 0
+	}
+	var sum time.Duration
+	for _, sample := range t.samples {
+		sum += sample
+	}
+	return sum / time.Duration(len(t.samples))
+}
+
+// add records the latency of a successful probe and updates the degraded
+// status, and returns true if the average latency exceeds max-latency.
+func (t *latencyTracker) add(latency time.Duration) bool {
+	if !t.enabled() {
+		return false
+	}
+	if uint(len(t.samples)) < t.conf.LatencySamples {
+		t.samples = append(t.samples, latency)
+	} else {
+		t.samples[t.next] = latency
+		t.next = (t.next + 1) % len(t.samples)
+	}
+
+	avg := t.average()
+	t.degraded = t.conf.DegradedLatency > 0 && avg > t.conf.DegradedLatency
+	return t.conf.MaxLatency > 0 && avg > t.conf.MaxLatency
+}
+
+// degradedWeight returns `weight` reduced to `percent` of it, but no less than 1
+// unless `weight` is zero.
+func degradedWeight(weight uint, percent uint) uint {
+	reduced := weight * percent / 100
+	if reduced == 0 && weight > 0 {
+		reduced = 1
+	}
+	return reduced
+}
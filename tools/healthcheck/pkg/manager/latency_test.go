@@ -0,0 +1,277 @@
+// /*
+// Copyright 2025 IQiYi Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// */
+
+package manager
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/checker"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/comm"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/types"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/utils"
+)
+
+func TestLatencyTracker(t *testing.T) {
+	tracker := newLatencyTracker(&LatencyConf{
+		MaxLatency:            200 * time.Millisecond,
+		DegradedLatency:       50 * time.Millisecond,
+		DegradedWeightPercent: 50,
+		LatencySamples:        3,
+	})
+	ms := time.Millisecond
+
+	steps := []struct {
+		latency  time.Duration
+		slow     bool
+		degraded bool
+		average  time.Duration
+	}{
+		{10 * ms, false, false, 10 * ms},
+		{10 * ms, false, false, 10 * ms},
+		{10 * ms, false, false, 10 * ms},
+		{400 * ms, false, true, 140 * ms}, // a single spike doesn't make it unhealthy
+		{10 * ms, false, true, 140 * ms},
+		{10 * ms, false, true, 140 * ms},
+		{10 * ms, false, false, 10 * ms}, // the spike is out of the window
+		{300 * ms, false, true, 320 * ms / 3},
+		{300 * ms, true, true, 610 * ms / 3},
+		{300 * ms, true, true, 300 * ms},
+		{0, false, true, 200 * ms},
+	}
+	for i, step := range steps {
+		slow := tracker.add(step.latency)
+		if slow != step.slow || tracker.degraded != step.degraded {
+			t.Errorf("step %d: expect slow %v degraded %v, got %v %v", i, step.slow, step.degraded,
+				slow, tracker.degraded)
+		}
+		if avg := tracker.average(); avg != step.average {
+			t.Errorf("step %d: expect average %v, got %v", i, step.average, avg)
+		}
+	}
+
+	tracker.setConf(&LatencyConf{LatencySamples: 3})
+	if tracker.enabled() || tracker.degraded || tracker.average() != 0 {
+		t.Errorf("expect latency tracker disabled and reset")
+	}
+	if tracker.add(time.Second) || tracker.average() != 0 {
+		t.Errorf("expect disabled latency tracker ignores samples")
+	}
+
+	for _, c := range []struct{ weight, percent, expect uint }{
+		{100, 50, 50}, {100, 100, 100}, {3, 10, 1}, {0, 50, 0},
+	} {
+		if w := degradedWeight(c.weight, c.percent); w != c.expect {
+			t.Errorf("degradedWeight(%d, %d): expect %d, got %d", c.weight, c.percent, c.expect, w)
+		}
+	}
+}
+
+func TestLatencyConfValid(t *testing.T) {
+	valid := func(lc LatencyConf, timeout time.Duration) bool {
+		conf := vsConfDefault.CheckerConf.DeepCopy()
+		conf.Method = checker.CheckMethodNone
+		conf.LatencyConf = lc
+		conf.Timeout = timeout
+		return conf.Valid() == nil
+	}
+	ms := time.Millisecond
+	for i, c := range []struct {
+		conf    LatencyConf
+		timeout time.Duration
+		valid   bool
+	}{
+		{LatencyConf{LatencySamples: 3}, time.Second, true},
+		{LatencyConf{MaxLatency: 500 * ms, LatencySamples: 3}, time.Second, true},
+		{LatencyConf{MaxLatency: time.Second, LatencySamples: 3}, time.Second, false},
+		{LatencyConf{MaxLatency: 2 * time.Second, LatencySamples: 3}, time.Second, false},
+		{LatencyConf{MaxLatency: -ms, LatencySamples: 3}, time.Second, false},
+		{LatencyConf{MaxLatency: 500 * ms}, time.Second, false},
+		{LatencyConf{DegradedLatency: 100 * ms, DegradedWeightPercent: 50, LatencySamples: 3}, time.Second, true},
+		{LatencyConf{DegradedLatency: 100 * ms, LatencySamples: 3}, time.Second, false},
+		{LatencyConf{DegradedLatency: 100 * ms, DegradedWeightPercent: 101, LatencySamples: 3}, time.Second, false},
+		{LatencyConf{DegradedLatency: time.Second, DegradedWeightPercent: 50, LatencySamples: 3}, time.Second, false},
+		{LatencyConf{MaxLatency: 500 * ms, DegradedLatency: 500 * ms, DegradedWeightPercent: 50,
+			LatencySamples: 3}, time.Second, false},
+		{LatencyConf{MaxLatency: 500 * ms, DegradedLatency: 100 * ms, DegradedWeightPercent: 50,
+			LatencySamples: 3}, time.Second, true},
+	} {
+		if v := valid(c.conf, c.timeout); v != c.valid {
+			t.Errorf("case %d: expect valid %v, got %v", i, c.valid, v)
+		}
+	}
+
+	vsConf := vsConfDefault.DeepCopy()
+	vsConf.Method = checker.CheckMethodNone
+	vsConf.MaxLatency = 500 * time.Millisecond
+	vsConf.RealServers = map[CheckerID]RSConf{
+		"192.168.88.30-TCP-80": {Timing: checker.Timing{Interval: time.Second, Timeout: 400 * ms}},
+	}
+	if err := vsConf.Valid(); err == nil {
+		t.Errorf("expect max-latency not less than the timeout of real server invalid")
+	}
+
+	// The max-latency method param of single probes must be less than timeout.
+	for i, c := range []struct {
+		maxLatency string
+		rsTimeout  time.Duration
+		valid      bool
+	}{
+		{"500ms", 0, true},
+		{"1s", 0, false},
+		{"2s", 0, false},
+		{"500ms", 400 * ms, false},
+		{"300ms", 400 * ms, true},
+	} {
+		vsConf := vsConfDefault.DeepCopy()
+		vsConf.Method = checker.CheckMethodTCP
+		vsConf.Timeout = time.Second
+		vsConf.MethodParams = map[string]string{checker.ParamMaxLatency: c.maxLatency}
+		if c.rsTimeout > 0 {
+			vsConf.RealServers = map[CheckerID]RSConf{
+				"192.168.88.30-TCP-80": {Timing: checker.Timing{Interval: time.Second, Timeout: c.rsTimeout}},
+			}
+		}
+		if err := vsConf.Valid(); (err == nil) != c.valid {
+			t.Errorf("method param case %d: expect valid %v, got %v", i, c.valid, err)
+		}
+	}
+}
+
+func TestCheckerLatency(t *testing.T) {
+	// The checker runs in its own goroutine, and is observed via targetDB.
+	savedDB, savedDelay := targetDB, CheckerStartDelayMax
+	t.Cleanup(func() { targetDB, CheckerStartDelayMax = savedDB, savedDelay })
+	targetDB, CheckerStartDelayMax = NewTargetDB(), time.Millisecond
+
+	var delay atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(time.Duration(delay.Load()))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	host, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+	portNum, _ := strconv.Atoi(port)
+	target := utils.L3L4Addr{IP: net.ParseIP(host), Port: uint16(portNum), Proto: utils.IPProtoTCP}
+
+	svc := &comm.VirtualServer{
+		Version:   1,
+		Addr:      utils.L3L4Addr{IP: net.ParseIP("192.168.100.3"), Port: 80, Proto: utils.IPProtoTCP},
+		DestCheck: checker.CheckMethodNone,
+		RSs:       []comm.RealServer{{Addr: target, Weight: 100}},
+	}
+	_, vs := newTestVS(t, svc, &vsConfDefault.QuorumConf)
+	defer vs.cleanup()
+	vs.va.m.appConf.AdminAddr = "127.0.0.1:0" // enable targetDB, but never serve
+	vs.conf.Method = checker.CheckMethodHTTP
+	vs.conf.UpRetry, vs.conf.DownRetry = 0, 0
+	vs.conf.Timing = checker.Timing{Interval: 2 * time.Second, Timeout: time.Second}
+	vs.conf.LatencyConf = LatencyConf{
+		MaxLatency:            200 * time.Millisecond,
+		DegradedLatency:       50 * time.Millisecond,
+		DegradedWeightPercent: 40,
+		LatencySamples:        3,
+	}
+	// The scheduler never runs, so the checker gets results only from check.
+	vs.doUpdate(&VSConfExt{VSConf: *vs.conf.DeepCopy(), vs: *svc.DeepCopy()})
+	ckid := CheckerID(target.String())
+	rs, ok := vs.backends[ckid]
+	if !ok {
+		t.Fatalf("backend %s not created", ckid)
+	}
+	results := rs.checker.result
+	method, err := checker.NewChecker(checker.CheckMethodHTTP, &target, nil)
+	if err != nil {
+		t.Fatalf("failed to create http checker: %v", err)
+	}
+	timeout := vs.conf.Timing.Timeout
+
+	// check delivers a check result to the checker, and returns its target info
+	// once the result is processed.
+	check := func(d time.Duration) *TargetInfo {
+		delay.Store(int64(d))
+		start := time.Now()
+		res, err := checker.CheckExTimeout(method, &target, timeout)
+		results <- checkResult{state: res.State, err: err, elapsed: time.Since(start),
+			timeout: timeout, result: res}
+		for deadline := time.Now().Add(3 * time.Second); time.Now().Before(deadline); {
+			if infos := targetDB.Get(&target); len(infos) == 1 && infos[0].LastCheck != nil &&
+				infos[0].LastCheck.After(start) {
+				return &infos[0]
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		t.Fatalf("check result not processed")
+		return nil
+	}
+	// pump delivers the notices from checker to VS, and returns the number of them.
+	pump := func() int {
+		n := 0
+		for {
+			select {
+			case notice := <-vs.notify:
+				vs.recvNotice(&notice)
+				n++
+			default:
+				return n
+			}
+		}
+	}
+
+	ms := time.Millisecond
+	steps := []struct {
+		name     string
+		delay    time.Duration
+		state    types.State
+		degraded bool
+		notices  int
+		weight   uint16
+	}{
+		{"fast", 0, types.Healthy, false, 1, 100},
+		{"fast again", 0, types.Healthy, false, 0, 100},
+		{"fast filling samples", 0, types.Healthy, false, 0, 100},
+		{"slow probe averaged", 100 * ms, types.Healthy, false, 0, 100},
+		{"slow probes degraded", 100 * ms, types.Healthy, true, 1, 40},
+		{"very slow probe", 450 * ms, types.Unhealthy, true, 1, 0},
+		{"fast but still degraded", 0, types.Healthy, true, 1, 40},
+		{"fast still degraded", 0, types.Healthy, true, 0, 40},
+		{"fast recovered", 0, types.Healthy, false, 1, 100},
+	}
+	for _, step := range steps {
+		info := check(step.delay)
+		if degraded := info.Latency != nil && info.Latency.Degraded; info.State != step.state.String() ||
+			degraded != step.degraded {
+			t.Errorf("%s: expect state %v degraded %v, got %+v", step.name, step.state, step.degraded, info)
+		}
+		if n := pump(); n != step.notices {
+			t.Errorf("%s: expect %d notices, got %d", step.name, step.notices, n)
+		}
+		if weight := vs.backendWeight(ckid, rs); rs.degraded != step.degraded || weight != step.weight {
+			t.Errorf("%s: expect backend degraded %v weight %d, got %v %d", step.name, step.degraded,
+				step.weight, rs.degraded, weight)
+		}
+		if step.state == types.Unhealthy && (info.LastResult == nil ||
+			info.LastResult.Reason != checker.ReasonLatency.String()) {
+			t.Errorf("%s: expect reason %v, got %+v", step.name, checker.ReasonLatency, info.LastResult)
+		}
+	}
+}
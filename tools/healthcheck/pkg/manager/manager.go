@@ -22,6 +22,7 @@ import (
 	"io/ioutil"
 	"math/rand"
 	"net/http"
+	"os"
 	"strings"
 	"sync"
 	"time"
@@ -34,19 +35,30 @@ import (
 	"gopkg.in/yaml.v2"
 )
 
-const VAStartDelayMax = 3 * time.Second
+// VAStartDelayMax is a variable only for tests to shorten it.
+var VAStartDelayMax = 3 * time.Second
 
 var (
-	_ utils.Task = (*cfgFileReloader)(nil)
-	_ utils.Task = (*svcLister)(nil)
+	_ utils.TriggeredTask = (*cfgFileReloader)(nil)
+	_ utils.TriggeredTask = (*svcLister)(nil)
 )
 
+// trigger requests a run of a TriggeredTask without blocking. The request is
+// merged into the pending one if any.
+func trigger(c chan<- struct{}) {
+	select {
+	case c <- struct{}{}:
+	default:
+	}
+}
+
 type cfgFileReloader struct {
 	name     string
 	interval time.Duration
 	filename string
 	raw      *ConfFileLayout // conf file content after merged default
-	m        *Manager        // the Manager instance controlling the Task
+	trigger  chan struct{}
+	m        *Manager // the Manager instance controlling the Task
 }
 
 func NewCfgFileReloader(m *Manager) *cfgFileReloader {
@@ -54,6 +66,7 @@ func NewCfgFileReloader(m *Manager) *cfgFileReloader {
 		name:     "config-file-reloader",
 		interval: m.appConf.HcCfgReloadInterval,
 		filename: m.appConf.HcCfgFile,
+		trigger:  make(chan struct{}, 1),
 		m:        m,
 	}
 }
@@ -66,13 +79,30 @@ func (t *cfgFileReloader) Interval() time.Duration {
 	return t.interval
 }
 
+func (t *cfgFileReloader) Trigger() <-chan struct{} {
+	return t.trigger
+}
+
+// Job loads and validates the config file, and applies it to the running VAs,
+// VSs and Checkers immediately if changed. An invalid config file is rejected
+// as a whole, and the running configs remain unchanged.
 func (t *cfgFileReloader) Job(ctx context.Context) {
 	conf, err := LoadFileConf(t.filename)
 	if err != nil || conf == nil {
 		glog.Errorf("Fail to load config file %s: %v.", t.filename, err)
 		return
 	}
-	t.m.conf = conf
+	old := t.m.getConf()
+	t.m.setConf(conf)
+	if old == nil {
+		glog.V(6).Infof("Config file loaded!")
+		return
+	}
+	if diffs := old.Diff(conf); len(diffs) > 0 {
+		glog.Infof("Config file reloaded with changes: %s", strings.Join(diffs, ", "))
+		trigger(t.m.svcLister.trigger)
+		return
+	}
 	glog.V(6).Infof("Config file reloaded!")
 }
 
@@ -88,16 +118,24 @@ type svcLister struct {
 	name     string
 	interval time.Duration
 	server   string
+	trigger  chan struct{}
+	list     func(ctx context.Context) ([]comm.VirtualServer, error)
+	applied  bool     // the service list has been applied once
 	m        *Manager // the Manager instance controlling the Task
 }
 
 func NewSvcLister(m *Manager) *svcLister {
-	return &svcLister{
+	t := &svcLister{
 		name:     "service-lister",
 		interval: m.appConf.DpvsServiceListInterval,
 		server:   m.appConf.DpvsAgentAddr,
+		trigger:  make(chan struct{}, 1),
 		m:        m,
 	}
+	t.list = func(ctx context.Context) ([]comm.VirtualServer, error) {
+		return comm.GetServiceFromDPVS(t.server, ctx)
+	}
+	return t
 }
 
 func (t *svcLister) Name() string {
@@ -108,11 +146,13 @@ func (t *svcLister) Interval() time.Duration {
 	return t.interval
 }
 
-func (t *svcLister) Job(ctx context.Context) {
-	var err error
+func (t *svcLister) Trigger() <-chan struct{} {
+	return t.trigger
+}
 
+func (t *svcLister) Job(ctx context.Context) {
 	// get the latest service list
-	dsvcs, err := comm.GetServiceFromDPVS(t.server, ctx)
+	dsvcs, err := t.list(ctx)
 	if err != nil {
 		glog.Warningf("Fail to get services from DPVS: %v.", err)
 		return
@@ -120,6 +160,15 @@ func (t *svcLister) Job(ctx context.Context) {
 	glog.V(5).Infof("Succeed to get %d services from DPVS", len(dsvcs))
 	glog.V(8).Infof("Got DPVS services: %v", dsvcs)
 
+	t.apply(dsvcs)
+}
+
+// apply diffs the services with the running VAs, creates new VAs, stops staled
+// ones, and updates existing ones with the current configs.
+func (t *svcLister) apply(dsvcs []comm.VirtualServer) {
+	var err error
+	conf := t.m.getConf()
+
 	// remove staled VAs
 	staled := make(map[VAID]bool)
 	for vaid, _ := range t.m.vas {
@@ -148,7 +197,7 @@ func (t *svcLister) Job(ctx context.Context) {
 	}
 	for vaid, vss := range vsgroup {
 		addr := vss[0].Addr.IP
-		vaConf := t.m.conf.GetVAConf(vaid)
+		vaConf := conf.GetVAConf(vaid)
 		va, ok := t.m.vas[vaid]
 		if !ok {
 			if vaConf.Disable {
@@ -178,6 +227,34 @@ func (t *svcLister) Job(ctx context.Context) {
 		}
 		va.Update(vaConfExt)
 	}
+
+	if !t.applied {
+		t.applied = true
+		t.dropSeeds(dsvcs)
+	}
+}
+
+// dropSeeds removes the seed states of the targets absent from the services
+// applied first, which are never consumed by the checkers created on startup.
+// The seeds of the targets present are consumed by their checkers soon.
+func (t *svcLister) dropSeeds(dsvcs []comm.VirtualServer) {
+	targets := make(map[string]struct{})
+	for _, svc := range dsvcs {
+		if _, ok := t.m.vas[VAID(svc.Addr.IP.String())]; !ok {
+			continue
+		}
+		vsid := VSID(svc.Addr.String())
+		for _, rs := range svc.RSs {
+			targets[fmt.Sprintf("%s/%s", vsid, rs.Addr.String())] = struct{}{}
+		}
+	}
+	dropped := stateDB.DropSeeds(func(uuid string) bool {
+		_, ok := targets[uuid]
+		return ok
+	})
+	if dropped > 0 {
+		glog.Infof("%d target states loaded but absent from services, drop them", dropped)
+	}
 }
 
 type metricServer struct {
@@ -193,6 +270,12 @@ type metricServer struct {
 func metricHandler(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprintf(w, "%s\n\n", time.Now())
 	fmt.Fprintf(w, "Thread Statistics:\n%s\n", AppThreadStatsDump())
+	if m := GetAppManager(); m != nil && m.scheduler != nil {
+		fmt.Fprintf(w, "Scheduler Statistics:\n%v\n\n", m.scheduler.Stats())
+	}
+	if m := GetAppManager(); m != nil && m.dispatcher != nil {
+		fmt.Fprintf(w, "Action Dispatcher Statistics:\n%v\n\n", m.dispatcher.Stats())
+	}
 	if _, err := fmt.Fprintf(w, "%s", metricDB); err != nil {
 		glog.Warningf("metric handler failed: %v", err)
 	}
@@ -315,15 +398,20 @@ func (s *metricServer) Shutdown(wg *sync.WaitGroup) {
 }
 
 type Manager struct {
-	appConf types.AppConf
-	vas     map[VAID]*VirtualAddress
-	conf    *Conf
+	appConf  types.AppConf
+	vas      map[VAID]*VirtualAddress
+	conf     *Conf
+	confLock sync.RWMutex
 
 	cfgFileReloader *cfgFileReloader
 	svcLister       *svcLister
+	stateSaver      *stateSaver // nil if state persistence disabled
 	cancel          context.CancelFunc
 
 	metricServer *metricServer
+	adminServer  *adminServer // nil if admin server disabled
+	scheduler    *Scheduler
+	dispatcher   *ActionDispatcher
 
 	wg       *sync.WaitGroup
 	quit     chan bool
@@ -342,7 +430,17 @@ func NewManager(conf *types.AppConf) *Manager {
 
 	m.cfgFileReloader = NewCfgFileReloader(m)
 	m.svcLister = NewSvcLister(m)
+	if len(m.appConf.StateFile) > 0 {
+		m.stateSaver = NewStateSaver(m)
+	}
 	m.metricServer = NewMetricServer(conf)
+	if len(m.appConf.AdminAddr) > 0 {
+		m.adminServer = NewAdminServer(&m.appConf)
+	}
+	m.scheduler = NewScheduler(m.appConf.CheckConcurrency, m.appConf.CheckJitter)
+	m.dispatcher = NewActionDispatcher(m.appConf.ActionerRate, m.appConf.ActionerTypeRates)
+	eventLog = utils.NewEventLogger(m.appConf.LogFormat, m.appConf.LogThrottleInterval,
+		os.Stdout, checkLogLimiter)
 
 	m.wg = &sync.WaitGroup{}
 	m.quit = make(chan bool, 1)
@@ -358,26 +456,49 @@ func (m *Manager) Run() {
 
 	// wait until m.conf loaded
 	glog.Infof("Awaiting manager conf to be populated ...")
-	for i := 0; i < 300 && m.conf == nil; i++ {
+	for i := 0; i < 300 && m.getConf() == nil; i++ {
 		time.Sleep(10 * time.Millisecond)
 	}
-	if m.conf == nil {
+	if m.getConf() == nil {
 		glog.Errorf("Manager conf populating failed!")
 		return
 	}
 
-	m.wg.Add(1)
-	go utils.RunTask(m.svcLister, ctx, m.wg, nil)
-
 	ctx2, cancel2 := context.WithCancel(context.Background())
 	go m.metricServer.Run(ctx2)
+	if m.adminServer != nil {
+		go m.adminServer.Run()
+	}
+
+	// Scheduler MUST start before any checker is created.
+	schedDone := make(chan struct{})
+	go func() {
+		m.scheduler.Run(ctx2)
+		close(schedDone)
+	}()
+
+	// Checker states MUST be loaded before any checker is created.
+	if m.stateSaver != nil {
+		m.loadStates()
+		m.wg.Add(1)
+		go utils.RunTask(m.stateSaver, ctx, m.wg,
+			time.NewTimer(m.appConf.StateSaveInterval).C)
+	}
+
+	m.wg.Add(1)
+	go utils.RunTask(m.svcLister, ctx, m.wg, nil)
 
 	<-m.quit
 	m.wg.Wait()
 
-	// Metric server MUST stop after everything is done.
+	// Metric server and scheduler MUST stop after everything is done.
 	cancel2()
+	<-schedDone
+	glog.Infof("Action dispatcher finished: %v", m.dispatcher.Stats())
 	m.metricServer.Shutdown(nil)
+	if m.adminServer != nil {
+		m.adminServer.Shutdown()
+	}
 
 	glog.Info("Manager server closed successfully.")
 }
@@ -389,6 +510,10 @@ func (m *Manager) Shutdown() {
 	m.stopping = true
 
 	glog.Info("Closing manager server ...")
+
+	// Save checker states before stopping checkers.
+	m.saveStates()
+
 	select {
 	case m.quit <- true:
 		// Stop tasks: cfgFileReloader, svcLister.
@@ -401,6 +526,49 @@ func (m *Manager) Shutdown() {
 	}
 }
 
+// Reload requests to reload the config file and apply it. Reloads requested
+// while one is in progress are queued and merged into one.
+func (m *Manager) Reload() {
+	trigger(m.cfgFileReloader.trigger)
+}
+
+func (m *Manager) getConf() *Conf {
+	m.confLock.RLock()
+	defer m.confLock.RUnlock()
+	return m.conf
+}
+
+func (m *Manager) setConf(conf *Conf) {
+	m.confLock.Lock()
+	defer m.confLock.Unlock()
+	m.conf = conf
+}
+
+func (m *Manager) loadStates() {
+	filename := m.appConf.StateFile
+	states, err := LoadStateFile(filename, m.appConf.StateMaxAge)
+	if err != nil {
+		// Never fail on a corrupted state file, just start cold.
+		glog.Errorf("Fail to load states from %s, ignore it: %v", filename, err)
+		return
+	}
+	stateDB.Seed(states, m.appConf.StateMaxAge)
+	glog.Infof("%d target states loaded from %s", len(states), filename)
+}
+
+func (m *Manager) saveStates() {
+	if m.stateSaver == nil {
+		return
+	}
+	filename := m.appConf.StateFile
+	states := stateDB.Freeze()
+	if err := SaveStateFile(filename, states); err != nil {
+		glog.Errorf("Fail to save states to %s: %v", filename, err)
+		return
+	}
+	glog.Infof("%d target states saved to %s", len(states), filename)
+}
+
 var appManager *Manager
 
 func SetAppManager(m *Manager) {
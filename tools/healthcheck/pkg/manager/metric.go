@@ -295,12 +295,7 @@ func (db *MetricDB) String() string {
 				glog.Warningf("VA %s VSID %v is not valid, skip VS metric %v.", vaID, vsID, vs)
 				continue
 			}
-			vipportStr := ""
-			if utils.IPAF(vipport.IP) == utils.IPv4 {
-				vipportStr = fmt.Sprintf("%s %s:%d", vipport.Proto, vipport.IP, vipport.Port)
-			} else {
-				vipportStr = fmt.Sprintf("%s [%s]:%d", vipport.Proto, vipport.IP, vipport.Port)
-			}
+			vipportStr := fmt.Sprintf("%s %s", vipport.Proto, vipport.Addr())
 			builder.WriteString(fmt.Sprintf("%s%-32s%s%-32s%s%-32s", indent, vipportStr, sep, vs.state, sep, vs.stats))
 			if len(vs.extras) > 0 {
 				builder.WriteString(fmt.Sprintf("%s%s", sep, strings.Join(vs.extras, " ")))
@@ -321,12 +316,7 @@ func (db *MetricDB) String() string {
 					glog.Warningf("VS %s CheckerID %v is not valid, skip Checker metric %v.", vsID, ckID, ck)
 					continue
 				}
-				backendStr := ""
-				if utils.IPAF(backend.IP) == utils.IPv4 {
-					backendStr = fmt.Sprintf("%s:%d", backend.IP, backend.Port)
-				} else {
-					backendStr = fmt.Sprintf("[%s]:%d", backend.IP, backend.Port)
-				}
+				backendStr := backend.Addr()
 				builder.WriteString(fmt.Sprintf("%s%-32s%s%-32s%s%-32s", indent, backendStr, sep, ck.state, sep, ck.stats))
 				if len(ck.extras) > 0 {
 					builder.WriteString(fmt.Sprintf("%s%s", sep, strings.Join(ck.extras, " ")))
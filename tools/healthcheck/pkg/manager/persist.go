@@ -0,0 +1,276 @@
+// /*
+// Copyright 2025 IQiYi Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// */
+
+package manager
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/types"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/utils"
+)
+
+const stateFileVersion = 1
+
+var _ utils.Task = (*stateSaver)(nil)
+
+// TargetState is the persistent health state of a checker target.
+type TargetState struct {
+	VS      VSID           `json:"vs"`
+	Target  utils.L3L4Addr `json:"target"`
+	State   types.State    `json:"state"`
+	Since   time.Time      `json:"since"`   // last transition time
+	Count   uint           `json:"count"`   // consecutive checks in State
+	Updated time.Time      `json:"updated"` // last check time
+}
+
+// UUID returns the ID of the checker the state belongs to, which is consistent
+// with Checker::UUID.
+func (ts *TargetState) UUID() string {
+	return fmt.Sprintf("%s/%s", ts.VS, ts.Target.String())
+}
+
+type stateFileLayout struct {
+	Version int           `json:"version"`
+	SavedAt time.Time     `json:"saved-at"`
+	Targets []TargetState `json:"targets"`
+}
+
+// StateDB keeps the states of all running checkers for persistence, and the
+// states loaded from state file to seed checkers on startup.
+type StateDB struct {
+	lock   sync.Mutex
+	live   map[string]TargetState // keyed by checker UUID
+	seeds  map[string]TargetState // keyed by checker UUID
+	maxAge time.Duration          // max age of seeds, 0 for no limit
+	frozen bool                   // no more save once frozen
+}
+
+var stateDB *StateDB
+
+func init() {
+	stateDB = NewStateDB()
+}
+
+func NewStateDB() *StateDB {
+	return &StateDB{
+		live:  make(map[string]TargetState),
+		seeds: make(map[string]TargetState),
+	}
+}
+
+// Update records the latest state of a running checker.
+func (db *StateDB) Update(ts *TargetState) {
+	db.lock.Lock()
+	db.live[ts.UUID()] = *ts
+	db.lock.Unlock()
+}
+
+// Remove drops the state of a stopped checker.
+func (db *StateDB) Remove(uuid string) {
+	db.lock.Lock()
+	delete(db.live, uuid)
+	db.lock.Unlock()
+}
+
+// Snapshot returns states of all running checkers sorted by UUID.
+func (db *StateDB) Snapshot() []TargetState {
+	db.lock.Lock()
+	states := make([]TargetState, 0, len(db.live))
+	for _, ts := range db.live {
+		states = append(states, ts)
+	}
+	db.lock.Unlock()
+
+	sort.Slice(states, func(i, j int) bool {
+		return states[i].UUID() < states[j].UUID()
+	})
+	return states
+}
+
+// Seed loads the states to seed checkers created afterwards. Seeds older than
+// `maxAge` when consumed are dropped, and zero `maxAge` means no age limit.
+func (db *StateDB) Seed(states []TargetState, maxAge time.Duration) {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	db.maxAge = maxAge
+	for _, ts := range states {
+		db.seeds[ts.UUID()] = ts
+	}
+}
+
+// Consume returns and removes the seed state for checker `uuid`.
+func (db *StateDB) Consume(uuid string) (*TargetState, bool) {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	ts, ok := db.seeds[uuid]
+	if !ok {
+		return nil, false
+	}
+	delete(db.seeds, uuid)
+	if db.maxAge > 0 && time.Since(ts.Updated) > db.maxAge {
+		glog.V(5).Infof("stale target state %s updated at %v, drop it", uuid, ts.Updated)
+		return nil, false
+	}
+	return &ts, true
+}
+
+// DropSeeds removes the seeds not kept by `keep`, and returns the number of
+// seeds removed.
+func (db *StateDB) DropSeeds(keep func(uuid string) bool) int {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	dropped := 0
+	for uuid := range db.seeds {
+		if !keep(uuid) {
+			delete(db.seeds, uuid)
+			dropped++
+		}
+	}
+	return dropped
+}
+
+// Freeze stops the StateDB from further saves, and returns the final snapshot.
+// It's called on shutdown before checkers are stopped.
+func (db *StateDB) Freeze() []TargetState {
+	states := db.Snapshot()
+	db.lock.Lock()
+	db.frozen = true
+	db.lock.Unlock()
+	return states
+}
+
+func (db *StateDB) Frozen() bool {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	return db.frozen
+}
+
+// MarshalStates serializes the states into state file format.
+func MarshalStates(states []TargetState, savedAt time.Time) ([]byte, error) {
+	return json.MarshalIndent(&stateFileLayout{
+		Version: stateFileVersion,
+		SavedAt: savedAt,
+		Targets: states,
+	}, "", "  ")
+}
+
+// UnmarshalStates parses the states from state file data. States older than
+// `maxAge` at `now` are dropped, and zero `maxAge` means no age limit.
+func UnmarshalStates(data []byte, maxAge time.Duration, now time.Time) ([]TargetState, error) {
+	var layout stateFileLayout
+	if err := json.Unmarshal(data, &layout); err != nil {
+		return nil, err
+	}
+	if layout.Version != stateFileVersion {
+		return nil, fmt.Errorf("unsupported state file version %d", layout.Version)
+	}
+
+	states := make([]TargetState, 0, len(layout.Targets))
+	for _, ts := range layout.Targets {
+		if ts.Target.IP == nil || len(ts.VS) == 0 {
+			glog.Warningf("invalid target state %v, drop it", ts)
+			continue
+		}
+		if ts.State != types.Healthy && ts.State != types.Unhealthy {
+			continue
+		}
+		if maxAge > 0 && now.Sub(ts.Updated) > maxAge {
+			glog.V(5).Infof("stale target state %s updated at %v, drop it", ts.UUID(), ts.Updated)
+			continue
+		}
+		states = append(states, ts)
+	}
+	return states, nil
+}
+
+// SaveStateFile writes states to `filename` atomically.
+func SaveStateFile(filename string, states []TargetState) error {
+	data, err := MarshalStates(states, time.Now())
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(filename), filepath.Base(filename)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filename)
+}
+
+// LoadStateFile reads states from `filename`. A nonexistent file is not an error.
+func LoadStateFile(filename string, maxAge time.Duration) ([]TargetState, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return UnmarshalStates(data, maxAge, time.Now())
+}
+
+type stateSaver struct {
+	name     string
+	interval time.Duration
+	filename string
+	db       *StateDB
+}
+
+func NewStateSaver(m *Manager) *stateSaver {
+	return &stateSaver{
+		name:     "state-saver",
+		interval: m.appConf.StateSaveInterval,
+		filename: m.appConf.StateFile,
+		db:       stateDB,
+	}
+}
+
+func (t *stateSaver) Name() string {
+	return t.name
+}
+
+func (t *stateSaver) Interval() time.Duration {
+	return t.interval
+}
+
+func (t *stateSaver) Job(ctx context.Context) {
+	if t.db.Frozen() {
+		return
+	}
+	states := t.db.Snapshot()
+	if err := SaveStateFile(t.filename, states); err != nil {
+		glog.Warningf("Fail to save states to %s: %v", t.filename, err)
+		return
+	}
+	glog.V(6).Infof("%d target states saved to %s", len(states), t.filename)
+}
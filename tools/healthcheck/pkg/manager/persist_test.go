@@ -0,0 +1,318 @@
+// /*
+// Copyright 2025 IQiYi Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// */
+
+package manager
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/actioner"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/checker"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/comm"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/types"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/utils"
+)
+
+func TestStateRoundTrip(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	states := []TargetState{
+		{
+			VS:      "192.168.100.1-TCP-80",
+			Target:  utils.L3L4Addr{IP: net.ParseIP("192.168.200.1").To4(), Port: 8080, Proto: utils.IPProtoTCP},
+			State:   types.Unhealthy,
+			Since:   now.Add(-time.Hour),
+			Count:   12,
+			Updated: now,
+		},
+		{
+			VS:      "2001::1-UDP-53",
+			Target:  utils.L3L4Addr{IP: net.ParseIP("2001::100"), Port: 53, Proto: utils.IPProtoUDP},
+			State:   types.Healthy,
+			Since:   now.Add(-time.Minute),
+			Count:   3,
+			Updated: now,
+		},
+	}
+
+	data, err := MarshalStates(states, now)
+	if err != nil {
+		t.Fatalf("marshal states failed: %v", err)
+	}
+	got, err := UnmarshalStates(data, time.Minute, now)
+	if err != nil {
+		t.Fatalf("unmarshal states failed: %v", err)
+	}
+	if len(got) != len(states) {
+		t.Fatalf("expect %d states, got %d", len(states), len(got))
+	}
+	for i := range states {
+		if got[i].UUID() != states[i].UUID() || got[i].State != states[i].State ||
+			got[i].Count != states[i].Count || !got[i].Since.Equal(states[i].Since) ||
+			!got[i].Updated.Equal(states[i].Updated) {
+			t.Errorf("state %d mismatched: expect %+v, got %+v", i, states[i], got[i])
+		}
+	}
+
+	// stale states are dropped
+	got, err = UnmarshalStates(data, time.Minute, now.Add(2*time.Minute))
+	if err != nil {
+		t.Fatalf("unmarshal states failed: %v", err)
+	}
+	if len(got) != 0 {
+		t.Errorf("expect stale states dropped, got %v", got)
+	}
+
+	// corrupted data
+	for _, bad := range []string{"", "{", "[]", `{"version": 100}`, "\x00\x01garbage"} {
+		if _, err := UnmarshalStates([]byte(bad), 0, now); err == nil {
+			t.Errorf("expect error for corrupted data %q", bad)
+		}
+	}
+}
+
+func TestStateFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "healthcheck-state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "state.json")
+
+	if states, err := LoadStateFile(filename, time.Minute); err != nil || states != nil {
+		t.Errorf("nonexistent state file: expect no states and no error, got %v, %v", states, err)
+	}
+
+	states := []TargetState{{
+		VS:      "192.168.100.1-TCP-80",
+		Target:  utils.L3L4Addr{IP: net.ParseIP("192.168.200.1"), Port: 8080, Proto: utils.IPProtoTCP},
+		State:   types.Unhealthy,
+		Since:   time.Now(),
+		Count:   2,
+		Updated: time.Now(),
+	}}
+	if err := SaveStateFile(filename, states); err != nil {
+		t.Fatalf("save state file failed: %v", err)
+	}
+	got, err := LoadStateFile(filename, time.Minute)
+	if err != nil || len(got) != 1 || got[0].UUID() != states[0].UUID() {
+		t.Errorf("load state file: expect %v, got %v, %v", states, got, err)
+	}
+
+	if err := ioutil.WriteFile(filename, []byte("corrupted"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadStateFile(filename, time.Minute); err == nil {
+		t.Errorf("expect error loading corrupted state file")
+	}
+}
+
+// countingActioner counts the Act calls of the actioner it wraps.
+type countingActioner struct {
+	actioner.ActionMethod
+	calls int
+}
+
+func (a *countingActioner) Act(signal types.State, timeout time.Duration,
+	data ...interface{}) (interface{}, error) {
+	a.calls++
+	return a.ActionMethod.Act(signal, timeout, data...)
+}
+
+func TestStateWarmRestart(t *testing.T) {
+	dir, err := ioutil.TempDir("", "healthcheck-state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "state.json")
+
+	savedDB := stateDB
+	defer func() { stateDB = savedDB }()
+	stateDB = NewStateDB()
+
+	svc := &comm.VirtualServer{
+		Version:   1,
+		Addr:      utils.L3L4Addr{IP: net.ParseIP("192.168.100.1"), Port: 80, Proto: utils.IPProtoTCP},
+		DestCheck: checker.CheckMethodNone,
+	}
+	var rss []comm.RealServer
+	for i := 1; i <= 4; i++ {
+		rss = append(rss, comm.RealServer{
+			Addr:   utils.L3L4Addr{IP: net.ParseIP(fmt.Sprintf("192.168.200.%d", i)), Port: 8080, Proto: utils.IPProtoTCP},
+			Weight: 100,
+		})
+	}
+	quorum := &vsConfDefault.QuorumConf
+
+	// Run #1: rs0 and rs2 go down, rs1 stays up, and rs3 is unconfirmed.
+	_, vs := newTestVS(t, svc, quorum)
+	vs.va.m.appConf.StateFile = filename
+	results := [][]types.State{
+		{types.Unhealthy, types.Unhealthy},
+		{types.Healthy, types.Healthy},
+		{types.Unhealthy, types.Unhealthy, types.Unhealthy},
+		{types.Unhealthy},
+	}
+	for i, rs := range rss {
+		ck, err := NewChecker(&rs.Addr, vs.conf.GetCheckerConf(), vs)
+		if err != nil {
+			t.Fatalf("failed to create checker: %v", err)
+		}
+		for _, state := range results[i] {
+			ck.doPostCheck(state)
+		}
+	}
+	if err := SaveStateFile(filename, stateDB.Freeze()); err != nil {
+		t.Fatalf("save state file failed: %v", err)
+	}
+
+	// Run #2: restart with states loaded, rs0 inhibited in dpvs already,
+	// rs2 not inhibited yet, and rs3 removed from dpvs.
+	stateDB = NewStateDB()
+	states, err := LoadStateFile(filename, time.Minute)
+	if err != nil {
+		t.Fatalf("load state file failed: %v", err)
+	}
+	stateDB.Seed(states, time.Minute)
+
+	va, vs := newTestVS(t, svc, quorum)
+	vs.va.m.appConf.StateFile = filename
+	counter := &countingActioner{ActionMethod: vs.actioner}
+	vs.actioner = counter
+	defer vs.cleanup()
+
+	svc.RSs = []comm.RealServer{rss[0], rss[1], rss[2]}
+	svc.RSs[0].Inhibited = true
+	svc.RSs[0].Weight = 0
+	vs.doUpdate(&VSConfExt{VSConf: *vs.conf.DeepCopy(), vs: *svc.DeepCopy()})
+
+	expects := []types.State{types.Unhealthy, types.Healthy, types.Unhealthy}
+	for i, expect := range expects {
+		ckid := CheckerID(rss[i].Addr.String())
+		if state := vs.backends[ckid].checkerState; state != expect {
+			t.Errorf("rs%d: expect restored state %v, got %v", i, expect, state)
+		}
+	}
+	// Only rs2 is inconsistent with dpvs and needs an action.
+	if counter.calls != 1 {
+		t.Errorf("expect 1 backend action on warm restart, got %d", counter.calls)
+	}
+	pumpVA(va)
+	if va.state != types.Healthy {
+		t.Errorf("expect VA state %v after warm restart, got %v", types.Healthy, va.state)
+	}
+
+	// rs3 is absent from the current config, its seed is never consumed and
+	// its state is no longer saved.
+	rs3 := fmt.Sprintf("%s/%s", vs.id, rss[3].Addr.String())
+	if _, ok := stateDB.Consume(rs3); !ok {
+		t.Errorf("seed of absent target consumed")
+	}
+	for _, ts := range stateDB.Snapshot() {
+		if ts.UUID() == rs3 {
+			t.Errorf("state of absent target saved")
+		}
+	}
+
+	// The first checks observing the restored states trigger no actions.
+	for i, expect := range expects {
+		vs.recvNotice(&BackendState{id: CheckerID(rss[i].Addr.String()), state: expect})
+	}
+	if counter.calls != 1 {
+		t.Errorf("expect no more backend actions, got %d", counter.calls)
+	}
+}
+
+func TestStateSeedLateTarget(t *testing.T) {
+	savedDB := stateDB
+	defer func() { stateDB = savedDB }()
+	stateDB = NewStateDB()
+
+	svc := comm.VirtualServer{
+		Version:   1,
+		Addr:      utils.L3L4Addr{IP: net.ParseIP("192.168.100.1"), Port: 80, Proto: utils.IPProtoTCP},
+		DestCheck: checker.CheckMethodNone,
+	}
+	var rss []comm.RealServer
+	for i := 1; i <= 2; i++ {
+		rss = append(rss, comm.RealServer{
+			Addr:   utils.L3L4Addr{IP: net.ParseIP(fmt.Sprintf("192.168.200.%d", i)), Port: 8080, Proto: utils.IPProtoTCP},
+			Weight: 100,
+		})
+	}
+	vsid := VSID(svc.Addr.String())
+	now := time.Now()
+	stateDB.Seed([]TargetState{
+		{VS: vsid, Target: rss[0].Addr, State: types.Unhealthy, Since: now, Count: 3, Updated: now},
+		{VS: vsid, Target: rss[1].Addr, State: types.Unhealthy, Since: now, Count: 3, Updated: now},
+	}, time.Minute)
+
+	// rs1 is absent from the services applied first, and its seed is dropped
+	// while the seed of rs0 is consumed by its checker.
+	svc.RSs = []comm.RealServer{rss[0]}
+	env := newReloadEnv(t, []comm.VirtualServer{svc})
+	env.start("")
+	for i := 0; i < 300; i++ {
+		stateDB.lock.Lock()
+		seeds := len(stateDB.seeds)
+		stateDB.lock.Unlock()
+		if seeds == 0 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	env.stop()
+	stateDB.lock.Lock()
+	seeds := len(stateDB.seeds)
+	stateDB.lock.Unlock()
+	if seeds != 0 {
+		t.Fatalf("expect no seeds left after the first apply, got %d", seeds)
+	}
+
+	// rs1 added later starts cold rather than from its stale seed.
+	svc.RSs = rss
+	_, vs := newTestVS(t, &svc, &vsConfDefault.QuorumConf)
+	ck, err := NewChecker(&rss[1].Addr, vs.conf.GetCheckerConf(), vs)
+	if err != nil {
+		t.Fatalf("failed to create checker: %v", err)
+	}
+	if ck.state != types.Unknown {
+		t.Errorf("expect late target to start with state %v, got %v", types.Unknown, ck.state)
+	}
+}
+
+func TestStateSeedMaxAge(t *testing.T) {
+	db := NewStateDB()
+	now := time.Now()
+	target := utils.L3L4Addr{IP: net.ParseIP("192.168.200.1"), Port: 8080, Proto: utils.IPProtoTCP}
+	fresh := TargetState{VS: "192.168.100.1-TCP-80", Target: target, State: types.Healthy, Updated: now}
+	stale := TargetState{VS: "192.168.100.2-TCP-80", Target: target, State: types.Healthy,
+		Updated: now.Add(-2 * time.Minute)}
+	db.Seed([]TargetState{fresh, stale}, time.Minute)
+
+	if _, ok := db.Consume(fresh.UUID()); !ok {
+		t.Errorf("fresh seed not consumed")
+	}
+	if _, ok := db.Consume(stale.UUID()); ok {
+		t.Errorf("stale seed consumed")
+	}
+}
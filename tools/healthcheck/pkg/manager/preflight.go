@@ -0,0 +1,103 @@
+// /*
+// Copyright 2025 IQiYi Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// */
+
+package manager
+
+import (
+	"fmt"
+
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/checker"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/preflight"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/types"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/utils"
+)
+
+// PreflightRequirements returns the requirements of the checkers and actioners
+// configured by `appConf` for the preflight checks. The checkers of the global
+// virtual server with the auto method are not counted, because the protocols
+// of the services are unknown until listed from dpvs.
+func PreflightRequirements(appConf *types.AppConf) (*preflight.Requirements, error) {
+	req := &preflight.Requirements{DpvsAgent: appConf.DpvsAgentAddr}
+	if len(appConf.HcCfgFile) == 0 {
+		collectActionerRequirements(req, "global/virtual-address", &vaConfDefault.ActionConf)
+		return req, nil
+	}
+
+	fc, err := loadFileLayout(appConf.HcCfgFile)
+	if err != nil {
+		return req, err
+	}
+	collectActionerRequirements(req, "global/virtual-address", &fc.Global.VAConf.ActionConf)
+	for vaid, va := range fc.VAs {
+		collectActionerRequirements(req, fmt.Sprintf("virtual-addresses/%s", vaid), &va.ActionConf)
+	}
+	collectCheckerRequirements(req, "global/virtual-server", fc.Global.VSConf.Method,
+		fc.Global.VSConf.MethodParams, 0)
+	for vsid, vs := range fc.VSs {
+		var proto utils.IPProto
+		if addr := utils.ParseL3L4Addr(string(vsid)); addr != nil {
+			proto = addr.Proto
+		}
+		collectCheckerRequirements(req, fmt.Sprintf("virtual-servers/%s", vsid), vs.Method,
+			vs.MethodParams, proto)
+	}
+	return req, nil
+}
+
+func collectCheckerRequirements(req *preflight.Requirements, user string, kind checker.Method,
+	params map[string]string, proto utils.IPProto) {
+	if kind == checker.CheckMethodAuto {
+		if proto == 0 {
+			return
+		}
+		kind = kind.TranslateAuto(proto)
+	}
+	user = fmt.Sprintf("%s: %s", user, kind)
+
+	switch kind {
+	case checker.CheckMethodPing, checker.CheckMethodUDPPing:
+		req.ICMP = append(req.ICMP, user)
+		if params[checker.ParamPrivileged] == checker.PingPrivilegedTrue {
+			req.RawICMP = append(req.RawICMP, user)
+			req.RawSocket = append(req.RawSocket, user)
+		}
+	case checker.CheckMethodARP:
+		req.RawSocket = append(req.RawSocket, user)
+		if ifname := params["ifname"]; len(ifname) > 0 {
+			req.AddIfname(ifname, user)
+		}
+	case checker.CheckMethodComposite:
+		kinds, children, err := checker.CompositeChildren(params)
+		if err != nil {
+			return
+		}
+		for i := range kinds {
+			collectCheckerRequirements(req, user, kinds[i], children[i], proto)
+		}
+	}
+}
+
+func collectActionerRequirements(req *preflight.Requirements, user string, conf *ActionConf) {
+	user = fmt.Sprintf("%s: %s", user, conf.Actioner)
+
+	switch conf.Actioner {
+	case "KernelRouteAddDel", "DpvsAddrKernelRouteAddDel":
+		req.NetAdmin = append(req.NetAdmin, user)
+		if ifname := conf.ActionParams["ifname"]; len(ifname) > 0 {
+			req.AddIfname(ifname, user)
+		}
+	}
+}
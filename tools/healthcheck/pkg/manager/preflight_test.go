@@ -0,0 +1,107 @@
+// /*
+// Copyright 2025 IQiYi Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// */
+
+package manager
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/types"
+)
+
+const preflightConfText = `
+global:
+  virtual-address:
+    actioner: KernelRouteAddDel
+    action-params:
+      ifname: lo
+virtual-addresses:
+  192.168.88.1:
+    actioner: DpvsAddrKernelRouteAddDel
+    action-params:
+      ifname: eth1
+      dpvs-ifname: dpdk0
+      allow-missing-link: "true"
+  192.168.88.2:
+    actioner: Blank
+virtual-servers:
+  192.168.88.1-UDP-53:
+    method: 10000
+  192.168.88.1-TCP-80:
+    method: 4
+    method-params:
+      privileged: "true"
+  192.168.88.2-TCP-80:
+    method: 11
+    method-params:
+      a.method: http
+      b.method: arp
+      b.ifname: eth1
+  192.168.88.2-TCP-443:
+    method: 2
+`
+
+func TestPreflightRequirements(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "healthcheck.conf")
+	if err := os.WriteFile(filename, []byte(preflightConfText), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	appConf := types.DefaultAppConf
+	appConf.HcCfgFile = filename
+	appConf.DpvsAgentAddr = "http://127.0.0.1:8082"
+
+	req, err := PreflightRequirements(&appConf)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	join := func(users []string) string {
+		sorted := append([]string(nil), users...)
+		sort.Strings(sorted)
+		return strings.Join(sorted, ";")
+	}
+	for name, c := range map[string]struct{ users, expect []string }{
+		"icmp": {req.ICMP, []string{
+			"virtual-servers/192.168.88.1-TCP-80: ping",
+			"virtual-servers/192.168.88.1-UDP-53: udpping"}},
+		"raw-icmp": {req.RawICMP, []string{"virtual-servers/192.168.88.1-TCP-80: ping"}},
+		"raw-socket": {req.RawSocket, []string{
+			"virtual-servers/192.168.88.1-TCP-80: ping",
+			"virtual-servers/192.168.88.2-TCP-80: composite: arp"}},
+		"net-admin": {req.NetAdmin, []string{
+			"global/virtual-address: KernelRouteAddDel",
+			"virtual-addresses/192.168.88.1: DpvsAddrKernelRouteAddDel"}},
+		"ifname lo": {req.Ifnames["lo"], []string{"global/virtual-address: KernelRouteAddDel"}},
+		"ifname eth1": {req.Ifnames["eth1"], []string{
+			"virtual-addresses/192.168.88.1: DpvsAddrKernelRouteAddDel",
+			"virtual-servers/192.168.88.2-TCP-80: composite: arp"}},
+	} {
+		if join(c.users) != join(c.expect) {
+			t.Errorf("%s: expect users %q, got %q", name, c.expect, c.users)
+		}
+	}
+	if len(req.Ifnames) != 2 || req.DpvsAgent != appConf.DpvsAgentAddr {
+		t.Errorf("unexpected requirements: %+v", req)
+	}
+
+	appConf.HcCfgFile = filepath.Join(t.TempDir(), "missing.conf")
+	if _, err := PreflightRequirements(&appConf); err == nil {
+		t.Errorf("expect error with missing config file")
+	}
+}
@@ -0,0 +1,90 @@
+// /*
+// Copyright 2025 IQiYi Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// */
+
+package manager
+
+import (
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/types"
+)
+
+// quorumTracker concludes the aggregate health state of a VS from the number of
+// its healthy backends with respect to QuorumConf. It remembers the last state
+// so that the up/down thresholds take effect as hysteresis.
+//
+// quorumTracker is not thread-safe, and should be accessed only in VS's loop.
+type quorumTracker struct {
+	conf  QuorumConf
+	state types.State // types.Unknown before the first judgement
+}
+
+func newQuorumTracker(conf *QuorumConf) *quorumTracker {
+	return &quorumTracker{
+		conf:  *conf,
+		state: types.Unknown,
+	}
+}
+
+// setConf updates the QuorumConf, the current quorum state is kept.
+func (q *quorumTracker) setConf(conf *QuorumConf) {
+	q.conf = *conf
+}
+
+// countUnknownUp tells whether backends with types.Unknown state should be
+// counted as healthy ones.
+func (q *quorumTracker) countUnknownUp() bool {
+	return q.conf.QuorumInitState != QuorumInitDown
+}
+
+// required returns the minimum healthy backends number required by `threshold`.
+func (q *quorumTracker) required(threshold uint, total int) int {
+	if q.conf.QuorumPercent > 0 {
+		// round up, so that 50% of 3 backends requires 2 healthy ones
+		return (int(threshold)*total + 99) / 100
+	}
+	return int(threshold)
+}
+
+// judge concludes the quorum state with the number of healthy backends and
+// the number of total backends.
+// A VS without any backends has no quorum.
+func (q *quorumTracker) judge(healthy, total int) types.State {
+	if total <= 0 {
+		q.state = types.Unhealthy
+		return q.state
+	}
+
+	state := q.state
+	if state == types.Unknown {
+		state = types.Healthy
+		if q.conf.QuorumInitState == QuorumInitDown {
+			state = types.Unhealthy
+		}
+	}
+
+	switch state {
+	case types.Healthy:
+		if healthy < q.required(q.conf.DownThreshold(), total) {
+			state = types.Unhealthy
+		}
+	case types.Unhealthy:
+		if healthy >= q.required(q.conf.UpThreshold(), total) {
+			state = types.Healthy
+		}
+	}
+
+	q.state = state
+	return state
+}
@@ -0,0 +1,255 @@
+// /*
+// Copyright 2025 IQiYi Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// */
+
+package manager
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/checker"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/comm"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/types"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/utils"
+)
+
+func TestQuorumTrackerHysteresis(t *testing.T) {
+	q := newQuorumTracker(&QuorumConf{
+		QuorumCount:     2,
+		QuorumUp:        3,
+		QuorumDown:      2,
+		QuorumInitState: QuorumInitUp,
+	})
+
+	cases := []struct {
+		healthy, total int
+		expect         types.State
+	}{
+		{4, 4, types.Healthy},
+		{2, 4, types.Healthy}, // not below quorum-down
+		{1, 4, types.Unhealthy},
+		{2, 4, types.Unhealthy}, // not reach quorum-up
+		{3, 4, types.Healthy},
+		{2, 4, types.Healthy},
+		{0, 0, types.Unhealthy}, // no backends
+		{1, 1, types.Unhealthy},
+		{3, 3, types.Healthy},
+	}
+	for i, c := range cases {
+		if state := q.judge(c.healthy, c.total); state != c.expect {
+			t.Errorf("case %d: judge(%d, %d) expect %v, got %v", i, c.healthy, c.total, c.expect, state)
+		}
+	}
+}
+
+func TestQuorumTrackerPercent(t *testing.T) {
+	q := newQuorumTracker(&QuorumConf{
+		QuorumPercent:   50,
+		QuorumInitState: QuorumInitDown,
+	})
+	if state := q.judge(1, 3); state != types.Unhealthy {
+		t.Errorf("1/3 with 50%% quorum: expect %v, got %v", types.Unhealthy, state)
+	}
+	if state := q.judge(2, 3); state != types.Healthy {
+		t.Errorf("2/3 with 50%% quorum: expect %v, got %v", types.Healthy, state)
+	}
+	if state := q.judge(2, 5); state != types.Unhealthy {
+		t.Errorf("2/5 with 50%% quorum: expect %v, got %v", types.Unhealthy, state)
+	}
+}
+
+func TestQuorumConfValid(t *testing.T) {
+	invalids := []QuorumConf{
+		{QuorumCount: 1, QuorumPercent: 50, QuorumInitState: QuorumInitUp},
+		{QuorumInitState: QuorumInitUp},
+		{QuorumPercent: 101, QuorumInitState: QuorumInitUp},
+		{QuorumCount: 2, QuorumUp: 1, QuorumDown: 2, QuorumInitState: QuorumInitUp},
+		{QuorumCount: 1, QuorumInitState: "unknown"},
+	}
+	for _, conf := range invalids {
+		if err := conf.Valid(); err == nil {
+			t.Errorf("expect invalid QuorumConf: %+v", conf)
+		}
+	}
+
+	conf := QuorumConf{QuorumPercent: 30}
+	conf.MergeDefault(&vsConfDefault.QuorumConf)
+	if err := conf.Valid(); err != nil {
+		t.Errorf("QuorumConf %+v merged default invalid: %v", conf, err)
+	}
+	if conf.QuorumCount != 0 || conf.QuorumInitState != QuorumInitUp {
+		t.Errorf("unexpected QuorumConf merged default: %+v", conf)
+	}
+}
+
+// newTestVS creates a VA with a Blank actioner and a VS in it, both are driven
+// manually by the caller rather than in their own loops.
+func newTestVS(t *testing.T, svc *comm.VirtualServer, qconf *QuorumConf) (*VirtualAddress, *VirtualService) {
+	m := NewManager(&types.DefaultAppConf)
+	m.setConf(confDefault.DeepCopy())
+
+	vaConf := vaConfDefault.DeepCopy()
+	vaConf.Actioner = "Blank"
+	vaConf.ActionParams = nil
+	va, err := NewVA(svc.Addr.IP, vaConf, m)
+	if err != nil {
+		t.Fatalf("failed to create VA: %v", err)
+	}
+
+	vsConf := vsConfDefault.DeepCopy()
+	vsConf.Method = checker.CheckMethodNone
+	vsConf.Actioner = "Blank"
+	vsConf.QuorumConf = *qconf
+	vsConf.DebounceWindow = -1 // remove backends immediately
+	vs, err := NewVS(svc, vsConf, va)
+	if err != nil {
+		t.Fatalf("failed to create VS: %v", err)
+	}
+	va.vss[vs.id] = newVAVS(&svc.Addr, svc.Version, vs)
+	return va, vs
+}
+
+// pumpVA delivers VS state notices to VA, and returns the number of VA actioner calls.
+func pumpVA(va *VirtualAddress) uint64 {
+	for {
+		select {
+		case state := <-va.notify:
+			va.recvNotice(&state)
+		default:
+			return va.stats.up + va.stats.down
+		}
+	}
+}
+
+func TestVSQuorumActions(t *testing.T) {
+	svc := &comm.VirtualServer{
+		Version:   1,
+		Addr:      utils.L3L4Addr{IP: net.ParseIP("192.168.100.1"), Port: 80, Proto: utils.IPProtoTCP},
+		DestCheck: checker.CheckMethodNone,
+	}
+	var rss []comm.RealServer
+	for i := 1; i <= 4; i++ {
+		rss = append(rss, comm.RealServer{
+			Addr:   utils.L3L4Addr{IP: net.ParseIP(fmt.Sprintf("192.168.200.%d", i)), Port: 8080, Proto: utils.IPProtoTCP},
+			Weight: 100,
+		})
+	}
+	ckid := func(i int) CheckerID { return CheckerID(rss[i].Addr.String()) }
+
+	va, vs := newTestVS(t, svc, &QuorumConf{
+		QuorumCount:     2,
+		QuorumUp:        3,
+		QuorumDown:      2,
+		QuorumInitState: QuorumInitUp,
+	})
+	defer vs.cleanup()
+
+	setRSs := func(rss []comm.RealServer) {
+		svc.RSs = rss
+		vs.doUpdate(&VSConfExt{VSConf: *vs.conf.DeepCopy(), vs: *svc.DeepCopy()})
+	}
+	notice := func(i int, state types.State) {
+		vs.recvNotice(&BackendState{id: ckid(i), state: state})
+	}
+
+	steps := []struct {
+		name    string
+		do      func()
+		vaState types.State
+		calls   uint64
+	}{
+		{"add 4 backends", func() { setRSs(rss) }, types.Healthy, 1},
+		{"rs0 down", func() { notice(0, types.Unhealthy) }, types.Healthy, 1},
+		{"rs1 down", func() { notice(1, types.Unhealthy) }, types.Healthy, 1},
+		{"rs2 down", func() { notice(2, types.Unhealthy) }, types.Unhealthy, 2},
+		{"rs2 flap up", func() { notice(2, types.Healthy) }, types.Unhealthy, 2},
+		{"rs2 flap down", func() { notice(2, types.Unhealthy) }, types.Unhealthy, 2},
+		{"rs2 up", func() { notice(2, types.Healthy) }, types.Unhealthy, 2},
+		{"rs1 up", func() { notice(1, types.Healthy) }, types.Healthy, 3},
+		{"rs3 healthy", func() { notice(3, types.Healthy) }, types.Healthy, 3},
+		{"remove rs0,rs1", func() { setRSs(rss[2:]) }, types.Healthy, 3},
+		{"remove all", func() { setRSs(nil) }, types.Unhealthy, 4},
+		{"add rs0 back", func() { setRSs(rss[:1]) }, types.Unhealthy, 4},
+		{"add rs1-rs3 back", func() { setRSs(rss) }, types.Healthy, 5},
+	}
+	for _, step := range steps {
+		step.do()
+		calls := pumpVA(va)
+		if va.state != step.vaState {
+			t.Errorf("%s: expect VA state %v, got %v", step.name, step.vaState, va.state)
+		}
+		if calls != step.calls {
+			t.Errorf("%s: expect %d VA actioner calls in total, got %d", step.name, step.calls, calls)
+		}
+	}
+}
+
+func TestVSQuorumDebounce(t *testing.T) {
+	svc := &comm.VirtualServer{
+		Version:   1,
+		Addr:      utils.L3L4Addr{IP: net.ParseIP("192.168.100.2"), Port: 80, Proto: utils.IPProtoTCP},
+		DestCheck: checker.CheckMethodNone,
+	}
+	var rss []comm.RealServer
+	for i := 1; i <= 2; i++ {
+		rss = append(rss, comm.RealServer{
+			Addr:   utils.L3L4Addr{IP: net.ParseIP(fmt.Sprintf("192.168.201.%d", i)), Port: 8080, Proto: utils.IPProtoTCP},
+			Weight: 100,
+		})
+	}
+
+	va, vs := newTestVS(t, svc, &QuorumConf{
+		QuorumCount:     1,
+		QuorumUp:        1,
+		QuorumDown:      1,
+		QuorumInitState: QuorumInitUp,
+	})
+	defer vs.cleanup()
+	vs.conf.DebounceWindow = time.Minute // backends removed are kept checked
+
+	setRSs := func(rss []comm.RealServer) {
+		svc.RSs = rss
+		vs.doUpdate(&VSConfExt{VSConf: *vs.conf.DeepCopy(), vs: *svc.DeepCopy()})
+	}
+
+	steps := []struct {
+		name     string
+		do       func()
+		backends int
+		vaState  types.State
+		calls    uint64
+	}{
+		{"add 2 backends", func() { setRSs(rss) }, 2, types.Healthy, 1},
+		{"remove rs0", func() { setRSs(rss[1:]) }, 2, types.Healthy, 1},
+		{"remove all", func() { setRSs(nil) }, 2, types.Unhealthy, 2},
+		{"add rs0 back", func() { setRSs(rss[:1]) }, 2, types.Healthy, 3},
+	}
+	for _, step := range steps {
+		step.do()
+		calls := pumpVA(va)
+		if len(vs.backends) != step.backends {
+			t.Errorf("%s: expect %d backends kept, got %d", step.name, step.backends, len(vs.backends))
+		}
+		if va.state != step.vaState {
+			t.Errorf("%s: expect VA state %v, got %v", step.name, step.vaState, va.state)
+		}
+		if calls != step.calls {
+			t.Errorf("%s: expect %d VA actioner calls in total, got %d", step.name, step.calls, calls)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+// /*
+// Copyright 2025 IQiYi Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// */
+
+package manager
+
+import (
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// weightSetter pushes the weight of backend `id` to dpvs.
+type weightSetter func(id CheckerID, weight uint) error
+
+type weightRamp struct {
+	from  uint // ramp-start-weight
+	to    uint // the configured weight
+	steps uint
+	step  uint // current step, in range [0, steps]
+
+	interval time.Duration
+	next     time.Time
+}
+
+func (r *weightRamp) weight() uint {
+	if r.step >= r.steps || r.from >= r.to {
+		return r.to
+	}
+	return r.from + (r.to-r.from)*r.step/r.steps
+}
+
+// rampTracker implements slow start of backends recovered from Unhealthy. It
+// keeps a weight ramp per backend, and pushes weight updates step by step with
+// the weightSetter.
+//
+// Notes on daemon restart: the intermediate weights are written to dpvs, and
+// the configured weight is not recoverable from dpvs if healthcheck restarts in
+// the middle of a ramp. Backends are in Unknown state after restart, and their
+// first Healthy notice does not start a ramp, so the weight fetched from dpvs
+// is pushed as it is.
+//
+// rampTracker is not thread-safe, and should be accessed only in VS's loop.
+type rampTracker struct {
+	conf   RampConf
+	ramps  map[CheckerID]*weightRamp
+	setter weightSetter
+}
+
+func newRampTracker(conf *RampConf, setter weightSetter) *rampTracker {
+	return &rampTracker{
+		conf:   *conf,
+		ramps:  make(map[CheckerID]*weightRamp),
+		setter: setter,
+	}
+}
+
+func (t *rampTracker) enabled() bool {
+	return t.conf.RampDuration > 0 && t.conf.RampSteps > 0
+}
+
+// setConf updates the RampConf, which takes effect on ramps started afterwards.
+func (t *rampTracker) setConf(conf *RampConf) {
+	t.conf = *conf
+}
+
+// weight returns the current ramp weight of backend `id`, and whether it's ramping.
+func (t *rampTracker) weight(id CheckerID) (uint, bool) {
+	r, ok := t.ramps[id]
+	if !ok {
+		return 0, false
+	}
+	return r.weight(), true
+}
+
+func (t *rampTracker) ramping(id CheckerID) bool {
+	_, ok := t.ramps[id]
+	return ok
+}
+
+// start begins ramping backend `id` up to weight `to`, and pushes the starting
+// weight. It returns false if slow start is disabled, and the caller should
+// push the full weight itself. A backend already ramping is not restarted.
+func (t *rampTracker) start(id CheckerID, to uint, now time.Time) bool {
+	if !t.enabled() {
+		return false
+	}
+	if _, ok := t.ramps[id]; ok {
+		return true
+	}
+	r := &weightRamp{
+		from:     t.conf.RampStartWeight,
+		to:       to,
+		steps:    t.conf.RampSteps,
+		interval: t.conf.RampStepInterval(),
+	}
+	r.next = now.Add(r.interval)
+	t.ramps[id] = r
+	glog.V(5).Infof("backend %s starts ramping weight %d->%d in %d steps over %v",
+		id, r.from, r.to, r.steps, t.conf.RampDuration)
+	if err := t.setter(id, r.weight()); err != nil {
+		glog.Warningf("backend %s set ramp weight %d failed: %v", id, r.weight(), err)
+	}
+	return true
+}
+
+// cancel stops ramping backend `id` immediately.
+func (t *rampTracker) cancel(id CheckerID) {
+	if _, ok := t.ramps[id]; ok {
+		glog.V(5).Infof("backend %s ramping canceled", id)
+		delete(t.ramps, id)
+	}
+}
+
+// tick advances all ramps due at `now`. A step failed to push is retried
+// in the next tick.
+func (t *rampTracker) tick(now time.Time) {
+	for id, r := range t.ramps {
+		if now.Before(r.next) {
+			continue
+		}
+		r.step++
+		if err := t.setter(id, r.weight()); err != nil {
+			glog.Warningf("backend %s set ramp weight %d failed: %v", id, r.weight(), err)
+			r.step--
+			continue
+		}
+		if r.step >= r.steps {
+			glog.V(5).Infof("backend %s ramping finished with weight %d", id, r.to)
+			delete(t.ramps, id)
+			continue
+		}
+		r.next = r.next.Add(r.interval)
+		if r.next.Before(now) {
+			r.next = now
+		}
+	}
+}
@@ -0,0 +1,136 @@
+// /*
+// Copyright 2025 IQiYi Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// */
+
+package manager
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// fakeWeightSetter records the sequence of weight updates per backend.
+type fakeWeightSetter struct {
+	updates map[CheckerID][]uint
+	fail    bool
+}
+
+func (s *fakeWeightSetter) set(id CheckerID, weight uint) error {
+	if s.fail {
+		return errors.New("fake failure")
+	}
+	if s.updates == nil {
+		s.updates = make(map[CheckerID][]uint)
+	}
+	s.updates[id] = append(s.updates[id], weight)
+	return nil
+}
+
+var testRampConf = RampConf{
+	RampStartWeight: 10,
+	RampDuration:    4 * time.Second,
+	RampSteps:       4,
+}
+
+func TestRampSequence(t *testing.T) {
+	setter := &fakeWeightSetter{}
+	ramps := newRampTracker(&testRampConf, setter.set)
+	now := time.Unix(1700000000, 0)
+
+	if !ramps.start("rs1", 100, now) {
+		t.Fatal("ramp not started")
+	}
+	for i := 0; i < 6; i++ {
+		now = now.Add(500 * time.Millisecond)
+		ramps.tick(now)
+		// overlapping healthy results must not restart the ramp
+		ramps.start("rs1", 100, now)
+	}
+	now = now.Add(time.Second)
+	ramps.tick(now)
+	now = now.Add(time.Second)
+	ramps.tick(now) // finished already, no more updates
+
+	expect := []uint{10, 32, 55, 77, 100}
+	if !reflect.DeepEqual(setter.updates["rs1"], expect) {
+		t.Errorf("expect weight updates %v, got %v", expect, setter.updates["rs1"])
+	}
+	if ramps.ramping("rs1") {
+		t.Errorf("rs1 still ramping after finished")
+	}
+}
+
+func TestRampCancelAndPerBackend(t *testing.T) {
+	setter := &fakeWeightSetter{}
+	ramps := newRampTracker(&testRampConf, setter.set)
+	now := time.Unix(1700000000, 0)
+
+	ramps.start("rs1", 100, now)
+	now = now.Add(time.Second)
+	ramps.tick(now)
+	ramps.start("rs2", 50, now)
+	now = now.Add(time.Second)
+	ramps.tick(now)
+
+	// rs1 goes unhealthy mid-ramp
+	ramps.cancel("rs1")
+	if w, ok := ramps.weight("rs1"); ok {
+		t.Errorf("rs1 ramp weight %d exists after canceled", w)
+	}
+	for i := 0; i < 4; i++ {
+		now = now.Add(time.Second)
+		ramps.tick(now)
+	}
+
+	if expect := []uint{10, 32, 55}; !reflect.DeepEqual(setter.updates["rs1"], expect) {
+		t.Errorf("rs1: expect weight updates %v, got %v", expect, setter.updates["rs1"])
+	}
+	if expect := []uint{10, 20, 30, 40, 50}; !reflect.DeepEqual(setter.updates["rs2"], expect) {
+		t.Errorf("rs2: expect weight updates %v, got %v", expect, setter.updates["rs2"])
+	}
+
+	// a new recovery restarts the ramp from the start weight
+	ramps.start("rs1", 100, now)
+	if w, _ := ramps.weight("rs1"); w != 10 {
+		t.Errorf("rs1: expect restarted ramp weight 10, got %d", w)
+	}
+}
+
+func TestRampRetryAndDisabled(t *testing.T) {
+	setter := &fakeWeightSetter{}
+	ramps := newRampTracker(&testRampConf, setter.set)
+	now := time.Unix(1700000000, 0)
+
+	ramps.start("rs1", 100, now)
+	setter.fail = true
+	now = now.Add(time.Second)
+	ramps.tick(now)
+	setter.fail = false
+	now = now.Add(time.Second)
+	ramps.tick(now)
+	if expect := []uint{10, 32}; !reflect.DeepEqual(setter.updates["rs1"], expect) {
+		t.Errorf("expect weight updates %v after retry, got %v", expect, setter.updates["rs1"])
+	}
+
+	disabled := newRampTracker(&RampConf{RampStartWeight: 1, RampSteps: 10}, setter.set)
+	if disabled.start("rs2", 100, now) {
+		t.Errorf("ramp started while disabled")
+	}
+	if len(setter.updates["rs2"]) > 0 {
+		t.Errorf("unexpected weight updates while disabled: %v", setter.updates["rs2"])
+	}
+}
@@ -0,0 +1,323 @@
+// /*
+// Copyright 2025 IQiYi Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// */
+
+package manager
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/checker"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/comm"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/types"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/utils"
+)
+
+const reloadConfBase = `
+global:
+  virtual-address:
+    actioner: Blank
+  virtual-server:
+    method: 1
+    interval: 100ms
+    timeout: 50ms
+    actioner: Blank
+`
+
+// reloadEnv runs the config file reloader and service lister of a Manager with
+// services from a fake dpvs.
+type reloadEnv struct {
+	t        *testing.T
+	m        *Manager
+	filename string
+	cancel   context.CancelFunc
+	tasks    sync.WaitGroup
+}
+
+func newReloadEnv(t *testing.T, svcs []comm.VirtualServer) *reloadEnv {
+	savedDelays := []time.Duration{VAStartDelayMax, VSStartDelayMax, CheckerStartDelayMax}
+	savedDB, savedManager := targetDB, GetAppManager()
+	VAStartDelayMax, VSStartDelayMax, CheckerStartDelayMax = time.Millisecond,
+		time.Millisecond, time.Millisecond
+	targetDB = NewTargetDB()
+
+	dir, err := ioutil.TempDir("", "healthcheck-reload")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	appConf := types.DefaultAppConf
+	appConf.HcCfgFile = filepath.Join(dir, "healthcheck.conf")
+	appConf.HcCfgReloadInterval = time.Hour
+	appConf.DpvsServiceListInterval = time.Hour
+	appConf.MetricDelay = time.Hour
+	appConf.AdminAddr = "127.0.0.1:0" // enable targetDB, but never serve
+	m := NewManager(&appConf)
+	m.svcLister.list = func(ctx context.Context) ([]comm.VirtualServer, error) {
+		return svcs, nil
+	}
+	SetAppManager(m)
+
+	env := &reloadEnv{t: t, m: m, filename: appConf.HcCfgFile}
+	t.Cleanup(func() {
+		env.stop()
+		os.RemoveAll(dir)
+		VAStartDelayMax, VSStartDelayMax, CheckerStartDelayMax = savedDelays[0],
+			savedDelays[1], savedDelays[2]
+		targetDB = savedDB
+		SetAppManager(savedManager)
+	})
+	return env
+}
+
+func (env *reloadEnv) start(conf string) {
+	env.write(conf)
+	ctx, cancel := context.WithCancel(context.Background())
+	env.cancel = cancel
+
+	env.tasks.Add(3)
+	go func() {
+		defer env.tasks.Done()
+		env.m.scheduler.Run(ctx)
+	}()
+	go func() {
+		defer env.tasks.Done()
+		for {
+			select {
+			case <-env.m.metricServer.notify:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	go utils.RunTask(env.m.cfgFileReloader, ctx, &env.tasks, nil)
+	for i := 0; i < 300 && env.m.getConf() == nil; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	env.tasks.Add(1)
+	go utils.RunTask(env.m.svcLister, ctx, &env.tasks, nil)
+}
+
+func (env *reloadEnv) stop() {
+	if env.cancel == nil {
+		return
+	}
+	// Stop the service lister before accessing m.vas.
+	env.cancel()
+	env.cancel = nil
+	for _, va := range env.m.vas {
+		va.Stop()
+	}
+	env.m.wg.Wait()
+	env.tasks.Wait()
+}
+
+func (env *reloadEnv) write(conf string) {
+	if err := ioutil.WriteFile(env.filename, []byte(reloadConfBase+conf), 0644); err != nil {
+		env.t.Fatal(err)
+	}
+}
+
+// live returns the method and params of all running checkers.
+func (env *reloadEnv) live() map[string]string {
+	res := make(map[string]string)
+	for _, info := range targetDB.List() {
+		res[fmt.Sprintf("%s/%s", info.VS, info.Target)] = fmt.Sprintf("%s%v", info.Method, info.Params)
+	}
+	return res
+}
+
+func (env *reloadEnv) converge(step string, expect map[string]string) {
+	var live map[string]string
+	for i := 0; i < 200; i++ {
+		if live = env.live(); reflect.DeepEqual(live, expect) {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	env.t.Fatalf("%s: checkers not converged, expect %v, got %v", step, expect, live)
+}
+
+func (env *reloadEnv) info(uuid string) *TargetInfo {
+	for _, info := range targetDB.List() {
+		if fmt.Sprintf("%s/%s", info.VS, info.Target) == uuid {
+			return &info
+		}
+	}
+	env.t.Fatalf("checker %s not found", uuid)
+	return nil
+}
+
+func TestConfigReload(t *testing.T) {
+	newSvc := func(vip string, rips ...string) comm.VirtualServer {
+		svc := comm.VirtualServer{
+			Version:   1,
+			Addr:      utils.L3L4Addr{IP: net.ParseIP(vip), Port: 80, Proto: utils.IPProtoTCP},
+			DestCheck: checker.CheckMethodNone,
+		}
+		for _, rip := range rips {
+			svc.RSs = append(svc.RSs, comm.RealServer{
+				Addr:   utils.L3L4Addr{IP: net.ParseIP(rip), Port: 80, Proto: utils.IPProtoTCP},
+				Weight: 100,
+			})
+		}
+		return svc
+	}
+	svcs := []comm.VirtualServer{
+		newSvc("192.168.100.1", "127.0.0.1", "127.0.0.2"),
+		newSvc("192.168.100.2", "127.0.0.3"),
+	}
+	const (
+		rs1 = "192.168.100.1-TCP-80/127.0.0.1-TCP-80"
+		rs2 = "192.168.100.1-TCP-80/127.0.0.2-TCP-80"
+		rs3 = "192.168.100.2-TCP-80/127.0.0.3-TCP-80"
+	)
+
+	env := newReloadEnv(t, svcs)
+
+	// Step 1: VIP 192.168.100.2 is disabled.
+	env.start(`
+virtual-addresses:
+  192.168.100.2:
+    disable: true
+`)
+	env.converge("step 1", map[string]string{
+		rs1: "nonemap[]",
+		rs2: "nonemap[]",
+	})
+	time.Sleep(300 * time.Millisecond) // wait for some checks
+	before := env.info(rs1)
+	if before.State != types.Healthy.String() {
+		t.Fatalf("step 1: expect %s healthy, got %s", rs1, before.State)
+	}
+
+	// Step 2: enable VIP 192.168.100.2, and change method of 192.168.100.1-TCP-80.
+	// Reloads requested continuously are queued.
+	env.write(`
+virtual-servers:
+  192.168.100.1-TCP-80:
+    method: 4
+    method-params:
+      privileged: "true"
+`)
+	for i := 0; i < 5; i++ {
+		env.m.Reload()
+	}
+	env.converge("step 2", map[string]string{
+		rs1: "pingmap[privileged:true]",
+		rs2: "pingmap[privileged:true]",
+		rs3: "nonemap[]",
+	})
+	after := env.info(rs1)
+	if after.State != before.State || !after.Since.Equal(before.Since) || after.Count < before.Count {
+		t.Errorf("step 2: state of %s not preserved, before %+v, after %+v", rs1, before, after)
+	}
+
+	// Step 3: an invalid config is rejected as a whole.
+	env.write(`
+virtual-addresses:
+  192.168.100.1:
+    disable: true
+virtual-servers:
+  192.168.100.2-TCP-80:
+    method: 4
+    method-params:
+      privileged: "maybe"
+`)
+	env.m.Reload()
+	time.Sleep(300 * time.Millisecond)
+	env.converge("step 3", map[string]string{
+		rs1: "pingmap[privileged:true]",
+		rs2: "pingmap[privileged:true]",
+		rs3: "nonemap[]",
+	})
+
+	// Step 4: disable VIP 192.168.100.1, and change method params of
+	// 192.168.100.2-TCP-80.
+	env.write(`
+virtual-addresses:
+  192.168.100.1:
+    disable: true
+virtual-servers:
+  192.168.100.2-TCP-80:
+    method: 4
+    method-params:
+      privileged: "auto"
+`)
+	env.m.Reload()
+	env.converge("step 4", map[string]string{
+		rs3: "pingmap[privileged:auto]",
+	})
+
+	// Step 5: revert to the initial config.
+	env.write(`
+virtual-addresses:
+  192.168.100.2:
+    disable: true
+`)
+	env.m.Reload()
+	env.converge("step 5", map[string]string{
+		rs1: "nonemap[]",
+		rs2: "nonemap[]",
+	})
+}
+
+func TestConfDiff(t *testing.T) {
+	base := confDefault.DeepCopy()
+	base.vaConf = map[VAID]VAConf{"192.168.100.1": vaConfDefault, "192.168.100.2": vaConfDefault}
+	base.vsConf = map[VSID]VSConf{"192.168.100.1-TCP-80": vsConfDefault}
+
+	if diffs := base.Diff(base.DeepCopy()); len(diffs) != 0 {
+		t.Errorf("expect no diffs, got %v", diffs)
+	}
+
+	other := base.DeepCopy()
+	other.vsGlobal.Interval = time.Minute
+	delete(other.vaConf, "192.168.100.1")
+	va := other.vaConf["192.168.100.2"]
+	va.Disable = true
+	other.vaConf["192.168.100.2"] = va
+	other.vaConf["192.168.100.3"] = vaConfDefault
+	other.vsConf["192.168.100.1-TCP-80"] = VSConf{}
+	expect := []string{
+		"~global/virtual-server",
+		"+virtual-addresses/192.168.100.3",
+		"-virtual-addresses/192.168.100.1",
+		"~virtual-addresses/192.168.100.2",
+		"~virtual-servers/192.168.100.1-TCP-80",
+	}
+	diffs := base.Diff(other)
+	if len(diffs) != len(expect) {
+		t.Fatalf("expect diffs %v, got %v", expect, diffs)
+	}
+	for _, diff := range expect {
+		found := false
+		for _, d := range diffs {
+			found = found || d == diff
+		}
+		if !found {
+			t.Errorf("diff %q not found in %v", diff, diffs)
+		}
+	}
+}
@@ -0,0 +1,359 @@
+// /*
+// Copyright 2025 IQiYi Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// */
+
+package manager
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// CheckJob is a health check procedure scheduled by the Scheduler.
+// It MUST return in or immediately after its check timeout, and SHOULD return
+// as soon as possible when ctx is canceled.
+type CheckJob func(ctx context.Context)
+
+// SchedulerStats holds the statistics of a Scheduler.
+type SchedulerStats struct {
+	Targets   int    // number of targets scheduled currently
+	Scheduled uint64 // number of jobs dispatched to the worker pool
+	Finished  uint64 // number of jobs finished
+	Delayed   uint64 // number of jobs delayed because the worker pool is saturated
+	Skipped   uint64 // number of jobs skipped because the previous one is still running
+}
+
+func (s SchedulerStats) String() string {
+	return fmt.Sprintf("targets %d, scheduled %d, finished %d, delayed %d, skipped %d",
+		s.Targets, s.Scheduled, s.Finished, s.Delayed, s.Skipped)
+}
+
+type schedEntry struct {
+	id       string
+	interval time.Duration
+	job      CheckJob
+	next     time.Time // next scheduling time
+	index    int       // index in the schedHeap, -1 if not in the heap
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	running int32 // accessed atomically
+}
+
+type schedHeap []*schedEntry
+
+func (h schedHeap) Len() int           { return len(h) }
+func (h schedHeap) Less(i, j int) bool { return h[i].next.Before(h[j].next) }
+func (h schedHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *schedHeap) Push(x interface{}) {
+	e := x.(*schedEntry)
+	e.index = len(*h)
+	*h = append(*h, e)
+}
+
+func (h *schedHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	e := old[n-1]
+	old[n-1] = nil
+	e.index = -1
+	*h = old[:n-1]
+	return e
+}
+
+type schedTask struct {
+	entry *schedEntry
+	due   time.Time
+}
+
+// Scheduler owns all health check invocations. It runs check jobs of each
+// target periodically with a bounded worker pool, and spreads the jobs over
+// their intervals with random jitter to avoid thundering herd.
+//
+// It guarantees at most one in-flight job per target. A job is skipped if the
+// previous one of the same target is still running when it's due.
+type Scheduler struct {
+	workers int
+	jitter  float64 // ratio of interval
+
+	lock    sync.Mutex
+	entries map[string]*schedEntry
+	queue   schedHeap
+
+	wakeup chan struct{}
+	tasks  chan schedTask
+
+	scheduled uint64
+	finished  uint64
+	delayed   uint64
+	skipped   uint64
+
+	// delayObserver is called with the scheduling delay of each job, i.e. the
+	// duration from the time the job is due to the time it starts running.
+	delayObserver func(time.Duration)
+}
+
+// NewScheduler creates a Scheduler with `workers` concurrent check workers,
+// and `jitter` in ratio of check interval (0 <= jitter <= 1).
+func NewScheduler(workers uint, jitter float64) *Scheduler {
+	if workers == 0 {
+		workers = 1
+	}
+	if jitter < 0 {
+		jitter = 0
+	} else if jitter > 1 {
+		jitter = 1
+	}
+	return &Scheduler{
+		workers: int(workers),
+		jitter:  jitter,
+		entries: make(map[string]*schedEntry),
+		queue:   make(schedHeap, 0),
+		wakeup:  make(chan struct{}, 1),
+		tasks:   make(chan schedTask),
+	}
+}
+
+func (s *Scheduler) notify() {
+	select {
+	case s.wakeup <- struct{}{}:
+	default:
+	}
+}
+
+// jittered returns the interval with a random jitter in range of
+// [-jitter/2, jitter/2] of the interval.
+func (s *Scheduler) jittered(interval time.Duration) time.Duration {
+	span := int64(float64(interval) * s.jitter)
+	if span <= 0 {
+		return interval
+	}
+	return interval + time.Duration(rand.Int63n(span)-span/2)
+}
+
+// Add schedules `job` for target `id` every `interval`, replacing the existing
+// one of the same id if any. The first run is spread randomly across the interval.
+func (s *Scheduler) Add(id string, interval time.Duration, job CheckJob) error {
+	if interval <= 0 {
+		return fmt.Errorf("invalid schedule interval %v for %s", interval, id)
+	}
+	if job == nil {
+		return fmt.Errorf("nil check job for %s", id)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	entry := &schedEntry{
+		id:       id,
+		interval: interval,
+		job:      job,
+		next:     time.Now().Add(time.Duration(rand.Int63n(int64(interval)))),
+		index:    -1,
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+
+	s.lock.Lock()
+	if old, ok := s.entries[id]; ok {
+		s.removeLocked(old)
+	}
+	s.entries[id] = entry
+	heap.Push(&s.queue, entry)
+	s.lock.Unlock()
+
+	s.notify()
+	return nil
+}
+
+// Update changes the interval of target `id`. The new interval takes effect
+// since the next scheduling.
+func (s *Scheduler) Update(id string, interval time.Duration) error {
+	if interval <= 0 {
+		return fmt.Errorf("invalid schedule interval %v for %s", interval, id)
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	entry, ok := s.entries[id]
+	if !ok {
+		return fmt.Errorf("target %s not scheduled", id)
+	}
+	entry.interval = interval
+	return nil
+}
+
+// Remove stops scheduling target `id`, and cancels its pending or in-flight job.
+func (s *Scheduler) Remove(id string) {
+	s.lock.Lock()
+	if entry, ok := s.entries[id]; ok {
+		s.removeLocked(entry)
+	}
+	s.lock.Unlock()
+}
+
+func (s *Scheduler) removeLocked(entry *schedEntry) {
+	entry.cancel()
+	if entry.index >= 0 {
+		heap.Remove(&s.queue, entry.index)
+	}
+	delete(s.entries, entry.id)
+}
+
+// Stats returns the current statistics of the Scheduler.
+func (s *Scheduler) Stats() SchedulerStats {
+	s.lock.Lock()
+	targets := len(s.entries)
+	s.lock.Unlock()
+	return SchedulerStats{
+		Targets:   targets,
+		Scheduled: atomic.LoadUint64(&s.scheduled),
+		Finished:  atomic.LoadUint64(&s.finished),
+		Delayed:   atomic.LoadUint64(&s.delayed),
+		Skipped:   atomic.LoadUint64(&s.skipped),
+	}
+}
+
+func (s *Scheduler) worker(ctx context.Context, wg *sync.WaitGroup) {
+	defer wg.Done()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case task := <-s.tasks:
+			entry := task.entry
+			if entry.ctx.Err() == nil { // not removed
+				if s.delayObserver != nil {
+					s.delayObserver(time.Since(task.due))
+				}
+				HealthCheckThreads.RunningInc()
+				entry.job(entry.ctx)
+				HealthCheckThreads.RunningDec()
+				HealthCheckThreads.FinishedInc()
+			}
+			atomic.StoreInt32(&entry.running, 0)
+			atomic.AddUint64(&s.finished, 1)
+		}
+	}
+}
+
+// popDue pops a due entry from the queue and reschedules it, or returns the
+// duration to wait until the earliest entry is due.
+func (s *Scheduler) popDue(now time.Time) (*schedEntry, time.Time, time.Duration) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if len(s.queue) == 0 {
+		return nil, time.Time{}, time.Hour
+	}
+	entry := s.queue[0]
+	if wait := entry.next.Sub(now); wait > 0 {
+		return nil, time.Time{}, wait
+	}
+
+	due := entry.next
+	entry.next = due.Add(s.jittered(entry.interval))
+	if entry.next.Before(now) {
+		// fall behind too much, catch up from now on
+		entry.next = now.Add(s.jittered(entry.interval))
+	}
+	heap.Fix(&s.queue, entry.index)
+	return entry, due, 0
+}
+
+func (s *Scheduler) dispatch(ctx context.Context, entry *schedEntry, due time.Time) {
+	if !atomic.CompareAndSwapInt32(&entry.running, 0, 1) {
+		atomic.AddUint64(&s.skipped, 1)
+		glog.V(5).Infof("Scheduler skipped check %s: the previous one is still running", entry.id)
+		return
+	}
+
+	task := schedTask{entry: entry, due: due}
+	select {
+	case s.tasks <- task:
+		atomic.AddUint64(&s.scheduled, 1)
+		return
+	default:
+	}
+
+	// All workers are busy, wait for an idle one.
+	delayed := atomic.AddUint64(&s.delayed, 1)
+	if delayed&(delayed-1) == 0 { // log at power of 2 times to avoid log flood
+		glog.Warningf("Scheduler worker pool (size %d) saturated, %d checks delayed so far",
+			s.workers, delayed)
+	}
+	select {
+	case s.tasks <- task:
+		atomic.AddUint64(&s.scheduled, 1)
+	case <-entry.ctx.Done():
+		atomic.StoreInt32(&entry.running, 0)
+	case <-ctx.Done():
+		atomic.StoreInt32(&entry.running, 0)
+	}
+}
+
+// Run starts the worker pool and the scheduling loop. It blocks until ctx is done.
+func (s *Scheduler) Run(ctx context.Context) {
+	glog.Infof("Scheduler started with %d workers, jitter %.2f", s.workers, s.jitter)
+
+	wg := &sync.WaitGroup{}
+	for i := 0; i < s.workers; i++ {
+		wg.Add(1)
+		go s.worker(ctx, wg)
+	}
+
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+	for {
+		entry, due, wait := s.popDue(time.Now())
+		if entry != nil {
+			s.dispatch(ctx, entry, due)
+			if ctx.Err() != nil {
+				break
+			}
+			continue
+		}
+
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(wait)
+
+		select {
+		case <-ctx.Done():
+		case <-s.wakeup:
+		case <-timer.C:
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	wg.Wait()
+	glog.Infof("Scheduler finished: %v", s.Stats())
+}
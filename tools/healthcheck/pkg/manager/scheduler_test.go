@@ -0,0 +1,243 @@
+// /*
+// Copyright 2025 IQiYi Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// */
+
+package manager
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSchedulerSingleFlight(t *testing.T) {
+	s := NewScheduler(8, 0)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.Run(ctx)
+
+	var inflight, maxInflight, runs int32
+	job := func(ctx context.Context) {
+		n := atomic.AddInt32(&inflight, 1)
+		for {
+			m := atomic.LoadInt32(&maxInflight)
+			if n <= m || atomic.CompareAndSwapInt32(&maxInflight, m, n) {
+				break
+			}
+		}
+		atomic.AddInt32(&runs, 1)
+		time.Sleep(50 * time.Millisecond) // much longer than the interval
+		atomic.AddInt32(&inflight, -1)
+	}
+	if err := s.Add("slow", 5*time.Millisecond, job); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(300 * time.Millisecond)
+	s.Remove("slow")
+
+	if max := atomic.LoadInt32(&maxInflight); max != 1 {
+		t.Errorf("expected at most 1 in-flight check per target, got %d", max)
+	}
+	if atomic.LoadInt32(&runs) == 0 {
+		t.Errorf("check never ran")
+	}
+	if stats := s.Stats(); stats.Skipped == 0 {
+		t.Errorf("expected skipped checks, got stats: %v", stats)
+	}
+}
+
+func TestSchedulerRemoveCancels(t *testing.T) {
+	s := NewScheduler(2, 0)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.Run(ctx)
+
+	started := make(chan struct{}, 1)
+	returned := make(chan struct{})
+	job := func(ctx context.Context) {
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		<-ctx.Done()
+		close(returned)
+	}
+	if err := s.Add("target", time.Millisecond, job); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-started:
+	case <-time.After(time.Second):
+		t.Fatal("check not started")
+	}
+
+	s.Remove("target")
+	select {
+	case <-returned:
+	case <-time.After(100 * time.Millisecond):
+		t.Fatal("in-flight check not canceled after target removed")
+	}
+	if stats := s.Stats(); stats.Targets != 0 {
+		t.Errorf("expected no targets after removal, got stats: %v", stats)
+	}
+}
+
+func TestSchedulerBackpressure(t *testing.T) {
+	s := NewScheduler(1, 0.1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.Run(ctx)
+
+	job := func(ctx context.Context) {
+		time.Sleep(20 * time.Millisecond)
+	}
+	for i := 0; i < 4; i++ {
+		if err := s.Add(fmt.Sprintf("target-%d", i), 10*time.Millisecond, job); err != nil {
+			t.Fatal(err)
+		}
+	}
+	time.Sleep(200 * time.Millisecond)
+
+	if stats := s.Stats(); stats.Delayed == 0 {
+		t.Errorf("expected delayed checks with a saturated pool, got stats: %v", stats)
+	}
+}
+
+func TestSchedulerInvalidArgs(t *testing.T) {
+	s := NewScheduler(1, 0)
+	if err := s.Add("x", 0, func(context.Context) {}); err == nil {
+		t.Errorf("expected error for zero interval")
+	}
+	if err := s.Add("x", time.Second, nil); err == nil {
+		t.Errorf("expected error for nil job")
+	}
+	if err := s.Update("not-exist", time.Second); err == nil {
+		t.Errorf("expected error for updating unscheduled target")
+	}
+}
+
+// delayPercentile returns the p-th percentile (0 < p <= 100) of the delays.
+func delayPercentile(delays []time.Duration, p float64) time.Duration {
+	if len(delays) == 0 {
+		return 0
+	}
+	sort.Slice(delays, func(i, j int) bool { return delays[i] < delays[j] })
+	idx := int(float64(len(delays))*p/100+0.5) - 1
+	if idx < 0 {
+		idx = 0
+	} else if idx >= len(delays) {
+		idx = len(delays) - 1
+	}
+	return delays[idx]
+}
+
+type delayRecorder struct {
+	lock   sync.Mutex
+	delays []time.Duration
+}
+
+func (r *delayRecorder) observe(d time.Duration) {
+	r.lock.Lock()
+	r.delays = append(r.delays, d)
+	r.lock.Unlock()
+}
+
+const (
+	benchTargets  = 50000
+	benchInterval = time.Second
+	benchDuration = 3 * time.Second
+	benchCheck    = time.Millisecond // simulated check latency
+	benchWorkers  = 256
+)
+
+// goroutineSampler samples the goroutine number periodically and keeps the max.
+func goroutineSampler(ctx context.Context) *int64 {
+	var max int64
+	go func() {
+		ticker := time.NewTicker(10 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if n := int64(runtime.NumGoroutine()); n > atomic.LoadInt64(&max) {
+					atomic.StoreInt64(&max, n)
+				}
+			}
+		}
+	}()
+	return &max
+}
+
+// BenchmarkCheckGoroutinePerTarget simulates the legacy model: each target has
+// its own ticker goroutine which fires every check in a new goroutine.
+func BenchmarkCheckGoroutinePerTarget(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		rec := &delayRecorder{}
+		ctx, cancel := context.WithTimeout(context.Background(), benchDuration)
+		maxGoroutines := goroutineSampler(ctx)
+		wg := &sync.WaitGroup{}
+		for j := 0; j < benchTargets; j++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				ticker := time.NewTicker(benchInterval)
+				defer ticker.Stop()
+				for {
+					select {
+					case <-ctx.Done():
+						return
+					case due := <-ticker.C:
+						go func() {
+							rec.observe(time.Since(due))
+							time.Sleep(benchCheck)
+						}()
+					}
+				}
+			}()
+		}
+		wg.Wait()
+		cancel()
+		b.ReportMetric(float64(atomic.LoadInt64(maxGoroutines)), "goroutines")
+		b.ReportMetric(float64(delayPercentile(rec.delays, 99).Microseconds()), "p99-delay-us")
+	}
+}
+
+// BenchmarkCheckScheduler runs the same workload with the pooled Scheduler.
+func BenchmarkCheckScheduler(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		rec := &delayRecorder{}
+		s := NewScheduler(benchWorkers, 0.1)
+		s.delayObserver = rec.observe
+		ctx, cancel := context.WithTimeout(context.Background(), benchDuration)
+		maxGoroutines := goroutineSampler(ctx)
+		for j := 0; j < benchTargets; j++ {
+			s.Add(fmt.Sprintf("target-%d", j), benchInterval, func(ctx context.Context) {
+				time.Sleep(benchCheck)
+			})
+		}
+		s.Run(ctx)
+		cancel()
+		b.ReportMetric(float64(atomic.LoadInt64(maxGoroutines)), "goroutines")
+		b.ReportMetric(float64(delayPercentile(rec.delays, 99).Microseconds()), "p99-delay-us")
+		b.ReportMetric(float64(s.Stats().Delayed), "delayed")
+	}
+}
@@ -29,9 +29,8 @@ import (
 	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/utils"
 )
 
-const (
-	VSStartDelayMax = 3 * time.Second
-)
+// VSStartDelayMax is a variable only for tests to shorten it.
+var VSStartDelayMax = 3 * time.Second
 
 var VAThreads ThreadStats
 
@@ -51,8 +50,9 @@ type VAVS struct {
 }
 
 type VSState struct {
-	id    VSID
-	state types.State
+	id     VSID
+	state  types.State
+	detail *actioner.ActionDetail // why the state changed, nil if unknown
 }
 
 type VirtualAddress struct {
@@ -192,12 +192,25 @@ func (va *VirtualAddress) judge() types.State {
 	}
 }
 
-func (va *VirtualAddress) actUP() error {
-	if _, err := va.actioner.Act(types.Healthy, va.conf.ActionTimeout); err != nil {
+// actData returns the data passed to actioner with the optional detail.
+func actData(detail *actioner.ActionDetail) []interface{} {
+	if detail == nil {
+		return nil
+	}
+	return []interface{}{detail}
+}
+
+func (va *VirtualAddress) actUP(detail *actioner.ActionDetail) error {
+	if _, err := va.m.dispatcher.Dispatch(string(va.id), va.conf.Actioner, va.conf.ActionTimeout,
+		func(timeout time.Duration) (interface{}, error) {
+			return va.actioner.Act(types.Healthy, timeout, actData(detail)...)
+		}); err != nil {
+		va.logAction(types.Healthy, err)
 		va.stats.upFailed++
 		va.metricTaint = true
 		return err
 	}
+	va.logAction(types.Healthy, nil)
 	glog.V(4).Infof("VA %v state changed to %v (upVSs:%d, downVSs:%d)",
 		va.id, types.Healthy, va.upVSs, va.downVSs)
 	va.state = types.Healthy
@@ -207,12 +220,17 @@ func (va *VirtualAddress) actUP() error {
 	return nil
 }
 
-func (va *VirtualAddress) actDOWN() error {
-	if _, err := va.actioner.Act(types.Unhealthy, va.conf.ActionTimeout); err != nil {
+func (va *VirtualAddress) actDOWN(detail *actioner.ActionDetail) error {
+	if _, err := va.m.dispatcher.Dispatch(string(va.id), va.conf.Actioner, va.conf.ActionTimeout,
+		func(timeout time.Duration) (interface{}, error) {
+			return va.actioner.Act(types.Unhealthy, timeout, actData(detail)...)
+		}); err != nil {
+		va.logAction(types.Unhealthy, err)
 		va.stats.downFailed++
 		va.metricTaint = true
 		return err
 	}
+	va.logAction(types.Unhealthy, nil)
 	glog.V(4).Infof("VA %v state changed to %v (upVSs:%d, downVSs:%d)",
 		va.id, types.Unhealthy, va.upVSs, va.downVSs)
 	va.state = types.Unhealthy
@@ -222,11 +240,33 @@ func (va *VirtualAddress) actDOWN() error {
 	return nil
 }
 
-func (va *VirtualAddress) act(state types.State) error {
+// logAction logs the action outcome of changing VA state to `state`.
+func (va *VirtualAddress) logAction(state types.State, err error) {
+	ev := &utils.LogEvent{
+		Level:  utils.LogLevelInfo,
+		Event:  utils.LogEventAction,
+		Target: string(va.id),
+		VIP:    string(va.id),
+		Method: va.conf.Actioner,
+		State:  state.String(),
+	}
+	if err == nil {
+		ev.Message = fmt.Sprintf("VA %s changed to %s by %s", va.id, state, va.conf.Actioner)
+		eventLog.Log(ev)
+		return
+	}
+	ev.Level = utils.LogLevelWarning
+	ev.Error = err.Error()
+	ev.Message = fmt.Sprintf("VA %s change to %s by %s failed: %v", va.id, state, va.conf.Actioner, err)
+	eventLog.LogFailure("VA actions failed", ev)
+}
+
+// act changes VA state with actioner, and `detail` tells why if not nil.
+func (va *VirtualAddress) act(state types.State, detail *actioner.ActionDetail) error {
 	if state == types.Unhealthy {
-		return va.actDOWN()
+		return va.actDOWN(detail)
 	}
-	return va.actUP()
+	return va.actUP(detail)
 }
 
 func (va *VirtualAddress) doUpdate(conf *VAConfExt) {
@@ -261,7 +301,7 @@ func (va *VirtualAddress) doUpdate(conf *VAConfExt) {
 				vacf.Actioner, vacf.ActionParams)
 			if va.state == types.Healthy {
 				// Switch state to Unhealthy before changing Actioner to avoid inconsistency.
-				if err := va.actDOWN(); err != nil {
+				if err := va.actDOWN(nil); err != nil {
 					glog.Errorf("Switch state to %s before changing VA %s actioner failed: %v, abort change",
 						types.Unhealthy, va.id, err)
 					skip = true
@@ -316,7 +356,7 @@ func (va *VirtualAddress) doUpdate(conf *VAConfExt) {
 	if len(staled) > 0 {
 		vaState := va.judge()
 		if vaState != va.state {
-			if err := va.act(vaState); err != nil {
+			if err := va.act(vaState, nil); err != nil {
 				glog.Warningf("VA %s state change to %v failed: %v", va.id, vaState, err)
 			}
 		}
@@ -325,7 +365,7 @@ func (va *VirtualAddress) doUpdate(conf *VAConfExt) {
 	// Create new or update existing VSs
 	for _, svc := range conf.vss {
 		vsid := VSID(svc.Addr.String())
-		vsConf := va.m.conf.GetVSConf(vsid)
+		vsConf := va.m.getConf().GetVSConf(vsid)
 		vavs, ok := va.vss[vsid]
 		if !ok { // create
 			vs, err := NewVS(&svc, vsConf, va)
@@ -389,7 +429,7 @@ func (va *VirtualAddress) recvNotice(state *VSState) {
 		}
 		vaState := va.judge()
 		if vaState != va.state {
-			if err := va.act(vaState); err != nil {
+			if err := va.act(vaState, state.detail); err != nil {
 				glog.Warningf("VA %s state change to %v failed: %v", va.id, state, err)
 			}
 		}
@@ -400,7 +440,7 @@ func (va *VirtualAddress) recvNotice(state *VSState) {
 		}
 		vaState := va.judge()
 		if vaState != va.state {
-			if err := va.act(vaState); err != nil {
+			if err := va.act(vaState, state.detail); err != nil {
 				glog.Warningf("VA %s state change to %v failed: %v", va.id, state, err)
 			}
 		}
@@ -426,7 +466,7 @@ func (va *VirtualAddress) doResync() {
 	}
 
 	if (state != va.state) || (actionState != types.Unknown && state != actionState) {
-		if err := va.act(state); err != nil {
+		if err := va.act(state, nil); err != nil {
 			glog.Warningf("VA %s state resync to %s failed: %v", va.id, state, err)
 		} else {
 			glog.Infof("VA %s state resync to %s succeeded", va.id, state)
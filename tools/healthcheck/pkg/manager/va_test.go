@@ -0,0 +1,111 @@
+// /*
+// Copyright 2025 IQiYi Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// */
+
+package manager
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/actioner"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/checker"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/comm"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/types"
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/utils"
+)
+
+// recordingActioner records the data passed to the actioner it wraps.
+type recordingActioner struct {
+	actioner.ActionMethod
+	data [][]interface{}
+}
+
+func (a *recordingActioner) Act(signal types.State, timeout time.Duration,
+	data ...interface{}) (interface{}, error) {
+	a.data = append(a.data, data)
+	return a.ActionMethod.Act(signal, timeout, data...)
+}
+
+func TestCheckerActionDetail(t *testing.T) {
+	ck := newTestFlapChecker(t, &FlapConf{})
+	ck.doCheckResult(&checkResult{state: types.Unhealthy, timeout: time.Second,
+		result: &checker.CheckResult{
+			State:  types.Unhealthy,
+			Reason: checker.ReasonConnRefused,
+			Detail: "connection refused",
+		}})
+	notice := <-ck.vs.notify
+	detail := notice.detail
+	if detail == nil || detail.Target.String() != ck.target.String() || detail.Method != "none" ||
+		time.Since(detail.Time) > time.Second ||
+		detail.Message != "Unhealthy, reason: conn-refused, connection refused" {
+		t.Fatalf("unexpected detail: %v", detail)
+	}
+	if s := detail.String(); !strings.HasPrefix(s, "192.168.200.1-TCP-8080 by none check at ") ||
+		!strings.HasSuffix(s, ": Unhealthy, reason: conn-refused, connection refused") {
+		t.Errorf("unexpected detail string: %s", s)
+	}
+
+	ck.doCheckResult(&checkResult{state: types.Healthy, timeout: time.Second,
+		result: &checker.CheckResult{State: types.Healthy}})
+	if notice := <-ck.vs.notify; notice.detail == nil || notice.detail.Message != "Healthy" {
+		t.Errorf("unexpected detail: %v", notice.detail)
+	}
+	if s := (*actioner.ActionDetail)(nil).String(); s != "" {
+		t.Errorf("unexpected nil detail string: %q", s)
+	}
+}
+
+func TestVAActionDetail(t *testing.T) {
+	svc := &comm.VirtualServer{
+		Version:   1,
+		Addr:      utils.L3L4Addr{IP: net.ParseIP("192.168.100.1"), Port: 80, Proto: utils.IPProtoTCP},
+		DestCheck: checker.CheckMethodNone,
+		RSs: []comm.RealServer{{
+			Addr:   utils.L3L4Addr{IP: net.ParseIP("192.168.200.1"), Port: 8080, Proto: utils.IPProtoTCP},
+			Weight: 100,
+		}},
+	}
+	va, vs := newTestVS(t, svc, &vsConfDefault.QuorumConf)
+	defer vs.cleanup()
+	recorder := &recordingActioner{ActionMethod: va.actioner}
+	va.actioner = recorder
+
+	vs.doUpdate(&VSConfExt{VSConf: *vs.conf.DeepCopy(), vs: *svc.DeepCopy()})
+	pumpVA(va)
+	ckid := CheckerID(svc.RSs[0].Addr.String())
+	detail := &actioner.ActionDetail{
+		Target:  svc.RSs[0].Addr.DeepCopy(),
+		Method:  "tcp",
+		Message: "Unhealthy, reason: conn-refused",
+		Time:    time.Now(),
+	}
+	vs.recvNotice(&BackendState{id: ckid, state: types.Unhealthy, detail: detail})
+	pumpVA(va)
+	vs.recvNotice(&BackendState{id: ckid, state: types.Healthy})
+	pumpVA(va)
+
+	// VA actions: up on backends added, down with the detail, up without detail.
+	if len(recorder.data) != 3 || len(recorder.data[0]) != 0 || len(recorder.data[1]) != 1 ||
+		recorder.data[1][0] != detail || len(recorder.data[2]) != 0 {
+		t.Errorf("unexpected VA action data: %v", recorder.data)
+	}
+	if va.state != types.Healthy {
+		t.Errorf("expect VA state %v, got %v", types.Healthy, va.state)
+	}
+}
@@ -17,6 +17,7 @@
 package manager
 
 import (
+	"errors"
 	"fmt"
 	"math/rand"
 	"sync"
@@ -30,10 +31,10 @@ import (
 	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/utils"
 )
 
-const (
-	DefaultCheckerWeight uint = 1
-	CheckerStartDelayMax      = 3 * time.Second
-)
+const DefaultCheckerWeight uint = 1
+
+// CheckerStartDelayMax is a variable only for tests to shorten it.
+var CheckerStartDelayMax = 3 * time.Second
 
 var VSThreads ThreadStats
 
@@ -51,12 +52,16 @@ type VSBackend struct {
 	version      uint64      // deployment version, may > vs's version due to partial update
 	state        types.State // health state in dpvs
 	checkerState types.State // health state reported from Checker
+	removed      time.Time   // when removed from dpvs, zero if present in dpvs
+	degraded     bool        // slower than degraded-latency, see LatencyConf
 	checker      *Checker    // Restriction: access only to its thread-safe members
 }
 
 type BackendState struct {
-	id    CheckerID
-	state types.State
+	id       CheckerID
+	state    types.State
+	degraded bool                   // slower than degraded-latency, only for Healthy
+	detail   *actioner.ActionDetail // why the state changed, nil if unknown
 }
 
 type VirtualService struct {
@@ -74,8 +79,12 @@ type VirtualService struct {
 	upBackends   int
 
 	backends map[CheckerID]*VSBackend
+	quorum   *quorumTracker
+	ramps    *rampTracker
 	actioner actioner.ActionMethod
 	resync   *time.Ticker // timer to resync backend state to dpvs
+	ramp     *time.Ticker // timer to step backend weight ramps, nil if disabled
+	debounce *time.Timer  // timer to purge removed backends, nil if none removed
 
 	// metric members
 	metricTaint  bool
@@ -89,6 +98,31 @@ type VirtualService struct {
 	quit   chan bool
 }
 
+// newVSActioner creates the actioner of VS `vsid`. The actions the actioner
+// starts on its own, such as the removals after drain-timeout of WeightDrain,
+// are paced by the action dispatcher as well.
+func newVSActioner(va *VirtualAddress, vsid VSID, kind string, target *utils.L3L4Addr,
+	params map[string]string) (actioner.ActionMethod, error) {
+	act, err := actioner.NewActioner(kind, target, params, va.m.appConf.DpvsAgentAddr)
+	if err != nil {
+		return nil, err
+	}
+	if m, ok := act.(actioner.ActionMethodWithDispatch); ok {
+		m.SetDispatch(func(item string, timeout time.Duration,
+			job func(timeout time.Duration) (interface{}, error)) (interface{}, error) {
+			return va.m.dispatcher.Dispatch(fmt.Sprintf("%s/%s/%s", vsid, kind, item), kind, timeout, job)
+		})
+	}
+	return act, nil
+}
+
+// stopActioner stops the background work of actioner `act` if any.
+func stopActioner(act actioner.ActionMethod) {
+	if m, ok := act.(actioner.ActionMethodWithStop); ok {
+		m.Stop()
+	}
+}
+
 func NewVS(sub *comm.VirtualServer, conf *VSConf, va *VirtualAddress) (*VirtualService, error) {
 	// Notes: conf has been validated, do not repeat the work!
 	// if err := conf.Valid(); err != nil {
@@ -102,8 +136,7 @@ func NewVS(sub *comm.VirtualServer, conf *VSConf, va *VirtualAddress) (*VirtualS
 		confCopied.Method = confCopied.Method.TranslateAuto(sub.Addr.Proto)
 	}
 
-	act, err := actioner.NewActioner(conf.Actioner, &sub.Addr, confCopied.ActionParams,
-		va.m.appConf.DpvsAgentAddr)
+	act, err := newVSActioner(va, vsid, conf.Actioner, &sub.Addr, confCopied.ActionParams)
 	if err != nil {
 		return nil, fmt.Errorf("VS actioner created failed: %v", err)
 	}
@@ -118,8 +151,10 @@ func NewVS(sub *comm.VirtualServer, conf *VSConf, va *VirtualAddress) (*VirtualS
 		since: time.Now(),
 
 		backends: make(map[CheckerID]*VSBackend),
+		quorum:   newQuorumTracker(&confCopied.QuorumConf),
 		actioner: act,
 		resync:   nil, // init it in func `Run`
+		ramp:     nil, // init it in func `Run`
 
 		metricTaint:  true,
 		metricTicker: nil, // init it in func `Run`
@@ -131,6 +166,8 @@ func NewVS(sub *comm.VirtualServer, conf *VSConf, va *VirtualAddress) (*VirtualS
 		quit:   make(chan bool, 1),
 	}
 
+	vs.ramps = newRampTracker(&confCopied.RampConf, vs.setBackendWeight)
+
 	glog.Infof("VS %s created", vsid)
 	return vs, nil
 }
@@ -162,18 +199,39 @@ func (vs *VirtualService) judge() types.State {
 		return vs.calcState()
 	}
 
-	// TODO: Support more VS healthy criteria with VSConf, such as
-	//   minimum healthy backends, minimum healthy ratio, ...
-	if vs.upBackends > 0 {
-		return types.Healthy
+	// Note: backends just added have not been counted in upBackends/downBackends.
+	// Backends removed from dpvs but kept in debounce-window serve no traffic,
+	// and are excluded from the quorum.
+	var healthy, total int
+	countUnknown := vs.quorum.countUnknownUp()
+	for _, rs := range vs.backends {
+		if !rs.removed.IsZero() {
+			continue
+		}
+		total++
+		if rs.checkerState == types.Healthy ||
+			(rs.checkerState == types.Unknown && countUnknown) {
+			healthy++
+		}
 	}
-	return types.Unhealthy
+	return vs.quorum.judge(healthy, total)
 }
 
-func (vs *VirtualService) sendStateChangeNotice(newState types.State) {
+// rejudge concludes the VS state, and notifies VA if the state changed.
+// The detail of the backend state change that leads to it is passed on to VA.
+func (vs *VirtualService) rejudge(detail *actioner.ActionDetail) {
+	vsState := vs.judge()
+	if vsState != vs.state {
+		vs.sendStateChangeNotice(vsState, detail)
+		vs.updateStateTo(vsState)
+	}
+}
+
+func (vs *VirtualService) sendStateChangeNotice(newState types.State, detail *actioner.ActionDetail) {
 	vs.va.notify <- VSState{
-		id:    vs.id,
-		state: newState,
+		id:     vs.id,
+		state:  newState,
+		detail: detail,
 	}
 }
 func (vs *VirtualService) updateStateTo(newState types.State) {
@@ -189,34 +247,53 @@ func (vs *VirtualService) updateStateTo(newState types.State) {
 	vs.metricTaint = true
 }
 
-func (vs *VirtualService) act(changed []CheckerID) error {
+func (vs *VirtualService) act(changed []CheckerID) (err error) {
+	var rss []comm.RealServer
+	var acted []CheckerID
 	var version uint64 = 0
-	rss := make([]comm.RealServer, 0, len(changed))
-	for _, ckid := range changed {
-		rs := vs.backends[ckid]
-		if version == 0 || rs.version < version {
-			// just in case, use the minimum version of all changed backends
-			version = rs.version
-		}
-		weight := uint16(rs.uweight)
-		if rs.checkerState == types.Unhealthy {
-			weight = 0
-		}
-		rss = append(rss, comm.RealServer{
-			Addr:      rs.addr,
-			Weight:    weight,
-			Inhibited: rs.checkerState == types.Unhealthy,
-		})
-	}
-	vsCom := comm.VirtualServer{
-		Version: version,
-		Addr:    vs.subject,
-		RSs:     rss,
-		// ignore any other field not concerned
-	}
+	defer func() { vs.logActions(rss, err) }()
 
+	items := make([]string, len(changed))
+	for i, ckid := range changed {
+		items[i] = string(ckid)
+	}
 	// Batch update, real checker states are carried by param `vsCom.rss`.
-	resp, err := vs.actioner.Act(types.Unknown, vs.conf.ActionTimeout, &vsCom)
+	resp, err := vs.va.m.dispatcher.DispatchChanges(string(vs.id), vs.conf.Actioner,
+		vs.conf.ActionTimeout, items, func(changes []string, timeout time.Duration) (interface{}, error) {
+			// The changes may be merged from coalesced actions, so the RS list
+			// is built from the current backend states when it's the action's turn.
+			for _, item := range changes {
+				ckid := CheckerID(item)
+				rs, ok := vs.backends[ckid]
+				if !ok {
+					continue
+				}
+				if version == 0 || rs.version < version {
+					// just in case, use the minimum version of all changed backends
+					version = rs.version
+				}
+				acted = append(acted, ckid)
+				rss = append(rss, comm.RealServer{
+					Addr:      rs.addr,
+					Weight:    vs.backendWeight(ckid, rs),
+					Inhibited: rs.checkerState == types.Unhealthy,
+				})
+			}
+			if len(rss) == 0 {
+				return nil, nil
+			}
+			vsCom := comm.VirtualServer{
+				Version: version,
+				Addr:    vs.subject,
+				RSs:     rss,
+				// ignore any other field not concerned
+			}
+			return vs.actioner.Act(types.Unknown, timeout, &vsCom)
+		})
+	if errors.Is(err, ErrActionCoalesced) {
+		// The changed backends are acted by the later action.
+		return nil
+	}
 	if err != nil {
 		// FIXME: Partial update may have happened,
 		//  how to know exactly the number of failed backends?
@@ -243,7 +320,7 @@ func (vs *VirtualService) act(changed []CheckerID) error {
 		// Note:
 		//   The returned new VS conf MUST contain all backends rather than
 		//   only the changed ones of the virtual service.
-		vsConf := vs.va.m.conf.GetVSConf(vs.id) // refetch VSConf
+		vsConf := vs.va.m.getConf().GetVSConf(vs.id) // refetch VSConf
 		vsConfExt := &VSConfExt{
 			VSConf: *vsConf,
 			vs:     *svc,
@@ -252,13 +329,77 @@ func (vs *VirtualService) act(changed []CheckerID) error {
 		return fmt.Errorf("outdated vs version %d", version)
 	}
 	// act succeeded, backend checkerState reflects its real state now
-	for _, ckid := range changed {
+	for _, ckid := range acted {
 		rs := vs.backends[ckid]
 		rs.state = rs.checkerState
 	}
 	return nil
 }
 
+// backendWeight returns the weight of backend `rs` to push to dpvs, which is
+// zero if Unhealthy, the ramp weight if ramping, and reduced if degraded.
+func (vs *VirtualService) backendWeight(id CheckerID, rs *VSBackend) uint16 {
+	if rs.checkerState == types.Unhealthy {
+		return 0
+	}
+	weight := rs.uweight
+	if rweight, ok := vs.ramps.weight(id); ok {
+		weight = rweight
+	}
+	if rs.degraded {
+		weight = degradedWeight(weight, vs.conf.DegradedWeightPercent)
+	}
+	return uint16(weight)
+}
+
+// logActions logs the action outcomes of backends `rss`.
+func (vs *VirtualService) logActions(rss []comm.RealServer, err error) {
+	for i := range rss {
+		ev := &utils.LogEvent{
+			Level:  utils.LogLevelInfo,
+			Event:  utils.LogEventAction,
+			Target: rss[i].Addr.String(),
+			VIP:    string(vs.id),
+			Method: vs.conf.Actioner,
+			State:  types.Healthy.String(),
+		}
+		if rss[i].Inhibited {
+			ev.State = types.Unhealthy.String()
+		}
+		if err == nil {
+			ev.Message = fmt.Sprintf("VS %s backend %s updated to %s (weight %d) by %s",
+				vs.id, ev.Target, ev.State, rss[i].Weight, vs.conf.Actioner)
+			eventLog.Log(ev)
+			continue
+		}
+		ev.Level = utils.LogLevelWarning
+		ev.Error = err.Error()
+		ev.Message = fmt.Sprintf("VS %s backend %s update to %s by %s failed: %v",
+			vs.id, ev.Target, ev.State, vs.conf.Actioner, err)
+		eventLog.LogFailure("VS actions failed", ev)
+	}
+}
+
+// setBackendWeight is the weightSetter of VS's rampTracker.
+func (vs *VirtualService) setBackendWeight(id CheckerID, weight uint) error {
+	glog.V(6).Infof("VS %s set backend %s weight %d", vs.id, id, weight)
+	if err := vs.act([]CheckerID{id}); err != nil {
+		glog.Warningf("VS %s update backend %s weight to %d failed: %v", vs.id, id, weight, err)
+		return err
+	}
+	return nil
+}
+
+func (vs *VirtualService) resetRampTicker() {
+	if vs.ramp != nil {
+		vs.ramp.Stop()
+		vs.ramp = nil
+	}
+	if vs.ramps.enabled() {
+		vs.ramp = time.NewTicker(vs.conf.RampStepInterval())
+	}
+}
+
 func (vs *VirtualService) doUpdate(conf *VSConfExt) {
 	// Update VSConf
 	vscf := conf.GetVSConf()
@@ -268,8 +409,23 @@ func (vs *VirtualService) doUpdate(conf *VSConfExt) {
 		vscf.Method = vscf.Method.TranslateAuto(conf.vs.Addr.Proto)
 	}
 
+	requorum := false
 	if !vscf.DeepEqual(&vs.conf) {
 		skip := false
+		if !vscf.QuorumConf.DeepEqual(&vs.conf.QuorumConf) {
+			glog.Infof("Updating QuorumConf of VS %s: %+v->%+v", vs.id, vs.conf.QuorumConf, vscf.QuorumConf)
+			vs.conf.QuorumConf = vscf.QuorumConf
+			vs.quorum.setConf(&vscf.QuorumConf)
+			requorum = true
+		}
+		if !vscf.RampConf.DeepEqual(&vs.conf.RampConf) {
+			glog.Infof("Updating RampConf of VS %s: %+v->%+v", vs.id, vs.conf.RampConf, vscf.RampConf)
+			vs.conf.RampConf = vscf.RampConf
+			vs.ramps.setConf(&vscf.RampConf)
+			if vs.resync != nil { // VS loop started
+				vs.resetRampTicker()
+			}
+		}
 		if vscf.ActionSyncTime > 0 && vscf.ActionSyncTime != vs.conf.ActionSyncTime {
 			glog.Infof("Updating ActionSyncTime of VS %s: %v->%v", vs.id, vs.conf.ActionSyncTime, vscf.ActionSyncTime)
 			if vs.resync != nil {
@@ -292,7 +448,7 @@ func (vs *VirtualService) doUpdate(conf *VSConfExt) {
 			changed := make([]CheckerID, 0, vs.downBackends)
 			restoreUnhealthy := false
 			for ckid, rs := range vs.backends {
-				if rs.checkerState == types.Unhealthy {
+				if rs.checkerState == types.Unhealthy && rs.removed.IsZero() {
 					changed = append(changed, ckid)
 				}
 			}
@@ -312,12 +468,12 @@ func (vs *VirtualService) doUpdate(conf *VSConfExt) {
 				restoreUnhealthy = true
 			}
 			if !skip {
-				act, err := actioner.NewActioner(vscf.Actioner, &vs.subject, vscf.ActionParams,
-					vs.va.m.appConf.DpvsAgentAddr)
+				act, err := newVSActioner(vs.va, vs.id, vscf.Actioner, &vs.subject, vscf.ActionParams)
 				if err != nil {
 					glog.Errorf("VS %s actioner recreated failed: %v", vs.id, err)
 					skip = true
 				} else {
+					stopActioner(vs.actioner)
 					vs.actioner = act
 				}
 			}
@@ -337,6 +493,7 @@ func (vs *VirtualService) doUpdate(conf *VSConfExt) {
 			glog.V(5).Infof("VSConf for %s updated successfully", vs.id)
 		} else {
 			vs.conf.CheckerConf = vscf.CheckerConf
+			vs.conf.DiscoveryConf = vscf.DiscoveryConf
 			glog.Warningf("VSConf for %s partially updated", vs.id)
 		}
 	}
@@ -352,29 +509,36 @@ func (vs *VirtualService) doUpdate(conf *VSConfExt) {
 			delete(staled, ckid)
 		}
 	}
-	for ckid, _ := range staled {
-		rs := vs.backends[ckid]
-		delete(vs.backends, ckid)
-		if rs.checkerState == types.Unhealthy {
-			vs.downBackends--
-		} else {
-			vs.upBackends--
+	now := time.Now()
+	for ckid, rs := range vs.backends {
+		if _, ok := staled[ckid]; !ok {
+			if !rs.removed.IsZero() {
+				glog.Infof("VS %s backend %s reappeared in dpvs in %v, take it back",
+					vs.id, ckid, now.Sub(rs.removed))
+				rs.removed = time.Time{}
+				requorum = true
+			}
+			continue
 		}
-		vs.metricTaint = true
-		rs.checker.Stop()
-	}
-	if len(staled) > 0 {
-		vsState := vs.judge()
-		if vsState != vs.state {
-			vs.sendStateChangeNotice(vsState)
-			vs.updateStateTo(vsState)
+		if rs.removed.IsZero() {
+			rs.removed = now
+			requorum = true
+			// Backends not in dpvs are never acted on, nor ramped.
+			vs.ramps.cancel(ckid)
+			if vs.conf.debounced() {
+				glog.Infof("VS %s backend %s removed from dpvs, keep checking it for %v",
+					vs.id, ckid, vs.conf.DebounceWindow)
+			}
 		}
 	}
+	if vs.purgeRemoved(now) {
+		requorum = true
+	}
 
 	// Create new or update existing Backends
 	for _, rs := range conf.vs.RSs {
 		ckid := CheckerID(rs.Addr.String())
-		ckConf := vscf.GetCheckerConf()
+		ckConf := vscf.GetRSCheckerConf(ckid)
 		state := types.Healthy
 		if rs.Inhibited {
 			state = types.Unhealthy
@@ -404,6 +568,27 @@ func (vs *VirtualService) doUpdate(conf *VSConfExt) {
 			}
 			vs.backends[ckid] = vsb
 			vs.metricTaint = true
+			requorum = true
+			if vs.conf.InitState == InitStateAssumeHealthy {
+				checker.assume(types.Healthy)
+			}
+			if restored := checker.noticedState(); restored != types.Unknown {
+				// Warm restart or assumed state: take it as if noticed from the checker,
+				// and skip the action if dpvs has been in the state already.
+				vsb.checkerState = restored
+				if restored == types.Unhealthy {
+					vs.downBackends++
+				} else {
+					vs.upBackends++
+				}
+				if vsb.state != restored {
+					if err := vs.act([]CheckerID{ckid}); err != nil {
+						glog.Warningf("VS %s restore backend %s to %s failed: %v", vs.id, ckid, restored, err)
+					}
+				} else {
+					glog.V(5).Infof("VS %s backend %s restored to %s, action skipped", vs.id, ckid, restored)
+				}
+			}
 			vs.wg.Add(1)
 			delay := time.NewTicker(time.Duration(1+rand.Intn(int(
 				CheckerStartDelayMax.Milliseconds()))) * time.Millisecond)
@@ -414,7 +599,9 @@ func (vs *VirtualService) doUpdate(conf *VSConfExt) {
 				glog.Warningf("received VSBackend %s with eailier version, skip it", uuid)
 				continue
 			}
-			if !rs.Inhibited || rs.Weight > 0 { // ??? Is it necessary?
+			if vs.ramps.ramping(ckid) {
+				// Weight from dpvs is the intermediate ramp weight, keep the configured one.
+			} else if !rs.Inhibited || rs.Weight > 0 { // ??? Is it necessary?
 				vsb.uweight = uint(rs.Weight)
 			}
 			vsb.version = conf.vs.Version
@@ -423,7 +610,9 @@ func (vs *VirtualService) doUpdate(conf *VSConfExt) {
 					uuid, vsb.state, state)
 				vsb.state = state
 			}
-			if vsb.state != vsb.checkerState {
+			// Backends not checked yet are left as they are in dpvs, which avoids
+			// spurious actions on unchanged backends when configs are reloaded.
+			if vsb.checkerState != types.Unknown && vsb.state != vsb.checkerState {
 				if err := vs.act([]CheckerID{ckid}); err != nil {
 					glog.Warningf("VS %s update backend %s to %s failed: %v", vs.id, ckid, vsb.checkerState, err)
 				}
@@ -431,6 +620,88 @@ func (vs *VirtualService) doUpdate(conf *VSConfExt) {
 			vsb.checker.Update(ckConf.DeepCopy())
 		}
 	}
+
+	// Backends entering or leaving the VS change the quorum.
+	if requorum {
+		vs.rejudge(nil)
+	}
+}
+
+// purgeRemoved removes the backends which have been removed from dpvs for the
+// debounce-window, and schedules the next purge if any backend remains removed.
+// It returns whether any backend is purged.
+func (vs *VirtualService) purgeRemoved(now time.Time) bool {
+	if vs.debounce != nil {
+		vs.debounce.Stop()
+		vs.debounce = nil
+	}
+
+	purged := false
+	var next time.Time
+	for ckid, rs := range vs.backends {
+		if rs.removed.IsZero() {
+			continue
+		}
+		expires := rs.removed.Add(vs.conf.DebounceWindow)
+		if vs.conf.debounced() && expires.After(now) {
+			if next.IsZero() || expires.Before(next) {
+				next = expires
+			}
+			continue
+		}
+		vs.removeBackend(ckid)
+		purged = true
+	}
+	if !next.IsZero() {
+		vs.debounce = time.NewTimer(next.Sub(now))
+	}
+	return purged
+}
+
+// removeBackend stops the checker of backend `ckid`, which cancels its in-flight
+// check, and signals the cleanup actioner if configured.
+func (vs *VirtualService) removeBackend(ckid CheckerID) {
+	rs := vs.backends[ckid]
+	delete(vs.backends, ckid)
+	if rs.checkerState == types.Unhealthy {
+		vs.downBackends--
+	} else {
+		vs.upBackends--
+	}
+	vs.metricTaint = true
+	vs.ramps.cancel(ckid)
+	rs.checker.Stop()
+	glog.Infof("VS %s backend %s removed", vs.id, ckid)
+
+	if len(vs.conf.CleanupActioner) == 0 {
+		return
+	}
+	act, err := actioner.NewServiceActioner(vs.conf.CleanupActioner, &rs.addr, &vs.subject,
+		vs.conf.CleanupActionParams, vs.va.m.appConf.DpvsAgentAddr)
+	if err != nil {
+		glog.Errorf("VS %s cleanup actioner created failed for backend %s: %v", vs.id, ckid, err)
+		return
+	}
+	detail := &actioner.ActionDetail{
+		Target:  rs.addr.DeepCopy(),
+		Method:  vs.conf.Method.String(),
+		Message: "removed from dpvs",
+		Time:    time.Now(),
+	}
+	// The cleanup action may be queued or throttled by the dispatcher, so it's
+	// dispatched in background rather than blocking the VS loop.
+	kind, timeout := vs.conf.CleanupActioner, vs.conf.ActionTimeout
+	vs.wg.Add(1)
+	go func() {
+		defer vs.wg.Done()
+		_, err := vs.va.m.dispatcher.Dispatch(fmt.Sprintf("%s/%s", vs.id, ckid), kind, timeout,
+			func(timeout time.Duration) (interface{}, error) {
+				return act.Act(types.Unhealthy, timeout, detail)
+			})
+		if err != nil {
+			glog.Warningf("VS %s cleanup backend %s by %s failed: %v", vs.id, ckid, kind, err)
+		}
+	}()
 }
 
 func (vs *VirtualService) recvNotice(state *BackendState) {
@@ -450,13 +721,37 @@ func (vs *VirtualService) recvNotice(state *BackendState) {
 	}
 
 	if rs.checkerState == state.state {
+		if rs.degraded != state.degraded {
+			// Only the weight changes, which is pushed unless the backend is
+			// ramping, whose next step takes it.
+			rs.degraded = state.degraded
+			if state.state == types.Healthy && rs.removed.IsZero() && !vs.ramps.ramping(state.id) {
+				if err := vs.act([]CheckerID{state.id}); err != nil {
+					glog.Warningf("VS %s update backend %s degraded %v failed: %v", vs.id, state.id,
+						state.degraded, err)
+				}
+			}
+		}
 		return
 	}
 	oldState := rs.checkerState
 	rs.checkerState = state.state
+	rs.degraded = state.degraded
+	fireStateHooks(&rs.addr, oldState, state.state)
 
-	if err := vs.act([]CheckerID{state.id}); err != nil {
-		glog.Warningf("VS %s update backend %s to %s failed: %v", vs.id, state.id, state.state, err)
+	// Slow start backends recovered from Unhealthy. Backends in Unknown state,
+	// such as those after restart, get their full weight immediately.
+	// Backends removed from dpvs are synced when they reappear.
+	ramped := false
+	if state.state == types.Unhealthy {
+		vs.ramps.cancel(state.id)
+	} else if oldState == types.Unhealthy && rs.removed.IsZero() {
+		ramped = vs.ramps.start(state.id, rs.uweight, time.Now())
+	}
+	if !ramped && rs.removed.IsZero() {
+		if err := vs.act([]CheckerID{state.id}); err != nil {
+			glog.Warningf("VS %s update backend %s to %s failed: %v", vs.id, state.id, state.state, err)
+		}
 	}
 
 	if state.state == types.Unhealthy {
@@ -464,29 +759,20 @@ func (vs *VirtualService) recvNotice(state *BackendState) {
 		if oldState == types.Healthy {
 			vs.upBackends--
 		}
-		vsState := vs.judge()
-		if vsState != vs.state {
-			vs.sendStateChangeNotice(vsState)
-			vs.updateStateTo(vsState)
-		}
 	} else {
 		vs.upBackends++
 		if oldState == types.Unhealthy {
 			vs.downBackends--
 		}
-		vsState := vs.judge()
-		if vsState != vs.state {
-			vs.sendStateChangeNotice(vsState)
-			vs.updateStateTo(vsState)
-		}
 	}
+	vs.rejudge(state.detail)
 }
 
 func (vs *VirtualService) doResync() {
 	// resync checkers state
 	changed := make([]CheckerID, 0)
 	for ckid, rs := range vs.backends {
-		if rs.checkerState != types.Unknown && rs.state != rs.checkerState {
+		if rs.checkerState != types.Unknown && rs.state != rs.checkerState && rs.removed.IsZero() {
 			changed = append(changed, ckid)
 		}
 	}
@@ -506,7 +792,7 @@ func (vs *VirtualService) doResync() {
 	if vsState != vs.state {
 		glog.Warningf("VS %s state changed %s->%s after recalculation, upBackends %d, downBackends %d",
 			vs.id, vs.state, vsState, vs.upBackends, vs.downBackends)
-		vs.sendStateChangeNotice(vsState)
+		vs.sendStateChangeNotice(vsState, nil)
 		vs.updateStateTo(vsState)
 	}
 }
@@ -544,12 +830,19 @@ func (vs *VirtualService) cleanup() {
 	if vs.resync != nil {
 		vs.resync.Stop()
 	}
+	if vs.ramp != nil {
+		vs.ramp.Stop()
+	}
+	if vs.debounce != nil {
+		vs.debounce.Stop()
+	}
 	if vs.metricTicker != nil {
 		vs.metricTicker.Stop()
 	}
 	for _, rs := range vs.backends {
 		rs.checker.Stop()
 	}
+	stopActioner(vs.actioner)
 	vs.wg.Wait()
 
 	vs.metricClean()
@@ -599,6 +892,9 @@ func (vs *VirtualService) Run(wg *sync.WaitGroup, start <-chan time.Time) {
 	if vs.resync == nil {
 		vs.resync = time.NewTicker(vs.conf.ActionSyncTime)
 	}
+	if vs.ramp == nil {
+		vs.resetRampTicker()
+	}
 	if vs.metricTicker == nil {
 		vs.metricTicker = time.NewTicker(vs.va.m.appConf.MetricDelay)
 	}
@@ -606,6 +902,13 @@ func (vs *VirtualService) Run(wg *sync.WaitGroup, start <-chan time.Time) {
 	glog.V(5).Infof("VS %v loop started\n", vs.id)
 
 	for {
+		var rampC, debounceC <-chan time.Time
+		if vs.ramp != nil {
+			rampC = vs.ramp.C
+		}
+		if vs.debounce != nil {
+			debounceC = vs.debounce.C
+		}
 		select {
 		case <-vs.quit:
 			VSThreads.RunningDec()
@@ -616,6 +919,13 @@ func (vs *VirtualService) Run(wg *sync.WaitGroup, start <-chan time.Time) {
 			vs.doUpdate(&conf)
 		case state := <-vs.notify:
 			vs.recvNotice(&state)
+		case now := <-rampC:
+			vs.ramps.tick(now)
+		case now := <-debounceC:
+			vs.debounce = nil
+			if vs.purgeRemoved(now) {
+				vs.rejudge(nil)
+			}
 		case <-vs.resync.C:
 			vs.doResync()
 		case <-vs.metricTicker.C:
@@ -58,6 +58,9 @@ func (in *CheckerConf) DeepCopyInto(out *CheckerConf) {
 			(*out)[key] = val
 		}
 	}
+	out.Timing = in.Timing
+	out.FlapConf = in.FlapConf
+	out.LatencyConf = in.LatencyConf
 	return
 }
 
@@ -103,6 +106,61 @@ func (in *Conf) DeepCopy() *Conf {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DiscoveryConf) DeepCopyInto(out *DiscoveryConf) {
+	*out = *in
+	if in.CleanupActionParams != nil {
+		in, out := &in.CleanupActionParams, &out.CleanupActionParams
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DiscoveryConf.
+func (in *DiscoveryConf) DeepCopy() *DiscoveryConf {
+	if in == nil {
+		return nil
+	}
+	out := new(DiscoveryConf)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FlapConf) DeepCopyInto(out *FlapConf) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FlapConf.
+func (in *FlapConf) DeepCopy() *FlapConf {
+	if in == nil {
+		return nil
+	}
+	out := new(FlapConf)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LatencyConf) DeepCopyInto(out *LatencyConf) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LatencyConf.
+func (in *LatencyConf) DeepCopy() *LatencyConf {
+	if in == nil {
+		return nil
+	}
+	out := new(LatencyConf)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Metric) DeepCopyInto(out *Metric) {
 	*out = *in
@@ -158,6 +216,55 @@ func (in *MetricDB) DeepCopy() *MetricDB {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *QuorumConf) DeepCopyInto(out *QuorumConf) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new QuorumConf.
+func (in *QuorumConf) DeepCopy() *QuorumConf {
+	if in == nil {
+		return nil
+	}
+	out := new(QuorumConf)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RSConf) DeepCopyInto(out *RSConf) {
+	*out = *in
+	out.Timing = in.Timing
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RSConf.
+func (in *RSConf) DeepCopy() *RSConf {
+	if in == nil {
+		return nil
+	}
+	out := new(RSConf)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RampConf) DeepCopyInto(out *RampConf) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RampConf.
+func (in *RampConf) DeepCopy() *RampConf {
+	if in == nil {
+		return nil
+	}
+	out := new(RampConf)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *VAConf) DeepCopyInto(out *VAConf) {
 	*out = *in
@@ -242,6 +349,16 @@ func (in *VSConf) DeepCopyInto(out *VSConf) {
 	*out = *in
 	in.CheckerConf.DeepCopyInto(&out.CheckerConf)
 	in.ActionConf.DeepCopyInto(&out.ActionConf)
+	out.QuorumConf = in.QuorumConf
+	out.RampConf = in.RampConf
+	in.DiscoveryConf.DeepCopyInto(&out.DiscoveryConf)
+	if in.RealServers != nil {
+		in, out := &in.RealServers, &out.RealServers
+		*out = make(map[CheckerID]RSConf, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 	return
 }
 
@@ -0,0 +1,97 @@
+// /*
+// Copyright 2025 IQiYi Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// */
+
+package preflight
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/iqiyi/dpvs/tools/healthcheck/pkg/comm"
+	"github.com/vishvananda/netlink"
+)
+
+var _ System = HostSystem{}
+
+// HostSystem inspects the host where the process runs.
+type HostSystem struct{}
+
+// EffectiveCaps parses the CapEff line of /proc/self/status.
+func (HostSystem) EffectiveCaps() (uint64, error) {
+	f, err := os.Open("/proc/self/status")
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	return parseCapEff(f)
+}
+
+func parseCapEff(r io.Reader) (uint64, error) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "CapEff:") {
+			continue
+		}
+		return strconv.ParseUint(strings.TrimSpace(strings.TrimPrefix(line, "CapEff:")), 16, 64)
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	return 0, fmt.Errorf("no CapEff found")
+}
+
+func openSocket(typ, proto int) error {
+	fd, err := syscall.Socket(syscall.AF_INET, typ|syscall.SOCK_CLOEXEC, proto)
+	if err != nil {
+		return os.NewSyscallError("socket", err)
+	}
+	return syscall.Close(fd)
+}
+
+func (HostSystem) OpenRawICMP() error {
+	return openSocket(syscall.SOCK_RAW, syscall.IPPROTO_ICMP)
+}
+
+func (HostSystem) OpenDgramICMP() error {
+	return openSocket(syscall.SOCK_DGRAM, syscall.IPPROTO_ICMP)
+}
+
+// NetlinkNoop lists the network interfaces by netlink, which changes nothing.
+func (HostSystem) NetlinkNoop() error {
+	_, err := netlink.LinkList()
+	return err
+}
+
+func (HostSystem) LinkExists(name string) error {
+	_, err := netlink.LinkByName(name)
+	return err
+}
+
+// ProbeAgent lists the services from dpvs-agent.
+func (HostSystem) ProbeAgent(server string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	_, err := comm.GetServiceFromDPVS(server, ctx)
+	return err
+}
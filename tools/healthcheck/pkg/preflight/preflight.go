@@ -0,0 +1,265 @@
+// /*
+// Copyright 2025 IQiYi Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// */
+
+// Package preflight inspects the host at startup for the capabilities and
+// resources required by the configured checkers and actioners, so that the
+// misconfiguration is reported at once rather than by per-check errors later.
+package preflight
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Status is the outcome of a preflight check.
+type Status int
+
+const (
+	StatusPass Status = iota
+	StatusWarn
+	StatusFail
+)
+
+func (s Status) String() string {
+	switch s {
+	case StatusPass:
+		return "PASS"
+	case StatusWarn:
+		return "WARN"
+	case StatusFail:
+		return "FAIL"
+	}
+	return fmt.Sprintf("Status(%d)", int(s))
+}
+
+func (s Status) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// Linux capabilities inspected, see capabilities(7).
+const (
+	CapNetAdmin = 12
+	CapNetRaw   = 13
+)
+
+// Requirements tells what the configured checkers and actioners require. Each
+// requirement lists its users, such as "virtual-servers/192.168.88.1-UDP-53:
+// udpping", which are shown in the report, and no user means not required.
+type Requirements struct {
+	ICMP      []string            // ICMP sockets, raw or unprivileged datagram ones
+	RawICMP   []string            // raw ICMP sockets only
+	RawSocket []string            // raw sockets of any kind, i.e., CAP_NET_RAW
+	NetAdmin  []string            // netlink changes, i.e., CAP_NET_ADMIN
+	Ifnames   map[string][]string // kernel network interfaces by name
+	DpvsAgent string              // server address of dpvs-agent, empty to skip
+}
+
+// AddIfname adds the user of network interface `ifname`.
+func (r *Requirements) AddIfname(ifname, user string) {
+	if r.Ifnames == nil {
+		r.Ifnames = make(map[string][]string)
+	}
+	r.Ifnames[ifname] = append(r.Ifnames[ifname], user)
+}
+
+// Result is the result of a preflight check. A failure not required by the
+// configured checkers and actioners is downgraded to a warning.
+type Result struct {
+	Name    string `json:"name"`
+	Status  Status `json:"status"`
+	Message string `json:"message"`
+}
+
+// Report is the results of all preflight checks.
+type Report struct {
+	Results []Result `json:"results"`
+}
+
+// Failed returns true if any check failed.
+func (r *Report) Failed() bool {
+	for _, res := range r.Results {
+		if res.Status == StatusFail {
+			return true
+		}
+	}
+	return false
+}
+
+// String returns the summary of the report, one line per check.
+func (r *Report) String() string {
+	var counts [3]int
+	var b strings.Builder
+	for _, res := range r.Results {
+		if res.Status >= StatusPass && res.Status <= StatusFail {
+			counts[res.Status]++
+		}
+		fmt.Fprintf(&b, "  [%s] %s: %s\n", res.Status, res.Name, res.Message)
+	}
+	return fmt.Sprintf("Preflight: %d passed, %d warnings, %d failed\n%s",
+		counts[StatusPass], counts[StatusWarn], counts[StatusFail], b.String())
+}
+
+// CapabilityReader reads the effective capability set of the process.
+type CapabilityReader interface {
+	EffectiveCaps() (uint64, error)
+}
+
+// ICMPOpener opens and closes ICMP sockets.
+type ICMPOpener interface {
+	OpenRawICMP() error
+	OpenDgramICMP() error
+}
+
+// NetlinkProber performs a no-op netlink request.
+type NetlinkProber interface {
+	NetlinkNoop() error
+}
+
+// LinkFinder looks up kernel network interfaces.
+type LinkFinder interface {
+	LinkExists(name string) error
+}
+
+// AgentProber requests the dpvs-agent API.
+type AgentProber interface {
+	ProbeAgent(server string, timeout time.Duration) error
+}
+
+// System is all the inspections of the host required by Run.
+type System interface {
+	CapabilityReader
+	ICMPOpener
+	NetlinkProber
+	LinkFinder
+	AgentProber
+}
+
+// AgentProbeTimeout is the timeout to request the dpvs-agent API.
+var AgentProbeTimeout = 3 * time.Second
+
+// Run executes all preflight checks on `sys` against `req`.
+func Run(sys System, req *Requirements) *Report {
+	report := &Report{}
+	report.Results = append(report.Results, CheckCapabilities(sys, req)...)
+	report.Results = append(report.Results, CheckICMPSocket(sys, req))
+	report.Results = append(report.Results, CheckNetlink(sys, req))
+	report.Results = append(report.Results, CheckIfnames(sys, req)...)
+	report.Results = append(report.Results, CheckDpvsAgent(sys, req))
+	return report
+}
+
+// required returns StatusFail if the requirement has any user, or StatusWarn
+// otherwise, and the message suffix telling the users.
+func required(users []string) (Status, string) {
+	if len(users) == 0 {
+		return StatusWarn, ", not required by current config"
+	}
+	return StatusFail, ", required by " + usersString(users)
+}
+
+func usersString(users []string) string {
+	const max = 3
+	users = append([]string(nil), users...)
+	sort.Strings(users)
+	if len(users) > max {
+		return fmt.Sprintf("%s and %d more", strings.Join(users[:max], ", "), len(users)-max)
+	}
+	return strings.Join(users, ", ")
+}
+
+// CheckCapabilities checks CAP_NET_RAW and CAP_NET_ADMIN in the effective
+// capability set.
+func CheckCapabilities(r CapabilityReader, req *Requirements) []Result {
+	caps, err := r.EffectiveCaps()
+	if err != nil {
+		return []Result{{"capabilities", StatusWarn,
+			fmt.Sprintf("fail to read effective capabilities: %v", err)}}
+	}
+	check := func(name string, bit uint, users []string) Result {
+		if caps&(1<<bit) != 0 {
+			return Result{name, StatusPass, "effective"}
+		}
+		status, suffix := required(users)
+		return Result{name, status, "not effective" + suffix}
+	}
+	return []Result{
+		check("CAP_NET_RAW", CapNetRaw, req.RawSocket),
+		check("CAP_NET_ADMIN", CapNetAdmin, req.NetAdmin),
+	}
+}
+
+// CheckICMPSocket checks if ICMP sockets can be opened. The unprivileged ICMP
+// datagram sockets suffice unless raw sockets are required.
+func CheckICMPSocket(o ICMPOpener, req *Requirements) Result {
+	const name = "icmp-socket"
+	rawErr := o.OpenRawICMP()
+	if rawErr == nil {
+		return Result{name, StatusPass, "raw ICMP socket opened"}
+	}
+	if dgramErr := o.OpenDgramICMP(); dgramErr != nil {
+		status, suffix := required(req.ICMP)
+		return Result{name, status, fmt.Sprintf("fail to open raw ICMP socket: %v, "+
+			"and unprivileged ICMP socket: %v%s", rawErr, dgramErr, suffix)}
+	}
+	status, suffix := required(req.RawICMP)
+	return Result{name, status, fmt.Sprintf("fail to open raw ICMP socket: %v, "+
+		"unprivileged ICMP socket opened%s", rawErr, suffix)}
+}
+
+// CheckNetlink checks if netlink requests work.
+func CheckNetlink(p NetlinkProber, req *Requirements) Result {
+	const name = "netlink"
+	if err := p.NetlinkNoop(); err != nil {
+		status, suffix := required(req.NetAdmin)
+		return Result{name, status, fmt.Sprintf("netlink request failed: %v%s", err, suffix)}
+	}
+	return Result{name, StatusPass, "netlink request succeeded"}
+}
+
+// CheckIfnames checks if the network interfaces required exist.
+func CheckIfnames(f LinkFinder, req *Requirements) []Result {
+	ifnames := make([]string, 0, len(req.Ifnames))
+	for ifname := range req.Ifnames {
+		ifnames = append(ifnames, ifname)
+	}
+	sort.Strings(ifnames)
+
+	results := make([]Result, 0, len(ifnames))
+	for _, ifname := range ifnames {
+		name := "ifname " + ifname
+		if err := f.LinkExists(ifname); err != nil {
+			status, suffix := required(req.Ifnames[ifname])
+			results = append(results, Result{name, status, fmt.Sprintf("%v%s", err, suffix)})
+			continue
+		}
+		results = append(results, Result{name, StatusPass, "exists"})
+	}
+	return results
+}
+
+// CheckDpvsAgent checks the connectivity to the dpvs-agent API.
+func CheckDpvsAgent(p AgentProber, req *Requirements) Result {
+	const name = "dpvs-agent"
+	if len(req.DpvsAgent) == 0 {
+		return Result{name, StatusWarn, "no dpvs-agent server address"}
+	}
+	if err := p.ProbeAgent(req.DpvsAgent, AgentProbeTimeout); err != nil {
+		return Result{name, StatusFail, fmt.Sprintf("%s unavailable: %v", req.DpvsAgent, err)}
+	}
+	return Result{name, StatusPass, fmt.Sprintf("%s connected", req.DpvsAgent)}
+}
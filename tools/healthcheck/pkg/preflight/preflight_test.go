@@ -0,0 +1,171 @@
+// /*
+// Copyright 2025 IQiYi Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// */
+
+package preflight
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeSystem inspects nothing but returns the given results.
+type fakeSystem struct {
+	caps     uint64
+	capsErr  error
+	rawErr   error
+	dgramErr error
+	nlErr    error
+	links    map[string]bool
+	agentErr error
+}
+
+func (s *fakeSystem) EffectiveCaps() (uint64, error) { return s.caps, s.capsErr }
+func (s *fakeSystem) OpenRawICMP() error             { return s.rawErr }
+func (s *fakeSystem) OpenDgramICMP() error           { return s.dgramErr }
+func (s *fakeSystem) NetlinkNoop() error             { return s.nlErr }
+
+func (s *fakeSystem) LinkExists(name string) error {
+	if !s.links[name] {
+		return errors.New("Link not found")
+	}
+	return nil
+}
+
+func (s *fakeSystem) ProbeAgent(server string, timeout time.Duration) error { return s.agentErr }
+
+var errPerm = errors.New("operation not permitted")
+
+func TestCheckCapabilities(t *testing.T) {
+	cases := []struct {
+		name   string
+		sys    fakeSystem
+		req    Requirements
+		expect []Status // CAP_NET_RAW, CAP_NET_ADMIN
+	}{
+		{"all effective", fakeSystem{caps: 1<<CapNetRaw | 1<<CapNetAdmin}, Requirements{},
+			[]Status{StatusPass, StatusPass}},
+		{"not required", fakeSystem{caps: 0}, Requirements{},
+			[]Status{StatusWarn, StatusWarn}},
+		{"raw required", fakeSystem{caps: 1 << CapNetAdmin},
+			Requirements{RawSocket: []string{"arp"}, NetAdmin: []string{"KernelRouteAddDel"}},
+			[]Status{StatusFail, StatusPass}},
+		{"admin required", fakeSystem{caps: 1 << CapNetRaw},
+			Requirements{NetAdmin: []string{"KernelRouteAddDel"}},
+			[]Status{StatusPass, StatusFail}},
+	}
+	for _, c := range cases {
+		results := CheckCapabilities(&c.sys, &c.req)
+		if len(results) != len(c.expect) {
+			t.Fatalf("%s: expect %d results, got %v", c.name, len(c.expect), results)
+		}
+		for i, res := range results {
+			if res.Status != c.expect[i] {
+				t.Errorf("%s: expect %s %v, got %+v", c.name, res.Name, c.expect[i], res)
+			}
+		}
+	}
+
+	results := CheckCapabilities(&fakeSystem{capsErr: errors.New("no procfs")},
+		&Requirements{RawSocket: []string{"arp"}})
+	if len(results) != 1 || results[0].Status != StatusWarn {
+		t.Errorf("expect a warning if unknown, got %v", results)
+	}
+}
+
+func TestCheckICMPSocket(t *testing.T) {
+	cases := []struct {
+		name   string
+		sys    fakeSystem
+		req    Requirements
+		expect Status
+	}{
+		{"raw opened", fakeSystem{dgramErr: errPerm}, Requirements{RawICMP: []string{"ping"}}, StatusPass},
+		// The unprivileged socket suffices, but raw sockets are unavailable.
+		{"dgram suffices", fakeSystem{rawErr: errPerm}, Requirements{ICMP: []string{"ping"}}, StatusWarn},
+		{"raw only", fakeSystem{rawErr: errPerm},
+			Requirements{ICMP: []string{"ping"}, RawICMP: []string{"ping"}}, StatusFail},
+		{"none opened", fakeSystem{rawErr: errPerm, dgramErr: errPerm},
+			Requirements{ICMP: []string{"udpping"}}, StatusFail},
+		{"not required", fakeSystem{rawErr: errPerm, dgramErr: errPerm}, Requirements{}, StatusWarn},
+	}
+	for _, c := range cases {
+		if res := CheckICMPSocket(&c.sys, &c.req); res.Status != c.expect {
+			t.Errorf("%s: expect %v, got %+v", c.name, c.expect, res)
+		}
+	}
+}
+
+func TestCheckIfnamesAndAgent(t *testing.T) {
+	sys := &fakeSystem{links: map[string]bool{"lo": true}}
+	req := &Requirements{}
+	req.AddIfname("lo", "global/virtual-address: KernelRouteAddDel")
+	req.AddIfname("eth9", "virtual-addresses/192.168.88.1: KernelRouteAddDel")
+	results := CheckIfnames(sys, req)
+	if len(results) != 2 || results[0].Name != "ifname eth9" || results[0].Status != StatusFail ||
+		!strings.Contains(results[0].Message, "virtual-addresses/192.168.88.1") ||
+		results[1].Name != "ifname lo" || results[1].Status != StatusPass {
+		t.Errorf("unexpected ifname results: %v", results)
+	}
+
+	if res := CheckDpvsAgent(sys, &Requirements{}); res.Status != StatusWarn {
+		t.Errorf("expect a warning without agent address, got %+v", res)
+	}
+	req.DpvsAgent = "http://127.0.0.1:8082"
+	if res := CheckDpvsAgent(sys, req); res.Status != StatusPass {
+		t.Errorf("expect agent connected, got %+v", res)
+	}
+	sys.agentErr = errors.New("connection refused")
+	if res := CheckDpvsAgent(sys, req); res.Status != StatusFail {
+		t.Errorf("expect agent unavailable, got %+v", res)
+	}
+}
+
+func TestRunReport(t *testing.T) {
+	sys := &fakeSystem{caps: 1 << CapNetAdmin, rawErr: errPerm, links: map[string]bool{"lo": true}}
+	req := &Requirements{ICMP: []string{"virtual-servers/192.168.88.1-UDP-53: udpping"},
+		DpvsAgent: "http://127.0.0.1:8082"}
+	req.AddIfname("lo", "global/virtual-address: KernelRouteAddDel")
+
+	// Missing CAP_NET_RAW only warns when the ping socket works.
+	report := Run(sys, req)
+	if report.Failed() {
+		t.Errorf("unexpected failure: %s", report)
+	}
+	summary := report.String()
+	if !strings.HasPrefix(summary, "Preflight: 4 passed, 2 warnings, 0 failed\n") ||
+		!strings.Contains(summary, "  [WARN] CAP_NET_RAW: not effective, not required by current config\n") {
+		t.Errorf("unexpected summary:\n%s", summary)
+	}
+
+	sys.dgramErr = errPerm
+	if report = Run(sys, req); !report.Failed() {
+		t.Errorf("expect failure without any ICMP socket: %s", report)
+	}
+}
+
+func TestParseCapEff(t *testing.T) {
+	status := "Name:\thealthcheck\nCapInh:\t0000000000000000\nCapPrm:\t0000000000003000\n" +
+		"CapEff:\t0000000000003000\nCapBnd:\t000001ffffffffff\n"
+	caps, err := parseCapEff(strings.NewReader(status))
+	if err != nil || caps != 1<<CapNetRaw|1<<CapNetAdmin {
+		t.Errorf("expect caps 0x3000, got %#x, %v", caps, err)
+	}
+	if _, err := parseCapEff(strings.NewReader("Name:\thealthcheck\n")); err == nil {
+		t.Errorf("expect error without CapEff")
+	}
+}
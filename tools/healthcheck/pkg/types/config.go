@@ -16,7 +16,61 @@
 
 package types
 
-import "time"
+import (
+	"fmt"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RateLimit is a token bucket rate limit, zero Rate means unlimited.
+type RateLimit struct {
+	Rate  float64 // tokens per second
+	Burst uint    // bucket size
+}
+
+func (l RateLimit) String() string {
+	if l.Rate <= 0 {
+		return "unlimited"
+	}
+	return fmt.Sprintf("%g/s burst %d", l.Rate, l.Burst)
+}
+
+// ParseRateLimits parses rate limits in format "NAME=RATE[:BURST],...".
+// BURST defaults to RATE rounded up if omitted.
+func ParseRateLimits(str string) (map[string]RateLimit, error) {
+	limits := make(map[string]RateLimit)
+	for _, item := range strings.Split(str, ",") {
+		item = strings.TrimSpace(item)
+		if len(item) == 0 {
+			continue
+		}
+		name, value, ok := strings.Cut(item, "=")
+		name = strings.TrimSpace(name)
+		if !ok || len(name) == 0 {
+			return nil, fmt.Errorf("invalid rate limit %q", item)
+		}
+		rateStr, burstStr, hasBurst := strings.Cut(value, ":")
+		rate, err := strconv.ParseFloat(strings.TrimSpace(rateStr), 64)
+		if err != nil || rate < 0 {
+			return nil, fmt.Errorf("invalid rate in %q", item)
+		}
+		burst := uint(rate)
+		if float64(burst) < rate {
+			burst++
+		}
+		if hasBurst {
+			n, err := strconv.ParseUint(strings.TrimSpace(burstStr), 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("invalid burst in %q", item)
+			}
+			burst = uint(n)
+		}
+		limits[name] = RateLimit{Rate: rate, Burst: burst}
+	}
+	return limits, nil
+}
 
 type AppConf struct {
 	// enable debug mode or not
@@ -45,6 +99,32 @@ type AppConf struct {
 	MetricNotifyChanSize uint
 	// max delayed time to send changed metric to metric server
 	MetricDelay time.Duration
+	// max number of health checks running concurrently
+	CheckConcurrency uint
+	// random jitter in ratio of check interval to spread checks across the interval
+	CheckJitter float64
+	// number of the recent check results kept per target, zero to disable
+	CheckHistory uint
+	// file path to persist checker states, empty to disable state persistence
+	StateFile string
+	// states older than the age are ignored when loaded from state file
+	StateMaxAge time.Duration
+	// time interval to save checker states to state file
+	StateSaveInterval time.Duration
+	// admin http server address, empty to disable admin server
+	AdminAddr string
+	// allow state overrides via admin server listening on non-loopback address
+	AdminAllowRemote bool
+	// global rate limit of actioner invocations, zero rate means unlimited
+	ActionerRate RateLimit
+	// rate limits of actioner invocations per actioner type
+	ActionerTypeRates map[string]RateLimit
+	// format of the check, state transition and action logs, "text" or "json"
+	LogFormat string
+	// interval to summarize the identical failure logs suppressed per target, zero to disable
+	LogThrottleInterval time.Duration
+	// refuse to start if any preflight check relevant to the config fails
+	StrictPreflight bool
 }
 
 var DefaultAppConf = AppConf{
@@ -61,4 +141,17 @@ var DefaultAppConf = AppConf{
 	MetricServerConfCheckUri: "/conf/check",
 	MetricNotifyChanSize:     1000,
 	MetricDelay:              2 * time.Second,
+	CheckConcurrency:         uint(runtime.NumCPU() * 32),
+	CheckJitter:              0.1,
+	CheckHistory:             100,
+	StateFile:                "",
+	StateMaxAge:              10 * time.Minute,
+	StateSaveInterval:        30 * time.Second,
+	AdminAddr:                "127.0.0.1:8899",
+	AdminAllowRemote:         false,
+	ActionerRate:             RateLimit{Rate: 50, Burst: 100},
+	ActionerTypeRates:        nil,
+	LogFormat:                "text",
+	LogThrottleInterval:      2 * time.Minute,
+	StrictPreflight:          false,
 }
@@ -0,0 +1,270 @@
+// /*
+// Copyright 2025 IQiYi Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// */
+
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+const (
+	LogFormatText = "text" // logs by glog, the default
+	LogFormatJSON = "json" // logs as JSON objects, one per line
+
+	DefaultLogThrottleInterval = 2 * time.Minute
+)
+
+// Levels of LogEvent.
+const (
+	LogLevelInfo    = "info"
+	LogLevelWarning = "warning"
+	LogLevelError   = "error"
+)
+
+// Kinds of LogEvent.
+const (
+	LogEventCheck      = "check"      // check failure
+	LogEventTransition = "transition" // state change noticed
+	LogEventAction     = "action"     // actioner outcome
+	LogEventSuppressed = "suppressed" // summary of the suppressed identical failures
+)
+
+// LogEvent is a structured log of a target. The fields irrelevant to the event
+// are left empty, but always present in the JSON object for a stable schema.
+type LogEvent struct {
+	Time       time.Time `json:"time"`
+	Level      string    `json:"level"`
+	Event      string    `json:"event"`
+	Target     string    `json:"target"`
+	VIP        string    `json:"vip"`
+	Method     string    `json:"method"`
+	State      string    `json:"state"`
+	Reason     string    `json:"reason"`
+	LatencyMs  float64   `json:"latency_ms"`
+	Error      string    `json:"error"`
+	Message    string    `json:"msg"`
+	Suppressed int       `json:"suppressed,omitempty"`
+}
+
+// SetLatency sets LatencyMs of the event in milliseconds.
+func (ev *LogEvent) SetLatency(latency time.Duration) {
+	ev.LatencyMs = float64(latency.Microseconds()) / 1000
+}
+
+// EventLogger is the logging facade of check results, state transitions and
+// actioner outcomes, which logs the events by glog in text format, or as JSON
+// objects. Repeated identical failures of a target, i.e., failures of the same
+// event, state and reason, are suppressed after the first occurrence, and
+// summarized every `interval` in a suppressed event, while the other events
+// are never suppressed. Zero `interval` disables the suppression.
+type EventLogger struct {
+	json     bool
+	interval time.Duration
+	limiter  *LogLimiter // bounds the failure logs in text format, optional
+
+	lock      sync.Mutex
+	out       io.Writer
+	throttles map[string]*logThrottle
+
+	// hooks for tests
+	now  func() time.Time
+	emit func(level, msg string)
+}
+
+// logThrottle tracks the failures of a target for suppression.
+type logThrottle struct {
+	last       string    // signature of the last failure logged
+	suppressed int       // identical failures suppressed in this period
+	since      time.Time // start of this period
+	period     uint64    // sequence of the period, to discard outdated summaries
+	event      LogEvent  // the last failure suppressed
+}
+
+// NewEventLogger creates an EventLogger of `format`, which is LogFormatText
+// unless LogFormatJSON is given. JSON objects are written to `out`, and the
+// failures in text format are further bounded by `limiter` if not nil.
+func NewEventLogger(format string, interval time.Duration, out io.Writer,
+	limiter *LogLimiter) *EventLogger {
+	if interval < 0 {
+		interval = 0
+	}
+	return &EventLogger{
+		json:      format == LogFormatJSON,
+		interval:  interval,
+		limiter:   limiter,
+		out:       out,
+		throttles: make(map[string]*logThrottle),
+		now:       time.Now,
+		emit:      emitEventLog,
+	}
+}
+
+func emitEventLog(level, msg string) {
+	switch level {
+	case LogLevelError:
+		glog.ErrorDepth(3, msg)
+	case LogLevelWarning:
+		glog.WarningDepth(3, msg)
+	default:
+		glog.InfoDepth(3, msg)
+	}
+}
+
+// Log logs the event unconditionally.
+func (l *EventLogger) Log(ev *LogEvent) {
+	l.write(ev, "")
+}
+
+// LogTransition logs the state transition event, and summarizes the failures
+// suppressed so far. The next failure of the target is logged then.
+func (l *EventLogger) LogTransition(ev *LogEvent) {
+	l.Forget(ev.VIP, ev.Target)
+	l.write(ev, "")
+}
+
+// LogFailure logs the failure event unless it's identical to the last failure
+// logged of the target, and returns false if suppressed. In text format, the
+// failure is also subject to the limiter of `key`, such as "UDP checks failed".
+func (l *EventLogger) LogFailure(key string, ev *LogEvent) bool {
+	id := ev.VIP + "/" + ev.Target
+	sig := strings.Join([]string{ev.Event, ev.State, ev.Reason}, "|")
+
+	l.lock.Lock()
+	th, ok := l.throttles[id]
+	if !ok {
+		th = &logThrottle{}
+		l.throttles[id] = th
+	}
+	if l.interval > 0 && th.last == sig {
+		if th.suppressed == 0 {
+			th.since = l.now()
+			th.period++
+			period := th.period
+			time.AfterFunc(l.interval, func() { l.summarize(id, period) })
+		}
+		th.suppressed++
+		th.event = *ev
+		l.lock.Unlock()
+		return false
+	}
+	summary := th.take(l.now())
+	th.last = sig
+	l.lock.Unlock()
+
+	if summary != nil {
+		l.write(summary, "")
+	}
+	l.write(ev, key)
+	return true
+}
+
+// Forget summarizes the failures suppressed of the target, and forgets it.
+func (l *EventLogger) Forget(vip, target string) {
+	id := vip + "/" + target
+	l.lock.Lock()
+	th, ok := l.throttles[id]
+	if !ok {
+		l.lock.Unlock()
+		return
+	}
+	delete(l.throttles, id)
+	summary := th.take(l.now())
+	l.lock.Unlock()
+
+	if summary != nil {
+		l.write(summary, "")
+	}
+}
+
+func (l *EventLogger) summarize(id string, period uint64) {
+	l.lock.Lock()
+	th, ok := l.throttles[id]
+	if !ok || th.period != period {
+		l.lock.Unlock()
+		return
+	}
+	summary := th.take(l.now())
+	l.lock.Unlock()
+
+	if summary != nil {
+		l.write(summary, "")
+	}
+}
+
+// take returns the summary of the failures suppressed, and resets the count.
+// It returns nil if nothing suppressed.
+func (th *logThrottle) take(now time.Time) *LogEvent {
+	if th.suppressed == 0 {
+		return nil
+	}
+	summary := th.event
+	summary.Time = time.Time{}
+	summary.Event = LogEventSuppressed
+	summary.LatencyMs = 0
+	summary.Suppressed = th.suppressed
+	summary.Message = fmt.Sprintf("%s/%s: suppressed %d identical errors in last %s",
+		summary.VIP, summary.Target, th.suppressed, shortDuration(now.Sub(th.since)))
+	th.suppressed = 0
+	return &summary
+}
+
+// write logs the event, and the failures of `key` are bounded by the limiter
+// in text format.
+func (l *EventLogger) write(ev *LogEvent, key string) {
+	if ev.Time.IsZero() {
+		ev.Time = l.now()
+	}
+	if !l.json {
+		if len(key) > 0 && l.limiter != nil && ev.Level != LogLevelInfo {
+			if ev.Level == LogLevelError {
+				l.limiter.Errorf(key, "%s", ev.Message)
+			} else {
+				l.limiter.Warningf(key, "%s", ev.Message)
+			}
+			return
+		}
+		l.emit(ev.Level, ev.Message)
+		return
+	}
+
+	data, err := json.Marshal(ev)
+	if err != nil {
+		glog.Warningf("failed to marshal log event %+v: %v", *ev, err)
+		return
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.out.Write(append(data, '\n'))
+}
+
+// shortDuration formats `d` in seconds at most, such as "2m" and "1h30m".
+func shortDuration(d time.Duration) string {
+	s := d.Round(time.Second).String()
+	if strings.HasSuffix(s, "m0s") {
+		s = s[:len(s)-2]
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = s[:len(s)-2]
+	}
+	return s
+}
@@ -0,0 +1,178 @@
+// /*
+// Copyright 2025 IQiYi Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// */
+
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type syncBuffer struct {
+	lock sync.Mutex
+	buf  bytes.Buffer
+	now  time.Time
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) lines() []string {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return strings.Split(strings.TrimSpace(b.buf.String()), "\n")
+}
+
+func (b *syncBuffer) clock() time.Time {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.now
+}
+
+func (b *syncBuffer) advance(d time.Duration) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.now = b.now.Add(d)
+}
+
+func checkFailure(reason string) *LogEvent {
+	return &LogEvent{
+		Level:  LogLevelWarning,
+		Event:  LogEventCheck,
+		Target: "192.168.88.30-TCP-8080",
+		VIP:    "192.168.88.1-TCP-80",
+		Method: "http",
+		State:  "Unhealthy",
+		Reason: reason,
+		Error:  "connection refused",
+	}
+}
+
+func TestEventLoggerJSON(t *testing.T) {
+	out := &syncBuffer{now: time.Now()}
+	interval := 100 * time.Millisecond
+	l := NewEventLogger(LogFormatJSON, interval, out, nil)
+	l.now = out.clock
+
+	ev := checkFailure("connect-refused")
+	ev.SetLatency(1500 * time.Microsecond)
+	if !l.LogFailure("HTTP checks failed", ev) {
+		t.Fatalf("expect the first failure logged")
+	}
+	for i := 0; i < 5; i++ {
+		if l.LogFailure("HTTP checks failed", checkFailure("connect-refused")) {
+			t.Fatalf("expect identical failure %d suppressed", i)
+		}
+	}
+
+	lines := out.lines()
+	if len(lines) != 1 {
+		t.Fatalf("expect 1 log line, got %q", lines)
+	}
+	var obj map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &obj); err != nil {
+		t.Fatalf("invalid JSON log %q: %v", lines[0], err)
+	}
+	for _, key := range []string{"time", "level", "event", "target", "vip", "method",
+		"state", "reason", "latency_ms", "error", "msg"} {
+		if _, ok := obj[key]; !ok {
+			t.Errorf("missing key %q in JSON log %q", key, lines[0])
+		}
+	}
+	if obj["latency_ms"] != 1.5 || obj["reason"] != "connect-refused" || obj["method"] != "http" {
+		t.Errorf("unexpected JSON log %q", lines[0])
+	}
+
+	// The suppressed failures are summarized in the interval.
+	out.advance(2 * time.Minute)
+	time.Sleep(interval + 100*time.Millisecond)
+	lines = out.lines()
+	if len(lines) != 2 {
+		t.Fatalf("expect summary logged, got %q", lines)
+	}
+	var summary LogEvent
+	if err := json.Unmarshal([]byte(lines[1]), &summary); err != nil {
+		t.Fatalf("invalid JSON log %q: %v", lines[1], err)
+	}
+	expected := "192.168.88.1-TCP-80/192.168.88.30-TCP-8080: suppressed 5 identical errors in last 2m"
+	if summary.Event != LogEventSuppressed || summary.Suppressed != 5 || summary.Message != expected {
+		t.Errorf("unexpected summary %+v", summary)
+	}
+
+	// A different failure is logged immediately, after the summary of the
+	// failures suppressed so far.
+	l.LogFailure("HTTP checks failed", checkFailure("connect-refused"))
+	l.LogFailure("HTTP checks failed", checkFailure("connect-refused"))
+	if !l.LogFailure("HTTP checks failed", checkFailure("status-mismatch")) {
+		t.Errorf("expect different failure logged")
+	}
+	lines = out.lines()
+	if len(lines) != 4 || !strings.Contains(lines[2], "suppressed 2 identical errors") ||
+		!strings.Contains(lines[3], "status-mismatch") {
+		t.Fatalf("unexpected logs on different failure: %q", lines[2:])
+	}
+
+	// Transitions are never suppressed, and reset the suppression.
+	l.LogFailure("HTTP checks failed", checkFailure("status-mismatch"))
+	for i := 0; i < 2; i++ {
+		l.LogTransition(&LogEvent{Level: LogLevelInfo, Event: LogEventTransition,
+			Target: ev.Target, VIP: ev.VIP, State: "Healthy"})
+	}
+	if !l.LogFailure("HTTP checks failed", checkFailure("status-mismatch")) {
+		t.Errorf("expect failure logged after transition")
+	}
+	lines = out.lines()
+	if len(lines) != 8 || !strings.Contains(lines[4], "suppressed 1 identical errors") ||
+		!strings.Contains(lines[5], `"event":"transition"`) ||
+		!strings.Contains(lines[6], `"event":"transition"`) ||
+		!strings.Contains(lines[7], "status-mismatch") {
+		t.Fatalf("unexpected logs on transition: %q", lines[4:])
+	}
+}
+
+func TestEventLoggerText(t *testing.T) {
+	rec := &logRecorder{}
+	limiter := NewLogLimiter(2, time.Hour)
+	limiter.emit = rec.emit
+	l := NewEventLogger(LogFormatText, 0, nil, limiter)
+	var emitted []string
+	l.emit = func(level, msg string) { emitted = append(emitted, level+":"+msg) }
+
+	// Zero interval disables suppression, leaving failures to the limiter.
+	for i := 0; i < 3; i++ {
+		ev := checkFailure("timeout")
+		ev.Message = fmt.Sprintf("check %d timeout", i)
+		if !l.LogFailure("HTTP checks timeout", ev) {
+			t.Errorf("expect failure %d not suppressed", i)
+		}
+	}
+	l.Log(&LogEvent{Level: LogLevelInfo, Event: LogEventAction, Message: "action done"})
+	if logs := rec.get(); len(logs) != 2 || logs[0] != "0:check 0 timeout" ||
+		logs[1] != "0:check 1 timeout" {
+		t.Errorf("unexpected failure logs: %q", logs)
+	}
+	if len(emitted) != 1 || emitted[0] != "info:action done" {
+		t.Errorf("unexpected action logs: %q", emitted)
+	}
+}
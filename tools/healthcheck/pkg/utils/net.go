@@ -15,11 +15,13 @@
 package utils
 
 import (
+	"context"
 	"fmt"
 	"net"
 	"strconv"
 	"strings"
 	"syscall"
+	"time"
 )
 
 // AF represents a network address family.
@@ -87,15 +89,16 @@ func (proto IPProto) String() string {
 }
 
 // ParseIPProto return an IPProto from its string representation.
+// The protocol name is case insensitive.
 func ParseIPProto(str string) IPProto {
-	switch str {
+	switch strings.ToUpper(str) {
 	case "TCP":
 		return IPProtoTCP
 	case "UDP":
 		return IPProtoUDP
 	case "ICMP":
 		return IPProtoICMP
-	case "ICMPv6":
+	case "ICMPV6", "ICMP6":
 		return IPProtoICMPv6
 	}
 	return 0
@@ -106,11 +109,21 @@ type L3L4Addr struct {
 	IP    net.IP
 	Port  uint16
 	Proto IPProto
+	Zone  string // IPv6 scoped addressing zone, i.e., the interface name
 }
 
 // String returns the string representation of the given L3L4Addr value.
 func (addr *L3L4Addr) String() string {
-	return fmt.Sprintf("%s-%s-%d", addr.IP, addr.Proto, addr.Port)
+	return fmt.Sprintf("%s-%s-%d", addr.ZonedIP(), addr.Proto, addr.Port)
+}
+
+// ZonedIP returns the IP with zone in format "fe80::1%eth1", or the IP alone
+// if no zone is specified.
+func (addr *L3L4Addr) ZonedIP() string {
+	if len(addr.Zone) == 0 {
+		return addr.IP.String()
+	}
+	return addr.IP.String() + "%" + addr.Zone
 }
 
 func (in *L3L4Addr) DeepCopyInto(out *L3L4Addr) {
@@ -151,42 +164,209 @@ func (addr *L3L4Addr) Network() string {
 	return network
 }
 
-// Addr returns the IP:Port representation for net.Dailer
+// Addr returns the IP:Port representation for net.Dailer, the IPv6 zone is
+// included if any, such as "[fe80::1%eth1]:80".
 func (addr *L3L4Addr) Addr() string {
 	if addr.IP.To4() != nil {
 		return fmt.Sprintf("%v:%d", addr.IP, addr.Port)
 	}
-	return fmt.Sprintf("[%v]:%d", addr.IP, addr.Port)
+	return fmt.Sprintf("[%s]:%d", addr.ZonedIP(), addr.Port)
 }
 
 // ParseL3L4Addr produces a L3L4Addr from its string representation.
+// It returns nil if str is invalid, refer to ParseL3L4AddrE for details.
 func ParseL3L4Addr(str string) *L3L4Addr {
-	segs := strings.Split(str, "-")
-	addr := L3L4Addr{}
-	if len(segs) > 0 {
-		if ip := net.ParseIP(segs[0]); ip != nil {
-			addr.IP = ip
-		} else {
-			return nil
-		}
+	addr, err := ParseL3L4AddrE(str)
+	if err != nil {
+		return nil
 	}
-	segs = segs[1:]
-	if len(segs) > 0 {
-		if proto := ParseIPProto(segs[0]); proto != 0 {
+	return addr
+}
+
+// ParseL3L4AddrE produces a L3L4Addr from its string representation, and
+// returns an error describing what is wrong if str is invalid. Supported
+// formats are listed below, where protocol names are case insensitive.
+//
+//	IP, IP-PROTO, IP-PROTO-PORT:   192.168.88.1-TCP-80, 2001::1-udp-53
+//	IP:PORT, [IP]:PORT:            192.168.88.1:80, [2001::1]:443
+//	IP[:PORT]/PROTO:               192.168.88.1:80/tcp, [2001::1]/icmpv6
+//	IP, [IP]:                      192.168.88.1, 2001::1, [2001::1]
+//
+// Port is required for TCP and UDP, and for IP:PORT formats. IPv6 addresses
+// may have a zone, such as fe80::1%eth1-TCP-80 and [fe80::1%eth1]:80.
+func ParseL3L4AddrE(str string) (*L3L4Addr, error) {
+	s := strings.TrimSpace(str)
+	if len(s) == 0 {
+		return nil, fmt.Errorf("empty address")
+	}
+
+	var addr *L3L4Addr
+	var err error
+	segs := strings.Split(s, "-")
+	n := len(segs)
+	if i := strings.LastIndexByte(s, '/'); i >= 0 {
+		proto := ParseIPProto(s[i+1:])
+		if proto == 0 {
+			return nil, fmt.Errorf("invalid protocol %q in address %q", s[i+1:], str)
+		}
+		if addr, err = parseHostPort(s[:i]); err == nil {
 			addr.Proto = proto
-		} else {
-			return nil
 		}
+	} else if n >= 3 && ParseIPProto(segs[n-2]) != 0 {
+		addr, err = parseDashedAddr(strings.Join(segs[:n-2], "-"), segs[n-2], &segs[n-1])
+	} else if n >= 2 && ParseIPProto(segs[n-1]) != 0 {
+		addr, err = parseDashedAddr(strings.Join(segs[:n-1], "-"), segs[n-1], nil)
+	} else {
+		addr, err = parseHostPort(s)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid address %q: %v", str, err)
+	}
+
+	if (addr.Proto == IPProtoTCP || addr.Proto == IPProtoUDP) && addr.Port == 0 {
+		return nil, fmt.Errorf("invalid address %q: port required for %s", str, addr.Proto)
+	}
+	return addr, nil
+}
+
+// parseZonedIP parses an IP address, which may contain an IPv6 zone.
+func parseZonedIP(s string) (net.IP, string, error) {
+	host, zone := s, ""
+	if i := strings.LastIndexByte(s, '%'); i >= 0 {
+		host, zone = s[:i], s[i+1:]
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return nil, "", fmt.Errorf("invalid IP %q", host)
+	}
+	if host != s && (ip.To4() != nil || len(zone) == 0 || strings.ContainsAny(zone, ":[]")) {
+		return nil, "", fmt.Errorf("invalid IPv6 zone in %q", s)
+	}
+	return ip, zone, nil
+}
+
+// parseHostPort parses formats IP, [IP], IP:PORT and [IP]:PORT.
+func parseHostPort(s string) (*L3L4Addr, error) {
+	if strings.HasPrefix(s, "[") && strings.HasSuffix(s, "]") {
+		ip, zone, err := parseZonedIP(s[1 : len(s)-1])
+		if err != nil {
+			return nil, err
+		}
+		return &L3L4Addr{IP: ip, Zone: zone}, nil
+	}
+	if !strings.HasPrefix(s, "[") && strings.Count(s, ":") != 1 {
+		ip, zone, err := parseZonedIP(s)
+		if err != nil {
+			return nil, err
+		}
+		return &L3L4Addr{IP: ip, Zone: zone}, nil
+	}
+
+	host, portStr, err := net.SplitHostPort(s)
+	if err != nil {
+		return nil, err
+	}
+	ip, zone, err := parseZonedIP(host)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil || port == 0 {
+		return nil, fmt.Errorf("invalid port %q", portStr)
+	}
+	return &L3L4Addr{IP: ip, Port: uint16(port), Zone: zone}, nil
+}
+
+// parseDashedAddr parses formats IP-PROTO and IP-PROTO-PORT, where port is
+// nil for the former.
+func parseDashedAddr(ipStr, protoStr string, portStr *string) (*L3L4Addr, error) {
+	ip, zone, err := parseZonedIP(ipStr)
+	if err != nil {
+		return nil, err
+	}
+	addr := &L3L4Addr{IP: ip, Proto: ParseIPProto(protoStr), Zone: zone}
+	if portStr != nil {
+		port, err := strconv.ParseUint(*portStr, 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid port %q", *portStr)
+		}
+		addr.Port = uint16(port)
+	}
+	return addr, nil
+}
+
+// MarshalText implements the encoding.TextMarshaler interface, so that
+// L3L4Addr can be used as JSON/YAML field or map key directly.
+func (addr L3L4Addr) MarshalText() ([]byte, error) {
+	switch {
+	case addr.IP == nil:
+		return []byte{}, nil
+	case addr.Proto != 0:
+		return []byte(addr.String()), nil
+	case addr.Port != 0:
+		return []byte(addr.Addr()), nil
+	}
+	return []byte(addr.ZonedIP()), nil
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface.
+func (addr *L3L4Addr) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		*addr = L3L4Addr{}
+		return nil
+	}
+	parsed, err := ParseL3L4AddrE(string(text))
+	if err != nil {
+		return err
+	}
+	*addr = *parsed
+	return nil
+}
+
+// DualStackAddrs returns the L3L4Addrs of a dual-stack target from its IPv4
+// and IPv6 address pair, either of which can be nil but not both.
+func DualStackAddrs(v4, v6 net.IP, proto IPProto, port uint16) ([]*L3L4Addr, error) {
+	addrs := make([]*L3L4Addr, 0, 2)
+	if len(v4) > 0 {
+		if v4.To4() == nil {
+			return nil, fmt.Errorf("%v is not an IPv4 address", v4)
+		}
+		addrs = append(addrs, &L3L4Addr{IP: IPAddrClone(v4.To4()), Port: port, Proto: proto})
+	}
+	if len(v6) > 0 {
+		if v6.To16() == nil || v6.To4() != nil {
+			return nil, fmt.Errorf("%v is not an IPv6 address", v6)
+		}
+		addrs = append(addrs, &L3L4Addr{IP: IPAddrClone(v6), Port: port, Proto: proto})
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("no address of dual-stack target")
+	}
+	return addrs, nil
+}
+
+// LookupDualStackAddrs resolves `host` within `timeout`, and returns the
+// L3L4Addrs of a dual-stack target with the first IPv4 and IPv6 address found.
+// IP literals are accepted as the host too.
+func LookupDualStackAddrs(host string, proto IPProto, port uint16,
+	timeout time.Duration) ([]*L3L4Addr, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	ips, err := net.DefaultResolver.LookupIP(ctx, "ip", host)
+	if err != nil {
+		return nil, err
 	}
-	segs = segs[1:]
-	if len(segs) > 0 {
-		if port, err := strconv.ParseUint(segs[0], 10, 16); err != nil {
-			return nil
-		} else {
-			addr.Port = uint16(port)
+	var v4, v6 net.IP
+	for _, ip := range ips {
+		if IPAF(ip) == IPv4 {
+			if v4 == nil {
+				v4 = ip
+			}
+		} else if v6 == nil {
+			v6 = ip
 		}
 	}
-	return &addr
+	return DualStackAddrs(v4, v6, proto, port)
 }
 
 // WriteFull tries to write the whole data in a slice to a net conn.
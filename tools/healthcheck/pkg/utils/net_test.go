@@ -0,0 +1,282 @@
+// /*
+// Copyright 2025 IQiYi Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// */
+
+package utils
+
+import (
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+func TestParseL3L4Addr(t *testing.T) {
+	for _, tc := range []struct {
+		str    string
+		expect *L3L4Addr // nil if invalid
+	}{
+		// dashed format
+		{"192.168.88.1-TCP-80", &L3L4Addr{IP: net.ParseIP("192.168.88.1"), Port: 80, Proto: IPProtoTCP}},
+		{"192.168.88.1-udp-53", &L3L4Addr{IP: net.ParseIP("192.168.88.1"), Port: 53, Proto: IPProtoUDP}},
+		{"2001::1-Tcp-443", &L3L4Addr{IP: net.ParseIP("2001::1"), Port: 443, Proto: IPProtoTCP}},
+		{"192.168.88.1-ICMP", &L3L4Addr{IP: net.ParseIP("192.168.88.1"), Proto: IPProtoICMP}},
+		{"2001::1-ICMPv6-0", &L3L4Addr{IP: net.ParseIP("2001::1"), Proto: IPProtoICMPv6}},
+		{" 192.168.88.1-TCP-80 ", &L3L4Addr{IP: net.ParseIP("192.168.88.1"), Port: 80, Proto: IPProtoTCP}},
+		{"192.168.88.1-TCP", nil},
+		{"192.168.88.1-TCP-0", nil},
+		{"192.168.88.1-TCP-", nil},
+		{"192.168.88.1-TCP-65536", nil},
+		{"192.168.88.1-TCP-http", nil},
+		{"192.168.88.1-SCTP-80", nil},
+		{"192.168.88.1-TCP-80-1", nil},
+		{"192.168.88.300-TCP-80", nil},
+		{"www.iqiyi.com-TCP-80", nil},
+		// host:port format
+		{"10.0.0.1:80", &L3L4Addr{IP: net.ParseIP("10.0.0.1"), Port: 80}},
+		{"10.0.0.1:80/tcp", &L3L4Addr{IP: net.ParseIP("10.0.0.1"), Port: 80, Proto: IPProtoTCP}},
+		{"10.0.0.1:53/UDP", &L3L4Addr{IP: net.ParseIP("10.0.0.1"), Port: 53, Proto: IPProtoUDP}},
+		{"[2001:db8::1]:443", &L3L4Addr{IP: net.ParseIP("2001:db8::1"), Port: 443}},
+		{"[2001:db8::1]:443/tcp", &L3L4Addr{IP: net.ParseIP("2001:db8::1"), Port: 443, Proto: IPProtoTCP}},
+		{"10.0.0.1/icmp", &L3L4Addr{IP: net.ParseIP("10.0.0.1"), Proto: IPProtoICMP}},
+		{"2001:db8::1/icmpv6", &L3L4Addr{IP: net.ParseIP("2001:db8::1"), Proto: IPProtoICMPv6}},
+		{"[2001:db8::1]/icmp6", &L3L4Addr{IP: net.ParseIP("2001:db8::1"), Proto: IPProtoICMPv6}},
+		{"10.0.0.1:0", nil},
+		{"10.0.0.1:", nil},
+		{"10.0.0.1:65536", nil},
+		{"10.0.0.1/tcp", nil},
+		{"10.0.0.1:80/", nil},
+		{"10.0.0.1:80/sctp", nil},
+		{"[10.0.0.1:80", nil},
+		{"2001:db8::1:443/tcp", nil},
+		{"[2001:db8::1]:http", nil},
+		{"localhost:80", nil},
+		// bare IP
+		{"10.0.0.1", &L3L4Addr{IP: net.ParseIP("10.0.0.1")}},
+		{"2001:db8::1", &L3L4Addr{IP: net.ParseIP("2001:db8::1")}},
+		{"[2001:db8::1]", &L3L4Addr{IP: net.ParseIP("2001:db8::1")}},
+		{"10.0.0", nil},
+		{"", nil},
+		// IPv6 zone
+		{"fe80::1%eth0", &L3L4Addr{IP: net.ParseIP("fe80::1"), Zone: "eth0"}},
+		{"[fe80::1%eth0]", &L3L4Addr{IP: net.ParseIP("fe80::1"), Zone: "eth0"}},
+		{"[fe80::1%eth0]:80", &L3L4Addr{IP: net.ParseIP("fe80::1"), Port: 80, Zone: "eth0"}},
+		{"[fe80::1%2]:80/tcp", &L3L4Addr{IP: net.ParseIP("fe80::1"), Port: 80, Proto: IPProtoTCP, Zone: "2"}},
+		{"fe80::1%eth1-TCP-80", &L3L4Addr{IP: net.ParseIP("fe80::1"), Port: 80, Proto: IPProtoTCP, Zone: "eth1"}},
+		{"fe80::1%eth-1-TCP-80", &L3L4Addr{IP: net.ParseIP("fe80::1"), Port: 80, Proto: IPProtoTCP, Zone: "eth-1"}},
+		{"fe80::1%eth0-ICMPv6", &L3L4Addr{IP: net.ParseIP("fe80::1"), Proto: IPProtoICMPv6, Zone: "eth0"}},
+		{"10.0.0.1%eth0", nil},
+		{"fe80::1%", nil},
+		{"fe80::1%eth0:80", nil},
+		{"[fe80::1%]:80", nil},
+	} {
+		addr, err := ParseL3L4AddrE(tc.str)
+		if tc.expect == nil {
+			if err == nil {
+				t.Errorf("%q: expect error, got %v", tc.str, addr)
+			} else if ParseL3L4Addr(tc.str) != nil {
+				t.Errorf("%q: expect nil", tc.str)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", tc.str, err)
+			continue
+		}
+		if !addr.IP.Equal(tc.expect.IP) || addr.Port != tc.expect.Port || addr.Proto != tc.expect.Proto ||
+			addr.Zone != tc.expect.Zone {
+			t.Errorf("%q: expect %v, got %v", tc.str, tc.expect, addr)
+		}
+	}
+}
+
+// TestParseL3L4AddrShort is a regression test of ParseL3L4Addr panicking with
+// slice bounds out of range on addresses of less than three segments.
+func TestParseL3L4AddrShort(t *testing.T) {
+	for _, tc := range []struct {
+		str    string
+		expect *L3L4Addr // nil if invalid
+	}{
+		{"192.168.88.1", &L3L4Addr{IP: net.ParseIP("192.168.88.1")}},
+		{"2001::1", &L3L4Addr{IP: net.ParseIP("2001::1")}},
+		{"192.168.88.1-ICMP", &L3L4Addr{IP: net.ParseIP("192.168.88.1"), Proto: IPProtoICMP}},
+		{"192.168.88.1-TCP", nil},
+		{"192.168.88.1-", nil},
+		{"-", nil},
+		{"--", nil},
+		{"-TCP-80", nil},
+	} {
+		var addr *L3L4Addr
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					t.Errorf("%q: panic: %v", tc.str, r)
+				}
+			}()
+			addr = ParseL3L4Addr(tc.str)
+		}()
+		if tc.expect == nil {
+			if addr != nil {
+				t.Errorf("%q: expect nil, got %v", tc.str, addr)
+			}
+			continue
+		}
+		if addr == nil || !addr.IP.Equal(tc.expect.IP) || addr.Port != tc.expect.Port ||
+			addr.Proto != tc.expect.Proto {
+			t.Errorf("%q: expect %v, got %v", tc.str, tc.expect, addr)
+		}
+	}
+}
+
+func TestL3L4AddrZone(t *testing.T) {
+	addr := &L3L4Addr{IP: net.ParseIP("fe80::1"), Port: 80, Proto: IPProtoTCP, Zone: "eth1"}
+	if s := addr.String(); s != "fe80::1%eth1-TCP-80" {
+		t.Errorf("unexpected string %q", s)
+	}
+	if s := addr.Addr(); s != "[fe80::1%eth1]:80" {
+		t.Errorf("unexpected addr %q", s)
+	}
+	if s := addr.Network(); s != "tcp6" {
+		t.Errorf("unexpected network %q", s)
+	}
+	if host, port, err := net.SplitHostPort(addr.Addr()); err != nil || host != "fe80::1%eth1" || port != "80" {
+		t.Errorf("addr %q not dialable: %q, %q, %v", addr.Addr(), host, port, err)
+	}
+	if parsed := ParseL3L4Addr(addr.String()); parsed == nil || parsed.String() != addr.String() {
+		t.Errorf("round trip mismatch: %v", parsed)
+	}
+	if parsed := ParseL3L4Addr(addr.Addr()); parsed == nil || parsed.Addr() != addr.Addr() ||
+		parsed.Zone != addr.Zone {
+		t.Errorf("round trip mismatch of addr: %v", parsed)
+	}
+	if parsed := ParseL3L4Addr("[fe80::1%2]:80"); parsed == nil || parsed.Zone != "2" {
+		t.Errorf("numeric zone not parsed: %v", parsed)
+	}
+	copied := addr.DeepCopy()
+	copied.IP[15] = 2
+	if copied.Zone != "eth1" || addr.IP.Equal(copied.IP) {
+		t.Errorf("unexpected deep copy %v of %v", copied, addr)
+	}
+
+	noZone := &L3L4Addr{IP: net.ParseIP("2001::1"), Port: 80, Proto: IPProtoTCP}
+	if noZone.String() != "2001::1-TCP-80" || noZone.Addr() != "[2001::1]:80" {
+		t.Errorf("unexpected zoneless addr %v, %v", noZone, noZone.Addr())
+	}
+}
+
+func TestL3L4AddrText(t *testing.T) {
+	type object struct {
+		Target  L3L4Addr            `json:"target" yaml:"target"`
+		Targets map[*L3L4Addr]int   `json:"targets" yaml:"-"`
+		Labels  map[string]L3L4Addr `json:"labels" yaml:"labels"`
+	}
+
+	for _, str := range []string{"192.168.88.1-TCP-80", "2001::1-UDP-53", "10.0.0.1:80",
+		"[2001::1]:443", "10.0.0.1", "2001::1", "10.0.0.1-ICMP-0", "fe80::1%eth1-TCP-80",
+		"[fe80::1%eth1]:80", "fe80::1%eth1", ""} {
+		var addr L3L4Addr
+		if err := addr.UnmarshalText([]byte(str)); err != nil {
+			t.Errorf("failed to unmarshal %q: %v", str, err)
+			continue
+		}
+		text, err := addr.MarshalText()
+		if err != nil {
+			t.Errorf("failed to marshal %v: %v", addr, err)
+			continue
+		}
+		var again L3L4Addr
+		if err := again.UnmarshalText(text); err != nil || !again.IP.Equal(addr.IP) ||
+			again.Port != addr.Port || again.Proto != addr.Proto || again.Zone != addr.Zone {
+			t.Errorf("%q: round trip mismatch, %q -> %v, %v", str, text, again, err)
+		}
+	}
+
+	target := L3L4Addr{IP: net.ParseIP("2001::1"), Port: 80, Proto: IPProtoTCP}
+	obj := object{
+		Target:  target,
+		Targets: map[*L3L4Addr]int{&target: 1},
+		Labels:  map[string]L3L4Addr{"web": {IP: net.ParseIP("10.0.0.1"), Port: 53, Proto: IPProtoUDP}},
+	}
+	data, err := json.Marshal(obj)
+	if err != nil {
+		t.Fatalf("failed to marshal json: %v", err)
+	}
+	expect := `{"target":"2001::1-TCP-80","targets":{"2001::1-TCP-80":1},"labels":{"web":"10.0.0.1-UDP-53"}}`
+	if string(data) != expect {
+		t.Errorf("expect json %s, got %s", expect, data)
+	}
+	var decoded object
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("failed to unmarshal json: %v", err)
+	}
+	if decoded.Target.String() != target.String() || len(decoded.Targets) != 1 ||
+		decoded.Labels["web"].Port != 53 {
+		t.Errorf("unexpected json decoded: %+v", decoded)
+	}
+	for k, v := range decoded.Targets {
+		if k.String() != target.String() || v != 1 {
+			t.Errorf("unexpected json decoded map: %v: %v", k, v)
+		}
+	}
+	if err := json.Unmarshal([]byte(`{"target":"10.0.0.1:0"}`), &decoded); err == nil {
+		t.Errorf("expect json unmarshal error")
+	}
+
+	data, err = yaml.Marshal(obj)
+	if err != nil {
+		t.Fatalf("failed to marshal yaml: %v", err)
+	}
+	decoded = object{}
+	if err := yaml.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("failed to unmarshal yaml: %v", err)
+	}
+	if decoded.Target.String() != target.String() || decoded.Labels["web"].Proto != IPProtoUDP {
+		t.Errorf("unexpected yaml decoded %+v from %s", decoded, data)
+	}
+	if err := yaml.Unmarshal([]byte("target: 10.0.0.1-SCTP-80\n"), &decoded); err == nil {
+		t.Errorf("expect yaml unmarshal error")
+	}
+}
+
+func TestDualStackAddrs(t *testing.T) {
+	v4, v6 := net.ParseIP("192.168.88.30"), net.ParseIP("2001::30")
+	addrs, err := DualStackAddrs(v4, v6, IPProtoTCP, 80)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(addrs) != 2 || addrs[0].Network() != "tcp4" || addrs[0].Addr() != "192.168.88.30:80" ||
+		addrs[1].Network() != "tcp6" || addrs[1].Addr() != "[2001::30]:80" {
+		t.Errorf("unexpected dual-stack addrs: %v", addrs)
+	}
+	if addrs, err := DualStackAddrs(nil, v6, IPProtoTCP, 80); err != nil || len(addrs) != 1 {
+		t.Errorf("expected single IPv6 addr, got %v, %v", addrs, err)
+	}
+	for _, pair := range [][2]net.IP{{v6, v6}, {v4, v4}, {nil, nil}} {
+		if _, err := DualStackAddrs(pair[0], pair[1], IPProtoTCP, 80); err == nil {
+			t.Errorf("expected error for address pair %v", pair)
+		}
+	}
+
+	addrs, err = LookupDualStackAddrs("localhost", IPProtoTCP, 80, time.Second)
+	if err != nil || len(addrs) == 0 || addrs[0].Addr() != "127.0.0.1:80" {
+		t.Errorf("unexpected addrs of localhost: %v, %v", addrs, err)
+	}
+	addrs, err = LookupDualStackAddrs("2001::30", IPProtoTCP, 80, time.Second)
+	if err != nil || len(addrs) != 1 || addrs[0].Addr() != "[2001::30]:80" {
+		t.Errorf("unexpected addrs of IP literal: %v, %v", addrs, err)
+	}
+}
@@ -0,0 +1,125 @@
+// /*
+// Copyright 2025 IQiYi Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// */
+
+package utils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// NetnsDir is where the named network namespaces are, the same as `ip netns`.
+const NetnsDir = "/var/run/netns"
+
+// NetnsPath resolves the network namespace `name` to the path of its file. The
+// name containing "/" is a path, such as "/proc/1234/ns/net", and the others
+// are taken as names under NetnsDir.
+func NetnsPath(name string) string {
+	if strings.Contains(name, "/") {
+		return name
+	}
+	return filepath.Join(NetnsDir, name)
+}
+
+// OpenNetns opens the file of the network namespace `name`, see NetnsPath. It
+// fails if the file does not exist or is not a network namespace.
+func OpenNetns(name string) (*os.File, error) {
+	path := NetnsPath(name)
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("network namespace %s not found", path)
+		}
+		return nil, fmt.Errorf("failed to open network namespace %s: %v", path, err)
+	}
+	var fs unix.Statfs_t
+	if err := unix.Fstatfs(int(file.Fd()), &fs); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to stat network namespace %s: %v", path, err)
+	}
+	// The namespace files are in nsfs, or procfs on the kernels before 3.19.
+	if fs.Type != unix.NSFS_MAGIC && fs.Type != unix.PROC_SUPER_MAGIC {
+		file.Close()
+		return nil, fmt.Errorf("%s is not a network namespace", path)
+	}
+	return file, nil
+}
+
+// ValidateNetns checks the network namespace `name` exists. Empty `name` is the
+// current namespace.
+func ValidateNetns(name string) error {
+	if len(name) == 0 {
+		return nil
+	}
+	file, err := OpenNetns(name)
+	if err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// RunInNetns runs `fn` in the network namespace `name`, and returns its error.
+// The sockets created by `fn` stay in the namespace after it returns, but `fn`
+// must not create them in other goroutines, which are not in the namespace.
+// Empty `name` runs `fn` in the current namespace.
+//
+// `fn` runs in a new goroutine locked to its OS thread, whose namespace is
+// switched back before unlocked. If it fails to switch back, the thread is
+// left locked and exits with the goroutine, so that no other goroutine ever
+// runs in the namespace.
+func RunInNetns(name string, fn func() error) error {
+	if len(name) == 0 {
+		return fn()
+	}
+	target, err := OpenNetns(name)
+	if err != nil {
+		return err
+	}
+	defer target.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		runtime.LockOSThread()
+		origin, err := os.Open(fmt.Sprintf("/proc/%d/task/%d/ns/net", os.Getpid(), unix.Gettid()))
+		if err != nil {
+			runtime.UnlockOSThread()
+			done <- fmt.Errorf("failed to open current network namespace: %v", err)
+			return
+		}
+		defer origin.Close()
+		if err := unix.Setns(int(target.Fd()), unix.CLONE_NEWNET); err != nil {
+			runtime.UnlockOSThread()
+			done <- fmt.Errorf("failed to enter network namespace %s: %v", NetnsPath(name), err)
+			return
+		}
+
+		fnErr := fn()
+
+		if err := unix.Setns(int(origin.Fd()), unix.CLONE_NEWNET); err != nil {
+			// Keep the thread locked to terminate it.
+			done <- fmt.Errorf("failed to leave network namespace %s: %v", NetnsPath(name), err)
+			return
+		}
+		runtime.UnlockOSThread()
+		done <- fnErr
+	}()
+	return <-done
+}
@@ -0,0 +1,80 @@
+// /*
+// Copyright 2025 IQiYi Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// */
+
+package utils
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestNetnsPath(t *testing.T) {
+	cases := map[string]string{
+		"tenant1":            "/var/run/netns/tenant1",
+		"/proc/1234/ns/net":  "/proc/1234/ns/net",
+		"./ns/tenant1":       "./ns/tenant1",
+		"/run/netns/tenant1": "/run/netns/tenant1",
+	}
+	for name, expect := range cases {
+		if path := NetnsPath(name); path != expect {
+			t.Errorf("NetnsPath(%q): expect %q, got %q", name, expect, path)
+		}
+	}
+}
+
+func TestValidateNetns(t *testing.T) {
+	cases := []struct {
+		name   string
+		errMsg string
+	}{
+		{"", ""},
+		{"hc-missing-ns", "network namespace /var/run/netns/hc-missing-ns not found"},
+		{"/etc/hostname", "is not a network namespace"},
+		{"/proc/self/ns/net", ""},
+	}
+	for _, c := range cases {
+		err := ValidateNetns(c.name)
+		if len(c.errMsg) == 0 {
+			if err != nil {
+				t.Errorf("ValidateNetns(%q): unexpected error %v", c.name, err)
+			}
+		} else if err == nil || !strings.Contains(err.Error(), c.errMsg) {
+			t.Errorf("ValidateNetns(%q): expect error %q, got %v", c.name, c.errMsg, err)
+		}
+	}
+}
+
+func TestRunInNetns(t *testing.T) {
+	errFn := errors.New("fn error")
+	for _, name := range []string{"", "/proc/self/ns/net"} {
+		called := false
+		err := RunInNetns(name, func() error {
+			called = true
+			return errFn
+		})
+		if !called || err != errFn {
+			t.Errorf("RunInNetns(%q): expect fn called with its error, got %v, %v", name, called, err)
+		}
+	}
+	called := false
+	if err := RunInNetns("hc-missing-ns", func() error {
+		called = true
+		return nil
+	}); err == nil || called {
+		t.Errorf("RunInNetns with missing netns: expect error without fn called, got %v, %v", err, called)
+	}
+}
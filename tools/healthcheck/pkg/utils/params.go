@@ -0,0 +1,145 @@
+// /*
+// Copyright 2025 IQiYi Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// */
+
+package utils
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// Variables in the values of checker and actioner params, in the form of
+// "{name}", which are expanded for the concrete target.
+const (
+	ParamVarIP    = "ip"    // IP of the target
+	ParamVarPort  = "port"  // port of the target
+	ParamVarProto = "proto" // protocol of the target, "tcp", "udp", ...
+	ParamVarAF    = "af"    // address family of the target, "ipv4" or "ipv6"
+	ParamVarAddr  = "addr"  // "IP:port" of the target, "[IP]:port" for IPv6
+	ParamVarVIP   = "vip"   // IP of the virtual service of the target
+	ParamVarVPort = "vport" // port of the virtual service of the target
+)
+
+// ParamVars provides the values of the param variables.
+type ParamVars struct {
+	Target *L3L4Addr // for {ip}, {port}, {proto}, {af} and {addr}
+	VIP    *L3L4Addr // for {vip} and {vport}
+}
+
+// SampleParamVars are placeholders to validate params without a concrete target.
+var SampleParamVars = &ParamVars{
+	Target: &L3L4Addr{IP: net.ParseIP("192.0.2.1"), Port: 80, Proto: IPProtoTCP},
+	VIP:    &L3L4Addr{IP: net.ParseIP("198.51.100.1"), Port: 80, Proto: IPProtoTCP},
+}
+
+// lookup returns the value of variable `name`.
+func (v *ParamVars) lookup(name string) (string, error) {
+	var target, vip *L3L4Addr
+	if v != nil {
+		target, vip = v.Target, v.VIP
+	}
+	switch name {
+	case ParamVarIP, ParamVarPort, ParamVarProto, ParamVarAF, ParamVarAddr:
+		if target == nil {
+			return "", fmt.Errorf("variable {%s} unavailable without target", name)
+		}
+	case ParamVarVIP, ParamVarVPort:
+		if vip == nil {
+			return "", fmt.Errorf("variable {%s} unavailable without virtual service", name)
+		}
+	default:
+		return "", fmt.Errorf("unknown variable {%s}", name)
+	}
+
+	switch name {
+	case ParamVarIP:
+		return target.IP.String(), nil
+	case ParamVarPort:
+		return strconv.Itoa(int(target.Port)), nil
+	case ParamVarProto:
+		return strings.ToLower(target.Proto.String()), nil
+	case ParamVarAF:
+		return strings.ToLower(IPAF(target.IP).String()), nil
+	case ParamVarAddr:
+		return target.Addr(), nil
+	case ParamVarVIP:
+		return vip.IP.String(), nil
+	}
+	return strconv.Itoa(int(vip.Port)), nil // ParamVarVPort
+}
+
+// isParamVarName tells if `s` is in the form of a variable name.
+func isParamVarName(s string) bool {
+	if len(s) == 0 {
+		return false
+	}
+	for _, r := range s {
+		if (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') {
+			return false
+		}
+	}
+	return true
+}
+
+// ExpandParam returns `val` with the variables expanded by `vars`. A brace not
+// enclosing a variable name, such as those of JSON or the "{{.IP}}" payload
+// templates, is kept as it is.
+func ExpandParam(val string, vars *ParamVars) (string, error) {
+	if !strings.Contains(val, "{") {
+		return val, nil
+	}
+	var b strings.Builder
+	for {
+		i := strings.IndexByte(val, '{')
+		if i < 0 {
+			break
+		}
+		j := strings.IndexByte(val[i+1:], '}')
+		if j < 0 || !isParamVarName(val[i+1:i+1+j]) {
+			b.WriteString(val[:i+1])
+			val = val[i+1:]
+			continue
+		}
+		sub, err := vars.lookup(val[i+1 : i+1+j])
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(val[:i])
+		b.WriteString(sub)
+		val = val[i+j+2:]
+	}
+	b.WriteString(val)
+	return b.String(), nil
+}
+
+// ExpandParams returns a copy of `params` with the variables in the values
+// expanded by `vars`. The params are shared by all targets of a config, so
+// they are never modified in place.
+func ExpandParams(params map[string]string, vars *ParamVars) (map[string]string, error) {
+	if params == nil {
+		return nil, nil
+	}
+	expanded := make(map[string]string, len(params))
+	for name, val := range params {
+		var err error
+		if expanded[name], err = ExpandParam(val, vars); err != nil {
+			return nil, fmt.Errorf("param %s: %v", name, err)
+		}
+	}
+	return expanded, nil
+}
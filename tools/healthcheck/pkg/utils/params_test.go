@@ -0,0 +1,98 @@
+// /*
+// Copyright 2025 IQiYi Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// */
+
+package utils
+
+import (
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExpandParams(t *testing.T) {
+	v4 := &ParamVars{
+		Target: &L3L4Addr{IP: net.ParseIP("192.168.88.10"), Port: 8080, Proto: IPProtoTCP},
+		VIP:    &L3L4Addr{IP: net.ParseIP("10.0.0.1"), Port: 80, Proto: IPProtoTCP},
+	}
+	v6 := &ParamVars{
+		Target: &L3L4Addr{IP: net.ParseIP("fe80::10"), Port: 53, Proto: IPProtoUDP, Zone: "eth1"},
+		VIP:    &L3L4Addr{IP: net.ParseIP("2001:db8::1"), Port: 5353, Proto: IPProtoUDP},
+	}
+	params := map[string]string{
+		"vars":    "{ip} {port} {proto} {af} {addr} {vip} {vport}",
+		"host":    "{ip}.backend.internal",
+		"uri":     "http://{addr}/healthz?vip={vip}:{vport}",
+		"json":    `{"ip":"{ip}"}`,
+		"payload": "{{.IP}}:{port}",
+		"braces":  "{} { ip } {{ip}} {ip",
+	}
+	for _, tc := range []struct {
+		vars   *ParamVars
+		expect map[string]string
+	}{
+		{v4, map[string]string{
+			"vars":    "192.168.88.10 8080 tcp ipv4 192.168.88.10:8080 10.0.0.1 80",
+			"host":    "192.168.88.10.backend.internal",
+			"uri":     "http://192.168.88.10:8080/healthz?vip=10.0.0.1:80",
+			"json":    `{"ip":"192.168.88.10"}`,
+			"payload": "{{.IP}}:8080",
+			"braces":  "{} { ip } {192.168.88.10} {ip",
+		}},
+		{v6, map[string]string{
+			"vars":    "fe80::10 53 udp ipv6 [fe80::10%eth1]:53 2001:db8::1 5353",
+			"host":    "fe80::10.backend.internal",
+			"uri":     "http://[fe80::10%eth1]:53/healthz?vip=2001:db8::1:5353",
+			"json":    `{"ip":"fe80::10"}`,
+			"payload": "{{.IP}}:53",
+			"braces":  "{} { ip } {fe80::10} {ip",
+		}},
+	} {
+		expanded, err := ExpandParams(params, tc.vars)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !reflect.DeepEqual(expanded, tc.expect) {
+			t.Errorf("expect %v, got %v", tc.expect, expanded)
+		}
+	}
+	if params["host"] != "{ip}.backend.internal" {
+		t.Errorf("params modified in place: %v", params)
+	}
+
+	if expanded, err := ExpandParams(nil, v4); err != nil || expanded != nil {
+		t.Errorf("unexpected expansion of nil params: %v, %v", expanded, err)
+	}
+	if expanded, err := ExpandParams(map[string]string{"uri": "/"}, nil); err != nil || expanded["uri"] != "/" {
+		t.Errorf("unexpected expansion without vars: %v, %v", expanded, err)
+	}
+
+	for _, tc := range []struct {
+		val  string
+		vars *ParamVars
+		err  string
+	}{
+		{"{host}", v4, "unknown variable {host}"},
+		{"{IP}", v4, "unknown variable {IP}"},
+		{"{ip}", nil, "unavailable without target"},
+		{"{vport}", &ParamVars{Target: v4.Target}, "unavailable without virtual service"},
+	} {
+		_, err := ExpandParams(map[string]string{"p": tc.val}, tc.vars)
+		if err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("%q: expect error %q, got %v", tc.val, tc.err, err)
+		}
+	}
+}
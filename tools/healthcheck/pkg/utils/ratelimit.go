@@ -0,0 +1,161 @@
+// /*
+// Copyright 2025 IQiYi Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// */
+
+package utils
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+const (
+	DefaultLogBurst  = 10
+	DefaultLogPeriod = 10 * time.Second
+
+	// LogVerboseSuppressed is the verbosity to log the suppressed messages.
+	LogVerboseSuppressed = 9
+)
+
+type logSeverity int
+
+const (
+	logWarning logSeverity = iota
+	logError
+)
+
+// LogLimiter bounds the logs of repeated messages with a token bucket per
+// message key, which allows `burst` messages in each `period` at most. The
+// messages beyond the limit are logged only at verbosity LogVerboseSuppressed,
+// and collapsed into a summary "N <key> in last <period>" at the end of the
+// period. The key should be of low cardinality, such as "UDP checks failed",
+// rather than containing the target address.
+type LogLimiter struct {
+	burst  int
+	period time.Duration
+
+	lock    sync.Mutex
+	buckets map[string]*logBucket
+
+	// hooks for tests
+	now  func() time.Time
+	emit func(severity logSeverity, msg string)
+}
+
+type logBucket struct {
+	tokens     float64
+	refilled   time.Time
+	suppressed int
+	severity   logSeverity
+}
+
+func NewLogLimiter(burst int, period time.Duration) *LogLimiter {
+	if burst <= 0 {
+		burst = DefaultLogBurst
+	}
+	if period <= 0 {
+		period = DefaultLogPeriod
+	}
+	return &LogLimiter{
+		burst:   burst,
+		period:  period,
+		buckets: make(map[string]*logBucket),
+		now:     time.Now,
+		emit:    emitLog,
+	}
+}
+
+func emitLog(severity logSeverity, msg string) {
+	if severity == logError {
+		glog.ErrorDepth(3, msg)
+	} else {
+		glog.WarningDepth(3, msg)
+	}
+}
+
+// Allow consumes a token of `key`, and returns false if no tokens left. The
+// caller should count on the limiter to log a summary if false is returned.
+func (l *LogLimiter) Allow(key string) bool {
+	return l.allow(key, logWarning)
+}
+
+func (l *LogLimiter) allow(key string, severity logSeverity) bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	now := l.now()
+	bucket, ok := l.buckets[key]
+	if !ok {
+		bucket = &logBucket{tokens: float64(l.burst), refilled: now}
+		l.buckets[key] = bucket
+	}
+
+	rate := float64(l.burst) / l.period.Seconds()
+	bucket.tokens += now.Sub(bucket.refilled).Seconds() * rate
+	if bucket.tokens > float64(l.burst) {
+		bucket.tokens = float64(l.burst)
+	}
+	bucket.refilled = now
+
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		return true
+	}
+
+	if bucket.suppressed == 0 {
+		time.AfterFunc(l.period, func() { l.summarize(key) })
+	}
+	bucket.suppressed++
+	if severity > bucket.severity {
+		bucket.severity = severity
+	}
+	return false
+}
+
+func (l *LogLimiter) summarize(key string) {
+	l.lock.Lock()
+	bucket, ok := l.buckets[key]
+	if !ok || bucket.suppressed == 0 {
+		l.lock.Unlock()
+		return
+	}
+	n, severity := bucket.suppressed, bucket.severity
+	bucket.suppressed, bucket.severity = 0, logWarning
+	l.lock.Unlock()
+
+	l.emit(severity, fmt.Sprintf("%d %s in last %v", n, key, l.period))
+}
+
+// Warningf logs at warning level if allowed by the limiter of `key`.
+func (l *LogLimiter) Warningf(key, format string, args ...interface{}) {
+	l.logf(key, logWarning, format, args...)
+}
+
+// Errorf logs at error level if allowed by the limiter of `key`.
+func (l *LogLimiter) Errorf(key, format string, args ...interface{}) {
+	l.logf(key, logError, format, args...)
+}
+
+func (l *LogLimiter) logf(key string, severity logSeverity, format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	if l.allow(key, severity) {
+		l.emit(severity, msg)
+		return
+	}
+	glog.V(LogVerboseSuppressed).Info("(suppressed) " + msg)
+}
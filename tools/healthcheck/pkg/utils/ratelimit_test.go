@@ -0,0 +1,91 @@
+// /*
+// Copyright 2025 IQiYi Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// */
+
+package utils
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+type logRecorder struct {
+	lock sync.Mutex
+	logs []string
+}
+
+func (r *logRecorder) emit(severity logSeverity, msg string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.logs = append(r.logs, fmt.Sprintf("%d:%s", severity, msg))
+}
+
+func (r *logRecorder) get() []string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return append([]string(nil), r.logs...)
+}
+
+func TestLogLimiter(t *testing.T) {
+	rec := &logRecorder{}
+	now := time.Now()
+	period := 200 * time.Millisecond
+	l := NewLogLimiter(3, period)
+	l.emit = rec.emit
+	l.now = func() time.Time { return now }
+
+	for i := 0; i < 10; i++ {
+		l.Warningf("UDP checks failed", "udp check %d failed", i)
+	}
+	l.Errorf("TCP checks failed", "tcp check failed")
+	logs := rec.get()
+	if len(logs) != 4 || logs[0] != "0:udp check 0 failed" || logs[2] != "0:udp check 2 failed" ||
+		logs[3] != "1:tcp check failed" {
+		t.Fatalf("unexpected logs before summary: %q", logs)
+	}
+
+	time.Sleep(period + 100*time.Millisecond)
+	logs = rec.get()
+	if len(logs) != 5 || logs[4] != "0:7 UDP checks failed in last 200ms" {
+		t.Fatalf("unexpected summary: %q", logs)
+	}
+
+	// Tokens are refilled at rate of burst/period.
+	now = now.Add(period/3 + time.Millisecond)
+	l.Warningf("UDP checks failed", "udp check refilled")
+	l.Errorf("UDP checks failed", "udp check suppressed")
+	time.Sleep(period + 100*time.Millisecond)
+	logs = rec.get()
+	if len(logs) != 7 || logs[5] != "0:udp check refilled" ||
+		logs[6] != "1:1 UDP checks failed in last 200ms" {
+		t.Fatalf("unexpected logs after refill: %q", logs)
+	}
+
+	// Tokens never exceed burst.
+	now = now.Add(time.Hour)
+	for i := 0; i < 5; i++ {
+		l.Warningf("UDP checks failed", "udp check %d failed", i)
+	}
+	if logs = rec.get(); len(logs) != 10 {
+		t.Fatalf("expect 3 more logs after idle, got %q", logs[7:])
+	}
+	for i := 0; i < 4; i++ {
+		if ok := l.Allow("TCP checks failed"); ok != (i < 3) {
+			t.Errorf("Allow %d: expect %v, got %v", i, i < 3, ok)
+		}
+	}
+}
@@ -29,11 +29,17 @@ type Shutdowner interface {
 	Shutdown()
 }
 
+// Reloader is an interface for a server that can reload its configs.
+type Reloader interface {
+	Reload()
+}
+
 var signalNames = map[syscall.Signal]string{
 	syscall.SIGINT:  "SIGINT",
 	syscall.SIGQUIT: "SIGQUIT",
 	syscall.SIGTERM: "SIGTERM",
 	syscall.SIGUSR1: "SIGUSR1",
+	syscall.SIGHUP:  "SIGHUP",
 }
 
 // signalName returns a string containing the standard name for a given signal.
@@ -65,6 +71,19 @@ func ShutdownHandler(server Shutdowner) {
 	}()
 }
 
+// ReloadHandler configures signal handling and initiates a config reload if
+// a SIGHUP is received by the process.
+func ReloadHandler(server Reloader) {
+	sigc := make(chan os.Signal, 1)
+	signal.Notify(sigc, syscall.SIGHUP)
+	go func() {
+		for range sigc {
+			log.Infof("Received %v, initiating config reload...", signalName(syscall.SIGHUP))
+			server.Reload()
+		}
+	}()
+}
+
 func dumpStacks() {
 	buf := make([]byte, 16384)
 	buf = buf[:runtime.Stack(buf, true)]
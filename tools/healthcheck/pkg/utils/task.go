@@ -31,6 +31,14 @@ type Task interface {
 	Job(ctx context.Context)
 }
 
+// TriggeredTask is a Task whose Job can also be triggered on demand besides
+// the regular schedules. Triggers arriving while the Job is running are queued
+// up, and the Job is never executed concurrently.
+type TriggeredTask interface {
+	Task
+	Trigger() <-chan struct{}
+}
+
 func RunTask(t Task, ctx context.Context, wg *sync.WaitGroup, start <-chan time.Time) {
 	glog.Infof("Task %q started.", t.Name())
 	if wg != nil {
@@ -45,6 +53,11 @@ func RunTask(t Task, ctx context.Context, wg *sync.WaitGroup, start <-chan time.
 	glog.V(7).Infof("Task %q scheduled.", t.Name())
 	t.Job(ctx)
 
+	var trigger <-chan struct{}
+	if tt, ok := t.(TriggeredTask); ok {
+		trigger = tt.Trigger()
+	}
+
 	ticker := time.NewTicker(t.Interval())
 	for {
 		select {
@@ -55,6 +68,9 @@ func RunTask(t Task, ctx context.Context, wg *sync.WaitGroup, start <-chan time.
 		case <-ticker.C:
 			glog.V(7).Infof("Task %q scheduled.", t.Name())
 			t.Job(ctx)
+		case <-trigger:
+			glog.V(7).Infof("Task %q triggered.", t.Name())
+			t.Job(ctx)
 		}
 	}
 }
@@ -0,0 +1,286 @@
+// /*
+// Copyright 2025 IQiYi Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// */
+
+// Package websocket implements the client side pieces of RFC 6455 used by the
+// health checkers: the opening handshake keys and the framing of messages.
+// Only what a check needs is supported, i.e. no extensions and no message
+// reassembly.
+package websocket
+
+import (
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// GUID is the magic string to derive Sec-WebSocket-Accept from the key.
+const GUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// Version is the value of the Sec-WebSocket-Version header.
+const Version = "13"
+
+// MaxControlPayload is the max payload size of control frames.
+const MaxControlPayload = 125
+
+// Opcode is the opcode of a frame.
+type Opcode byte
+
+const (
+	OpContinuation Opcode = 0x0
+	OpText         Opcode = 0x1
+	OpBinary       Opcode = 0x2
+	OpClose        Opcode = 0x8
+	OpPing         Opcode = 0x9
+	OpPong         Opcode = 0xa
+)
+
+// IsControl tells if the opcode is of a control frame.
+func (op Opcode) IsControl() bool {
+	return op&0x8 != 0
+}
+
+// Close status codes.
+const (
+	CloseNormal     = 1000
+	CloseGoingAway  = 1001
+	CloseProtoError = 1002
+	CloseNoStatus   = 1005 // never sent, reported if the close frame has no code
+)
+
+var (
+	ErrMaskedFrame    = errors.New("masked frame from server")
+	ErrFrameTooLarge  = errors.New("frame payload too large")
+	ErrBadControl     = errors.New("malformed control frame")
+	ErrReservedBits   = errors.New("reserved bits set without extension")
+	ErrInvalidOpcode  = errors.New("invalid opcode")
+	ErrInvalidLength  = errors.New("invalid frame length")
+	errControlPayload = fmt.Errorf("control frame payload exceeds %d bytes", MaxControlPayload)
+)
+
+// Header is the header of a frame.
+type Header struct {
+	Fin    bool
+	Opcode Opcode
+	Masked bool
+	Mask   [4]byte
+	Length int64
+}
+
+// NewKey returns a random Sec-WebSocket-Key.
+func NewKey() (string, error) {
+	nonce := make([]byte, 16)
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(nonce), nil
+}
+
+// AcceptKey returns the Sec-WebSocket-Accept expected for `key`.
+func AcceptKey(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key + GUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// HeaderHasToken tells if the comma-separated header `name` contains `token`,
+// case insensitive.
+func HeaderHasToken(header http.Header, name, token string) bool {
+	for _, val := range header.Values(name) {
+		for _, t := range strings.Split(val, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// CheckUpgrade validates the opening handshake response headers for `key`.
+func CheckUpgrade(header http.Header, key string) error {
+	if !strings.EqualFold(header.Get("Upgrade"), "websocket") ||
+		!HeaderHasToken(header, "Connection", "upgrade") {
+		return errors.New("connection not upgraded to websocket")
+	}
+	if accept := header.Get("Sec-WebSocket-Accept"); accept != AcceptKey(key) {
+		return fmt.Errorf("unexpected Sec-WebSocket-Accept %q", accept)
+	}
+	return nil
+}
+
+// MaskBytes applies `mask` to `b` in place, where `pos` is the offset of `b`
+// in the payload. It returns the offset following `b`.
+func MaskBytes(mask [4]byte, pos int, b []byte) int {
+	for i := range b {
+		b[i] ^= mask[(pos+i)%4]
+	}
+	return (pos + len(b)) % 4
+}
+
+// AppendFrame appends the frame of `h` and `payload` to `dst`. The payload is
+// masked with h.Mask if h.Masked, and h.Length is ignored.
+func AppendFrame(dst []byte, h Header, payload []byte) []byte {
+	b0 := byte(h.Opcode) & 0x0f
+	if h.Fin {
+		b0 |= 0x80
+	}
+	var b1 byte
+	if h.Masked {
+		b1 = 0x80
+	}
+	switch n := len(payload); {
+	case n <= 125:
+		dst = append(dst, b0, b1|byte(n))
+	case n <= 0xffff:
+		dst = append(dst, b0, b1|126)
+		dst = binary.BigEndian.AppendUint16(dst, uint16(n))
+	default:
+		dst = append(dst, b0, b1|127)
+		dst = binary.BigEndian.AppendUint64(dst, uint64(n))
+	}
+	if !h.Masked {
+		return append(dst, payload...)
+	}
+	dst = append(dst, h.Mask[:]...)
+	off := len(dst)
+	dst = append(dst, payload...)
+	MaskBytes(h.Mask, 0, dst[off:])
+	return dst
+}
+
+// WriteFrame writes the frame of `h` and `payload` to `w`.
+func WriteFrame(w io.Writer, h Header, payload []byte) error {
+	if h.Opcode.IsControl() && len(payload) > MaxControlPayload {
+		return errControlPayload
+	}
+	frame := AppendFrame(make([]byte, 0, 14+len(payload)), h, payload)
+	for len(frame) > 0 {
+		n, err := w.Write(frame)
+		if err != nil {
+			return err
+		}
+		frame = frame[n:]
+	}
+	return nil
+}
+
+// WriteClientFrame writes a final frame of `op` with a random mask, as
+// required for the frames sent by clients.
+func WriteClientFrame(w io.Writer, op Opcode, payload []byte) error {
+	h := Header{Fin: true, Opcode: op, Masked: true}
+	if _, err := io.ReadFull(rand.Reader, h.Mask[:]); err != nil {
+		return err
+	}
+	return WriteFrame(w, h, payload)
+}
+
+// ClosePayload returns the payload of a close frame with `code` and `reason`.
+func ClosePayload(code int, reason string) []byte {
+	payload := binary.BigEndian.AppendUint16(nil, uint16(code))
+	return append(payload, reason...)
+}
+
+// CloseCode returns the status code in the payload of a close frame.
+func CloseCode(payload []byte) int {
+	if len(payload) < 2 {
+		return CloseNoStatus
+	}
+	return int(binary.BigEndian.Uint16(payload))
+}
+
+// ReadHeader reads a frame header from `r`.
+func ReadHeader(r io.Reader) (Header, error) {
+	var h Header
+	var buf [8]byte
+	if _, err := io.ReadFull(r, buf[:2]); err != nil {
+		return h, err
+	}
+	if buf[0]&0x70 != 0 {
+		return h, ErrReservedBits
+	}
+	h.Fin = buf[0]&0x80 != 0
+	h.Opcode = Opcode(buf[0] & 0x0f)
+	switch h.Opcode {
+	case OpContinuation, OpText, OpBinary, OpClose, OpPing, OpPong:
+	default:
+		return h, fmt.Errorf("%w 0x%x", ErrInvalidOpcode, byte(h.Opcode))
+	}
+	h.Masked = buf[1]&0x80 != 0
+	h.Length = int64(buf[1] & 0x7f)
+	switch h.Length {
+	case 126:
+		if _, err := io.ReadFull(r, buf[:2]); err != nil {
+			return h, err
+		}
+		h.Length = int64(binary.BigEndian.Uint16(buf[:2]))
+	case 127:
+		if _, err := io.ReadFull(r, buf[:8]); err != nil {
+			return h, err
+		}
+		n := binary.BigEndian.Uint64(buf[:8])
+		if n>>63 != 0 {
+			return h, ErrInvalidLength
+		}
+		h.Length = int64(n)
+	}
+	if h.Opcode.IsControl() && (!h.Fin || h.Length > MaxControlPayload) {
+		return h, fmt.Errorf("%w 0x%x", ErrBadControl, byte(h.Opcode))
+	}
+	if h.Masked {
+		if _, err := io.ReadFull(r, h.Mask[:]); err != nil {
+			return h, err
+		}
+	}
+	return h, nil
+}
+
+// ReadFrame reads a frame from `r` and returns its header and the unmasked
+// payload. The payload larger than `limit` is discarded with ErrFrameTooLarge
+// returned, so that the next frame can still be read.
+func ReadFrame(r io.Reader, limit int64) (Header, []byte, error) {
+	h, err := ReadHeader(r)
+	if err != nil {
+		return h, nil, err
+	}
+	if h.Length > limit {
+		if _, err := io.CopyN(io.Discard, r, h.Length); err != nil {
+			return h, nil, err
+		}
+		return h, nil, ErrFrameTooLarge
+	}
+	payload := make([]byte, h.Length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return h, nil, err
+	}
+	if h.Masked {
+		MaskBytes(h.Mask, 0, payload)
+	}
+	return h, payload, nil
+}
+
+// ReadServerFrame reads a frame sent by server, which must not be masked.
+func ReadServerFrame(r io.Reader, limit int64) (Header, []byte, error) {
+	h, payload, err := ReadFrame(r, limit)
+	if err == nil && h.Masked {
+		return h, nil, ErrMaskedFrame
+	}
+	return h, payload, err
+}
@@ -0,0 +1,226 @@
+// /*
+// Copyright 2025 IQiYi Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// */
+
+package websocket
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+)
+
+// rfc6455Mask is the masking key in the examples of RFC 6455 section 5.7.
+var rfc6455Mask = [4]byte{0x37, 0xfa, 0x21, 0x3d}
+
+func rfc6455Payload(size int) []byte {
+	payload := make([]byte, size)
+	for i := range payload {
+		payload[i] = byte(i)
+	}
+	return payload
+}
+
+// rfc6455Frames are the examples of RFC 6455 section 5.7.
+var rfc6455Frames = []struct {
+	name    string
+	header  Header
+	payload []byte
+	frame   []byte // frame header, followed by the payload if it's large
+}{
+	{
+		name:    "unmasked text",
+		header:  Header{Fin: true, Opcode: OpText},
+		payload: []byte("Hello"),
+		frame:   []byte{0x81, 0x05, 0x48, 0x65, 0x6c, 0x6c, 0x6f},
+	},
+	{
+		name:    "masked text",
+		header:  Header{Fin: true, Opcode: OpText, Masked: true, Mask: rfc6455Mask},
+		payload: []byte("Hello"),
+		frame:   []byte{0x81, 0x85, 0x37, 0xfa, 0x21, 0x3d, 0x7f, 0x9f, 0x4d, 0x51, 0x58},
+	},
+	{
+		name:    "fragmented text first",
+		header:  Header{Opcode: OpText},
+		payload: []byte("Hel"),
+		frame:   []byte{0x01, 0x03, 0x48, 0x65, 0x6c},
+	},
+	{
+		name:    "fragmented text last",
+		header:  Header{Fin: true, Opcode: OpContinuation},
+		payload: []byte("lo"),
+		frame:   []byte{0x80, 0x02, 0x6c, 0x6f},
+	},
+	{
+		name:    "unmasked ping",
+		header:  Header{Fin: true, Opcode: OpPing},
+		payload: []byte("Hello"),
+		frame:   []byte{0x89, 0x05, 0x48, 0x65, 0x6c, 0x6c, 0x6f},
+	},
+	{
+		name:    "masked pong",
+		header:  Header{Fin: true, Opcode: OpPong, Masked: true, Mask: rfc6455Mask},
+		payload: []byte("Hello"),
+		frame:   []byte{0x8a, 0x85, 0x37, 0xfa, 0x21, 0x3d, 0x7f, 0x9f, 0x4d, 0x51, 0x58},
+	},
+	{
+		name:    "256 bytes binary",
+		header:  Header{Fin: true, Opcode: OpBinary},
+		payload: rfc6455Payload(256),
+		frame:   []byte{0x82, 0x7e, 0x01, 0x00},
+	},
+	{
+		name:    "64KiB binary",
+		header:  Header{Fin: true, Opcode: OpBinary},
+		payload: rfc6455Payload(65536),
+		frame:   []byte{0x82, 0x7f, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00},
+	},
+}
+
+func TestAcceptKey(t *testing.T) {
+	// the example of RFC 6455 section 1.3
+	if accept := AcceptKey("dGhlIHNhbXBsZSBub25jZQ=="); accept != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("unexpected accept key %q", accept)
+	}
+
+	key, err := NewKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key2, _ := NewKey(); key2 == key || len(key) != 24 {
+		t.Errorf("unexpected random keys %q, %q", key, key2)
+	}
+
+	header := http.Header{}
+	header.Set("Upgrade", "WebSocket")
+	header.Set("Connection", "keep-alive, Upgrade")
+	header.Set("Sec-WebSocket-Accept", AcceptKey(key))
+	if err := CheckUpgrade(header, key); err != nil {
+		t.Errorf("unexpected upgrade error: %v", err)
+	}
+	header.Set("Sec-WebSocket-Accept", "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=")
+	if err := CheckUpgrade(header, key); err == nil {
+		t.Errorf("expect error on wrong accept key")
+	}
+	header.Set("Sec-WebSocket-Accept", AcceptKey(key))
+	header.Set("Connection", "keep-alive")
+	if err := CheckUpgrade(header, key); err == nil {
+		t.Errorf("expect error on connection not upgraded")
+	}
+}
+
+func TestFrameEncode(t *testing.T) {
+	for _, tc := range rfc6455Frames {
+		expected := tc.frame
+		if len(tc.payload) > 125 {
+			expected = append(append([]byte{}, tc.frame...), tc.payload...)
+		}
+		if frame := AppendFrame(nil, tc.header, tc.payload); !bytes.Equal(frame, expected) {
+			t.Errorf("%s: unexpected frame % x", tc.name, frame[:len(tc.frame)])
+		}
+		var buf bytes.Buffer
+		if err := WriteFrame(&buf, tc.header, tc.payload); err != nil || !bytes.Equal(buf.Bytes(), expected) {
+			t.Errorf("%s: unexpected frame written, err: %v", tc.name, err)
+		}
+	}
+}
+
+func TestFrameDecode(t *testing.T) {
+	for _, tc := range rfc6455Frames {
+		frame := tc.frame
+		if len(tc.payload) > 125 {
+			frame = append(append([]byte{}, tc.frame...), tc.payload...)
+		}
+		r := bytes.NewReader(frame)
+		h, payload, err := ReadFrame(r, 1<<20)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+			continue
+		}
+		expected := tc.header
+		expected.Length = int64(len(tc.payload))
+		if h != expected {
+			t.Errorf("%s: unexpected header %+v", tc.name, h)
+		}
+		if !bytes.Equal(payload, tc.payload) {
+			t.Errorf("%s: unexpected payload", tc.name)
+		}
+		if r.Len() != 0 {
+			t.Errorf("%s: %d bytes left unread", tc.name, r.Len())
+		}
+
+		_, _, err = ReadServerFrame(bytes.NewReader(frame), 1<<20)
+		if tc.header.Masked != errors.Is(err, ErrMaskedFrame) {
+			t.Errorf("%s: unexpected server frame error: %v", tc.name, err)
+		}
+	}
+}
+
+func TestFrameDecodeErrors(t *testing.T) {
+	cases := []struct {
+		name  string
+		frame []byte
+		err   error
+	}{
+		{"reserved bits", []byte{0xc1, 0x00}, ErrReservedBits},
+		{"reserved opcode", []byte{0x83, 0x00}, ErrInvalidOpcode},
+		{"fragmented ping", []byte{0x09, 0x00}, ErrBadControl},
+		{"large ping", []byte{0x89, 0x7e, 0x00, 0x7e}, ErrBadControl},
+		{"invalid length", []byte{0x82, 0x7f, 0x80, 0, 0, 0, 0, 0, 0, 0}, ErrInvalidLength},
+		{"truncated header", []byte{0x81}, io.ErrUnexpectedEOF},
+		{"truncated length", []byte{0x82, 0x7e, 0x01}, io.ErrUnexpectedEOF},
+		{"truncated payload", []byte{0x81, 0x05, 0x48}, io.ErrUnexpectedEOF},
+	}
+	for _, tc := range cases {
+		if _, _, err := ReadFrame(bytes.NewReader(tc.frame), 1<<20); !errors.Is(err, tc.err) {
+			t.Errorf("%s: expect error %v, got %v", tc.name, tc.err, err)
+		}
+	}
+
+	// The frame too large is skipped and the next frame is readable.
+	frames := AppendFrame(nil, Header{Fin: true, Opcode: OpText}, []byte("Hello"))
+	frames = AppendFrame(frames, Header{Fin: true, Opcode: OpPong}, []byte("ok"))
+	r := bytes.NewReader(frames)
+	if _, _, err := ReadFrame(r, 4); err != ErrFrameTooLarge {
+		t.Errorf("expect error %v, got %v", ErrFrameTooLarge, err)
+	}
+	if h, payload, err := ReadFrame(r, 4); err != nil || h.Opcode != OpPong || string(payload) != "ok" {
+		t.Errorf("unexpected frame %+v %q after the skipped one, err: %v", h, payload, err)
+	}
+
+	if err := WriteFrame(io.Discard, Header{Fin: true, Opcode: OpPing}, make([]byte, 126)); err == nil {
+		t.Errorf("expect error on large ping")
+	}
+}
+
+func TestClientFrame(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteClientFrame(&buf, OpClose, ClosePayload(CloseNormal, "bye")); err != nil {
+		t.Fatal(err)
+	}
+	h, payload, err := ReadFrame(&buf, MaxControlPayload)
+	if err != nil || !h.Masked || !h.Fin || h.Opcode != OpClose {
+		t.Fatalf("unexpected client frame %+v, err: %v", h, err)
+	}
+	if CloseCode(payload) != CloseNormal || string(payload[2:]) != "bye" {
+		t.Errorf("unexpected close payload %q", payload)
+	}
+	if CloseCode(nil) != CloseNoStatus {
+		t.Errorf("unexpected close code of empty payload")
+	}
+}